@@ -0,0 +1,277 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Catker/acmeDeliver/pkg/config"
+	"github.com/Catker/acmeDeliver/pkg/domainmatch"
+)
+
+// completionProg 补全脚本注册的命令名
+const completionProg = "acmedeliver-client"
+
+// 取值固定的参数及其候选值
+var completionChoices = map[string][]string{
+	"output":      {outputText, outputJSON},
+	"get":         {getCert, getKey, getFullchain, getAll},
+	"completion":  {"bash", "zsh", "fish"},
+	"init-system": {"systemd", "openrc"},
+	"sort":        {sortExpiry, sortName, sortUpdated},
+}
+
+// 取值为域名的参数，补全时读取配置文件中的域名（-d 支持逗号分隔的多个域名）
+var completionDomainFlags = map[string]bool{
+	"d":            true,
+	"force-domain": true,
+}
+
+// 取值为文件路径的参数
+var completionFileFlags = map[string]bool{
+	"c":   true,
+	"out": true,
+}
+
+// completionFlag 补全脚本中的一个参数
+type completionFlag struct {
+	name    string
+	usage   string
+	isBool  bool
+	choices []string
+	domain  bool
+	file    bool
+}
+
+// option 返回参数的写法：单字母使用 -x，其余使用 --name
+func (f completionFlag) option() string {
+	if len(f.name) == 1 {
+		return "-" + f.name
+	}
+	return "--" + f.name
+}
+
+// collectCompletionFlags 返回 fs 中注册的全部参数，按名称排序
+func collectCompletionFlags(fs *flag.FlagSet) []completionFlag {
+	var flags []completionFlag
+	fs.VisitAll(func(f *flag.Flag) {
+		bf, ok := f.Value.(interface{ IsBoolFlag() bool })
+		flags = append(flags, completionFlag{
+			name:    f.Name,
+			usage:   f.Usage,
+			isBool:  ok && bf.IsBoolFlag(),
+			choices: completionChoices[f.Name],
+			domain:  completionDomainFlags[f.Name],
+			file:    completionFileFlags[f.Name],
+		})
+	})
+	sort.Slice(flags, func(i, j int) bool { return flags[i].name < flags[j].name })
+	return flags
+}
+
+// writeCompletion 输出 bash、zsh 或 fish 补全脚本，覆盖 fs 中注册的全部参数
+// 域名通过 --complete-domains 读取 -c 指定（或当前目录 config.yaml）的配置文件动态补全
+func writeCompletion(w io.Writer, shell string, fs *flag.FlagSet) error {
+	flags := collectCompletionFlags(fs)
+	var script string
+	switch shell {
+	case "bash":
+		script = bashCompletion(flags)
+	case "zsh":
+		script = zshCompletion(flags)
+	case "fish":
+		script = fishCompletion(flags)
+	default:
+		return fmt.Errorf("不支持的 shell %q，可选 bash、zsh 或 fish", shell)
+	}
+	_, err := io.WriteString(w, script)
+	return err
+}
+
+// bashCompletion 生成 bash 补全脚本
+func bashCompletion(flags []completionFlag) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `# %[1]s bash 补全
+# 加载: source <(%[1]s --completion bash)
+
+_acmedeliver_client_domains() {
+    local i config=()
+    for ((i = 1; i < COMP_CWORD - 1; i++)); do
+        case "${COMP_WORDS[i]}" in
+            -c|--c) config=(-c "${COMP_WORDS[i+1]}") ;;
+        esac
+    done
+    "${COMP_WORDS[0]}" --complete-domains "${config[@]}" 2>/dev/null
+}
+
+_acmedeliver_client() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local prev="${COMP_WORDS[COMP_CWORD-1]}"
+    local prefix=""
+    case "$prev" in
+`, completionProg)
+
+	var options, valueOptions []string
+	for _, f := range flags {
+		options = append(options, f.option())
+		patterns := "-" + f.name + "|--" + f.name
+		switch {
+		case f.isBool:
+		case len(f.choices) > 0:
+			fmt.Fprintf(&b, "        %s)\n            COMPREPLY=($(compgen -W %q -- \"$cur\"))\n            return ;;\n",
+				patterns, strings.Join(f.choices, " "))
+		case f.domain:
+			fmt.Fprintf(&b, "        %s)\n", patterns)
+			b.WriteString("            [[ \"$cur\" == *,* ]] && prefix=\"${cur%,*},\"\n")
+			b.WriteString("            COMPREPLY=($(compgen -P \"$prefix\" -W \"$(_acmedeliver_client_domains)\" -- \"${cur##*,}\"))\n")
+			b.WriteString("            return ;;\n")
+		case f.file:
+			fmt.Fprintf(&b, "        %s)\n            COMPREPLY=($(compgen -f -- \"$cur\"))\n            return ;;\n", patterns)
+		default:
+			valueOptions = append(valueOptions, patterns)
+		}
+	}
+	if len(valueOptions) > 0 {
+		fmt.Fprintf(&b, "        %s)\n            return ;;\n", strings.Join(valueOptions, "|"))
+	}
+	fmt.Fprintf(&b, `    esac
+    COMPREPLY=($(compgen -W %q -- "$cur"))
+}
+
+complete -F _acmedeliver_client %s
+`, strings.Join(options, " "), completionProg)
+	return b.String()
+}
+
+// zshCompletion 生成 zsh 补全脚本
+func zshCompletion(flags []completionFlag) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `#compdef %[1]s
+# %[1]s zsh 补全
+# 加载: source <(%[1]s --completion zsh)，或保存为 fpath 中的 _%[1]s
+
+_acmedeliver_client_domains() {
+    local -a config domains
+    [[ -n ${opt_args[-c]} ]] && config=(-c ${opt_args[-c]})
+    domains=(${(f)"$(${words[1]} --complete-domains $config 2>/dev/null)"})
+    (( ${#domains} )) && _values -s , 'domain' $domains
+}
+
+_acmedeliver_client() {
+    _arguments \
+`, completionProg)
+	for _, f := range flags {
+		spec := f.option() + "[" + zshEscape(f.usage) + "]"
+		switch {
+		case f.isBool:
+		case len(f.choices) > 0:
+			spec += ":" + f.name + ":(" + strings.Join(f.choices, " ") + ")"
+		case f.domain:
+			spec += ":domain:_acmedeliver_client_domains"
+		case f.file:
+			spec += ":file:_files"
+		default:
+			spec += ":" + f.name + ": "
+		}
+		fmt.Fprintf(&b, "        %s \\\n", shellSingleQuote(spec))
+	}
+	fmt.Fprintf(&b, `        && return 0
+}
+
+if [[ $zsh_eval_context[-1] == loadautofunc ]]; then
+    _acmedeliver_client "$@"
+else
+    compdef _acmedeliver_client %s
+fi
+`, completionProg)
+	return b.String()
+}
+
+// fishCompletion 生成 fish 补全脚本
+func fishCompletion(flags []completionFlag) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `# %[1]s fish 补全
+# 加载: %[1]s --completion fish | source
+
+function __acmedeliver_client_domains
+    set -l tokens (commandline -opc)
+    set -l config
+    for i in (seq 2 (math (count $tokens) - 1))
+        if contains -- $tokens[$i] -c --c
+            set config -c $tokens[(math $i + 1)]
+        end
+    end
+    $tokens[1] --complete-domains $config 2>/dev/null
+end
+
+complete -c %[1]s -f
+`, completionProg)
+	for _, f := range flags {
+		opt := "-l " + f.name
+		if len(f.name) == 1 {
+			opt = "-s " + f.name
+		}
+		line := fmt.Sprintf("complete -c %s %s -d %s", completionProg, opt, shellSingleQuote(f.usage))
+		switch {
+		case f.isBool:
+		case len(f.choices) > 0:
+			line += " -x -a " + shellSingleQuote(strings.Join(f.choices, " "))
+		case f.domain:
+			line += " -x -a '(__acmedeliver_client_domains)'"
+		case f.file:
+			line += " -r -F"
+		default:
+			line += " -x"
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+// zshEscape 转义 _arguments 说明中的特殊字符
+func zshEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, ":", `\:`).Replace(s)
+}
+
+// shellSingleQuote 为 shell 加单引号（bash、zsh、fish 通用）
+func shellSingleQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// runCompleteDomains 输出配置文件中的域名（domains、subscribe 与非通配符站点），供补全脚本调用
+// 配置文件无法读取时不输出任何内容
+func runCompleteDomains(w io.Writer) {
+	path := configFile
+	if path == "" {
+		if _, err := os.Stat("config.yaml"); err == nil {
+			path = "config.yaml"
+		}
+	}
+	config.SetLaxConfig(true)
+	cfg, err := config.LoadClientConfigUnvalidated(path)
+	if err != nil {
+		return
+	}
+
+	seen := make(map[string]bool)
+	add := func(domain string) {
+		domain = strings.TrimSpace(domain)
+		if domain == "" || domainmatch.IsWildcard(domain) || seen[domain] {
+			return
+		}
+		seen[domain] = true
+		fmt.Fprintln(w, domain)
+	}
+	for _, domain := range cfg.Domains {
+		add(domain)
+	}
+	for _, domain := range cfg.Subscribe {
+		add(domain)
+	}
+	for _, site := range cfg.Sites {
+		add(site.Domain)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"os/exec"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// registeredFlags 在独立的 FlagSet 上注册客户端的全部参数并返回
+func registeredFlags(t *testing.T) *flag.FlagSet {
+	t.Helper()
+	oldCommandLine, oldArgs, oldUsage := flag.CommandLine, os.Args, flag.Usage
+	t.Cleanup(func() { flag.CommandLine, os.Args, flag.Usage = oldCommandLine, oldArgs, oldUsage })
+
+	flag.CommandLine = flag.NewFlagSet(completionProg, flag.ContinueOnError)
+	os.Args = []string{completionProg}
+	parseFlags()
+	return flag.CommandLine
+}
+
+func TestWriteCompletion(t *testing.T) {
+	fs := registeredFlags(t)
+
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		t.Run(shell, func(t *testing.T) {
+			var out bytes.Buffer
+			require.NoError(t, writeCompletion(&out, shell, fs))
+			script := out.String()
+
+			fs.VisitAll(func(f *flag.Flag) {
+				opt := completionFlag{name: f.Name}.option()
+				if shell == "fish" {
+					opt = "-l " + f.Name
+					if len(f.Name) == 1 {
+						opt = "-s " + f.Name
+					}
+				}
+				require.Regexp(t, `(\s|['"])`+regexp.QuoteMeta(opt)+`(\s|["[])`, script, "补全脚本缺少参数 %s", f.Name)
+			})
+			require.Contains(t, script, "--complete-domains", "域名应动态读取配置文件")
+			require.Contains(t, script, "text json", "--output 应补全可选值")
+
+			if path, err := exec.LookPath(shell); err == nil {
+				cmd := exec.Command(path, "-n")
+				cmd.Stdin = bytes.NewReader(out.Bytes())
+				output, err := cmd.CombinedOutput()
+				require.NoError(t, err, "%s 语法检查失败: %s", shell, output)
+			}
+		})
+	}
+
+	require.Error(t, writeCompletion(&bytes.Buffer{}, "powershell", fs))
+}
+
+func TestRunCompleteDomains(t *testing.T) {
+	oldConfigFile := configFile
+	configFile = writeTempConfig(t, `client:
+  domains: ["example.com", "example.org"]
+  subscribe: ["example.com", "*.example.net", "api.example.net"]
+  sites:
+    - domain: "**.example.com"
+    - domain: "www.example.org"
+`)
+	t.Cleanup(func() { configFile = oldConfigFile })
+
+	var out bytes.Buffer
+	runCompleteDomains(&out)
+	require.Equal(t, "example.com\nexample.org\napi.example.net\nwww.example.org\n", out.String(), "去重并跳过通配符")
+
+	configFile = "/nonexistent/config.yaml"
+	out.Reset()
+	runCompleteDomains(&out)
+	require.Empty(t, out.String())
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Catker/acmeDeliver/pkg/cert"
+	"github.com/Catker/acmeDeliver/pkg/client"
+	"github.com/Catker/acmeDeliver/pkg/config"
+	"github.com/Catker/acmeDeliver/pkg/websocket"
+)
+
+// --export 支持的导出格式
+const (
+	exportFormatPEM    = "pem"
+	exportFormatPKCS12 = "pkcs12"
+)
+
+// exportMetadataFile 每个域名目录中记录证书状态的文件
+const exportMetadataFile = "metadata.json"
+
+// validateExportArgs 校验 --export 参数：必须指定 --output-dir，--format 与 --export-password-file 只能配合 --export 使用
+func validateExportArgs(opts *CliOptions) error {
+	if !opts.Export {
+		if opts.OutputDir != "" || opts.ExportFormat != "" || opts.ExportPasswordFile != "" {
+			return fmt.Errorf("--output-dir、--format 与 --export-password-file 只能与 --export 同时使用")
+		}
+		return nil
+	}
+
+	if opts.OutputDir == "" {
+		return fmt.Errorf("--export 需要配合 --output-dir 指定输出目录")
+	}
+	switch opts.ExportFormat {
+	case "", exportFormatPEM, exportFormatPKCS12:
+	default:
+		return fmt.Errorf("不支持的导出格式 %q，可选 pem 或 pkcs12", opts.ExportFormat)
+	}
+	if opts.ExportPasswordFile != "" && opts.ExportFormat != exportFormatPKCS12 {
+		return fmt.Errorf("--export-password-file 只能与 --format pkcs12 同时使用")
+	}
+	return nil
+}
+
+// runExport 下载服务端的全部域名（或 -d 指定的域名）证书并导出到 --output-dir/<域名>/
+// 先写入输出目录旁的临时目录，全部成功后再重命名为输出目录；任一域名失败时不留下任何文件
+func runExport(ctx context.Context, wsClient client.CertClient, opts *CliOptions) error {
+	outDir := filepath.Clean(opts.OutputDir)
+	if err := checkExportDir(outDir); err != nil {
+		return err
+	}
+
+	password, err := readExportPassword(opts.ExportPasswordFile)
+	if err != nil {
+		return err
+	}
+
+	var domains []string
+	for _, d := range strings.Split(opts.DomainsStr, ",") {
+		if trimmed := strings.TrimSpace(d); trimmed != "" {
+			domains = append(domains, trimmed)
+		}
+	}
+	if len(domains) == 0 {
+		entries, err := wsClient.ListDomains(ctx)
+		if err != nil {
+			return fmt.Errorf("获取域名列表失败: %w", err)
+		}
+		for _, entry := range entries {
+			domains = append(domains, entry.Domain)
+		}
+	}
+	if len(domains) == 0 {
+		return fmt.Errorf("服务端没有可导出的域名")
+	}
+
+	parent := filepath.Dir(outDir)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return fmt.Errorf("创建输出目录失败: %w", err)
+	}
+	tmpDir, err := os.MkdirTemp(parent, "."+filepath.Base(outDir)+".tmp-")
+	if err != nil {
+		return fmt.Errorf("创建临时目录失败: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for i, domain := range domains {
+		log := slog.With("domain", domain, "progress", fmt.Sprintf("%d/%d", i+1, len(domains)))
+		log.Info("开始导出域名")
+		certs, err := wsClient.DownloadCert(ctx, domain, true)
+		if err != nil {
+			log.Error("下载证书失败", "error", err)
+			return fmt.Errorf("导出 %s 失败: 下载证书失败: %w", domain, err)
+		}
+		// 域名来自服务端，禁止路径分隔符与路径穿越
+		domainDir, err := websocket.SafeDomainDir(tmpDir, domain)
+		if err != nil {
+			return fmt.Errorf("导出 %s 失败: %w", domain, err)
+		}
+		if err := writeExportDomain(domainDir, domain, certs, opts.ExportFormat, password); err != nil {
+			log.Error("导出域名失败", "error", err)
+			return fmt.Errorf("导出 %s 失败: %w", domain, err)
+		}
+		log.Info("成功导出域名")
+	}
+
+	// 输出目录为空目录时先删除，使重命名可以原子替换
+	os.Remove(outDir)
+	if err := os.Rename(tmpDir, outDir); err != nil {
+		return fmt.Errorf("重命名临时目录失败: %w", err)
+	}
+	slog.Info("证书导出完成", "total", len(domains), "output_dir", outDir)
+	return nil
+}
+
+// checkExportDir 输出目录必须不存在或为空目录，避免覆盖已有文件
+func checkExportDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("检查输出目录失败: %w", err)
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("输出目录 %s 已存在且不为空", dir)
+	}
+	return nil
+}
+
+// readExportPassword 读取 PKCS#12 文件的密码（文件首行），未指定时返回空密码
+func readExportPassword(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	return config.ReadPasswordFile(path)
+}
+
+// writeExportDomain 将单个域名的证书与 metadata.json 写入 dir（私钥与 PKCS#12 文件权限 0600）
+func writeExportDomain(dir, domain string, certs *client.CertificateFiles, format, password string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建域名目录失败: %w", err)
+	}
+
+	var files []getFile
+	if format == exportFormatPKCS12 {
+		p12, err := cert.PEMToPKCS12(certs.Fullchain, certs.Key, password, false)
+		if err != nil {
+			return fmt.Errorf("生成 PKCS#12 文件失败: %w", err)
+		}
+		files = []getFile{{name: domain + ".p12", content: p12, perm: 0600}}
+	} else {
+		files = selectGetFiles(certs, getAll)
+	}
+	for _, f := range files {
+		if len(f.content) == 0 {
+			return fmt.Errorf("服务端未返回 %s", f.name)
+		}
+		if err := writeFileWithPerm(filepath.Join(dir, f.name), f.content, f.perm); err != nil {
+			return fmt.Errorf("写入 %s 失败: %w", f.name, err)
+		}
+	}
+
+	status := cert.StatusFromPEM(domain, certs.Cert, certs.Key, certs.Fullchain, certs.Timestamp)
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileWithPerm(filepath.Join(dir, exportMetadataFile), append(data, '\n'), 0644)
+}
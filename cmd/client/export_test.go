@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Catker/acmeDeliver/pkg/cert"
+	"github.com/Catker/acmeDeliver/pkg/client"
+)
+
+func TestValidateExportArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    CliOptions
+		wantErr bool
+	}{
+		{"未使用 --export", CliOptions{}, false},
+		{"导出 PEM", CliOptions{Export: true, OutputDir: "/tmp/certs"}, false},
+		{"导出 PKCS#12", CliOptions{Export: true, OutputDir: "/tmp/certs", ExportFormat: exportFormatPKCS12, ExportPasswordFile: "/etc/p12.pass"}, false},
+		{"缺少 --output-dir", CliOptions{Export: true}, true},
+		{"不支持的格式", CliOptions{Export: true, OutputDir: "/tmp/certs", ExportFormat: "der"}, true},
+		{"PEM 不能指定密码文件", CliOptions{Export: true, OutputDir: "/tmp/certs", ExportPasswordFile: "/etc/p12.pass"}, true},
+		{"--output-dir 缺少 --export", CliOptions{OutputDir: "/tmp/certs"}, true},
+		{"--format 缺少 --export", CliOptions{ExportFormat: exportFormatPKCS12}, true},
+		{"与 --deploy 冲突", CliOptions{Export: true, OutputDir: "/tmp/certs", Deploy: true}, true},
+		{"与 --remove 冲突", CliOptions{Export: true, OutputDir: "/tmp/certs", Remove: true, DomainsStr: "a.com"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateArgs(&tt.opts)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRunExport(t *testing.T) {
+	certPEM, keyPEM := generateKeyPair(t)
+	stub := &stubCertClient{certs: map[string]*client.CertificateFiles{
+		"a.com": {Cert: certPEM, Key: keyPEM, Fullchain: certPEM, Timestamp: 1700000000},
+		"b.com": {Cert: certPEM, Key: keyPEM, Fullchain: certPEM, Timestamp: 1700000100},
+	}}
+	ctx := context.Background()
+
+	// 导出服务端全部域名（PEM）
+	outDir := filepath.Join(t.TempDir(), "export")
+	require.NoError(t, runExport(ctx, stub, &CliOptions{Export: true, OutputDir: outDir}))
+	for _, domain := range []string{"a.com", "b.com"} {
+		for name, perm := range map[string]os.FileMode{"cert.pem": 0644, "key.pem": 0600, "fullchain.pem": 0644, "metadata.json": 0644} {
+			info, err := os.Stat(filepath.Join(outDir, domain, name))
+			require.NoError(t, err)
+			require.Equal(t, perm, info.Mode().Perm(), "%s/%s", domain, name)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(outDir, "b.com", exportMetadataFile))
+	require.NoError(t, err)
+	var status cert.DomainStatus
+	require.NoError(t, json.Unmarshal(data, &status))
+	require.Equal(t, "b.com", status.Domain)
+	require.Equal(t, int64(1700000100), status.LastUpdate)
+	require.True(t, status.Valid)
+	require.Equal(t, "example.com", status.Subject)
+
+	// 输出目录不为空时拒绝覆盖
+	require.Error(t, runExport(ctx, stub, &CliOptions{Export: true, OutputDir: outDir}))
+
+	// PKCS#12：空输出目录，-d 指定域名，密码取自文件首行
+	p12Dir := t.TempDir()
+	passFile := filepath.Join(t.TempDir(), "p12.pass")
+	require.NoError(t, os.WriteFile(passFile, []byte("s3cret\nignored\n"), 0600))
+	require.NoError(t, runExport(ctx, stub, &CliOptions{Export: true, OutputDir: p12Dir, DomainsStr: "a.com", ExportFormat: exportFormatPKCS12, ExportPasswordFile: passFile}))
+	entries, err := os.ReadDir(p12Dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	p12, err := os.ReadFile(filepath.Join(p12Dir, "a.com", "a.com.p12"))
+	require.NoError(t, err)
+	_, leaf, _, err := cert.DecodePKCS12(p12, "s3cret")
+	require.NoError(t, err)
+	require.Equal(t, "example.com", leaf.Subject.CommonName)
+	_, err = os.Stat(filepath.Join(p12Dir, "a.com", "key.pem"))
+	require.True(t, os.IsNotExist(err), "PKCS#12 格式不应导出 key.pem")
+}
+
+func TestRunExportFailureLeavesNoFiles(t *testing.T) {
+	certPEM, keyPEM := generateKeyPair(t)
+	stub := &stubCertClient{certs: map[string]*client.CertificateFiles{
+		"a.com": {Cert: certPEM, Key: keyPEM, Fullchain: certPEM, Timestamp: 1700000000},
+	}}
+
+	parent := t.TempDir()
+	outDir := filepath.Join(parent, "export")
+	err := runExport(context.Background(), stub, &CliOptions{Export: true, OutputDir: outDir, DomainsStr: "a.com,missing.com"})
+	require.Error(t, err)
+
+	// 任一域名失败时既不创建输出目录，也不留下临时目录
+	entries, err := os.ReadDir(parent)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// --status 域名排序方式（--sort）
+const (
+	sortExpiry  = "expiry"  // 按过期时间升序，无证书的域名排在最后
+	sortName    = "name"    // 按域名字母序
+	sortUpdated = "updated" // 按下发时间升序（最久未更新的在前），无时间戳的域名排在最前
+)
+
+// statusFilter --status 的过滤与排序条件，只影响客户端的展示
+type statusFilter struct {
+	DomainGlob     string // 域名 glob（如 *.example.com），空表示不过滤
+	ExpiringWithin int    // 只保留剩余天数不超过该值的域名（含已过期），0 表示不过滤
+	OnlyProblems   bool   // 只保留已过期、已吊销、出错或文件缺失的域名
+	Sort           string // 排序方式，空表示保持服务端顺序
+	ClientFilter   string // 客户端 ID 或 IP 子串，空表示不过滤
+}
+
+// active 是否设置了任何过滤条件（只排序不算过滤）
+func (f statusFilter) active() bool {
+	return f.DomainGlob != "" || f.ExpiringWithin > 0 || f.OnlyProblems || f.ClientFilter != ""
+}
+
+// validate 检查过滤条件是否合法
+func (f statusFilter) validate() error {
+	if f.DomainGlob != "" {
+		if _, err := path.Match(f.DomainGlob, ""); err != nil {
+			return fmt.Errorf("--domain-filter 不是有效的 glob: %q", f.DomainGlob)
+		}
+	}
+	if f.ExpiringWithin < 0 {
+		return fmt.Errorf("--expiring-within 不能为负数")
+	}
+	switch f.Sort {
+	case "", sortExpiry, sortName, sortUpdated:
+	default:
+		return fmt.Errorf("不支持的排序方式 %q，可选 expiry、name 或 updated", f.Sort)
+	}
+	return nil
+}
+
+// statusFilterFromOptions 从命令行参数生成过滤条件
+func statusFilterFromOptions(opts *CliOptions) statusFilter {
+	return statusFilter{
+		DomainGlob:     opts.DomainFilter,
+		ExpiringWithin: opts.ExpiringWithin,
+		OnlyProblems:   opts.OnlyProblems,
+		Sort:           opts.StatusSort,
+		ClientFilter:   opts.ClientFilter,
+	}
+}
+
+// applyFilter 按过滤条件筛选并排序报告中的域名与客户端
+// 设置了过滤条件时记录过滤前的数量，便于文本输出提示、JSON 输出区分
+func (r *statusReport) applyFilter(f statusFilter) {
+	if f.active() {
+		r.TotalClients, r.TotalDomains = len(r.Clients), len(r.Domains)
+	}
+
+	if f.ClientFilter != "" {
+		clients := r.Clients[:0]
+		for _, c := range r.Clients {
+			if strings.Contains(c.ID, f.ClientFilter) || strings.Contains(c.RemoteIP, f.ClientFilter) {
+				clients = append(clients, c)
+			}
+		}
+		r.Clients = clients
+	}
+
+	domains := r.Domains[:0]
+	for _, d := range r.Domains {
+		if f.matchDomain(d) {
+			domains = append(domains, d)
+		}
+	}
+	r.Domains = domains
+
+	switch f.Sort {
+	case sortName:
+		sort.SliceStable(r.Domains, func(i, j int) bool { return r.Domains[i].Domain < r.Domains[j].Domain })
+	case sortExpiry:
+		sort.SliceStable(r.Domains, func(i, j int) bool {
+			a, b := r.Domains[i].NotAfter, r.Domains[j].NotAfter
+			if a == 0 || b == 0 {
+				return a != 0 && b == 0
+			}
+			return a < b
+		})
+	case sortUpdated:
+		sort.SliceStable(r.Domains, func(i, j int) bool { return r.Domains[i].LastUpdate < r.Domains[j].LastUpdate })
+	}
+}
+
+// matchDomain 判断域名是否满足全部过滤条件
+func (f statusFilter) matchDomain(d domainStatusReport) bool {
+	if f.DomainGlob != "" {
+		if ok, _ := path.Match(f.DomainGlob, d.Domain); !ok {
+			return false
+		}
+	}
+	if f.ExpiringWithin > 0 && (d.NotAfter == 0 || d.DaysRemaining > f.ExpiringWithin) {
+		return false
+	}
+	if f.OnlyProblems {
+		switch d.State {
+		case certStateExpired, certStateRevoked, certStateError, certStateInvalid:
+		default:
+			return false
+		}
+	}
+	return true
+}
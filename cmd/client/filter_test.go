@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// reportDomains 返回报告中的域名列表
+func reportDomains(r *statusReport) []string {
+	names := make([]string, 0, len(r.Domains))
+	for _, d := range r.Domains {
+		names = append(names, d.Domain)
+	}
+	return names
+}
+
+func TestStatusReportApplyFilter(t *testing.T) {
+	tests := []struct {
+		name        string
+		filter      statusFilter
+		wantDomains []string
+		wantClients int
+	}{
+		{"无过滤保持服务端顺序", statusFilter{},
+			[]string{"example.com", "soon.example.com", "old.example.com", "revoked.example.com", "nocrl.example.com", "broken.example.com", "empty.example.com"}, 2},
+		{"域名 glob", statusFilter{DomainGlob: "*o*.example.com"},
+			[]string{"soon.example.com", "old.example.com", "revoked.example.com", "nocrl.example.com", "broken.example.com"}, 2},
+		{"glob 不匹配根域名", statusFilter{DomainGlob: "*.example.com"},
+			[]string{"soon.example.com", "old.example.com", "revoked.example.com", "nocrl.example.com", "broken.example.com", "empty.example.com"}, 2},
+		{"即将过期（含已过期）", statusFilter{ExpiringWithin: 30},
+			[]string{"soon.example.com", "old.example.com"}, 2},
+		{"只显示问题", statusFilter{OnlyProblems: true},
+			[]string{"old.example.com", "revoked.example.com", "broken.example.com", "empty.example.com"}, 2},
+		{"条件组合", statusFilter{DomainGlob: "*.example.com", OnlyProblems: true, ExpiringWithin: 90},
+			[]string{"old.example.com", "revoked.example.com"}, 2},
+		{"按名称排序", statusFilter{Sort: sortName},
+			[]string{"broken.example.com", "empty.example.com", "example.com", "nocrl.example.com", "old.example.com", "revoked.example.com", "soon.example.com"}, 2},
+		{"按过期时间排序，无证书的在最后", statusFilter{Sort: sortExpiry},
+			[]string{"old.example.com", "soon.example.com", "example.com", "revoked.example.com", "nocrl.example.com", "broken.example.com", "empty.example.com"}, 2},
+		{"按下发时间排序", statusFilter{Sort: sortUpdated, OnlyProblems: true},
+			[]string{"old.example.com", "broken.example.com", "empty.example.com", "revoked.example.com"}, 2},
+		{"客户端按 ID 过滤", statusFilter{ClientFilter: "web-02"}, nil, 1},
+		{"客户端按 IP 子串过滤", statusFilter{ClientFilter: "10.0.0."}, nil, 2},
+		{"客户端无匹配", statusFilter{ClientFilter: "db"}, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := testStatusReport()
+			report.applyFilter(tt.filter)
+			if tt.wantDomains != nil {
+				require.Equal(t, tt.wantDomains, reportDomains(report))
+			}
+			require.Len(t, report.Clients, tt.wantClients)
+			if tt.filter.active() {
+				require.Equal(t, 7, report.TotalDomains)
+				require.Equal(t, 2, report.TotalClients)
+			} else {
+				require.Zero(t, report.TotalDomains, "只排序时不记录过滤前数量")
+			}
+		})
+	}
+}
+
+func TestRenderStatusFiltered(t *testing.T) {
+	useUTC(t)
+
+	tests := []struct {
+		name   string
+		filter statusFilter
+		want   []string
+	}{
+		{"部分匹配", statusFilter{ExpiringWithin: 30, ClientFilter: "web-01"},
+			[]string{"符合条件 1 个（共 2 个客户端在线）", "符合条件 2 个（共 7 个域名）", "soon.example.com"}},
+		{"无匹配", statusFilter{DomainGlob: "*.example.net", ClientFilter: "db"},
+			[]string{"没有符合条件的客户端（共 2 个客户端在线）", "没有符合条件的域名（共 7 个域名）"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := testStatusReport()
+			report.applyFilter(tt.filter)
+			var buf bytes.Buffer
+			require.NoError(t, renderStatus(&buf, report, outputText))
+			for _, s := range tt.want {
+				require.Contains(t, buf.String(), s)
+			}
+		})
+	}
+
+	// JSON 输出同样只包含过滤后的结果
+	report := testStatusReport()
+	report.applyFilter(statusFilter{OnlyProblems: true, Sort: sortName})
+	var buf bytes.Buffer
+	require.NoError(t, renderStatus(&buf, report, outputJSON))
+	var got struct {
+		Domains []struct {
+			Domain string `json:"domain"`
+		} `json:"domains"`
+		TotalDomains int `json:"total_domains"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	require.Len(t, got.Domains, 4)
+	require.Equal(t, "broken.example.com", got.Domains[0].Domain)
+	require.Equal(t, 7, got.TotalDomains)
+}
+
+func TestValidateArgsStatusFilter(t *testing.T) {
+	require.NoError(t, validateArgs(&CliOptions{Status: true, DomainFilter: "*.example.com", ExpiringWithin: 30, StatusSort: sortExpiry}))
+	require.NoError(t, validateArgs(&CliOptions{Status: true, OnlyProblems: true, ClientFilter: "10.0.0."}))
+	require.Error(t, validateArgs(&CliOptions{Deploy: true, OnlyProblems: true}), "过滤参数只能与 --status 同时使用")
+	require.Error(t, validateArgs(&CliOptions{StatusSort: sortName}))
+	require.Error(t, validateArgs(&CliOptions{Status: true, DomainFilter: "[example"}))
+	require.Error(t, validateArgs(&CliOptions{Status: true, ExpiringWithin: -1}))
+	require.Error(t, validateArgs(&CliOptions{Status: true, StatusSort: "size"}))
+}
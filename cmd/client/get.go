@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Catker/acmeDeliver/pkg/client"
+	"github.com/Catker/acmeDeliver/pkg/workspace"
+)
+
+// --get 可选的文件
+const (
+	getCert      = "cert"
+	getKey       = "key"
+	getFullchain = "fullchain"
+	getAll       = "all"
+)
+
+// outStdout --out 取该值时将文件写入标准输出
+const outStdout = "-"
+
+// getFile --get 选中的单个文件
+type getFile struct {
+	name    string
+	content []byte
+	perm    os.FileMode
+}
+
+// validateGetArgs 校验 --get/--out 参数：必须同时指定，且只能针对单个 -d 域名
+func validateGetArgs(opts *CliOptions) error {
+	if opts.Get == "" {
+		if opts.Out != "" {
+			return fmt.Errorf("--out 只能与 --get 同时使用")
+		}
+		if opts.InsecureStdout {
+			return fmt.Errorf("--insecure-stdout 只能与 --get key --out - 同时使用")
+		}
+		return nil
+	}
+
+	switch opts.Get {
+	case getCert, getKey, getFullchain, getAll:
+	default:
+		return fmt.Errorf("不支持的 --get 值 %q，可选 cert、key、fullchain 或 all", opts.Get)
+	}
+	if opts.Out == "" {
+		return fmt.Errorf("--get 需要配合 --out 指定输出目录，或使用 --out - 输出到标准输出")
+	}
+	if opts.Out == outStdout && opts.Get == getAll {
+		return fmt.Errorf("--get all 只能输出到目录，标准输出一次只能输出一个文件")
+	}
+	if domain := strings.TrimSpace(opts.DomainsStr); domain == "" || strings.Contains(domain, ",") {
+		return fmt.Errorf("--get 需要通过 -d 指定单个域名")
+	}
+	return nil
+}
+
+// runGet 下载单个域名的证书并按 --get/--out 输出，不使用站点配置与部署器，也不写入工作目录
+func runGet(ctx context.Context, stdout io.Writer, wsClient client.CertClient, opts *CliOptions) error {
+	domain := strings.TrimSpace(opts.DomainsStr)
+	certs, err := wsClient.DownloadCert(ctx, domain, true)
+	if err != nil {
+		return fmt.Errorf("下载证书失败: %w", err)
+	}
+	return writeGetFiles(stdout, isTerminal(stdout), certs, opts)
+}
+
+// writeGetFiles 将选中的证书文件写入 stdout 或 --out 目录
+// 写入标准输出时，stdout 为终端且选中私钥的情况下除非指定 --insecure-stdout，否则拒绝输出
+func writeGetFiles(stdout io.Writer, stdoutIsTTY bool, certs *workspace.CertificateFiles, opts *CliOptions) error {
+	files := selectGetFiles(certs, opts.Get)
+	for _, f := range files {
+		if len(f.content) == 0 {
+			return fmt.Errorf("服务端未返回 %s", f.name)
+		}
+	}
+
+	if opts.Out == outStdout {
+		f := files[0]
+		if f.name == "key.pem" && stdoutIsTTY && !opts.InsecureStdout {
+			return fmt.Errorf("拒绝将私钥输出到终端，请重定向到文件或管道，或使用 --insecure-stdout")
+		}
+		_, err := stdout.Write(f.content)
+		return err
+	}
+
+	if err := os.MkdirAll(opts.Out, 0755); err != nil {
+		return fmt.Errorf("创建输出目录失败: %w", err)
+	}
+	for _, f := range files {
+		path := filepath.Join(opts.Out, f.name)
+		if err := writeFileWithPerm(path, f.content, f.perm); err != nil {
+			return fmt.Errorf("写入 %s 失败: %w", path, err)
+		}
+		slog.Info("证书文件已写入", "file", path, "perm", f.perm)
+	}
+	return nil
+}
+
+// selectGetFiles 按 --get 的取值返回要输出的文件，私钥权限为 0600
+func selectGetFiles(certs *workspace.CertificateFiles, get string) []getFile {
+	cert := getFile{name: "cert.pem", content: certs.Cert, perm: 0644}
+	key := getFile{name: "key.pem", content: certs.Key, perm: 0600}
+	fullchain := getFile{name: "fullchain.pem", content: certs.Fullchain, perm: 0644}
+
+	switch get {
+	case getCert:
+		return []getFile{cert}
+	case getKey:
+		return []getFile{key}
+	case getFullchain:
+		return []getFile{fullchain}
+	default:
+		return []getFile{cert, key, fullchain}
+	}
+}
+
+// writeFileWithPerm 先写入临时文件再原子重命名，并显式设置权限（不受 umask 与已有文件权限影响）
+func writeFileWithPerm(path string, content []byte, perm os.FileMode) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, perm); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, perm); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// isTerminal 判断输出是否为终端（字符设备），非文件输出视为非终端
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Catker/acmeDeliver/pkg/client"
+	"github.com/Catker/acmeDeliver/pkg/testutil/wstest"
+	"github.com/Catker/acmeDeliver/pkg/workspace"
+)
+
+func testGetCerts() *workspace.CertificateFiles {
+	return &workspace.CertificateFiles{Cert: []byte("CERT\n"), Key: []byte("KEY\n"), Fullchain: []byte("FULLCHAIN\n")}
+}
+
+func TestValidateGetArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    CliOptions
+		wantErr bool
+	}{
+		{"未使用 --get", CliOptions{}, false},
+		{"输出到标准输出", CliOptions{Get: getFullchain, Out: outStdout, DomainsStr: "example.com"}, false},
+		{"输出到目录", CliOptions{Get: getAll, Out: "/tmp/certs", DomainsStr: "example.com"}, false},
+		{"不支持的文件", CliOptions{Get: "chain", Out: outStdout, DomainsStr: "example.com"}, true},
+		{"缺少 --out", CliOptions{Get: getCert, DomainsStr: "example.com"}, true},
+		{"缺少 --get", CliOptions{Out: outStdout}, true},
+		{"--insecure-stdout 缺少 --get", CliOptions{InsecureStdout: true}, true},
+		{"all 不能输出到标准输出", CliOptions{Get: getAll, Out: outStdout, DomainsStr: "example.com"}, true},
+		{"缺少域名", CliOptions{Get: getCert, Out: outStdout}, true},
+		{"多个域名", CliOptions{Get: getCert, Out: outStdout, DomainsStr: "a.com,b.com"}, true},
+		{"与 --deploy 冲突", CliOptions{Get: getCert, Out: outStdout, DomainsStr: "example.com", Deploy: true}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateArgs(&tt.opts)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestWriteGetFilesStdout(t *testing.T) {
+	tests := []struct {
+		name     string
+		get      string
+		tty      bool
+		insecure bool
+		want     string
+		wantErr  bool
+	}{
+		{"证书链", getFullchain, true, false, "FULLCHAIN\n", false},
+		{"证书", getCert, false, false, "CERT\n", false},
+		{"私钥输出到管道", getKey, false, false, "KEY\n", false},
+		{"私钥拒绝输出到终端", getKey, true, false, "", true},
+		{"私钥 --insecure-stdout 输出到终端", getKey, true, true, "KEY\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			opts := &CliOptions{Get: tt.get, Out: outStdout, InsecureStdout: tt.insecure}
+			err := writeGetFiles(&out, tt.tty, testGetCerts(), opts)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.want, out.String())
+		})
+	}
+}
+
+func TestWriteGetFilesDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "certs")
+	// 已存在的私钥文件权限过宽时应被收紧
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "key.pem"), []byte("OLD"), 0644))
+
+	var out bytes.Buffer
+	require.NoError(t, writeGetFiles(&out, false, testGetCerts(), &CliOptions{Get: getAll, Out: dir}))
+	require.Empty(t, out.String(), "输出到目录时不应写入标准输出")
+
+	for name, want := range map[string]struct {
+		content string
+		perm    os.FileMode
+	}{
+		"cert.pem":      {"CERT\n", 0644},
+		"key.pem":       {"KEY\n", 0600},
+		"fullchain.pem": {"FULLCHAIN\n", 0644},
+	} {
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, want.content, string(data), name)
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Equal(t, want.perm, info.Mode().Perm(), name)
+	}
+
+	// 只获取单个文件
+	single := t.TempDir()
+	require.NoError(t, writeGetFiles(&out, false, testGetCerts(), &CliOptions{Get: getKey, Out: single}))
+	entries, err := os.ReadDir(single)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "key.pem", entries[0].Name())
+}
+
+func TestWriteGetFilesMissing(t *testing.T) {
+	certs := &workspace.CertificateFiles{Cert: []byte("CERT\n")}
+	err := writeGetFiles(&bytes.Buffer{}, false, certs, &CliOptions{Get: getAll, Out: t.TempDir()})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "key.pem")
+}
+
+func TestRunGet(t *testing.T) {
+	const domain = "example.com"
+	server := wstest.NewMockServer(t)
+	certPEM, keyPEM := server.AddDomain(t, domain)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	wsClient := client.NewWSClient(server.URL, wstest.DefaultPassword, nil)
+	require.NoError(t, wsClient.Connect(ctx))
+	defer wsClient.Close()
+
+	out := t.TempDir()
+	require.NoError(t, runGet(ctx, &bytes.Buffer{}, wsClient, &CliOptions{Get: getAll, Out: out, DomainsStr: domain}))
+
+	data, err := os.ReadFile(filepath.Join(out, "cert.pem"))
+	require.NoError(t, err)
+	require.Equal(t, certPEM, data)
+	data, err = os.ReadFile(filepath.Join(out, "key.pem"))
+	require.NoError(t, err)
+	require.Equal(t, keyPEM, data)
+}
@@ -2,18 +2,29 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"log/slog"
 
+	"github.com/google/uuid"
+
 	"github.com/Catker/acmeDeliver/pkg/client"
 	"github.com/Catker/acmeDeliver/pkg/command"
 	"github.com/Catker/acmeDeliver/pkg/config"
 	"github.com/Catker/acmeDeliver/pkg/deployer"
+	"github.com/Catker/acmeDeliver/pkg/install"
+	"github.com/Catker/acmeDeliver/pkg/logging"
+	"github.com/Catker/acmeDeliver/pkg/websocket"
 	"github.com/Catker/acmeDeliver/pkg/workspace"
 )
 
@@ -24,12 +35,50 @@ type CliOptions struct {
 	// 基础参数
 	Server     string
 	Password   string
+	ClientID   string // 客户端标识（覆盖配置文件）
 	DomainsStr string // -d "dom1,dom2" 域名列表
 	Debug      bool
+	LaxConfig  bool   // 宽松解析配置文件，忽略未知字段
+	Output     string // 输出格式：text（默认）/ json
 
 	// 功能参数
-	Deploy bool // 部署模式：检查更新并部署证书
-	Status bool // 查询服务器运行状态（在线客户端 + 证书状态）
+	Deploy     bool // 部署模式：检查更新并部署证书
+	Status     bool // 查询服务器运行状态（在线客户端 + 证书状态）
+	List       bool // 列出服务端可用的域名（域名、时间戳与文件）
+	ReloadOnly bool // 仅执行站点配置中的重载命令，不下载证书
+	Check      bool // 仅检查各域名是否有可用更新，不写入任何文件
+	CheckCRL   bool // 配合 --status，由服务端通过 CRL 检查证书是否已被吊销
+
+	// --status 过滤与排序（仅影响展示，不改变服务端查询）
+	DomainFilter   string // 按 glob 过滤域名
+	ExpiringWithin int    // 只显示剩余天数不超过该值的域名
+	OnlyProblems   bool   // 只显示已过期、已吊销、出错或文件缺失的域名
+	StatusSort     string // 域名排序：expiry / name / updated
+	ClientFilter   string // 按客户端 ID 或 IP 子串过滤
+
+	VerifyWorkspace bool // 校验工作目录中已保存证书的完整性
+	ValidateConfig  bool // 校验配置并输出全部问题，不连接服务器
+	PrintConfig     bool // 输出合并后的有效配置（隐藏密码）
+
+	// 域名下线
+	Remove        bool // 删除 -d 指定域名的工作目录并执行重载命令
+	PurgeDeployed bool // 配合 --remove，同时删除站点配置中的部署文件
+
+	// 直接获取证书文件，不经过站点配置与部署器
+	Get            string // 要获取的文件：cert / key / fullchain / all
+	Out            string // 输出目录，"-" 表示标准输出
+	InsecureStdout bool   // 允许将私钥输出到终端
+
+	// 导出服务端全部证书
+	Export             bool   // 下载全部域名的证书并保存到 --output-dir
+	OutputDir          string // 导出目录，每个域名一个子目录
+	ExportFormat       string // 导出格式：pem（默认）/ pkcs12
+	ExportPasswordFile string // PKCS#12 文件的密码文件（首行），默认空密码
+
+	// 监控插件模式（Nagios/Zabbix）
+	Monitor  bool // 离线检查已部署证书的剩余有效期
+	WarnDays int  // 剩余天数不超过该值时为 WARNING
+	CritDays int  // 剩余天数不超过该值时为 CRITICAL
 
 	// 网络参数
 	IPMode4 bool
@@ -38,10 +87,38 @@ type CliOptions struct {
 	// 功能增强
 	ReloadCmd string // 自定义重载命令
 	DryRun    bool   // Dry-Run 模式
-	Force     bool   // 强制更新模式
+	Force     bool   // 强制更新模式；配合 --remove 时允许下线没有站点配置的域名
+
+	Concurrency     int    // --deploy 同时处理的域名数
+	SinceDeploy     string // 配合 --deploy，本地证书时间戳在该时长内的域名直接跳过，不请求服务端（如 7d、12h）
+	ConnectTimeout  int    // 连接与认证超时（秒），覆盖 timeouts.connect
+	RequestTimeout  int    // 请求超时（秒），覆盖 timeouts.request
+	ReloadTimeout   int    // 重载命令超时（秒），覆盖 timeouts.reload
+	ShutdownTimeout int    // daemon 退出时等待重载命令的时间（秒），覆盖 timeouts.shutdown
+	Retries         int    // 连接、下载与状态查询遇到网络错误时的最大尝试次数
 
 	// Daemon 模式
-	Daemon bool // 守护进程模式
+	Daemon      bool   // 守护进程模式
+	ForceDomain string // 请求服务端立即向本机 daemon 推送指定域名
+	Resync      bool   // 一次性强制同步 daemon 订阅的全部证书（即使本地已是最新）
+
+	// 密钥轮换
+	RotateKey    bool   // 请求服务端轮换认证密钥
+	NewKey       string // 指定新密钥（留空由服务端生成）
+	RotateWindow int    // 等待 daemon 重新认证的秒数（0 使用服务端配置）
+
+	// 安装 daemon 为系统服务
+	InstallService bool   // 生成并安装 systemd unit 或 OpenRC 脚本
+	ServiceUser    string // 服务的运行用户
+	ServiceGroup   string // 服务的运行用户组
+	ServiceEnable  bool   // 安装后设置开机启动
+	InitSystem     string // systemd / openrc，空表示自动检测
+
+	// Shell 补全
+	Completion      string // 输出 bash / zsh / fish 补全脚本
+	CompleteDomains bool   // 输出配置文件中的域名（供补全脚本调用）
+
+	GenClientConfig bool // 按 -s 与 -d 生成客户端配置文件模板
 }
 
 // parseFlags 解析命令行参数并返回 CliOptions
@@ -51,18 +128,55 @@ func parseFlags() *CliOptions {
 	// 基础参数
 	flag.StringVar(&configFile, "c", "", "配置文件路径")
 	flag.StringVar(&opts.Server, "s", "", "服务器地址")
+	flag.StringVar(&opts.Server, "server", "", "同 -s")
 	flag.StringVar(&opts.Password, "k", "", "认证密码")
+	flag.StringVar(&opts.ClientID, "client-id", "", "客户端标识（默认使用主机名）")
 	flag.StringVar(&opts.DomainsStr, "d", "", "要操作的域名，多个域名以逗号分隔 (例如 \"d1.com,d2.com\")")
+	flag.StringVar(&opts.DomainsStr, "domains", "", "同 -d")
 	flag.BoolVar(&opts.Debug, "debug", false, "调试模式")
+	flag.BoolVar(&opts.LaxConfig, "lax-config", false, "宽松模式：忽略配置文件中的未知字段")
+	flag.StringVar(&opts.Output, "output", outputText, "输出格式：text 或 json（json 模式下 --status/--list/--deploy/--check 结果输出到 stdout，日志输出到 stderr）")
 
 	// 功能参数
 	flag.BoolVar(&opts.Deploy, "deploy", false, "检查更新并部署证书（根据配置文件中的路径部署）")
 	flag.BoolVar(&opts.Status, "status", false, "查询服务器运行状态（在线客户端 + 证书状态）")
+	flag.BoolVar(&opts.List, "list", false, "列出服务端可用的域名及其更新时间与文件（配合 --output json 便于脚本处理）")
+	flag.BoolVar(&opts.CheckCRL, "check-crl", false, "配合 --status，由服务端通过证书中的 CRL 分发点检查证书是否已被吊销")
+	flag.StringVar(&opts.DomainFilter, "domain-filter", "", "配合 --status，只显示匹配 glob 的域名（如 \"*.example.com\"）")
+	flag.IntVar(&opts.ExpiringWithin, "expiring-within", 0, "配合 --status，只显示剩余有效期不超过指定天数的域名（含已过期）")
+	flag.BoolVar(&opts.OnlyProblems, "only-problems", false, "配合 --status，只显示已过期、已吊销、出错或文件缺失的域名")
+	flag.StringVar(&opts.StatusSort, "sort", "", "配合 --status，域名排序方式：expiry（过期时间）、name（域名）或 updated（下发时间，最久未更新的在前）")
+	flag.StringVar(&opts.ClientFilter, "client-filter", "", "配合 --status，只显示 ID 或 IP 包含该字符串的在线客户端")
+	flag.BoolVar(&opts.ReloadOnly, "reload-only", false, "仅执行站点配置中的重载命令（去重），不连接服务器、不下载证书")
+	flag.BoolVar(&opts.VerifyWorkspace, "verify-workspace", false, "校验工作目录中所有域名证书的完整性（PEM 格式、证书与私钥匹配、校验和），不连接服务器")
+	flag.BoolVar(&opts.PrintConfig, "print-config", false, "以 YAML 输出合并配置文件、环境变量与命令行后的有效配置（隐藏密码）并退出，不校验配置、不连接服务器")
+	flag.BoolVar(&opts.ValidateConfig, "validate-config", false, "校验配置（服务端地址、站点域名与路径、重载命令、工作目录等）并列出全部问题，不连接服务器（无效时退出码 6）")
+	flag.BoolVar(&opts.Monitor, "monitor", false, "离线检查站点已部署证书的剩余有效期，按 Nagios 约定输出状态行与 perfdata（退出码 0=OK，1=WARNING，2=CRITICAL，3=UNKNOWN）")
+	flag.IntVar(&opts.WarnDays, "warn-days", defaultWarnDays, "配合 --monitor，剩余天数不超过该值时为 WARNING")
+	flag.IntVar(&opts.CritDays, "crit-days", defaultCritDays, "配合 --monitor，剩余天数不超过该值时为 CRITICAL")
+	flag.BoolVar(&opts.Check, "check", false, "仅检查各域名是否有可用更新（退出码 0=最新，1=有更新，2=出错），不写入任何文件")
+	flag.StringVar(&opts.Get, "get", "", "下载 -d 指定的单个域名的证书文件：cert、key、fullchain 或 all（配合 --out，不使用站点配置）")
+	flag.StringVar(&opts.Out, "out", "", "配合 --get，输出目录（私钥权限 0600），或 - 输出到标准输出")
+	flag.BoolVar(&opts.InsecureStdout, "insecure-stdout", false, "配合 --get key --out -，允许将私钥输出到终端")
+	flag.BoolVar(&opts.Export, "export", false, "下载服务端全部域名（或 -d 指定的域名）的证书，保存到 --output-dir/<域名>/（含 metadata.json，先写入临时目录再整体重命名）")
+	flag.StringVar(&opts.OutputDir, "output-dir", "", "配合 --export，输出目录（必须不存在或为空）")
+	flag.StringVar(&opts.ExportFormat, "format", "", "配合 --export，导出格式：pem（cert.pem、key.pem、fullchain.pem，默认）或 pkcs12（<域名>.p12）")
+	flag.StringVar(&opts.ExportPasswordFile, "export-password-file", "", "配合 --export --format pkcs12，从文件首行读取 PKCS#12 密码（默认空密码）")
+	flag.BoolVar(&opts.Remove, "remove", false, "下线 -d 指定的域名：删除工作目录中的域名目录并执行站点的重载命令（不连接服务器，可配合 --dry-run 预览）")
+	flag.BoolVar(&opts.PurgeDeployed, "purge-deployed", false, "配合 --remove，同时删除站点配置中的 cert_path、key_path、fullchain_path、bundle_path、combined_path 与 pkcs12_path 文件")
 
 	// 功能增强参数
 	flag.StringVar(&opts.ReloadCmd, "reload-cmd", "", "覆盖默认的重载命令 (例如 \"systemctl reload apache2\")")
 	flag.BoolVar(&opts.DryRun, "dry-run", false, "演练模式，只显示将执行的操作，不实际执行")
-	flag.BoolVar(&opts.Force, "f", false, "强制更新证书，即使证书尚未过期")
+	flag.BoolVar(&opts.Force, "f", false, "强制下载并部署证书，即使本地已是最新；部署文件内容未变化时同样执行重载")
+	flag.BoolVar(&opts.Force, "force", false, "同 -f；配合 --remove 时允许下线没有匹配站点配置的域名（只删除工作目录），通配符站点时 --purge-deployed 同时删除不含 {domain} 的共享部署文件")
+	flag.IntVar(&opts.ConnectTimeout, "connect-timeout", 0, "连接与认证超时秒数，覆盖配置文件中的 timeouts.connect（0 使用默认值 10 秒）")
+	flag.IntVar(&opts.RequestTimeout, "request-timeout", 0, "请求超时秒数，覆盖配置文件中的 timeouts.request（0 使用默认值：下载 30 秒，查询 10 秒）")
+	flag.IntVar(&opts.ReloadTimeout, "reload-timeout", 0, "重载命令超时秒数，覆盖配置文件中的 timeouts.reload（0 使用默认值 15 秒）")
+	flag.IntVar(&opts.ShutdownTimeout, "shutdown-timeout", 0, "daemon 退出时等待执行中重载命令的秒数，覆盖配置文件中的 timeouts.shutdown（0 使用默认值 30 秒）")
+	flag.IntVar(&opts.Concurrency, "concurrency", defaultConcurrency, "配合 --deploy，同时处理的域名数（0 使用默认值）")
+	flag.StringVar(&opts.SinceDeploy, "since-deploy", "", "配合 --deploy，本地证书时间戳在指定时长内（如 7d、12h）的域名直接跳过，不请求服务端（-f 时忽略）")
+	flag.IntVar(&opts.Retries, "retries", defaultRetries, "连接服务器、下载证书与查询状态遇到网络错误或超时时的最大尝试次数（含首次，1 表示不重试），认证失败与域名不存在不重试")
 
 	// 网络参数
 	flag.BoolVar(&opts.IPMode4, "4", false, "仅使用IPv4")
@@ -70,6 +184,27 @@ func parseFlags() *CliOptions {
 
 	// Daemon 模式
 	flag.BoolVar(&opts.Daemon, "daemon", false, "以守护进程模式运行，监听证书推送")
+	flag.StringVar(&opts.ForceDomain, "force-domain", "", "请求服务端立即向本机 daemon 重新推送指定域名的证书")
+	flag.BoolVar(&opts.Resync, "resync", false, "按 daemon 配置一次性强制同步订阅的全部证书：忽略本地时间戳，重写工作目录与部署文件并执行重载命令，完成后退出")
+
+	// 密钥轮换
+	flag.BoolVar(&opts.RotateKey, "rotate-key", false, "请求服务端轮换认证密钥，在线 daemon 自动切换到新密钥")
+	flag.StringVar(&opts.NewKey, "new-key", "", "配合 --rotate-key 指定新密钥（默认由服务端生成）")
+	flag.IntVar(&opts.RotateWindow, "rotate-window", 0, "配合 --rotate-key 指定等待 daemon 重新认证的秒数（默认使用服务端 key_rotation_window）")
+
+	// 安装系统服务
+	flag.BoolVar(&opts.InstallService, "install-service", false, "将 daemon 安装为系统服务：生成 systemd unit（非 systemd 系统生成 OpenRC 脚本），ExecStart 使用 -c 指定的配置文件（配合 --dry-run 只输出不写入）")
+	flag.StringVar(&opts.ServiceUser, "service-user", "", "配合 --install-service，服务的运行用户（默认 root；执行重载命令通常需要 root）")
+	flag.StringVar(&opts.ServiceGroup, "service-group", "", "配合 --install-service，服务的运行用户组")
+	flag.BoolVar(&opts.ServiceEnable, "enable", false, "配合 --install-service，安装后设置开机启动")
+	flag.StringVar(&opts.InitSystem, "init-system", "", "配合 --install-service，指定 systemd 或 openrc（默认自动检测）")
+
+	// Shell 补全
+	flag.StringVar(&opts.Completion, "completion", "", "输出 shell 补全脚本：bash、zsh 或 fish（如 source <(acmedeliver-client --completion bash)）")
+	flag.BoolVar(&opts.CompleteDomains, "complete-domains", false, "输出配置文件中的域名，供补全脚本调用")
+
+	// 生成客户端配置
+	flag.BoolVar(&opts.GenClientConfig, "gen-client-config", false, "按 --server 与 --domains 输出客户端配置文件模板（按本机 Web 服务器推测部署路径，reloadcmd 以注释给出）")
 
 	flag.Usage = usage
 	flag.Parse()
@@ -79,199 +214,455 @@ func parseFlags() *CliOptions {
 
 var configFile string
 
+// defaultConcurrency --deploy 默认同时处理的域名数
+const defaultConcurrency = 4
+
+// defaultRetries 网络错误时默认的最大尝试次数
+const defaultRetries = 3
+
+// exitCode 命令行退出码（--check / --monitor 模式使用各自约定的退出码）
+type exitCode int
+
+const (
+	exitOK             exitCode = 0 // 成功
+	exitError          exitCode = 1 // 其他错误
+	exitUsage          exitCode = 2 // 参数错误
+	exitConnection     exitCode = 3 // 连接或认证失败
+	exitPartialFailure exitCode = 4 // 部分域名部署失败（至少一个成功、一个失败）
+	exitAllFailed      exitCode = 5 // 所有域名部署失败
+	exitConfig         exitCode = 6 // 配置无效
+)
+
+// --check 模式的退出码
+const (
+	checkExitUpToDate = 0 // 所有域名均为最新
+	checkExitUpdates  = 1 // 存在可用更新
+	checkExitError    = 2 // 检查出错
+)
+
+// logger 当前日志输出，daemon 模式下用于热重载日志级别与重新打开日志文件
+var logger *logging.Logger
+
 func main() {
 	// 1. 解析命令行参数
 	opts := parseFlags()
 
-	// 2. 设置日志
-	setupLogger(opts.Debug)
+	// 补全脚本与补全候选直接写入标准输出，不输出启动日志
+	if opts.Completion != "" {
+		if err := writeCompletion(os.Stdout, opts.Completion, flag.CommandLine); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(int(exitUsage))
+		}
+		return
+	}
+	if opts.CompleteDomains {
+		runCompleteDomains(os.Stdout)
+		return
+	}
+	if opts.GenClientConfig {
+		runGenClientConfig(os.Stdout, opts)
+		return
+	}
+
+	// 2. 设置日志（加载配置前先按命令行输出到标准输出）
+	setupLogger(structuredOutputLogging(config.LoggingConfig{}, opts), opts.Debug)
 	slog.Info("acmeDeliver 客户端启动", "version", VERSION)
 
-	// 3. 加载配置
+	// 3. 加载配置，并按配置中的 logging 重新初始化日志
 	cfg, err := loadConfiguration(opts)
 	if err != nil {
 		slog.Error("加载客户端配置失败", "error", err)
-		os.Exit(1)
+		exitFailure(opts, exitConfig)
+	}
+	if logger, err = setupLogger(structuredOutputLogging(cfg.Logging, opts), cfg.Debug); err != nil {
+		slog.Error("初始化日志失败", "error", err)
+		exitFailure(opts, exitConfig)
+	}
+	defer logger.Close()
+
+	// 输出合并后的有效配置，排查配置优先级
+	if opts.PrintConfig {
+		if err := runPrintConfig(os.Stdout, cfg); err != nil {
+			slog.Error("输出配置失败", "error", err)
+			os.Exit(int(exitError))
+		}
+		return
+	}
+
+	// 4. 校验配置并输出全部问题（无需连接服务器）
+	if opts.ValidateConfig {
+		if err := validateArgs(opts); err != nil {
+			slog.Error("参数验证失败", "error", err)
+			os.Exit(int(exitUsage))
+		}
+		if !runValidateConfig(os.Stdout, cfg, opts.Output) {
+			os.Exit(int(exitConfig))
+		}
+		return
+	}
+
+	// 5. 安装 daemon 为系统服务（--dry-run 时输出服务文件）
+	if opts.InstallService {
+		if err := validateArgs(opts); err != nil {
+			slog.Error("参数验证失败", "error", err)
+			os.Exit(int(exitUsage))
+		}
+		if err := runInstallService(os.Stdout, opts); err != nil {
+			slog.Error("安装服务失败", "error", err)
+			os.Exit(int(exitError))
+		}
+		return
+	}
+
+	// 6. 请求服务端向本机 daemon 定向推送
+	if opts.ForceDomain != "" {
+		if err := runForceDomain(cfg, opts.ForceDomain); err != nil {
+			slog.Error("请求推送失败", "domain", opts.ForceDomain, "error", err)
+			os.Exit(int(exitError))
+		}
+		return
+	}
+
+	// 7. 强制重新同步 daemon 订阅的全部证书
+	if opts.Resync {
+		if err := validateArgs(opts); err != nil {
+			slog.Error("参数验证失败", "error", err)
+			os.Exit(int(exitUsage))
+		}
+		if err := runResync(cfg); err != nil {
+			slog.Error("强制同步失败", "error", err)
+			os.Exit(int(exitError))
+		}
+		return
+	}
+
+	// 8. 轮换认证密钥
+	if opts.RotateKey {
+		if err := runRotateKey(cfg, opts); err != nil {
+			slog.Error("密钥轮换失败", "error", err)
+			os.Exit(int(exitError))
+		}
+		return
+	}
+
+	// 9. 仅执行重载命令（无需连接服务器）
+	if opts.ReloadOnly {
+		if err := validateArgs(opts); err != nil {
+			slog.Error("参数验证失败", "error", err)
+			os.Exit(int(exitUsage))
+		}
+		runReloadOnly(cfg, opts)
+		return
+	}
+
+	// 10. 校验工作目录中已保存的证书（无需连接服务器）
+	if opts.VerifyWorkspace {
+		if err := validateArgs(opts); err != nil {
+			slog.Error("参数验证失败", "error", err)
+			os.Exit(int(exitUsage))
+		}
+		if !runVerifyWorkspace(cfg) {
+			os.Exit(int(exitError))
+		}
+		return
+	}
+
+	// 11. 下线域名（无需连接服务器）
+	if opts.Remove {
+		if err := validateArgs(opts); err != nil {
+			slog.Error("参数验证失败", "error", err)
+			os.Exit(int(exitUsage))
+		}
+		if err := runRemove(os.Stdout, cfg, opts); err != nil {
+			slog.Error("执行失败", "error", err)
+			os.Exit(int(exitError))
+		}
+		return
+	}
+
+	// 12. 离线检查已部署证书的有效期（监控插件，无需连接服务器）
+	if opts.Monitor {
+		if err := validateArgs(opts); err != nil {
+			slog.Error("参数验证失败", "error", err)
+			os.Exit(monitorUnknown)
+		}
+		os.Exit(runMonitor(os.Stdout, cfg, opts, time.Now()))
 	}
 
-	// 4. 检查是否是 daemon 模式
+	// 13. 检查是否是 daemon 模式
 	// 注意：--status 和 --deploy 是一次性命令，应优先执行，不受 daemon.enabled 配置影响
-	if (opts.Daemon || cfg.Daemon.Enabled) && !opts.Status && !opts.Deploy {
+	if (opts.Daemon || cfg.Daemon.Enabled) && !opts.Status && !opts.List && !opts.Deploy && !opts.Check && opts.Get == "" && !opts.Export {
 		runDaemon(cfg)
 		return
 	}
 
-	// 5. 验证参数（非 daemon 模式）
+	// 14. 验证参数（非 daemon 模式）
 	if err := validateArgs(opts); err != nil {
 		slog.Error("参数验证失败", "error", err)
-		os.Exit(1)
+		exitFailure(opts, exitUsage)
 	}
 
-	// 6. 创建 WebSocket 客户端
-	tlsConfig := &client.TLSConfig{
-		CaFile:             cfg.TLSCaFile,
-		InsecureSkipVerify: cfg.TLSInsecureSkipVerify,
-	}
-	wsClient := client.NewWSClient(cfg.Server, cfg.Password, tlsConfig)
+	// 15. 创建 WebSocket 客户端
+	wsClient := client.NewWSClient(cfg.Server, cfg.Password, clientTLSConfig(cfg))
+	wsClient.SetWSPath(cfg.WSPath)
+	wsClient.SetMaxMessageSize(int64(cfg.MaxMessageSize))
+	wsClient.SetConnectTimeout(time.Duration(cfg.Timeouts.Connect) * time.Second)
+	wsClient.SetRequestTimeout(time.Duration(cfg.Timeouts.Request) * time.Second)
+	wsClient.SetRetries(opts.Retries)
+	wsClient.SetAutoReconnect(cfg.AutoReconnect, cfg.MaxReconnectAttempts)
+	wsClient.SetDisableLegacyAuth(cfg.DisableLegacyAuth)
 	ctx := context.Background()
 
-	// 连接服务器
-	if err := wsClient.Connect(ctx); err != nil {
-		slog.Error("连接服务器失败", "error", err)
-		os.Exit(1)
+	// 仅检查更新：以退出码报告结果
+	if opts.Check {
+		if err := wsClient.Connect(ctx); err != nil {
+			slog.Error("连接服务器失败", "error", err)
+			exitFailure(opts, exitConnection)
+		}
+		code := runCheck(ctx, wsClient, cfg, opts)
+		wsClient.Close()
+		os.Exit(code)
 	}
-	defer wsClient.Close()
 
-	// 7. 运行 CLI 逻辑
-	if err := runCLI(ctx, wsClient, cfg, opts); err != nil {
+	// 16. 运行 CLI 逻辑
+	code, err := runCLI(ctx, os.Stdout, wsClient, cfg, opts)
+	if err != nil {
 		slog.Error("执行失败", "error", err)
-		os.Exit(1)
+	}
+	if code != exitOK {
+		os.Exit(int(code))
 	}
 
 	slog.Info("操作完成")
 }
 
-// runCLI 运行 CLI 业务逻辑
-func runCLI(ctx context.Context, wsClient *client.WSClient, cfg *config.ClientConfig, opts *CliOptions) error {
+// runCLI 连接服务器并运行 CLI 业务逻辑，结果按 --output 格式输出到 w
+// 返回的退出码区分失败类别：连接或认证失败、参数错误、部分或全部域名部署失败
+func runCLI(ctx context.Context, w io.Writer, c client.CertClient, cfg *config.ClientConfig, opts *CliOptions) (exitCode, error) {
+	if err := c.Connect(ctx); err != nil {
+		return exitConnection, fmt.Errorf("连接服务器失败: %w", err)
+	}
+	defer c.Close()
 
 	// 服务器状态查询模式
 	if opts.Status {
-		status, err := wsClient.GetServerStatus(ctx)
+		getStatus := c.GetServerStatus
+		if opts.CheckCRL {
+			getStatus = c.GetServerStatusWithCRL
+		}
+		status, err := getStatus(ctx)
 		if err != nil {
-			return fmt.Errorf("获取服务器状态失败: %w", err)
+			return exitError, fmt.Errorf("获取服务器状态失败: %w", err)
 		}
+		report := newStatusReport(cfg.Server, status, time.Now())
+		report.fillLastDeployed(cfg.WorkDir)
+		report.applyFilter(statusFilterFromOptions(opts))
+		return resultCode(renderStatus(w, report, opts.Output))
+	}
 
-		fmt.Println("======== acmeDeliver 服务器状态 ========")
-		fmt.Printf("服务器: %s\n", cfg.Server)
-		fmt.Printf("生成时间: %s\n\n", time.Unix(status.GeneratedAt, 0).Format("2006-01-02 15:04:05"))
-
-		// 在线客户端
-		fmt.Println("─────── 在线客户端 ───────")
-		if len(status.Clients) == 0 {
-			fmt.Println("当前没有客户端在线")
-		} else {
-			fmt.Printf("共 %d 个客户端在线:\n\n", len(status.Clients))
-			for i, c := range status.Clients {
-				connectedAt := time.Unix(c.ConnectedAt, 0)
-				duration := time.Since(connectedAt)
-				durationStr := formatDuration(duration)
-				fmt.Printf("[%d] %s\n", i+1, c.ID)
-				fmt.Printf("    IP: %s\n", c.RemoteIP)
-				fmt.Printf("    连接时间: %s (已连接 %s)\n", connectedAt.Format("2006-01-02 15:04:05"), durationStr)
-				if len(c.Domains) > 0 {
-					fmt.Printf("    订阅域名: %s\n", strings.Join(c.Domains, ", "))
-				} else {
-					fmt.Println("    订阅域名: (无)")
-				}
-				fmt.Println()
-			}
-		}
+	// 直接获取证书文件
+	if opts.Get != "" {
+		return resultCode(runGet(ctx, w, c, opts))
+	}
 
-		// 证书状态
-		fmt.Println("─────── 证书状态 ───────")
-		if len(status.Domains) == 0 {
-			fmt.Println("没有可用的域名证书")
-		} else {
-			fmt.Printf("共 %d 个域名:\n\n", len(status.Domains))
-			for i, d := range status.Domains {
-				// 状态标记
-				statusIcon := "❓"
-				statusText := "未知"
-				if d.Valid {
-					if d.NotAfter > 0 && d.DaysRemaining <= 0 {
-						statusIcon = "🔴"
-						statusText = "证书已过期"
-					} else if d.NotAfter > 0 && d.DaysRemaining <= 7 {
-						statusIcon = "🟡"
-						statusText = "即将过期"
-					} else if d.LastUpdate > 0 {
-						statusIcon = "✅"
-						statusText = "可用"
-					} else {
-						statusIcon = "✅"
-						statusText = "可用（无时间戳）"
-					}
-				} else if d.Error != "" {
-					statusIcon = "❌"
-					statusText = d.Error
-				} else {
-					statusIcon = "⚠️"
-					statusText = "文件异常"
-				}
+	// 导出全部证书
+	if opts.Export {
+		return resultCode(runExport(ctx, c, opts))
+	}
 
-				fmt.Printf("[%d] %s\n", i+1, d.Domain)
-				fmt.Printf("    状态: %s %s\n", statusIcon, statusText)
+	// 域名列表查询模式
+	if opts.List {
+		domains, err := c.ListDomains(ctx)
+		if err != nil {
+			return exitError, fmt.Errorf("获取域名列表失败: %w", err)
+		}
+		return resultCode(renderDomainList(w, domains, opts.Output))
+	}
 
-				if d.LastUpdate > 0 {
-					tm := time.Unix(d.LastUpdate, 0)
-					fmt.Printf("    下发: %s\n", tm.Format("2006-01-02 15:04:05"))
-				}
+	if !opts.Deploy {
+		return exitUsage, fmt.Errorf("未指定操作，请使用 --status、--list、--deploy、--get、--export 或 --check")
+	}
+	results, err := runDeploy(ctx, c, cfg, opts)
+	if err != nil {
+		return exitUsage, err
+	}
+	if err := renderDeployResults(w, results, opts.Output); err != nil {
+		return exitError, err
+	}
+	return deployExitCode(results), nil
+}
 
-				if d.NotAfter > 0 {
-					expireTime := time.Unix(d.NotAfter, 0)
-					expiryIcon := "🟢"
-					expiryText := fmt.Sprintf("剩余 %d 天", d.DaysRemaining)
-					if d.DaysRemaining <= 0 {
-						expiryIcon = "🔴"
-						expiryText = fmt.Sprintf("已过期 %d 天", -d.DaysRemaining)
-					} else if d.DaysRemaining <= 7 {
-						expiryIcon = "🔴"
-					} else if d.DaysRemaining <= 30 {
-						expiryIcon = "🟡"
-					}
-					fmt.Printf("    过期: %s %s (%s)\n", expiryIcon, expireTime.Format("2006-01-02 15:04:05"), expiryText)
-				}
+// resultCode 将不区分类别的错误映射为退出码
+func resultCode(err error) (exitCode, error) {
+	if err != nil {
+		return exitError, err
+	}
+	return exitOK, nil
+}
 
-				if d.Issuer != "" {
-					fmt.Printf("    颁发: %s\n", d.Issuer)
-				}
-				fmt.Println()
-			}
-		}
-		return nil
+// deployExitCode 汇总部署结果：没有失败返回 exitOK，全部失败返回 exitAllFailed，否则返回 exitPartialFailure
+// 证书未更新而跳过的域名视为成功
+func deployExitCode(results []deployResult) exitCode {
+	succeeded, failed := countDeployResults(results)
+	switch {
+	case failed == 0:
+		return exitOK
+	case succeeded == 0:
+		return exitAllFailed
+	default:
+		return exitPartialFailure
 	}
+}
 
+// runDeploy 并发部署域名证书（最多 opts.Concurrency 个同时进行），最后统一执行去重后的 reload 命令
+// 单个域名失败不影响其余域名，失败原因记录在结果中；结果顺序与域名顺序一致
+func runDeploy(ctx context.Context, wsClient client.CertClient, cfg *config.ClientConfig, opts *CliOptions) ([]deployResult, error) {
 	// 获取要处理的域名
 	domains := getDomainsToProcess(cfg, opts)
 	if len(domains) == 0 {
-		return fmt.Errorf("没有指定要处理的域名，请使用 -d 参数或在配置文件中设置 domains")
+		return nil, fmt.Errorf("没有指定要处理的域名，请使用 -d 参数或在配置文件中设置 domains")
 	}
 
-	// 批量 reload 收集器（用于 --deploy 模式）
-	pendingReloads := make(map[string]bool)
-	deployedCount := 0
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+	sinceDeploy, err := parseSinceDeploy(opts.SinceDeploy)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
 
-	// 循环处理每个域名
-	for _, domain := range domains {
-		slog.Info("开始处理域名", "domain", domain)
-		var err error
-		var reloadCmd string
+	// 批量 reload 收集器（各域名并发写入）
+	var mu sync.Mutex
+	pendingReloads := make(map[string]bool)
+	results := make([]deployResult, len(domains))
+
+	// 每个域名使用独立的工作空间与文件锁，WSClient 按请求 ID 分发响应，可安全并发
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, domain := range domains {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, domain string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			log := slog.With("domain", domain)
+			if localTS, recent := deployedWithin(cfg.WorkDir, domain, sinceDeploy, now); recent && !opts.Force {
+				log.Debug("本地证书在 --since-deploy 时长内，跳过", "local_timestamp", time.Unix(localTS, 0), "since_deploy", opts.SinceDeploy)
+				results[i] = deployResult{Domain: domain, Action: actionSkipped}
+				return
+			}
+			log.Info("开始处理域名")
 
-		switch {
-		case opts.Deploy:
 			// 批量部署模式：部署证书但跳过 reload，最后统一执行
-			reloadCmd, err = handleDeployBatch(ctx, wsClient, cfg, domain, opts)
-			if reloadCmd != "" {
-				pendingReloads[reloadCmd] = true
-				deployedCount++
+			result, err := handleDeployBatch(ctx, wsClient, cfg, domain, opts)
+			if err != nil {
+				log.Error("处理域名失败", "error", err)
+				result.Error = err.Error()
+			} else {
+				log.Info("成功处理域名", "action", result.Action)
+			}
+			results[i] = result
+
+			if result.ReloadCmd != "" {
+				mu.Lock()
+				pendingReloads[result.ReloadCmd] = true
+				mu.Unlock()
+			}
+		}(i, domain)
+	}
+	wg.Wait()
+
+	succeeded, failed := countDeployResults(results)
+	slog.Info("域名处理完成", "total", len(results), "succeeded", succeeded, "failed", failed)
+
+	// 统一执行 reload 命令（去重后）
+	var reloadErrs map[string]error
+	if len(pendingReloads) > 0 {
+		slog.Info("开始统一执行重载命令", "commands", len(pendingReloads))
+		reloadErrs = executeReloadCommands(reloadOutput(opts), pendingReloads, time.Duration(cfg.Timeouts.Reload)*time.Second, opts.DryRun)
+		rollbackFailedReloads(reloadOutput(opts), results, reloadErrs, time.Duration(cfg.Timeouts.Reload)*time.Second)
+	}
+	runPostCmds(results, reloadErrs, time.Duration(cfg.Timeouts.Reload)*time.Second, opts.DryRun)
+
+	if !opts.DryRun {
+		recordDeployHistory(cfg, results, reloadErrs, time.Now())
+	}
+	return results, nil
+}
+
+// rollbackFailedReloads 重载命令失败时恢复使用该命令的域名的部署文件备份并重新执行一次该命令
+// 已回滚的域名标记为部署失败；没有备份（如首次部署）的域名保持原结果
+func rollbackFailedReloads(out io.Writer, results []deployResult, reloadErrs map[string]error, timeout time.Duration) {
+	for cmd, reloadErr := range reloadErrs {
+		if reloadErr == nil {
+			continue
+		}
+		var backups []*client.DeployBackup
+		var idx []int
+		for i, r := range results {
+			if r.Action == actionDeployed && r.ReloadCmd == cmd && !r.backup.Empty() {
+				backups = append(backups, r.backup)
+				idx = append(idx, i)
 			}
 		}
+		if len(backups) == 0 {
+			continue
+		}
 
-		if err != nil {
-			slog.Error("处理域名失败", "domain", domain, "error", err)
-		} else {
-			slog.Info("成功处理域名", "domain", domain)
+		slog.Warn("重载命令失败，回滚到部署前的证书", "cmd", cmd, "domains", len(backups))
+		rollbackErr := client.RollbackDeployments(backups, func() error {
+			return executeReloadCommands(out, map[string]bool{cmd: true}, timeout, false)[cmd]
+		})
+		err := client.RollbackError(reloadErr, rollbackErr)
+		for _, i := range idx {
+			slog.Error("部署已回滚", "domain", results[i].Domain, "error", err)
+			results[i].Action = actionFailed
+			results[i].Error = err.Error()
 		}
-		fmt.Println()
 	}
+}
 
-	// 统一执行 reload 命令（去重后）
-	if opts.Deploy && deployedCount > 0 && len(pendingReloads) > 0 {
-		slog.Info("开始统一执行重载命令", "deployed", deployedCount, "commands", len(pendingReloads))
-		executeReloadCommands(pendingReloads, opts.DryRun)
+// runPostCmds 为部署成功的域名执行 postcmd，重载命令失败的域名跳过
+// postcmd 失败只记录日志，不影响部署结果
+func runPostCmds(results []deployResult, reloadErrs map[string]error, timeout time.Duration, dryRun bool) {
+	for _, r := range results {
+		if r.Action != actionDeployed || r.postCmd == "" {
+			continue
+		}
+		if err := reloadErrs[r.ReloadCmd]; err != nil {
+			slog.Warn("重载命令失败，跳过 postcmd", "domain", r.Domain, "reload_cmd", r.ReloadCmd)
+			continue
+		}
+		if err := client.RunHook(client.HookPostCmd, r.postCmd, r.Domain, timeout, dryRun); err != nil {
+			slog.Error("postcmd 执行失败", "domain", r.Domain, "error", err)
+		}
 	}
+}
 
-	return nil
+// recordDeployHistory 为部署成功的域名追加部署历史，重载命令的执行结果一并记录
+// 写入失败只记录警告，不视为部署失败
+func recordDeployHistory(cfg *config.ClientConfig, results []deployResult, reloadErrs map[string]error, now time.Time) {
+	retention := time.Duration(cfg.DeployHistoryRetentionDays) * 24 * time.Hour
+	for _, r := range results {
+		if r.Action != actionDeployed || len(r.certPEM) == 0 {
+			continue
+		}
+		var reloadOK *bool
+		if err, ran := reloadErrs[r.ReloadCmd]; ran && r.ReloadCmd != "" {
+			ok := err == nil
+			reloadOK = &ok
+		}
+		rec, err := workspace.NewDeployRecord(r.certPEM, workspace.DeployerLocal, reloadOK, now)
+		if err == nil {
+			err = workspace.AppendDeployHistory(cfg.WorkDir, r.Domain, rec, retention)
+		}
+		if err != nil {
+			slog.Warn("记录部署历史失败", "domain", r.Domain, "error", err)
+		}
+	}
 }
 
 // getDomainsToProcess 获取要处理的域名列表
@@ -295,54 +686,85 @@ func getDomainsToProcess(cfg *config.ClientConfig, opts *CliOptions) []string {
 }
 
 // handleDeployBatch 批量部署证书（不执行 reload）
-// 返回需要执行的 reload 命令（如有），由调用方统一执行
-func handleDeployBatch(ctx context.Context, wsClient *client.WSClient, cfg *config.ClientConfig, domain string, opts *CliOptions) (string, error) {
-	slog.Debug("开始部署流程", "domain", domain, "dryRun", opts.DryRun)
+// 结果中的 reload 命令（如有）由调用方统一执行；出错时结果的 Action 为 failed
+func handleDeployBatch(ctx context.Context, wsClient client.CertClient, cfg *config.ClientConfig, domain string, opts *CliOptions) (deployResult, error) {
+	log := slog.With("domain", domain)
+	log.Debug("开始部署流程", "dryRun", opts.DryRun)
+	failed := deployResult{Domain: domain, Action: actionFailed}
 
 	// 1. 创建工作空间
 	ws := workspace.NewWorkspace(cfg.WorkDir, domain)
+	ws.SetSyncOnWrite(cfg.SyncOnWrite)
 	if err := ws.Ensure(); err != nil {
-		return "", fmt.Errorf("创建工作空间失败: %w", err)
+		return failed, fmt.Errorf("创建工作空间失败: %w", err)
 	}
 
 	// 2. 获取文件锁
-	lock, err := ws.Lock()
+	lock, err := ws.LockWithTimeout(cfg.LockTimeout())
 	if err != nil {
-		return "", fmt.Errorf("无法获取文件锁: %w", err)
+		return failed, fmt.Errorf("无法获取文件锁: %w", err)
 	}
 	defer lock.Unlock()
 
-	// 3. 下载证书 (WebSocket request)
-	certs, err := wsClient.DownloadCert(ctx, domain, opts.Force)
+	// 3. 下载证书：携带本地时间戳，服务端证书未更新时不返回文件
+	// 强制模式忽略本地时间戳，服务端总是返回全部文件，随后重写工作目录与部署文件并执行重载
+	localTS := ws.Timestamp()
+	since := localTS
+	if opts.Force {
+		since = 0
+	}
+	certs, err := wsClient.DownloadCertSince(ctx, domain, since, opts.Force)
 	if err != nil {
-		return "", fmt.Errorf("下载证书失败: %w", err)
+		return failed, fmt.Errorf("下载证书失败: %w", err)
+	}
+
+	if !needsDeploy(localTS, certs.Timestamp, opts.Force) {
+		// 旧版服务端不返回校验和，此时仅凭时间戳判断
+		if len(certs.Checksums) == 0 || ws.MatchesChecksums(certs.Checksums) {
+			log.Info("证书未更新，跳过部署（使用 -f 强制部署）", "timestamp", certs.Timestamp)
+			return deployResult{Domain: domain, Action: actionSkipped}, nil
+		}
+
+		// 时间戳一致但本地文件被修改或损坏，重新下载完整证书
+		log.Warn("本地证书文件与服务端不一致，重新下载")
+		if certs, err = wsClient.DownloadCertSince(ctx, domain, localTS, true); err != nil {
+			return failed, fmt.Errorf("下载证书失败: %w", err)
+		}
 	}
 
 	if certs.IsEmpty() {
-		slog.Warn("未获取到证书数据")
-		return "", nil
+		log.Warn("未获取到证书数据")
+		return deployResult{Domain: domain, Action: actionSkipped}, nil
 	}
 
 	// 4. 保存到工作空间
 	if err := ws.SaveCertificateFiles(certs); err != nil {
-		return "", fmt.Errorf("保存证书失败: %w", err)
+		return failed, fmt.Errorf("保存证书失败: %w", err)
 	}
-	slog.Info("证书已保存到工作目录", "dir", ws.GetWorkDir())
+	log.Info("证书已保存到工作目录", "dir", ws.GetWorkDir())
 
 	// 5. 查找部署配置
-	site := findSiteConfig(cfg, domain)
+	site := config.FindSite(cfg.Sites, domain)
 	if site == nil {
-		slog.Info("未找到此域名的站点部署配置，跳过部署步骤", "domain", domain)
-		return "", nil
+		log.Info("未找到此域名的站点部署配置，跳过部署步骤")
+		if opts.DryRun {
+			return deployResult{Domain: domain, Action: actionDeployed}, nil
+		}
+		saveDeployedTimestamp(ws, certs.Timestamp)
+		return deployResult{Domain: domain, Action: actionDeployed, certPEM: certs.Cert}, nil
+	}
+
+	// 启用 verify_ct 的站点要求证书已提交到 CT 日志，未通过时不部署
+	if err := client.VerifySiteCT(site, certs.Cert, certs.Fullchain); err != nil {
+		return failed, err
 	}
 
 	// 6. 确定 reload 命令
-	// 优先级: 命令行 > 站点配置 > 全局默认
-	reloadCmd := site.ReloadCmd
-	if opts.ReloadCmd != "" {
-		reloadCmd = opts.ReloadCmd
-	} else if reloadCmd == "" && cfg.DefaultReloadCmd != "" {
-		reloadCmd = cfg.DefaultReloadCmd
+	reloadCmd := resolveReloadCmd(cfg, site, opts)
+
+	pkcs12Password, err := site.PKCS12Secret()
+	if err != nil {
+		return failed, fmt.Errorf("读取 PKCS#12 密码失败: %w", err)
 	}
 
 	// 7. 准备部署配置（跳过 reload，由调用方统一执行）
@@ -351,32 +773,336 @@ func handleDeployBatch(ctx context.Context, wsClient *client.WSClient, cfg *conf
 		CertPath:      site.CertPath,
 		KeyPath:       site.KeyPath,
 		FullchainPath: site.FullchainPath,
+		BundlePath:    site.BundlePath,
+		CombinedPath:  site.CombinedPath,
+		CombinedOrder: site.CombinedOrder,
 		ReloadCmd:     reloadCmd,
 		SkipReload:    true, // 批量模式：跳过 reload
+		Force:         opts.Force,
+		ReloadTimeout: time.Duration(cfg.Timeouts.Reload) * time.Second,
+
+		VerifyAfterDeploy: site.VerifyAfterDeploy,
+		BackupRetention:   cfg.DeployBackupRetention(),
+		SyncOnWrite:       cfg.SyncOnWrite,
+		PKCS12Path:        site.PKCS12Path,
+		PKCS12Password:    pkcs12Password,
+		PKCS12Legacy:      site.PKCS12Legacy,
+		FileOwner:         site.OwnerName(),
+		FileGroup:         site.GroupName(),
+	}
+	deployConfig.CertMode, deployConfig.KeyMode, deployConfig.DirMode = site.FileModes()
+
+	// 8. 写入文件前执行 precmd，失败时取消部署
+	if err := client.RunHook(client.HookPreCmd, site.PreCmd, domain, deployConfig.ReloadTimeout, opts.DryRun); err != nil {
+		return failed, err
 	}
 
 	if opts.DryRun {
-		slog.Info("[DryRun] 模式: 证书将会被部署",
+		log.Info("[DryRun] 模式: 证书将会被部署",
 			"cert", deployConfig.CertPath,
 			"cmd", reloadCmd)
-		return reloadCmd, nil
+		return deployResult{Domain: domain, Action: actionDeployed, ReloadCmd: reloadCmd, postCmd: site.PostCmd}, nil
 	}
 
-	// 8. 执行部署（只写入文件，不执行 reload）
+	// 9. 执行部署（只写入文件，不执行 reload）
 	d, err := deployer.NewDeployer(deployConfig)
 	if err != nil {
-		return "", fmt.Errorf("创建部署器失败: %w", err)
+		return failed, fmt.Errorf("创建部署器失败: %w", err)
 	}
 
 	if err := d.Deploy(certs, opts.DryRun); err != nil {
-		return "", fmt.Errorf("部署执行失败: %w", err)
+		return failed, fmt.Errorf("部署执行失败: %w", err)
+	}
+	saveDeployedTimestamp(ws, certs.Timestamp)
+
+	// 部署文件内容均未变化（-f 除外）：不执行 reload 与 postcmd，不记录部署历史
+	if deployer.UnchangedOf(d) {
+		log.Info("部署文件内容均未变化，跳过重载（使用 -f 强制重载）")
+		return deployResult{Domain: domain, Action: actionUnchanged}, nil
+	}
+
+	return deployResult{Domain: domain, Action: actionDeployed, ReloadCmd: reloadCmd, certPEM: certs.Cert, postCmd: site.PostCmd, backup: deployer.BackupOf(d)}, nil
+}
+
+// needsDeploy 判断是否需要部署：强制模式、本地或服务端缺少时间戳、服务端证书更新时返回 true
+func needsDeploy(localTS, remoteTS int64, force bool) bool {
+	return force || localTS == 0 || remoteTS == 0 || remoteTS > localTS
+}
+
+// parseSinceDeploy 解析 --since-deploy：支持 time.ParseDuration 的格式与以 d 结尾的天数（如 7d），空字符串返回 0
+func parseSinceDeploy(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	var d time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("--since-deploy 无效: %q", s)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(s); err != nil {
+			return 0, fmt.Errorf("--since-deploy 无效: %q", s)
+		}
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("--since-deploy 必须大于 0，当前值: %q", s)
+	}
+	return d, nil
+}
+
+// deployedWithin 判断工作目录中域名证书的时间戳是否在 window 内，同时返回该时间戳
+// window 为 0 或本地没有时间戳时返回 false
+func deployedWithin(workDir, domain string, window time.Duration, now time.Time) (int64, bool) {
+	if window <= 0 {
+		return 0, false
+	}
+	localTS := workspace.GetDomainTimestamp(workDir, domain)
+	if localTS <= 0 {
+		return 0, false
+	}
+	return localTS, now.Sub(time.Unix(localTS, 0)) < window
+}
+
+// saveDeployedTimestamp 部署成功后记录服务端时间戳
+// 写入失败只影响下次运行是否跳过，不视为部署失败
+func saveDeployedTimestamp(ws *workspace.Workspace, timestamp int64) {
+	if timestamp <= 0 {
+		return
+	}
+	if err := ws.SaveTimestamp(timestamp); err != nil {
+		slog.Warn("记录证书时间戳失败", "error", err)
+	}
+}
+
+// checkResult 单个域名的更新检查结果
+type checkResult struct {
+	Domain string `json:"domain"`
+	Local  int64  `json:"local"`  // 本地已部署证书的时间戳（0 表示未部署）
+	Remote int64  `json:"remote"` // 服务端证书时间戳
+	Update bool   `json:"update"` // 是否有可用更新
+	Error  string `json:"error"`
+}
+
+// runCheck 检查各域名是否有可用更新，只读取本地时间戳，不写入任何文件
+// 返回 --check 模式的退出码
+func runCheck(ctx context.Context, wsClient client.CertClient, cfg *config.ClientConfig, opts *CliOptions) int {
+	domains := getDomainsToProcess(cfg, opts)
+	if len(domains) == 0 {
+		slog.Error("没有指定要检查的域名，请使用 -d 参数或在配置文件中设置 domains")
+		return checkExitError
+	}
+
+	// 通过状态查询获取服务端时间戳，不传输证书与私钥
+	status, err := wsClient.GetServerStatus(ctx)
+	if err != nil {
+		slog.Error("获取服务器状态失败", "error", err)
+		return checkExitError
+	}
+
+	results := checkUpdates(status.Domains, domains, cfg.WorkDir)
+	if err := renderCheckResults(os.Stdout, results, opts.Output); err != nil {
+		slog.Error("输出检查结果失败", "error", err)
+		return checkExitError
+	}
+	return checkExitCode(results)
+}
+
+// checkUpdates 对比服务端与本地时间戳，判定规则与 --deploy 一致
+func checkUpdates(serverDomains []websocket.DomainStatus, domains []string, workDir string) []checkResult {
+	remote := make(map[string]websocket.DomainStatus, len(serverDomains))
+	for _, d := range serverDomains {
+		remote[d.Domain] = d
+	}
+
+	results := make([]checkResult, 0, len(domains))
+	for _, domain := range domains {
+		r := checkResult{Domain: domain, Local: workspace.GetDomainTimestamp(workDir, domain)}
+		if d, ok := remote[domain]; !ok {
+			r.Error = "服务端不存在该域名"
+		} else {
+			r.Remote = d.LastUpdate
+			r.Update = needsDeploy(r.Local, r.Remote, false)
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// checkExitCode 汇总检查结果：任一出错返回 2，存在更新返回 1，否则返回 0
+func checkExitCode(results []checkResult) int {
+	code := checkExitUpToDate
+	for _, r := range results {
+		if r.Error != "" {
+			return checkExitError
+		}
+		if r.Update {
+			code = checkExitUpdates
+		}
+	}
+	return code
+}
+
+// formatTimestamp 格式化时间戳，0 显示为"无"
+func formatTimestamp(ts int64) string {
+	if ts == 0 {
+		return "无"
+	}
+	return time.Unix(ts, 0).Format("2006-01-02 15:04:05")
+}
+
+// exitFailure 以 code 退出，--check / --monitor 模式下使用约定的错误退出码
+func exitFailure(opts *CliOptions, code exitCode) {
+	if opts.Monitor {
+		os.Exit(monitorUnknown)
+	}
+	if opts.Check {
+		os.Exit(checkExitError)
+	}
+	os.Exit(int(code))
+}
+
+// resolveReloadCmd 确定站点的 reload 命令
+// 优先级: 命令行 > 站点配置 > 全局默认
+func resolveReloadCmd(cfg *config.ClientConfig, site *config.SiteDeployConfig, opts *CliOptions) string {
+	if opts.ReloadCmd != "" {
+		return opts.ReloadCmd
+	}
+	if site.ReloadCmd != "" {
+		return site.ReloadCmd
+	}
+	return cfg.DefaultReloadCmd
+}
+
+// collectReloadCommands 收集站点配置中的 reload 命令（去重）
+// 指定 -d 时只收集这些域名匹配到的站点，否则收集全部站点
+func collectReloadCommands(cfg *config.ClientConfig, opts *CliOptions) map[string]bool {
+	commands := make(map[string]bool)
+
+	if opts.DomainsStr != "" {
+		for _, domain := range getDomainsToProcess(cfg, opts) {
+			site := config.FindSite(cfg.Sites, domain)
+			if site == nil {
+				slog.Warn("未找到此域名的站点配置，跳过", "domain", domain)
+				continue
+			}
+			if cmd := resolveReloadCmd(cfg, site, opts); cmd != "" {
+				commands[cmd] = true
+			}
+		}
+		return commands
+	}
+
+	for i := range cfg.Sites {
+		if cmd := resolveReloadCmd(cfg, &cfg.Sites[i], opts); cmd != "" {
+			commands[cmd] = true
+		}
+	}
+	return commands
+}
+
+// runReloadOnly 仅执行重载命令（证书已部署，例如手动修改后需要重新加载服务）
+func runReloadOnly(cfg *config.ClientConfig, opts *CliOptions) {
+	commands := collectReloadCommands(cfg, opts)
+	if len(commands) == 0 {
+		slog.Warn("没有可执行的重载命令，请检查站点配置中的 reloadcmd 或使用 --reload-cmd")
+		return
+	}
+
+	slog.Info("开始执行重载命令", "commands", len(commands), "dryRun", opts.DryRun)
+	executeReloadCommands(reloadOutput(opts), commands, time.Duration(cfg.Timeouts.Reload)*time.Second, opts.DryRun)
+}
+
+// runInstallService 生成并安装以 daemon 模式运行的系统服务
+// 客户端需要向 /etc 等目录部署证书，只将 /usr、/boot 设为只读（ProtectSystem=true），且不使用独立的 /tmp
+func runInstallService(w io.Writer, opts *CliOptions) error {
+	if configFile == "" {
+		return fmt.Errorf("请使用 -c 指定配置文件，服务启动时将使用该文件")
+	}
+	configPath, err := filepath.Abs(configFile)
+	if err != nil {
+		return err
+	}
+	exe, err := install.Executable()
+	if err != nil {
+		return fmt.Errorf("获取可执行文件路径失败: %w", err)
+	}
+	workDir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	svc := &install.Service{
+		Name:          "acmedeliver-client",
+		Description:   "acmeDeliver 证书分发客户端",
+		ExecPath:      exe,
+		Args:          []string{"-c", configPath, "--daemon"},
+		User:          opts.ServiceUser,
+		Group:         opts.ServiceGroup,
+		WorkingDir:    workDir,
+		ProtectSystem: "true",
+	}
+	return install.Run(w, opts.InitSystem, svc, opts.DryRun, opts.ServiceEnable)
+}
+
+// runValidateConfig 校验配置并输出全部问题，配置有效时返回 true
+func runValidateConfig(w io.Writer, cfg *config.ClientConfig, format string) bool {
+	errs := config.ValidateClientConfig(cfg)
+	if err := renderValidationErrors(w, errs, format); err != nil {
+		slog.Error("输出校验结果失败", "error", err)
+	}
+	return len(errs) == 0
+}
+
+// runVerifyWorkspace 校验工作目录中所有域名的证书完整性，全部通过时返回 true
+func runVerifyWorkspace(cfg *config.ClientConfig) bool {
+	results, err := workspace.VerifyAll(cfg.WorkDir)
+	if err != nil {
+		slog.Error("读取工作目录失败", "dir", cfg.WorkDir, "error", err)
+		return false
+	}
+	if len(results) == 0 {
+		fmt.Println("工作目录中没有域名证书")
+		return true
+	}
+
+	domains := make([]string, 0, len(results))
+	for domain := range results {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+
+	failed := 0
+	for _, domain := range domains {
+		if err := results[domain]; err != nil {
+			fmt.Printf("❌ %s: %v\n", domain, err)
+			failed++
+		} else {
+			fmt.Printf("✅ %s\n", domain)
+		}
 	}
+	fmt.Printf("\n共 %d 个域名，%d 个校验失败\n", len(domains), failed)
+	return failed == 0
+}
 
-	return reloadCmd, nil
+// reloadOutput 返回重载命令输出的写入位置，--output json 时 stdout 专用于输出结果，改为 stderr
+func reloadOutput(opts *CliOptions) io.Writer {
+	if opts.Output == outputJSON {
+		return os.Stderr
+	}
+	return os.Stdout
 }
 
-// executeReloadCommands 统一执行去重后的 reload 命令
-func executeReloadCommands(commands map[string]bool, dryRun bool) {
+// executeReloadCommands 统一执行去重后的 reload 命令，命令输出逐行实时写入 out
+// timeout 为单个命令的执行超时，不大于 0 时使用 client.DefaultReloadTimeout
+// 返回实际执行的命令及其执行结果（成功为 nil），dryRun 时为空
+func executeReloadCommands(out io.Writer, commands map[string]bool, timeout time.Duration, dryRun bool) map[string]error {
+	if timeout <= 0 {
+		timeout = client.DefaultReloadTimeout
+	}
+	results := make(map[string]error, len(commands))
 	for cmd := range commands {
 		if cmd == "" {
 			continue
@@ -386,32 +1112,33 @@ func executeReloadCommands(commands map[string]bool, dryRun bool) {
 			continue
 		}
 		slog.Info("执行重载命令", "cmd", cmd)
-		output, err := command.Execute(context.Background(), cmd, 15*time.Second)
+		err := command.ExecuteStreaming(context.Background(), cmd, timeout, func(line string) {
+			fmt.Fprintln(out, line)
+		})
 		if err != nil {
-			slog.Error("重载命令执行失败", "cmd", cmd, "error", err, "output", output)
+			slog.Error("重载命令执行失败", "cmd", cmd, "error", err)
 		} else {
-			slog.Info("重载命令执行成功", "cmd", cmd, "output", output)
+			slog.Info("重载命令执行成功", "cmd", cmd)
 		}
+		results[cmd] = err
 	}
+	return results
 }
 
-// findSiteConfig 查找域名对应的站点配置
-func findSiteConfig(cfg *config.ClientConfig, domain string) *config.SiteDeployConfig {
-	for i := range cfg.Sites {
-		site := &cfg.Sites[i]
-		// 精确匹配
-		if site.Domain == domain {
-			return site
-		}
-		// 通配符匹配
-		if strings.HasPrefix(site.Domain, "*.") {
-			suffix := site.Domain[1:] // .example.com
-			if strings.HasSuffix(domain, suffix) {
-				return site
-			}
-		}
+// daemonSyncInterval 计算定时同步间隔，返回 0 表示禁用（重连同步仍然有效）
+// sync_interval_minutes 为正数时优先使用；否则 sync_interval 正数为自定义间隔（秒），0/未设置为默认 1 小时
+// 任一字段为负数表示禁用
+func daemonSyncInterval(cfg config.DaemonModeConfig) time.Duration {
+	switch {
+	case cfg.SyncIntervalMinutes > 0:
+		return time.Duration(cfg.SyncIntervalMinutes) * time.Minute
+	case cfg.SyncIntervalMinutes < 0, cfg.SyncInterval < 0:
+		return 0
+	case cfg.SyncInterval > 0:
+		return time.Duration(cfg.SyncInterval) * time.Second
+	default:
+		return time.Hour
 	}
-	return nil
 }
 
 // runDaemon 运行 daemon 模式
@@ -420,11 +1147,50 @@ func runDaemon(cfg *config.ClientConfig) {
 		"server", cfg.Server,
 		"subscribe", cfg.Subscribe)
 
+	daemonCfg := newDaemonConfig(cfg)
+	slog.Info("客户端标识", "client_id", daemonCfg.ClientID)
+	daemon := client.NewDaemon(daemonCfg)
+
+	// 启动配置热重载（如果指定了配置文件）
+	if configFile != "" {
+		watcher := config.NewClientConfigWatcher(configFile, cfg)
+
+		// 注册配置更新回调
+		watcher.RegisterCallback(func(oldCfg, newCfg *config.ClientConfig) {
+			slog.Info("检测到配置变化，更新 Daemon 配置")
+			daemon.UpdateConfig(newCfg.Server, newCfg.Subscribe, newCfg.Sites)
+			// 调试模式下固定为 debug 级别
+			if !newCfg.Debug {
+				if err := logger.SetLevel(newCfg.Logging.Level); err != nil {
+					slog.Warn("日志级别无效，保持原级别", "error", err)
+				}
+			}
+		})
+
+		if err := watcher.Start(); err != nil {
+			slog.Warn("启动配置热重载失败", "error", err)
+		} else {
+			defer watcher.Stop()
+		}
+	}
+
+	// logrotate 移走日志文件后通过 SIGHUP 通知重新打开
+	defer logger.ReopenOnSIGHUP()()
+
+	if err := daemon.Run(context.Background()); err != nil {
+		slog.Error("Daemon 运行失败", "error", err)
+		os.Exit(1)
+	}
+}
+
+// newDaemonConfig 根据客户端配置生成 daemon 配置，未设置的间隔使用默认值
+func newDaemonConfig(cfg *config.ClientConfig) *client.DaemonConfig {
 	// 设置默认值
 	reconnectInterval := 30 * time.Second
 	heartbeatInterval := 60 * time.Second
 	reloadDebounce := 5 * time.Second
-	syncInterval := 1 * time.Hour // 默认 1 小时同步一次
+	pongTimeout := 90 * time.Second
+	reconnectJitterMax := 10 * time.Second
 
 	if cfg.Daemon.ReconnectInterval > 0 {
 		reconnectInterval = time.Duration(cfg.Daemon.ReconnectInterval) * time.Second
@@ -435,84 +1201,190 @@ func runDaemon(cfg *config.ClientConfig) {
 	if cfg.Daemon.ReloadDebounce > 0 {
 		reloadDebounce = time.Duration(cfg.Daemon.ReloadDebounce) * time.Second
 	}
-	// SyncInterval: 正数=自定义间隔，0/未设置=默认1小时，负数=禁用
-	if cfg.Daemon.SyncInterval > 0 {
-		syncInterval = time.Duration(cfg.Daemon.SyncInterval) * time.Second
-	} else if cfg.Daemon.SyncInterval < 0 {
-		// 负数表示禁用定时同步（重连同步仍然有效）
-		syncInterval = 0
+	if cfg.Daemon.PongTimeout > 0 {
+		pongTimeout = time.Duration(cfg.Daemon.PongTimeout) * time.Second
 	}
-	// SyncInterval == 0（未设置）时使用默认值 syncInterval = 1 * time.Hour
-
-	// 获取客户端 ID（使用主机名）
-	clientID, _ := os.Hostname()
-	if clientID == "" {
-		clientID = "acmedeliver-client"
+	// ReconnectJitterMaxSeconds: 正数=自定义上限，0/未设置=默认10秒，负数=禁用
+	if cfg.Daemon.ReconnectJitterMaxSeconds > 0 {
+		reconnectJitterMax = time.Duration(cfg.Daemon.ReconnectJitterMaxSeconds) * time.Second
+	} else if cfg.Daemon.ReconnectJitterMaxSeconds < 0 {
+		reconnectJitterMax = 0
 	}
 
 	// 直接使用配置中的站点配置（类型已统一为 config.SiteDeployConfig）
-	daemonCfg := &client.DaemonConfig{
-		ServerURL:         cfg.Server,
-		Password:          cfg.Password,
-		ClientID:          clientID,
-		WorkDir:           cfg.WorkDir,
-		Subscribe:         cfg.Subscribe,
-		Sites:             cfg.Sites,
-		ReconnectInterval: reconnectInterval,
-		HeartbeatInterval: heartbeatInterval,
-		ReloadDebounce:    reloadDebounce,
-		SyncInterval:      syncInterval,
-		TLSConfig: &client.TLSConfig{
-			CaFile:             cfg.TLSCaFile,
-			InsecureSkipVerify: cfg.TLSInsecureSkipVerify,
-		},
+	return &client.DaemonConfig{
+		ServerURL:          cfg.Server,
+		WSPath:             cfg.WSPath,
+		MaxMessageSize:     int64(cfg.MaxMessageSize),
+		Password:           cfg.Password,
+		ClientID:           resolveClientID(cfg.ClientID, os.Hostname),
+		WorkDir:            cfg.WorkDir,
+		Subscribe:          cfg.Subscribe,
+		Sites:              cfg.Sites,
+		ReconnectInterval:  reconnectInterval,
+		ReconnectJitterMax: reconnectJitterMax,
+		HeartbeatInterval:  heartbeatInterval,
+		PongTimeout:        pongTimeout,
+		ReloadDebounce:     reloadDebounce,
+		ReloadTimeout:      time.Duration(cfg.Timeouts.Reload) * time.Second,
+		ShutdownTimeout:    time.Duration(cfg.Timeouts.Shutdown) * time.Second,
+		HistoryRetention:   time.Duration(cfg.DeployHistoryRetentionDays) * 24 * time.Hour,
+		BackupRetention:    cfg.DeployBackupRetention(),
+		VerifyPush:         cfg.VerifyPushMode(),
+		SyncOnWrite:        cfg.SyncOnWrite,
+		SyncInterval:       daemonSyncInterval(cfg.Daemon),
+		DeployOnStart:      cfg.Daemon.DeployOnStartEnabled(),
+		CleanupWorkdir:     cfg.Daemon.CleanupWorkdir,
+		StatusListen:       cfg.Daemon.StatusListen,
+		DisableLegacyAuth:  cfg.DisableLegacyAuth,
+		ConfigFile:         configFile,
+		TLSConfig:          clientTLSConfig(cfg),
 	}
+}
 
-	daemon := client.NewDaemon(daemonCfg)
+// resolveClientID 确定 daemon 客户端 ID
+// 优先级：配置/命令行 > 主机名 > 随机 UUID
+// 随机 UUID 每次启动都会变化，仅作为最后兜底
+func resolveClientID(configured string, hostname func() (string, error)) string {
+	if id := strings.TrimSpace(configured); id != "" {
+		return id
+	}
+	if name, err := hostname(); err == nil && name != "" {
+		return name
+	}
+	id := uuid.New().String()
+	slog.Warn("无法获取主机名，使用随机客户端 ID（重启后会变化，建议配置 client_id）", "client_id", id)
+	return id
+}
 
-	// 启动配置热重载（如果指定了配置文件）
-	if configFile != "" {
-		watcher := config.NewClientConfigWatcher(configFile, cfg)
+// clientTLSConfig 根据客户端配置构建连接服务端使用的 TLS 配置（含 mTLS 客户端证书）
+func clientTLSConfig(cfg *config.ClientConfig) *client.TLSConfig {
+	return &client.TLSConfig{
+		CaFile:             cfg.TLSCaFile,
+		InsecureSkipVerify: cfg.TLSInsecureSkipVerify,
+		CertFile:           cfg.ClientCertFile,
+		KeyFile:            cfg.ClientKeyFile,
+	}
+}
 
-		// 注册配置更新回调
-		watcher.RegisterCallback(func(oldCfg, newCfg *config.ClientConfig) {
-			slog.Info("检测到配置变化，更新 Daemon 配置")
-			daemon.UpdateConfig(newCfg.Subscribe, newCfg.Sites)
-		})
+// errAdminTokenRequired 调用服务端管理接口但未配置 admin_token
+var errAdminTokenRequired = errors.New("未配置 admin_token：--force-domain、--rotate-key 需要与服务端一致的 admin_token（或环境变量 ACMEDELIVER_ADMIN_TOKEN）")
 
-		if err := watcher.Start(); err != nil {
-			slog.Warn("启动配置热重载失败", "error", err)
-		} else {
-			defer watcher.Stop()
-		}
+// runForceDomain 请求服务端立即向本机 daemon 推送指定域名
+// 推送以 admin_push 消息发送，仅本机 daemon 接收
+func runForceDomain(cfg *config.ClientConfig, domain string) error {
+	if cfg.AdminToken == "" {
+		return errAdminTokenRequired
 	}
+	apiClient := client.NewAPIClient(cfg.Server, cfg.Password, clientTLSConfig(cfg))
+	apiClient.SetWSPath(cfg.WSPath)
+	apiClient.SetDisableLegacyAuth(cfg.DisableLegacyAuth)
+	apiClient.SetAdminToken(cfg.AdminToken)
 
-	if err := daemon.Run(context.Background()); err != nil {
-		slog.Error("Daemon 运行失败", "error", err)
-		os.Exit(1)
+	clientID := resolveClientID(cfg.ClientID, os.Hostname)
+	result, err := apiClient.PushDomain(context.Background(), domain, clientID)
+	if err != nil {
+		return err
 	}
+
+	slog.Info("已请求服务端推送证书", "domain", result.Domain, "client_id", result.ClientID, "sent", result.Sent)
+	return nil
 }
 
-// setupLogger 设置日志
-// 修复：通过 HandlerOptions 正确设置日志级别
-func setupLogger(debug bool) {
-	var level slog.Level
-	if debug {
-		level = slog.LevelDebug
+// resyncClientIDSuffix --resync 使用的客户端 ID 后缀，避免与本机运行中的 daemon 连接冲突
+const resyncClientIDSuffix = "-resync"
+
+// runResync 以 daemon 配置连接服务端，用全部为 0 的时间戳请求订阅的全部证书并部署，完成后退出
+// 超时时间取 timeouts.request，未设置时为 2 分钟
+func runResync(cfg *config.ClientConfig) error {
+	daemonCfg := newDaemonConfig(cfg)
+	daemonCfg.ClientID += resyncClientIDSuffix
+
+	timeout := 2 * time.Minute
+	if cfg.Timeouts.Request > 0 {
+		timeout = time.Duration(cfg.Timeouts.Request) * time.Second
+	}
+
+	slog.Info("开始强制同步", "server", cfg.Server, "subscribe", cfg.Subscribe)
+	pushed, err := client.NewDaemon(daemonCfg).Resync(context.Background(), timeout)
+	if err != nil {
+		return err
+	}
+	slog.Info("✅ 强制同步完成", "pushed", pushed)
+	return nil
+}
+
+// runRotateKey 请求服务端轮换认证密钥并输出结果
+// 服务端等待在线 daemon 用新密钥重新认证后才返回，未及时切换的客户端需手动更新 password
+func runRotateKey(cfg *config.ClientConfig, opts *CliOptions) error {
+	if cfg.AdminToken == "" {
+		return errAdminTokenRequired
+	}
+	apiClient := client.NewAPIClient(cfg.Server, cfg.Password, clientTLSConfig(cfg))
+	apiClient.SetWSPath(cfg.WSPath)
+	apiClient.SetDisableLegacyAuth(cfg.DisableLegacyAuth)
+	apiClient.SetAdminToken(cfg.AdminToken)
+
+	// 服务端默认最长等待 60 秒，额外留出余量
+	window := time.Duration(opts.RotateWindow) * time.Second
+	timeout := 2 * time.Minute
+	if window > 0 {
+		timeout = window + time.Minute
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	fmt.Println("🔑 正在轮换密钥，等待在线 daemon 使用新密钥重新认证...")
+	result, err := apiClient.RotateKey(ctx, opts.NewKey, window)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n新密钥: %s\n", result.Key)
+	fmt.Printf("已通知连接: %d\n", result.Notified)
+	fmt.Printf("已重新认证: %d %v\n", len(result.Reauthenticated), result.Reauthenticated)
+	if len(result.Missing) > 0 {
+		fmt.Printf("⚠️  未重新认证: %d %v（需手动更新这些客户端的 password）\n", len(result.Missing), result.Missing)
+	}
+	if result.Persisted {
+		fmt.Println("✅ 新密钥已写入服务端配置文件")
 	} else {
-		level = slog.LevelInfo
+		fmt.Println("⚠️  新密钥仅在服务端内存中生效，重启前请更新服务端配置")
+	}
+	if result.Warning != "" {
+		fmt.Printf("⚠️  %s\n", result.Warning)
 	}
+	fmt.Println("请将所有客户端配置中的 password 更新为新密钥，daemon 重启后才会读取配置文件")
+	return nil
+}
+
+// setupLogger 按配置初始化日志
+func setupLogger(cfg config.LoggingConfig, debug bool) (*logging.Logger, error) {
+	return logging.Setup(clientLoggingConfig(cfg, debug))
+}
+
+// structuredOutputLogging --output json、--monitor 或 --out - 时 stdout 专用于输出结果，原本输出到 stdout 的日志改为 stderr
+func structuredOutputLogging(cfg config.LoggingConfig, opts *CliOptions) config.LoggingConfig {
+	if (opts.Output == outputJSON || opts.Monitor || opts.PrintConfig || opts.Out == outStdout || (opts.InstallService && opts.DryRun)) && (cfg.Output == "" || cfg.Output == "stdout") {
+		cfg.Output = "stderr"
+	}
+	return cfg
+}
 
-	opts := &slog.HandlerOptions{Level: level}
-	var handler slog.Handler
+// clientLoggingConfig 合并调试开关与日志配置
+// 调试模式固定为 debug 级别；未指定格式时调试模式使用文本日志，否则使用 JSON 日志
+func clientLoggingConfig(cfg config.LoggingConfig, debug bool) config.LoggingConfig {
 	if debug {
-		// 调试模式使用文本日志
-		handler = slog.NewTextHandler(os.Stdout, opts)
-	} else {
-		// 生产模式使用 JSON 日志
-		handler = slog.NewJSONHandler(os.Stdout, opts)
+		cfg.Level = "debug"
+	}
+	if cfg.Format == "" {
+		if debug {
+			cfg.Format = logging.FormatText
+		} else {
+			cfg.Format = logging.FormatJSON
+		}
 	}
-	slog.SetDefault(slog.New(handler))
+	return cfg
 }
 
 // validateArgs 验证参数
@@ -522,10 +1394,89 @@ func validateArgs(opts *CliOptions) error {
 		return fmt.Errorf("-4 和 -6 选项不能同时使用")
 	}
 
-	// 检查操作参数冲突：--status 和 --deploy 互斥
+	switch opts.Output {
+	case "", outputText, outputJSON:
+	default:
+		return fmt.Errorf("不支持的输出格式 %q，可选 text 或 json", opts.Output)
+	}
+
+	if opts.CheckCRL && !opts.Status {
+		return fmt.Errorf("--check-crl 只能与 --status 同时使用")
+	}
+	filter := statusFilterFromOptions(opts)
+	if (filter.active() || filter.Sort != "") && !opts.Status {
+		return fmt.Errorf("--domain-filter、--expiring-within、--only-problems、--sort 与 --client-filter 只能与 --status 同时使用")
+	}
+	if err := filter.validate(); err != nil {
+		return err
+	}
+
+	// 检查操作参数冲突：--status、--deploy、--check 和 --reload-only 互斥
 	if opts.Status && opts.Deploy {
 		return fmt.Errorf("不能同时指定 --status 和 --deploy")
 	}
+	if opts.Check && (opts.Status || opts.Deploy) {
+		return fmt.Errorf("--check 不能与 --status 或 --deploy 同时使用")
+	}
+	if opts.ReloadOnly && (opts.Status || opts.Deploy || opts.Check) {
+		return fmt.Errorf("--reload-only 不能与 --status、--deploy 或 --check 同时使用")
+	}
+	if opts.VerifyWorkspace && (opts.Status || opts.Deploy || opts.Check || opts.ReloadOnly) {
+		return fmt.Errorf("--verify-workspace 不能与 --status、--deploy、--check 或 --reload-only 同时使用")
+	}
+	if opts.Monitor && (opts.Status || opts.Deploy || opts.Check || opts.ReloadOnly || opts.VerifyWorkspace) {
+		return fmt.Errorf("--monitor 不能与 --status、--deploy、--check、--reload-only 或 --verify-workspace 同时使用")
+	}
+	if opts.List && (opts.Status || opts.Deploy || opts.Check || opts.ReloadOnly || opts.VerifyWorkspace || opts.Monitor) {
+		return fmt.Errorf("--list 不能与 --status、--deploy、--check、--reload-only、--verify-workspace 或 --monitor 同时使用")
+	}
+	if opts.Get != "" && (opts.Status || opts.Deploy || opts.Check || opts.ReloadOnly || opts.VerifyWorkspace || opts.Monitor || opts.List) {
+		return fmt.Errorf("--get 不能与 --status、--deploy、--check、--reload-only、--verify-workspace、--monitor 或 --list 同时使用")
+	}
+	if err := validateGetArgs(opts); err != nil {
+		return err
+	}
+	if opts.Export && (opts.Status || opts.Deploy || opts.Check || opts.ReloadOnly || opts.VerifyWorkspace || opts.Monitor || opts.List || opts.Get != "") {
+		return fmt.Errorf("--export 不能与 --status、--deploy、--check、--reload-only、--verify-workspace、--monitor、--list 或 --get 同时使用")
+	}
+	if err := validateExportArgs(opts); err != nil {
+		return err
+	}
+	if opts.Remove && (opts.Status || opts.Deploy || opts.Check || opts.ReloadOnly || opts.VerifyWorkspace || opts.Monitor || opts.List || opts.Get != "" || opts.Export) {
+		return fmt.Errorf("--remove 不能与 --status、--deploy、--check、--reload-only、--verify-workspace、--monitor、--list 或 --get 同时使用")
+	}
+	if err := validateRemoveArgs(opts); err != nil {
+		return err
+	}
+	if opts.InstallService && (opts.Status || opts.Deploy || opts.Check || opts.ReloadOnly || opts.VerifyWorkspace || opts.Monitor || opts.List || opts.Get != "" || opts.Remove || opts.Daemon || opts.ValidateConfig) {
+		return fmt.Errorf("--install-service 不能与其他操作模式同时使用")
+	}
+	if !opts.InstallService && (opts.ServiceUser != "" || opts.ServiceGroup != "" || opts.ServiceEnable || opts.InitSystem != "") {
+		return fmt.Errorf("--service-user、--service-group、--enable 与 --init-system 只能与 --install-service 同时使用")
+	}
+	if opts.ValidateConfig && (opts.Status || opts.Deploy || opts.Check || opts.ReloadOnly || opts.VerifyWorkspace || opts.Monitor || opts.List || opts.Get != "" || opts.Remove) {
+		return fmt.Errorf("--validate-config 不能与其他操作模式同时使用")
+	}
+	if opts.Resync && (opts.Status || opts.Deploy || opts.Check || opts.ReloadOnly || opts.VerifyWorkspace || opts.Monitor || opts.List || opts.Get != "" || opts.Remove || opts.Daemon || opts.ValidateConfig || opts.InstallService) {
+		return fmt.Errorf("--resync 不能与其他操作模式同时使用")
+	}
+	if opts.Concurrency < 0 {
+		return fmt.Errorf("--concurrency 不能为负数")
+	}
+	if opts.SinceDeploy != "" {
+		if !opts.Deploy {
+			return fmt.Errorf("--since-deploy 只能与 --deploy 同时使用")
+		}
+		if _, err := parseSinceDeploy(opts.SinceDeploy); err != nil {
+			return err
+		}
+	}
+	if opts.Retries < 0 {
+		return fmt.Errorf("--retries 不能为负数")
+	}
+	if opts.Monitor && (opts.CritDays < 0 || opts.WarnDays < opts.CritDays) {
+		return fmt.Errorf("--warn-days (%d) 必须不小于 --crit-days (%d)，且均不能为负数", opts.WarnDays, opts.CritDays)
+	}
 
 	return nil
 }
@@ -542,6 +1493,7 @@ func loadConfiguration(opts *CliOptions) (*config.ClientConfig, error) {
 	}
 
 	// 先加载基础配置，再由命令行做最终覆盖和校验
+	config.SetLaxConfig(opts.LaxConfig)
 	cfg, err := config.LoadClientConfigUnvalidated(configFile)
 	if err != nil {
 		return nil, fmt.Errorf("加载配置源失败: %w", err)
@@ -557,9 +1509,24 @@ func loadConfiguration(opts *CliOptions) (*config.ClientConfig, error) {
 	if opts.Password != "" {
 		cfg.Password = opts.Password
 	}
+	if opts.ClientID != "" {
+		cfg.ClientID = opts.ClientID
+	}
 	if opts.Debug {
 		cfg.Debug = opts.Debug
 	}
+	if opts.ConnectTimeout != 0 {
+		cfg.Timeouts.Connect = opts.ConnectTimeout
+	}
+	if opts.RequestTimeout != 0 {
+		cfg.Timeouts.Request = opts.RequestTimeout
+	}
+	if opts.ReloadTimeout != 0 {
+		cfg.Timeouts.Reload = opts.ReloadTimeout
+	}
+	if opts.ShutdownTimeout != 0 {
+		cfg.Timeouts.Shutdown = opts.ShutdownTimeout
+	}
 	if opts.IPMode4 {
 		cfg.IPMode = 4
 	} else if opts.IPMode6 {
@@ -571,13 +1538,58 @@ func loadConfiguration(opts *CliOptions) (*config.ClientConfig, error) {
 		cfg.DefaultReloadCmd = opts.ReloadCmd
 	}
 
-	if err := config.ValidateClientConfig(cfg); err != nil {
+	// --validate-config 自行校验并列出全部问题，--print-config 原样输出
+	if opts.ValidateConfig || opts.PrintConfig {
+		return cfg, nil
+	}
+
+	// --reload-only 不连接服务器，无需校验密码
+	if opts.ReloadOnly {
+		if err := config.ValidateSites(cfg.Sites); err != nil {
+			return nil, err
+		}
+		if err := config.ValidateReloadAllowlist(cfg); err != nil {
+			return nil, err
+		}
+		return cfg, nil
+	}
+
+	// --verify-workspace 只读取本地工作目录，无需校验密码
+	if opts.VerifyWorkspace {
+		return cfg, nil
+	}
+
+	// --monitor 只读取本地已部署的证书，无需校验密码
+	if opts.Monitor {
+		if err := config.ValidateSites(cfg.Sites); err != nil {
+			return nil, err
+		}
+		return cfg, nil
+	}
+
+	if err := errors.Join(config.ValidateClientConfig(cfg)...); err != nil {
 		return nil, err
 	}
 
 	return cfg, nil
 }
 
+// runPrintConfig 以 YAML 输出合并后的有效配置，密码与 PKCS#12 密码已隐藏
+func runPrintConfig(w io.Writer, cfg *config.ClientConfig) error {
+	return config.PrintConfig(w, cfg.Redacted())
+}
+
+// runGenClientConfig 按 --server 与 --domains 输出客户端配置文件模板，每个域名一个站点，部署路径按本机 Web 服务器推测
+func runGenClientConfig(w io.Writer, opts *CliOptions) {
+	var sites []config.SiteDeployConfig
+	for _, d := range strings.Split(opts.DomainsStr, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			sites = append(sites, config.GuessSiteDeployConfig(d))
+		}
+	}
+	fmt.Fprint(w, config.GenerateClientConfig(sites, "", opts.Server))
+}
+
 // usage 显示帮助信息
 func usage() {
 	fmt.Fprintf(os.Stderr, `acmeDeliver 客户端 v%s
@@ -586,14 +1598,34 @@ func usage() {
   acmedeliver-client [选项]
 
 操作模式:
-  --status              查询服务器运行状态（在线客户端 + 证书状态）
+  --status              查询服务器运行状态（在线客户端 + 证书状态），可配合 --check-crl 检查吊销状态，
+                        --domain-filter/--expiring-within/--only-problems/--sort/--client-filter 过滤与排序
+  --list                列出服务端可用的域名（域名、更新时间、文件）
   --deploy              检查更新并部署证书
+  --check               仅检查是否有可用更新（退出码 0=最新，1=有更新，2=出错）
+  --get <文件> --out <目录|->  下载单个域名的 cert/key/fullchain/all 到目录或标准输出（不使用站点配置）
+  --export --output-dir <目录>  导出服务端全部证书到目录（每个域名一个子目录，--format pkcs12 生成 .p12）
+  --reload-only         仅执行站点配置中的重载命令（不下载证书）
+  --verify-workspace    校验工作目录中已保存证书的完整性（不连接服务器）
+  --validate-config     校验配置并列出全部问题（不连接服务器）
+  --print-config        输出合并配置文件、环境变量与命令行后的有效配置（YAML，隐藏密码）
+  --remove              下线 -d 指定的域名：删除工作目录，--purge-deployed 时删除已部署文件，随后执行重载命令
+  --monitor             监控插件：离线检查已部署证书的剩余天数（配合 --warn-days/--crit-days）
   --daemon              以守护进程模式运行
+  --force-domain <域名> 请求服务端立即向本机 daemon 推送指定域名
+  --resync              按 daemon 配置强制同步订阅的全部证书（重写文件并执行重载命令）后退出
+  --rotate-key          轮换认证密钥，在线 daemon 自动切换到新密钥
+  --install-service     将 daemon 安装为 systemd 服务（非 systemd 系统生成 OpenRC 脚本），配合 --dry-run 只输出
+  --completion <shell>  输出 bash、zsh 或 fish 补全脚本（补全参数与配置文件中的域名）
+  --gen-client-config   按 --server 与 --domains 输出客户端配置文件模板
 
 选项:
 `, VERSION)
 	flag.PrintDefaults()
 	fmt.Fprintf(os.Stderr, `
+退出码（--check / --monitor 除外）:
+  0 成功  1 其他错误  2 参数错误  3 连接或认证失败  4 部分域名部署失败  5 所有域名部署失败  6 配置无效
+
 部署说明:
   使用 --deploy 时，将根据配置文件中指定的路径部署证书：
   - cert_path:      证书路径
@@ -605,17 +1637,75 @@ func usage() {
   # 查询服务器运行状态（在线客户端 + 证书状态）
   acmedeliver-client -s http://server:9090 -k your-password --status
 
-  # 查询服务器运行状态
-  acmedeliver-client -s http://server:9090 -k your-password --status
+  # 只查看 30 天内过期的 example.com 子域名，按过期时间排序
+  acmedeliver-client -c config.yaml --status --domain-filter "*.example.com" --expiring-within 30 --sort expiry
+
+  # 只列出有问题（过期、吊销、文件缺失）的域名，输出 JSON
+  acmedeliver-client -c config.yaml --status --only-problems --output json
+
+  # 列出服务端可用的域名
+  acmedeliver-client -c config.yaml --list
+  acmedeliver-client -c config.yaml --list --output json | jq -r '.[].domain'
+
+  # 备份或迁移：导出服务端全部证书（PEM 或 PKCS#12）
+  acmedeliver-client -c config.yaml --export --output-dir /backup/certs-$(date +%%F)
+  acmedeliver-client -c config.yaml --export --output-dir /backup/p12 --format pkcs12 --export-password-file /etc/acmedeliver/p12.pass
 
   # 检查更新并部署
   acmedeliver-client -c config.yaml -d example.com --deploy
 
-  # 批量处理多个域名
-  acmedeliver-client -c config.yaml -d "example.com,example.org" --deploy
+  # 批量处理多个域名（默认同时处理 4 个，可用 --concurrency 调整）
+  acmedeliver-client -c config.yaml -d "example.com,example.org" --deploy --concurrency 8
+
+  # CI/CD 中频繁执行时，本地证书 7 天内保存过的域名不请求服务端
+  acmedeliver-client -c config.yaml --deploy --since-deploy 7d
+
+  # cron 中部署，网络抖动时最多尝试 5 次（默认 3 次）
+  acmedeliver-client -c config.yaml --deploy --retries 5
+
+  # 监控：仅检查配置的域名是否有可用更新
+  acmedeliver-client -c config.yaml --check
+
+  # 以 JSON 输出部署结果，便于脚本处理（日志输出到 stderr）
+  acmedeliver-client -c config.yaml --deploy --output json | jq '.[] | select(.action == "failed")'
+
+  # Nagios/Zabbix 监控：检查本机已部署证书，14 天内过期告警，7 天内严重
+  acmedeliver-client -c config.yaml --monitor --warn-days 14 --crit-days 7
+
+  # 将证书链输出到标准输出，交给其他工具处理
+  acmedeliver-client -c config.yaml -d example.com --get fullchain --out - | openssl x509 -noout -enddate
+
+  # 不使用站点配置，直接将全部证书文件写入目录（私钥权限 0600）
+  acmedeliver-client -c config.yaml -d example.com --get all --out /etc/ssl/example.com
+
+  # 手动修改证书后重新执行重载命令（可配合 --dry-run 预览）
+  acmedeliver-client -c config.yaml --reload-only
+
+  # 站点下线：预览后删除工作目录与已部署的证书文件，并执行站点的重载命令
+  acmedeliver-client -c config.yaml -d example.com --remove --purge-deployed --dry-run
+  acmedeliver-client -c config.yaml -d example.com --remove --purge-deployed
 
   # 以守护进程模式运行
   acmedeliver-client -c config.yaml --daemon
+
+  # 生成客户端配置文件，检查部署路径并取消 reloadcmd 注释后使用
+  acmedeliver-client --gen-client-config --server https://acme.example.com:9443 --domains "a.com,b.com" > config.yaml
+
+  # 启用 bash 补全（zsh 使用 --completion zsh，fish 使用 --completion fish | source）
+  source <(acmedeliver-client --completion bash)
+
+  # 预览并安装 daemon 的系统服务，设置开机启动
+  acmedeliver-client -c /etc/acmedeliver/client.yaml --install-service --dry-run
+  sudo acmedeliver-client -c /etc/acmedeliver/client.yaml --install-service --enable
+
+  # 让服务端立即向本机 daemon 重新推送某个域名
+  acmedeliver-client -c config.yaml --force-domain example.com
+
+  # 推送丢失或部署文件被误删后，强制重新同步 daemon 订阅的全部证书并执行重载
+  acmedeliver-client -c config.yaml --resync
+
+  # 轮换认证密钥（新密钥由服务端生成，最长等待 120 秒）
+  acmedeliver-client -c config.yaml --rotate-key --rotate-window 120
 `)
 }
 
@@ -1,11 +1,30 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
+	gorilla "github.com/gorilla/websocket"
 	"github.com/stretchr/testify/require"
+
+	"github.com/Catker/acmeDeliver/pkg/client"
+	"github.com/Catker/acmeDeliver/pkg/config"
+	"github.com/Catker/acmeDeliver/pkg/testutil"
+	"github.com/Catker/acmeDeliver/pkg/testutil/wstest"
+	"github.com/Catker/acmeDeliver/pkg/websocket"
+	"github.com/Catker/acmeDeliver/pkg/workspace"
 )
 
 func writeTempConfig(t *testing.T, content string) string {
@@ -31,6 +50,42 @@ func TestLoadConfigurationAllowsCLIOnlyPassword(t *testing.T) {
 	require.Equal(t, "/tmp/acme", cfg.WorkDir)
 }
 
+func TestLoadConfigurationRequestTimeout(t *testing.T) {
+	oldConfigFile := configFile
+	configFile = writeTempConfig(t, "client:\n  password: \"p\"\n  request_timeout: 60\n")
+	t.Cleanup(func() { configFile = oldConfigFile })
+
+	cfg, err := loadConfiguration(&CliOptions{})
+	require.NoError(t, err)
+	require.Equal(t, 60, cfg.Timeouts.Request)
+
+	cfg, err = loadConfiguration(&CliOptions{RequestTimeout: 5})
+	require.NoError(t, err)
+	require.Equal(t, 5, cfg.Timeouts.Request, "--request-timeout 应覆盖配置文件")
+
+	_, err = loadConfiguration(&CliOptions{RequestTimeout: -1})
+	require.Error(t, err)
+}
+
+func TestLoadConfigurationTimeouts(t *testing.T) {
+	oldConfigFile := configFile
+	configFile = writeTempConfig(t, "client:\n  password: \"p\"\n  timeouts:\n    connect: 20\n    reload: 90\n")
+	t.Cleanup(func() { configFile = oldConfigFile })
+
+	cfg, err := loadConfiguration(&CliOptions{})
+	require.NoError(t, err)
+	require.Equal(t, config.TimeoutsConfig{Connect: 20, Reload: 90}, cfg.Timeouts)
+
+	cfg, err = loadConfiguration(&CliOptions{ConnectTimeout: 30, RequestTimeout: 40, ReloadTimeout: 120, ShutdownTimeout: 60})
+	require.NoError(t, err)
+	require.Equal(t, config.TimeoutsConfig{Connect: 30, Request: 40, Reload: 120, Shutdown: 60}, cfg.Timeouts, "命令行参数应覆盖配置文件")
+
+	for _, opts := range []*CliOptions{{ConnectTimeout: -1}, {ReloadTimeout: -1}, {ShutdownTimeout: -1}} {
+		_, err = loadConfiguration(opts)
+		require.Error(t, err)
+	}
+}
+
 func TestLoadConfigurationRejectsBrokenConfigFile(t *testing.T) {
 	oldConfigFile := configFile
 	configFile = writeTempConfig(t, "client:\n  password: [broken")
@@ -58,3 +113,907 @@ client:
 	require.Equal(t, "cli-password", cfg.Password)
 	require.Equal(t, "/tmp/file-workdir", cfg.WorkDir)
 }
+
+func TestAdminCommandsRequireAdminToken(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.ClientConfig{Server: server.URL, Password: "test-password"}
+	if err := runForceDomain(cfg, "example.com"); !errors.Is(err, errAdminTokenRequired) {
+		t.Errorf("runForceDomain() error = %v, want errAdminTokenRequired", err)
+	}
+	if err := runRotateKey(cfg, &CliOptions{}); !errors.Is(err, errAdminTokenRequired) {
+		t.Errorf("runRotateKey() error = %v, want errAdminTokenRequired", err)
+	}
+	if requests != 0 {
+		t.Errorf("未配置 admin_token 时发送了 %d 次请求, want 0", requests)
+	}
+}
+
+func TestPrintConfig(t *testing.T) {
+	oldConfigFile := configFile
+	configFile = writeTempConfig(t, `
+client:
+  server: "http://file-config:1111"
+  password: "file-password"
+  workdir: "/tmp/file-workdir"
+  sites:
+    - domain: "example.com"
+      pkcs12_path: "/etc/ssl/example.com.p12"
+      pkcs12_password: "p12-password"
+`)
+	t.Cleanup(func() { configFile = oldConfigFile })
+	t.Setenv("ACMEDELIVER_SERVER", "http://env-server:2222")
+	t.Setenv("ACMEDELIVER_WORKDIR", "/tmp/env-workdir")
+
+	// --print-config 不校验配置，命令行 > 环境变量 > 配置文件
+	cfg, err := loadConfiguration(&CliOptions{PrintConfig: true, Server: "http://cli-server:3333"})
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, runPrintConfig(&buf, cfg))
+
+	out := buf.String()
+	require.Contains(t, out, "server: http://cli-server:3333")
+	require.Contains(t, out, "workdir: /tmp/env-workdir", "环境变量应覆盖配置文件")
+	require.Contains(t, out, "password: '"+config.RedactedValue+"'")
+	require.NotContains(t, out, "file-password")
+	require.NotContains(t, out, "p12-password")
+	require.Equal(t, "file-password", cfg.Password, "输出配置不应修改原配置")
+}
+
+func TestResolveClientID(t *testing.T) {
+	hostnameOK := func() (string, error) { return "web-01", nil }
+	hostnameFail := func() (string, error) { return "", errors.New("no hostname") }
+	hostnameEmpty := func() (string, error) { return "", nil }
+
+	t.Run("configured ID wins over hostname", func(t *testing.T) {
+		require.Equal(t, "node-a", resolveClientID("node-a", hostnameOK))
+	})
+
+	t.Run("blank configured ID falls back to hostname", func(t *testing.T) {
+		require.Equal(t, "web-01", resolveClientID("  ", hostnameOK))
+	})
+
+	t.Run("hostname error falls back to UUID", func(t *testing.T) {
+		id := resolveClientID("", hostnameFail)
+		_, err := uuid.Parse(id)
+		require.NoError(t, err)
+	})
+
+	t.Run("empty hostname falls back to UUID", func(t *testing.T) {
+		id := resolveClientID("", hostnameEmpty)
+		_, err := uuid.Parse(id)
+		require.NoError(t, err)
+	})
+}
+
+func TestLoadConfigurationClientIDPriority(t *testing.T) {
+	oldConfigFile := configFile
+	configFile = writeTempConfig(t, `
+client:
+  password: "file-password"
+  client_id: "file-id"
+`)
+	t.Cleanup(func() { configFile = oldConfigFile })
+
+	cfg, err := loadConfiguration(&CliOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "file-id", cfg.ClientID)
+
+	cfg, err = loadConfiguration(&CliOptions{ClientID: "cli-id"})
+	require.NoError(t, err)
+	require.Equal(t, "cli-id", cfg.ClientID)
+}
+
+func TestCollectReloadCommands(t *testing.T) {
+	cfg := &config.ClientConfig{
+		DefaultReloadCmd: "systemctl reload default",
+		Sites: []config.SiteDeployConfig{
+			{Domain: "a.example.com", ReloadCmd: "systemctl reload nginx"},
+			{Domain: "b.example.com", ReloadCmd: "systemctl reload nginx"},
+			{Domain: "c.example.com", ReloadCmd: "systemctl reload haproxy"},
+			{Domain: "d.example.com"}, // 回退到全局默认命令
+			{Domain: "*.internal.example.com", ReloadCmd: "systemctl reload caddy"},
+		},
+	}
+
+	tests := []struct {
+		name string
+		opts *CliOptions
+		want map[string]bool
+	}{
+		{
+			name: "all sites deduped",
+			opts: &CliOptions{},
+			want: map[string]bool{
+				"systemctl reload nginx":   true,
+				"systemctl reload haproxy": true,
+				"systemctl reload default": true,
+				"systemctl reload caddy":   true,
+			},
+		},
+		{
+			name: "--reload-cmd overrides every site",
+			opts: &CliOptions{ReloadCmd: "systemctl reload apache2"},
+			want: map[string]bool{"systemctl reload apache2": true},
+		},
+		{
+			name: "-d limits to matching sites",
+			opts: &CliOptions{DomainsStr: "a.example.com, b.example.com,api.internal.example.com,unknown.com"},
+			want: map[string]bool{
+				"systemctl reload nginx": true,
+				"systemctl reload caddy": true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, collectReloadCommands(cfg, tt.opts))
+		})
+	}
+
+	t.Run("no default and no site command", func(t *testing.T) {
+		empty := &config.ClientConfig{Sites: []config.SiteDeployConfig{{Domain: "a.example.com"}}}
+		require.Empty(t, collectReloadCommands(empty, &CliOptions{}))
+	})
+}
+
+func TestExecuteReloadCommandsStreamsOutput(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("需要 sh")
+	}
+	commands := map[string]bool{"sh -c 'echo stopping\necho started'": true}
+
+	var out bytes.Buffer
+	executeReloadCommands(&out, commands, 0, false)
+	require.Equal(t, "stopping\nstarted\n", out.String())
+
+	out.Reset()
+	executeReloadCommands(&out, commands, 0, true)
+	require.Empty(t, out.String(), "dry-run 不应执行命令")
+
+	require.Equal(t, os.Stderr, reloadOutput(&CliOptions{Output: outputJSON}), "json 模式下 stdout 专用于输出结果")
+	require.Equal(t, os.Stdout, reloadOutput(&CliOptions{Output: outputText}))
+}
+
+func TestExecuteReloadCommandsTimeout(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("需要 sleep")
+	}
+
+	start := time.Now()
+	executeReloadCommands(io.Discard, map[string]bool{"sleep 5": true}, 200*time.Millisecond, false)
+	require.Less(t, time.Since(start), 3*time.Second, "应按传入的超时终止重载命令")
+}
+
+func TestRecordDeployHistory(t *testing.T) {
+	certPEM, _, err := testutil.GenerateSelfSignedCert("example.com", time.Hour)
+	require.NoError(t, err)
+
+	workDir := t.TempDir()
+	for _, domain := range []string{"a.example.com", "b.example.com", "c.example.com", "d.example.com"} {
+		require.NoError(t, workspace.NewWorkspace(workDir, domain).Ensure())
+	}
+	cfg := &config.ClientConfig{WorkDir: workDir}
+	results := []deployResult{
+		{Domain: "a.example.com", Action: actionDeployed, ReloadCmd: "reload-ok", certPEM: certPEM},
+		{Domain: "b.example.com", Action: actionDeployed, ReloadCmd: "reload-fail", certPEM: certPEM},
+		{Domain: "c.example.com", Action: actionDeployed, certPEM: certPEM},
+		{Domain: "d.example.com", Action: actionSkipped},
+	}
+	reloadErrs := map[string]error{"reload-ok": nil, "reload-fail": errors.New("exit status 1")}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	recordDeployHistory(cfg, results, reloadErrs, now)
+
+	reloadOK := func(domain string) *bool {
+		records, err := workspace.GetDeployHistory(workDir, domain, 0)
+		require.NoError(t, err)
+		require.Len(t, records, 1, domain)
+		require.True(t, records[0].DeployedAt.Equal(now))
+		require.Equal(t, workspace.DeployerLocal, records[0].Deployer)
+		return records[0].ReloadOK
+	}
+	require.True(t, *reloadOK("a.example.com"))
+	require.False(t, *reloadOK("b.example.com"))
+	require.Nil(t, reloadOK("c.example.com"), "未执行重载时不记录重载结果")
+
+	records, err := workspace.GetDeployHistory(workDir, "d.example.com", 0)
+	require.NoError(t, err)
+	require.Empty(t, records, "跳过的域名不应记录部署历史")
+
+	// --status 显示本机最近一次部署时间
+	report := newStatusReport("ws://server:9090", &websocket.StatusResponse{
+		Domains: []websocket.DomainStatus{{Domain: "a.example.com"}, {Domain: "d.example.com"}},
+	}, now)
+	report.fillLastDeployed(workDir)
+	require.Equal(t, now.Unix(), report.Domains[0].LastDeployed)
+	require.Zero(t, report.Domains[1].LastDeployed)
+}
+
+func TestLoadConfigurationReloadOnlySkipsPassword(t *testing.T) {
+	oldConfigFile := configFile
+	configFile = writeTempConfig(t, `
+client:
+  sites:
+    - domain: "example.com"
+      reloadcmd: "systemctl reload nginx"
+`)
+	t.Cleanup(func() { configFile = oldConfigFile })
+
+	_, err := loadConfiguration(&CliOptions{})
+	require.Error(t, err, "普通模式仍需要密码")
+
+	cfg, err := loadConfiguration(&CliOptions{ReloadOnly: true})
+	require.NoError(t, err)
+	require.Len(t, cfg.Sites, 1)
+
+	require.Error(t, validateArgs(&CliOptions{ReloadOnly: true, Deploy: true}))
+}
+
+func TestValidateArgsRetries(t *testing.T) {
+	require.NoError(t, validateArgs(&CliOptions{Deploy: true, Retries: defaultRetries}))
+	require.NoError(t, validateArgs(&CliOptions{Deploy: true, Retries: 0}), "0 视为不重试")
+	require.Error(t, validateArgs(&CliOptions{Deploy: true, Retries: -1}))
+}
+
+func TestValidateArgsResync(t *testing.T) {
+	require.NoError(t, validateArgs(&CliOptions{Resync: true}))
+	require.Error(t, validateArgs(&CliOptions{Resync: true, Deploy: true}))
+	require.Error(t, validateArgs(&CliOptions{Resync: true, Daemon: true}))
+}
+
+func TestDaemonSyncInterval(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.DaemonModeConfig
+		want time.Duration
+	}{
+		{"未设置使用默认 1 小时", config.DaemonModeConfig{}, time.Hour},
+		{"sync_interval 秒", config.DaemonModeConfig{SyncInterval: 600}, 10 * time.Minute},
+		{"sync_interval 负数禁用", config.DaemonModeConfig{SyncInterval: -1}, 0},
+		{"sync_interval_minutes", config.DaemonModeConfig{SyncIntervalMinutes: 15}, 15 * time.Minute},
+		{"sync_interval_minutes 优先", config.DaemonModeConfig{SyncInterval: 600, SyncIntervalMinutes: 5}, 5 * time.Minute},
+		{"sync_interval_minutes 优先于禁用", config.DaemonModeConfig{SyncInterval: -1, SyncIntervalMinutes: 5}, 5 * time.Minute},
+		{"sync_interval_minutes 负数禁用", config.DaemonModeConfig{SyncInterval: 600, SyncIntervalMinutes: -1}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, daemonSyncInterval(tt.cfg))
+		})
+	}
+}
+
+func TestClientLoggingConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   config.LoggingConfig
+		debug bool
+		want  config.LoggingConfig
+	}{
+		{"默认 JSON", config.LoggingConfig{}, false, config.LoggingConfig{Format: "json"}},
+		{"调试模式使用文本与 debug 级别", config.LoggingConfig{Level: "warn"}, true, config.LoggingConfig{Level: "debug", Format: "text"}},
+		{"保留配置的格式与输出", config.LoggingConfig{Level: "info", Format: "text", Output: "/var/log/acme.log", MaxSizeMB: 10}, false,
+			config.LoggingConfig{Level: "info", Format: "text", Output: "/var/log/acme.log", MaxSizeMB: 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, clientLoggingConfig(tt.cfg, tt.debug))
+		})
+	}
+}
+
+func TestNeedsDeploy(t *testing.T) {
+	tests := []struct {
+		name     string
+		local    int64
+		remote   int64
+		force    bool
+		expected bool
+	}{
+		{"本地未部署", 0, 1700000000, false, true},
+		{"服务端有更新", 1700000000, 1700000100, false, true},
+		{"已是最新", 1700000100, 1700000100, false, false},
+		{"本地更新（时钟回拨）", 1700000200, 1700000100, false, false},
+		{"服务端缺少时间戳", 1700000000, 0, false, true},
+		{"强制部署", 1700000100, 1700000100, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, needsDeploy(tt.local, tt.remote, tt.force))
+		})
+	}
+}
+
+func TestCheckUpdates(t *testing.T) {
+	workDir := t.TempDir()
+	for domain, ts := range map[string]string{"current.com": "1700000100", "stale.com": "1700000000"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(workDir, domain), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(workDir, domain, "time.log"), []byte(ts), 0644))
+	}
+	server := []websocket.DomainStatus{
+		{Domain: "current.com", LastUpdate: 1700000100},
+		{Domain: "stale.com", LastUpdate: 1700000100},
+		{Domain: "new.com", LastUpdate: 1700000100},
+	}
+
+	tests := []struct {
+		name     string
+		domains  []string
+		wantCode int
+	}{
+		{"全部最新", []string{"current.com"}, checkExitUpToDate},
+		{"存在更新", []string{"current.com", "stale.com"}, checkExitUpdates},
+		{"本地未部署", []string{"new.com"}, checkExitUpdates},
+		{"服务端不存在", []string{"stale.com", "missing.com"}, checkExitError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := checkUpdates(server, tt.domains, workDir)
+			require.Len(t, results, len(tt.domains))
+			require.Equal(t, tt.wantCode, checkExitCode(results))
+		})
+	}
+
+	// 检查不应写入任何文件
+	_, err := os.Stat(filepath.Join(workDir, "new.com"))
+	require.True(t, os.IsNotExist(err))
+}
+
+// generateKeyPair 生成自签名证书及其私钥（PEM）
+func generateKeyPair(t *testing.T) (certPEM, keyPEM []byte) {
+	t.Helper()
+	certPEM, keyPEM, err := testutil.GenerateSelfSignedCert("example.com", time.Hour)
+	require.NoError(t, err)
+	return certPEM, keyPEM
+}
+
+func TestHandleDeployBatchWithoutFullchain(t *testing.T) {
+	// 服务端只提供证书与私钥的域名照常部署
+	const domain = "example.com"
+	server := wstest.NewMockServer(t)
+	certPEM, keyPEM := generateKeyPair(t)
+	server.WriteFile(t, domain, "cert.pem", certPEM)
+	server.WriteFile(t, domain, "key.pem", keyPEM)
+	server.WriteFile(t, domain, "time.log", []byte("1700000000"))
+
+	deployDir := t.TempDir()
+	cfg := &config.ClientConfig{
+		WorkDir: t.TempDir(),
+		Sites: []config.SiteDeployConfig{{
+			Domain:   domain,
+			CertPath: filepath.Join(deployDir, "cert.pem"),
+			KeyPath:  filepath.Join(deployDir, "key.pem"),
+		}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	wsClient := client.NewWSClient(server.URL, "test-password", nil)
+	require.NoError(t, wsClient.Connect(ctx))
+	defer wsClient.Close()
+
+	result, err := handleDeployBatch(ctx, wsClient, cfg, domain, &CliOptions{})
+	require.NoError(t, err)
+	require.Equal(t, actionDeployed, result.Action)
+	data, err := os.ReadFile(filepath.Join(deployDir, "key.pem"))
+	require.NoError(t, err)
+	require.Equal(t, keyPEM, data)
+}
+
+func TestHandleDeployBatchSkipsUnchanged(t *testing.T) {
+	const domain = "example.com"
+	server := wstest.NewMockServer(t)
+	publish := func(cert, key []byte, timestamp string) {
+		files := map[string][]byte{"cert.pem": cert, "key.pem": key, "fullchain.pem": cert, "time.log": []byte(timestamp)}
+		for name, content := range files {
+			server.WriteFile(t, domain, name, content)
+		}
+	}
+	certV1, keyV1 := generateKeyPair(t)
+	certV2, keyV2 := generateKeyPair(t)
+	publish(certV1, keyV1, "1700000000")
+
+	deployDir := t.TempDir()
+	certPath := filepath.Join(deployDir, "cert.pem")
+	cfg := &config.ClientConfig{
+		WorkDir: t.TempDir(),
+		Sites:   []config.SiteDeployConfig{{Domain: domain, CertPath: certPath, ReloadCmd: "true"}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	wsClient := client.NewWSClient(server.URL, "test-password", nil)
+	require.NoError(t, wsClient.Connect(ctx))
+	defer wsClient.Close()
+
+	deploy := func(force bool) string {
+		t.Helper()
+		result, err := handleDeployBatch(ctx, wsClient, cfg, domain, &CliOptions{Force: force})
+		require.NoError(t, err)
+		if result.ReloadCmd == "" {
+			require.Contains(t, []deployAction{actionSkipped, actionUnchanged}, result.Action)
+		} else {
+			require.Equal(t, actionDeployed, result.Action)
+		}
+		return result.ReloadCmd
+	}
+	deployed := func() string {
+		t.Helper()
+		data, err := os.ReadFile(certPath)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	// 首次部署并记录时间戳
+	require.Equal(t, "true", deploy(false))
+	require.Equal(t, string(certV1), deployed())
+	require.Equal(t, int64(1700000000), workspace.GetDomainTimestamp(cfg.WorkDir, domain))
+
+	// 证书未更新：跳过下载、部署与 reload
+	require.NoError(t, os.WriteFile(certPath, []byte("local-edit"), 0644))
+	require.Empty(t, deploy(false))
+	require.Equal(t, "local-edit", deployed())
+
+	// -f 强制重新部署，部署文件内容未变化时同样执行 reload
+	require.Equal(t, "true", deploy(true))
+	require.Equal(t, string(certV1), deployed())
+	require.Equal(t, "true", deploy(true))
+
+	// 时间戳未变但工作目录文件与服务端不一致：重新下载并部署
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.WorkDir, domain, "cert.pem"), certV2, 0644))
+	require.NoError(t, os.WriteFile(certPath, []byte("local-edit"), 0644))
+	require.Equal(t, "true", deploy(false))
+	require.Equal(t, string(certV1), deployed())
+	workCert, err := os.ReadFile(filepath.Join(cfg.WorkDir, domain, "cert.pem"))
+	require.NoError(t, err)
+	require.Equal(t, string(certV1), string(workCert))
+
+	// 恢复一致后再次跳过
+	require.Empty(t, deploy(false))
+
+	// 服务端时间戳更新但证书内容相同：写入部署文件，跳过 reload
+	publish(certV1, keyV1, "1700000050")
+	result, err := handleDeployBatch(ctx, wsClient, cfg, domain, &CliOptions{})
+	require.NoError(t, err)
+	require.Equal(t, actionUnchanged, result.Action)
+	require.Empty(t, result.ReloadCmd)
+	require.Equal(t, int64(1700000050), workspace.GetDomainTimestamp(cfg.WorkDir, domain))
+
+	// 服务端更新后正常部署
+	publish(certV2, keyV2, "1700000100")
+	require.Equal(t, "true", deploy(false))
+	require.Equal(t, string(certV2), deployed())
+	require.Equal(t, int64(1700000100), workspace.GetDomainTimestamp(cfg.WorkDir, domain))
+}
+
+func TestGenClientConfigMode(t *testing.T) {
+	var buf bytes.Buffer
+	runGenClientConfig(&buf, &CliOptions{Server: "https://acme.example.com", DomainsStr: "a.com, b.com,"})
+
+	path := writeTempConfig(t, buf.String())
+	cfg, err := config.LoadClientConfig(path)
+	require.NoError(t, err, buf.String())
+	require.Equal(t, "https://acme.example.com", cfg.Server)
+	require.Equal(t, []string{"a.com", "b.com"}, cfg.Subscribe)
+	require.Len(t, cfg.Sites, 2)
+	require.Equal(t, "b.com", cfg.Sites[1].Domain)
+}
+
+func TestVerifyWorkspaceMode(t *testing.T) {
+	oldConfigFile := configFile
+	workDir := t.TempDir()
+	configFile = writeTempConfig(t, "client:\n  workdir: \""+workDir+"\"\n")
+	t.Cleanup(func() { configFile = oldConfigFile })
+
+	cfg, err := loadConfiguration(&CliOptions{VerifyWorkspace: true})
+	require.NoError(t, err, "校验工作目录无需密码")
+	require.True(t, runVerifyWorkspace(cfg), "空工作目录视为通过")
+
+	require.NoError(t, os.MkdirAll(filepath.Join(workDir, "broken.com"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "broken.com", "cert.pem"), []byte("x"), 0644))
+	require.False(t, runVerifyWorkspace(cfg))
+
+	require.Error(t, validateArgs(&CliOptions{VerifyWorkspace: true, Deploy: true}))
+}
+
+func TestValidateConfigMode(t *testing.T) {
+	oldConfigFile := configFile
+	configFile = writeTempConfig(t, "client:\n  server: \"ftp://acme.example.com\"\n  workdir: \""+t.TempDir()+"\"\n  sites:\n    - domain: \"example.com\"\n      cert_path: \"cert.pem\"\n")
+	t.Cleanup(func() { configFile = oldConfigFile })
+
+	cfg, err := loadConfiguration(&CliOptions{ValidateConfig: true})
+	require.NoError(t, err, "--validate-config 自行列出问题，加载阶段不应报错")
+
+	var out bytes.Buffer
+	require.False(t, runValidateConfig(&out, cfg, outputText))
+	require.Contains(t, out.String(), "未配置密码")
+	require.Contains(t, out.String(), "不支持的协议")
+	require.Contains(t, out.String(), "sites[0].cert_path")
+	require.Contains(t, out.String(), "共 3 个问题")
+
+	out.Reset()
+	require.False(t, runValidateConfig(&out, cfg, outputJSON))
+	require.Contains(t, out.String(), `"field": "sites[0].cert_path"`)
+
+	cfg.Password = "secret"
+	cfg.Server = "https://acme.example.com"
+	cfg.Sites[0].CertPath = "/etc/ssl/cert.pem"
+	out.Reset()
+	require.True(t, runValidateConfig(&out, cfg, outputText))
+	require.Contains(t, out.String(), "配置有效")
+
+	require.Error(t, validateArgs(&CliOptions{ValidateConfig: true, Deploy: true}))
+}
+
+func TestInstallServiceMode(t *testing.T) {
+	oldConfigFile := configFile
+	configFile = writeTempConfig(t, "client:\n  password: \"secret\"\n")
+	t.Cleanup(func() { configFile = oldConfigFile })
+
+	var out bytes.Buffer
+	opts := &CliOptions{InstallService: true, DryRun: true, InitSystem: "systemd", ServiceUser: "acme"}
+	require.NoError(t, runInstallService(&out, opts))
+	require.Contains(t, out.String(), " -c "+configFile+" --daemon\n")
+	require.Contains(t, out.String(), "\nUser=acme\n")
+	require.Contains(t, out.String(), "\nProtectSystem=true\n", "客户端需要向 /etc 部署证书")
+	require.NotContains(t, out.String(), "PrivateTmp")
+
+	configFile = ""
+	require.Error(t, runInstallService(&out, opts), "未指定配置文件时无法生成 ExecStart")
+
+	require.NoError(t, validateArgs(&CliOptions{InstallService: true, ServiceEnable: true, InitSystem: "openrc"}))
+	require.Error(t, validateArgs(&CliOptions{InstallService: true, Daemon: true}))
+	require.Error(t, validateArgs(&CliOptions{Daemon: true, ServiceUser: "acme"}), "--service-user 需配合 --install-service")
+}
+
+// newSlowCertServer 启动只处理认证与证书请求的桩服务端
+// 每个证书请求在独立协程中延迟 delay 后响应，模拟网络往返；不在 certs 中的域名返回错误
+func newSlowCertServer(t *testing.T, delay time.Duration, certs map[string][]byte) *httptest.Server {
+	t.Helper()
+	upgrader := gorilla.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var mu sync.Mutex
+		reply := func(requestID, msgType string, data any) {
+			msg, _ := websocket.NewMessage(msgType, data)
+			msg.RequestID = requestID
+			mu.Lock()
+			defer mu.Unlock()
+			conn.WriteJSON(msg)
+		}
+		for {
+			var msg websocket.Message
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			switch msg.Type {
+			case websocket.MsgTypeAuth:
+				reply(msg.RequestID, websocket.MsgTypeAuthResult, &websocket.AuthResponse{Success: true})
+			case websocket.MsgTypeCertRequest:
+				var req websocket.CertRequest
+				msg.ParseData(&req)
+				go func(requestID string) {
+					time.Sleep(delay)
+					resp := &websocket.CertResponse{Domain: req.Domain, Error: "域名不存在"}
+					if cert, ok := certs[req.Domain]; ok {
+						resp = &websocket.CertResponse{Domain: req.Domain, Timestamp: 1700000000, Files: map[string][]byte{
+							"cert.pem": cert, "key.pem": certs[req.Domain+"/key"], "fullchain.pem": cert,
+						}}
+					}
+					reply(requestID, websocket.MsgTypeCertResponse, resp)
+				}(msg.RequestID)
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRunDeployConcurrent(t *testing.T) {
+	const delay = 100 * time.Millisecond
+	certs := make(map[string][]byte)
+	var domains []string
+	for _, d := range []string{"a.com", "b.com", "c.com", "d.com", "e.com", "f.com"} {
+		cert, key := generateKeyPair(t)
+		certs[d], certs[d+"/key"] = cert, key
+		domains = append(domains, d)
+	}
+	domains = append(domains, "missing.com")
+	server := newSlowCertServer(t, delay, certs)
+
+	deploy := func(concurrency int) ([]deployResult, time.Duration) {
+		t.Helper()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		wsClient := client.NewWSClient(server.URL, "test-password", nil)
+		require.NoError(t, wsClient.Connect(ctx))
+		defer wsClient.Close()
+
+		cfg := &config.ClientConfig{WorkDir: t.TempDir(), Domains: domains}
+		start := time.Now()
+		results, err := runDeploy(ctx, wsClient, cfg, &CliOptions{Concurrency: concurrency})
+		require.NoError(t, err)
+		return results, time.Since(start)
+	}
+
+	serialResults, serial := deploy(1)
+	results, parallel := deploy(4)
+	require.GreaterOrEqual(t, serial, time.Duration(len(domains))*delay)
+	require.Less(t, parallel, serial/2, "并发部署耗时 %v，串行 %v", parallel, serial)
+
+	// 结果顺序与域名顺序一致，且与串行结果相同
+	require.Equal(t, serialResults, results)
+	for i, r := range results {
+		require.Equal(t, domains[i], r.Domain)
+		if r.Domain == "missing.com" {
+			require.Equal(t, actionFailed, r.Action)
+			require.Contains(t, r.Error, "域名不存在")
+		} else {
+			require.Equal(t, actionDeployed, r.Action, r.Error)
+		}
+	}
+	succeeded, failed := countDeployResults(results)
+	require.Equal(t, 6, succeeded)
+	require.Equal(t, 1, failed)
+}
+
+// stubCertClient 桩证书客户端：connectErr 模拟连接或认证失败，certs 中没有的域名下载失败
+type stubCertClient struct {
+	connectErr error
+	certs      map[string]*client.CertificateFiles
+	closed     bool
+}
+
+func (s *stubCertClient) Connect(ctx context.Context) error { return s.connectErr }
+
+func (s *stubCertClient) Close() { s.closed = true }
+
+func (s *stubCertClient) DownloadCert(ctx context.Context, domain string, force bool) (*client.CertificateFiles, error) {
+	return s.DownloadCertSince(ctx, domain, 0, force)
+}
+
+func (s *stubCertClient) DownloadCertSince(ctx context.Context, domain string, since int64, force bool) (*client.CertificateFiles, error) {
+	certs, ok := s.certs[domain]
+	if !ok {
+		return nil, errors.New("域名不存在")
+	}
+	copied := *certs
+	return &copied, nil
+}
+
+func (s *stubCertClient) GetServerStatus(ctx context.Context) (*websocket.StatusResponse, error) {
+	return &websocket.StatusResponse{}, nil
+}
+
+func (s *stubCertClient) GetServerStatusWithCRL(ctx context.Context) (*websocket.StatusResponse, error) {
+	return s.GetServerStatus(ctx)
+}
+
+func (s *stubCertClient) ListDomains(ctx context.Context) ([]websocket.DomainEntry, error) {
+	entries := make([]websocket.DomainEntry, 0, len(s.certs))
+	for domain := range s.certs {
+		entries = append(entries, websocket.DomainEntry{Domain: domain})
+	}
+	return entries, nil
+}
+
+func TestRunCLIExitCodes(t *testing.T) {
+	cert, key := generateKeyPair(t)
+	certs := map[string]*client.CertificateFiles{
+		"a.com": {Cert: cert, Key: key, Fullchain: cert, Timestamp: 1700000000},
+		"b.com": {Cert: cert, Key: key, Fullchain: cert, Timestamp: 1700000000},
+	}
+
+	tests := []struct {
+		name       string
+		connectErr error
+		opts       CliOptions
+		want       exitCode
+	}{
+		{"认证失败", errors.New("认证失败: 签名无效"), CliOptions{Deploy: true, DomainsStr: "a.com"}, exitConnection},
+		{"未指定操作", nil, CliOptions{}, exitUsage},
+		{"没有要部署的域名", nil, CliOptions{Deploy: true}, exitUsage},
+		{"全部成功", nil, CliOptions{Deploy: true, DomainsStr: "a.com,b.com"}, exitOK},
+		{"部分失败", nil, CliOptions{Deploy: true, DomainsStr: "a.com,missing.com"}, exitPartialFailure},
+		{"全部失败", nil, CliOptions{Deploy: true, DomainsStr: "missing.com,other.com"}, exitAllFailed},
+		{"列出域名", nil, CliOptions{List: true}, exitOK},
+		{"查询状态", nil, CliOptions{Status: true}, exitOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &stubCertClient{connectErr: tt.connectErr, certs: certs}
+			cfg := &config.ClientConfig{WorkDir: t.TempDir()}
+
+			code, err := runCLI(context.Background(), io.Discard, stub, cfg, &tt.opts)
+			require.Equal(t, tt.want, code, "error = %v", err)
+			if tt.want == exitOK {
+				require.NoError(t, err)
+			}
+			if tt.connectErr == nil {
+				require.True(t, stub.closed, "连接成功后应关闭客户端")
+			}
+		})
+	}
+}
+
+func TestDeployExitCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		actions []deployAction
+		want    exitCode
+	}{
+		{"已部署与跳过", []deployAction{actionDeployed, actionSkipped}, exitOK},
+		{"跳过与失败", []deployAction{actionSkipped, actionFailed}, exitPartialFailure},
+		{"全部失败", []deployAction{actionFailed, actionFailed}, exitAllFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []deployResult
+			for _, action := range tt.actions {
+				results = append(results, deployResult{Action: action})
+			}
+			require.Equal(t, tt.want, deployExitCode(results))
+		})
+	}
+}
+
+func TestParseSinceDeploy(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"7d", 7 * 24 * time.Hour, false},
+		{"12h", 12 * time.Hour, false},
+		{"90m", 90 * time.Minute, false},
+		{"0d", 0, true},
+		{"-1h", 0, true},
+		{"d", 0, true},
+		{"1.5d", 0, true},
+		{"week", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseSinceDeploy(tt.in)
+		if tt.wantErr {
+			require.Error(t, err, tt.in)
+			continue
+		}
+		require.NoError(t, err, tt.in)
+		require.Equal(t, tt.want, got, tt.in)
+	}
+
+	require.NoError(t, validateArgs(&CliOptions{Deploy: true, SinceDeploy: "7d"}))
+	require.Error(t, validateArgs(&CliOptions{Status: true, SinceDeploy: "7d"}), "--since-deploy 需配合 --deploy")
+	require.Error(t, validateArgs(&CliOptions{Deploy: true, SinceDeploy: "7x"}))
+}
+
+func TestRunDeploySinceDeploy(t *testing.T) {
+	workDir := t.TempDir()
+	now := time.Now()
+	for domain, ts := range map[string]time.Time{
+		"recent.example.com": now.Add(-time.Hour),
+		"old.example.com":    now.Add(-30 * 24 * time.Hour),
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(workDir, domain), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(workDir, domain, "time.log"), []byte(strconv.FormatInt(ts.Unix(), 10)), 0644))
+	}
+
+	// 桩客户端中没有任何证书：请求服务端的域名都会失败，跳过的域名不会请求
+	cfg := &config.ClientConfig{WorkDir: workDir, Domains: []string{"recent.example.com", "old.example.com", "new.example.com"}}
+	results, err := runDeploy(context.Background(), &stubCertClient{}, cfg, &CliOptions{SinceDeploy: "7d"})
+	require.NoError(t, err)
+	require.Equal(t, []deployAction{actionSkipped, actionFailed, actionFailed},
+		[]deployAction{results[0].Action, results[1].Action, results[2].Action})
+
+	// -f 忽略 --since-deploy
+	results, err = runDeploy(context.Background(), &stubCertClient{}, cfg, &CliOptions{SinceDeploy: "7d", Force: true})
+	require.NoError(t, err)
+	require.Equal(t, actionFailed, results[0].Action)
+}
+
+func TestRunDeployHooks(t *testing.T) {
+	cert, key := generateKeyPair(t)
+	certs := map[string]*client.CertificateFiles{}
+	for _, d := range []string{"ok.com", "blocked.com", "reload-fail.com"} {
+		certs[d] = &client.CertificateFiles{Cert: cert, Key: key, Fullchain: cert, Timestamp: 1700000000}
+	}
+	deployDir, markDir := t.TempDir(), t.TempDir()
+	postCmd := "touch " + filepath.Join(markDir, "{domain}.post")
+	site := func(domain, preCmd, reloadCmd string) config.SiteDeployConfig {
+		return config.SiteDeployConfig{
+			Domain:    domain,
+			CertPath:  filepath.Join(deployDir, "{domain}.pem"),
+			ReloadCmd: reloadCmd,
+			PreCmd:    preCmd,
+			PostCmd:   postCmd,
+		}
+	}
+	cfg := &config.ClientConfig{
+		WorkDir: t.TempDir(),
+		Domains: []string{"ok.com", "blocked.com", "reload-fail.com"},
+		Sites: []config.SiteDeployConfig{
+			site("ok.com", "/bin/true", "/bin/true"),
+			site("blocked.com", "/bin/false", "/bin/true"),
+			site("reload-fail.com", "", "/bin/false"),
+		},
+	}
+
+	results, err := runDeploy(context.Background(), &stubCertClient{certs: certs}, cfg, &CliOptions{})
+	require.NoError(t, err)
+	require.Equal(t, actionDeployed, results[0].Action, results[0].Error)
+	require.Equal(t, actionFailed, results[1].Action, "precmd 失败时取消部署")
+	require.Contains(t, results[1].Error, "precmd")
+	require.Equal(t, actionDeployed, results[2].Action, "postcmd 与 reload 失败不影响部署结果")
+
+	exists := func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+	require.True(t, exists(filepath.Join(deployDir, "ok.com.pem")))
+	require.False(t, exists(filepath.Join(deployDir, "blocked.com.pem")), "precmd 失败时不应写入部署文件")
+	require.True(t, exists(filepath.Join(markDir, "ok.com.post")))
+	require.False(t, exists(filepath.Join(markDir, "blocked.com.post")))
+	require.False(t, exists(filepath.Join(markDir, "reload-fail.com.post")), "reload 失败时跳过 postcmd")
+
+	// 演练模式不执行 precmd 与 postcmd
+	cfg.WorkDir = t.TempDir()
+	cfg.Domains = []string{"blocked.com"}
+	results, err = runDeploy(context.Background(), &stubCertClient{certs: certs}, cfg, &CliOptions{DryRun: true})
+	require.NoError(t, err)
+	require.Equal(t, actionDeployed, results[0].Action)
+	require.False(t, exists(filepath.Join(markDir, "blocked.com.post")))
+}
+
+func TestRunDeployRollback(t *testing.T) {
+	cert, key := generateKeyPair(t)
+	certs := map[string]*client.CertificateFiles{}
+	for _, d := range []string{"a.com", "b.com"} {
+		certs[d] = &client.CertificateFiles{Cert: cert, Key: key, Fullchain: cert, Timestamp: 1700000000}
+	}
+	deployDir := t.TempDir()
+	oldPath := filepath.Join(deployDir, "a.com.pem")
+	require.NoError(t, os.WriteFile(oldPath, []byte("old cert"), 0644))
+
+	// reload 在部署新证书后退出码 1，恢复旧证书后成功
+	reloadCmd := filepath.Join(t.TempDir(), "reload.sh")
+	require.NoError(t, os.WriteFile(reloadCmd, []byte("#!/bin/sh\ngrep -q 'old cert' "+oldPath+" || exit 1\n"), 0755))
+	cfg := &config.ClientConfig{
+		WorkDir: t.TempDir(),
+		Domains: []string{"a.com", "b.com"},
+		Sites: []config.SiteDeployConfig{
+			{Domain: "a.com", CertPath: filepath.Join(deployDir, "{domain}.pem"), ReloadCmd: reloadCmd},
+			{Domain: "b.com", CertPath: filepath.Join(deployDir, "{domain}.pem"), ReloadCmd: reloadCmd},
+		},
+	}
+
+	results, err := runDeploy(context.Background(), &stubCertClient{certs: certs}, cfg, &CliOptions{})
+	require.NoError(t, err)
+	require.Equal(t, actionFailed, results[0].Action, "reload 失败时已回滚的域名视为部署失败")
+	require.Contains(t, results[0].Error, "已回滚")
+	require.Equal(t, actionDeployed, results[1].Action, "首次部署没有备份，保持原结果")
+
+	data, err := os.ReadFile(oldPath)
+	require.NoError(t, err)
+	require.Equal(t, "old cert", string(data))
+	backups, _ := filepath.Glob(oldPath + ".bak.*")
+	require.Len(t, backups, 1)
+
+	// 已回滚的域名不记录部署历史
+	_, err = os.Stat(filepath.Join(cfg.WorkDir, "a.com", "deploy_history.jsonl"))
+	require.True(t, os.IsNotExist(err))
+}
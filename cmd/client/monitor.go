@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/cert"
+	"github.com/Catker/acmeDeliver/pkg/config"
+	"github.com/Catker/acmeDeliver/pkg/domainmatch"
+)
+
+// --monitor 模式的退出码（Nagios 插件约定）
+const (
+	monitorOK       = 0 // 所有证书剩余天数高于警告阈值
+	monitorWarning  = 1 // 存在剩余天数不超过 --warn-days 的证书
+	monitorCritical = 2 // 存在剩余天数不超过 --crit-days、文件缺失或无法解析的证书
+	monitorUnknown  = 3 // 无法完成检查（如配置错误、没有可检查的站点）
+)
+
+// 默认告警阈值（天）
+const (
+	defaultWarnDays = 14
+	defaultCritDays = 7
+)
+
+// monitorStateNames 退出码对应的状态名称
+var monitorStateNames = map[int]string{
+	monitorOK:       "OK",
+	monitorWarning:  "WARNING",
+	monitorCritical: "CRITICAL",
+	monitorUnknown:  "UNKNOWN",
+}
+
+// monitorResult 单个域名已部署证书的检查结果
+type monitorResult struct {
+	Domain string
+	Path   string // 检查的证书文件（{domain} 已替换）
+	Days   int    // 剩余有效天数，出错时无意义
+	State  int    // monitorOK / monitorWarning / monitorCritical
+	Error  string // 文件缺失或无法解析的原因
+}
+
+// monitorTarget 待检查的域名及其站点配置
+type monitorTarget struct {
+	domain string
+	site   *config.SiteDeployConfig
+}
+
+// monitorTargets 确定要检查的域名：指定了 -d 或 domains 时按域名查找站点配置，
+// 否则检查所有站点；路径含 {domain} 的通配符站点无法确定实际文件，跳过并记录日志
+func monitorTargets(cfg *config.ClientConfig, opts *CliOptions) []monitorTarget {
+	if domains := getDomainsToProcess(cfg, opts); len(domains) > 0 {
+		targets := make([]monitorTarget, 0, len(domains))
+		for _, domain := range domains {
+			targets = append(targets, monitorTarget{domain: domain, site: config.FindSite(cfg.Sites, domain)})
+		}
+		return targets
+	}
+
+	targets := make([]monitorTarget, 0, len(cfg.Sites))
+	for i := range cfg.Sites {
+		site := &cfg.Sites[i]
+		if domainmatch.IsWildcard(site.Domain) && strings.Contains(monitorCertPath(site), "{domain}") {
+			slog.Warn("通配符站点的证书路径包含 {domain}，无法确定实际文件，请使用 -d 指定域名", "site", site.Domain)
+			continue
+		}
+		targets = append(targets, monitorTarget{domain: site.Domain, site: site})
+	}
+	return targets
+}
+
+// monitorCertPath 返回站点用于检查的证书路径，优先 fullchain_path
+func monitorCertPath(site *config.SiteDeployConfig) string {
+	if site.FullchainPath != "" {
+		return site.FullchainPath
+	}
+	return site.CertPath
+}
+
+// checkDeployedCert 检查单个域名已部署的证书文件
+func checkDeployedCert(target monitorTarget, warnDays, critDays int, now time.Time) monitorResult {
+	r := monitorResult{Domain: target.domain, State: monitorCritical}
+	if target.site == nil {
+		r.Error = "未找到站点配置"
+		return r
+	}
+	path := monitorCertPath(target.site)
+	if path == "" {
+		r.Error = "站点未配置 cert_path 或 fullchain_path"
+		return r
+	}
+	r.Path = strings.ReplaceAll(path, "{domain}", target.domain)
+
+	data, err := os.ReadFile(r.Path)
+	if err != nil {
+		r.Error = fmt.Sprintf("读取 %s 失败: %v", r.Path, err)
+		return r
+	}
+	notAfter, err := cert.ParseCertificateExpiry(data)
+	if err != nil {
+		r.Error = fmt.Sprintf("解析 %s 失败: %v", r.Path, err)
+		return r
+	}
+
+	r.Days = int(notAfter.Sub(now).Hours() / 24)
+	switch {
+	case !notAfter.After(now) || r.Days <= critDays:
+		r.State = monitorCritical
+	case r.Days <= warnDays:
+		r.State = monitorWarning
+	default:
+		r.State = monitorOK
+	}
+	return r
+}
+
+// runMonitor 离线检查已部署证书的剩余有效期，输出单行状态与 perfdata，返回退出码
+func runMonitor(w io.Writer, cfg *config.ClientConfig, opts *CliOptions, now time.Time) int {
+	targets := monitorTargets(cfg, opts)
+	if len(targets) == 0 {
+		fmt.Fprintln(w, "CERT UNKNOWN - 没有可检查的站点，请在配置文件中设置 sites 或使用 -d 指定域名")
+		return monitorUnknown
+	}
+
+	results := make([]monitorResult, 0, len(targets))
+	for _, target := range targets {
+		results = append(results, checkDeployedCert(target, opts.WarnDays, opts.CritDays, now))
+	}
+	return writeMonitorStatus(w, results, opts.WarnDays, opts.CritDays)
+}
+
+// writeMonitorStatus 输出状态行：状态 - 问题摘要 | perfdata，返回整体退出码
+func writeMonitorStatus(w io.Writer, results []monitorResult, warnDays, critDays int) int {
+	code := monitorOK
+	var problems, perfdata []string
+	for _, r := range results {
+		if r.State > code {
+			code = r.State
+		}
+		switch {
+		case r.Error != "":
+			problems = append(problems, fmt.Sprintf("%s: %s", r.Domain, r.Error))
+		case r.State != monitorOK:
+			problems = append(problems, fmt.Sprintf("%s: 剩余 %d 天", r.Domain, r.Days))
+		}
+		if r.Error == "" {
+			perfdata = append(perfdata, fmt.Sprintf("%s_days=%d;%d;%d", r.Domain, r.Days, warnDays, critDays))
+		}
+	}
+
+	summary := fmt.Sprintf("%d 个证书均有效", len(results))
+	if len(problems) > 0 {
+		summary = strings.Join(problems, "; ")
+	}
+	line := fmt.Sprintf("CERT %s - %s", monitorStateNames[code], summary)
+	if len(perfdata) > 0 {
+		line += " | " + strings.Join(perfdata, " ")
+	}
+	fmt.Fprintln(w, line)
+	return code
+}
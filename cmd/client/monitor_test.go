@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Catker/acmeDeliver/pkg/config"
+	"github.com/Catker/acmeDeliver/pkg/testutil"
+)
+
+// writeCertExpiring 在 path 写入 notAfter 到期的自签名证书
+func writeCertExpiring(t *testing.T, path string, notAfter time.Time) {
+	t.Helper()
+	certPEM, _, err := testutil.GenerateSelfSignedCert(filepath.Base(filepath.Dir(path)), time.Until(notAfter))
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, certPEM, 0644))
+}
+
+func TestCheckDeployedCert(t *testing.T) {
+	now := time.Now()
+	dir := t.TempDir()
+	site := &config.SiteDeployConfig{Domain: "*.example.com", FullchainPath: filepath.Join(dir, "{domain}", "fullchain.pem")}
+
+	day := 24 * time.Hour
+	writeCertExpiring(t, filepath.Join(dir, "ok.example.com", "fullchain.pem"), now.Add(60*day+time.Hour))
+	writeCertExpiring(t, filepath.Join(dir, "warn.example.com", "fullchain.pem"), now.Add(10*day+time.Hour))
+	writeCertExpiring(t, filepath.Join(dir, "crit.example.com", "fullchain.pem"), now.Add(3*day+time.Hour))
+	writeCertExpiring(t, filepath.Join(dir, "expired.example.com", "fullchain.pem"), now.Add(-time.Hour))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "junk.example.com"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "junk.example.com", "fullchain.pem"), []byte("not a cert"), 0644))
+
+	tests := []struct {
+		name     string
+		target   monitorTarget
+		want     int
+		wantDays int
+		wantErr  bool
+	}{
+		{"有效", monitorTarget{"ok.example.com", site}, monitorOK, 60, false},
+		{"即将过期", monitorTarget{"warn.example.com", site}, monitorWarning, 10, false},
+		{"严重", monitorTarget{"crit.example.com", site}, monitorCritical, 3, false},
+		{"已过期", monitorTarget{"expired.example.com", site}, monitorCritical, 0, false},
+		{"文件缺失", monitorTarget{"missing.example.com", site}, monitorCritical, 0, true},
+		{"无法解析", monitorTarget{"junk.example.com", site}, monitorCritical, 0, true},
+		{"未找到站点配置", monitorTarget{"other.com", nil}, monitorCritical, 0, true},
+		{"未配置证书路径", monitorTarget{"ok.example.com", &config.SiteDeployConfig{Domain: "ok.example.com"}}, monitorCritical, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := checkDeployedCert(tt.target, 14, 7, now)
+			require.Equal(t, tt.want, r.State)
+			require.Equal(t, tt.wantErr, r.Error != "", "error = %q", r.Error)
+			if !tt.wantErr {
+				require.Equal(t, tt.wantDays, r.Days)
+			}
+		})
+	}
+}
+
+func TestWriteMonitorStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		results  []monitorResult
+		wantCode int
+		wantLine string
+	}{
+		{
+			name: "全部正常",
+			results: []monitorResult{
+				{Domain: "example.com", Days: 34, State: monitorOK},
+				{Domain: "api.example.com", Days: 60, State: monitorOK},
+			},
+			wantCode: monitorOK,
+			wantLine: "CERT OK - 2 个证书均有效 | example.com_days=34;14;7 api.example.com_days=60;14;7",
+		},
+		{
+			name: "警告",
+			results: []monitorResult{
+				{Domain: "example.com", Days: 34, State: monitorOK},
+				{Domain: "api.example.com", Days: 10, State: monitorWarning},
+			},
+			wantCode: monitorWarning,
+			wantLine: "CERT WARNING - api.example.com: 剩余 10 天 | example.com_days=34;14;7 api.example.com_days=10;14;7",
+		},
+		{
+			name: "文件缺失为严重且不输出 perfdata",
+			results: []monitorResult{
+				{Domain: "example.com", Days: 10, State: monitorWarning},
+				{Domain: "api.example.com", State: monitorCritical, Error: "读取失败"},
+			},
+			wantCode: monitorCritical,
+			wantLine: "CERT CRITICAL - example.com: 剩余 10 天; api.example.com: 读取失败 | example.com_days=10;14;7",
+		},
+		{
+			name:     "全部出错",
+			results:  []monitorResult{{Domain: "example.com", State: monitorCritical, Error: "读取失败"}},
+			wantCode: monitorCritical,
+			wantLine: "CERT CRITICAL - example.com: 读取失败",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.Equal(t, tt.wantCode, writeMonitorStatus(&buf, tt.results, 14, 7))
+			require.Equal(t, tt.wantLine+"\n", buf.String())
+		})
+	}
+}
+
+func TestRunMonitor(t *testing.T) {
+	now := time.Now()
+	dir := t.TempDir()
+	writeCertExpiring(t, filepath.Join(dir, "example.com", "fullchain.pem"), now.Add(34*24*time.Hour+time.Hour))
+	writeCertExpiring(t, filepath.Join(dir, "a.example.org", "cert.pem"), now.Add(5*24*time.Hour+time.Hour))
+
+	cfg := &config.ClientConfig{Sites: []config.SiteDeployConfig{
+		{Domain: "example.com", CertPath: filepath.Join(dir, "{domain}", "cert.pem"), FullchainPath: filepath.Join(dir, "{domain}", "fullchain.pem")},
+		{Domain: "*.example.org", CertPath: filepath.Join(dir, "{domain}", "cert.pem")},
+	}}
+	opts := &CliOptions{Monitor: true, WarnDays: 14, CritDays: 7}
+
+	// 未指定域名：检查所有站点，路径含 {domain} 的通配符站点被跳过
+	var buf bytes.Buffer
+	require.Equal(t, monitorOK, runMonitor(&buf, cfg, opts, now))
+	require.Equal(t, "CERT OK - 1 个证书均有效 | example.com_days=34;14;7\n", buf.String())
+
+	// -d 指定域名时通过通配符站点定位证书
+	buf.Reset()
+	opts.DomainsStr = "example.com,a.example.org"
+	require.Equal(t, monitorCritical, runMonitor(&buf, cfg, opts, now))
+	require.True(t, strings.HasPrefix(buf.String(), "CERT CRITICAL - a.example.org: 剩余 5 天 |"), buf.String())
+
+	// 没有可检查的站点
+	buf.Reset()
+	require.Equal(t, monitorUnknown, runMonitor(&buf, &config.ClientConfig{}, &CliOptions{Monitor: true}, now))
+	require.True(t, strings.HasPrefix(buf.String(), "CERT UNKNOWN"))
+}
+
+func TestValidateArgsMonitor(t *testing.T) {
+	require.NoError(t, validateArgs(&CliOptions{Monitor: true, WarnDays: 14, CritDays: 7}))
+	require.NoError(t, validateArgs(&CliOptions{Monitor: true, WarnDays: 7, CritDays: 7}))
+	require.Error(t, validateArgs(&CliOptions{Monitor: true, WarnDays: 5, CritDays: 7}))
+	require.Error(t, validateArgs(&CliOptions{Monitor: true, WarnDays: 14, CritDays: -1}))
+	require.Error(t, validateArgs(&CliOptions{Monitor: true, Deploy: true, WarnDays: 14, CritDays: 7}))
+}
+
+func TestLoadConfigurationMonitorSkipsPassword(t *testing.T) {
+	oldConfigFile := configFile
+	configFile = writeTempConfig(t, `
+client:
+  sites:
+    - domain: "example.com"
+      cert_path: "/etc/ssl/example.com.pem"
+`)
+	t.Cleanup(func() { configFile = oldConfigFile })
+
+	cfg, err := loadConfiguration(&CliOptions{Monitor: true})
+	require.NoError(t, err)
+	require.Len(t, cfg.Sites, 1)
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/client"
+	"github.com/Catker/acmeDeliver/pkg/config"
+	"github.com/Catker/acmeDeliver/pkg/websocket"
+	"github.com/Catker/acmeDeliver/pkg/workspace"
+)
+
+// 输出格式（--output）
+const (
+	outputText = "text" // 面向人阅读的文本（默认）
+	outputJSON = "json" // 面向脚本的 JSON，日志改为输出到 stderr
+)
+
+// 域名证书状态（--status 派生字段）
+const (
+	certStateOK       = "ok"       // 证书可用
+	certStateExpiring = "expiring" // 7 天内过期
+	certStateExpired  = "expired"  // 已过期
+	certStateRevoked  = "revoked"  // 已被 CRL 吊销
+	certStateError    = "error"    // 服务端读取证书出错
+	certStateInvalid  = "invalid"  // 证书文件缺失或异常
+)
+
+// statusReport --status 的输出内容：服务端状态加上按查询时间计算的派生字段
+type statusReport struct {
+	Server      string               `json:"server"`
+	GeneratedAt int64                `json:"generated_at"`
+	Clients     []clientStatusReport `json:"clients"`
+	Domains     []domainStatusReport `json:"domains"`
+
+	// 过滤前的数量，仅设置了 --domain-filter 等过滤条件时输出
+	TotalClients int `json:"total_clients,omitempty"`
+	TotalDomains int `json:"total_domains,omitempty"`
+}
+
+// clientStatusReport 在线客户端状态
+type clientStatusReport struct {
+	websocket.ClientStatusInfo
+	ConnectedSeconds int64 `json:"connected_seconds"` // 已连接时长（秒）
+}
+
+// domainStatusReport 域名证书状态
+type domainStatusReport struct {
+	websocket.DomainStatus
+	State        string `json:"state"`                   // ok / expiring / expired / revoked / error / invalid
+	LastDeployed int64  `json:"last_deployed,omitempty"` // 本机最近一次部署时间（来自工作目录中的部署历史）
+}
+
+// newStatusReport 根据服务端状态生成输出内容，now 用于计算连接时长
+func newStatusReport(server string, status *websocket.StatusResponse, now time.Time) *statusReport {
+	report := &statusReport{
+		Server:      server,
+		GeneratedAt: status.GeneratedAt,
+		Clients:     make([]clientStatusReport, 0, len(status.Clients)),
+		Domains:     make([]domainStatusReport, 0, len(status.Domains)),
+	}
+	for _, c := range status.Clients {
+		report.Clients = append(report.Clients, clientStatusReport{
+			ClientStatusInfo: c,
+			ConnectedSeconds: int64(now.Sub(time.Unix(c.ConnectedAt, 0)).Seconds()),
+		})
+	}
+	for _, d := range status.Domains {
+		report.Domains = append(report.Domains, domainStatusReport{DomainStatus: d, State: certState(d)})
+	}
+	return report
+}
+
+// fillLastDeployed 从本机工作目录的部署历史中读取各域名最近一次部署时间，无部署历史时保持为空
+func (r *statusReport) fillLastDeployed(workDir string) {
+	if workDir == "" {
+		return
+	}
+	for i := range r.Domains {
+		records, err := workspace.GetDeployHistory(workDir, r.Domains[i].Domain, 1)
+		if err != nil {
+			slog.Debug("读取部署历史失败", "domain", r.Domains[i].Domain, "error", err)
+			continue
+		}
+		if len(records) > 0 {
+			r.Domains[i].LastDeployed = records[0].DeployedAt.Unix()
+		}
+	}
+}
+
+// certState 判定域名证书状态
+func certState(d websocket.DomainStatus) string {
+	switch {
+	case !d.Valid && d.Error != "":
+		return certStateError
+	case !d.Valid:
+		return certStateInvalid
+	case d.Revoked:
+		return certStateRevoked
+	case d.NotAfter > 0 && d.DaysRemaining <= 0:
+		return certStateExpired
+	case d.NotAfter > 0 && d.DaysRemaining <= 7:
+		return certStateExpiring
+	default:
+		return certStateOK
+	}
+}
+
+// deployAction 单个域名的部署结果
+type deployAction string
+
+const (
+	actionDeployed deployAction = "deployed" // 已下载并部署
+	actionSkipped  deployAction = "skipped"  // 证书未更新或未获取到数据，未部署
+	actionFailed   deployAction = "failed"   // 部署失败
+
+	// actionUnchanged 已写入部署文件，但内容与原有文件均相同，未执行重载
+	actionUnchanged deployAction = "unchanged"
+)
+
+// deployResult --deploy 单个域名的处理结果
+type deployResult struct {
+	Domain    string       `json:"domain"`
+	Action    deployAction `json:"action"`
+	ReloadCmd string       `json:"reload_cmd"` // 需要执行的重载命令，空表示无需重载
+	Error     string       `json:"error"`
+
+	certPEM []byte               // 已部署的证书，用于记录部署历史
+	postCmd string               // 站点的 postcmd（未替换 {domain}），reload 成功后执行
+	backup  *client.DeployBackup // 覆盖前的部署文件备份，reload 失败时回滚
+}
+
+// writeJSON 以缩进格式输出 JSON
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// renderStatus 按输出格式渲染 --status 结果
+func renderStatus(w io.Writer, report *statusReport, format string) error {
+	if format == outputJSON {
+		return writeJSON(w, report)
+	}
+
+	fmt.Fprintln(w, "======== acmeDeliver 服务器状态 ========")
+	fmt.Fprintf(w, "服务器: %s\n", report.Server)
+	fmt.Fprintf(w, "生成时间: %s\n\n", time.Unix(report.GeneratedAt, 0).Format("2006-01-02 15:04:05"))
+
+	// 在线客户端
+	fmt.Fprintln(w, "─────── 在线客户端 ───────")
+	if len(report.Clients) == 0 {
+		if report.TotalClients > 0 {
+			fmt.Fprintf(w, "没有符合条件的客户端（共 %d 个客户端在线）\n", report.TotalClients)
+		} else {
+			fmt.Fprintln(w, "当前没有客户端在线")
+		}
+	} else {
+		if report.TotalClients > 0 {
+			fmt.Fprintf(w, "符合条件 %d 个（共 %d 个客户端在线）:\n\n", len(report.Clients), report.TotalClients)
+		} else {
+			fmt.Fprintf(w, "共 %d 个客户端在线:\n\n", len(report.Clients))
+		}
+		for i, c := range report.Clients {
+			connectedAt := time.Unix(c.ConnectedAt, 0)
+			duration := formatDuration(time.Duration(c.ConnectedSeconds) * time.Second)
+			fmt.Fprintf(w, "[%d] %s\n", i+1, c.ID)
+			fmt.Fprintf(w, "    IP: %s\n", c.RemoteIP)
+			fmt.Fprintf(w, "    连接时间: %s (已连接 %s)\n", connectedAt.Format("2006-01-02 15:04:05"), duration)
+			if len(c.Domains) > 0 {
+				fmt.Fprintf(w, "    订阅域名: %s\n", strings.Join(c.Domains, ", "))
+			} else {
+				fmt.Fprintln(w, "    订阅域名: (无)")
+			}
+			fmt.Fprintln(w)
+		}
+	}
+
+	// 证书状态
+	fmt.Fprintln(w, "─────── 证书状态 ───────")
+	if len(report.Domains) == 0 {
+		if report.TotalDomains > 0 {
+			fmt.Fprintf(w, "没有符合条件的域名（共 %d 个域名）\n", report.TotalDomains)
+		} else {
+			fmt.Fprintln(w, "没有可用的域名证书")
+		}
+		return nil
+	}
+	if report.TotalDomains > 0 {
+		fmt.Fprintf(w, "符合条件 %d 个（共 %d 个域名）:\n\n", len(report.Domains), report.TotalDomains)
+	} else {
+		fmt.Fprintf(w, "共 %d 个域名:\n\n", len(report.Domains))
+	}
+	for i, d := range report.Domains {
+		// 状态标记
+		var statusIcon, statusText string
+		switch d.State {
+		case certStateRevoked:
+			statusIcon, statusText = "⛔", "证书已被吊销"
+		case certStateExpired:
+			statusIcon, statusText = "🔴", "证书已过期"
+		case certStateExpiring:
+			statusIcon, statusText = "🟡", "即将过期"
+		case certStateError:
+			statusIcon, statusText = "❌", d.Error
+		case certStateInvalid:
+			statusIcon, statusText = "⚠️", "文件异常"
+		default:
+			statusIcon, statusText = "✅", "可用"
+			if d.LastUpdate == 0 {
+				statusText = "可用（无时间戳）"
+			}
+		}
+
+		fmt.Fprintf(w, "[%d] %s\n", i+1, d.Domain)
+		fmt.Fprintf(w, "    状态: %s %s\n", statusIcon, statusText)
+
+		if d.LastUpdate > 0 {
+			fmt.Fprintf(w, "    下发: %s\n", time.Unix(d.LastUpdate, 0).Format("2006-01-02 15:04:05"))
+		}
+		if d.LastDeployed > 0 {
+			fmt.Fprintf(w, "    本机部署: %s\n", time.Unix(d.LastDeployed, 0).Format("2006-01-02 15:04:05"))
+		}
+
+		if d.NotAfter > 0 {
+			expireTime := time.Unix(d.NotAfter, 0)
+			expiryIcon := "🟢"
+			expiryText := fmt.Sprintf("剩余 %d 天", d.DaysRemaining)
+			if d.DaysRemaining <= 0 {
+				expiryIcon = "🔴"
+				expiryText = fmt.Sprintf("已过期 %d 天", -d.DaysRemaining)
+			} else if d.DaysRemaining <= 7 {
+				expiryIcon = "🔴"
+			} else if d.DaysRemaining <= 30 {
+				expiryIcon = "🟡"
+			}
+			fmt.Fprintf(w, "    过期: %s %s (%s)\n", expiryIcon, expireTime.Format("2006-01-02 15:04:05"), expiryText)
+		}
+
+		if d.Issuer != "" {
+			fmt.Fprintf(w, "    颁发: %s\n", d.Issuer)
+		}
+		if d.CRLError != "" {
+			fmt.Fprintf(w, "    CRL: ⚠️ 检查失败: %s\n", d.CRLError)
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+// renderDeployResults 按输出格式渲染 --deploy 结果
+func renderDeployResults(w io.Writer, results []deployResult, format string) error {
+	if format == outputJSON {
+		return writeJSON(w, results)
+	}
+
+	for _, r := range results {
+		switch r.Action {
+		case actionDeployed:
+			if r.ReloadCmd != "" {
+				fmt.Fprintf(w, "✅ %s: 已部署（重载命令: %s）\n", r.Domain, r.ReloadCmd)
+			} else {
+				fmt.Fprintf(w, "✅ %s: 已部署\n", r.Domain)
+			}
+		case actionSkipped:
+			fmt.Fprintf(w, "⏭️ %s: 已跳过\n", r.Domain)
+		case actionUnchanged:
+			fmt.Fprintf(w, "✅ %s: 部署文件未变化，跳过重载\n", r.Domain)
+		default:
+			fmt.Fprintf(w, "❌ %s: %s\n", r.Domain, r.Error)
+		}
+	}
+	succeeded, failed := countDeployResults(results)
+	fmt.Fprintf(w, "\n共 %d 个域名，%d 个成功，%d 个失败\n", len(results), succeeded, failed)
+	return nil
+}
+
+// countDeployResults 统计成功（已部署、未变化或跳过）与失败的域名数
+func countDeployResults(results []deployResult) (succeeded, failed int) {
+	for _, r := range results {
+		if r.Action == actionFailed {
+			failed++
+		} else {
+			succeeded++
+		}
+	}
+	return succeeded, failed
+}
+
+// renderCheckResults 按输出格式渲染 --check 结果
+func renderCheckResults(w io.Writer, results []checkResult, format string) error {
+	if format == outputJSON {
+		return writeJSON(w, results)
+	}
+
+	for _, r := range results {
+		switch {
+		case r.Error != "":
+			fmt.Fprintf(w, "❌ %s: %s\n", r.Domain, r.Error)
+		case r.Update:
+			fmt.Fprintf(w, "🔄 %s: 有可用更新（本地 %s → 服务端 %s）\n", r.Domain, formatTimestamp(r.Local), formatTimestamp(r.Remote))
+		default:
+			fmt.Fprintf(w, "✅ %s: 已是最新（%s）\n", r.Domain, formatTimestamp(r.Local))
+		}
+	}
+	return nil
+}
+
+// validationIssue --validate-config 的单项问题
+type validationIssue struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// renderValidationErrors 按输出格式渲染 --validate-config 结果
+func renderValidationErrors(w io.Writer, errs []error, format string) error {
+	issues := make([]validationIssue, 0, len(errs))
+	for _, err := range errs {
+		issue := validationIssue{Message: err.Error()}
+		var verr *config.ConfigValidationError
+		if errors.As(err, &verr) {
+			issue.Field = verr.Field
+		}
+		issues = append(issues, issue)
+	}
+	if format == outputJSON {
+		return writeJSON(w, issues)
+	}
+
+	if len(issues) == 0 {
+		fmt.Fprintln(w, "✅ 配置有效")
+		return nil
+	}
+	for _, issue := range issues {
+		fmt.Fprintf(w, "❌ %s\n", issue.Message)
+	}
+	fmt.Fprintf(w, "\n共 %d 个问题\n", len(issues))
+	return nil
+}
+
+// renderDomainList 按输出格式渲染 --list 结果：每行一个域名，列出更新时间与文件
+func renderDomainList(w io.Writer, domains []websocket.DomainEntry, format string) error {
+	if format == outputJSON {
+		if domains == nil {
+			domains = []websocket.DomainEntry{}
+		}
+		return writeJSON(w, domains)
+	}
+
+	if len(domains) == 0 {
+		fmt.Fprintln(w, "没有可用的域名证书")
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DOMAIN\tUPDATED\tFILES")
+	for _, d := range domains {
+		// 缺失值用 "-" 占位，保持列对齐
+		updated, files := "-", "-"
+		if d.Timestamp > 0 {
+			updated = formatTimestamp(d.Timestamp)
+		}
+		if len(d.Files) > 0 {
+			files = strings.Join(d.Files, ",")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", d.Domain, updated, files)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\n共 %d 个域名\n", len(domains))
+	return nil
+}
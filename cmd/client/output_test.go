@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Catker/acmeDeliver/pkg/config"
+	"github.com/Catker/acmeDeliver/pkg/websocket"
+)
+
+var updateGolden = flag.Bool("update", false, "更新 testdata 中的 golden 文件")
+
+// assertGolden 将输出与 testdata/<name> 比对，-update 时重写 golden 文件
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		require.NoError(t, os.MkdirAll("testdata", 0755))
+		require.NoError(t, os.WriteFile(path, got, 0644))
+	}
+	want, err := os.ReadFile(path)
+	require.NoError(t, err, "golden 文件不存在，使用 go test -update 生成")
+	require.Equal(t, string(want), string(got))
+}
+
+// useUTC 固定本地时区，避免文本输出中的时间随运行环境变化
+func useUTC(t *testing.T) {
+	t.Helper()
+	local := time.Local
+	time.Local = time.UTC
+	t.Cleanup(func() { time.Local = local })
+}
+
+func testStatusReport() *statusReport {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	status := &websocket.StatusResponse{
+		GeneratedAt: now.Unix(),
+		Clients: []websocket.ClientStatusInfo{
+			{ID: "web-01", RemoteIP: "10.0.0.1", ConnectedAt: now.Add(-26*time.Hour - 30*time.Minute).Unix(), Domains: []string{"example.com", "*.example.org"}},
+			{ID: "web-02", RemoteIP: "10.0.0.2", ConnectedAt: now.Add(-45 * time.Second).Unix()},
+		},
+		Domains: []websocket.DomainStatus{
+			{Domain: "example.com", Valid: true, HasCert: true, HasKey: true, HasFullchain: true,
+				LastUpdate: now.Add(-24 * time.Hour).Unix(), NotAfter: now.Add(60 * 24 * time.Hour).Unix(), DaysRemaining: 60, Issuer: "R3"},
+			{Domain: "soon.example.com", Valid: true, HasCert: true, HasKey: true, HasFullchain: true,
+				LastUpdate: now.Add(-80 * 24 * time.Hour).Unix(), NotAfter: now.Add(5 * 24 * time.Hour).Unix(), DaysRemaining: 5},
+			{Domain: "old.example.com", Valid: true, HasCert: true, HasKey: true, HasFullchain: true,
+				NotAfter: now.Add(-2 * 24 * time.Hour).Unix(), DaysRemaining: -2},
+			{Domain: "revoked.example.com", Valid: true, HasCert: true, HasKey: true, HasFullchain: true, Revoked: true,
+				LastUpdate: now.Add(-24 * time.Hour).Unix(), NotAfter: now.Add(60 * 24 * time.Hour).Unix(), DaysRemaining: 60},
+			{Domain: "nocrl.example.com", Valid: true, HasCert: true, HasKey: true, HasFullchain: true, CRLError: "证书未包含 CRL 分发点"},
+			{Domain: "broken.example.com", HasCert: true, Error: "证书解析失败"},
+			{Domain: "empty.example.com"},
+		},
+	}
+	report := newStatusReport("ws://server:9090", status, now)
+	report.Domains[0].LastDeployed = now.Add(-23 * time.Hour).Unix()
+	return report
+}
+
+func testDeployResults() []deployResult {
+	return []deployResult{
+		{Domain: "example.com", Action: actionDeployed, ReloadCmd: "systemctl reload nginx"},
+		{Domain: "internal.example.com", Action: actionDeployed},
+		{Domain: "static.example.com", Action: actionSkipped},
+		{Domain: "www.example.com", Action: actionUnchanged},
+		{Domain: "missing.example.com", Action: actionFailed, Error: "下载证书失败: 证书不存在"},
+	}
+}
+
+func TestRenderStatus(t *testing.T) {
+	useUTC(t)
+	report := testStatusReport()
+
+	for _, format := range []string{outputText, outputJSON} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, renderStatus(&buf, report, format))
+			assertGolden(t, "status."+format+".golden", buf.Bytes())
+		})
+	}
+}
+
+func TestRenderDeployResults(t *testing.T) {
+	for _, format := range []string{outputText, outputJSON} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, renderDeployResults(&buf, testDeployResults(), format))
+			assertGolden(t, "deploy."+format+".golden", buf.Bytes())
+		})
+	}
+}
+
+func TestRenderDomainList(t *testing.T) {
+	useUTC(t)
+	domains := []websocket.DomainEntry{
+		{Domain: "example.com", Timestamp: 1709294400, Files: []string{"cert.pem", "fullchain.pem", "key.pem", "time.log"}},
+		{Domain: "api.example.org", Timestamp: 1706702400, Files: []string{"cert.pem", "key.pem"}},
+		{Domain: "pending.example.net", Files: []string{}},
+	}
+
+	for _, format := range []string{outputText, outputJSON} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, renderDomainList(&buf, domains, format))
+			assertGolden(t, "list."+format+".golden", buf.Bytes())
+		})
+	}
+
+	// 空列表：JSON 输出 [] 而不是 null
+	var buf bytes.Buffer
+	require.NoError(t, renderDomainList(&buf, nil, outputJSON))
+	require.Equal(t, "[]\n", buf.String())
+}
+
+func TestNewStatusReportEmpty(t *testing.T) {
+	// 无客户端和域名时 JSON 输出空数组而不是 null，便于脚本处理
+	var buf bytes.Buffer
+	require.NoError(t, renderStatus(&buf, newStatusReport("ws://server:9090", &websocket.StatusResponse{}, time.Now()), outputJSON))
+	require.Contains(t, buf.String(), `"clients": []`)
+	require.Contains(t, buf.String(), `"domains": []`)
+}
+
+func TestStructuredOutputLogging(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.LoggingConfig
+		opts CliOptions
+		want string
+	}{
+		{"文本输出保持 stdout", config.LoggingConfig{}, CliOptions{Output: outputText}, ""},
+		{"JSON 输出默认改为 stderr", config.LoggingConfig{}, CliOptions{Output: outputJSON}, "stderr"},
+		{"JSON 输出显式 stdout 改为 stderr", config.LoggingConfig{Output: "stdout"}, CliOptions{Output: outputJSON}, "stderr"},
+		{"JSON 输出保留日志文件", config.LoggingConfig{Output: "/var/log/acme.log"}, CliOptions{Output: outputJSON}, "/var/log/acme.log"},
+		{"监控模式改为 stderr", config.LoggingConfig{}, CliOptions{Monitor: true}, "stderr"},
+		{"证书输出到标准输出时改为 stderr", config.LoggingConfig{}, CliOptions{Get: getFullchain, Out: outStdout}, "stderr"},
+		{"证书输出到目录保持 stdout", config.LoggingConfig{}, CliOptions{Get: getAll, Out: "/tmp/certs"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, structuredOutputLogging(tt.cfg, &tt.opts).Output)
+		})
+	}
+}
+
+func TestValidateArgsOutputFormat(t *testing.T) {
+	require.NoError(t, validateArgs(&CliOptions{Output: outputText}))
+	require.NoError(t, validateArgs(&CliOptions{Output: outputJSON, Deploy: true}))
+	require.Error(t, validateArgs(&CliOptions{Output: "yaml"}))
+}
+
+func TestValidateArgsList(t *testing.T) {
+	require.NoError(t, validateArgs(&CliOptions{List: true, Output: outputJSON}))
+	require.Error(t, validateArgs(&CliOptions{List: true, Status: true}))
+	require.Error(t, validateArgs(&CliOptions{List: true, Deploy: true}))
+}
+
+func TestValidateArgsCheckCRL(t *testing.T) {
+	require.NoError(t, validateArgs(&CliOptions{Status: true, CheckCRL: true}))
+	require.Error(t, validateArgs(&CliOptions{Deploy: true, CheckCRL: true}))
+}
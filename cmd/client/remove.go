@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/client"
+	"github.com/Catker/acmeDeliver/pkg/config"
+)
+
+// validateRemoveArgs 校验 --remove 参数：必须通过 -d 指定域名，--purge-deployed 只能与 --remove 同时使用
+func validateRemoveArgs(opts *CliOptions) error {
+	if !opts.Remove {
+		if opts.PurgeDeployed {
+			return fmt.Errorf("--purge-deployed 只能与 --remove 同时使用")
+		}
+		return nil
+	}
+	if strings.TrimSpace(opts.DomainsStr) == "" {
+		return fmt.Errorf("--remove 需要通过 -d 指定要下线的域名")
+	}
+	return nil
+}
+
+// runRemove 下线 -d 指定的域名：删除工作目录中的域名目录，--purge-deployed 时删除站点配置中的部署文件，
+// 最后统一执行匹配站点的重载命令（去重），删除内容按 --output 格式输出
+// 没有匹配的站点配置且未指定 --force 的域名不做任何删除，计为失败
+func runRemove(w io.Writer, cfg *config.ClientConfig, opts *CliOptions) error {
+	results := make([]*client.RemovalResult, 0)
+	reloads := make(map[string]bool)
+	failed := 0
+	for _, domain := range getDomainsToProcess(cfg, opts) {
+		site := config.FindSite(cfg.Sites, domain)
+		result, err := client.RemoveDomain(client.RemoveOptions{
+			WorkDir:       cfg.WorkDir,
+			Domain:        domain,
+			Site:          site,
+			PurgeDeployed: opts.PurgeDeployed,
+			Force:         opts.Force,
+			DryRun:        opts.DryRun,
+		})
+		if errors.Is(err, client.ErrNoSiteConfig) {
+			err = fmt.Errorf("%w，使用 --force 仍删除工作目录", err)
+		}
+		if err != nil {
+			slog.Error("域名下线失败", "domain", domain, "error", err)
+			failed++
+			continue
+		}
+		results = append(results, result)
+
+		if site != nil {
+			if cmd := resolveReloadCmd(cfg, site, opts); cmd != "" {
+				reloads[cmd] = true
+			}
+		}
+	}
+
+	if len(reloads) > 0 {
+		executeReloadCommands(reloadOutput(opts), reloads, time.Duration(cfg.Timeouts.Reload)*time.Second, opts.DryRun)
+	}
+	if err := renderRemoveResults(w, results, opts.Output); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d 个域名下线失败", failed)
+	}
+	return nil
+}
+
+// renderRemoveResults 输出 --remove 删除（--dry-run 时为将要删除）的内容
+func renderRemoveResults(w io.Writer, results []*client.RemovalResult, format string) error {
+	if format == outputJSON {
+		return writeJSON(w, results)
+	}
+
+	for _, r := range results {
+		verb := "已删除"
+		if r.DryRun {
+			verb = "[DryRun] 将删除"
+		}
+		fmt.Fprintf(w, "%s:\n", r.Domain)
+		for _, path := range r.Skipped {
+			fmt.Fprintf(w, "  跳过共享部署文件（通配符站点，--force 仍删除）: %s\n", path)
+		}
+		if r.Workspace == "" && len(r.Deployed) == 0 {
+			fmt.Fprintln(w, "  没有需要删除的文件")
+			continue
+		}
+		if r.Workspace != "" {
+			fmt.Fprintf(w, "  %s工作目录: %s\n", verb, r.Workspace)
+		}
+		for _, path := range r.Deployed {
+			fmt.Fprintf(w, "  %s部署文件: %s\n", verb, path)
+		}
+	}
+	return nil
+}
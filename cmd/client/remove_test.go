@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Catker/acmeDeliver/pkg/client"
+	"github.com/Catker/acmeDeliver/pkg/config"
+)
+
+func TestValidateRemoveArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    CliOptions
+		wantErr bool
+	}{
+		{"下线域名", CliOptions{Remove: true, DomainsStr: "example.com"}, false},
+		{"删除已部署文件", CliOptions{Remove: true, PurgeDeployed: true, DomainsStr: "a.com,b.com"}, false},
+		{"缺少域名", CliOptions{Remove: true}, true},
+		{"--purge-deployed 缺少 --remove", CliOptions{PurgeDeployed: true, DomainsStr: "example.com"}, true},
+		{"与 --deploy 冲突", CliOptions{Remove: true, Deploy: true, DomainsStr: "example.com"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateArgs(&tt.opts)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+// newRemoveConfig 创建包含 example.com 工作目录与已部署证书的配置，重载命令在 reloadMarker 创建文件
+func newRemoveConfig(t *testing.T) (cfg *config.ClientConfig, certPath, reloadMarker string) {
+	t.Helper()
+	workDir, deployDir := t.TempDir(), t.TempDir()
+	for _, domain := range []string{"example.com", "unmanaged.com"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(workDir, domain), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(workDir, domain, "cert.pem"), []byte("cert"), 0644))
+	}
+	certPath = filepath.Join(deployDir, "cert.pem")
+	require.NoError(t, os.WriteFile(certPath, []byte("cert"), 0644))
+	reloadMarker = filepath.Join(deployDir, "reloaded")
+
+	cfg = &config.ClientConfig{
+		WorkDir: workDir,
+		Sites:   []config.SiteDeployConfig{{Domain: "example.com", CertPath: certPath, ReloadCmd: "touch " + reloadMarker}},
+	}
+	return cfg, certPath, reloadMarker
+}
+
+func TestRunRemove(t *testing.T) {
+	cfg, certPath, reloadMarker := newRemoveConfig(t)
+
+	var out bytes.Buffer
+	err := runRemove(&out, cfg, &CliOptions{Remove: true, PurgeDeployed: true, DomainsStr: "example.com", Output: outputJSON})
+	require.NoError(t, err)
+
+	var results []client.RemovalResult
+	require.NoError(t, json.Unmarshal(out.Bytes(), &results))
+	require.Len(t, results, 1)
+	assert.Equal(t, filepath.Join(cfg.WorkDir, "example.com"), results[0].Workspace)
+	assert.Equal(t, []string{certPath}, results[0].Deployed)
+
+	assert.NoDirExists(t, filepath.Join(cfg.WorkDir, "example.com"))
+	assert.DirExists(t, filepath.Join(cfg.WorkDir, "unmanaged.com"))
+	assert.NoFileExists(t, certPath)
+	assert.FileExists(t, reloadMarker, "下线后应执行站点的重载命令")
+}
+
+func TestRunRemoveDryRun(t *testing.T) {
+	cfg, certPath, reloadMarker := newRemoveConfig(t)
+
+	var out bytes.Buffer
+	err := runRemove(&out, cfg, &CliOptions{Remove: true, PurgeDeployed: true, DryRun: true, DomainsStr: "example.com"})
+	require.NoError(t, err)
+
+	assert.Contains(t, out.String(), "[DryRun] 将删除工作目录: "+filepath.Join(cfg.WorkDir, "example.com"))
+	assert.Contains(t, out.String(), "[DryRun] 将删除部署文件: "+certPath)
+	assert.DirExists(t, filepath.Join(cfg.WorkDir, "example.com"))
+	assert.FileExists(t, certPath)
+	assert.NoFileExists(t, reloadMarker)
+}
+
+func TestRunRemoveRequiresSiteOrForce(t *testing.T) {
+	cfg, _, _ := newRemoveConfig(t)
+	opts := &CliOptions{Remove: true, DomainsStr: "unmanaged.com"}
+
+	err := runRemove(&bytes.Buffer{}, cfg, opts)
+	require.Error(t, err)
+	assert.DirExists(t, filepath.Join(cfg.WorkDir, "unmanaged.com"))
+
+	opts.Force = true
+	require.NoError(t, runRemove(&bytes.Buffer{}, cfg, opts))
+	assert.NoDirExists(t, filepath.Join(cfg.WorkDir, "unmanaged.com"))
+	assert.DirExists(t, filepath.Join(cfg.WorkDir, "example.com"))
+}
@@ -2,36 +2,80 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 
 	"github.com/Catker/acmeDeliver/pkg/config"
+	"github.com/Catker/acmeDeliver/pkg/install"
+	"github.com/Catker/acmeDeliver/pkg/logging"
+	"github.com/Catker/acmeDeliver/pkg/ringlog"
 	"github.com/Catker/acmeDeliver/pkg/server"
 )
 
 const VERSION = "3.1.1"
 
-func main() {
-	// 显示版本信息
-	fmt.Printf("acmeDeliver v%s - 轻量证书分发服务\n\n", VERSION)
+// 服务安装参数（--install-service）
+var (
+	installService = flag.Bool("install-service", false, "生成 systemd unit（非 systemd 系统生成 OpenRC 脚本）并安装，ExecStart 使用 -c 指定的配置文件")
+	serviceUser    = flag.String("service-user", "", "配合 --install-service，服务的运行用户（默认 root）")
+	serviceGroup   = flag.String("service-group", "", "配合 --install-service，服务的运行用户组")
+	serviceEnable  = flag.Bool("enable", false, "配合 --install-service，安装后设置开机启动")
+	serviceDryRun  = flag.Bool("dry-run", false, "配合 --install-service，只输出服务文件，不写入系统")
+	initSystem     = flag.String("init-system", "", "配合 --install-service，指定 systemd 或 openrc（默认自动检测）")
+)
 
+func main() {
 	// 初始化配置
-	if err := config.InitConfig(); err != nil {
+	if err := config.InitConfig(); errors.Is(err, config.ErrConfigPrinted) {
+		return
+	} else if err != nil {
 		slog.Error("初始化配置失败", "error", err)
 		os.Exit(1)
 	}
 	cfg := config.GetConfig()
 
+	// 安装系统服务（--dry-run 时服务文件输出到 stdout，不显示版本信息）
+	if *installService {
+		if err := runInstallService(cfg); err != nil {
+			slog.Error("安装服务失败", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// 显示版本信息
+	fmt.Printf("acmeDeliver v%s - 轻量证书分发服务\n\n", VERSION)
+
+	// 按配置初始化日志（级别支持热重载，SIGHUP 重新打开日志文件）
+	// 同时在内存中保留最近的日志，供 /healthz 不健康时返回
+	logRing := ringlog.NewRingHandler(ringlog.DefaultCapacity, nil)
+	logger, err := logging.Setup(cfg.Logging, logRing)
+	if err != nil {
+		slog.Error("初始化日志失败", "error", err)
+		os.Exit(1)
+	}
+	defer logger.Close()
+	defer logger.ReopenOnSIGHUP()()
+	config.RegisterReloadCallback(func(newCfg *config.Config) {
+		if err := logger.SetLevel(newCfg.Logging.Level); err != nil {
+			slog.Warn("日志级别无效，保持原级别", "error", err)
+		}
+	})
+
 	// 创建服务器实例（封装所有依赖，替代全局变量）
 	srv, err := server.NewServer(cfg)
 	if err != nil {
 		slog.Error("创建服务器失败", "error", err)
 		os.Exit(1)
 	}
+	srv.SetLogRing(logRing)
 
 	// 将系统信号统一转换为上下文取消，交给 Run(ctx) 处理关闭
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
@@ -44,6 +88,54 @@ func main() {
 	}
 }
 
+// runInstallService 按当前配置生成并安装服务文件
+// 证书目录（上传接口写入）与日志文件目录在 ProtectSystem=strict 下保持可写
+func runInstallService(cfg *config.Config) error {
+	if cfg.ConfigFile == "" {
+		return fmt.Errorf("请使用 -c 指定配置文件，服务启动时将使用该文件")
+	}
+	configPath, err := filepath.Abs(cfg.ConfigFile)
+	if err != nil {
+		return err
+	}
+	exe, err := install.Executable()
+	if err != nil {
+		return fmt.Errorf("获取可执行文件路径失败: %w", err)
+	}
+	workDir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	var writable []string
+	for _, dir := range config.CertDirs(cfg) {
+		if abs, err := filepath.Abs(dir); err == nil {
+			writable = append(writable, abs)
+		}
+	}
+	switch cfg.Logging.Output {
+	case "", "stdout", "stderr":
+	default:
+		if abs, err := filepath.Abs(cfg.Logging.Output); err == nil {
+			writable = append(writable, filepath.Dir(abs))
+		}
+	}
+
+	svc := &install.Service{
+		Name:           "acmedeliver-server",
+		Description:    "acmeDeliver 证书分发服务端",
+		ExecPath:       exe,
+		Args:           []string{"-c", configPath},
+		User:           *serviceUser,
+		Group:          *serviceGroup,
+		WorkingDir:     workDir,
+		ProtectSystem:  "strict",
+		ReadWritePaths: writable,
+		PrivateTmp:     true,
+	}
+	return install.Run(os.Stdout, *initSystem, svc, *serviceDryRun, *serviceEnable)
+}
+
 func init() {
 	// 自定义帮助信息
 	if len(os.Args) > 1 && (os.Args[1] == "-h" || os.Args[1] == "--help") {
@@ -53,11 +145,35 @@ func init() {
 
 	// 生成示例配置
 	if len(os.Args) > 1 && os.Args[1] == "--gen-config" {
-		fmt.Println(config.GenerateExampleConfig())
+		format, err := config.ParseFormat(genConfigFormat(os.Args[2:]))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		example, err := config.GenerateConfig(format)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "生成示例配置失败:", err)
+			os.Exit(1)
+		}
+		fmt.Println(example)
 		os.Exit(0)
 	}
 }
 
+// genConfigFormat 从 --gen-config 之后的参数中读取 --format 值
+// 支持 "--format json" 与 "--format=json" 两种写法
+func genConfigFormat(args []string) string {
+	for i, arg := range args {
+		if v, ok := strings.CutPrefix(arg, "--format="); ok {
+			return v
+		}
+		if arg == "--format" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
 func usage() {
 	fmt.Fprintf(os.Stderr, `acmeDeliver v%s - 轻量证书分发服务
 
@@ -69,7 +185,10 @@ func usage() {
 	flag.PrintDefaults()
 	fmt.Fprintf(os.Stderr, `
 特殊命令:
-  --gen-config  生成示例配置文件
+  --gen-config [--format yaml|json|toml]  生成示例配置文件（默认 yaml）
+  --print-config  输出合并配置文件、环境变量与命令行后的有效配置（YAML，隐藏密码）
+  --install-service [--service-user U] [--service-group G] [--enable] [--dry-run]
+                生成并安装 systemd unit（OpenRC 系统生成 /etc/init.d 脚本）
   -h, --help    显示帮助信息
 
 状态查询:
@@ -83,7 +202,15 @@ func usage() {
   # 使用命令行参数
   acmedeliver-server -p 8080 -d /var/certs -k mypassword
 
+  # 安装为系统服务（先用 --dry-run 预览）
+  acmedeliver-server -c /etc/acmedeliver/config.yaml --install-service --service-user acmedeliver --dry-run
+  sudo acmedeliver-server -c /etc/acmedeliver/config.yaml --install-service --service-user acmedeliver --enable
+
+  # 查看生效的配置（排查配置优先级）
+  ACMEDELIVER_PORT=8080 acmedeliver-server -c config.yaml --print-config
+
   # 生成示例配置
   acmedeliver-server --gen-config > config.yaml
+  acmedeliver-server --gen-config --format toml > config.toml
 `)
 }
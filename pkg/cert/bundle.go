@@ -0,0 +1,102 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+)
+
+// BundleCerts 生成 HAProxy、部分 Java 配置等需要的单文件证书包（PEM）
+// 顺序为：叶子证书、按签发关系排列的中间证书，fullchain 中包含根证书时放在最后
+// certPEM 为叶子证书，为空时取 fullchainPEM 的第一张证书；fullchainPEM 中与叶子证书相同（DER 相同）
+// 或重复的证书只保留一份，无法接入签发链的证书按原顺序放在中间证书之后
+func BundleCerts(certPEM, fullchainPEM []byte) ([]byte, error) {
+	var leaf *x509.Certificate
+	if len(bytes.TrimSpace(certPEM)) > 0 {
+		certs, err := parseCertificateChain(certPEM)
+		if err != nil {
+			return nil, fmt.Errorf("解析证书失败: %w", err)
+		}
+		leaf = certs[0]
+	}
+
+	var chain []*x509.Certificate
+	if len(bytes.TrimSpace(fullchainPEM)) > 0 {
+		certs, err := parseCertificateChain(fullchainPEM)
+		if err != nil {
+			return nil, fmt.Errorf("解析证书链失败: %w", err)
+		}
+		chain = certs
+	}
+	if leaf == nil {
+		if len(chain) == 0 {
+			return nil, fmt.Errorf("证书与证书链均为空")
+		}
+		leaf = chain[0]
+	}
+
+	// 去除叶子证书与重复的证书，分出根证书（自签名）
+	seen := map[string]bool{string(leaf.Raw): true}
+	var rest, roots []*x509.Certificate
+	for _, c := range chain {
+		if seen[string(c.Raw)] {
+			continue
+		}
+		seen[string(c.Raw)] = true
+		if isSelfSigned(c) {
+			roots = append(roots, c)
+		} else {
+			rest = append(rest, c)
+		}
+	}
+
+	// 从叶子证书开始沿签发者查找中间证书
+	ordered := []*x509.Certificate{leaf}
+	for current := leaf; ; {
+		i := findIssuer(rest, current)
+		if i < 0 {
+			break
+		}
+		current = rest[i]
+		ordered = append(ordered, current)
+		rest = append(rest[:i], rest[i+1:]...)
+	}
+	ordered = append(ordered, rest...)
+	ordered = append(ordered, roots...)
+
+	var buf bytes.Buffer
+	for _, c := range ordered {
+		if err := pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: c.Raw}); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// findIssuer 返回 certs 中签发 c 的证书下标，不存在时返回 -1
+// 按主题与签发者名称匹配，双方都带密钥标识时还需 AuthorityKeyId 与 SubjectKeyId 一致
+func findIssuer(certs []*x509.Certificate, c *x509.Certificate) int {
+	for i, candidate := range certs {
+		if issuedBy(c, candidate) {
+			return i
+		}
+	}
+	return -1
+}
+
+// issuedBy 判断 c 是否由 issuer 签发
+func issuedBy(c, issuer *x509.Certificate) bool {
+	if !bytes.Equal(c.RawIssuer, issuer.RawSubject) {
+		return false
+	}
+	if len(c.AuthorityKeyId) > 0 && len(issuer.SubjectKeyId) > 0 {
+		return bytes.Equal(c.AuthorityKeyId, issuer.SubjectKeyId)
+	}
+	return true
+}
+
+// isSelfSigned 判断证书是否自签名（根证书）
+func isSelfSigned(c *x509.Certificate) bool {
+	return issuedBy(c, c)
+}
@@ -0,0 +1,84 @@
+package cert
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Catker/acmeDeliver/pkg/testutil"
+)
+
+// intermediate 签发名为 name 的中间 CA
+func (ca *testCA) intermediate(t *testing.T, name string) *testCA {
+	t.Helper()
+	caPEM, caKeyPEM, err := testutil.GenerateIntermediateCA(string(ca.pem), string(ca.keyPEM), name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parseTestCA(t, caPEM, caKeyPEM)
+}
+
+// pemSubjects 返回 PEM 中各证书的 CommonName
+func pemSubjects(t *testing.T, data []byte) []string {
+	t.Helper()
+	certs, err := parseCertificateChain(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(certs))
+	for i, c := range certs {
+		names[i] = c.Subject.CommonName
+	}
+	return names
+}
+
+func TestBundleCerts(t *testing.T) {
+	root := newTestCA(t)
+	inter1 := root.intermediate(t, "Intermediate 1")
+	inter2 := inter1.intermediate(t, "Intermediate 2")
+	leaf := inter2.issue(t, 10)
+
+	join := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+	tests := []struct {
+		name      string
+		cert      []byte
+		fullchain []byte
+		want      []string
+	}{
+		{"标准 fullchain", leaf, join(leaf, inter2.pem, inter1.pem), []string{"example.com", "Intermediate 2", "Intermediate 1"}},
+		{"中间证书乱序", leaf, join(inter1.pem, leaf, inter2.pem), []string{"example.com", "Intermediate 2", "Intermediate 1"}},
+		{"根证书放在最后", leaf, join(root.pem, leaf, inter2.pem, inter1.pem), []string{"example.com", "Intermediate 2", "Intermediate 1", "acmeDeliver Test CA"}},
+		{"fullchain 不含叶子证书", leaf, join(inter2.pem, inter1.pem), []string{"example.com", "Intermediate 2", "Intermediate 1"}},
+		{"重复证书只保留一份", leaf, join(leaf, inter2.pem, inter2.pem, inter1.pem), []string{"example.com", "Intermediate 2", "Intermediate 1"}},
+		{"未提供 cert 时取 fullchain 第一张", nil, join(leaf, inter2.pem), []string{"example.com", "Intermediate 2"}},
+		{"只有叶子证书", leaf, nil, []string{"example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := BundleCerts(tt.cert, tt.fullchain)
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := pemSubjects(t, got)
+			if len(names) != len(tt.want) {
+				t.Fatalf("证书包 = %v, want %v", names, tt.want)
+			}
+			for i := range names {
+				if names[i] != tt.want[i] {
+					t.Fatalf("证书包 = %v, want %v", names, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestBundleCertsErrors(t *testing.T) {
+	if _, err := BundleCerts(nil, nil); err == nil {
+		t.Error("证书与证书链均为空时应返回错误")
+	}
+	if _, err := BundleCerts([]byte("not a pem"), nil); err == nil {
+		t.Error("无效的证书应返回错误")
+	}
+	if _, err := BundleCerts(newTestCA(t).pem, []byte("-----BEGIN CERTIFICATE-----\nAAAA\n-----END CERTIFICATE-----\n")); err == nil {
+		t.Error("无效的证书链应返回错误")
+	}
+}
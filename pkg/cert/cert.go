@@ -7,7 +7,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strconv"
+	"sort"
 	"strings"
 	"time"
 )
@@ -17,8 +17,8 @@ type DomainStatus struct {
 	Domain        string `json:"domain"`                   // 域名
 	LastUpdate    int64  `json:"last_update,omitempty"`    // 最后更新时间（Unix 时间戳）
 	HasCert       bool   `json:"has_cert"`                 // 是否有 cert.pem
-	HasKey        bool   `json:"has_key"`                  // 是否有 key.pem
-	HasFullchain  bool   `json:"has_fullchain"`            // 是否有 fullchain.pem
+	HasKey        bool   `json:"has_key"`                  // 是否有 key.pem（certbot 目录为 privkey.pem）
+	HasFullchain  bool   `json:"has_fullchain"`            // 是否有 fullchain.pem（没有时 certbot 的 chain.pem 也视为证书链）
 	CertSize      int64  `json:"cert_size,omitempty"`      // cert.pem 大小
 	KeySize       int64  `json:"key_size,omitempty"`       // key.pem 大小
 	FullchainSize int64  `json:"fullchain_size,omitempty"` // fullchain.pem 大小
@@ -29,6 +29,8 @@ type DomainStatus struct {
 	Subject       string `json:"subject,omitempty"`        // 证书主题
 	Issuer        string `json:"issuer,omitempty"`         // 颁发者
 	Error         string `json:"error,omitempty"`          // 错误信息
+	Revoked       bool   `json:"revoked,omitempty"`        // 证书已被 CRL 吊销（仅请求 CRL 检查时填充）
+	CRLError      string `json:"crl_error,omitempty"`      // CRL 检查失败原因
 }
 
 // ParseCertificate 解析 PEM 格式的证书文件
@@ -44,25 +46,20 @@ func ParseCertificate(certPEM []byte) (*x509.Certificate, error) {
 	return x509.ParseCertificate(block.Bytes)
 }
 
-// CollectDomainStatus 收集单个域名的证书状态
+// CollectDomainStatus 收集单个域名的证书状态，文件名取自 DomainDirFileNames
 func CollectDomainStatus(baseDir, domain string) DomainStatus {
 	domainDir := filepath.Join(baseDir, domain)
+	names := DomainDirFileNames(domainDir, domain)
 	status := DomainStatus{Domain: domain}
 
 	// 检查 time.log
-	timeLogPath := filepath.Join(domainDir, "time.log")
+	timeLogPath := filepath.Join(domainDir, names.TimeLog)
 	if content, err := os.ReadFile(timeLogPath); err == nil {
-		ts := strings.TrimSpace(string(content))
-		if len(ts) >= 10 {
-			ts = ts[:10] // 只取前10位
-		}
-		if t, err := strconv.ParseInt(ts, 10, 64); err == nil {
-			status.LastUpdate = t
-		}
+		status.LastUpdate, _ = ParseTimeLog(content)
 	}
 
 	// 检查 cert.pem
-	certPath := filepath.Join(domainDir, "cert.pem")
+	certPath := filepath.Join(domainDir, names.Cert)
 	if info, err := os.Stat(certPath); err == nil {
 		status.HasCert = true
 		status.CertSize = info.Size()
@@ -71,33 +68,27 @@ func CollectDomainStatus(baseDir, domain string) DomainStatus {
 		if status.CertSize > 0 {
 			if certData, err := os.ReadFile(certPath); err == nil {
 				if cert, err := ParseCertificate(certData); err == nil {
-					status.NotBefore = cert.NotBefore.Unix()
-					status.NotAfter = cert.NotAfter.Unix()
-					status.DaysRemaining = int(time.Until(cert.NotAfter).Hours() / 24)
-					status.Subject = cert.Subject.CommonName
-					// 获取颁发者信息
-					if cert.Issuer.CommonName != "" {
-						status.Issuer = cert.Issuer.CommonName
-					} else if len(cert.Issuer.Organization) > 0 {
-						status.Issuer = cert.Issuer.Organization[0]
-					}
+					fillCertInfo(&status, cert)
 				}
 			}
 		}
 	}
 
 	// 检查 key.pem
-	keyPath := filepath.Join(domainDir, "key.pem")
+	keyPath := filepath.Join(domainDir, names.Key)
 	if info, err := os.Stat(keyPath); err == nil {
 		status.HasKey = true
 		status.KeySize = info.Size()
 	}
 
-	// 检查 fullchain.pem
-	fullchainPath := filepath.Join(domainDir, "fullchain.pem")
+	// 检查 fullchain.pem，不存在时使用 certbot 的 chain.pem（仅含中间证书）
+	fullchainPath := filepath.Join(domainDir, names.Fullchain)
 	if info, err := os.Stat(fullchainPath); err == nil {
 		status.HasFullchain = true
 		status.FullchainSize = info.Size()
+	} else if info, err := os.Stat(filepath.Join(domainDir, ChainFileName)); err == nil && !info.IsDir() {
+		status.HasFullchain = true
+		status.FullchainSize = info.Size()
 	}
 
 	// 判定整体有效性：三个文件都存在且非空
@@ -116,19 +107,74 @@ func CollectDomainStatus(baseDir, domain string) DomainStatus {
 	return status
 }
 
-// CollectAllDomainStatus 收集目录下所有域名的证书状态
-func CollectAllDomainStatus(baseDir string) []DomainStatus {
-	entries, err := os.ReadDir(baseDir)
-	if err != nil {
-		return nil
+// StatusFromPEM 由内存中的证书文件生成域名状态（如 --export 导出的证书），lastUpdate 为证书时间戳
+// 文件内容为空视为文件不存在
+func StatusFromPEM(domain string, certPEM, keyPEM, fullchainPEM []byte, lastUpdate int64) DomainStatus {
+	status := DomainStatus{
+		Domain:        domain,
+		LastUpdate:    lastUpdate,
+		HasCert:       len(certPEM) > 0,
+		HasKey:        len(keyPEM) > 0,
+		HasFullchain:  len(fullchainPEM) > 0,
+		CertSize:      int64(len(certPEM)),
+		KeySize:       int64(len(keyPEM)),
+		FullchainSize: int64(len(fullchainPEM)),
+	}
+	if cert, err := ParseCertificate(certPEM); err == nil {
+		fillCertInfo(&status, cert)
+	}
+	status.Valid = status.HasCert && status.HasKey && status.HasFullchain
+	if !status.Valid {
+		status.Error = "缺少必需文件"
+	}
+	return status
+}
+
+// fillCertInfo 填充证书的有效期、主题与颁发者
+func fillCertInfo(status *DomainStatus, cert *x509.Certificate) {
+	status.NotBefore = cert.NotBefore.Unix()
+	status.NotAfter = cert.NotAfter.Unix()
+	status.DaysRemaining = int(time.Until(cert.NotAfter).Hours() / 24)
+	status.Subject = cert.Subject.CommonName
+	// 获取颁发者信息
+	if cert.Issuer.CommonName != "" {
+		status.Issuer = cert.Issuer.CommonName
+	} else if len(cert.Issuer.Organization) > 0 {
+		status.Issuer = cert.Issuer.Organization[0]
 	}
+}
 
+// CollectAllDomainStatus 收集各证书目录下所有域名的证书状态，结果按域名排序
+// 同一域名存在于多个目录时只收集排在前面的目录（与 FindDomainDir 的优先级一致），无法读取的目录跳过
+func CollectAllDomainStatus(baseDirs []string) []DomainStatus {
 	var domains []DomainStatus
-	for _, entry := range entries {
-		if !entry.IsDir() {
+	seen := make(map[string]bool)
+	for _, baseDir := range baseDirs {
+		entries, err := os.ReadDir(baseDir)
+		if err != nil {
 			continue
 		}
-		domains = append(domains, CollectDomainStatus(baseDir, entry.Name()))
+		for _, entry := range entries {
+			// 跳过文件与隐藏目录（如归档目录 .archive）
+			if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || seen[entry.Name()] {
+				continue
+			}
+			seen[entry.Name()] = true
+			domains = append(domains, CollectDomainStatus(baseDir, entry.Name()))
+		}
 	}
+	sort.Slice(domains, func(i, j int) bool { return domains[i].Domain < domains[j].Domain })
 	return domains
 }
+
+// FindDomainDir 按顺序在各证书目录中查找域名目录，返回第一个存在的目录
+// 同一域名存在于多个目录时以排在前面的目录为准，后面目录中的同名域名被忽略
+func FindDomainDir(baseDirs []string, domain string) (string, bool) {
+	for _, baseDir := range baseDirs {
+		dir := filepath.Join(baseDir, domain)
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir, true
+		}
+	}
+	return "", false
+}
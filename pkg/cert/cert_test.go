@@ -1,64 +1,22 @@
 package cert
 
 import (
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
-	"crypto/x509"
-	"crypto/x509/pkix"
 	"encoding/pem"
-	"math/big"
 	"os"
 	"path/filepath"
 	"strconv"
 	"testing"
 	"time"
-)
-
-// ============================================
-// 辅助函数 - 生成测试证书
-// ============================================
 
-// generateTestCert 生成测试用的自签名证书
-func generateTestCert(notBefore, notAfter time.Time, cn string, issuerOrg string) ([]byte, error) {
-	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-	if err != nil {
-		return nil, err
-	}
-
-	template := &x509.Certificate{
-		SerialNumber: big.NewInt(1),
-		Subject: pkix.Name{
-			CommonName: cn,
-		},
-		Issuer: pkix.Name{
-			CommonName:   issuerOrg,
-			Organization: []string{issuerOrg},
-		},
-		NotBefore:             notBefore,
-		NotAfter:              notAfter,
-		KeyUsage:              x509.KeyUsageDigitalSignature,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
-		BasicConstraintsValid: true,
-	}
-
-	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
-	if err != nil {
-		return nil, err
-	}
-
-	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), nil
-}
+	"github.com/Catker/acmeDeliver/pkg/testutil"
+)
 
 // ============================================
 // ParseCertificate 测试
 // ============================================
 
 func TestParseCertificate_Valid(t *testing.T) {
-	notBefore := time.Now()
-	notAfter := notBefore.Add(365 * 24 * time.Hour)
-
-	certPEM, err := generateTestCert(notBefore, notAfter, "example.com", "Test CA")
+	certPEM, _, err := testutil.GenerateSelfSignedCert("example.com", 365*24*time.Hour)
 	if err != nil {
 		t.Fatalf("生成测试证书失败: %v", err)
 	}
@@ -109,9 +67,7 @@ func TestCollectDomainStatus_Complete(t *testing.T) {
 	}
 
 	// 生成测试证书
-	notBefore := time.Now()
-	notAfter := notBefore.Add(90 * 24 * time.Hour)
-	certPEM, err := generateTestCert(notBefore, notAfter, domain, "Let's Encrypt")
+	certPEM, _, err := testutil.GenerateSelfSignedCert(domain, 90*24*time.Hour)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -162,6 +118,61 @@ func TestCollectDomainStatus_Complete(t *testing.T) {
 	}
 }
 
+func TestCollectDomainStatus_Certbot(t *testing.T) {
+	domain := "example.com"
+	certPEM, _, err := testutil.GenerateSelfSignedCert(domain, 90*24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		files       map[string]string
+		wantKeySize int64
+	}{
+		{
+			"certbot 目录",
+			map[string]string{"cert.pem": string(certPEM), "privkey.pem": "private key", "chain.pem": "chain", "fullchain.pem": string(certPEM)},
+			int64(len("private key")),
+		},
+		{
+			"缺少 fullchain.pem 时使用 chain.pem",
+			map[string]string{"cert.pem": string(certPEM), "privkey.pem": "private key", "chain.pem": "chain"},
+			int64(len("private key")),
+		},
+		{
+			"同时存在时优先 key.pem",
+			map[string]string{"cert.pem": string(certPEM), "key.pem": "key", "privkey.pem": "private key", "fullchain.pem": string(certPEM)},
+			int64(len("key")),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			domainDir := filepath.Join(tmpDir, domain)
+			if err := os.MkdirAll(domainDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(domainDir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			status := CollectDomainStatus(tmpDir, domain)
+			if !status.HasKey || !status.HasFullchain || !status.Valid {
+				t.Errorf("HasKey = %v, HasFullchain = %v, Valid = %v, want all true (error %q)", status.HasKey, status.HasFullchain, status.Valid, status.Error)
+			}
+			if status.KeySize != tt.wantKeySize {
+				t.Errorf("KeySize = %d, want %d", status.KeySize, tt.wantKeySize)
+			}
+			if status.Subject != domain {
+				t.Errorf("Subject = %q, want %q", status.Subject, domain)
+			}
+		})
+	}
+}
+
 func TestCollectDomainStatus_MissingFiles(t *testing.T) {
 	tmpDir := t.TempDir()
 	domain := "missing.com"
@@ -228,7 +239,7 @@ func TestCollectDomainStatus_NonExistentDomain(t *testing.T) {
 
 func TestCollectAllDomainStatus_Empty(t *testing.T) {
 	tmpDir := t.TempDir()
-	statuses := CollectAllDomainStatus(tmpDir)
+	statuses := CollectAllDomainStatus([]string{tmpDir})
 
 	if len(statuses) != 0 {
 		t.Errorf("期望空切片，得到 %d 个元素", len(statuses))
@@ -255,7 +266,7 @@ func TestCollectAllDomainStatus_MultipleDomains(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	statuses := CollectAllDomainStatus(tmpDir)
+	statuses := CollectAllDomainStatus([]string{tmpDir})
 
 	if len(statuses) != len(domains) {
 		t.Errorf("期望 %d 个域名，得到 %d 个", len(domains), len(statuses))
@@ -263,7 +274,7 @@ func TestCollectAllDomainStatus_MultipleDomains(t *testing.T) {
 }
 
 func TestCollectAllDomainStatus_InvalidDir(t *testing.T) {
-	statuses := CollectAllDomainStatus("/nonexistent/path/12345")
+	statuses := CollectAllDomainStatus([]string{"/nonexistent/path/12345"})
 
 	if statuses != nil {
 		t.Error("期望返回 nil，实际返回非空切片")
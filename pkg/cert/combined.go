@@ -0,0 +1,64 @@
+package cert
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// DefaultCombinedOrder 未设置 combined_order 时合并文件的组成顺序（HAProxy 的 crt 格式）
+const DefaultCombinedOrder = "fullchain,key"
+
+// 合并文件可用的组成部分
+const (
+	CombinedCert      = "cert"
+	CombinedKey       = "key"
+	CombinedFullchain = "fullchain"
+)
+
+// ParseCombinedOrder 解析逗号分隔的组成顺序（如 "fullchain,key"、"key,cert"），空字符串使用 DefaultCombinedOrder
+// 只允许 cert、key、fullchain，且不能重复
+func ParseCombinedOrder(order string) ([]string, error) {
+	if strings.TrimSpace(order) == "" {
+		order = DefaultCombinedOrder
+	}
+	var parts []string
+	for _, p := range strings.Split(order, ",") {
+		p = strings.TrimSpace(p)
+		switch p {
+		case CombinedCert, CombinedKey, CombinedFullchain:
+		default:
+			return nil, fmt.Errorf("不支持的组成部分 %q（可选 cert、key、fullchain）", p)
+		}
+		for _, seen := range parts {
+			if seen == p {
+				return nil, fmt.Errorf("组成部分 %q 重复", p)
+			}
+		}
+		parts = append(parts, p)
+	}
+	return parts, nil
+}
+
+// CombinePEM 按 order 依次拼接证书、私钥与证书链（PEM），用于 HAProxy 等读取单个文件的程序
+// 各部分原样拼接，不以换行结尾的部分补一个换行；任一组成部分为空时返回错误，不生成不完整的文件
+func CombinePEM(order string, certPEM, keyPEM, fullchainPEM []byte) ([]byte, error) {
+	parts, err := ParseCombinedOrder(order)
+	if err != nil {
+		return nil, err
+	}
+	contents := map[string][]byte{CombinedCert: certPEM, CombinedKey: keyPEM, CombinedFullchain: fullchainPEM}
+
+	var buf bytes.Buffer
+	for _, p := range parts {
+		content := contents[p]
+		if len(bytes.TrimSpace(content)) == 0 {
+			return nil, fmt.Errorf("合并文件的组成部分 %s 内容为空", p)
+		}
+		buf.Write(content)
+		if content[len(content)-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes(), nil
+}
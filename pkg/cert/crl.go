@@ -0,0 +1,275 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MaxCRLSize 单个 CRL 的最大下载大小（10 MB）
+const MaxCRLSize = 10 << 20
+
+// DefaultCRLCacheTTL CRL 缓存的默认有效期
+const DefaultCRLCacheTTL = time.Hour
+
+// ErrNoCRLDistributionPoints 证书未包含 CRL 分发点，无法通过 CRL 检查吊销状态
+var ErrNoCRLDistributionPoints = errors.New("证书未包含 CRL 分发点")
+
+// ErrCRLUnverified 证书链中没有颁发者证书，无法校验 CRL 签名，CRL 未列出该证书时不能据此判定未吊销
+var ErrCRLUnverified = errors.New("缺少颁发者证书，无法校验 CRL 签名，请检查 fullchain")
+
+// crlEntry 已下载并解析的 CRL
+type crlEntry struct {
+	revoked    map[string]struct{} // 已吊销证书序列号（十进制字符串）
+	nextUpdate time.Time           // CRL 的 NextUpdate，为零值表示未指定
+	fetchedAt  atomic.Int64        // 最近一次下载或确认未变化的时间（Unix 纳秒）
+}
+
+// expired 判断 CRL 是否已超过 NextUpdate
+func (e *crlEntry) expired(now time.Time) bool {
+	return !e.nextUpdate.IsZero() && now.After(e.nextUpdate)
+}
+
+var (
+	crlCacheTTL atomic.Int64 // 缓存有效期（纳秒），0 表示使用默认值
+
+	// crlCache 缓存键+ETag -> *crlEntry，同一 URL 的 CRL 内容变化时 ETag 随之变化
+	crlCache sync.Map
+	// crlETags 缓存键 -> 当前缓存条目的 ETag，用于定位缓存与发送条件请求
+	crlETags sync.Map
+)
+
+// crlCacheKey 返回 CRL 的缓存键，包含校验签名所用的颁发者证书
+// 未校验签名的 CRL 与用不同颁发者校验的 CRL 分开缓存，不会被当作已校验的结果复用
+func crlCacheKey(url string, issuer *x509.Certificate) string {
+	if issuer == nil {
+		return url + "#unverified"
+	}
+	sum := sha256.Sum256(issuer.Raw)
+	return url + "#" + hex.EncodeToString(sum[:])
+}
+
+// SetCRLCacheTTL 设置 CRL 缓存有效期，ttl <= 0 时恢复默认值
+// 有效期内直接使用缓存；过期后携带 If-None-Match 重新请求，服务器返回 304 时沿用缓存
+func SetCRLCacheTTL(ttl time.Duration) {
+	if ttl < 0 {
+		ttl = 0
+	}
+	crlCacheTTL.Store(int64(ttl))
+}
+
+// CRLCacheTTL 返回当前 CRL 缓存有效期
+func CRLCacheTTL() time.Duration {
+	if ttl := time.Duration(crlCacheTTL.Load()); ttl > 0 {
+		return ttl
+	}
+	return DefaultCRLCacheTTL
+}
+
+// CheckCRL 通过证书中的 CRL 分发点检查证书是否已被吊销
+// certPEM 的第一个证书为待检查证书；包含完整证书链时会用颁发者证书校验 CRL 签名
+// 任一分发点的 CRL 列出该序列号即视为已吊销；所有分发点都获取失败时返回错误
+// 缺少颁发者证书时 CRL 未经签名校验，未列出该序列号时返回 ErrCRLUnverified 而不是判定未吊销
+func CheckCRL(certPEM []byte, httpTimeout time.Duration) (bool, error) {
+	certs, err := parseCertificateChain(certPEM)
+	if err != nil {
+		return false, err
+	}
+	leaf := certs[0]
+	if len(leaf.CRLDistributionPoints) == 0 {
+		return false, ErrNoCRLDistributionPoints
+	}
+
+	var issuer *x509.Certificate
+	for _, c := range certs[1:] {
+		if bytes.Equal(c.RawSubject, leaf.RawIssuer) {
+			issuer = c
+			break
+		}
+	}
+
+	client := &http.Client{Timeout: httpTimeout}
+	serial := leaf.SerialNumber.String()
+	var errs []error
+	checked := false
+	for _, url := range leaf.CRLDistributionPoints {
+		entry, err := fetchCRL(client, url, issuer)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", url, err))
+			continue
+		}
+		checked = true
+		if _, ok := entry.revoked[serial]; ok {
+			return true, nil
+		}
+	}
+	if !checked {
+		return false, fmt.Errorf("获取 CRL 失败: %w", errors.Join(errs...))
+	}
+	if issuer == nil {
+		return false, ErrCRLUnverified
+	}
+	return false, nil
+}
+
+// CheckDomainStatusCRL 对已收集的域名证书状态执行 CRL 检查，结果写入 Revoked / CRLError
+// 优先读取 fullchain.pem，以便用颁发者证书校验 CRL 签名
+// 域名目录按 FindDomainDir 的优先级在 baseDirs 中查找
+func CheckDomainStatusCRL(baseDirs []string, statuses []DomainStatus, httpTimeout time.Duration) {
+	for i := range statuses {
+		s := &statuses[i]
+		if !s.HasCert || s.CertSize == 0 {
+			continue
+		}
+		domainDir, ok := FindDomainDir(baseDirs, s.Domain)
+		if !ok {
+			s.CRLError = "域名目录不存在"
+			continue
+		}
+		names := DomainDirFileNames(domainDir, s.Domain)
+		data, err := os.ReadFile(filepath.Join(domainDir, names.Fullchain))
+		if err != nil || len(data) == 0 {
+			data, err = os.ReadFile(filepath.Join(domainDir, names.Cert))
+		}
+		if err != nil {
+			s.CRLError = err.Error()
+			continue
+		}
+		revoked, err := CheckCRL(data, httpTimeout)
+		if err != nil {
+			s.CRLError = err.Error()
+			continue
+		}
+		s.Revoked = revoked
+	}
+}
+
+// parseCertificateChain 解析 PEM 中的全部证书
+func parseCertificateChain(certPEM []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := certPEM
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("解析证书失败: %w", err)
+		}
+		certs = append(certs, c)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("无效的 PEM 数据")
+	}
+	return certs, nil
+}
+
+// fetchCRL 获取 CRL，优先使用缓存；已超过 NextUpdate 的缓存条目不再使用
+func fetchCRL(client *http.Client, url string, issuer *x509.Certificate) (*crlEntry, error) {
+	key := crlCacheKey(url, issuer)
+	var cached *crlEntry
+	etag := ""
+	if v, ok := crlETags.Load(key); ok {
+		etag = v.(string)
+		if e, ok := crlCache.Load(key + etag); ok && !e.(*crlEntry).expired(time.Now()) {
+			cached = e.(*crlEntry)
+			if time.Since(time.Unix(0, cached.fetchedAt.Load())) < CRLCacheTTL() {
+				return cached, nil
+			}
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil && etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		cached.fetchedAt.Store(time.Now().UnixNano())
+		return cached, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxCRLSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取 CRL 失败: %w", err)
+	}
+	if len(data) > MaxCRLSize {
+		return nil, fmt.Errorf("CRL 超过 %d 字节上限", MaxCRLSize)
+	}
+
+	entry, err := parseCRL(data, issuer)
+	if err != nil {
+		return nil, err
+	}
+	entry.fetchedAt.Store(time.Now().UnixNano())
+
+	newETag := resp.Header.Get("ETag")
+	crlCache.Store(key+newETag, entry)
+	crlETags.Store(key, newETag)
+	if newETag != etag {
+		crlCache.Delete(key + etag)
+	}
+	return entry, nil
+}
+
+// parseCRL 解析 DER 或 PEM 格式的 CRL，issuer 非空时校验签名；已超过 NextUpdate 的 CRL 返回错误
+func parseCRL(data []byte, issuer *x509.Certificate) (*crlEntry, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN")) {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("无效的 CRL PEM 数据")
+		}
+		data = block.Bytes
+	}
+
+	list, err := x509.ParseRevocationList(data)
+	if err != nil {
+		return nil, fmt.Errorf("解析 CRL 失败: %w", err)
+	}
+	if issuer != nil {
+		if err := list.CheckSignatureFrom(issuer); err != nil {
+			return nil, fmt.Errorf("CRL 签名校验失败: %w", err)
+		}
+	}
+
+	if !list.NextUpdate.IsZero() && time.Now().After(list.NextUpdate) {
+		return nil, fmt.Errorf("CRL 已过期（next_update: %s）", list.NextUpdate.UTC().Format(time.RFC3339))
+	}
+
+	entry := &crlEntry{
+		revoked:    make(map[string]struct{}, len(list.RevokedCertificateEntries)),
+		nextUpdate: list.NextUpdate,
+	}
+	for _, rc := range list.RevokedCertificateEntries {
+		entry.revoked[rc.SerialNumber.String()] = struct{}{}
+	}
+	return entry, nil
+}
@@ -0,0 +1,354 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/testutil"
+)
+
+// testCA 测试用 CA，可签发证书和 CRL
+type testCA struct {
+	cert   *x509.Certificate
+	key    *ecdsa.PrivateKey
+	pem    []byte
+	keyPEM []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	caPEM, caKeyPEM, err := testutil.GenerateCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parseTestCA(t, caPEM, caKeyPEM)
+}
+
+// parseTestCA 由 testutil 生成的 CA 证书与私钥（PEM）构造 testCA
+func parseTestCA(t *testing.T, caPEM, caKeyPEM []byte) *testCA {
+	t.Helper()
+	certs, err := parseCertificateChain(caPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(caKeyPEM)
+	if block == nil {
+		t.Fatal("无效的 CA 私钥 PEM")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: certs[0], key: key.(*ecdsa.PrivateKey), pem: caPEM, keyPEM: caKeyPEM}
+}
+
+// issue 签发带 CRL 分发点的叶子证书，返回 PEM
+func (ca *testCA) issue(t *testing.T, serial int64, crlURLs ...string) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		CRLDistributionPoints: crlURLs,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// crl 生成吊销了指定序列号的 CRL（DER）
+func (ca *testCA) crl(t *testing.T, revoked ...int64) []byte {
+	t.Helper()
+	return ca.crlWithNextUpdate(t, time.Now().Add(time.Hour), revoked...)
+}
+
+// crlWithNextUpdate 生成指定 NextUpdate 的 CRL（DER）
+func (ca *testCA) crlWithNextUpdate(t *testing.T, nextUpdate time.Time, revoked ...int64) []byte {
+	t.Helper()
+	template := &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: nextUpdate.Add(-2 * time.Hour),
+		NextUpdate: nextUpdate,
+	}
+	for _, serial := range revoked {
+		template.RevokedCertificateEntries = append(template.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   big.NewInt(serial),
+			RevocationTime: time.Now().Add(-time.Minute),
+		})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, template, ca.cert, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+// crlServer 提供 CRL 下载，支持 ETag 条件请求并统计完整下载次数
+type crlServer struct {
+	*httptest.Server
+	body      atomic.Value // []byte
+	etag      atomic.Value // string
+	downloads atomic.Int32
+	notMod    atomic.Int32
+}
+
+func newCRLServer(t *testing.T, body []byte, etag string) *crlServer {
+	t.Helper()
+	s := &crlServer{}
+	s.body.Store(body)
+	s.etag.Store(etag)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := s.etag.Load().(string)
+		if etag != "" {
+			if r.Header.Get("If-None-Match") == etag {
+				s.notMod.Add(1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", etag)
+		}
+		s.downloads.Add(1)
+		w.Write(s.body.Load().([]byte))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// withCRLCacheTTL 临时修改缓存有效期
+func withCRLCacheTTL(t *testing.T, ttl time.Duration) {
+	t.Helper()
+	old := CRLCacheTTL()
+	SetCRLCacheTTL(ttl)
+	t.Cleanup(func() { SetCRLCacheTTL(old) })
+}
+
+func TestCheckCRL(t *testing.T) {
+	ca := newTestCA(t)
+	server := newCRLServer(t, ca.crl(t, 100, 200), "")
+
+	tests := []struct {
+		name    string
+		certPEM []byte
+		want    bool
+		wantErr error
+	}{
+		{"已吊销", ca.issue(t, 100, server.URL), true, nil},
+		{"无证书链时无法判定未吊销", ca.issue(t, 101, server.URL), false, ErrCRLUnverified},
+		{"包含证书链时校验签名", append(ca.issue(t, 200, server.URL), ca.pem...), true, nil},
+		{"校验签名后未吊销", append(ca.issue(t, 201, server.URL), ca.pem...), false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CheckCRL(tt.certPEM, 5*time.Second)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CheckCRL() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("CheckCRL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckCRLPEMEncoded(t *testing.T) {
+	ca := newTestCA(t)
+	crlPEM := pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: ca.crl(t, 7)})
+	server := newCRLServer(t, crlPEM, "")
+
+	revoked, err := CheckCRL(ca.issue(t, 7, server.URL), 5*time.Second)
+	if err != nil || !revoked {
+		t.Errorf("CheckCRL() = %v, %v; want true, nil", revoked, err)
+	}
+}
+
+func TestCheckCRLErrors(t *testing.T) {
+	ca := newTestCA(t)
+	otherCA := newTestCA(t)
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+	}))
+	defer slow.Close()
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+	oversized := newCRLServer(t, bytes.Repeat([]byte{0x30}, MaxCRLSize+1), "")
+	garbage := newCRLServer(t, []byte("not a crl"), "")
+	forged := newCRLServer(t, otherCA.crl(t, 1), "")
+	expired := newCRLServer(t, ca.crlWithNextUpdate(t, time.Now().Add(-time.Minute)), "")
+
+	tests := []struct {
+		name    string
+		certPEM []byte
+		timeout time.Duration
+	}{
+		{"无效 PEM", []byte("garbage"), time.Second},
+		{"超时", ca.issue(t, 1, slow.URL), 100 * time.Millisecond},
+		{"HTTP 错误", ca.issue(t, 1, notFound.URL), time.Second},
+		{"超过大小上限", ca.issue(t, 1, oversized.URL), 5 * time.Second},
+		{"无法解析", ca.issue(t, 1, garbage.URL), time.Second},
+		{"签名不匹配", append(ca.issue(t, 1, forged.URL), ca.pem...), time.Second},
+		{"已超过 NextUpdate", append(ca.issue(t, 1, expired.URL), ca.pem...), time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			revoked, err := CheckCRL(tt.certPEM, tt.timeout)
+			if err == nil {
+				t.Fatal("CheckCRL() 应返回错误")
+			}
+			if revoked {
+				t.Error("出错时不应报告已吊销")
+			}
+		})
+	}
+
+	if _, err := CheckCRL(ca.issue(t, 1), time.Second); !errors.Is(err, ErrNoCRLDistributionPoints) {
+		t.Errorf("无分发点 error = %v, want ErrNoCRLDistributionPoints", err)
+	}
+}
+
+func TestCheckCRLFallsBackToNextDistributionPoint(t *testing.T) {
+	ca := newTestCA(t)
+	down := httptest.NewServer(http.NotFoundHandler())
+	defer down.Close()
+	server := newCRLServer(t, ca.crl(t, 9), "")
+
+	revoked, err := CheckCRL(ca.issue(t, 9, down.URL, server.URL), 5*time.Second)
+	if err != nil || !revoked {
+		t.Errorf("CheckCRL() = %v, %v; want true, nil", revoked, err)
+	}
+}
+
+func TestCheckCRLCache(t *testing.T) {
+	ca := newTestCA(t)
+	server := newCRLServer(t, ca.crl(t), `"v1"`)
+	certPEM := append(ca.issue(t, 5, server.URL), ca.pem...)
+
+	// 有效期内只下载一次
+	withCRLCacheTTL(t, time.Hour)
+	for i := 0; i < 3; i++ {
+		if _, err := CheckCRL(certPEM, 5*time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := server.downloads.Load(); n != 1 {
+		t.Fatalf("有效期内下载次数 = %d, want 1", n)
+	}
+
+	// 过期后发送条件请求，ETag 未变化时沿用缓存
+	SetCRLCacheTTL(time.Nanosecond)
+	if _, err := CheckCRL(certPEM, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if server.downloads.Load() != 1 || server.notMod.Load() != 1 {
+		t.Fatalf("downloads = %d, not modified = %d, want 1, 1", server.downloads.Load(), server.notMod.Load())
+	}
+
+	// CRL 更新后 ETag 变化，重新下载并识别新的吊销
+	server.body.Store(ca.crl(t, 5))
+	server.etag.Store(`"v2"`)
+	revoked, err := CheckCRL(certPEM, 5*time.Second)
+	if err != nil || !revoked {
+		t.Fatalf("CRL 更新后 CheckCRL() = %v, %v; want true, nil", revoked, err)
+	}
+	if n := server.downloads.Load(); n != 2 {
+		t.Errorf("CRL 更新后下载次数 = %d, want 2", n)
+	}
+	if _, ok := crlCache.Load(crlCacheKey(server.URL, ca.cert) + `"v1"`); ok {
+		t.Error("旧 ETag 的缓存条目应被删除")
+	}
+
+	// 缓存的 CRL 超过 NextUpdate 后即使仍在有效期内也重新下载（不发送条件请求）
+	SetCRLCacheTTL(time.Hour)
+	e, ok := crlCache.Load(crlCacheKey(server.URL, ca.cert) + `"v2"`)
+	if !ok {
+		t.Fatal("缺少 v2 缓存条目")
+	}
+	e.(*crlEntry).nextUpdate = time.Now().Add(-time.Second)
+	if revoked, err := CheckCRL(certPEM, 5*time.Second); err != nil || !revoked {
+		t.Fatalf("缓存过期后 CheckCRL() = %v, %v; want true, nil", revoked, err)
+	}
+	if n := server.downloads.Load(); n != 3 {
+		t.Errorf("缓存超过 NextUpdate 后下载次数 = %d, want 3", n)
+	}
+}
+
+func TestCheckCRLCacheSeparatesUnverified(t *testing.T) {
+	withCRLCacheTTL(t, time.Hour)
+	ca := newTestCA(t)
+	otherCA := newTestCA(t)
+	server := newCRLServer(t, otherCA.crl(t), "")
+
+	// 未校验签名时下载的伪造 CRL 不会被当作已校验的结果复用
+	if _, err := CheckCRL(ca.issue(t, 5, server.URL), 5*time.Second); !errors.Is(err, ErrCRLUnverified) {
+		t.Fatalf("无证书链 CheckCRL() error = %v, want ErrCRLUnverified", err)
+	}
+	if _, err := CheckCRL(append(ca.issue(t, 5, server.URL), ca.pem...), 5*time.Second); err == nil {
+		t.Error("包含证书链时应校验 CRL 签名并返回错误")
+	}
+	if n := server.downloads.Load(); n != 2 {
+		t.Errorf("下载次数 = %d, want 2", n)
+	}
+}
+
+func TestCheckDomainStatusCRL(t *testing.T) {
+	ca := newTestCA(t)
+	server := newCRLServer(t, ca.crl(t, 42), "")
+
+	baseDir := t.TempDir()
+	write := func(domain string, serial int64, urls ...string) {
+		dir := filepath.Join(baseDir, domain)
+		os.MkdirAll(dir, 0755)
+		leaf := ca.issue(t, serial, urls...)
+		os.WriteFile(filepath.Join(dir, "cert.pem"), leaf, 0644)
+		os.WriteFile(filepath.Join(dir, "key.pem"), []byte("key"), 0600)
+		os.WriteFile(filepath.Join(dir, "fullchain.pem"), append(leaf, ca.pem...), 0644)
+	}
+	write("revoked.com", 42, server.URL)
+	write("good.com", 43, server.URL)
+	write("nocrl.com", 44)
+	write("nochain.com", 45, server.URL)
+	os.WriteFile(filepath.Join(baseDir, "nochain.com", "fullchain.pem"), nil, 0644)
+
+	statuses := CollectAllDomainStatus([]string{baseDir})
+	CheckDomainStatusCRL([]string{baseDir}, statuses, 5*time.Second)
+
+	got := make(map[string]DomainStatus)
+	for _, s := range statuses {
+		got[s.Domain] = s
+	}
+	if !got["revoked.com"].Revoked || got["revoked.com"].CRLError != "" {
+		t.Errorf("revoked.com = %+v, want Revoked", got["revoked.com"])
+	}
+	if got["good.com"].Revoked || got["good.com"].CRLError != "" {
+		t.Errorf("good.com = %+v, want not revoked", got["good.com"])
+	}
+	if got["nocrl.com"].Revoked || got["nocrl.com"].CRLError == "" {
+		t.Errorf("nocrl.com = %+v, want CRLError", got["nocrl.com"])
+	}
+	if got["nochain.com"].Revoked || got["nochain.com"].CRLError != ErrCRLUnverified.Error() {
+		t.Errorf("nochain.com = %+v, want CRLError %q", got["nochain.com"], ErrCRLUnverified)
+	}
+}
@@ -0,0 +1,344 @@
+package cert
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCTLogListURL 未配置 ct_log_urls 时使用的 CT 日志列表（Google v3 格式）
+const DefaultCTLogListURL = "https://www.gstatic.com/ct/log_list/v3/log_list.json"
+
+// maxCTLogListSize CT 日志列表的最大下载大小（5 MB）
+const maxCTLogListSize = 5 << 20
+
+// ctHTTPTimeout 下载 CT 日志列表的超时
+var ctHTTPTimeout = 30 * time.Second
+
+// oidSCTList 证书中内嵌 SCT 列表的扩展（RFC 6962 3.3）
+var oidSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+var (
+	// ErrNoSCT 证书未内嵌 SCT，无法证明已提交到 CT 日志
+	ErrNoSCT = errors.New("证书未包含 SCT（签名证书时间戳）扩展")
+	// ErrCTVerificationFailed 证书的 SCT 均未通过已知 CT 日志的签名校验
+	ErrCTVerificationFailed = errors.New("CT 日志校验失败")
+)
+
+// ctVerified 已通过 CT 校验的证书指纹，同一证书不重复下载日志列表与校验
+var ctVerified sync.Map
+
+// signedCertificateTimestamp 解码后的 SCT（RFC 6962 3.2）
+type signedCertificateTimestamp struct {
+	logID      [32]byte
+	timestamp  uint64
+	extensions []byte
+	hashAlg    byte
+	sigAlg     byte
+	signature  []byte
+}
+
+// VerifyCTLog 校验证书内嵌的 SCT 至少有一个由 logURLs 中列出的 CT 日志签发且签名有效
+// certPEM 的第一个证书为待校验证书，之后须包含其颁发者证书（如 fullchain.pem），用于计算 SCT 签名中的颁发者公钥哈希
+// logURLs 为 Google v3 格式的日志列表（http(s) URL 或本地文件路径），为空时使用 DefaultCTLogListURL
+// 校验通过的证书按 SHA-256 指纹缓存，之后不再重复校验
+func VerifyCTLog(certPEM []byte, logURLs []string) error {
+	certs, err := parseCertificateChain(certPEM)
+	if err != nil {
+		return err
+	}
+	leaf := certs[0]
+	fingerprint := sha256.Sum256(leaf.Raw)
+	if _, ok := ctVerified.Load(fingerprint); ok {
+		return nil
+	}
+
+	var sctExt []byte
+	for _, ext := range leaf.Extensions {
+		if ext.Id.Equal(oidSCTList) {
+			sctExt = ext.Value
+			break
+		}
+	}
+	if sctExt == nil {
+		return ErrNoSCT
+	}
+	scts, err := parseSCTList(sctExt)
+	if err != nil {
+		return fmt.Errorf("%w: 解析 SCT 失败: %v", ErrCTVerificationFailed, err)
+	}
+
+	var issuer *x509.Certificate
+	for _, c := range certs[1:] {
+		if bytes.Equal(c.RawSubject, leaf.RawIssuer) {
+			issuer = c
+			break
+		}
+	}
+	if issuer == nil {
+		return fmt.Errorf("%w: 缺少颁发者证书，请使用包含证书链的 fullchain", ErrCTVerificationFailed)
+	}
+	tbs, err := removeTBSExtension(leaf.RawTBSCertificate, oidSCTList)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCTVerificationFailed, err)
+	}
+
+	if len(logURLs) == 0 {
+		logURLs = []string{DefaultCTLogListURL}
+	}
+	logs, err := loadCTLogs(logURLs)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCTVerificationFailed, err)
+	}
+
+	issuerKeyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+	var failures []string
+	for _, sct := range scts {
+		pub, ok := logs[sct.logID]
+		if !ok {
+			failures = append(failures, fmt.Sprintf("日志 %s 不在已知日志列表中", base64.StdEncoding.EncodeToString(sct.logID[:])))
+			continue
+		}
+		if err := verifySCTSignature(pub, sct, precertSignedData(sct, issuerKeyHash, tbs)); err != nil {
+			failures = append(failures, fmt.Sprintf("日志 %s 的签名无效: %v", base64.StdEncoding.EncodeToString(sct.logID[:]), err))
+			continue
+		}
+		ctVerified.Store(fingerprint, struct{}{})
+		return nil
+	}
+	return fmt.Errorf("%w: %d 个 SCT 均未通过校验（%s）", ErrCTVerificationFailed, len(scts), strings.Join(failures, "；"))
+}
+
+// parseSCTList 解码扩展值中的 SignedCertificateTimestampList（OCTET STRING 包裹的 TLS 编码）
+func parseSCTList(extValue []byte) ([]signedCertificateTimestamp, error) {
+	var raw []byte
+	if rest, err := asn1.Unmarshal(extValue, &raw); err != nil || len(rest) > 0 {
+		return nil, errors.New("扩展不是 OCTET STRING")
+	}
+	list, rest, ok := readOpaque16(raw)
+	if !ok || len(rest) > 0 {
+		return nil, errors.New("SCT 列表长度不正确")
+	}
+
+	var scts []signedCertificateTimestamp
+	for len(list) > 0 {
+		var data []byte
+		if data, list, ok = readOpaque16(list); !ok {
+			return nil, errors.New("SCT 长度不正确")
+		}
+		sct, err := parseSCT(data)
+		if err != nil {
+			return nil, err
+		}
+		scts = append(scts, sct)
+	}
+	if len(scts) == 0 {
+		return nil, errors.New("SCT 列表为空")
+	}
+	return scts, nil
+}
+
+// parseSCT 解码单个 v1 SCT
+func parseSCT(data []byte) (signedCertificateTimestamp, error) {
+	var sct signedCertificateTimestamp
+	if len(data) < 1+32+8 || data[0] != 0 {
+		return sct, errors.New("不支持的 SCT 版本或长度不正确")
+	}
+	copy(sct.logID[:], data[1:33])
+	sct.timestamp = binary.BigEndian.Uint64(data[33:41])
+
+	var ok bool
+	rest := data[41:]
+	if sct.extensions, rest, ok = readOpaque16(rest); !ok || len(rest) < 2 {
+		return sct, errors.New("SCT 扩展长度不正确")
+	}
+	sct.hashAlg, sct.sigAlg = rest[0], rest[1]
+	if sct.signature, rest, ok = readOpaque16(rest[2:]); !ok || len(rest) > 0 {
+		return sct, errors.New("SCT 签名长度不正确")
+	}
+	return sct, nil
+}
+
+// readOpaque16 读取以 2 字节长度为前缀的数据
+func readOpaque16(b []byte) (data, rest []byte, ok bool) {
+	if len(b) < 2 {
+		return nil, nil, false
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return nil, nil, false
+	}
+	return b[2 : 2+n], b[2+n:], true
+}
+
+// precertSignedData 构造内嵌 SCT 的签名数据（RFC 6962 3.2 digitally-signed 结构，entry_type 为 precert_entry）
+func precertSignedData(sct signedCertificateTimestamp, issuerKeyHash [32]byte, tbs []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(0) // sct_version v1
+	buf.WriteByte(0) // signature_type certificate_timestamp
+	binary.Write(&buf, binary.BigEndian, sct.timestamp)
+	binary.Write(&buf, binary.BigEndian, uint16(1)) // entry_type precert_entry
+	buf.Write(issuerKeyHash[:])
+	buf.Write([]byte{byte(len(tbs) >> 16), byte(len(tbs) >> 8), byte(len(tbs))})
+	buf.Write(tbs)
+	binary.Write(&buf, binary.BigEndian, uint16(len(sct.extensions)))
+	buf.Write(sct.extensions)
+	return buf.Bytes()
+}
+
+// verifySCTSignature 以日志公钥校验 SCT 签名，只支持 SHA-256 的 ECDSA 与 RSA 签名
+func verifySCTSignature(pub crypto.PublicKey, sct signedCertificateTimestamp, signed []byte) error {
+	const (
+		hashSHA256 = 4
+		sigRSA     = 1
+		sigECDSA   = 3
+	)
+	if sct.hashAlg != hashSHA256 {
+		return fmt.Errorf("不支持的哈希算法 %d", sct.hashAlg)
+	}
+	digest := sha256.Sum256(signed)
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		if sct.sigAlg != sigECDSA || !ecdsa.VerifyASN1(key, digest[:], sct.signature) {
+			return errors.New("ECDSA 签名校验失败")
+		}
+	case *rsa.PublicKey:
+		if sct.sigAlg != sigRSA {
+			return errors.New("签名算法与日志公钥不匹配")
+		}
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sct.signature); err != nil {
+			return errors.New("RSA 签名校验失败")
+		}
+	default:
+		return fmt.Errorf("不支持的日志公钥类型 %T", pub)
+	}
+	return nil
+}
+
+// removeTBSExtension 返回删除指定扩展后重新编码的 TBSCertificate（签发 SCT 时的预证书内容）
+func removeTBSExtension(rawTBS []byte, oid asn1.ObjectIdentifier) ([]byte, error) {
+	var tbs asn1.RawValue
+	if _, err := asn1.Unmarshal(rawTBS, &tbs); err != nil {
+		return nil, fmt.Errorf("解析 TBSCertificate 失败: %w", err)
+	}
+
+	var out []byte
+	for rest := tbs.Bytes; len(rest) > 0; {
+		var field asn1.RawValue
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &field); err != nil {
+			return nil, fmt.Errorf("解析 TBSCertificate 失败: %w", err)
+		}
+		// extensions [3] EXPLICIT SEQUENCE OF Extension
+		if field.Class != asn1.ClassContextSpecific || field.Tag != 3 {
+			out = append(out, field.FullBytes...)
+			continue
+		}
+		var exts asn1.RawValue
+		if _, err := asn1.Unmarshal(field.Bytes, &exts); err != nil {
+			return nil, fmt.Errorf("解析证书扩展失败: %w", err)
+		}
+		var kept []byte
+		for extRest := exts.Bytes; len(extRest) > 0; {
+			var ext asn1.RawValue
+			if extRest, err = asn1.Unmarshal(extRest, &ext); err != nil {
+				return nil, fmt.Errorf("解析证书扩展失败: %w", err)
+			}
+			var id asn1.ObjectIdentifier
+			if _, err := asn1.Unmarshal(ext.Bytes, &id); err == nil && id.Equal(oid) {
+				continue
+			}
+			kept = append(kept, ext.FullBytes...)
+		}
+		seq, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true, Bytes: kept})
+		if err != nil {
+			return nil, err
+		}
+		wrapped, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 3, IsCompound: true, Bytes: seq})
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, wrapped...)
+	}
+	return asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true, Bytes: out})
+}
+
+// ctLogList Google v3 格式的 CT 日志列表，只解析校验所需的字段
+type ctLogList struct {
+	Operators []struct {
+		Logs []struct {
+			Key string `json:"key"` // base64 编码的 DER 公钥
+		} `json:"logs"`
+	} `json:"operators"`
+}
+
+// loadCTLogs 读取日志列表，返回 日志 ID（公钥 DER 的 SHA-256）-> 公钥
+func loadCTLogs(sources []string) (map[[32]byte]crypto.PublicKey, error) {
+	logs := make(map[[32]byte]crypto.PublicKey)
+	for _, src := range sources {
+		data, err := readCTLogList(src)
+		if err != nil {
+			return nil, fmt.Errorf("读取 CT 日志列表 %s 失败: %w", src, err)
+		}
+		var list ctLogList
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, fmt.Errorf("解析 CT 日志列表 %s 失败: %w", src, err)
+		}
+		for _, op := range list.Operators {
+			for _, l := range op.Logs {
+				der, err := base64.StdEncoding.DecodeString(l.Key)
+				if err != nil {
+					continue
+				}
+				pub, err := x509.ParsePKIXPublicKey(der)
+				if err != nil {
+					continue
+				}
+				logs[sha256.Sum256(der)] = pub
+			}
+		}
+	}
+	if len(logs) == 0 {
+		return nil, errors.New("CT 日志列表中没有可用的日志公钥")
+	}
+	return logs, nil
+}
+
+// readCTLogList 读取 http(s) URL 或本地文件中的日志列表
+func readCTLogList(src string) ([]byte, error) {
+	if !strings.HasPrefix(src, "http://") && !strings.HasPrefix(src, "https://") {
+		return os.ReadFile(src)
+	}
+	client := &http.Client{Timeout: ctHTTPTimeout}
+	resp, err := client.Get(src)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCTLogListSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxCTLogListSize {
+		return nil, fmt.Errorf("超过大小上限 %d 字节", maxCTLogListSize)
+	}
+	return data, nil
+}
@@ -0,0 +1,180 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestCTLog 生成 CT 日志密钥，返回私钥与公钥 DER
+func newTestCTLog(t *testing.T) (*ecdsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, der
+}
+
+// writeCTLogList 写入包含 logKeys 的 v3 日志列表，返回文件路径
+func writeCTLogList(t *testing.T, logKeys ...[]byte) string {
+	t.Helper()
+	var logs []map[string]string
+	for _, der := range logKeys {
+		logs = append(logs, map[string]string{"key": base64.StdEncoding.EncodeToString(der), "url": "https://ct.example.com/"})
+	}
+	data, _ := json.Marshal(map[string]interface{}{"operators": []interface{}{map[string]interface{}{"name": "test", "logs": logs}}})
+	path := filepath.Join(t.TempDir(), "log_list.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// issueWithSCT 由 ca 签发内嵌 SCT 的叶子证书：SCT 的日志 ID 取自 logKeyDER，签名使用 signer
+// 返回证书 + CA 的 PEM，以及不含 SCT 扩展时的 TBSCertificate
+func (ca *testCA) issueWithSCT(t *testing.T, serial int64, logKeyDER []byte, signer *ecdsa.PrivateKey) (chainPEM, tbsWithoutSCT []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now().Add(-time.Hour).Truncate(time.Second),
+		NotAfter:     time.Now().Add(time.Hour).Truncate(time.Second),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	precert, _ := x509.ParseCertificate(der)
+
+	sct := signedCertificateTimestamp{logID: sha256.Sum256(logKeyDER), timestamp: uint64(time.Now().UnixMilli()), hashAlg: 4, sigAlg: 3}
+	digest := sha256.Sum256(precertSignedData(sct, sha256.Sum256(ca.cert.RawSubjectPublicKeyInfo), precert.RawTBSCertificate))
+	if sct.signature, err = ecdsa.SignASN1(rand.Reader, signer, digest[:]); err != nil {
+		t.Fatal(err)
+	}
+
+	var serialized bytes.Buffer
+	serialized.WriteByte(0)
+	serialized.Write(sct.logID[:])
+	binary.Write(&serialized, binary.BigEndian, sct.timestamp)
+	binary.Write(&serialized, binary.BigEndian, uint16(0))
+	serialized.Write([]byte{sct.hashAlg, sct.sigAlg})
+	binary.Write(&serialized, binary.BigEndian, uint16(len(sct.signature)))
+	serialized.Write(sct.signature)
+
+	var list bytes.Buffer
+	binary.Write(&list, binary.BigEndian, uint16(serialized.Len()+2))
+	binary.Write(&list, binary.BigEndian, uint16(serialized.Len()))
+	list.Write(serialized.Bytes())
+	value, _ := asn1.Marshal(list.Bytes())
+
+	template.ExtraExtensions = []pkix.Extension{{Id: oidSCTList, Value: value}}
+	der, err = x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return append(leafPEM, ca.pem...), precert.RawTBSCertificate
+}
+
+func TestRemoveTBSExtension(t *testing.T) {
+	ca := newTestCA(t)
+	logKey, logDER := newTestCTLog(t)
+	chain, want := ca.issueWithSCT(t, 100, logDER, logKey)
+	certs, err := parseCertificateChain(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := removeTBSExtension(certs[0].RawTBSCertificate, oidSCTList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("删除 SCT 扩展后的 TBSCertificate 与预证书不一致")
+	}
+}
+
+func TestVerifyCTLog(t *testing.T) {
+	ca := newTestCA(t)
+	logKey, logDER := newTestCTLog(t)
+	otherKey, otherDER := newTestCTLog(t)
+	logList := writeCTLogList(t, otherDER, logDER)
+
+	valid, _ := ca.issueWithSCT(t, 101, logDER, logKey)
+	if err := VerifyCTLog(valid, []string{logList}); err != nil {
+		t.Fatalf("VerifyCTLog() error = %v", err)
+	}
+	// 校验通过的证书已缓存，不再读取日志列表
+	if err := VerifyCTLog(valid, []string{filepath.Join(t.TempDir(), "missing.json")}); err != nil {
+		t.Errorf("已缓存的证书 VerifyCTLog() error = %v", err)
+	}
+
+	unknownLog, _ := ca.issueWithSCT(t, 102, []byte("unknown log key"), logKey)
+	badSignature, _ := ca.issueWithSCT(t, 103, logDER, otherKey)
+	leafOnly, _ := ca.issueWithSCT(t, 104, logDER, logKey)
+	leafOnly = leafOnly[:len(leafOnly)-len(ca.pem)]
+
+	tests := []struct {
+		name    string
+		certPEM []byte
+		logURLs []string
+		wantErr error
+	}{
+		{"日志不在列表中", unknownLog, []string{logList}, ErrCTVerificationFailed},
+		{"签名无效", badSignature, []string{logList}, ErrCTVerificationFailed},
+		{"缺少颁发者证书", leafOnly, []string{logList}, ErrCTVerificationFailed},
+		{"没有 SCT", ca.issue(t, 105), []string{logList}, ErrNoSCT},
+		{"日志列表不存在", badSignature, []string{filepath.Join(t.TempDir(), "missing.json")}, ErrCTVerificationFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := VerifyCTLog(tt.certPEM, tt.logURLs); !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyCTLog() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyCTLogHTTP(t *testing.T) {
+	ca := newTestCA(t)
+	logKey, logDER := newTestCTLog(t)
+	data, err := os.ReadFile(writeCTLogList(t, logDER))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	chain, _ := ca.issueWithSCT(t, 200, logDER, logKey)
+	if err := VerifyCTLog(chain, []string{srv.URL}); err != nil {
+		t.Errorf("VerifyCTLog() error = %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+package cert
+
+import (
+	"crypto/x509"
+	"strings"
+
+	"github.com/Catker/acmeDeliver/pkg/domainmatch"
+)
+
+// CoversDomain 判断证书的 SAN（没有 SAN 时为 CN）是否覆盖 domain
+// 支持 *.example.com 形式的通配符证书（只匹配一级子域名）；domain 本身为通配符时要求证书包含同样的通配符名称
+func CoversDomain(c *x509.Certificate, domain string) bool {
+	names := c.DNSNames
+	if len(names) == 0 && c.Subject.CommonName != "" {
+		names = []string{c.Subject.CommonName}
+	}
+
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSuffix(name, "."))
+		if name == domain {
+			return true
+		}
+		if strings.HasPrefix(name, "*.") && domainmatch.Match(name, domain) {
+			return true
+		}
+	}
+	return false
+}
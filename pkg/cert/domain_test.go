@@ -0,0 +1,33 @@
+package cert
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+)
+
+func TestCoversDomain(t *testing.T) {
+	tests := []struct {
+		name   string
+		cert   *x509.Certificate
+		domain string
+		want   bool
+	}{
+		{"精确匹配", &x509.Certificate{DNSNames: []string{"example.com"}}, "example.com", true},
+		{"大小写与结尾点", &x509.Certificate{DNSNames: []string{"Example.COM."}}, "example.com", true},
+		{"通配符匹配一级子域名", &x509.Certificate{DNSNames: []string{"*.example.com"}}, "www.example.com", true},
+		{"通配符不匹配多级子域名", &x509.Certificate{DNSNames: []string{"*.example.com"}}, "a.b.example.com", false},
+		{"通配符不匹配根域名", &x509.Certificate{DNSNames: []string{"*.example.com"}}, "example.com", false},
+		{"通配符域名需要同样的通配符证书", &x509.Certificate{DNSNames: []string{"*.example.com"}}, "*.example.com", true},
+		{"域名不符", &x509.Certificate{DNSNames: []string{"other.com"}}, "example.com", false},
+		{"没有 SAN 时使用 CN", &x509.Certificate{Subject: pkix.Name{CommonName: "example.com"}}, "example.com", true},
+		{"有 SAN 时忽略 CN", &x509.Certificate{Subject: pkix.Name{CommonName: "example.com"}, DNSNames: []string{"other.com"}}, "example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CoversDomain(tt.cert, tt.domain); got != tt.want {
+				t.Errorf("CoversDomain(%v, %q) = %v, want %v", tt.cert.DNSNames, tt.domain, got, tt.want)
+			}
+		})
+	}
+}
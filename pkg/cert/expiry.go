@@ -0,0 +1,60 @@
+package cert
+
+import (
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"time"
+)
+
+// validity 对应 X.509 Validity 结构（UTCTime 或 GeneralizedTime）
+type validity struct {
+	NotBefore, NotAfter time.Time
+}
+
+// ParseCertificateExpiry 仅解析 PEM 证书的过期时间（tbsCertificate.validity.notAfter）
+// 逐层跳过 DER 字段，不构造完整的 x509.Certificate，适合批量扫描大量域名的到期时间
+// 需要主题、颁发者等其他字段时请使用 ParseCertificate
+func ParseCertificateExpiry(pemData []byte) (time.Time, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return time.Time{}, fmt.Errorf("无效的 PEM 数据")
+	}
+	if block.Type != "CERTIFICATE" {
+		return time.Time{}, fmt.Errorf("不是证书类型: %s", block.Type)
+	}
+
+	// Certificate ::= SEQUENCE { tbsCertificate, signatureAlgorithm, signatureValue }
+	var cert asn1.RawValue
+	if _, err := asn1.Unmarshal(block.Bytes, &cert); err != nil {
+		return time.Time{}, fmt.Errorf("解析证书结构失败: %w", err)
+	}
+	var tbs asn1.RawValue
+	if _, err := asn1.Unmarshal(cert.Bytes, &tbs); err != nil {
+		return time.Time{}, fmt.Errorf("解析 tbsCertificate 失败: %w", err)
+	}
+
+	// tbsCertificate ::= SEQUENCE { [0] version OPTIONAL, serialNumber, signature, issuer, validity, ... }
+	rest := tbs.Bytes
+	var field asn1.RawValue
+	rest, err := asn1.Unmarshal(rest, &field)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("解析证书字段失败: %w", err)
+	}
+	// 有显式版本号时，第一个字段之后才是序列号
+	skip := 2 // signature、issuer
+	if field.Class == asn1.ClassContextSpecific && field.Tag == 0 {
+		skip = 3 // serialNumber、signature、issuer
+	}
+	for i := 0; i < skip; i++ {
+		if rest, err = asn1.Unmarshal(rest, &field); err != nil {
+			return time.Time{}, fmt.Errorf("解析证书字段失败: %w", err)
+		}
+	}
+
+	var v validity
+	if _, err := asn1.Unmarshal(rest, &v); err != nil {
+		return time.Time{}, fmt.Errorf("解析证书有效期失败: %w", err)
+	}
+	return v.NotAfter, nil
+}
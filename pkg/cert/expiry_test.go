@@ -0,0 +1,97 @@
+package cert
+
+import (
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/testutil"
+)
+
+func TestParseCertificateExpiry(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		notAfter time.Time
+	}{
+		{"UTCTime", now.Add(90 * 24 * time.Hour)},
+		{"GeneralizedTime（2050 年及以后）", time.Date(2051, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{"已过期", now.Add(-24 * time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			certPEM, _, err := testutil.GenerateSelfSignedCert("example.com", tt.notAfter.Sub(now))
+			if err != nil {
+				t.Fatalf("生成测试证书失败: %v", err)
+			}
+
+			got, err := ParseCertificateExpiry(certPEM)
+			if err != nil {
+				t.Fatalf("ParseCertificateExpiry() error = %v", err)
+			}
+
+			full, err := ParseCertificate(certPEM)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(full.NotAfter) {
+				t.Errorf("ParseCertificateExpiry() = %v, 完整解析 = %v", got, full.NotAfter)
+			}
+			// 证书中的时间精确到秒，生成证书时的当前时间与 now 也略有差异
+			if diff := got.Sub(tt.notAfter); diff < -2*time.Second || diff > 2*time.Second {
+				t.Errorf("ParseCertificateExpiry() = %v, want %v", got, tt.notAfter)
+			}
+		})
+	}
+}
+
+func TestParseCertificateExpiry_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"空数据", nil},
+		{"非 PEM", []byte("not a certificate")},
+		{"非证书类型", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte{0x30, 0x00}})},
+		{"损坏的 DER", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte{0x30, 0x03, 0x02, 0x01}})},
+		{"缺少有效期", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte{0x30, 0x05, 0x30, 0x03, 0x02, 0x01, 0x01}})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCertificateExpiry(tt.data); err == nil {
+				t.Error("ParseCertificateExpiry() 应返回错误")
+			}
+		})
+	}
+}
+
+func BenchmarkCertificateExpiry(b *testing.B) {
+	certPEM, _, err := testutil.GenerateSelfSignedCert("example.com", 90*24*time.Hour)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Logf("证书 PEM 大小: %d 字节", len(certPEM))
+
+	b.Run("ParseCertificateExpiry", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := ParseCertificateExpiry(certPEM); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("ParseCertificate", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			cert, err := ParseCertificate(certPEM)
+			if err != nil {
+				b.Fatal(err)
+			}
+			_ = cert.NotAfter
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package cert
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// 证书文件的标准文件名，客户端工作目录与默认的服务端证书目录使用
+const (
+	CertFileName      = "cert.pem"
+	KeyFileName       = "key.pem"
+	FullchainFileName = "fullchain.pem"
+	TimeLogFileName   = "time.log"
+	// ChainFileName certbot 目录中只含中间证书的证书链，状态查询在缺少 fullchain 时以其作为证书链
+	ChainFileName = "chain.pem"
+)
+
+// FileNames 服务端证书目录中各类文件的实际文件名，支持 {domain} 占位符
+// 未设置的字段使用标准文件名；如 acme.sh 的默认输出可配置为 key: "{domain}.key"、fullchain: "fullchain.cer"
+type FileNames struct {
+	Cert      string `yaml:"cert,omitempty" json:"cert,omitempty" toml:"cert,omitempty"`
+	Key       string `yaml:"key,omitempty" json:"key,omitempty" toml:"key,omitempty"`
+	Fullchain string `yaml:"fullchain,omitempty" json:"fullchain,omitempty" toml:"fullchain,omitempty"`
+	TimeLog   string `yaml:"time_log,omitempty" json:"time_log,omitempty" toml:"time_log,omitempty"`
+}
+
+// DefaultFileNames 返回标准文件名
+func DefaultFileNames() FileNames {
+	return FileNames{Cert: CertFileName, Key: KeyFileName, Fullchain: FullchainFileName, TimeLog: TimeLogFileName}
+}
+
+// ForDomain 补全未设置的文件名并替换 {domain} 占位符
+func (n FileNames) ForDomain(domain string) FileNames {
+	def := DefaultFileNames()
+	resolve := func(name, fallback string) string {
+		if name == "" {
+			return fallback
+		}
+		return strings.ReplaceAll(name, "{domain}", domain)
+	}
+	return FileNames{
+		Cert:      resolve(n.Cert, def.Cert),
+		Key:       resolve(n.Key, def.Key),
+		Fullchain: resolve(n.Fullchain, def.Fullchain),
+		TimeLog:   resolve(n.TimeLog, def.TimeLog),
+	}
+}
+
+// IsDefault 判断补全后是否与标准文件名相同
+func (n FileNames) IsDefault() bool {
+	return n.ForDomain("") == DefaultFileNames()
+}
+
+// List 按 cert、key、fullchain、time.log 的顺序返回文件名
+func (n FileNames) List() []string {
+	return []string{n.Cert, n.Key, n.Fullchain, n.TimeLog}
+}
+
+// Validate 校验文件名：不能包含路径分隔符或 ..，各类文件不能同名
+func (n FileNames) Validate() error {
+	seen := make(map[string]string)
+	resolved := n.ForDomain("example.com")
+	for i, name := range resolved.List() {
+		field := []string{"cert", "key", "fullchain", "time_log"}[i]
+		if strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") {
+			return fmt.Errorf("cert_filenames.%s 不能包含路径分隔符或 ..: %q", field, name)
+		}
+		if other, ok := seen[name]; ok {
+			return fmt.Errorf("cert_filenames.%s 与 cert_filenames.%s 不能使用相同的文件名 %q", field, other, name)
+		}
+		seen[name] = field
+	}
+	return nil
+}
+
+// ToStandard 将以实际文件名为键的 map 转换为以标准文件名为键，其余文件保持原名
+// 同一实际文件可对应多个标准文件名（如 Caddy 的 <domain>.crt 同时作为 cert 与 fullchain）
+// names 为 nil 时表示服务端使用标准文件名，原样返回
+func ToStandard[V any](names *FileNames, files map[string]V) map[string]V {
+	if names == nil || files == nil {
+		return files
+	}
+	actual := names.ForDomain("").List()
+	standard := DefaultFileNames().List()
+	rename := make(map[string][]string, len(actual))
+	for i := range actual {
+		rename[actual[i]] = append(rename[actual[i]], standard[i])
+	}
+
+	out := make(map[string]V, len(files))
+	// 先复制其他文件，再写入映射后的文件，与标准文件名同名的其他文件被覆盖
+	for name, v := range files {
+		if _, ok := rename[name]; !ok {
+			out[name] = v
+		}
+	}
+	for name, v := range files {
+		for _, std := range rename[name] {
+			out[std] = v
+		}
+	}
+	return out
+}
+
+var (
+	fileNamesMu sync.RWMutex
+	fileNames   FileNames
+)
+
+// SetFileNames 设置服务端证书目录使用的文件名（用于启动与配置热重载），调用方应先校验
+func SetFileNames(n FileNames) {
+	fileNamesMu.Lock()
+	fileNames = n
+	fileNamesMu.Unlock()
+}
+
+// DomainFileNames 返回指定域名在服务端证书目录中的实际文件名
+func DomainFileNames(domain string) FileNames {
+	fileNamesMu.RLock()
+	defer fileNamesMu.RUnlock()
+	return fileNames.ForDomain(domain)
+}
@@ -0,0 +1,56 @@
+package cert
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFileNamesForDomain(t *testing.T) {
+	names := FileNames{Cert: "{domain}.cer", Key: "{domain}.key", Fullchain: "fullchain.cer"}
+	got := names.ForDomain("example.com")
+	want := FileNames{Cert: "example.com.cer", Key: "example.com.key", Fullchain: "fullchain.cer", TimeLog: "time.log"}
+	if got != want {
+		t.Errorf("ForDomain() = %+v, want %+v", got, want)
+	}
+	if names.IsDefault() {
+		t.Error("IsDefault() = true, want false")
+	}
+	if !(FileNames{}).IsDefault() || !DefaultFileNames().IsDefault() {
+		t.Error("未设置或标准文件名时 IsDefault() 应为 true")
+	}
+}
+
+func TestFileNamesValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		names   FileNames
+		wantErr bool
+	}{
+		{"默认", FileNames{}, false},
+		{"acme.sh", FileNames{Cert: "{domain}.cer", Key: "{domain}.key", Fullchain: "fullchain.cer"}, false},
+		{"路径分隔符", FileNames{Key: "../key.pem"}, true},
+		{"子目录", FileNames{Cert: "certs/cert.pem"}, true},
+		{"与其他文件同名", FileNames{Fullchain: "cert.pem"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.names.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestToStandard(t *testing.T) {
+	files := map[string][]byte{"example.com.cer": []byte("cert"), "fullchain.cer": []byte("chain"), "ca.cer": []byte("ca"), "time.log": []byte("1")}
+	if got := ToStandard(nil, files); !reflect.DeepEqual(got, files) {
+		t.Errorf("names 为 nil 时应原样返回，got %v", got)
+	}
+
+	names := FileNames{Cert: "{domain}.cer", Fullchain: "fullchain.cer"}.ForDomain("example.com")
+	got := ToStandard(&names, files)
+	want := map[string][]byte{"cert.pem": []byte("cert"), "fullchain.pem": []byte("chain"), "ca.cer": []byte("ca"), "time.log": []byte("1")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ToStandard() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,15 @@
+package cert
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// VerifyKeyPair 校验 PEM 证书与私钥是否匹配
+// 支持 PKCS#1 / PKCS#8 / EC 私钥；证书数据可以是单个证书或完整证书链（以第一个证书为准）
+func VerifyKeyPair(certPEM, keyPEM []byte) error {
+	if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		return fmt.Errorf("证书与私钥不匹配: %w", err)
+	}
+	return nil
+}
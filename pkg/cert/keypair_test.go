@@ -0,0 +1,44 @@
+package cert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/testutil"
+)
+
+// generateTestKeyPair 生成自签名证书及其私钥（PEM）
+func generateTestKeyPair(t *testing.T) (certPEM, keyPEM []byte) {
+	t.Helper()
+	certPEM, keyPEM, err := testutil.GenerateSelfSignedCert("example.com", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certPEM, keyPEM
+}
+
+func TestVerifyKeyPair(t *testing.T) {
+	certPEM, keyPEM := generateTestKeyPair(t)
+	_, otherKey := generateTestKeyPair(t)
+
+	tests := []struct {
+		name    string
+		cert    []byte
+		key     []byte
+		wantErr bool
+	}{
+		{"匹配", certPEM, keyPEM, false},
+		{"私钥不匹配", certPEM, otherKey, true},
+		{"证书无效", []byte("not a cert"), keyPEM, true},
+		{"私钥无效", certPEM, []byte("not a key"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyKeyPair(tt.cert, tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyKeyPair() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
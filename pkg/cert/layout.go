@@ -0,0 +1,57 @@
+package cert
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Layout 域名目录的文件布局，对应生成证书的 ACME 客户端
+type Layout string
+
+const (
+	LayoutStandard Layout = "standard" // cert.pem / key.pem / fullchain.pem
+	LayoutCertbot  Layout = "certbot"  // cert.pem / privkey.pem / fullchain.pem / chain.pem
+	LayoutAcmeSh   Layout = "acme.sh"  // <domain>.cer / <domain>.key / fullchain.cer / ca.cer
+	LayoutCaddy    Layout = "caddy"    // <domain>.crt（含证书链）/ <domain>.key
+)
+
+// DetectLayout 按域名目录中已有的文件识别常见 ACME 客户端的布局，返回布局及对应的实际文件名
+// 域名取自目录名（acme.sh 的 ECC 目录去掉 _ecc 后缀）；依次识别标准布局（存在 key.pem）、certbot（存在 privkey.pem）、
+// acme.sh（存在 <domain>.cer 与 <domain>.key）与 Caddy（存在 <domain>.crt 与 <domain>.key），均不符合时按标准布局返回
+// time.log 始终使用标准文件名
+func DetectLayout(domainDir string) (Layout, FileNames) {
+	domain := strings.TrimSuffix(filepath.Base(domainDir), "_ecc")
+	exists := func(name string) bool {
+		info, err := os.Stat(filepath.Join(domainDir, name))
+		return err == nil && !info.IsDir()
+	}
+
+	names := DefaultFileNames()
+	switch {
+	case exists(KeyFileName):
+		return LayoutStandard, names
+	case exists("privkey.pem"):
+		names.Key = "privkey.pem"
+		return LayoutCertbot, names
+	case exists(domain+".cer") && exists(domain+".key"):
+		names.Cert, names.Key, names.Fullchain = domain+".cer", domain+".key", "fullchain.cer"
+		return LayoutAcmeSh, names
+	case exists(domain+".crt") && exists(domain+".key"):
+		names.Cert, names.Key, names.Fullchain = domain+".crt", domain+".key", domain+".crt"
+		return LayoutCaddy, names
+	}
+	return LayoutStandard, names
+}
+
+// DomainDirFileNames 返回域名目录实际使用的文件名：配置了 cert_filenames 时以配置为准，否则按 DetectLayout 自动识别
+func DomainDirFileNames(domainDir, domain string) FileNames {
+	fileNamesMu.RLock()
+	configured := fileNames
+	fileNamesMu.RUnlock()
+	if !configured.IsDefault() {
+		return configured.ForDomain(domain)
+	}
+	_, names := DetectLayout(domainDir)
+	return names
+}
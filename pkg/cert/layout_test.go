@@ -0,0 +1,95 @@
+package cert
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeLayoutFiles 在 baseDir/dirName 下创建空文件
+func writeLayoutFiles(t *testing.T, baseDir, dirName string, files ...string) string {
+	t.Helper()
+	dir := filepath.Join(baseDir, dirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(dir, f), []byte(f), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestDetectLayout(t *testing.T) {
+	tests := []struct {
+		name       string
+		dirName    string
+		files      []string
+		wantLayout Layout
+		wantNames  FileNames
+	}{
+		{
+			"标准", "example.com", []string{"cert.pem", "key.pem", "fullchain.pem", "time.log"},
+			LayoutStandard, DefaultFileNames(),
+		},
+		{
+			"certbot", "example.com", []string{"cert.pem", "privkey.pem", "chain.pem", "fullchain.pem"},
+			LayoutCertbot, FileNames{Cert: "cert.pem", Key: "privkey.pem", Fullchain: "fullchain.pem", TimeLog: "time.log"},
+		},
+		{
+			"acme.sh", "example.com", []string{"example.com.cer", "example.com.key", "fullchain.cer", "ca.cer", "example.com.conf"},
+			LayoutAcmeSh, FileNames{Cert: "example.com.cer", Key: "example.com.key", Fullchain: "fullchain.cer", TimeLog: "time.log"},
+		},
+		{
+			"acme.sh ECC 目录", "example.com_ecc", []string{"example.com.cer", "example.com.key", "fullchain.cer"},
+			LayoutAcmeSh, FileNames{Cert: "example.com.cer", Key: "example.com.key", Fullchain: "fullchain.cer", TimeLog: "time.log"},
+		},
+		{
+			"Caddy", "example.com", []string{"example.com.crt", "example.com.key", "example.com.json"},
+			LayoutCaddy, FileNames{Cert: "example.com.crt", Key: "example.com.key", Fullchain: "example.com.crt", TimeLog: "time.log"},
+		},
+		{
+			"标准布局优先", "example.com", []string{"key.pem", "privkey.pem", "example.com.cer", "example.com.key"},
+			LayoutStandard, DefaultFileNames(),
+		},
+		{
+			"无法识别", "example.com", []string{"other.txt"},
+			LayoutStandard, DefaultFileNames(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeLayoutFiles(t, t.TempDir(), tt.dirName, tt.files...)
+			layout, names := DetectLayout(dir)
+			if layout != tt.wantLayout || names != tt.wantNames {
+				t.Errorf("DetectLayout() = %s %+v, want %s %+v", layout, names, tt.wantLayout, tt.wantNames)
+			}
+		})
+	}
+}
+
+func TestDomainDirFileNames(t *testing.T) {
+	dir := writeLayoutFiles(t, t.TempDir(), "example.com", "cert.pem", "privkey.pem", "fullchain.pem")
+	if got := DomainDirFileNames(dir, "example.com"); got.Key != "privkey.pem" {
+		t.Errorf("未配置 cert_filenames 时应自动识别 certbot 布局，got %+v", got)
+	}
+
+	// 配置了 cert_filenames 时以配置为准
+	SetFileNames(FileNames{Key: "{domain}.key"})
+	t.Cleanup(func() { SetFileNames(FileNames{}) })
+	if got := DomainDirFileNames(dir, "example.com"); got.Key != "example.com.key" {
+		t.Errorf("配置了 cert_filenames 时应使用配置，got %+v", got)
+	}
+}
+
+func TestToStandardSharedFile(t *testing.T) {
+	_, names := DetectLayout(writeLayoutFiles(t, t.TempDir(), "example.com", "example.com.crt", "example.com.key"))
+	files := map[string][]byte{"example.com.crt": []byte("chain"), "example.com.key": []byte("key")}
+	got := ToStandard(&names, files)
+	want := map[string][]byte{"cert.pem": []byte("chain"), "fullchain.pem": []byte("chain"), "key.pem": []byte("key")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ToStandard() = %v, want %v", got, want)
+	}
+}
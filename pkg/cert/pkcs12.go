@@ -0,0 +1,665 @@
+package cert
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"unicode/utf16"
+)
+
+// PKCS#12 编码参数：与 OpenSSL 3 默认值一致
+const (
+	pkcs12Iterations = 2048
+	pkcs12SaltLen    = 16
+	// 旧版 PBE 使用 8 字节盐值（与 OpenSSL -legacy 一致）
+	pkcs12LegacySaltLen = 8
+)
+
+var (
+	oidDataContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedData     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+	oidCertBag           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidShroudedKeyBag    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertTypeX509      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidLocalKeyID        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+	oidPBES2             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBEWithSHA3DES    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidPBEWithSHARC2_40  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 6}
+	oidPBKDF2            = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA256    = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES256CBC         = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidSHA1              = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	asn1NULL             = asn1.RawValue{Tag: asn1.TagNull}
+	errPKCS12BadPassword = errors.New("PKCS#12 密码错误或文件已损坏")
+)
+
+type pfxPdu struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData `asn1:"optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue     `asn1:"tag:0,explicit"`
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes2Params struct {
+	KDF              pkix.AlgorithmIdentifier
+	EncryptionScheme pkix.AlgorithmIdentifier
+}
+
+// pbeParams RFC 7292 附录 C 旧版 PBE 算法的参数
+type pbeParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+// encryptedData PKCS#7 EncryptedData，旧版 PKCS#12 以此加密证书
+type encryptedData struct {
+	Version              int
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           []byte `asn1:"tag:0,optional"`
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	PRF        pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// PEMToPKCS12 将 PEM 证书链（叶子证书在前）与私钥编码为 PKCS#12，编码前校验证书与私钥是否匹配
+// legacy 为 true 时使用旧版算法，见 EncodePKCS12
+func PEMToPKCS12(fullchainPEM, keyPEM []byte, password string, legacy bool) ([]byte, error) {
+	if err := VerifyKeyPair(fullchainPEM, keyPEM); err != nil {
+		return nil, err
+	}
+	chain, err := parseCertificateChain(fullchainPEM)
+	if err != nil {
+		return nil, err
+	}
+	key, err := parsePrivateKeyPEM(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	return EncodePKCS12(key, chain[0], chain[1:], password, legacy)
+}
+
+// EncodePKCS12 编码 PKCS#12：私钥使用 PBES2（PBKDF2-HMAC-SHA256 + AES-256-CBC）加密，
+// 证书不加密，整体以 HMAC-SHA256 校验，与 OpenSSL 3 的默认算法兼容
+// legacy 为 true 时私钥使用 pbeWithSHAAnd3-KeyTripleDES-CBC、证书使用 pbeWithSHAAnd40BitRC2-CBC 加密，
+// 以 HMAC-SHA1 校验（与 OpenSSL 的 -legacy 一致），供 Java 8u301 之前等不支持 PBES2 的程序读取
+func EncodePKCS12(key crypto.PrivateKey, leaf *x509.Certificate, caCerts []*x509.Certificate, password string, legacy bool) ([]byte, error) {
+	keyID := sha1.Sum(leaf.Raw)
+	attrs, err := localKeyIDAttributes(keyID[:])
+	if err != nil {
+		return nil, err
+	}
+
+	var certBags []safeBag
+	for i, c := range append([]*x509.Certificate{leaf}, caCerts...) {
+		bag, err := asn1.Marshal(certBag{ID: oidCertTypeX509, Data: c.Raw})
+		if err != nil {
+			return nil, err
+		}
+		sb := safeBag{ID: oidCertBag, Value: explicitValue(bag)}
+		if i == 0 {
+			sb.Attributes = attrs
+		}
+		certBags = append(certBags, sb)
+	}
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("编码私钥失败: %w", err)
+	}
+	var shrouded []byte
+	if legacy {
+		shrouded, err = encryptLegacyKey(pkcs8, password)
+	} else {
+		shrouded, err = encryptPBES2(pkcs8, password)
+	}
+	if err != nil {
+		return nil, err
+	}
+	keyBags := []safeBag{{ID: oidShroudedKeyBag, Value: explicitValue(shrouded), Attributes: attrs}}
+
+	var certsInfo contentInfo
+	if legacy {
+		certsInfo, err = encryptedContentInfoFor(certBags, password)
+	} else {
+		certsInfo, err = dataContentInfo(certBags)
+	}
+	if err != nil {
+		return nil, err
+	}
+	keysInfo, err := dataContentInfo(keyBags)
+	if err != nil {
+		return nil, err
+	}
+	authSafe := []contentInfo{certsInfo, keysInfo}
+	authSafeDER, err := asn1.Marshal(authSafe)
+	if err != nil {
+		return nil, err
+	}
+
+	pfx := pfxPdu{Version: 3}
+	if pfx.AuthSafe, err = octetContentInfo(authSafeDER); err != nil {
+		return nil, err
+	}
+	salt := make([]byte, pkcs12SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	macAlg, newHash := oidSHA256, sha256.New
+	if legacy {
+		macAlg, newHash = oidSHA1, sha1.New
+	}
+	pfx.MacData = macData{
+		Mac:        digestInfo{Algorithm: pkix.AlgorithmIdentifier{Algorithm: macAlg, Parameters: asn1NULL}},
+		MacSalt:    salt,
+		Iterations: pkcs12Iterations,
+	}
+	pfx.MacData.Mac.Digest = pkcs12MAC(newHash, authSafeDER, salt, pkcs12Iterations, password)
+	return asn1.Marshal(pfx)
+}
+
+// DecodePKCS12 解码 EncodePKCS12 生成的 PKCS#12（包括 legacy 格式），校验 MAC 后返回私钥、叶子证书与中间证书
+func DecodePKCS12(data []byte, password string) (crypto.PrivateKey, *x509.Certificate, []*x509.Certificate, error) {
+	var pfx pfxPdu
+	if rest, err := asn1.Unmarshal(data, &pfx); err != nil || len(rest) > 0 {
+		return nil, nil, nil, fmt.Errorf("解析 PKCS#12 失败: %v", err)
+	}
+	authSafeDER, err := contentOctets(pfx.AuthSafe)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	newHash := sha1.New
+	switch alg := pfx.MacData.Mac.Algorithm.Algorithm; {
+	case alg.Equal(oidSHA256):
+		newHash = sha256.New
+	case !alg.Equal(oidSHA1):
+		return nil, nil, nil, fmt.Errorf("不支持的 PKCS#12 MAC 算法: %v", alg)
+	}
+	mac := pkcs12MAC(newHash, authSafeDER, pfx.MacData.MacSalt, pfx.MacData.Iterations, password)
+	if !hmac.Equal(mac, pfx.MacData.Mac.Digest) {
+		return nil, nil, nil, errPKCS12BadPassword
+	}
+
+	var authSafe []contentInfo
+	if _, err := asn1.Unmarshal(authSafeDER, &authSafe); err != nil {
+		return nil, nil, nil, fmt.Errorf("解析 PKCS#12 内容失败: %w", err)
+	}
+	var key crypto.PrivateKey
+	var certs []*x509.Certificate
+	for _, ci := range authSafe {
+		var bagsDER []byte
+		if ci.ContentType.Equal(oidEncryptedData) {
+			bagsDER, err = decryptContentInfo(ci, password)
+		} else {
+			bagsDER, err = contentOctets(ci)
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		var bags []safeBag
+		if _, err := asn1.Unmarshal(bagsDER, &bags); err != nil {
+			return nil, nil, nil, fmt.Errorf("解析 PKCS#12 safe bag 失败: %w", err)
+		}
+		for _, bag := range bags {
+			switch {
+			case bag.ID.Equal(oidCertBag):
+				var cb certBag
+				if _, err := asn1.Unmarshal(bag.Value.Bytes, &cb); err != nil {
+					return nil, nil, nil, fmt.Errorf("解析证书 bag 失败: %w", err)
+				}
+				c, err := x509.ParseCertificate(cb.Data)
+				if err != nil {
+					return nil, nil, nil, fmt.Errorf("解析证书失败: %w", err)
+				}
+				certs = append(certs, c)
+			case bag.ID.Equal(oidShroudedKeyBag):
+				pkcs8, err := decryptShroudedKey(bag.Value.Bytes, password)
+				if err != nil {
+					return nil, nil, nil, err
+				}
+				if key, err = x509.ParsePKCS8PrivateKey(pkcs8); err != nil {
+					return nil, nil, nil, fmt.Errorf("解析私钥失败: %w", err)
+				}
+			}
+		}
+	}
+	if key == nil || len(certs) == 0 {
+		return nil, nil, nil, fmt.Errorf("PKCS#12 中缺少私钥或证书")
+	}
+	return key, certs[0], certs[1:], nil
+}
+
+// PKCS12Matches 判断 data 是否为由 fullchainPEM 与 keyPEM 以相同密码、相同算法（legacy）编码的 PKCS#12
+// 每次编码使用随机盐值，无法按字节比对，解码后比较证书链、私钥与 MAC 算法；解码失败时返回 false
+func PKCS12Matches(data, fullchainPEM, keyPEM []byte, password string, legacy bool) bool {
+	var pfx pfxPdu
+	if _, err := asn1.Unmarshal(data, &pfx); err != nil || pfx.MacData.Mac.Algorithm.Algorithm.Equal(oidSHA1) != legacy {
+		return false
+	}
+	key, leaf, caCerts, err := DecodePKCS12(data, password)
+	if err != nil {
+		return false
+	}
+	chain, err := parseCertificateChain(fullchainPEM)
+	if err != nil || len(chain) != len(caCerts)+1 {
+		return false
+	}
+	for i, c := range append([]*x509.Certificate{leaf}, caCerts...) {
+		if !c.Equal(chain[i]) {
+			return false
+		}
+	}
+	wantKey, err := parsePrivateKeyPEM(keyPEM)
+	if err != nil {
+		return false
+	}
+	got, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return false
+	}
+	want, err := x509.MarshalPKCS8PrivateKey(wantKey)
+	return err == nil && bytes.Equal(got, want)
+}
+
+// parsePrivateKeyPEM 解析 PKCS#1 / PKCS#8 / EC 格式的 PEM 私钥
+func parsePrivateKeyPEM(keyPEM []byte) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("无效的私钥 PEM 数据")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("不支持的私钥格式: %s", block.Type)
+}
+
+// localKeyIDAttributes 关联私钥与叶子证书的 localKeyId 属性
+func localKeyIDAttributes(id []byte) ([]pkcs12Attribute, error) {
+	value, err := asn1.Marshal(id)
+	if err != nil {
+		return nil, err
+	}
+	return []pkcs12Attribute{{
+		ID:    oidLocalKeyID,
+		Value: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: value},
+	}}, nil
+}
+
+// explicitValue 包装为 [0] EXPLICIT
+func explicitValue(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+// dataContentInfo 将 safe bag 序列编码为 data 类型的 ContentInfo
+func dataContentInfo(bags []safeBag) (contentInfo, error) {
+	der, err := asn1.Marshal(bags)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	return octetContentInfo(der)
+}
+
+// octetContentInfo 将 content 以 OCTET STRING 封装为 data 类型的 ContentInfo
+func octetContentInfo(content []byte) (contentInfo, error) {
+	octets, err := asn1.Marshal(content)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	return contentInfo{ContentType: oidDataContentType, Content: explicitValue(octets)}, nil
+}
+
+// contentOctets 取出 data 类型 ContentInfo 中的 OCTET STRING
+func contentOctets(ci contentInfo) ([]byte, error) {
+	if !ci.ContentType.Equal(oidDataContentType) {
+		return nil, fmt.Errorf("不支持的 PKCS#12 内容类型: %v", ci.ContentType)
+	}
+	var octets []byte
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &octets); err != nil {
+		return nil, fmt.Errorf("解析 PKCS#12 内容失败: %w", err)
+	}
+	return octets, nil
+}
+
+// encryptPBES2 以 PBES2（PBKDF2-HMAC-SHA256 + AES-256-CBC）加密 PKCS#8 私钥，返回 EncryptedPrivateKeyInfo
+func encryptPBES2(plain []byte, password string) ([]byte, error) {
+	salt := make([]byte, pkcs12SaltLen)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:       salt,
+		Iterations: pkcs12Iterations,
+		PRF:        pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1NULL},
+	})
+	if err != nil {
+		return nil, err
+	}
+	ivParam, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KDF:              pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme: pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParam}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(pbkdf2SHA256([]byte(password), salt, pkcs12Iterations, 32))
+	if err != nil {
+		return nil, err
+	}
+	padLen := aes.BlockSize - len(plain)%aes.BlockSize
+	data := append(append([]byte{}, plain...), bytes.Repeat([]byte{byte(padLen)}, padLen)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+		EncryptedData: data,
+	})
+}
+
+// decryptShroudedKey 解密 EncryptedPrivateKeyInfo，支持 PBES2 与旧版 pbeWithSHAAnd3-KeyTripleDES-CBC
+func decryptShroudedKey(der []byte, password string) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("解析加密私钥失败: %w", err)
+	}
+	switch alg := info.Algorithm.Algorithm; {
+	case alg.Equal(oidPBES2):
+		return decryptPBES2(info, password)
+	case alg.Equal(oidPBEWithSHA3DES):
+		return decryptLegacyPBE(info.Algorithm, info.EncryptedData, password)
+	default:
+		return nil, fmt.Errorf("不支持的私钥加密算法: %v", alg)
+	}
+}
+
+// decryptPBES2 解密 PBES2（PBKDF2-HMAC-SHA256 + AES-256-CBC）加密的私钥
+func decryptPBES2(info encryptedPrivateKeyInfo, password string) ([]byte, error) {
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("解析 PBES2 参数失败: %w", err)
+	}
+	var kdf pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KDF.Parameters.FullBytes, &kdf); err != nil {
+		return nil, fmt.Errorf("解析 PBKDF2 参数失败: %w", err)
+	}
+	if !params.KDF.Algorithm.Equal(oidPBKDF2) || !kdf.PRF.Algorithm.Equal(oidHMACWithSHA256) || !params.EncryptionScheme.Algorithm.Equal(oidAES256CBC) {
+		return nil, fmt.Errorf("不支持的 PBES2 算法组合")
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil || len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("无效的 AES IV")
+	}
+	data := info.EncryptedData
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, errPKCS12BadPassword
+	}
+
+	block, err := aes.NewCipher(pbkdf2SHA256([]byte(password), kdf.Salt, kdf.Iterations, 32))
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+	padLen := int(plain[len(plain)-1])
+	if padLen == 0 || padLen > aes.BlockSize || !bytes.Equal(plain[len(plain)-padLen:], bytes.Repeat([]byte{byte(padLen)}, padLen)) {
+		return nil, errPKCS12BadPassword
+	}
+	return plain[:len(plain)-padLen], nil
+}
+
+// encryptLegacyKey 以 pbeWithSHAAnd3-KeyTripleDES-CBC 加密 PKCS#8 私钥，返回 EncryptedPrivateKeyInfo
+func encryptLegacyKey(plain []byte, password string) ([]byte, error) {
+	alg, data, err := encryptLegacyPBE(oidPBEWithSHA3DES, plain, password)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(encryptedPrivateKeyInfo{Algorithm: alg, EncryptedData: data})
+}
+
+// encryptedContentInfoFor 以 pbeWithSHAAnd40BitRC2-CBC 加密 safe bag 序列，返回 encryptedData 类型的 ContentInfo
+func encryptedContentInfoFor(bags []safeBag, password string) (contentInfo, error) {
+	plain, err := asn1.Marshal(bags)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	alg, data, err := encryptLegacyPBE(oidPBEWithSHARC2_40, plain, password)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	der, err := asn1.Marshal(encryptedData{EncryptedContentInfo: encryptedContentInfo{
+		ContentType:                oidDataContentType,
+		ContentEncryptionAlgorithm: alg,
+		EncryptedContent:           data,
+	}})
+	if err != nil {
+		return contentInfo{}, err
+	}
+	return contentInfo{ContentType: oidEncryptedData, Content: explicitValue(der)}, nil
+}
+
+// decryptContentInfo 解密 encryptedData 类型的 ContentInfo，返回其中的 safe bag 序列
+func decryptContentInfo(ci contentInfo, password string) ([]byte, error) {
+	var ed encryptedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &ed); err != nil {
+		return nil, fmt.Errorf("解析 PKCS#12 加密内容失败: %w", err)
+	}
+	return decryptLegacyPBE(ed.EncryptedContentInfo.ContentEncryptionAlgorithm, ed.EncryptedContentInfo.EncryptedContent, password)
+}
+
+// legacyPBECipher 按 RFC 7292 附录 B 派生密钥与 IV，返回旧版 PBE 算法对应的分组密码
+func legacyPBECipher(alg asn1.ObjectIdentifier, password string, params pbeParams) (cipher.Block, []byte, error) {
+	pw := bmpPassword(password)
+	iv := pkcs12KDF(sha1.New, pw, params.Salt, 2, params.Iterations, 8)
+	switch {
+	case alg.Equal(oidPBEWithSHA3DES):
+		block, err := des.NewTripleDESCipher(pkcs12KDF(sha1.New, pw, params.Salt, 1, params.Iterations, 24))
+		return block, iv, err
+	case alg.Equal(oidPBEWithSHARC2_40):
+		return newRC2Cipher(pkcs12KDF(sha1.New, pw, params.Salt, 1, params.Iterations, 5), 40), iv, nil
+	default:
+		return nil, nil, fmt.Errorf("不支持的 PKCS#12 加密算法: %v", alg)
+	}
+}
+
+// encryptLegacyPBE 以旧版 PBE 算法（3DES 或 RC2-40，均为 8 字节分组）加密 plain，返回算法标识与密文
+func encryptLegacyPBE(alg asn1.ObjectIdentifier, plain []byte, password string) (pkix.AlgorithmIdentifier, []byte, error) {
+	params := pbeParams{Salt: make([]byte, pkcs12LegacySaltLen), Iterations: pkcs12Iterations}
+	if _, err := rand.Read(params.Salt); err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+	paramsDER, err := asn1.Marshal(params)
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+	block, iv, err := legacyPBECipher(alg, password, params)
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+
+	bs := block.BlockSize()
+	padLen := bs - len(plain)%bs
+	data := append(append([]byte{}, plain...), bytes.Repeat([]byte{byte(padLen)}, padLen)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+	return pkix.AlgorithmIdentifier{Algorithm: alg, Parameters: asn1.RawValue{FullBytes: paramsDER}}, data, nil
+}
+
+// decryptLegacyPBE 解密 encryptLegacyPBE 加密的数据
+func decryptLegacyPBE(alg pkix.AlgorithmIdentifier, data []byte, password string) ([]byte, error) {
+	var params pbeParams
+	if _, err := asn1.Unmarshal(alg.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("解析 PBE 参数失败: %w", err)
+	}
+	block, iv, err := legacyPBECipher(alg.Algorithm, password, params)
+	if err != nil {
+		return nil, err
+	}
+	bs := block.BlockSize()
+	if len(data) == 0 || len(data)%bs != 0 {
+		return nil, errPKCS12BadPassword
+	}
+
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+	padLen := int(plain[len(plain)-1])
+	if padLen == 0 || padLen > bs || !bytes.Equal(plain[len(plain)-padLen:], bytes.Repeat([]byte{byte(padLen)}, padLen)) {
+		return nil, errPKCS12BadPassword
+	}
+	return plain[:len(plain)-padLen], nil
+}
+
+// pkcs12MAC 按 RFC 7292 附录 B 派生 MAC 密钥并计算 authSafe 的 HMAC
+func pkcs12MAC(newHash func() hash.Hash, content, salt []byte, iterations int, password string) []byte {
+	key := pkcs12KDF(newHash, bmpPassword(password), salt, 3, iterations, newHash().Size())
+	mac := hmac.New(newHash, key)
+	mac.Write(content)
+	return mac.Sum(nil)
+}
+
+// bmpPassword 将密码编码为以 0x0000 结尾的 BMPString（UTF-16BE）
+func bmpPassword(password string) []byte {
+	units := utf16.Encode([]rune(password))
+	out := make([]byte, 0, 2*len(units)+2)
+	for _, u := range units {
+		out = append(out, byte(u>>8), byte(u))
+	}
+	return append(out, 0, 0)
+}
+
+// pkcs12KDF RFC 7292 附录 B.2 的密钥派生函数，id 为 1（加密密钥）、2（IV）或 3（MAC 密钥）
+func pkcs12KDF(newHash func() hash.Hash, password, salt []byte, id byte, iterations, size int) []byte {
+	const v = 64 // SHA-1 与 SHA-256 的块大小
+	fill := func(src []byte) []byte {
+		if len(src) == 0 {
+			return nil
+		}
+		out := make([]byte, v*((len(src)+v-1)/v))
+		for i := range out {
+			out[i] = src[i%len(src)]
+		}
+		return out
+	}
+	d := bytes.Repeat([]byte{id}, v)
+	in := append(fill(salt), fill(password)...)
+
+	var out []byte
+	for len(out) < size {
+		h := newHash()
+		h.Write(d)
+		h.Write(in)
+		a := h.Sum(nil)
+		for j := 1; j < iterations; j++ {
+			h.Reset()
+			h.Write(a)
+			a = h.Sum(a[:0])
+		}
+		out = append(out, a...)
+
+		// I_j = (I_j + B + 1) mod 2^(v*8)
+		b := fill(a)[:v]
+		for j := 0; j < len(in); j += v {
+			carry := 1
+			for k := v - 1; k >= 0; k-- {
+				sum := int(in[j+k]) + int(b[k]) + carry
+				in[j+k] = byte(sum)
+				carry = sum >> 8
+			}
+		}
+	}
+	return out[:size]
+}
+
+// pbkdf2SHA256 RFC 8018 PBKDF2，PRF 为 HMAC-SHA256
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	var out []byte
+	for block := uint32(1); len(out) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write([]byte{byte(block >> 24), byte(block >> 16), byte(block >> 8), byte(block)})
+		u := prf.Sum(nil)
+		t := append([]byte{}, u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for k := range t {
+				t[k] ^= u[k]
+			}
+		}
+		out = append(out, t...)
+	}
+	return out[:keyLen]
+}
@@ -0,0 +1,138 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Catker/acmeDeliver/pkg/testutil"
+)
+
+func TestPEMToPKCS12RoundTrip(t *testing.T) {
+	caPEM, caKeyPEM, err := testutil.GenerateCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, keyPEM, err := testutil.GenerateSignedCert(string(caPEM), string(caKeyPEM), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fullchain := append(append([]byte{}, certPEM...), caPEM...)
+	wantKey, _ := parsePrivateKeyPEM(keyPEM)
+
+	for _, tt := range []struct {
+		password string
+		legacy   bool
+	}{{"s3cret-密码", false}, {"", false}, {"s3cret-密码", true}, {"", true}} {
+		password := tt.password
+		data, err := PEMToPKCS12(fullchain, keyPEM, password, tt.legacy)
+		if err != nil {
+			t.Fatalf("PEMToPKCS12(legacy=%v) error = %v", tt.legacy, err)
+		}
+		key, leaf, ca, err := DecodePKCS12(data, password)
+		if err != nil {
+			t.Fatalf("DecodePKCS12() error = %v", err)
+		}
+		if !reflect.DeepEqual(key, wantKey) {
+			t.Error("解码后的私钥与原私钥不一致")
+		}
+		if leaf.Subject.CommonName != "example.com" || len(ca) != 1 || ca[0].Subject.CommonName != "acmeDeliver Test CA" {
+			t.Errorf("证书链 = %s + %d 个中间证书", leaf.Subject.CommonName, len(ca))
+		}
+
+		if _, _, _, err := DecodePKCS12(data, password+"x"); !errors.Is(err, errPKCS12BadPassword) {
+			t.Errorf("错误密码 error = %v, want errPKCS12BadPassword", err)
+		}
+	}
+
+	// 证书与私钥不匹配时拒绝编码
+	_, otherKey := generateTestKeyPair(t)
+	if _, err := PEMToPKCS12(fullchain, otherKey, "", false); err == nil {
+		t.Error("证书与私钥不匹配时应返回错误")
+	}
+}
+
+func TestPKCS12Matches(t *testing.T) {
+	caPEM, caKeyPEM, err := testutil.GenerateCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, keyPEM, err := testutil.GenerateSignedCert(string(caPEM), string(caKeyPEM), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fullchain := append(append([]byte{}, certPEM...), caPEM...)
+	data, err := PEMToPKCS12(fullchain, keyPEM, "s3cret", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherCert, otherKey, err := testutil.GenerateSignedCert(string(caPEM), string(caKeyPEM), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		fullchain []byte
+		key       []byte
+		password  string
+		legacy    bool
+		want      bool
+	}{
+		{"内容相同", fullchain, keyPEM, "s3cret", false, true},
+		{"证书链不同", certPEM, keyPEM, "s3cret", false, false},
+		{"证书与私钥已更新", append(append([]byte{}, otherCert...), caPEM...), otherKey, "s3cret", false, false},
+		{"密码不同", fullchain, keyPEM, "other", false, false},
+		{"算法不同", fullchain, keyPEM, "s3cret", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PKCS12Matches(data, tt.fullchain, tt.key, tt.password, tt.legacy); got != tt.want {
+				t.Errorf("PKCS12Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if PKCS12Matches([]byte("not pkcs12"), fullchain, keyPEM, "s3cret", false) {
+		t.Error("无效数据时 PKCS12Matches() 应返回 false")
+	}
+}
+
+func TestPKCS12KDF(t *testing.T) {
+	// RFC 7292 附录 B 的 MAC 密钥派生，期望值为 OpenSSL 的测试向量
+	key := pkcs12KDF(sha1.New, bmpPassword("smeg"), []byte{0x0a, 0x58, 0xcf, 0x64, 0x53, 0x0d, 0x82, 0x3f}, 1, 1, 24)
+	want, _ := hex.DecodeString("8aaae6297b6cb04642ab5b077851284eb7128f1a2a7fbca3")
+	if !bytes.Equal(key, want) {
+		t.Errorf("pkcs12KDF() = %x, want %x", key, want)
+	}
+}
+
+func TestRC2(t *testing.T) {
+	// RFC 2268 第 5 节的测试向量
+	tests := []struct {
+		key, plain, cipher string
+		bits               int
+	}{
+		{"0000000000000000", "0000000000000000", "ebb773f993278eff", 63},
+		{"ffffffffffffffff", "ffffffffffffffff", "278b27e42e2f0d49", 64},
+		{"3000000000000000", "1000000000000001", "30649edf9be7d2c2", 64},
+		{"88", "0000000000000000", "61a8a244adacccf0", 64},
+	}
+	for _, tt := range tests {
+		key, _ := hex.DecodeString(tt.key)
+		plain, _ := hex.DecodeString(tt.plain)
+		block := newRC2Cipher(key, tt.bits)
+
+		out := make([]byte, rc2BlockSize)
+		block.Encrypt(out, plain)
+		if hex.EncodeToString(out) != tt.cipher {
+			t.Errorf("RC2(%s, %d) = %x, want %s", tt.key, tt.bits, out, tt.cipher)
+		}
+		block.Decrypt(out, out)
+		if !bytes.Equal(out, plain) {
+			t.Errorf("RC2(%s, %d) 解密 = %x, want %s", tt.key, tt.bits, out, tt.plain)
+		}
+	}
+}
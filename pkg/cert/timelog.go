@@ -0,0 +1,37 @@
+package cert
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// maxSecondsTimestamp 大于该值的时间戳视为毫秒（或更高精度），约为公元 5138 年的秒级时间戳
+const maxSecondsTimestamp = 1e11
+
+// ParseTimeLog 解析 time.log 的内容，返回秒级 Unix 时间戳
+// 忽略首尾空白与换行，只取第一个字段；毫秒、微秒时间戳换算为秒，小数部分被舍去
+// 内容为空、不是数字或不大于 0 时返回 false
+func ParseTimeLog(content []byte) (int64, bool) {
+	fields := bytes.Fields(content)
+	if len(fields) == 0 {
+		return 0, false
+	}
+	ts := fields[0]
+	if i := bytes.IndexByte(ts, '.'); i >= 0 {
+		ts = ts[:i]
+	}
+	for _, c := range ts {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+
+	t, err := strconv.ParseInt(string(ts), 10, 64)
+	if err != nil || t <= 0 {
+		return 0, false
+	}
+	for t >= maxSecondsTimestamp {
+		t /= 1000
+	}
+	return t, true
+}
@@ -0,0 +1,37 @@
+package cert
+
+import "testing"
+
+func TestParseTimeLog(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    int64
+		wantOK  bool
+	}{
+		{"秒级时间戳", "1700000000", 1700000000, true},
+		{"毫秒时间戳", "1700000000123", 1700000000, true},
+		{"微秒时间戳", "1700000000123456", 1700000000, true},
+		{"末尾换行", "1700000000\n", 1700000000, true},
+		{"CRLF 换行", "1700000000\r\n", 1700000000, true},
+		{"首尾空白", "  \t1700000000  \n", 1700000000, true},
+		{"带小数", "1700000000.5", 1700000000, true},
+		{"多余字段", "1700000000 extra\n", 1700000000, true},
+		{"空文件", "", 0, false},
+		{"只有空白", " \n\t", 0, false},
+		{"非数字", "not-a-time", 0, false},
+		{"数字后接字母", "1700000000abc", 0, false},
+		{"负数", "-1700000000", 0, false},
+		{"零", "0", 0, false},
+		{"只有小数点", ".", 0, false},
+		{"溢出", "99999999999999999999", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseTimeLog([]byte(tt.content))
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("ParseTimeLog(%q) = (%d, %v), want (%d, %v)", tt.content, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/config"
+	"github.com/Catker/acmeDeliver/pkg/security"
+)
+
+// REST API 认证请求头（与服务端 pkg/server 保持一致）
+const (
+	headerTimestamp        = "X-Acme-Timestamp"
+	headerSignature        = "X-Acme-Signature"
+	headerSignatureVersion = "X-Acme-Signature-Version"
+)
+
+// APIClient 服务端 REST 管理接口客户端
+type APIClient struct {
+	serverURL string
+	wsPath    string // WebSocket 端点路径，server 以该路径结尾时去除
+	password  string
+	tlsConfig *TLSConfig
+
+	disableLegacyAuth bool // 旧版本服务端拒绝 HMAC 签名时不回退到旧版签名
+
+	adminToken string // 管理接口令牌，非空时以 Authorization: Bearer 认证，不再签名
+}
+
+// NewAPIClient 创建 REST 管理接口客户端
+func NewAPIClient(serverURL, password string, tlsConfig *TLSConfig) *APIClient {
+	return &APIClient{
+		serverURL: serverURL,
+		password:  password,
+		tlsConfig: tlsConfig,
+	}
+}
+
+// SetWSPath 设置 WebSocket 端点路径（需与服务端 ws_path 一致），空表示默认 /ws
+func (c *APIClient) SetWSPath(path string) {
+	c.wsPath = path
+}
+
+// SetAdminToken 设置管理接口令牌（对应配置 admin_token）
+// 推送、下线、轮换密钥等管理接口只接受 admin_token；为空时仍以 password 签名，仅适用于旧版本服务端
+func (c *APIClient) SetAdminToken(token string) {
+	c.adminToken = token
+}
+
+// DomainPushResult 手动推送结果
+type DomainPushResult struct {
+	Domain   string `json:"domain"`
+	ClientID string `json:"client_id,omitempty"`
+	Sent     int    `json:"sent"`
+	Error    string `json:"error,omitempty"`
+}
+
+// PushDomain 请求服务端立即推送指定域名的证书
+// clientID 非空时仅推送给该客户端（admin_push），否则广播给所有订阅者
+func (c *APIClient) PushDomain(ctx context.Context, domain, clientID string) (*DomainPushResult, error) {
+	path := "/api/v1/domains/" + url.PathEscape(domain) + "/push"
+	if clientID != "" {
+		path += "?client_id=" + url.QueryEscape(clientID)
+	}
+
+	var result DomainPushResult
+	if err := c.do(ctx, http.MethodPost, path, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// KeyRotationResult 密钥轮换结果
+type KeyRotationResult struct {
+	Key             string   `json:"key"`
+	Notified        int      `json:"notified"`
+	Reauthenticated []string `json:"reauthenticated"`
+	Missing         []string `json:"missing"`
+	Persisted       bool     `json:"persisted"`
+	Warning         string   `json:"warning,omitempty"`
+}
+
+// RotateKey 请求服务端轮换认证密钥
+// newKey 为空时由服务端生成；window 为等待 daemon 重新认证的时间，0 表示使用服务端配置
+// 服务端在等待结束后才返回，ctx 的超时需大于 window
+func (c *APIClient) RotateKey(ctx context.Context, newKey string, window time.Duration) (*KeyRotationResult, error) {
+	body := map[string]interface{}{}
+	if newKey != "" {
+		body["key"] = newKey
+	}
+	if window > 0 {
+		body["window"] = int(window / time.Second)
+	}
+
+	var result KeyRotationResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/admin/rotate-key", body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// do 发送带认证信息的请求并解析 JSON 响应
+// body 非 nil 时以 JSON 编码作为请求体；ctx 带截止时间时以其为准，否则默认 30 秒超时
+// 设置了 admin_token 时以 Bearer 令牌认证；否则使用 HMAC 签名，旧版本服务端返回 401 时按 disable_legacy_auth 决定是否以旧版签名重试
+func (c *APIClient) do(ctx context.Context, method, path string, body, out interface{}) error {
+	tlsConfig, err := BuildTLSConfig(c.tlsConfig)
+	if err != nil {
+		return fmt.Errorf("TLS 配置错误: %w", err)
+	}
+
+	var data []byte
+	if body != nil {
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	httpClient := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+	if _, ok := ctx.Deadline(); ok {
+		httpClient.Timeout = 0
+	}
+
+	version := security.SignatureV2
+	for {
+		var reqBody io.Reader
+		if body != nil {
+			reqBody = bytes.NewReader(data)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, httpBaseURL(c.serverURL, c.wsPath)+path, reqBody)
+		if err != nil {
+			return err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		if c.adminToken != "" {
+			req.Header.Set("Authorization", "Bearer "+c.adminToken)
+		} else {
+			timestamp := time.Now().Unix()
+			verifier := security.NewSignatureVerifier(c.password)
+			req.Header.Set(headerTimestamp, strconv.FormatInt(timestamp, 10))
+			req.Header.Set(headerSignature, verifier.GenerateSignatureVersion(timestamp, version))
+			req.Header.Set(headerSignatureVersion, strconv.Itoa(version))
+		}
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("请求服务器失败: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			var errResp struct {
+				Error string `json:"error"`
+			}
+			json.NewDecoder(resp.Body).Decode(&errResp)
+			resp.Body.Close()
+			err := fmt.Errorf("服务器返回 %d: %s", resp.StatusCode, errResp.Error)
+			if resp.StatusCode == http.StatusUnauthorized && c.adminToken == "" {
+				serverVersion, _ := strconv.Atoi(resp.Header.Get(headerSignatureVersion))
+				if legacyAuthFallback(slog.Default(), version, serverVersion, c.disableLegacyAuth) {
+					version = security.SignatureV1
+					continue
+				}
+				return legacyAuthError(err, version, serverVersion, c.disableLegacyAuth)
+			}
+			return err
+		}
+
+		defer resp.Body.Close()
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+}
+
+// httpBaseURL 将服务器地址规范化为 HTTP(S) 基础地址
+// 兼容 ws:// / wss:// 写法，并去除末尾的 WebSocket 端点路径与斜杠
+func httpBaseURL(serverURL, wsPath string) string {
+	u := serverURL
+	u = strings.Replace(u, "ws://", "http://", 1)
+	u = strings.Replace(u, "wss://", "https://", 1)
+	u = strings.TrimSuffix(u, "/")
+	u = strings.TrimSuffix(u, config.NormalizeWSPath(wsPath))
+	return u
+}
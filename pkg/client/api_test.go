@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIClient_AdminToken(t *testing.T) {
+	var authorization, signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		signature = r.Header.Get(headerSignature)
+		if authorization != "Bearer admin-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"管理令牌无效"}`))
+			return
+		}
+		w.Write([]byte(`{"domain":"example.com","sent":1}`))
+	}))
+	defer server.Close()
+
+	api := NewAPIClient(server.URL, "test-password", nil)
+	api.SetAdminToken("admin-secret")
+	result, err := api.PushDomain(context.Background(), "example.com", "")
+	if err != nil || result.Sent != 1 {
+		t.Fatalf("PushDomain() = %+v, %v", result, err)
+	}
+	if signature != "" {
+		t.Errorf("设置 admin_token 后不应携带签名请求头, got %q", signature)
+	}
+
+	// 令牌错误时直接失败，不回退到旧版签名
+	requests := 0
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"管理令牌无效"}`))
+	})
+	api.SetAdminToken("wrong-token")
+	if _, err := api.PushDomain(context.Background(), "example.com", ""); err == nil {
+		t.Fatal("令牌错误时 PushDomain() 应返回错误")
+	}
+	if requests != 1 {
+		t.Errorf("令牌错误时发送了 %d 次请求, want 1", requests)
+	}
+}
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// backupTimeFormat 备份文件名中的时间戳，按字典序排序即按时间排序
+const backupTimeFormat = "20060102T150405.000000000"
+
+// DeployBackup 部署前对已有部署文件的备份，reload 失败时用于回滚
+type DeployBackup struct {
+	Domain string
+	files  map[string]string // 部署路径 -> 备份路径
+}
+
+// BackupDeployedFiles 覆盖部署文件前将已存在的文件复制为 <部署路径>.bak.<时间戳>，每个部署文件只保留最近 retention 份
+// retention 不大于 0 时不备份并返回 nil；不存在的部署文件（首次部署）不备份
+func BackupDeployedFiles(domain string, paths []string, retention int, now time.Time) (*DeployBackup, error) {
+	if retention <= 0 {
+		return nil, nil
+	}
+	b := &DeployBackup{Domain: domain, files: make(map[string]string)}
+	suffix := ".bak." + now.UTC().Format(backupTimeFormat)
+	for _, path := range paths {
+		if _, done := b.files[path]; done || path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		if err := copyFileAtomic(path, path+suffix); err != nil {
+			return nil, fmt.Errorf("备份 %s 失败: %w", path, err)
+		}
+		b.files[path] = path + suffix
+		pruneBackups(path, retention)
+	}
+	return b, nil
+}
+
+// Restore 用备份覆盖部署文件，回滚到部署前的证书；备份文件保留
+func (b *DeployBackup) Restore() error {
+	if b == nil {
+		return nil
+	}
+	paths := make([]string, 0, len(b.files))
+	for path := range b.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var errs []error
+	for _, path := range paths {
+		if err := copyFileAtomic(b.files[path], path); err != nil {
+			errs = append(errs, fmt.Errorf("恢复 %s 失败: %w", path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Empty 判断是否没有可用于回滚的备份
+func (b *DeployBackup) Empty() bool {
+	return b == nil || len(b.files) == 0
+}
+
+// RollbackDeployments reload 失败后恢复全部备份并重新执行 reload
+// 恢复失败时不再执行 reload；全部成功时返回 nil
+func RollbackDeployments(backups []*DeployBackup, reload func() error) error {
+	var errs []error
+	for _, b := range backups {
+		if err := b.Restore(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	if err := reload(); err != nil {
+		return fmt.Errorf("回滚后重新执行重载命令失败: %w", err)
+	}
+	return nil
+}
+
+// RollbackError 组合 reload 失败与回滚结果，用于日志与 cert_ack
+func RollbackError(reloadErr, rollbackErr error) error {
+	if rollbackErr != nil {
+		return fmt.Errorf("执行重载命令失败: %w；回滚失败: %v", reloadErr, rollbackErr)
+	}
+	return fmt.Errorf("执行重载命令失败，已回滚到部署前的证书: %w", reloadErr)
+}
+
+// pruneBackups 删除 path 最旧的备份，只保留最近 retention 份
+func pruneBackups(path string, retention int) {
+	matches, err := filepath.Glob(globEscape(path) + ".bak.*")
+	if err != nil || len(matches) <= retention {
+		return
+	}
+	sort.Strings(matches)
+	for _, old := range matches[:len(matches)-retention] {
+		os.Remove(old)
+	}
+}
+
+// globEscape 转义路径中的 glob 元字符
+func globEscape(path string) string {
+	var buf []byte
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '*', '?', '[', '\\':
+			buf = append(buf, '\\')
+		}
+		buf = append(buf, path[i])
+	}
+	return string(buf)
+}
+
+// copyFileAtomic 将 src 复制到 dst（先写临时文件再重命名），保留 src 的权限与属主
+func copyFileAtomic(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	tmp := dst + ".tmp"
+	if err := os.WriteFile(tmp, content, info.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, info.Mode().Perm()); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	preserveOwner(info, tmp)
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/config"
+)
+
+// writeReloadScript 写入可执行的 shell 脚本作为 reload 命令
+func writeReloadScript(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "reload.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBackupDeployedFiles(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(keyPath, []byte("key-0"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// 未启用备份
+	if b, err := BackupDeployedFiles("example.com", []string{keyPath}, 0, time.Now()); err != nil || b != nil {
+		t.Fatalf("retention=0: BackupDeployedFiles() = %v, %v, want nil", b, err)
+	}
+
+	// 每次部署前备份，只保留最近 2 份；不存在的文件不备份
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	var last *DeployBackup
+	for i := 1; i <= 4; i++ {
+		b, err := BackupDeployedFiles("example.com", []string{certPath, keyPath, keyPath, ""}, 2, start.Add(time.Duration(i)*time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		last = b
+		os.WriteFile(keyPath, []byte("key-"+string(rune('0'+i))), 0600)
+	}
+	if len(last.files) != 1 {
+		t.Errorf("备份文件数 = %d, want 1（cert.pem 不存在）", len(last.files))
+	}
+	matches, _ := filepath.Glob(keyPath + ".bak.*")
+	if len(matches) != 2 {
+		t.Fatalf("保留的备份 = %v, want 2 份", matches)
+	}
+	if data, _ := os.ReadFile(matches[0]); string(data) != "key-2" {
+		t.Errorf("最旧的保留备份内容 = %q, want key-2", data)
+	}
+	if info, _ := os.Stat(matches[1]); info.Mode().Perm() != 0600 {
+		t.Errorf("备份文件权限 = %v, want 0600", info.Mode().Perm())
+	}
+
+	// 恢复最近一次部署前的文件
+	if err := last.Restore(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(keyPath); string(data) != "key-3" {
+		t.Errorf("恢复后内容 = %q, want key-3", data)
+	}
+}
+
+func TestRollbackDeployments(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cert.pem")
+	os.WriteFile(path, []byte("old"), 0644)
+	b, err := BackupDeployedFiles("example.com", []string{path}, 1, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(path, []byte("new"), 0644)
+
+	reloads := 0
+	if err := RollbackDeployments([]*DeployBackup{b, nil}, func() error { reloads++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "old" || reloads != 1 {
+		t.Errorf("回滚后内容 = %q, reload 次数 = %d", data, reloads)
+	}
+
+	// 备份丢失时不再执行 reload
+	os.Remove(b.files[path])
+	if err := RollbackDeployments([]*DeployBackup{b}, func() error { reloads++; return nil }); err == nil || reloads != 1 {
+		t.Errorf("备份丢失: err = %v, reload 次数 = %d", err, reloads)
+	}
+}
+
+func TestDaemon_RollbackOnReloadFailure(t *testing.T) {
+	workDir, deployDir, site := setupStartupDeploy(t)
+	certPath := filepath.Join(deployDir, "example.com", "cert.pem")
+	os.MkdirAll(filepath.Dir(certPath), 0755)
+	os.WriteFile(certPath, []byte("old-cert"), 0644)
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(certPath, old, old)
+
+	// 新证书部署后 reload 失败，恢复旧证书后 reload 成功
+	site.ReloadCmd = writeReloadScript(t, "grep -q old-cert "+certPath+" || exit 1")
+	site.PostCmd = "touch " + filepath.Join(deployDir, "post")
+	d := NewDaemon(&DaemonConfig{WorkDir: workDir, Sites: []config.SiteDeployConfig{site}, ReloadDebounce: time.Hour, BackupRetention: 3})
+
+	d.deployFromWorkdir()
+	d.flushReloads()
+
+	if data, _ := os.ReadFile(certPath); string(data) != "old-cert" {
+		t.Errorf("reload 失败后 cert.pem = %q, want 回滚到 old-cert", data)
+	}
+	if _, err := os.Stat(filepath.Join(deployDir, "example.com", "key.pem")); err != nil {
+		t.Errorf("首次部署的文件没有备份，应保留: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(deployDir, "post")); !os.IsNotExist(err) {
+		t.Error("reload 失败时不应执行 postcmd")
+	}
+	status := d.Status()
+	deploy := status.Domains["example.com"].LastDeploy
+	if deploy == nil || deploy.OK || !strings.Contains(deploy.Error, "已回滚") {
+		t.Errorf("last_deploy = %+v, want 回滚错误", deploy)
+	}
+	if status.LastReload == nil || !status.LastReload.OK {
+		t.Errorf("last_reload = %+v, want 回滚后重新 reload 成功", status.LastReload)
+	}
+	if len(d.pendingDeploys) != 0 {
+		t.Errorf("reload 结束后仍有等待 reload 结果的部署: %v", d.pendingDeploys)
+	}
+
+	// 服务端推送的部署回滚时发送 cert_ack，未连接时不应 panic
+	os.WriteFile(certPath, []byte("new-cert"), 0644)
+	d.scheduleReload("example.com", &site, &DeployBackup{Domain: "example.com", files: map[string]string{certPath: certPath + ".missing"}}, true)
+	d.flushReloads()
+	if deploy := d.Status().Domains["example.com"].LastDeploy; deploy == nil || !strings.Contains(deploy.Error, "回滚失败") {
+		t.Errorf("备份丢失时 last_deploy = %+v, want 回滚失败", deploy)
+	}
+}
@@ -3,34 +3,87 @@ package client
 
 import (
 	"context"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 
+	"github.com/Catker/acmeDeliver/pkg/cert"
+	"github.com/Catker/acmeDeliver/pkg/config"
 	"github.com/Catker/acmeDeliver/pkg/security"
 	ws "github.com/Catker/acmeDeliver/pkg/websocket"
 )
 
+// CertClient 一次性命令使用的证书服务客户端，由 WSClient 实现
+// 命令行逻辑依赖该接口，测试中可替换为桩实现
+type CertClient interface {
+	Connect(ctx context.Context) error
+	Close()
+	DownloadCert(ctx context.Context, domain string, force bool) (*CertificateFiles, error)
+	DownloadCertSince(ctx context.Context, domain string, since int64, force bool) (*CertificateFiles, error)
+	GetServerStatus(ctx context.Context) (*ws.StatusResponse, error)
+	GetServerStatusWithCRL(ctx context.Context) (*ws.StatusResponse, error)
+	ListDomains(ctx context.Context) ([]ws.DomainEntry, error)
+}
+
 // WSClient WebSocket 统一客户端
 // 提供 CLI 一次性操作（下载证书、列表查询）和 Daemon 模式共用的底层通信
 type WSClient struct {
 	serverURL string
+	wsPath    string // WebSocket 端点路径，空表示默认 /ws
 	password  string
 	tlsConfig *TLSConfig // TLS 配置（可选）
 	conn      *websocket.Conn
+	done      chan struct{} // 当前连接的读取循环退出时关闭
+	readErr   error         // 读取循环因消息超限退出时的错误，done 关闭前设置
 	mu        sync.Mutex
+	connMu    sync.Mutex // 串行化断线重连
 
-	// 响应等待
-	responses     map[string]chan *ws.Message
+	// 响应等待（按请求 ID 关联，支持同类请求并发）
+	responses     map[string]*pendingResponse
 	responsesMu   sync.Mutex
-	authenticated bool
+	authenticated atomic.Bool
+
+	requestTimeout time.Duration // 请求超时，0 表示按请求类型使用默认值
+	maxMessageSize int64         // 单条消息大小上限，0 表示使用 ws.DefaultMaxMessageSize
+	connectTimeout time.Duration // 建立连接与认证的超时，0 表示使用默认值
+
+	// 重试（Connect、证书下载与状态查询）
+	retries        int           // 最大尝试次数（含首次）
+	retryBaseDelay time.Duration // 指数退避基数
+	jitterRand     io.Reader
+
+	// 自动重连（发送请求时连接已断开则重连并重发）
+	autoReconnect        bool
+	maxReconnectAttempts int          // 收到响应前连续自动重连的次数上限
+	reconnectAttempts    atomic.Int32 // 自上次收到响应以来的自动重连次数
+
+	disableLegacyAuth bool // 旧版本服务端拒绝 HMAC 签名时不回退到旧版签名
 }
 
+var (
+	errRequestTimeout    = errors.New("请求超时")
+	errConnectionClosed  = errors.New("连接已断开")
+	errServerUnavailable = errors.New("服务端暂时不可用")
+	errMessageTooBig     = errors.New("消息超过大小限制")
+)
+
+// 请求默认超时
+const (
+	DefaultConnectTimeout  = 10 * time.Second // WebSocket 握手与认证
+	DefaultDownloadTimeout = 30 * time.Second // 证书下载
+	DefaultQueryTimeout    = 10 * time.Second // 状态与域名列表查询
+)
+
 // NewWSClient 创建新的 WebSocket 客户端
 // tlsConfig 可为 nil，表示使用系统默认 TLS 配置
 func NewWSClient(serverURL, password string, tlsConfig *TLSConfig) *WSClient {
@@ -38,23 +91,98 @@ func NewWSClient(serverURL, password string, tlsConfig *TLSConfig) *WSClient {
 		serverURL: serverURL,
 		password:  password,
 		tlsConfig: tlsConfig,
-		responses: make(map[string]chan *ws.Message),
+		responses: make(map[string]*pendingResponse),
+
+		retries:        1,
+		retryBaseDelay: defaultRetryBaseDelay,
+		jitterRand:     rand.Reader,
+
+		maxReconnectAttempts: DefaultMaxReconnectAttempts,
 	}
 }
 
-// Connect 连接服务器并完成认证
-func (c *WSClient) Connect(ctx context.Context) error {
-	// 解析服务器地址
-	wsURL := c.serverURL
-	if !strings.HasPrefix(wsURL, "ws://") && !strings.HasPrefix(wsURL, "wss://") {
-		wsURL = strings.Replace(wsURL, "http://", "ws://", 1)
-		wsURL = strings.Replace(wsURL, "https://", "wss://", 1)
+// SetRequestTimeout 设置证书下载、状态与域名列表查询的超时，d 不大于 0 时恢复默认值
+// CRL 检查由服务端逐个下载 CRL，始终使用更长的固定超时
+func (c *WSClient) SetRequestTimeout(d time.Duration) {
+	c.requestTimeout = d
+}
+
+// SetConnectTimeout 设置 WebSocket 握手与认证各自的超时，d 不大于 0 时恢复默认值
+func (c *WSClient) SetConnectTimeout(d time.Duration) {
+	c.connectTimeout = d
+}
+
+// handshakeTimeout 返回已设置的连接超时，未设置时返回 DefaultConnectTimeout
+func (c *WSClient) handshakeTimeout() time.Duration {
+	if c.connectTimeout > 0 {
+		return c.connectTimeout
 	}
-	// 确保所有 URL 都追加 /ws 后缀
-	if !strings.HasSuffix(wsURL, "/ws") {
-		wsURL = wsURL + "/ws"
+	return DefaultConnectTimeout
+}
+
+// timeout 返回已设置的请求超时，未设置时返回 def
+func (c *WSClient) timeout(def time.Duration) time.Duration {
+	if c.requestTimeout > 0 {
+		return c.requestTimeout
 	}
+	return def
+}
 
+// SetMaxMessageSize 设置单条消息的大小上限（对应配置 max_message_size），n 不大于 0 时恢复默认值
+func (c *WSClient) SetMaxMessageSize(n int64) {
+	c.maxMessageSize = n
+}
+
+// readLimit 返回单条消息大小上限，limit 不大于 0 时为 ws.DefaultMaxMessageSize
+func readLimit(limit int64) int64 {
+	if limit > 0 {
+		return limit
+	}
+	return ws.DefaultMaxMessageSize
+}
+
+// messageSizeError 将消息超限导致的断开转换为明确的错误，其他错误返回 nil
+// 本端读取超限时为 ErrReadLimit，服务端拒绝超限消息时以 close 1009 关闭连接
+func messageSizeError(err error, limit int64) error {
+	if errors.Is(err, websocket.ErrReadLimit) {
+		return fmt.Errorf("%w: 服务端消息超过客户端 max_message_size（%d 字节），请调大客户端配置的 max_message_size", errMessageTooBig, readLimit(limit))
+	}
+	if websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+		return fmt.Errorf("%w: 请求超过服务端 max_message_size，连接已被服务端关闭", errMessageTooBig)
+	}
+	return nil
+}
+
+// SetWSPath 设置 WebSocket 端点路径（需与服务端 ws_path 一致），空表示默认 /ws
+func (c *WSClient) SetWSPath(path string) {
+	c.wsPath = path
+}
+
+// websocketURL 将服务器地址转换为 WebSocket 地址：http(s):// 转换为 ws(s)://，
+// 地址未以端点路径结尾时追加该路径
+func websocketURL(serverURL, wsPath string) string {
+	u := serverURL
+	if !strings.HasPrefix(u, "ws://") && !strings.HasPrefix(u, "wss://") {
+		u = strings.Replace(u, "http://", "ws://", 1)
+		u = strings.Replace(u, "https://", "wss://", 1)
+	}
+	path := config.NormalizeWSPath(wsPath)
+	if strings.HasSuffix(u, path) {
+		return u
+	}
+	return strings.TrimSuffix(u, "/") + path
+}
+
+// Connect 连接服务器并完成认证，网络错误时按 SetRetries 设置重试
+func (c *WSClient) Connect(ctx context.Context) error {
+	return c.retry(ctx, "连接服务器", func() error {
+		return c.connect(ctx)
+	})
+}
+
+// connect 建立连接并完成认证（单次尝试）
+func (c *WSClient) connect(ctx context.Context) error {
+	wsURL := websocketURL(c.serverURL, c.wsPath)
 	slog.Debug("正在连接服务器", "url", wsURL)
 
 	// 构建 TLS 配置
@@ -65,21 +193,31 @@ func (c *WSClient) Connect(ctx context.Context) error {
 
 	// 建立连接（带连接超时）
 	dialer := websocket.Dialer{
-		HandshakeTimeout: 10 * time.Second,
-		TLSClientConfig:  tlsConfig,
+		HandshakeTimeout:  c.handshakeTimeout(),
+		TLSClientConfig:   tlsConfig,
+		EnableCompression: true, // 服务端启用 ws_compression 时协商压缩
 	}
-	conn, _, err := dialer.DialContext(ctx, wsURL, nil)
+	conn, resp, err := dialer.DialContext(ctx, wsURL, nil)
 	if err != nil {
+		if resp != nil && resp.StatusCode >= 500 {
+			return fmt.Errorf("连接服务器失败: %w (HTTP %d)", errServerUnavailable, resp.StatusCode)
+		}
 		return fmt.Errorf("连接服务器失败: %w", err)
 	}
+	conn.SetReadLimit(readLimit(c.maxMessageSize))
+	done := make(chan struct{})
+	c.mu.Lock()
 	c.conn = conn
+	c.done = done
+	c.readErr = nil
+	c.mu.Unlock()
 
 	// 启动消息读取循环
-	go c.readLoop()
+	go c.readLoop(conn, done)
 
 	// 发送认证请求
 	if err := c.authenticate(ctx); err != nil {
-		c.conn.Close()
+		conn.Close()
 		return fmt.Errorf("认证失败: %w", err)
 	}
 
@@ -89,68 +227,131 @@ func (c *WSClient) Connect(ctx context.Context) error {
 
 // Close 关闭连接
 func (c *WSClient) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if c.conn != nil {
 		c.conn.Close()
 	}
 }
 
+// reconnectIfClosed 在连接已断开时重新连接并认证
+// 从未连接过时不做处理，由调用方返回"未认证"
+func (c *WSClient) reconnectIfClosed(ctx context.Context) error {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+
+	c.mu.Lock()
+	done := c.done
+	c.mu.Unlock()
+	if done == nil {
+		return nil
+	}
+	select {
+	case <-done:
+	default:
+		return nil
+	}
+
+	slog.Info("与服务器的连接已断开，正在重新连接")
+	return c.connect(ctx)
+}
+
 // authenticate 发送认证请求并等待响应
+// 使用 HMAC 签名，旧版本服务端拒绝时按 disable_legacy_auth 决定是否回退到旧版签名重新认证
 func (c *WSClient) authenticate(ctx context.Context) error {
+	version := security.SignatureV2
+	for {
+		serverVersion, err := c.authenticateVersion(ctx, version)
+		if err == nil {
+			return nil
+		}
+		if legacyAuthFallback(slog.Default(), version, serverVersion, c.disableLegacyAuth) {
+			version = security.SignatureV1
+			continue
+		}
+		return legacyAuthError(err, version, serverVersion, c.disableLegacyAuth)
+	}
+}
+
+// authenticateVersion 以指定版本的签名认证，返回服务端支持的最高签名版本（旧版本服务端为 0，未收到响应时为 -1）
+func (c *WSClient) authenticateVersion(ctx context.Context, version int) (int, error) {
 	timestamp := time.Now().Unix()
 
 	// 使用统一的签名验证器生成签名
 	verifier := security.NewSignatureVerifier(c.password)
-	signature := verifier.GenerateSignature(timestamp)
+	signature := verifier.GenerateSignatureVersion(timestamp, version)
 
 	authReq := &ws.AuthRequest{
-		ClientID:  "cli-client",
-		Signature: signature,
-		Domains:   []string{}, // CLI 模式不订阅任何域名
+		ClientID:         "cli-client",
+		Signature:        signature,
+		Domains:          []string{}, // CLI 模式不订阅任何域名
+		SignatureVersion: version,
 	}
 
 	msg, err := ws.NewMessage(ws.MsgTypeAuth, authReq)
 	if err != nil {
-		return err
+		return -1, err
 	}
 	msg.Timestamp = timestamp
+	msg.Version = ws.CurrentProtocolVersion
 
-	// 注册响应等待
-	respChan := c.registerResponse(ws.MsgTypeAuthResult)
-	defer c.unregisterResponse(ws.MsgTypeAuthResult)
-
-	// 发送认证请求
-	if err := c.sendMessage(msg); err != nil {
-		return err
+	sent := time.Now()
+	resp, err := c.request(ctx, msg, ws.MsgTypeAuthResult, c.handshakeTimeout())
+	if err != nil {
+		return -1, err
 	}
 
-	// 等待认证响应
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case <-time.After(10 * time.Second):
-		return fmt.Errorf("认证超时")
-	case resp := <-respChan:
-		var authResp ws.AuthResponse
-		if err := resp.ParseData(&authResp); err != nil {
-			return fmt.Errorf("解析认证响应失败: %w", err)
-		}
-		if !authResp.Success {
-			return fmt.Errorf("认证被拒绝: %s", authResp.Message)
+	var authResp ws.AuthResponse
+	if err := resp.ParseData(&authResp); err != nil {
+		return -1, fmt.Errorf("解析认证响应失败: %w", err)
+	}
+	skew, hasSkew := clockSkew(authResp.ServerTime, sent, time.Now())
+	if hasSkew {
+		logClockSkew(slog.Default(), skew)
+	}
+	if !authResp.Success {
+		// 时钟偏差超过容差时，"时间戳已过期" 的真实原因是本机时间不准
+		if hasSkew && clockSkewExceeded(skew) {
+			return authResp.SignatureVersion, fmt.Errorf("认证被拒绝: %s（%s，超过容差 %s，请校准系统时间）",
+				authResp.Message, describeClockSkew(skew), clockSkewTolerance)
 		}
-		c.authenticated = true
-		return nil
+		return authResp.SignatureVersion, fmt.Errorf("认证被拒绝: %s", authResp.Message)
 	}
+	c.authenticated.Store(true)
+	return authResp.SignatureVersion, nil
 }
 
 // DownloadCert 下载证书（CLI 一次性操作）
 func (c *WSClient) DownloadCert(ctx context.Context, domain string, force bool) (*CertificateFiles, error) {
-	if !c.authenticated {
+	return c.DownloadCertSince(ctx, domain, 0, force)
+}
+
+// DownloadCertSince 下载比本地时间戳 since 更新的证书
+// 服务端证书未更新时返回的文件为空，仅 Timestamp 有效；force 为 true 时总是返回文件
+// 网络错误时按 SetRetries 设置重试，连接已断开时先重新连接
+func (c *WSClient) DownloadCertSince(ctx context.Context, domain string, since int64, force bool) (*CertificateFiles, error) {
+	var certs *CertificateFiles
+	err := c.retry(ctx, "下载证书", func() error {
+		if err := c.reconnectIfClosed(ctx); err != nil {
+			return err
+		}
+		var err error
+		certs, err = c.downloadCertSince(ctx, domain, since, force)
+		return err
+	})
+	return certs, err
+}
+
+// downloadCertSince 发送证书请求并等待响应（单次尝试）
+func (c *WSClient) downloadCertSince(ctx context.Context, domain string, since int64, force bool) (*CertificateFiles, error) {
+	if !c.authenticated.Load() {
 		return nil, fmt.Errorf("未认证")
 	}
 
 	req := &ws.CertRequest{
-		Domain: domain,
-		Force:  force,
+		Domain:    domain,
+		Force:     force,
+		Timestamp: since,
 	}
 
 	msg, err := ws.NewMessage(ws.MsgTypeCertRequest, req)
@@ -158,103 +359,232 @@ func (c *WSClient) DownloadCert(ctx context.Context, domain string, force bool)
 		return nil, err
 	}
 
-	// 注册响应等待
-	respChan := c.registerResponse(ws.MsgTypeCertResponse)
-	defer c.unregisterResponse(ws.MsgTypeCertResponse)
-
-	// 发送请求
-	if err := c.sendMessage(msg); err != nil {
+	resp, err := c.request(ctx, msg, ws.MsgTypeCertResponse, c.timeout(DefaultDownloadTimeout))
+	if err != nil {
 		return nil, err
 	}
 
-	// 等待响应
-	select {
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	case <-time.After(30 * time.Second):
-		return nil, fmt.Errorf("请求超时")
-	case resp := <-respChan:
-		var certResp ws.CertResponse
-		if err := resp.ParseData(&certResp); err != nil {
-			return nil, fmt.Errorf("解析响应失败: %w", err)
-		}
-		if certResp.Error != "" {
-			return nil, fmt.Errorf("服务器错误: %s", certResp.Error)
-		}
+	var certResp ws.CertResponse
+	if err := resp.ParseData(&certResp); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+	if certResp.Error != "" {
+		return nil, fmt.Errorf("服务器错误: %s", certResp.Error)
+	}
 
-		// 转换为 CertificateFiles
-		certs := &CertificateFiles{}
-		if data, ok := certResp.Files["cert.pem"]; ok {
-			certs.Cert = data
-		}
-		if data, ok := certResp.Files["key.pem"]; ok {
-			certs.Key = data
-		}
-		if data, ok := certResp.Files["fullchain.pem"]; ok {
-			certs.Fullchain = data
-		}
-		return certs, nil
+	// 转换为 CertificateFiles，服务端使用非标准文件名时先映射为标准文件名
+	files := cert.ToStandard(certResp.FileNames, certResp.Files)
+	certs := &CertificateFiles{Timestamp: certResp.Timestamp, Checksums: cert.ToStandard(certResp.FileNames, certResp.Checksums)}
+	if data, ok := files[cert.CertFileName]; ok {
+		certs.Cert = data
+	}
+	if data, ok := files[cert.KeyFileName]; ok {
+		certs.Key = data
 	}
+	if data, ok := files[cert.FullchainFileName]; ok {
+		certs.Fullchain = data
+	}
+	return certs, nil
 }
 
 // GetServerStatus 获取服务器状态（在线客户端 + 证书状态）
+// 网络错误时按 SetRetries 设置重试，连接已断开时先重新连接
 func (c *WSClient) GetServerStatus(ctx context.Context) (*ws.StatusResponse, error) {
-	if !c.authenticated {
+	var status *ws.StatusResponse
+	err := c.retry(ctx, "查询服务器状态", func() error {
+		if err := c.reconnectIfClosed(ctx); err != nil {
+			return err
+		}
+		var err error
+		status, err = c.getServerStatus(ctx, &ws.StatusRequest{}, c.timeout(DefaultQueryTimeout))
+		return err
+	})
+	return status, err
+}
+
+// GetServerStatusWithCRL 获取服务器状态，并由服务端通过 CRL 检查各域名证书是否已被吊销
+// 服务端需逐个下载 CRL，等待时间较长
+func (c *WSClient) GetServerStatusWithCRL(ctx context.Context) (*ws.StatusResponse, error) {
+	return c.getServerStatus(ctx, &ws.StatusRequest{CheckCRL: true}, 2*time.Minute)
+}
+
+// getServerStatus 发送状态请求并等待响应
+func (c *WSClient) getServerStatus(ctx context.Context, req *ws.StatusRequest, timeout time.Duration) (*ws.StatusResponse, error) {
+	if !c.authenticated.Load() {
 		return nil, fmt.Errorf("未认证")
 	}
 
-	req := &ws.StatusRequest{}
-
 	msg, err := ws.NewMessage(ws.MsgTypeStatusRequest, req)
 	if err != nil {
 		return nil, err
 	}
 
-	// 注册响应等待
-	respChan := c.registerResponse(ws.MsgTypeStatusResponse)
-	defer c.unregisterResponse(ws.MsgTypeStatusResponse)
+	resp, err := c.request(ctx, msg, ws.MsgTypeStatusResponse, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	var statusResp ws.StatusResponse
+	if err := resp.ParseData(&statusResp); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+	if statusResp.Error != "" {
+		return nil, fmt.Errorf("服务器错误: %s", statusResp.Error)
+	}
+	return &statusResp, nil
+}
+
+// ListDomains 获取服务端证书目录中的域名列表（仅域名、时间戳与文件名）
+func (c *WSClient) ListDomains(ctx context.Context) ([]ws.DomainEntry, error) {
+	if !c.authenticated.Load() {
+		return nil, fmt.Errorf("未认证")
+	}
+
+	msg, err := ws.NewMessage(ws.MsgTypeListRequest, nil)
+	if err != nil {
+		return nil, err
+	}
 
-	// 发送请求
-	if err := c.sendMessage(msg); err != nil {
+	resp, err := c.request(ctx, msg, ws.MsgTypeListResponse, c.timeout(DefaultQueryTimeout))
+	if err != nil {
 		return nil, err
 	}
 
-	// 等待响应
+	var listResp ws.ListResponse
+	if err := resp.ParseData(&listResp); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+	if listResp.Error != "" {
+		return nil, fmt.Errorf("服务器错误: %s", listResp.Error)
+	}
+	return listResp.Domains, nil
+}
+
+// request 发送请求并等待 RequestID 匹配的响应
+// 服务端返回同一 RequestID 的 error 消息时转换为错误
+// 启用自动重连时，等待响应期间连接断开会重连并以同一 RequestID 重发一次
+func (c *WSClient) request(ctx context.Context, msg *ws.Message, respType string, timeout time.Duration) (*ws.Message, error) {
+	msg.RequestID = uuid.New().String()
+
+	// 注册响应等待
+	respChan := c.registerResponse(msg.RequestID, respType)
+	defer c.unregisterResponse(msg.RequestID)
+
+	resent := false
+	for {
+		// 发送请求（发送失败时 sendMessage 可能已重连，之后再取当前连接的 done）
+		if err := c.sendMessage(ctx, msg); err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		done := c.done
+		c.mu.Unlock()
+
+		resp, err := c.awaitResponse(ctx, respChan, done, timeout)
+		if errors.Is(err, errConnectionClosed) && !resent && c.canAutoReconnect(msg) {
+			if err := c.reconnectAfter(ctx, done, err); err != nil {
+				return nil, err
+			}
+			resent = true
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		// 收到响应后重新计算连续自动重连次数（Connect 内的认证响应不算）
+		if msg.Type != ws.MsgTypeAuth {
+			c.reconnectAttempts.Store(0)
+		}
+		return responseResult(resp)
+	}
+}
+
+// responseResult 将服务端返回的 error 消息转换为错误
+func responseResult(resp *ws.Message) (*ws.Message, error) {
+	if resp.Type == ws.MsgTypeError {
+		var errData ws.ErrorData
+		if err := resp.ParseData(&errData); err != nil {
+			return nil, fmt.Errorf("解析错误响应失败: %w", err)
+		}
+		return nil, fmt.Errorf("服务器错误 (%d): %s", errData.Code, errData.Message)
+	}
+	return resp, nil
+}
+
+// awaitResponse 等待 respChan 上的响应，done 关闭时返回读取循环的错误或 errConnectionClosed
+func (c *WSClient) awaitResponse(ctx context.Context, respChan chan *ws.Message, done chan struct{}, timeout time.Duration) (*ws.Message, error) {
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
-	case <-time.After(10 * time.Second):
-		return nil, fmt.Errorf("请求超时")
-	case resp := <-respChan:
-		var statusResp ws.StatusResponse
-		if err := resp.ParseData(&statusResp); err != nil {
-			return nil, fmt.Errorf("解析响应失败: %w", err)
-		}
-		if statusResp.Error != "" {
-			return nil, fmt.Errorf("服务器错误: %s", statusResp.Error)
+	case <-done:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.readErr != nil {
+			return nil, c.readErr
 		}
-		return &statusResp, nil
+		return nil, errConnectionClosed
+	case <-time.After(timeout):
+		return nil, errRequestTimeout
+	case resp := <-respChan:
+		return resp, nil
 	}
 }
 
 // sendMessage 发送消息
-func (c *WSClient) sendMessage(msg *ws.Message) error {
+// 启用自动重连时发送失败（连接已断开）会调用 Reconnect 并重发一次，认证消息除外
+func (c *WSClient) sendMessage(ctx context.Context, msg *ws.Message) error {
 	data, err := json.Marshal(msg)
 	if err != nil {
 		return err
 	}
 
+	done, err := c.writeMessage(data)
+	if err == nil || !errors.Is(err, errConnectionClosed) || !c.canAutoReconnect(msg) {
+		return err
+	}
+	if err := c.reconnectAfter(ctx, done, err); err != nil {
+		return err
+	}
+	_, err = c.writeMessage(data)
+	return err
+}
+
+// writeMessage 在当前连接上发送数据，返回该连接的 done
+// 连接不存在或读取循环已退出时返回 errConnectionClosed（因消息超限断开时返回该错误）
+func (c *WSClient) writeMessage(data []byte) (chan struct{}, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	return c.conn.WriteMessage(websocket.TextMessage, data)
+	if c.conn == nil {
+		return c.done, errConnectionClosed
+	}
+	select {
+	case <-c.done:
+		if c.readErr != nil {
+			return c.done, c.readErr
+		}
+		return c.done, errConnectionClosed
+	default:
+	}
+	if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		return c.done, fmt.Errorf("%w: %v", errConnectionClosed, err)
+	}
+	return c.done, nil
 }
 
-// readLoop 消息读取循环
-func (c *WSClient) readLoop() {
+// readLoop 消息读取循环，退出时关闭 done 通知等待中的请求连接已断开
+func (c *WSClient) readLoop(conn *websocket.Conn, done chan struct{}) {
+	defer close(done)
 	for {
-		_, data, err := c.conn.ReadMessage()
+		_, data, err := conn.ReadMessage()
 		if err != nil {
+			if sizeErr := messageSizeError(err, c.maxMessageSize); sizeErr != nil {
+				slog.Error("连接因消息超限断开", "error", sizeErr)
+				c.mu.Lock()
+				c.readErr = sizeErr
+				c.mu.Unlock()
+				return
+			}
 			slog.Debug("WebSocket 读取结束", "error", err)
 			return
 		}
@@ -270,35 +600,54 @@ func (c *WSClient) readLoop() {
 	}
 }
 
+// pendingResponse 等待中的请求
+type pendingResponse struct {
+	respType string // 期望的响应类型（兼容不回传 RequestID 的旧版服务端）
+	ch       chan *ws.Message
+}
+
 // registerResponse 注册响应等待通道
-func (c *WSClient) registerResponse(msgType string) chan *ws.Message {
+func (c *WSClient) registerResponse(requestID, respType string) chan *ws.Message {
 	c.responsesMu.Lock()
 	defer c.responsesMu.Unlock()
 
 	ch := make(chan *ws.Message, 1)
-	c.responses[msgType] = ch
+	c.responses[requestID] = &pendingResponse{respType: respType, ch: ch}
 	return ch
 }
 
 // unregisterResponse 注销响应等待通道
-func (c *WSClient) unregisterResponse(msgType string) {
+func (c *WSClient) unregisterResponse(requestID string) {
 	c.responsesMu.Lock()
 	defer c.responsesMu.Unlock()
 
-	delete(c.responses, msgType)
+	delete(c.responses, requestID)
 }
 
-// dispatchResponse 分发响应到等待通道
+// dispatchResponse 按 RequestID 分发响应到等待通道
+// 旧版服务端不回传 RequestID，此时退化为按消息类型匹配任一等待中的请求
 func (c *WSClient) dispatchResponse(msg *ws.Message) {
 	c.responsesMu.Lock()
-	ch, ok := c.responses[msg.Type]
+	pending, ok := c.responses[msg.RequestID]
+	if msg.RequestID == "" {
+		ok = false
+		for _, p := range c.responses {
+			if p.respType == msg.Type {
+				pending, ok = p, true
+				break
+			}
+		}
+	}
 	c.responsesMu.Unlock()
 
-	if ok {
-		select {
-		case ch <- msg:
-		default:
-			slog.Warn("响应通道已满，丢弃消息", "type", msg.Type)
-		}
+	if !ok {
+		slog.Debug("未找到等待中的请求，忽略消息", "type", msg.Type, "request_id", msg.RequestID)
+		return
+	}
+
+	select {
+	case pending.ch <- msg:
+	default:
+		slog.Warn("响应通道已满，丢弃消息", "type", msg.Type, "request_id", msg.RequestID)
 	}
 }
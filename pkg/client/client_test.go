@@ -0,0 +1,510 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/Catker/acmeDeliver/pkg/cert"
+	"github.com/Catker/acmeDeliver/pkg/testutil/wstest"
+	ws "github.com/Catker/acmeDeliver/pkg/websocket"
+)
+
+// newTestWSServer 启动真实的 WebSocket 服务端，证书目录中每个域名的 cert.pem 内容为域名本身
+func newTestWSServer(t *testing.T, domains ...string) *wstest.MockServer {
+	t.Helper()
+	server := wstest.NewMockServer(t)
+	for _, domain := range domains {
+		server.WriteFile(t, domain, "cert.pem", []byte(domain))
+	}
+	return server
+}
+
+// useAcmeShFileNames 将服务端证书文件名设置为 acme.sh 的默认输出，测试结束时恢复
+func useAcmeShFileNames(t *testing.T) {
+	t.Helper()
+	cert.SetFileNames(cert.FileNames{Cert: "{domain}.cer", Key: "{domain}.key", Fullchain: "fullchain.cer"})
+	t.Cleanup(func() { cert.SetFileNames(cert.FileNames{}) })
+}
+
+func TestWSClient_DownloadCertCustomFileNames(t *testing.T) {
+	useAcmeShFileNames(t)
+	server := wstest.NewMockServer(t)
+	server.WriteFile(t, "example.com", "example.com.cer", []byte("CERT"))
+	server.WriteFile(t, "example.com", "example.com.key", []byte("KEY"))
+	server.WriteFile(t, "example.com", "fullchain.cer", []byte("FULLCHAIN"))
+	server.WriteFile(t, "example.com", "time.log", []byte("1700000000"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client := NewWSClient(server.URL, wstest.DefaultPassword, nil)
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer client.Close()
+
+	certs, err := client.DownloadCert(ctx, "example.com", true)
+	if err != nil {
+		t.Fatalf("DownloadCert() error = %v", err)
+	}
+	if string(certs.Cert) != "CERT" || string(certs.Key) != "KEY" || string(certs.Fullchain) != "FULLCHAIN" || certs.Timestamp != 1700000000 {
+		t.Errorf("DownloadCert() = %+v, want 按配置的文件名读取", certs)
+	}
+	for _, name := range []string{"cert.pem", "key.pem", "fullchain.pem"} {
+		if certs.Checksums[name] == "" {
+			t.Errorf("校验和缺少 %s: %v", name, certs.Checksums)
+		}
+	}
+}
+
+func TestWSClient_ConcurrentDownloadCert(t *testing.T) {
+	domains := []string{"a.example.com", "b.example.com", "c.example.com", "d.example.com", "e.example.com"}
+	server := newTestWSServer(t, domains...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewWSClient(server.URL, "test-password", nil)
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer client.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(domains)*4)
+	for i := 0; i < 4; i++ {
+		for _, domain := range domains {
+			wg.Add(1)
+			go func(domain string) {
+				defer wg.Done()
+				certs, err := client.DownloadCert(ctx, domain, false)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if string(certs.Cert) != domain {
+					t.Errorf("DownloadCert(%s) 返回了 %s 的证书", domain, certs.Cert)
+				}
+			}(domain)
+		}
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("DownloadCert() error = %v", err)
+	}
+
+	// 并发请求混合不同类型
+	if _, err := client.DownloadCert(ctx, "missing.example.com", false); err == nil {
+		t.Error("不存在的域名应返回错误")
+	}
+	if _, err := client.GetServerStatus(ctx); err != nil {
+		t.Errorf("GetServerStatus() error = %v", err)
+	}
+}
+
+// TestWSClient_OutOfOrderResponses 桩服务端收齐两个证书请求后按相反顺序响应，
+// 验证同类型并发请求按 RequestID 而非到达顺序取回各自的响应
+func TestWSClient_OutOfOrderResponses(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var pending []ws.Message
+		for {
+			var msg ws.Message
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			switch msg.Type {
+			case ws.MsgTypeAuth:
+				reply, _ := ws.NewMessage(ws.MsgTypeAuthResult, &ws.AuthResponse{Success: true})
+				reply.RequestID = msg.RequestID
+				conn.WriteJSON(reply)
+			case ws.MsgTypeCertRequest:
+				pending = append(pending, msg)
+				if len(pending) < 2 {
+					continue
+				}
+				for i := len(pending) - 1; i >= 0; i-- {
+					var req ws.CertRequest
+					pending[i].ParseData(&req)
+					reply, _ := ws.NewMessage(ws.MsgTypeCertResponse, &ws.CertResponse{
+						Domain: req.Domain,
+						Files:  map[string][]byte{"cert.pem": []byte(req.Domain)},
+					})
+					reply.RequestID = pending[i].RequestID
+					conn.WriteJSON(reply)
+				}
+				pending = nil
+			}
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client := NewWSClient(server.URL, "test-password", nil)
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer client.Close()
+
+	domains := []string{"a.example.com", "b.example.com"}
+	got := make([]string, len(domains))
+	var wg sync.WaitGroup
+	for i, domain := range domains {
+		wg.Add(1)
+		go func(i int, domain string) {
+			defer wg.Done()
+			certs, err := client.DownloadCert(ctx, domain, false)
+			if err != nil {
+				t.Errorf("DownloadCert(%s) error = %v", domain, err)
+				return
+			}
+			got[i] = string(certs.Cert)
+		}(i, domain)
+	}
+	wg.Wait()
+
+	for i, domain := range domains {
+		if got[i] != domain {
+			t.Errorf("DownloadCert(%s) 收到了 %q 的响应", domain, got[i])
+		}
+	}
+}
+
+func TestWSClient_DispatchResponse(t *testing.T) {
+	c := NewWSClient("", "", nil)
+	first := c.registerResponse("req-1", ws.MsgTypeCertResponse)
+	second := c.registerResponse("req-2", ws.MsgTypeCertResponse)
+
+	// 按 RequestID 精确分发
+	c.dispatchResponse(&ws.Message{Type: ws.MsgTypeCertResponse, RequestID: "req-2"})
+	select {
+	case msg := <-second:
+		if msg.RequestID != "req-2" {
+			t.Errorf("RequestID = %q, want req-2", msg.RequestID)
+		}
+	default:
+		t.Fatal("req-2 未收到响应")
+	}
+	if len(first) != 0 {
+		t.Error("req-1 不应收到 req-2 的响应")
+	}
+
+	// 未知 RequestID 被忽略
+	c.dispatchResponse(&ws.Message{Type: ws.MsgTypeCertResponse, RequestID: "req-unknown"})
+	if len(first) != 0 {
+		t.Error("未知 RequestID 不应分发")
+	}
+
+	// 旧版服务端不回传 RequestID：按类型匹配
+	c.unregisterResponse("req-2")
+	c.dispatchResponse(&ws.Message{Type: ws.MsgTypeCertResponse})
+	if len(first) != 1 {
+		t.Error("缺少 RequestID 时应按消息类型分发")
+	}
+}
+
+func TestWSClient_DownloadCertSince(t *testing.T) {
+	server := wstest.NewMockServer(t)
+	server.WriteFile(t, "example.com", "cert.pem", []byte("cert"))
+	server.WriteFile(t, "example.com", "time.log", []byte("1700000100"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client := NewWSClient(server.URL, "test-password", nil)
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer client.Close()
+
+	tests := []struct {
+		name      string
+		since     int64
+		force     bool
+		wantFiles bool
+	}{
+		{"本地未部署", 0, false, true},
+		{"本地较旧", 1700000000, false, true},
+		{"已是最新", 1700000100, false, false},
+		{"强制下载", 1700000100, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			certs, err := client.DownloadCertSince(ctx, "example.com", tt.since, tt.force)
+			if err != nil {
+				t.Fatalf("DownloadCertSince() error = %v", err)
+			}
+			if certs.Timestamp != 1700000100 {
+				t.Errorf("Timestamp = %d, want 1700000100", certs.Timestamp)
+			}
+			if got := !certs.IsEmpty(); got != tt.wantFiles {
+				t.Errorf("返回文件 = %v, want %v", got, tt.wantFiles)
+			}
+		})
+	}
+}
+
+func TestWSClient_ListDomains(t *testing.T) {
+	server := newTestWSServer(t, "b.example.com", "a.example.com")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewWSClient(server.URL, "test-password", nil)
+	if _, err := client.ListDomains(ctx); err == nil {
+		t.Error("未认证时 ListDomains() 应返回错误")
+	}
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer client.Close()
+
+	domains, err := client.ListDomains(ctx)
+	if err != nil {
+		t.Fatalf("ListDomains() error = %v", err)
+	}
+	if len(domains) != 2 || domains[0].Domain != "a.example.com" || domains[1].Domain != "b.example.com" {
+		t.Fatalf("ListDomains() = %+v", domains)
+	}
+	if len(domains[0].Files) != 1 || domains[0].Files[0] != "cert.pem" || domains[0].Timestamp != 0 {
+		t.Errorf("domains[0] = %+v, want files [cert.pem] without timestamp", domains[0])
+	}
+}
+
+// newSlowWSServer 启动桩服务端：认证立即成功，证书与状态请求延迟 delay 后才响应
+func newSlowWSServer(t *testing.T, delay time.Duration) *httptest.Server {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var mu sync.Mutex
+		reply := func(requestID, msgType string, data interface{}) {
+			msg, _ := ws.NewMessage(msgType, data)
+			msg.RequestID = requestID
+			mu.Lock()
+			defer mu.Unlock()
+			conn.WriteJSON(msg)
+		}
+		for {
+			var msg ws.Message
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			switch msg.Type {
+			case ws.MsgTypeAuth:
+				reply(msg.RequestID, ws.MsgTypeAuthResult, &ws.AuthResponse{Success: true})
+			case ws.MsgTypeCertRequest:
+				go func(requestID string) {
+					time.Sleep(delay)
+					reply(requestID, ws.MsgTypeCertResponse, &ws.CertResponse{Domain: "example.com", Files: map[string][]byte{"cert.pem": []byte("cert")}})
+				}(msg.RequestID)
+			case ws.MsgTypeStatusRequest:
+				go func(requestID string) {
+					time.Sleep(delay)
+					reply(requestID, ws.MsgTypeStatusResponse, &ws.StatusResponse{})
+				}(msg.RequestID)
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWSClient_RequestTimeout(t *testing.T) {
+	const delay = 500 * time.Millisecond
+	server := newSlowWSServer(t, delay)
+
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		wantErr bool
+	}{
+		{"超时短于服务端延迟", 100 * time.Millisecond, true},
+		{"超时长于服务端延迟", 5 * time.Second, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			client := NewWSClient(server.URL, "test-password", nil)
+			client.SetRequestTimeout(tt.timeout)
+			if err := client.Connect(ctx); err != nil {
+				t.Fatalf("Connect() error = %v", err)
+			}
+			defer client.Close()
+
+			requests := map[string]func() error{
+				"DownloadCert": func() error {
+					_, err := client.DownloadCert(ctx, "example.com", false)
+					return err
+				},
+				"GetServerStatus": func() error {
+					_, err := client.GetServerStatus(ctx)
+					return err
+				},
+			}
+			for name, do := range requests {
+				start := time.Now()
+				err := do()
+				elapsed := time.Since(start)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("%s() error = %v, wantErr %v", name, err, tt.wantErr)
+				}
+				if tt.wantErr && elapsed >= delay {
+					t.Errorf("%s() 在 %v 后才超时，配置的超时为 %v", name, elapsed, tt.timeout)
+				}
+			}
+		})
+	}
+}
+
+func TestWSClient_ConnectTimeout(t *testing.T) {
+	// 桩服务端完成握手后不响应认证
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	client := NewWSClient(server.URL, "test-password", nil)
+	client.SetConnectTimeout(200 * time.Millisecond)
+	start := time.Now()
+	err := client.Connect(context.Background())
+	if err == nil {
+		client.Close()
+		t.Fatal("认证无响应时 Connect() 应失败")
+	}
+	if elapsed := time.Since(start); elapsed >= DefaultConnectTimeout {
+		t.Errorf("Connect() 在 %v 后才超时，配置的超时为 200ms", elapsed)
+	}
+
+	if got := NewWSClient("", "", nil).handshakeTimeout(); got != DefaultConnectTimeout {
+		t.Errorf("未设置时 handshakeTimeout() = %v, want %v", got, DefaultConnectTimeout)
+	}
+}
+
+func TestWSClient_Timeout(t *testing.T) {
+	client := NewWSClient("ws://127.0.0.1", "test-password", nil)
+	if got := client.timeout(DefaultDownloadTimeout); got != DefaultDownloadTimeout {
+		t.Errorf("未设置时 timeout() = %v, want %v", got, DefaultDownloadTimeout)
+	}
+	client.SetRequestTimeout(time.Minute)
+	if got := client.timeout(DefaultQueryTimeout); got != time.Minute {
+		t.Errorf("timeout() = %v, want 1m", got)
+	}
+	client.SetRequestTimeout(0)
+	if got := client.timeout(DefaultQueryTimeout); got != DefaultQueryTimeout {
+		t.Errorf("恢复默认后 timeout() = %v, want %v", got, DefaultQueryTimeout)
+	}
+}
+
+func TestWebsocketURL(t *testing.T) {
+	tests := []struct {
+		server string
+		wsPath string
+		want   string
+	}{
+		{"http://example.com:9090", "", "ws://example.com:9090/ws"},
+		{"https://example.com", "", "wss://example.com/ws"},
+		{"https://example.com/", "", "wss://example.com/ws"},
+		{"wss://example.com/ws", "", "wss://example.com/ws"},
+		{"https://example.com", "/acme/ws", "wss://example.com/acme/ws"},
+		{"https://example.com/acme/ws", "/acme/ws", "wss://example.com/acme/ws"},
+		{"https://example.com", "acme/ws/", "wss://example.com/acme/ws"},
+	}
+	for _, tt := range tests {
+		if got := websocketURL(tt.server, tt.wsPath); got != tt.want {
+			t.Errorf("websocketURL(%q, %q) = %q, want %q", tt.server, tt.wsPath, got, tt.want)
+		}
+	}
+}
+
+func TestHTTPBaseURL(t *testing.T) {
+	tests := []struct {
+		server string
+		wsPath string
+		want   string
+	}{
+		{"http://example.com:9090", "", "http://example.com:9090"},
+		{"wss://example.com/ws", "", "https://example.com"},
+		{"wss://example.com/acme/ws/", "/acme/ws", "https://example.com"},
+	}
+	for _, tt := range tests {
+		if got := httpBaseURL(tt.server, tt.wsPath); got != tt.want {
+			t.Errorf("httpBaseURL(%q, %q) = %q, want %q", tt.server, tt.wsPath, got, tt.want)
+		}
+	}
+}
+
+func TestWSClient_MaxMessageSize(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Run("服务端推送超过客户端限制", func(t *testing.T) {
+		server := newTestWSServer(t)
+		server.WriteFile(t, "big.example.com", "cert.pem", []byte(strings.Repeat("x", 4096)))
+		server.WriteFile(t, "small.example.com", "cert.pem", []byte("small"))
+
+		client := NewWSClient(server.URL, "test-password", nil)
+		client.SetMaxMessageSize(2048)
+		client.SetRetries(3)
+		if err := client.Connect(ctx); err != nil {
+			t.Fatalf("Connect() error = %v", err)
+		}
+		defer client.Close()
+
+		if _, err := client.DownloadCert(ctx, "small.example.com", false); err != nil {
+			t.Fatalf("未超限的下载失败: %v", err)
+		}
+		attempts := server.Attempts()
+		_, err := client.DownloadCert(ctx, "big.example.com", false)
+		if !errors.Is(err, errMessageTooBig) || !strings.Contains(err.Error(), "max_message_size") {
+			t.Fatalf("DownloadCert() error = %v, want 消息超限错误", err)
+		}
+		if server.Attempts() != attempts {
+			t.Error("消息超限不应重连重试")
+		}
+	})
+
+	t.Run("请求超过服务端限制", func(t *testing.T) {
+		server := wstest.NewMockServer(t, wstest.WithServeConfig(func(cfg *ws.ServeConfig) {
+			cfg.MaxMessageSize = 64
+		}))
+		client := NewWSClient(server.URL, "test-password", nil)
+		err := client.Connect(ctx)
+		if !errors.Is(err, errMessageTooBig) || !strings.Contains(err.Error(), "服务端 max_message_size") {
+			t.Fatalf("Connect() error = %v, want 服务端拒绝超限消息", err)
+		}
+	})
+}
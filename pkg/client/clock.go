@@ -0,0 +1,46 @@
+package client
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/security"
+)
+
+// clockSkewTolerance 服务端校验签名时间戳的容差
+const clockSkewTolerance = time.Duration(security.DefaultTimestampTolerance) * time.Second
+
+// clockSkew 根据认证响应中的服务端时间估算本机时钟偏差
+// 以发出请求与收到响应的中点作为服务端生成响应时的本机时间，正数表示本机时钟快于服务端
+// serverTime 为 0（旧版本服务端未返回时间）时 ok 为 false
+func clockSkew(serverTime int64, sent, received time.Time) (skew time.Duration, ok bool) {
+	if serverTime <= 0 {
+		return 0, false
+	}
+	local := sent.Add(received.Sub(sent) / 2)
+	return local.Sub(time.Unix(serverTime, 0)).Round(time.Second), true
+}
+
+// clockSkewExceeded 判断时钟偏差是否超过签名时间戳容差
+func clockSkewExceeded(skew time.Duration) bool {
+	return skew > clockSkewTolerance || skew < -clockSkewTolerance
+}
+
+// describeClockSkew 返回面向用户的时钟偏差说明，如 "本机时钟比服务端快 45s"
+func describeClockSkew(skew time.Duration) string {
+	if skew < 0 {
+		return fmt.Sprintf("本机时钟比服务端慢 %s", -skew)
+	}
+	return fmt.Sprintf("本机时钟比服务端快 %s", skew)
+}
+
+// logClockSkew 记录时钟偏差，超过容差时以 WARN 提示校准系统时间
+func logClockSkew(logger *slog.Logger, skew time.Duration) {
+	if clockSkewExceeded(skew) {
+		logger.Warn("⏰ "+describeClockSkew(skew)+"，超过签名时间戳容差，认证将失败，请校准系统时间（如启用 NTP）",
+			"skew", skew, "tolerance", clockSkewTolerance)
+		return
+	}
+	logger.Debug("本机与服务端时钟偏差", "skew", skew)
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	ws "github.com/Catker/acmeDeliver/pkg/websocket"
+)
+
+func TestClockSkew(t *testing.T) {
+	sent := time.Unix(1700000000, 0)
+	tests := []struct {
+		name       string
+		serverTime int64
+		received   time.Time
+		want       time.Duration
+		wantOK     bool
+	}{
+		{"时钟一致", 1700000000, sent, 0, true},
+		{"本机快 45 秒", 1700000000 - 45, sent, 45 * time.Second, true},
+		{"本机慢 45 秒", 1700000000 + 45, sent, -45 * time.Second, true},
+		{"以往返中点估算", 1700000001, sent.Add(2 * time.Second), 0, true},
+		{"往返较慢时扣除一半延迟", 1700000000 - 40, sent.Add(10 * time.Second), 45 * time.Second, true},
+		{"旧版本服务端未返回时间", 0, sent, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := clockSkew(tt.serverTime, sent, tt.received)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("clockSkew() = (%v, %v), want (%v, %v)", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestClockSkewExceeded(t *testing.T) {
+	tests := []struct {
+		skew time.Duration
+		want bool
+	}{
+		{0, false},
+		{clockSkewTolerance, false},
+		{-clockSkewTolerance, false},
+		{clockSkewTolerance + time.Second, true},
+		{-clockSkewTolerance - time.Second, true},
+	}
+	for _, tt := range tests {
+		if got := clockSkewExceeded(tt.skew); got != tt.want {
+			t.Errorf("clockSkewExceeded(%v) = %v, want %v", tt.skew, got, tt.want)
+		}
+	}
+
+	if got := describeClockSkew(45 * time.Second); got != "本机时钟比服务端快 45s" {
+		t.Errorf("describeClockSkew(45s) = %q", got)
+	}
+	if got := describeClockSkew(-90 * time.Second); got != "本机时钟比服务端慢 1m30s" {
+		t.Errorf("describeClockSkew(-90s) = %q", got)
+	}
+}
+
+func TestWSClient_AuthReportsClockSkew(t *testing.T) {
+	// 桩服务端以比本机慢 45 秒的时间拒绝认证
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var msg ws.Message
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			if msg.Type == ws.MsgTypeAuth {
+				reply, _ := ws.NewMessage(ws.MsgTypeAuthResult, &ws.AuthResponse{
+					Message:    "时间戳已过期",
+					ServerTime: time.Now().Add(-45 * time.Second).Unix(),
+				})
+				reply.RequestID = msg.RequestID
+				conn.WriteJSON(reply)
+			}
+		}
+	}))
+	defer server.Close()
+
+	client := NewWSClient(server.URL, "test-password", nil)
+	err := client.Connect(context.Background())
+	if err == nil {
+		client.Close()
+		t.Fatal("认证被拒绝时 Connect() 应失败")
+	}
+	if !strings.Contains(err.Error(), "时间戳已过期") || !strings.Contains(err.Error(), "本机时钟比服务端快 4") {
+		t.Errorf("Connect() error = %v, want 包含时钟偏差说明", err)
+	}
+}
@@ -3,38 +3,61 @@ package client
 
 import (
 	"context"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"math/big"
 	"os"
 	"os/signal"
 	"path/filepath"
-	"strconv"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/websocket"
 
+	"github.com/Catker/acmeDeliver/pkg/cert"
 	"github.com/Catker/acmeDeliver/pkg/config"
 	"github.com/Catker/acmeDeliver/pkg/security"
 	ws "github.com/Catker/acmeDeliver/pkg/websocket"
+	"github.com/Catker/acmeDeliver/pkg/workspace"
 )
 
 // DaemonConfig Daemon 模式配置
 type DaemonConfig struct {
-	ServerURL         string                    // WebSocket 服务器地址
-	Password          string                    // 认证密码
-	ClientID          string                    // 客户端标识
-	WorkDir           string                    // 工作目录
-	Subscribe         []string                  // 订阅的域名列表
-	Sites             []config.SiteDeployConfig // 站点部署配置
-	ReconnectInterval time.Duration             // 重连间隔
-	HeartbeatInterval time.Duration             // 心跳间隔
-	ReloadDebounce    time.Duration             // Reload 防抖延迟（默认 5 秒）
-	SyncInterval      time.Duration             // 定时同步间隔（0/未设置=默认1小时，负数=禁用）
-	TLSConfig         *TLSConfig                // TLS 配置（可选）
+	ServerURL          string                    // WebSocket 服务器地址
+	WSPath             string                    // WebSocket 端点路径，空表示默认 /ws
+	Password           string                    // 认证密码
+	ClientID           string                    // 客户端标识
+	WorkDir            string                    // 工作目录
+	Subscribe          []string                  // 订阅的域名列表
+	Sites              []config.SiteDeployConfig // 站点部署配置
+	ReconnectInterval  time.Duration             // 重连间隔
+	ReconnectJitterMax time.Duration             // 首次重连附加的随机延迟上限，0 表示不附加
+	HeartbeatInterval  time.Duration             // 心跳间隔
+	PongTimeout        time.Duration             // 最长可接受的服务端静默时间（默认 90 秒），超时后断开重连
+	MaxMessageSize     int64                     // 单条消息大小上限（默认 10 MB）
+	ReloadDebounce     time.Duration             // Reload 防抖延迟（默认 5 秒）
+	ReloadTimeout      time.Duration             // 单个 reload 命令的执行超时（默认 15 秒）
+	ShutdownTimeout    time.Duration             // 退出时等待执行中的 reload 命令结束的时间（默认 30 秒）
+	SyncInterval       time.Duration             // 定时同步间隔，与心跳相互独立，0 表示禁用
+	CleanupWorkdir     bool                      // 订阅列表缩减后删除工作目录中不再订阅的域名目录
+	DeployOnStart      bool                      // 启动时（连接前）重新部署工作目录中部署文件缺失或过期的证书
+	HistoryRetention   time.Duration             // 部署历史保留时长，0 表示全部保留
+	BackupRetention    int                       // 覆盖部署文件前每个文件保留的备份数，0 表示不备份
+	VerifyPush         string                    // 部署推送证书前的校验模式：strict / warn，空或 off 表示不校验
+	SyncOnWrite        bool                      // 写入工作目录与部署文件后 fsync 文件及所在目录
+	StatusListen       string                    // 本地状态接口监听地址（如 127.0.0.1:9091），空表示不启用
+	DisableLegacyAuth  bool                      // 旧版本服务端拒绝 HMAC 签名时不回退到旧版签名
+	TLSConfig          *TLSConfig                // TLS 配置（可选）
+
+	ConfigFile string // 配置文件路径，密钥轮换后将新密码写回该文件，空表示仅在内存中生效
 }
 
 // Daemon 客户端守护进程
@@ -50,30 +73,110 @@ type Daemon struct {
 	// Reload 防抖器
 	reloadDebouncer *ReloadDebouncer
 
+	// reload 命令 -> 等待该命令结果的部署（执行 postcmd 或回滚）
+	pendingDeploys map[string][]pendingDeploy
+	hookMu         sync.Mutex
+
 	// Pong 超时检测
 	lastPong time.Time
 	pongMu   sync.RWMutex
+
+	// 密钥轮换后正在使用新密钥重新认证（受 mu 保护）
+	rotating bool
+
+	// 最近一次发送认证请求的时间，用于估算时钟偏差（受 mu 保护）
+	authSentAt time.Time
+
+	// 服务端地址已变更，当前连接断开后立即重连新地址（受 mu 保护）
+	redial bool
+
+	// 认证使用的签名版本，每次连接从 HMAC 签名开始，旧版本服务端拒绝后回退到 V1（受 mu 保护）
+	authVersion int
+
+	// Resync 期间为 true，推送的证书即使部署文件内容未变化也执行 reload（受 mu 保护）
+	forcePush bool
+
+	// 重连抖动的随机源，默认 crypto/rand.Reader，测试中可替换
+	jitterRand io.Reader
+
+	// systemd 服务通知（sd_notify），不在 systemd 下运行时为空操作，测试中可替换
+	notifier         notifier
+	watchdogInterval time.Duration // systemd watchdog 超时，0 表示未启用
+
+	// 携带 server_url 与 client_id 的日志记录器，服务端地址热重载后替换
+	logger atomic.Pointer[slog.Logger]
+
+	// 运行状态，供本地状态接口与状态文件使用
+	state *DaemonState
 }
 
 // ConfigUpdate 配置更新通知
 type ConfigUpdate struct {
+	NewServerURL string // 空表示不变
 	NewSubscribe []string
 	NewSites     []config.SiteDeployConfig
 }
 
+// errServerURLChanged 服务端地址已变更，主动断开当前连接
+var errServerURLChanged = errors.New("服务端地址已变更")
+
+// DefaultShutdownTimeout 退出时等待执行中的 reload 命令结束的默认时间
+const DefaultShutdownTimeout = 30 * time.Second
+
+// newDaemonLogger 创建携带 server_url 与 client_id 的日志记录器
+func newDaemonLogger(serverURL, clientID string) *slog.Logger {
+	return slog.Default().With("server_url", serverURL, "client_id", clientID)
+}
+
+// log 返回当前的日志记录器
+func (d *Daemon) log() *slog.Logger {
+	return d.logger.Load()
+}
+
 // NewDaemon 创建新的 Daemon
 func NewDaemon(cfg *DaemonConfig) *Daemon {
+	logger := newDaemonLogger(cfg.ServerURL, cfg.ClientID)
+
 	// 设置默认防抖延迟
 	if cfg.ReloadDebounce <= 0 {
 		cfg.ReloadDebounce = 5 * time.Second
 	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = DefaultShutdownTimeout
+	}
 
-	return &Daemon{
+	// pong 超时必须大于心跳间隔，否则每次心跳前都会被判定为超时
+	if cfg.PongTimeout <= 0 {
+		cfg.PongTimeout = ws.DefaultPongTimeout
+	}
+	if cfg.HeartbeatInterval > 0 && cfg.PongTimeout <= cfg.HeartbeatInterval {
+		adjusted := cfg.HeartbeatInterval * 3
+		logger.Warn("pong_timeout 不大于心跳间隔，已自动调整",
+			"pong_timeout", cfg.PongTimeout,
+			"heartbeat_interval", cfg.HeartbeatInterval,
+			"adjusted", adjusted)
+		cfg.PongTimeout = adjusted
+	}
+
+	d := &Daemon{
 		config:          cfg,
 		configUpdates:   make(chan *ConfigUpdate, 16),
-		reloadDebouncer: NewReloadDebouncer(cfg.ReloadDebounce),
+		reloadDebouncer: NewReloadDebouncer(cfg.ReloadDebounce, cfg.ReloadTimeout),
+		pendingDeploys:  make(map[string][]pendingDeploy),
 		lastPong:        time.Now(),
+		jitterRand:      rand.Reader,
+		notifier:        newSystemdNotifier(os.Getenv),
+		state:           newDaemonState(cfg.ServerURL, cfg.ClientID, logger),
 	}
+	d.logger.Store(logger)
+	d.reloadDebouncer.onResult = d.onReloadResult
+	d.watchdogInterval = systemdWatchdogInterval(os.Getenv, os.Getpid())
+	return d
+}
+
+// Status 返回 daemon 当前运行状态的快照
+func (d *Daemon) Status() DaemonStatus {
+	return d.state.Snapshot()
 }
 
 // backoff 计算指数退避间隔
@@ -87,10 +190,26 @@ func backoff(attempt int, base time.Duration) time.Duration {
 	return delay
 }
 
+// reconnectJitter 从 r 中生成 [0, limit) 范围内的随机延迟
+// 服务端重启时所有客户端同时断线，退避基数相同会导致集中重连，附加随机延迟将其打散
+func reconnectJitter(r io.Reader, limit time.Duration) (time.Duration, error) {
+	if limit <= 0 {
+		return 0, nil
+	}
+	n, err := rand.Int(r, big.NewInt(int64(limit)))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(n.Int64()), nil
+}
+
 // writeMessage 线程安全的 WebSocket 写入
 func (d *Daemon) writeMessage(data []byte) error {
 	d.connMu.Lock()
 	defer d.connMu.Unlock()
+	if d.conn == nil {
+		return errConnectionClosed
+	}
 	return d.conn.WriteMessage(websocket.TextMessage, data)
 }
 
@@ -110,34 +229,59 @@ func (d *Daemon) getLastPong() time.Time {
 
 // Run 运行 Daemon
 func (d *Daemon) Run(ctx context.Context) error {
-	slog.Info("Daemon 模式启动",
-		"server", d.config.ServerURL,
-		"subscribe", d.config.Subscribe)
+	d.log().Info("Daemon 模式启动", "subscribe", d.config.Subscribe)
 
 	// 确保工作目录存在
 	if err := os.MkdirAll(d.config.WorkDir, 0755); err != nil {
 		return err
 	}
+	defer d.shutdownReloads()
+	defer d.sdNotify("STOPPING=1")
+
+	if err := d.state.enableFile(filepath.Join(d.config.WorkDir, StatusFile)); err != nil {
+		d.log().Warn("写入 daemon 状态文件失败", "error", err)
+	}
+	if d.config.StatusListen != "" {
+		shutdown, err := d.serveStatus()
+		if err != nil {
+			return err
+		}
+		defer shutdown()
+	}
+
+	if d.config.DeployOnStart {
+		d.deployFromWorkdir()
+	}
 
 	// 使用 signal.NotifyContext 让信号通过 context 传播
 	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	if d.watchdogInterval > 0 {
+		d.log().Info("已启用 systemd watchdog", "timeout", d.watchdogInterval)
+		go d.watchdog(ctx)
+	}
+
 	attempt := 0 // 重连尝试次数，用于指数退避
 	for {
 		select {
 		case <-ctx.Done():
-			slog.Info("收到退出信号，正在退出")
+			d.log().Info("收到退出信号，正在退出")
 			return nil
 		default:
 			// 连接并处理
 			if err := d.connectAndServe(ctx); err != nil {
 				// 如果是 context 取消导致的错误，直接返回
 				if ctx.Err() != nil {
-					slog.Info("收到退出信号，正在退出")
+					d.log().Info("收到退出信号，正在退出")
 					return nil
 				}
-				slog.Error("连接断开", "error", err)
+				// 服务端地址变更，无需退避，立即连接新地址
+				if errors.Is(err, errServerURLChanged) {
+					attempt = 0
+					continue
+				}
+				d.log().Error("连接断开", "error", err)
 			} else {
 				// 连接成功后重置退避计数
 				attempt = 0
@@ -145,17 +289,27 @@ func (d *Daemon) Run(ctx context.Context) error {
 
 			// 检查是否需要退出
 			if ctx.Err() != nil {
-				slog.Info("收到退出信号，正在退出")
+				d.log().Info("收到退出信号，正在退出")
 				return nil
 			}
 
 			// 计算退避间隔并等待重连
 			waitDuration := backoff(attempt, d.config.ReconnectInterval)
-			slog.Info("准备重新连接...", "wait", waitDuration, "attempt", attempt+1)
+			if attempt == 0 {
+				// 仅首次重连附加抖动，后续重连已由指数退避错开
+				jitter, err := reconnectJitter(d.jitterRand, d.config.ReconnectJitterMax)
+				if err != nil {
+					d.log().Warn("生成重连抖动失败，跳过", "error", err)
+				} else {
+					d.log().Debug("首次重连附加随机延迟", "jitter", jitter, "max", d.config.ReconnectJitterMax)
+				}
+				waitDuration += jitter
+			}
+			d.log().Info("准备重新连接...", "wait", waitDuration, "attempt", attempt+1)
 
 			select {
 			case <-ctx.Done():
-				slog.Info("收到退出信号，正在退出")
+				d.log().Info("收到退出信号，正在退出")
 				return nil
 			case <-time.After(waitDuration):
 				attempt++
@@ -164,58 +318,107 @@ func (d *Daemon) Run(ctx context.Context) error {
 	}
 }
 
-// connectAndServe 连接服务器并处理消息
-func (d *Daemon) connectAndServe(ctx context.Context) error {
-	// 解析服务器地址
-	serverURL := d.config.ServerURL
-	if !strings.HasPrefix(serverURL, "ws://") && !strings.HasPrefix(serverURL, "wss://") {
-		// 将 http:// 转换为 ws://
-		serverURL = strings.Replace(serverURL, "http://", "ws://", 1)
-		serverURL = strings.Replace(serverURL, "https://", "wss://", 1)
-	}
-	// 确保所有 URL 都追加 /ws 后缀
-	if !strings.HasSuffix(serverURL, "/ws") {
-		serverURL = serverURL + "/ws"
+// shutdownReloads 退出前立即执行防抖中的 reload 命令并等待结束，最长等待 ShutdownTimeout
+// 避免刚部署的证书因防抖尚未到期而未生效，或服务在重启过程中被中断
+func (d *Daemon) shutdownReloads() {
+	done := make(chan struct{})
+	go func() {
+		d.reloadDebouncer.Flush()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(d.config.ShutdownTimeout):
+		d.log().Warn("等待重载命令结束超时，直接退出", "timeout", d.config.ShutdownTimeout)
 	}
+}
 
-	slog.Info("正在连接服务器", "url", serverURL)
+// dial 建立到服务端的 WebSocket 连接
+func (d *Daemon) dial(ctx context.Context) (*websocket.Conn, error) {
+	d.mu.RLock()
+	serverURL := websocketURL(d.config.ServerURL, d.config.WSPath)
+	d.mu.RUnlock()
+	d.log().Info("正在连接服务器", "url", serverURL)
 
 	// 构建 TLS 配置
 	tlsConfig, err := BuildTLSConfig(d.config.TLSConfig)
 	if err != nil {
-		return fmt.Errorf("TLS 配置错误: %w", err)
+		return nil, fmt.Errorf("TLS 配置错误: %w", err)
 	}
 
 	// 建立连接（带连接超时）
 	dialer := websocket.Dialer{
-		HandshakeTimeout: 10 * time.Second,
-		TLSClientConfig:  tlsConfig,
+		HandshakeTimeout:  10 * time.Second,
+		TLSClientConfig:   tlsConfig,
+		EnableCompression: true, // 服务端启用 ws_compression 时协商压缩
 	}
 	conn, _, err := dialer.DialContext(ctx, serverURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetReadLimit(readLimit(d.config.MaxMessageSize))
+	return conn, nil
+}
+
+// connectAndServe 连接服务器并处理消息
+func (d *Daemon) connectAndServe(ctx context.Context) error {
+	// 断线期间积压的配置更新先行应用：连接使用最新的服务端地址，认证时直接携带最新的订阅列表
+	d.drainConfigUpdates()
+	d.mu.Lock()
+	d.redial = false
+	d.authVersion = security.SignatureV2
+	d.mu.Unlock()
+
+	conn, err := d.dial(ctx)
 	if err != nil {
 		return err
 	}
 	d.conn = conn
 	defer conn.Close()
 
-	slog.Info("已连接到服务器")
+	d.log().Info("已连接到服务器")
+	d.updateLastPong() // 避免 watchdog 把上一次连接的静默时间计入本次连接
+	d.state.setConnected(true)
+	defer d.state.setConnected(false)
 
 	// 发送认证请求
 	if err := d.authenticate(); err != nil {
 		return err
 	}
 
+	// 后台任务随连接断开退出；等待配置更新处理退出后再返回，
+	// 保证断线期间的配置更新只由下一次连接的 drainConfigUpdates 按顺序应用
+	connCtx, cancel := context.WithCancel(ctx)
+	var updates sync.WaitGroup
+	defer func() {
+		cancel()
+		updates.Wait()
+	}()
+
 	// 启动心跳
-	go d.heartbeat(ctx)
+	go d.heartbeat(connCtx)
 
 	// 启动配置更新处理
-	go d.handleConfigUpdates(ctx)
+	updates.Add(1)
+	go func() {
+		defer updates.Done()
+		d.handleConfigUpdates(connCtx)
+	}()
 
 	// 启动定时同步（如果配置了 sync_interval）
-	go d.syncLoop(ctx)
+	go d.syncLoop(connCtx)
 
 	// 读取消息循环
-	return d.readLoop(ctx)
+	err = d.readLoop(ctx)
+
+	d.mu.Lock()
+	redial := d.redial
+	d.redial = false
+	d.mu.Unlock()
+	if redial {
+		return errServerURLChanged
+	}
+	return err
 }
 
 // authenticate 发送认证请求
@@ -223,13 +426,20 @@ func (d *Daemon) authenticate() error {
 	timestamp := time.Now().Unix()
 
 	// 使用统一的签名验证器生成签名
+	// 订阅列表可能被热重载修改，认证时总是使用当前的列表
+	d.mu.RLock()
 	verifier := security.NewSignatureVerifier(d.config.Password)
-	signature := verifier.GenerateSignature(timestamp)
+	clientID := d.config.ClientID
+	subscribe := slices.Clone(d.config.Subscribe)
+	version := d.authVersion
+	d.mu.RUnlock()
+	signature := verifier.GenerateSignatureVersion(timestamp, version)
 
 	authReq := &ws.AuthRequest{
-		ClientID:  d.config.ClientID,
-		Signature: signature,
-		Domains:   d.config.Subscribe,
+		ClientID:         clientID,
+		Signature:        signature,
+		Domains:          subscribe,
+		SignatureVersion: version,
 	}
 
 	msg, err := ws.NewMessage(ws.MsgTypeAuth, authReq)
@@ -237,17 +447,21 @@ func (d *Daemon) authenticate() error {
 		return err
 	}
 	msg.Timestamp = timestamp
+	msg.Version = ws.CurrentProtocolVersion
 
 	data, err := json.Marshal(msg)
 	if err != nil {
 		return err
 	}
 
+	d.mu.Lock()
+	d.authSentAt = time.Now()
+	d.mu.Unlock()
 	if err := d.writeMessage(data); err != nil {
 		return err
 	}
 
-	slog.Debug("已发送认证请求", "client_id", d.config.ClientID, "domains", d.config.Subscribe)
+	d.log().Debug("已发送认证请求", "domains", subscribe)
 	return nil
 }
 
@@ -283,12 +497,15 @@ func (d *Daemon) readLoop(ctx context.Context) error {
 			return ctx.Err()
 		case result := <-resultCh:
 			if result.err != nil {
+				if sizeErr := messageSizeError(result.err, d.config.MaxMessageSize); sizeErr != nil {
+					return sizeErr
+				}
 				return result.err
 			}
 
 			var msg ws.Message
 			if err := json.Unmarshal(result.data, &msg); err != nil {
-				slog.Warn("无效的消息格式", "error", err)
+				d.log().Warn("无效的消息格式", "error", err)
 				continue
 			}
 
@@ -303,176 +520,442 @@ func (d *Daemon) handleMessage(msg *ws.Message) {
 	case ws.MsgTypeAuthResult:
 		var resp ws.AuthResponse
 		if err := msg.ParseData(&resp); err == nil {
-			if resp.Success {
-				slog.Info("认证成功", "message", resp.Message)
+			d.mu.Lock()
+			rotating := d.rotating
+			d.rotating = false
+			sent := d.authSentAt
+			d.mu.Unlock()
+
+			skew, hasSkew := clockSkew(resp.ServerTime, sent, time.Now())
+			if hasSkew {
+				logClockSkew(d.log(), skew)
+			}
+
+			switch {
+			case resp.Success && rotating:
+				d.state.recordAuth()
+				// 连接与订阅保持不变，无需重新同步
+				d.log().Info("🔑 已使用新密钥重新认证", "message", resp.Message)
+			case resp.Success:
+				d.state.recordAuth()
+				d.log().Info("认证成功", "message", resp.Message)
+				// 已连接并认证，通知 systemd 启动完成（重复发送 READY=1 无副作用）
+				d.sdNotify("READY=1\nSTATUS=已连接到服务器")
 				// 认证成功后立即请求同步证书
 				if err := d.requestSync(); err != nil {
-					slog.Warn("发送证书同步请求失败", "error", err)
+					d.log().Warn("发送证书同步请求失败", "error", err)
 				}
-			} else {
-				slog.Error("认证失败", "message", resp.Message)
+			case d.retryLegacyAuth(resp.SignatureVersion):
+				// 旧版本服务端拒绝 HMAC 签名，已以旧版签名重新认证
+			case hasSkew && clockSkewExceeded(skew):
+				d.log().Error("认证失败："+describeClockSkew(skew)+"，请校准系统时间", "message", resp.Message, "skew", skew)
+			default:
+				d.log().Error("认证失败", "message", resp.Message)
 			}
 		}
 
-	case ws.MsgTypeCertPush:
+	case ws.MsgTypeKeyRotation:
+		var data ws.KeyRotationData
+		if err := msg.ParseData(&data); err != nil {
+			d.log().Error("解析密钥轮换数据失败", "error", err)
+			return
+		}
+		d.handleKeyRotation(&data, msg.Timestamp)
+
+	case ws.MsgTypeCertPush, ws.MsgTypeAdminPush:
 		var certData ws.CertPushData
 		if err := msg.ParseData(&certData); err != nil {
-			slog.Error("解析证书数据失败", "error", err)
+			d.log().Error("解析证书数据失败", "error", err)
+			return
+		}
+		// admin_push 由 --force-domain 请求，部署文件内容未变化时同样执行 reload
+		d.handleCertPush(&certData, msg.Type == ws.MsgTypeAdminPush)
+
+	case ws.MsgTypeCertRevoke:
+		var data ws.CertRevokeData
+		if err := msg.ParseData(&data); err != nil {
+			d.log().Error("解析下线数据失败", "error", err)
 			return
 		}
-		d.handleCertPush(&certData)
+		d.handleCertRevoke(&data)
+
+	case ws.MsgTypeSubscribeResult:
+		var result ws.SubscribeResult
+		if err := msg.ParseData(&result); err != nil {
+			d.log().Error("解析订阅结果失败", "error", err)
+			return
+		}
+		d.handleSubscribeResult(&result)
+
+	case ws.MsgTypeSyncResult:
+		var result ws.SyncResult
+		if err := msg.ParseData(&result); err == nil {
+			d.log().Debug("证书同步完成", "pushed", result.Pushed)
+		}
 
 	case ws.MsgTypePong:
 		d.updateLastPong()
-		slog.Debug("收到心跳响应")
+		d.log().Debug("收到心跳响应")
 
 	case ws.MsgTypeError:
 		var errData ws.ErrorData
 		if err := msg.ParseData(&errData); err == nil {
-			slog.Error("收到错误", "code", errData.Code, "message", errData.Message)
+			if errData.Code == ws.ErrCodeVersionNotSupported {
+				d.log().Error("服务端不支持当前协议版本，请升级服务端", "version", ws.CurrentProtocolVersion, "supported", errData.SupportedVersions)
+				return
+			}
+			d.log().Error("收到错误", "code", errData.Code, "message", errData.Message)
 		}
 	}
 }
 
+// handleSubscribeResult 记录服务端对订阅更新的处理结果
+// 被拒绝的域名不会收到推送，以 WARN 逐个记录原因，便于排查收不到证书的问题
+func (d *Daemon) handleSubscribeResult(result *ws.SubscribeResult) {
+	for _, r := range result.Rejected {
+		d.log().Warn("服务端拒绝订阅域名", "domain", r.Domain, "reason", r.Reason)
+	}
+	switch {
+	case len(result.Accepted) == 0 && len(result.Rejected) > 0:
+		d.log().Error("订阅更新被全部拒绝，服务端保留原有订阅", "rejected", len(result.Rejected))
+	case len(result.Rejected) > 0:
+		d.log().Info("订阅已部分生效", "accepted", result.Accepted, "rejected", len(result.Rejected))
+	default:
+		d.log().Info("订阅已生效", "accepted", result.Accepted)
+	}
+}
+
+// handleKeyRotation 处理服务端下发的密钥轮换
+// 新密钥以当前密钥加密，能解开即说明消息来自持有当前密钥的服务端；随后使用新密钥重新认证
+// 服务端提交新密钥后旧密钥失效，因此配置了 ConfigFile 时将新密码原子写回配置文件，保证重启后仍能认证
+func (d *Daemon) handleKeyRotation(data *ws.KeyRotationData, timestamp int64) {
+	d.mu.Lock()
+	oldKey := d.config.Password
+	newKey, err := security.OpenRotationKey(oldKey, data.SealedKey, timestamp)
+	if err != nil {
+		d.mu.Unlock()
+		d.log().Error("密钥轮换消息校验失败，忽略", "error", err)
+		return
+	}
+	d.config.Password = newKey
+	d.rotating = true
+	configFile := d.config.ConfigFile
+	d.mu.Unlock()
+
+	if configFile == "" {
+		d.log().Warn("未使用配置文件，新密码仅在内存中生效，重启前请更新启动参数或环境变量")
+	} else if _, err := config.UpdateClientPassword(configFile, oldKey, newKey); err != nil {
+		d.log().Error("新密码写入配置文件失败，重启后将无法认证，请手动更新 password", "config", configFile, "error", err)
+	}
+
+	d.log().Info("🔑 收到密钥轮换，使用新密钥重新认证")
+	if err := d.authenticate(); err != nil {
+		d.log().Error("使用新密钥重新认证失败", "error", err)
+	}
+}
+
 // handleCertPush 处理证书推送
-func (d *Daemon) handleCertPush(data *ws.CertPushData) {
-	slog.Info("收到证书推送", "domain", data.Domain, "files", len(data.Files))
+// 部署文件内容均未变化时跳过 reload 并以 ws.CertAckUnchanged 确认，force 为 true 或处于 Resync 期间时仍执行 reload
+func (d *Daemon) handleCertPush(data *ws.CertPushData, force bool) {
+	d.mu.RLock()
+	force = force || d.forcePush
+	d.mu.RUnlock()
+	d.log().Info("收到证书推送", "domain", data.Domain, "files", len(data.Files))
+	d.state.recordPush(data.Domain)
+
+	// 服务端使用非标准文件名时映射为标准文件名，工作目录与部署始终使用 cert.pem / key.pem / fullchain.pem / time.log
+	files := cert.ToStandard(data.FileNames, data.Files)
 
 	// 1. 保存到工作目录
 	domainDir, err := safeDomainDir(d.config.WorkDir, data.Domain)
 	if err != nil {
-		slog.Error("非法域名路径", "domain", data.Domain, "error", err)
+		d.log().Error("非法域名路径", "domain", data.Domain, "error", err)
 		d.sendCertAck(data.Domain, false, "非法域名路径")
 		return
 	}
 	if err := os.MkdirAll(domainDir, 0755); err != nil {
-		slog.Error("创建域名目录失败", "error", err)
+		d.log().Error("创建域名目录失败", "error", err)
 		d.sendCertAck(data.Domain, false, err.Error())
 		return
 	}
+	if err := workspace.ClearChecksum(d.config.WorkDir, data.Domain); err != nil {
+		d.log().Warn("删除旧校验和失败", "domain", data.Domain, "error", err)
+	}
 
-	for filename, content := range data.Files {
+	for filename, content := range files {
 		filePath, err := safeDomainFilePath(d.config.WorkDir, data.Domain, filename)
 		if err != nil {
-			slog.Error("非法证书文件路径", "domain", data.Domain, "file", filename, "error", err)
+			d.log().Error("非法证书文件路径", "domain", data.Domain, "file", filename, "error", err)
 			d.sendCertAck(data.Domain, false, "非法证书文件路径")
 			return
 		}
-		if err := os.WriteFile(filePath, content, 0644); err != nil {
-			slog.Error("保存证书文件失败", "file", filePath, "error", err)
+		if err := workspace.WriteFileAtomic(filePath, content, 0644, d.config.SyncOnWrite); err != nil {
+			d.log().Error("保存证书文件失败", "file", filePath, "error", err)
 			d.sendCertAck(data.Domain, false, err.Error())
 			return
 		}
-		slog.Debug("保存证书文件", "file", filePath)
+		d.log().Debug("保存证书文件", "file", filePath)
+	}
+
+	d.log().Info("证书已保存到工作目录", "dir", domainDir)
+	if err := workspace.Verify(d.config.WorkDir, data.Domain); err != nil {
+		d.log().Warn("工作目录证书校验失败", "domain", data.Domain, "error", err)
 	}
 
-	slog.Info("证书已保存到工作目录", "dir", domainDir)
+	// 2. 校验推送的证书，strict 模式下校验失败时保留工作目录中的文件供排查，不部署
+	site := config.FindSite(d.config.Sites, data.Domain)
+	if err := d.verifyPush(data.Domain, files, site); err != nil {
+		d.state.recordDeploy(data.Domain, err)
+		d.sendCertAck(data.Domain, false, err.Error())
+		return
+	}
+	if err := VerifySiteCT(site, files[cert.CertFileName], files[cert.FullchainFileName]); err != nil {
+		d.log().Error("推送的证书未通过 CT 日志校验，跳过部署，文件保留在工作目录中", "domain", data.Domain, "error", err)
+		d.state.recordDeploy(data.Domain, err)
+		d.sendCertAck(data.Domain, false, err.Error())
+		return
+	}
 
-	// 2. 查找匹配的站点配置并部署（只复制文件，不执行 reload）
-	site := d.findSiteConfig(data.Domain)
+	// 3. 查找匹配的站点配置并部署（只复制文件，不执行 reload）
 	if site != nil {
-		if err := d.deployCertFilesWithRetry(data.Domain, domainDir, site, 3); err != nil {
-			slog.Error("部署证书失败", "domain", data.Domain, "error", err)
+		var backup *DeployBackup
+		var unchanged bool
+		err := d.runPreCmd(data.Domain, site)
+		if err == nil {
+			backup, unchanged, err = d.deployCertFilesWithRetry(data.Domain, domainDir, site, 3)
+		}
+		d.state.recordDeploy(data.Domain, err)
+		if err != nil {
+			d.log().Error("部署证书失败", "domain", data.Domain, "error", err)
 			d.sendCertAck(data.Domain, false, err.Error())
 			return
 		}
-		slog.Info("证书文件部署完成", "domain", data.Domain)
-
-		// 3. 使用 debouncer 触发 reload（防抖）
-		if site.ReloadCmd != "" {
-			d.reloadDebouncer.Trigger(site.ReloadCmd)
+		if unchanged && !force {
+			d.log().Info("部署文件内容均未变化，跳过 reload", "domain", data.Domain)
+			d.sendCertAck(data.Domain, true, ws.CertAckUnchanged)
+			return
 		}
+		d.log().Info("证书文件部署完成", "domain", data.Domain)
+
+		// 4. 使用 debouncer 触发 reload（防抖），reload 成功后执行 postcmd，失败时回滚并再次发送 cert_ack
+		d.scheduleReload(data.Domain, site, backup, true)
 	} else {
-		slog.Info("未找到站点配置，跳过自动部署", "domain", data.Domain)
+		d.log().Info("未找到站点配置，跳过自动部署", "domain", data.Domain)
 	}
 
+	d.recordDeployHistory(data.Domain, files[cert.CertFileName])
 	d.sendCertAck(data.Domain, true, "")
 }
 
-// findSiteConfig 查找域名对应的站点配置
-func (d *Daemon) findSiteConfig(domain string) *config.SiteDeployConfig {
-	for _, site := range d.config.Sites {
-		// 精确匹配
-		if site.Domain == domain {
-			return &site
-		}
-		// 通配符匹配
-		if strings.HasPrefix(site.Domain, "*.") {
-			suffix := site.Domain[1:] // .example.com
-			if strings.HasSuffix(domain, suffix) {
-				return &site
-			}
-		}
+// handleCertRevoke 处理域名下线：删除工作目录中的域名目录，按消息要求删除已部署文件，随后触发站点的 reload
+// 没有匹配的站点配置且消息未指定 force 时忽略
+func (d *Daemon) handleCertRevoke(data *ws.CertRevokeData) {
+	d.mu.RLock()
+	workDir := d.config.WorkDir
+	site := config.FindSite(d.config.Sites, data.Domain)
+	d.mu.RUnlock()
+
+	result, err := RemoveDomain(RemoveOptions{
+		WorkDir:       workDir,
+		Domain:        data.Domain,
+		Site:          site,
+		PurgeDeployed: data.PurgeDeployed,
+		Force:         data.Force,
+	})
+	if err != nil {
+		d.log().Error("域名下线失败", "domain", data.Domain, "error", err)
+		return
+	}
+	d.log().Info("🗑️ 域名已下线", "domain", data.Domain, "workspace", result.Workspace, "deployed", result.Deployed, "skipped", result.Skipped)
+
+	if site != nil && site.ReloadCmd != "" {
+		d.reloadDebouncer.Trigger(site.ReloadCmd)
+	}
+}
+
+// recordDeployHistory 记录一次成功部署，reload 经防抖异步执行，结果未知，不记录重载结果
+// 写入失败只记录警告，不影响推送确认
+func (d *Daemon) recordDeployHistory(domain string, certPEM []byte) {
+	d.mu.RLock()
+	workDir, retention := d.config.WorkDir, d.config.HistoryRetention
+	d.mu.RUnlock()
+
+	rec, err := workspace.NewDeployRecord(certPEM, workspace.DeployerLocal, nil, time.Now())
+	if err == nil {
+		err = workspace.AppendDeployHistory(workDir, domain, rec, retention)
+	}
+	if err != nil {
+		d.log().Warn("记录部署历史失败", "domain", domain, "error", err)
 	}
-	return nil
 }
 
 // deployCertFiles 部署证书文件（只复制文件，不执行 reload）
-// reload 命令由调用方通过 debouncer 统一触发
-func (d *Daemon) deployCertFiles(domain, srcDir string, site *config.SiteDeployConfig) error {
+// reload 命令由调用方通过 debouncer 统一触发；开启 verify_after_deploy 时校验失败返回 ErrDeployVerificationFailed
+// 写入前与已有部署文件比对，有变化时先按 retention 备份已有文件；unchanged 为 true 表示全部部署文件内容均未变化（未备份），调用方据此跳过 reload
+func (d *Daemon) deployCertFiles(domain, srcDir string, site *config.SiteDeployConfig, retention int) (backup *DeployBackup, unchanged bool, err error) {
 	// 替换路径中的 {domain} 占位符
 	replaceDomain := func(path string) string {
 		return strings.ReplaceAll(path, "{domain}", domain)
 	}
 
-	// 复制证书文件
-	copyFile := func(src, dst string) error {
-		if dst == "" {
-			return nil
+	// 按站点的 owner / group 与 cert_mode / key_mode 设置属主与权限
+	certAttrs, keyAttrs, combinedAttrs := SiteFileAttrs(site, false), SiteFileAttrs(site, true), SiteCombinedAttrs(site)
+	certAttrs.Sync, keyAttrs.Sync, combinedAttrs.Sync = d.config.SyncOnWrite, d.config.SyncOnWrite, d.config.SyncOnWrite
+
+	set := DeploySet{Domain: domain}
+	copyFile := func(name, dst string, attrs FileAttrs) error {
+		content, err := os.ReadFile(filepath.Join(srcDir, name))
+		if err != nil {
+			return fmt.Errorf("读取 %s 失败: %w", name, err)
 		}
-		dst = replaceDomain(dst)
+		set.Add(replaceDomain(dst), content, attrs)
+		return nil
+	}
 
-		// 确保目标目录存在
-		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
-			return err
+	// 部署 cert.pem、key.pem 与 fullchain.pem
+	if site.CertPath != "" {
+		if err := copyFile("cert.pem", site.CertPath, certAttrs); err != nil {
+			return nil, false, err
 		}
+	}
+	if site.KeyPath != "" {
+		if err := copyFile("key.pem", site.KeyPath, keyAttrs); err != nil {
+			return nil, false, err
+		}
+	}
+	if site.FullchainPath != "" {
+		if err := copyFile("fullchain.pem", site.FullchainPath, certAttrs); err != nil {
+			return nil, false, err
+		}
+	}
 
-		content, err := os.ReadFile(src)
+	// 由 cert.pem 与 fullchain.pem 生成证书包
+	if site.BundlePath != "" {
+		bundle, err := readBundle(srcDir)
 		if err != nil {
-			return err
+			return nil, false, fmt.Errorf("生成证书包失败: %w", err)
 		}
-		return os.WriteFile(dst, content, 0644)
+		set.Add(replaceDomain(site.BundlePath), bundle, certAttrs)
 	}
 
-	// 部署 cert.pem
-	if site.CertPath != "" {
-		if err := copyFile(filepath.Join(srcDir, "cert.pem"), site.CertPath); err != nil {
-			slog.Warn("复制 cert.pem 失败", "error", err)
+	// 按 combined_order 拼接合并文件，任一组成部分缺失时返回错误
+	if site.CombinedPath != "" {
+		combined, err := readCombined(srcDir, site.CombinedOrder)
+		if err != nil {
+			return nil, false, fmt.Errorf("生成合并文件失败: %w", err)
 		}
+		set.Add(replaceDomain(site.CombinedPath), combined, combinedAttrs)
 	}
 
-	// 部署 key.pem
-	if site.KeyPath != "" {
-		if err := copyFile(filepath.Join(srcDir, "key.pem"), site.KeyPath); err != nil {
-			slog.Warn("复制 key.pem 失败", "error", err)
+	// 由私钥与证书链生成 PKCS#12，证书与私钥不匹配时返回错误
+	if site.PKCS12Path != "" {
+		if err := addPKCS12(&set, srcDir, replaceDomain(site.PKCS12Path), site, combinedAttrs); err != nil {
+			return nil, false, fmt.Errorf("生成 PKCS#12 文件失败: %w", err)
 		}
 	}
 
-	// 部署 fullchain.pem
-	if site.FullchainPath != "" {
-		if err := copyFile(filepath.Join(srcDir, "fullchain.pem"), site.FullchainPath); err != nil {
-			slog.Warn("复制 fullchain.pem 失败", "error", err)
+	backup, unchanged, err = set.Write(retention, time.Now())
+	if err != nil {
+		return backup, false, err
+	}
+
+	// 重新读取部署文件确认与工作目录一致，失败时由调用方重试部署
+	if site.VerifyAfterDeploy {
+		if err := verifySiteDeployment(domain, srcDir, site); err != nil {
+			return backup, false, err
+		}
+	}
+	return backup, unchanged, nil
+}
+
+// readBundle 读取工作目录中的 cert.pem 与 fullchain.pem，生成单文件证书包
+func readBundle(srcDir string) ([]byte, error) {
+	certPEM, err := os.ReadFile(filepath.Join(srcDir, "cert.pem"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	fullchainPEM, err := os.ReadFile(filepath.Join(srcDir, "fullchain.pem"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return cert.BundleCerts(certPEM, fullchainPEM)
+}
+
+// readCombined 读取工作目录中的证书、私钥与证书链，按 order 拼接为单个文件
+func readCombined(srcDir, order string) ([]byte, error) {
+	read := func(name string) ([]byte, error) {
+		data, err := os.ReadFile(filepath.Join(srcDir, name))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
 		}
+		return data, nil
 	}
+	certPEM, err := read("cert.pem")
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := read("key.pem")
+	if err != nil {
+		return nil, err
+	}
+	fullchainPEM, err := read("fullchain.pem")
+	if err != nil {
+		return nil, err
+	}
+	return cert.CombinePEM(order, certPEM, keyPEM, fullchainPEM)
+}
 
+// addPKCS12 读取工作目录中的私钥与证书链（没有 fullchain.pem 时使用 cert.pem），编码为 PKCS#12 加入 set
+func addPKCS12(set *DeploySet, srcDir, dst string, site *config.SiteDeployConfig, attrs FileAttrs) error {
+	keyPEM, err := os.ReadFile(filepath.Join(srcDir, "key.pem"))
+	if err != nil {
+		return err
+	}
+	chainPEM, err := os.ReadFile(filepath.Join(srcDir, "fullchain.pem"))
+	if os.IsNotExist(err) {
+		chainPEM, err = os.ReadFile(filepath.Join(srcDir, "cert.pem"))
+	}
+	if err != nil {
+		return err
+	}
+	password, err := site.PKCS12Secret()
+	if err != nil {
+		return err
+	}
+	p12, err := cert.PEMToPKCS12(chainPEM, keyPEM, password, site.PKCS12Legacy)
+	if err != nil {
+		return err
+	}
+	set.AddPKCS12(dst, p12, chainPEM, keyPEM, password, site.PKCS12Legacy, attrs)
 	return nil
 }
 
-// deployCertFilesWithRetry 带重试的证书部署
-func (d *Daemon) deployCertFilesWithRetry(domain, srcDir string, site *config.SiteDeployConfig, maxRetries int) error {
+// deployCertFilesWithRetry 带重试的证书部署，按 backup_retention 备份，返回值含义同 deployCertFiles
+// 重试时沿用首次创建的备份，不再备份失败的尝试写入的部分文件
+func (d *Daemon) deployCertFilesWithRetry(domain, srcDir string, site *config.SiteDeployConfig, maxRetries int) (*DeployBackup, bool, error) {
+	d.mu.RLock()
+	retention := d.config.BackupRetention
+	d.mu.RUnlock()
+
+	var backup *DeployBackup
 	var lastErr error
 	for i := 0; i < maxRetries; i++ {
-		if err := d.deployCertFiles(domain, srcDir, site); err != nil {
+		b, unchanged, err := d.deployCertFiles(domain, srcDir, site, retention)
+		if b != nil {
+			backup, retention = b, 0
+		}
+		if err != nil {
 			lastErr = err
 			if i < maxRetries-1 {
-				slog.Warn("证书部署失败，重试中", "attempt", i+1, "error", err)
+				d.log().Warn("证书部署失败，重试中", "attempt", i+1, "error", err)
 				time.Sleep(time.Duration(i+1) * 500 * time.Millisecond)
 			}
 			continue
 		}
-		return nil
+		// 失败的尝试可能已写入部分文件，重试后的比对结果不能说明内容未变化
+		return backup, unchanged && i == 0, nil
 	}
-	return lastErr
+	return backup, false, lastErr
 }
 
 // sendCertAck 发送证书接收确认
@@ -493,40 +976,43 @@ func (d *Daemon) sendCertAck(domain string, success bool, message string) {
 }
 
 // heartbeat 心跳发送与 pong 超时检测
+// 超过 PongTimeout 未收到服务端任何 pong 时主动关闭连接，由重连循环重新建立，
+// 避免依赖 TCP keepalive 发现失效连接（可能需要数分钟）
 func (d *Daemon) heartbeat(ctx context.Context) {
 	ticker := time.NewTicker(d.config.HeartbeatInterval)
 	defer ticker.Stop()
 
+	// 静默检测粒度：pong_timeout 的 1/4，最少 1 秒
+	checkInterval := d.config.PongTimeout / 4
+	if checkInterval < time.Second {
+		checkInterval = time.Second
+	}
+	checker := time.NewTicker(checkInterval)
+	defer checker.Stop()
+
 	d.updateLastPong() // 初始化 pong 时间
-	missedPongs := 0
-	const maxMissed = 3 // 连续丢失 3 次 pong 则断开
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
-			// 检查 pong 超时
-			if time.Since(d.getLastPong()) > d.config.HeartbeatInterval*2 {
-				missedPongs++
-				slog.Warn("心跳响应超时", "missed", missedPongs)
-				if missedPongs >= maxMissed {
-					slog.Error("心跳超时，关闭连接")
-					d.conn.Close()
-					return
-				}
-			} else {
-				missedPongs = 0
+		case <-checker.C:
+			if silence := time.Since(d.getLastPong()); silence > d.config.PongTimeout {
+				d.log().Error("服务端长时间无响应，判定连接已失效，断开重连",
+					"silence", silence.Round(time.Second),
+					"pong_timeout", d.config.PongTimeout)
+				d.conn.Close()
+				return
 			}
-
+		case <-ticker.C:
 			// 发送 ping
 			msg, _ := ws.NewMessage(ws.MsgTypePing, nil)
 			data, _ := json.Marshal(msg)
 			if err := d.writeMessage(data); err != nil {
-				slog.Warn("发送心跳失败", "error", err)
+				d.log().Warn("发送心跳失败", "error", err)
 				return
 			}
-			slog.Debug("发送心跳")
+			d.log().Debug("发送心跳")
 		}
 	}
 }
@@ -545,43 +1031,103 @@ func (d *Daemon) handleConfigUpdates(ctx context.Context) {
 			if update == nil {
 				continue
 			}
-			d.applyConfigUpdate(update)
+			d.applyConfigUpdate(update, true)
+		}
+	}
+}
+
+// drainConfigUpdates 按顺序应用通道中积压的配置更新，不发送订阅请求
+// 在连接前调用，新的服务端地址与订阅列表随本次连接与认证生效
+func (d *Daemon) drainConfigUpdates() {
+	for {
+		select {
+		case update := <-d.configUpdates:
+			if update != nil {
+				d.applyConfigUpdate(update, false)
+			}
+		default:
+			return
 		}
 	}
 }
 
-// applyConfigUpdate 应用配置更新
-func (d *Daemon) applyConfigUpdate(update *ConfigUpdate) {
+// applyConfigUpdate 应用配置更新，online 为 true 时订阅变化立即发送订阅请求，
+// 服务端地址变化时断开当前连接，由 Run 立即重连新地址
+func (d *Daemon) applyConfigUpdate(update *ConfigUpdate, online bool) {
 	d.mu.Lock()
-	oldSubscribe := d.config.Subscribe
+	oldSubscribe, oldServerURL, clientID := d.config.Subscribe, d.config.ServerURL, d.config.ClientID
 	d.config.Subscribe = update.NewSubscribe
 	d.config.Sites = update.NewSites
+	serverChanged := update.NewServerURL != "" && update.NewServerURL != oldServerURL
+	if serverChanged {
+		d.config.ServerURL = update.NewServerURL
+		d.redial = online
+	}
 	d.mu.Unlock()
+	d.state.bumpConfigGeneration()
+
+	if serverChanged {
+		// 之后的日志携带新地址
+		logger := newDaemonLogger(update.NewServerURL, clientID)
+		d.logger.Store(logger)
+		d.state.setServerURL(update.NewServerURL, logger)
+		d.log().Info("服务端地址已变更", "old", oldServerURL, "new", update.NewServerURL)
+		if online {
+			// 关闭连接使 readLoop 返回，订阅随新连接的认证请求发送
+			d.log().Info("断开当前连接，重新连接新地址")
+			d.conn.Close()
+		}
+	}
 
-	slog.Info("应用配置更新",
+	d.log().Info("应用配置更新",
 		"old_subscribe", oldSubscribe,
 		"new_subscribe", update.NewSubscribe,
 		"sites_count", len(update.NewSites))
 
-	// 如果订阅列表发生变化，发送新的订阅请求
-	if !stringSlicesEqual(oldSubscribe, update.NewSubscribe) {
+	// 如果订阅列表发生变化，发送新的订阅请求；未连接或即将重连新地址时随下一次认证请求生效
+	switch {
+	case stringSlicesEqual(oldSubscribe, update.NewSubscribe):
+	case !online || serverChanged:
+		d.log().Info("订阅列表已更新，将随认证请求生效", "domains", update.NewSubscribe)
+	default:
 		if err := d.sendSubscription(update.NewSubscribe); err != nil {
-			slog.Error("发送订阅更新失败", "error", err)
+			d.log().Error("发送订阅更新失败", "error", err)
 		} else {
-			slog.Info("订阅更新已发送", "domains", update.NewSubscribe)
+			d.log().Info("订阅更新已发送", "domains", update.NewSubscribe)
+		}
+	}
+
+	// 订阅列表缩减时清理不再订阅的域名目录
+	if d.config.CleanupWorkdir && subscriptionShrunk(oldSubscribe, update.NewSubscribe) {
+		removed, err := workspace.CleanupWorkdir(d.config.WorkDir, update.NewSubscribe, false)
+		if err != nil {
+			d.log().Error("清理工作目录失败", "workdir", d.config.WorkDir, "error", err)
+		} else if len(removed) > 0 {
+			d.log().Info("已清理不再订阅的域名目录", "domains", removed)
 		}
 	}
 }
 
-// UpdateConfig 更新配置（供外部调用）
-func (d *Daemon) UpdateConfig(newSubscribe []string, newSites []config.SiteDeployConfig) {
+// subscriptionShrunk 判断新订阅列表是否移除了旧列表中的域名模式
+func subscriptionShrunk(oldSubscribe, newSubscribe []string) bool {
+	for _, pattern := range oldSubscribe {
+		if !slices.Contains(newSubscribe, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateConfig 更新配置（供外部调用），newServerURL 为空表示服务端地址不变
+func (d *Daemon) UpdateConfig(newServerURL string, newSubscribe []string, newSites []config.SiteDeployConfig) {
 	select {
 	case d.configUpdates <- &ConfigUpdate{
+		NewServerURL: newServerURL,
 		NewSubscribe: newSubscribe,
 		NewSites:     newSites,
 	}:
 	default:
-		slog.Warn("配置更新通道已满，跳过此次更新")
+		d.log().Warn("配置更新通道已满，跳过此次更新")
 	}
 }
 
@@ -624,6 +1170,11 @@ func stringSlicesEqual(a, b []string) bool {
 // requestSync 请求同步证书
 // 收集本地订阅域名的时间戳，发送给服务端比对
 func (d *Daemon) requestSync() error {
+	return d.sendSyncRequest(false)
+}
+
+// sendSyncRequest 发送证书同步请求，force 为 true 时时间戳全部置 0，服务端推送订阅的全部证书
+func (d *Daemon) sendSyncRequest(force bool) error {
 	if d.conn == nil {
 		return nil
 	}
@@ -637,14 +1188,24 @@ func (d *Daemon) requestSync() error {
 	for _, domain := range subscribe {
 		if domain == "*" {
 			// 全局订阅：收集本地所有域名的时间戳
-			d.collectAllLocalTimestamps(workDir, timestamps)
+			local, err := workspace.ListDomains(workDir)
+			if err != nil && !os.IsNotExist(err) {
+				d.log().Warn("扫描本地证书目录失败", "workdir", workDir, "error", err)
+			}
+			for domain, ts := range local {
+				timestamps[domain] = ts
+			}
 			continue
 		}
-		ts := d.readLocalTimestamp(workDir, domain)
-		timestamps[domain] = ts
+		timestamps[domain] = workspace.GetDomainTimestamp(workDir, domain)
+	}
+	if force {
+		for domain := range timestamps {
+			timestamps[domain] = 0
+		}
 	}
 
-	slog.Debug("发送证书同步请求", "domains", len(timestamps))
+	d.log().Debug("发送证书同步请求", "domains", len(timestamps), "force", force)
 
 	req := &ws.SyncRequest{Timestamps: timestamps}
 	msg, err := ws.NewMessage(ws.MsgTypeSyncRequest, req)
@@ -660,54 +1221,20 @@ func (d *Daemon) requestSync() error {
 	return d.writeMessage(data)
 }
 
-// readLocalTimestamp 读取本地指定域名的时间戳
-func (d *Daemon) readLocalTimestamp(workDir, domain string) int64 {
-	timeLogPath := filepath.Join(workDir, domain, "time.log")
-	content, err := os.ReadFile(timeLogPath)
-	if err != nil {
-		return 0 // 文件不存在返回 0，表示需要同步
-	}
-
-	ts := strings.TrimSpace(string(content))
-	if len(ts) > 10 {
-		ts = ts[:10]
-	}
-
-	if t, err := strconv.ParseInt(ts, 10, 64); err == nil {
-		return t
-	}
-	return 0
-}
-
-// collectAllLocalTimestamps 收集本地所有域名的时间戳（用于全局订阅 "*"）
-func (d *Daemon) collectAllLocalTimestamps(workDir string, timestamps map[string]int64) {
-	entries, err := os.ReadDir(workDir)
-	if err != nil {
-		return
-	}
-
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
-		}
-		domain := entry.Name()
-		ts := d.readLocalTimestamp(workDir, domain)
-		timestamps[domain] = ts
-	}
-}
-
 // syncLoop 定时同步循环
+// 每次连接启动一个独立于心跳的 ticker，即使没有收到推送也定期发送同步请求，
+// 网络抖动导致推送丢失时由服务端比对时间戳后补推
 func (d *Daemon) syncLoop(ctx context.Context) {
 	d.mu.RLock()
 	interval := d.config.SyncInterval
 	d.mu.RUnlock()
 
 	if interval <= 0 {
-		slog.Debug("定时同步已禁用")
+		d.log().Debug("定时同步已禁用")
 		return
 	}
 
-	slog.Info("启动定时同步", "interval", interval)
+	d.log().Info("启动定时同步", "interval", interval)
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
@@ -716,10 +1243,105 @@ func (d *Daemon) syncLoop(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			slog.Debug("执行定时证书同步")
+			d.log().Debug("执行定时证书同步")
 			if err := d.requestSync(); err != nil {
-				slog.Warn("定时同步请求失败", "error", err)
+				d.log().Warn("定时同步请求失败", "error", err)
 			}
 		}
 	}
 }
+
+// Resync 一次性强制同步，用于 CLI 的 --resync
+// 连接服务端并以全部为 0 的时间戳发送同步请求，服务端推送订阅的全部证书；
+// 每个推送都重写工作目录与部署文件，且即使部署文件内容未变化也触发 reload。
+// 收到服务端的同步结果后立即执行防抖队列中的 reload，返回推送数量；timeout 为等待认证与同步结果的最长时间
+func (d *Daemon) Resync(ctx context.Context, timeout time.Duration) (int, error) {
+	if err := os.MkdirAll(d.config.WorkDir, 0755); err != nil {
+		return 0, err
+	}
+	conn, err := d.dial(ctx)
+	if err != nil {
+		return 0, err
+	}
+	d.conn = conn
+	defer conn.Close()
+	defer d.flushReloads()
+
+	d.mu.Lock()
+	d.authVersion = security.SignatureV2
+	d.forcePush = true
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		d.forcePush = false
+		d.mu.Unlock()
+	}()
+	if err := d.authenticate(); err != nil {
+		return 0, err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return 0, fmt.Errorf("等待同步结果失败: %w", err)
+		}
+		var msg ws.Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			d.log().Warn("解析消息失败", "error", err)
+			continue
+		}
+
+		switch msg.Type {
+		case ws.MsgTypeAuthResult:
+			var resp ws.AuthResponse
+			if err := msg.ParseData(&resp); err != nil {
+				return 0, fmt.Errorf("解析认证响应失败: %w", err)
+			}
+			d.mu.RLock()
+			sent := d.authSentAt
+			d.mu.RUnlock()
+			skew, hasSkew := clockSkew(resp.ServerTime, sent, time.Now())
+			if hasSkew {
+				logClockSkew(d.log(), skew)
+			}
+			if !resp.Success {
+				if d.retryLegacyAuth(resp.SignatureVersion) {
+					continue
+				}
+				if hasSkew && clockSkewExceeded(skew) {
+					return 0, fmt.Errorf("认证被拒绝: %s（%s，请校准系统时间）", resp.Message, describeClockSkew(skew))
+				}
+				d.mu.RLock()
+				version := d.authVersion
+				d.mu.RUnlock()
+				return 0, legacyAuthError(fmt.Errorf("认证被拒绝: %s", resp.Message), version, resp.SignatureVersion, d.config.DisableLegacyAuth)
+			}
+			if err := d.sendSyncRequest(true); err != nil {
+				return 0, fmt.Errorf("发送同步请求失败: %w", err)
+			}
+
+		case ws.MsgTypeSyncResult:
+			var result ws.SyncResult
+			if err := msg.ParseData(&result); err != nil {
+				return 0, fmt.Errorf("解析同步结果失败: %w", err)
+			}
+			return result.Pushed, nil
+
+		case ws.MsgTypeError:
+			var errData ws.ErrorData
+			if err := msg.ParseData(&errData); err != nil {
+				return 0, fmt.Errorf("解析错误消息失败: %w", err)
+			}
+			return 0, fmt.Errorf("服务端返回错误 (%d): %s", errData.Code, errData.Message)
+
+		default:
+			d.handleMessage(&msg)
+		}
+	}
+}
+
+// flushReloads 立即执行防抖队列中的 reload 并等待结束
+func (d *Daemon) flushReloads() {
+	d.reloadDebouncer.Flush()
+}
@@ -0,0 +1,201 @@
+package client
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// StatusFile daemon 状态文件名（位于工作目录），内容与本地 /status 接口相同
+const StatusFile = "daemon-status.json"
+
+// DaemonStatus daemon 运行状态快照，/status 接口与 daemon-status.json 的 JSON 结构
+type DaemonStatus struct {
+	Connected        bool                    `json:"connected"`             // 是否已连接服务端
+	ServerURL        string                  `json:"server_url"`            // 服务端地址
+	ClientID         string                  `json:"client_id"`             // 客户端标识
+	StartedAt        time.Time               `json:"started_at"`            // daemon 启动时间
+	UpdatedAt        time.Time               `json:"updated_at"`            // 状态最近一次变化的时间
+	LastAuth         *time.Time              `json:"last_auth,omitempty"`   // 最近一次认证成功的时间
+	ConfigGeneration int                     `json:"config_generation"`     // 配置版本，启动时为 1，每应用一次热重载加 1
+	LastReload       *OperationResult        `json:"last_reload,omitempty"` // 最近一次 reload 命令的结果
+	Domains          map[string]*DomainState `json:"domains"`               // 域名 -> 推送与部署状态
+}
+
+// DomainState 单个域名的推送与部署状态
+type DomainState struct {
+	LastPush   *time.Time       `json:"last_push,omitempty"`   // 最近一次收到推送的时间
+	LastDeploy *OperationResult `json:"last_deploy,omitempty"` // 最近一次部署的结果
+}
+
+// OperationResult 部署或 reload 的执行结果
+type OperationResult struct {
+	At      time.Time `json:"at"`
+	OK      bool      `json:"ok"`
+	Command string    `json:"command,omitempty"` // reload 命令（仅 last_reload）
+	Error   string    `json:"error,omitempty"`
+}
+
+// newOperationResult 根据执行错误生成结果
+func newOperationResult(at time.Time, command string, err error) *OperationResult {
+	r := &OperationResult{At: at, OK: err == nil, Command: command}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// DaemonState 记录 daemon 运行状态，并发安全
+// 设置了状态文件路径时，每次变化都以原子替换的方式写入状态文件，供监控程序无需端口即可读取
+type DaemonState struct {
+	mu     sync.Mutex
+	status DaemonStatus
+	path   string // 状态文件路径，空表示不写文件
+	now    func() time.Time
+	logger *slog.Logger
+}
+
+// newDaemonState 创建 daemon 状态
+func newDaemonState(serverURL, clientID string, logger *slog.Logger) *DaemonState {
+	now := time.Now()
+	return &DaemonState{
+		status: DaemonStatus{
+			ServerURL:        serverURL,
+			ClientID:         clientID,
+			StartedAt:        now,
+			UpdatedAt:        now,
+			ConfigGeneration: 1,
+			Domains:          make(map[string]*DomainState),
+		},
+		now:    time.Now,
+		logger: logger,
+	}
+}
+
+// Snapshot 返回当前状态的副本
+func (s *DaemonState) Snapshot() DaemonStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshotLocked()
+}
+
+// snapshotLocked 深拷贝当前状态，调用方须持有锁
+func (s *DaemonState) snapshotLocked() DaemonStatus {
+	snap := s.status
+	if s.status.LastReload != nil {
+		r := *s.status.LastReload
+		snap.LastReload = &r
+	}
+	snap.Domains = make(map[string]*DomainState, len(s.status.Domains))
+	for domain, ds := range s.status.Domains {
+		c := &DomainState{LastPush: ds.LastPush}
+		if ds.LastDeploy != nil {
+			r := *ds.LastDeploy
+			c.LastDeploy = &r
+		}
+		snap.Domains[domain] = c
+	}
+	return snap
+}
+
+// enableFile 开始将状态写入 path，并立即写入一次
+func (s *DaemonState) enableFile(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.path = path
+	return s.writeFileLocked()
+}
+
+// update 在锁内修改状态并写入状态文件，写入失败只记录警告
+func (s *DaemonState) update(fn func(status *DaemonStatus, now time.Time)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	fn(&s.status, now)
+	s.status.UpdatedAt = now
+	if err := s.writeFileLocked(); err != nil {
+		s.logger.Warn("更新 daemon 状态文件失败", "path", s.path, "error", err)
+	}
+}
+
+// domain 返回域名的状态，不存在时创建
+func (s *DaemonStatus) domain(name string) *DomainState {
+	ds, ok := s.Domains[name]
+	if !ok {
+		ds = &DomainState{}
+		s.Domains[name] = ds
+	}
+	return ds
+}
+
+// setConnected 记录连接状态
+func (s *DaemonState) setConnected(connected bool) {
+	s.update(func(status *DaemonStatus, _ time.Time) { status.Connected = connected })
+}
+
+// setServerURL 记录热重载后的服务端地址，之后使用携带新地址的 logger 记录日志
+func (s *DaemonState) setServerURL(serverURL string, logger *slog.Logger) {
+	s.update(func(status *DaemonStatus, _ time.Time) {
+		status.ServerURL = serverURL
+		s.logger = logger
+	})
+}
+
+// recordAuth 记录认证成功
+func (s *DaemonState) recordAuth() {
+	s.update(func(status *DaemonStatus, now time.Time) { status.LastAuth = &now })
+}
+
+// recordPush 记录收到域名的证书推送
+func (s *DaemonState) recordPush(domain string) {
+	s.update(func(status *DaemonStatus, now time.Time) { status.domain(domain).LastPush = &now })
+}
+
+// recordDeploy 记录域名的部署结果
+func (s *DaemonState) recordDeploy(domain string, err error) {
+	s.update(func(status *DaemonStatus, now time.Time) {
+		status.domain(domain).LastDeploy = newOperationResult(now, "", err)
+	})
+}
+
+// recordReload 记录 reload 命令的执行结果
+func (s *DaemonState) recordReload(cmd string, err error) {
+	s.update(func(status *DaemonStatus, now time.Time) {
+		status.LastReload = newOperationResult(now, cmd, err)
+	})
+}
+
+// bumpConfigGeneration 应用一次配置热重载
+func (s *DaemonState) bumpConfigGeneration() {
+	s.update(func(status *DaemonStatus, _ time.Time) { status.ConfigGeneration++ })
+}
+
+// writeFileLocked 先写临时文件再重命名，读取方不会看到写了一半的内容；调用方须持有锁
+func (s *DaemonState) writeFileLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.status, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), "."+filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
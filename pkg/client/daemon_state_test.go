@@ -0,0 +1,191 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// newTestDaemonState 创建时钟固定的 daemon 状态
+func newTestDaemonState(now time.Time) *DaemonState {
+	s := newDaemonState("wss://acme.example.com:9090", "web-1", slog.Default())
+	s.status.StartedAt, s.status.UpdatedAt = now, now
+	s.now = func() time.Time { return now }
+	return s
+}
+
+// jsonKeys 返回 JSON 对象的键（已排序）
+func jsonKeys(t *testing.T, data []byte) []string {
+	t.Helper()
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestDaemonStatus_JSON(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	s := newTestDaemonState(now)
+
+	// 初始状态不输出尚未发生的事件
+	data, _ := json.Marshal(s.Snapshot())
+	want := []string{"client_id", "config_generation", "connected", "domains", "server_url", "started_at", "updated_at"}
+	if got := jsonKeys(t, data); !reflect.DeepEqual(got, want) {
+		t.Errorf("初始状态 JSON 键 = %v, want %v", got, want)
+	}
+
+	s.setConnected(true)
+	s.recordAuth()
+	s.recordPush("example.com")
+	s.recordDeploy("example.com", nil)
+	s.recordDeploy("api.example.com", errors.New("权限不足"))
+	s.recordReload("systemctl reload nginx", nil)
+	s.bumpConfigGeneration()
+
+	data, _ = json.Marshal(s.Snapshot())
+	var got map[string]interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	ts := now.Format(time.RFC3339)
+	wantJSON := map[string]interface{}{
+		"connected":         true,
+		"server_url":        "wss://acme.example.com:9090",
+		"client_id":         "web-1",
+		"started_at":        ts,
+		"updated_at":        ts,
+		"last_auth":         ts,
+		"config_generation": float64(2),
+		"last_reload":       map[string]interface{}{"at": ts, "ok": true, "command": "systemctl reload nginx"},
+		"domains": map[string]interface{}{
+			"example.com": map[string]interface{}{
+				"last_push":   ts,
+				"last_deploy": map[string]interface{}{"at": ts, "ok": true},
+			},
+			"api.example.com": map[string]interface{}{
+				"last_deploy": map[string]interface{}{"at": ts, "ok": false, "error": "权限不足"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, wantJSON) {
+		t.Errorf("状态 JSON = %s", data)
+	}
+}
+
+func TestDaemonState_SnapshotIsCopy(t *testing.T) {
+	s := newTestDaemonState(time.Now())
+	s.recordDeploy("example.com", nil)
+	s.recordReload("reload", nil)
+
+	snap := s.Snapshot()
+	snap.Domains["example.com"].LastDeploy.OK = false
+	snap.Domains["other.com"] = &DomainState{}
+	snap.LastReload.OK = false
+
+	again := s.Snapshot()
+	if !again.Domains["example.com"].LastDeploy.OK || !again.LastReload.OK || len(again.Domains) != 1 {
+		t.Error("修改快照不应影响 daemon 状态")
+	}
+}
+
+func TestDaemonState_WritesFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, StatusFile)
+	s := newTestDaemonState(time.Now())
+
+	// 未启用状态文件时不写入
+	s.setConnected(true)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("未启用状态文件时不应写入")
+	}
+
+	if err := s.enableFile(path); err != nil {
+		t.Fatal(err)
+	}
+	s.recordPush("example.com")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status DaemonStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		t.Fatalf("状态文件不是有效的 JSON: %v", err)
+	}
+	if !status.Connected || status.Domains["example.com"] == nil || status.Domains["example.com"].LastPush == nil {
+		t.Errorf("状态文件内容未更新: %s", data)
+	}
+
+	// 写入后不残留临时文件
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("目录中应只有状态文件，实际 %d 个文件", len(entries))
+	}
+}
+
+func TestDaemon_StatusHandler(t *testing.T) {
+	d := NewDaemon(&DaemonConfig{ServerURL: "wss://acme.example.com:9090", ClientID: "web-1"})
+	handler := d.statusHandler()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get("/healthz")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("未连接时 /healthz 状态码 = %d, want 503", w.Code)
+	}
+	var health StatusHealthResponse
+	json.Unmarshal(w.Body.Bytes(), &health)
+	if health.Status != statusHealthDisconnected || health.Connected {
+		t.Errorf("未连接时 /healthz = %+v", health)
+	}
+
+	d.state.setConnected(true)
+	if w := get("/healthz"); w.Code != http.StatusOK {
+		t.Errorf("已连接时 /healthz 状态码 = %d, want 200", w.Code)
+	}
+
+	d.applyConfigUpdate(&ConfigUpdate{}, false)
+	w = get("/status")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("/status 状态码 = %d, Content-Type = %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	var status DaemonStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if !status.Connected || status.ClientID != "web-1" || status.ConfigGeneration != 2 {
+		t.Errorf("/status = %+v", status)
+	}
+	if d.Status().ConfigGeneration != 2 {
+		t.Error("Status() 应与 /status 一致")
+	}
+}
+
+func TestReloadDebouncer_OnResult(t *testing.T) {
+	d := NewDaemon(&DaemonConfig{ReloadDebounce: time.Hour})
+	d.reloadDebouncer.Trigger("false")
+	d.flushReloads()
+
+	r := d.Status().LastReload
+	if r == nil || r.OK || r.Command != "false" || r.Error == "" {
+		t.Errorf("last_reload = %+v, want 失败的 false 命令", r)
+	}
+}
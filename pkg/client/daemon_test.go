@@ -0,0 +1,835 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/Catker/acmeDeliver/pkg/config"
+	"github.com/Catker/acmeDeliver/pkg/security"
+	"github.com/Catker/acmeDeliver/pkg/testutil"
+	"github.com/Catker/acmeDeliver/pkg/testutil/wstest"
+	ws "github.com/Catker/acmeDeliver/pkg/websocket"
+	"github.com/Catker/acmeDeliver/pkg/workspace"
+)
+
+func TestNewDaemon_LoggerAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	old := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(old) })
+
+	d := NewDaemon(&DaemonConfig{ServerURL: "wss://cert.example.com", ClientID: "node-1"})
+	d.log().Info("测试")
+
+	line := buf.String()
+	if !strings.Contains(line, "server_url=wss://cert.example.com") || !strings.Contains(line, "client_id=node-1") {
+		t.Errorf("日志缺少标识属性: %q", line)
+	}
+
+	// 热重载修改服务端地址后，日志携带新地址
+	d.applyConfigUpdate(&ConfigUpdate{NewServerURL: "wss://new.example.com"}, false)
+	buf.Reset()
+	d.log().Info("测试")
+	line = buf.String()
+	if !strings.Contains(line, "server_url=wss://new.example.com") || !strings.Contains(line, "client_id=node-1") {
+		t.Errorf("服务端地址变更后日志属性 = %q", line)
+	}
+}
+
+func TestNewDaemon_PongTimeoutDefaults(t *testing.T) {
+	tests := []struct {
+		name      string
+		heartbeat time.Duration
+		pong      time.Duration
+		want      time.Duration
+	}{
+		{"默认值", 60 * time.Second, 0, ws.DefaultPongTimeout},
+		{"自定义", 30 * time.Second, 45 * time.Second, 45 * time.Second},
+		{"不大于心跳间隔时自动调整", 60 * time.Second, 60 * time.Second, 180 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDaemon(&DaemonConfig{HeartbeatInterval: tt.heartbeat, PongTimeout: tt.pong})
+			if d.config.PongTimeout != tt.want {
+				t.Errorf("PongTimeout = %v, want %v", d.config.PongTimeout, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewDaemon_TimeoutDefaults(t *testing.T) {
+	d := NewDaemon(&DaemonConfig{})
+	if d.config.ShutdownTimeout != DefaultShutdownTimeout {
+		t.Errorf("ShutdownTimeout = %v, want %v", d.config.ShutdownTimeout, DefaultShutdownTimeout)
+	}
+	if d.reloadDebouncer.timeout != DefaultReloadTimeout {
+		t.Errorf("reload timeout = %v, want %v", d.reloadDebouncer.timeout, DefaultReloadTimeout)
+	}
+
+	d = NewDaemon(&DaemonConfig{ReloadTimeout: time.Minute, ShutdownTimeout: 2 * time.Minute})
+	if d.config.ShutdownTimeout != 2*time.Minute || d.reloadDebouncer.timeout != time.Minute {
+		t.Errorf("ShutdownTimeout = %v, reload timeout = %v", d.config.ShutdownTimeout, d.reloadDebouncer.timeout)
+	}
+}
+
+// errReader 总是返回错误的随机源
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, errors.New("entropy unavailable") }
+
+func TestReconnectJitter(t *testing.T) {
+	tests := []struct {
+		name    string
+		rand    []byte
+		limit   time.Duration
+		want    time.Duration
+		wantErr bool
+	}{
+		// 10s = 1e10ns 共 34 位，rand.Int 读取 5 字节（大端）
+		{"按随机源取值", []byte{0x00, 0x00, 0x00, 0x03, 0xE8}, 10 * time.Second, 1000, false},
+		{"随机源全零", make([]byte, 8), 10 * time.Second, 0, false},
+		{"上限为 0 时不读取随机源", nil, 0, 0, false},
+		{"随机源不足", []byte{0x01}, 10 * time.Second, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := reconnectJitter(bytes.NewReader(tt.rand), tt.limit)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("reconnectJitter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("reconnectJitter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconnectJitter_WithinRange(t *testing.T) {
+	limit := 10 * time.Second
+	// 用确定性的字节序列覆盖不同取值，结果必须落在 [0, limit)
+	for seed := 0; seed < 256; seed++ {
+		buf := make([]byte, 64)
+		for i := range buf {
+			buf[i] = byte(seed*31 + i*7)
+		}
+		got, err := reconnectJitter(bytes.NewReader(buf), limit)
+		if err != nil {
+			t.Fatalf("seed %d: reconnectJitter() error = %v", seed, err)
+		}
+		if got < 0 || got >= limit {
+			t.Errorf("seed %d: jitter = %v, 超出 [0, %v)", seed, got, limit)
+		}
+	}
+}
+
+func TestDaemon_RunAppliesReconnectJitter(t *testing.T) {
+	tests := []struct {
+		name         string
+		rand         io.Reader
+		wantAttempts func(n int32) bool
+	}{
+		// 3<<40ns ≈ 55 分钟，首次重连被推迟到测试结束之后
+		{"抖动推迟首次重连", bytes.NewReader(bytes.Repeat([]byte{0x03, 0, 0, 0, 0, 0}, 4)), func(n int32) bool { return n == 1 }},
+		// 抖动为 0 时按 10ms 起步的指数退避多次重连
+		{"零抖动", bytes.NewReader(make([]byte, 64)), func(n int32) bool { return n > 1 }},
+		{"随机源失败时不附加抖动", errReader{}, func(n int32) bool { return n > 1 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := wstest.NewMockServer(t)
+			server.SetUnavailable(http.StatusServiceUnavailable)
+
+			d := NewDaemon(&DaemonConfig{
+				ServerURL:          server.WSURL(),
+				WorkDir:            t.TempDir(),
+				ReconnectInterval:  10 * time.Millisecond,
+				ReconnectJitterMax: time.Hour,
+			})
+			d.jitterRand = tt.rand
+
+			ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+			defer cancel()
+			if err := d.Run(ctx); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			if n := int32(server.Attempts()); !tt.wantAttempts(n) {
+				t.Errorf("连接尝试次数 = %d，不符合预期", n)
+			}
+		})
+	}
+}
+
+func TestDaemon_HeartbeatClosesStaleConnection(t *testing.T) {
+	// 服务端只读取消息，从不回复 pong，模拟失效连接
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	d := NewDaemon(&DaemonConfig{
+		HeartbeatInterval: 100 * time.Millisecond,
+		PongTimeout:       1500 * time.Millisecond,
+	})
+	d.conn = conn
+
+	done := make(chan struct{})
+	start := time.Now()
+	go func() {
+		d.heartbeat(context.Background())
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("pong 超时后 heartbeat 应关闭连接并退出")
+	}
+
+	if elapsed := time.Since(start); elapsed < 1500*time.Millisecond {
+		t.Errorf("heartbeat 在 %v 后退出，早于 pong_timeout", elapsed)
+	}
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Error("连接应已被关闭")
+	}
+}
+
+func TestDaemon_SyncLoopSendsPeriodicSync(t *testing.T) {
+	// 服务端记录收到的同步请求，从不推送证书，模拟推送丢失
+	upgrader := websocket.Upgrader{}
+	requests := make(chan ws.SyncRequest, 16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var msg ws.Message
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			var req ws.SyncRequest
+			if msg.Type == ws.MsgTypeSyncRequest && msg.ParseData(&req) == nil {
+				requests <- req
+			}
+		}
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	workDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(workDir, "example.com"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(workDir, "example.com", "time.log"), []byte("1700000000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDaemon(&DaemonConfig{
+		WorkDir:           workDir,
+		Subscribe:         []string{"*"},
+		HeartbeatInterval: time.Hour,
+		SyncInterval:      50 * time.Millisecond,
+	})
+	d.conn = conn
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.syncLoop(ctx)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case req := <-requests:
+			if ts := req.Timestamps["example.com"]; ts != 1700000000 {
+				t.Errorf("同步请求中的时间戳 = %d, want 1700000000", ts)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("未收到第 %d 次定时同步请求", i+1)
+		}
+	}
+}
+
+func TestDaemon_SyncLoopDisabled(t *testing.T) {
+	d := NewDaemon(&DaemonConfig{})
+	done := make(chan struct{})
+	go func() {
+		d.syncLoop(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("SyncInterval 为 0 时 syncLoop 应立即返回")
+	}
+}
+
+func TestDaemon_Resync(t *testing.T) {
+	server := wstest.NewMockServer(t)
+	certPEM, _ := server.AddDomain(t, "example.com")
+
+	workDir := t.TempDir()
+	deployDir := t.TempDir()
+	certPath := filepath.Join(deployDir, "cert.pem")
+	marker := filepath.Join(deployDir, "reloaded")
+	d := NewDaemon(&DaemonConfig{
+		ServerURL: server.URL,
+		Password:  "test-password",
+		ClientID:  "node-1-resync",
+		WorkDir:   workDir,
+		Subscribe: []string{"example.com"},
+		Sites:     []config.SiteDeployConfig{{Domain: "example.com", CertPath: certPath, ReloadCmd: "touch " + marker}},
+	})
+
+	// 本地已是最新时，无论部署文件内容未变化还是被修改，强制同步都重写文件并执行 reload
+	for i := 0; i < 3; i++ {
+		if i == 2 {
+			os.WriteFile(certPath, []byte("local-edit"), 0644)
+		}
+		os.Remove(marker)
+		pushed, err := d.Resync(context.Background(), 5*time.Second)
+		if err != nil {
+			t.Fatalf("第 %d 次 Resync() error = %v", i+1, err)
+		}
+		if pushed != 1 {
+			t.Errorf("第 %d 次 Resync() pushed = %d, want 1", i+1, pushed)
+		}
+		if data, err := os.ReadFile(certPath); err != nil || !bytes.Equal(data, certPEM) {
+			t.Errorf("第 %d 次同步后部署的证书不正确, err = %v", i+1, err)
+		}
+		if _, err := os.Stat(marker); err != nil {
+			t.Errorf("第 %d 次同步后 reload 未执行: %v", i+1, err)
+		}
+	}
+}
+
+func TestDaemon_ResyncAuthFailure(t *testing.T) {
+	server := wstest.NewMockServer(t)
+	d := NewDaemon(&DaemonConfig{ServerURL: server.URL, Password: "wrong", ClientID: "node-1", WorkDir: t.TempDir()})
+	if _, err := d.Resync(context.Background(), 5*time.Second); err == nil || !strings.Contains(err.Error(), "认证被拒绝") {
+		t.Errorf("Resync() error = %v, want 认证被拒绝", err)
+	}
+}
+
+func TestDaemon_KeyRotationReauthenticates(t *testing.T) {
+	server := wstest.NewMockServer(t, wstest.WithPassword("old-key"))
+	hub := server.Hub
+
+	conn, _, err := websocket.DefaultDialer.Dial(server.WSURL(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configFile, []byte("client:\n  password: \"old-key\"\n  client_id: \"node-1\"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	d := NewDaemon(&DaemonConfig{Password: "old-key", ClientID: "node-1", WorkDir: t.TempDir(), ConfigFile: configFile})
+	d.conn = conn
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.readLoop(ctx)
+	if err := d.authenticate(); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(hub.GetClientStatus()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("daemon 未完成认证")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := hub.BeginKeyRotation("old-key", "new-key"); err != nil {
+		t.Fatal(err)
+	}
+	defer hub.EndKeyRotation()
+
+	result := hub.WaitKeyRotation(5 * time.Second)
+	if len(result.Reauthenticated) != 1 || result.Reauthenticated[0] != "node-1" {
+		t.Fatalf("Reauthenticated = %v, Missing = %v", result.Reauthenticated, result.Missing)
+	}
+
+	d.mu.RLock()
+	password := d.config.Password
+	d.mu.RUnlock()
+	if password != "new-key" {
+		t.Errorf("Password = %q, want new-key", password)
+	}
+
+	// 重启：服务端已提交新密钥，daemon 从配置文件读取写回的新密码后仍能认证
+	cfg, err := config.LoadClientConfigUnvalidated(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Password != "new-key" {
+		t.Fatalf("配置文件中的 password = %q, want new-key", cfg.Password)
+	}
+	restarted := wstest.NewMockServer(t, wstest.WithPassword("new-key"))
+	d = NewDaemon(&DaemonConfig{ServerURL: restarted.URL, Password: cfg.Password, ClientID: cfg.ClientID, WorkDir: t.TempDir()})
+	if _, err := d.Resync(context.Background(), 5*time.Second); err != nil {
+		t.Errorf("重启后使用配置文件中的密码认证失败: %v", err)
+	}
+}
+
+func TestDaemon_KeyRotationIgnoresForgedMessage(t *testing.T) {
+	d := NewDaemon(&DaemonConfig{Password: "old-key"})
+
+	sealed, err := security.SealRotationKey("attacker-key", "evil-key", 1700000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.handleKeyRotation(&ws.KeyRotationData{SealedKey: sealed}, 1700000000)
+
+	if d.config.Password != "old-key" || d.rotating {
+		t.Errorf("伪造的轮换消息不应修改密码, Password = %q", d.config.Password)
+	}
+}
+
+func TestDaemon_HandleSubscribeResult(t *testing.T) {
+	tests := []struct {
+		name   string
+		result ws.SubscribeResult
+		want   []string
+	}{
+		{"全部接受", ws.SubscribeResult{Accepted: []string{"a.example.com", "*.example.org"}},
+			[]string{"level=INFO msg=订阅已生效", "accepted=\"[a.example.com *.example.org]\""}},
+		{"部分被拒绝", ws.SubscribeResult{
+			Accepted: []string{"a.example.com"},
+			Rejected: []ws.RejectedDomain{{Domain: "*", Reason: "不允许订阅"}, {Domain: "bad_domain", Reason: "无效的域名"}},
+		}, []string{
+			"level=WARN msg=服务端拒绝订阅域名",
+			"domain=* reason=不允许订阅",
+			"domain=bad_domain reason=无效的域名",
+			"level=INFO msg=订阅已部分生效",
+			"accepted=[a.example.com] rejected=2",
+		}},
+		{"全部被拒绝", ws.SubscribeResult{Accepted: []string{}, Rejected: []ws.RejectedDomain{{Domain: "*", Reason: "不允许订阅"}}},
+			[]string{"level=ERROR msg=订阅更新被全部拒绝，服务端保留原有订阅"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			old := slog.Default()
+			slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+			t.Cleanup(func() { slog.SetDefault(old) })
+
+			d := NewDaemon(&DaemonConfig{})
+			msg, err := ws.NewMessage(ws.MsgTypeSubscribeResult, &tt.result)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d.handleMessage(msg)
+
+			for _, s := range tt.want {
+				if !strings.Contains(buf.String(), s) {
+					t.Errorf("日志缺少 %q:\n%s", s, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestDaemon_ReconnectUsesUpdatedSubscription(t *testing.T) {
+	server := wstest.NewMockServer(t)
+	d := NewDaemon(&DaemonConfig{
+		ServerURL:         server.WSURL(),
+		Password:          wstest.DefaultPassword,
+		ClientID:          "node-1",
+		WorkDir:           t.TempDir(),
+		Subscribe:         []string{"a.example.com"},
+		ReconnectInterval: 10 * time.Millisecond,
+		HeartbeatInterval: time.Minute,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- d.Run(ctx) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// waitDomains 等待服务端看到的订阅变为 want（want 为 nil 表示客户端已断开）
+	waitDomains := func(want []string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			status := server.Hub.GetClientStatus()
+			if want == nil && len(status) == 0 {
+				return
+			}
+			if want != nil && len(status) == 1 && slices.Equal(status[0].Domains, want) {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("服务端订阅 = %+v, want %v", status, want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitDomains([]string{"a.example.com"})
+
+	// 断线期间更新订阅，重连认证时直接携带新的订阅列表
+	server.SetUnavailable(http.StatusServiceUnavailable)
+	server.Hub.Kick("node-1", "测试断线")
+	waitDomains(nil)
+	d.UpdateConfig("", []string{"a.example.com", "b.example.com"}, nil)
+	server.SetUnavailable(0)
+	waitDomains([]string{"a.example.com", "b.example.com"})
+}
+
+func TestDaemon_ServerURLChangeRedials(t *testing.T) {
+	oldServer, newServer := wstest.NewMockServer(t), wstest.NewMockServer(t)
+	d := NewDaemon(&DaemonConfig{
+		ServerURL:         oldServer.WSURL(),
+		Password:          wstest.DefaultPassword,
+		ClientID:          "node-1",
+		WorkDir:           t.TempDir(),
+		Subscribe:         []string{"a.example.com"},
+		ReconnectInterval: time.Hour, // 地址变更后应立即重连，不等待退避
+		HeartbeatInterval: time.Minute,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- d.Run(ctx) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// waitClients 等待服务端的在线客户端数变为 want
+	waitClients := func(server *wstest.MockServer, want int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for len(server.Hub.GetClientStatus()) != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("在线客户端 %d 个, want %d", len(server.Hub.GetClientStatus()), want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitClients(oldServer, 1)
+
+	d.UpdateConfig(newServer.WSURL(), []string{"a.example.com", "b.example.com"}, nil)
+	waitClients(oldServer, 0)
+	waitClients(newServer, 1)
+
+	if got := newServer.Hub.GetClientStatus()[0].Domains; !slices.Equal(got, []string{"a.example.com", "b.example.com"}) {
+		t.Errorf("新服务端看到的订阅 = %v", got)
+	}
+	if got := d.Status().ServerURL; got != newServer.WSURL() {
+		t.Errorf("状态中的服务端地址 = %q, want %q", got, newServer.WSURL())
+	}
+}
+
+func TestDaemon_DrainConfigUpdatesInOrder(t *testing.T) {
+	d := NewDaemon(&DaemonConfig{Subscribe: []string{"a.example.com"}})
+	d.UpdateConfig("", []string{"b.example.com"}, nil)
+	d.UpdateConfig("", []string{"c.example.com"}, []config.SiteDeployConfig{{Domain: "c.example.com"}})
+
+	// 未连接时不发送订阅请求（d.conn 为 nil 时发送会 panic）
+	d.drainConfigUpdates()
+	if !slices.Equal(d.config.Subscribe, []string{"c.example.com"}) || len(d.config.Sites) != 1 {
+		t.Errorf("应用积压更新后 Subscribe = %v, Sites = %v", d.config.Subscribe, d.config.Sites)
+	}
+	select {
+	case update := <-d.configUpdates:
+		t.Errorf("积压的更新未被全部应用: %+v", update)
+	default:
+	}
+}
+
+func TestDaemon_ApplyConfigUpdateCleansWorkdir(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		workDir := t.TempDir()
+		for _, domain := range []string{"example.com", "old.com"} {
+			os.MkdirAll(filepath.Join(workDir, domain), 0755)
+			os.WriteFile(filepath.Join(workDir, domain, "time.log"), []byte("1700000000"), 0644)
+		}
+
+		d := NewDaemon(&DaemonConfig{WorkDir: workDir, Subscribe: []string{"example.com", "old.com"}, CleanupWorkdir: enabled})
+		d.applyConfigUpdate(&ConfigUpdate{NewSubscribe: []string{"example.com"}}, false)
+
+		if _, err := os.Stat(filepath.Join(workDir, "old.com")); os.IsNotExist(err) != enabled {
+			t.Errorf("cleanup_workdir=%v: old.com 存在 = %v", enabled, err == nil)
+		}
+		if _, err := os.Stat(filepath.Join(workDir, "example.com")); err != nil {
+			t.Errorf("cleanup_workdir=%v: example.com 不应被删除", enabled)
+		}
+	}
+}
+
+func TestDaemon_RecordDeployHistory(t *testing.T) {
+	workDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(workDir, "example.com"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	certPEM, _, err := testutil.GenerateSelfSignedCert("example.com", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDaemon(&DaemonConfig{WorkDir: workDir})
+	d.recordDeployHistory("example.com", certPEM)
+	d.recordDeployHistory("example.com", []byte("not pem")) // 无效证书只记录警告
+
+	records, err := workspace.GetDeployHistory(workDir, "example.com", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("部署记录 %d 条, want 1", len(records))
+	}
+	if records[0].Deployer != workspace.DeployerLocal || records[0].ReloadOK != nil {
+		t.Errorf("记录 = %+v，reload 异步执行时不应记录重载结果", records[0])
+	}
+}
+
+func TestDaemon_HandleCertRevoke(t *testing.T) {
+	workDir, deployDir := t.TempDir(), t.TempDir()
+	for _, domain := range []string{"example.com", "unmanaged.com"} {
+		os.MkdirAll(filepath.Join(workDir, domain), 0755)
+		os.WriteFile(filepath.Join(workDir, domain, "time.log"), []byte("1700000000"), 0644)
+	}
+	certPath := filepath.Join(deployDir, "cert.pem")
+	os.WriteFile(certPath, []byte("cert"), 0644)
+
+	d := NewDaemon(&DaemonConfig{
+		WorkDir: workDir,
+		Sites:   []config.SiteDeployConfig{{Domain: "example.com", CertPath: certPath}},
+	})
+
+	// 没有站点配置且未指定 force 时忽略
+	d.handleCertRevoke(&ws.CertRevokeData{Domain: "unmanaged.com", PurgeDeployed: true})
+	if _, err := os.Stat(filepath.Join(workDir, "unmanaged.com")); err != nil {
+		t.Error("没有站点配置时不应删除工作目录")
+	}
+
+	d.handleCertRevoke(&ws.CertRevokeData{Domain: "example.com", PurgeDeployed: true})
+	if _, err := os.Stat(filepath.Join(workDir, "example.com")); !os.IsNotExist(err) {
+		t.Error("工作目录应被删除")
+	}
+	if _, err := os.Stat(certPath); !os.IsNotExist(err) {
+		t.Error("已部署文件应被删除")
+	}
+}
+
+func TestDaemon_DeployCertFilesBundle(t *testing.T) {
+	caPEM, caKeyPEM, err := testutil.GenerateCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafPEM, _, err := testutil.GenerateSignedCert(string(caPEM), string(caKeyPEM), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srcDir, deployDir := t.TempDir(), t.TempDir()
+	os.WriteFile(filepath.Join(srcDir, "cert.pem"), leafPEM, 0644)
+	os.WriteFile(filepath.Join(srcDir, "fullchain.pem"), append(append([]byte{}, leafPEM...), caPEM...), 0644)
+
+	d := NewDaemon(&DaemonConfig{WorkDir: t.TempDir()})
+	site := &config.SiteDeployConfig{Domain: "example.com", BundlePath: filepath.Join(deployDir, "{domain}.pem")}
+	if _, _, err := d.deployCertFiles("example.com", srcDir, site, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(deployDir, "example.com.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(leafPEM)+string(caPEM) {
+		t.Errorf("证书包内容 = \n%s", data)
+	}
+}
+
+func TestDaemon_HandleCertPushCustomFileNames(t *testing.T) {
+	useAcmeShFileNames(t)
+	certPEM, keyPEM, err := testutil.GenerateSelfSignedCert("example.com", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseDir := t.TempDir()
+	os.MkdirAll(filepath.Join(baseDir, "example.com"), 0755)
+	for name, content := range map[string][]byte{"example.com.cer": certPEM, "example.com.key": keyPEM, "fullchain.cer": certPEM, "time.log": []byte("1700000000")} {
+		os.WriteFile(filepath.Join(baseDir, "example.com", name), content, 0644)
+	}
+	data, err := ws.LoadCertPushData([]string{baseDir}, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.FileNames == nil {
+		t.Fatal("非标准文件名的推送应携带 file_names")
+	}
+
+	workDir, deployDir := t.TempDir(), t.TempDir()
+	site := config.SiteDeployConfig{
+		Domain:        "example.com",
+		CertPath:      filepath.Join(deployDir, "cert.pem"),
+		KeyPath:       filepath.Join(deployDir, "key.pem"),
+		FullchainPath: filepath.Join(deployDir, "fullchain.pem"),
+	}
+	d := NewDaemon(&DaemonConfig{WorkDir: workDir, Sites: []config.SiteDeployConfig{site}, ReloadDebounce: time.Hour})
+	d.handleCertPush(data, false)
+	d.flushReloads()
+
+	// 工作目录与部署文件使用标准文件名
+	for name, want := range map[string][]byte{"cert.pem": certPEM, "key.pem": keyPEM, "fullchain.pem": certPEM, "time.log": []byte("1700000000")} {
+		if got, err := os.ReadFile(filepath.Join(workDir, "example.com", name)); err != nil || string(got) != string(want) {
+			t.Errorf("工作目录 %s = %q, %v", name, got, err)
+		}
+	}
+	for _, name := range []string{"cert.pem", "key.pem", "fullchain.pem"} {
+		if _, err := os.Stat(filepath.Join(deployDir, name)); err != nil {
+			t.Errorf("%s 未部署: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "example.com", "example.com.cer")); !os.IsNotExist(err) {
+		t.Error("工作目录不应保留服务端的实际文件名")
+	}
+}
+
+// dialAckServer 启动记录 cert_ack 的桩服务端并返回已连接的 websocket 连接与收到的确认
+func dialAckServer(t *testing.T) (*websocket.Conn, <-chan ws.CertAck) {
+	t.Helper()
+	acks := make(chan ws.CertAck, 10)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var msg ws.Message
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			var ack ws.CertAck
+			if msg.Type == ws.MsgTypeCertAck && msg.ParseData(&ack) == nil {
+				acks <- ack
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, acks
+}
+
+func TestDaemon_HandleCertPushSkipsReloadWhenUnchanged(t *testing.T) {
+	certPEM, keyPEM, err := testutil.GenerateSelfSignedCert("example.com", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deployDir := t.TempDir()
+	marker := filepath.Join(deployDir, "reloaded")
+	site := config.SiteDeployConfig{
+		Domain:    "example.com",
+		CertPath:  filepath.Join(deployDir, "cert.pem"),
+		KeyPath:   filepath.Join(deployDir, "key.pem"),
+		ReloadCmd: "touch " + marker,
+	}
+	d := NewDaemon(&DaemonConfig{WorkDir: t.TempDir(), Sites: []config.SiteDeployConfig{site}, ReloadDebounce: time.Hour})
+	var acks <-chan ws.CertAck
+	d.conn, acks = dialAckServer(t)
+
+	// push 推送证书，返回是否执行了 reload 与 cert_ack 的消息
+	push := func(cert []byte, force bool) (bool, string) {
+		t.Helper()
+		os.Remove(marker)
+		d.handleCertPush(&ws.CertPushData{Domain: "example.com", Files: map[string][]byte{"cert.pem": cert, "key.pem": keyPEM}}, force)
+		d.flushReloads()
+		var ack ws.CertAck
+		select {
+		case ack = <-acks:
+		case <-time.After(5 * time.Second):
+			t.Fatal("未收到 cert_ack")
+		}
+		if !ack.Success {
+			t.Fatalf("cert_ack 失败: %s", ack.Message)
+		}
+		_, err := os.Stat(marker)
+		return err == nil, ack.Message
+	}
+
+	if reloaded, msg := push(certPEM, false); !reloaded || msg != "" {
+		t.Errorf("首次推送 reloaded = %v, ack = %q, want true, \"\"", reloaded, msg)
+	}
+	if reloaded, msg := push(certPEM, false); reloaded || msg != ws.CertAckUnchanged {
+		t.Errorf("内容未变化时 reloaded = %v, ack = %q, want false, %q", reloaded, msg, ws.CertAckUnchanged)
+	}
+	if reloaded, msg := push(certPEM, true); !reloaded || msg != "" {
+		t.Errorf("admin_push 强制部署时 reloaded = %v, ack = %q, want true, \"\"", reloaded, msg)
+	}
+
+	newCert, _, err := testutil.GenerateSelfSignedCert("example.com", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, msg := push(newCert, false); !reloaded || msg != "" {
+		t.Errorf("证书更新后 reloaded = %v, ack = %q, want true, \"\"", reloaded, msg)
+	}
+}
+
+func TestSubscriptionShrunk(t *testing.T) {
+	tests := []struct {
+		old, new []string
+		want     bool
+	}{
+		{[]string{"a.com"}, []string{"a.com", "b.com"}, false},
+		{[]string{"a.com", "b.com"}, []string{"b.com", "a.com"}, false},
+		{[]string{"a.com", "b.com"}, []string{"a.com"}, true},
+		{[]string{"*"}, []string{"a.com"}, true},
+	}
+
+	for _, tt := range tests {
+		if got := subscriptionShrunk(tt.old, tt.new); got != tt.want {
+			t.Errorf("subscriptionShrunk(%v, %v) = %v, want %v", tt.old, tt.new, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package client
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/cert"
+	"github.com/Catker/acmeDeliver/pkg/config"
+	"github.com/Catker/acmeDeliver/pkg/workspace"
+)
+
+// 部署文件与部署时新建目录的默认权限
+const (
+	DefaultDeployFileMode os.FileMode = 0644
+	DefaultDeployDirMode  os.FileMode = 0755
+	// DefaultCombinedFileMode 合并文件与 PKCS#12 文件含私钥，未设置 key_mode 时使用 0600
+	DefaultCombinedFileMode os.FileMode = 0600
+)
+
+// FileAttrs 部署文件的属主与权限，零值字段使用默认值
+type FileAttrs struct {
+	Owner   string      // 属主（用户名或 UID），为空时不修改
+	Group   string      // 属组（组名或 GID），为空时不修改
+	Mode    os.FileMode // 文件权限，0 表示 DefaultDeployFileMode
+	DirMode os.FileMode // 新建上级目录的权限，0 表示 DefaultDeployDirMode
+	Sync    bool        // rename 前 fsync 文件、rename 后 fsync 所在目录（sync_on_write）
+}
+
+// SiteFileAttrs 返回站点部署文件的属主与权限，key 为 true 时使用 key_mode，否则使用 cert_mode
+func SiteFileAttrs(site *config.SiteDeployConfig, key bool) FileAttrs {
+	certMode, keyMode, dirMode := site.FileModes()
+	attrs := FileAttrs{Owner: site.OwnerName(), Group: site.GroupName(), Mode: certMode, DirMode: dirMode}
+	if key {
+		attrs.Mode = keyMode
+	}
+	return attrs
+}
+
+// SiteCombinedAttrs 返回站点合并文件与 PKCS#12 文件的属主与权限：使用 key_mode，未设置时为 DefaultCombinedFileMode
+func SiteCombinedAttrs(site *config.SiteDeployConfig) FileAttrs {
+	attrs := SiteFileAttrs(site, true)
+	if attrs.Mode == 0 {
+		attrs.Mode = DefaultCombinedFileMode
+	}
+	return attrs
+}
+
+// WriteDeployFile 以临时文件 + rename 原子写入部署文件（attrs.Sync 时 fsync 文件与目录），rename 后设置权限与属主
+// 不存在的上级目录按 DirMode 创建并设置相同的属主；用户或组不存在时返回错误，非 root 运行时跳过属主设置并记录警告
+func WriteDeployFile(path string, content []byte, attrs FileAttrs) error {
+	mode, dirMode := attrs.Mode, attrs.DirMode
+	if mode == 0 {
+		mode = DefaultDeployFileMode
+	}
+	if dirMode == 0 {
+		dirMode = DefaultDeployDirMode
+	}
+
+	if err := mkdirAllWithAttrs(filepath.Dir(path), dirMode, attrs); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+
+	if err := workspace.WriteFileAtomic(path, content, mode, attrs.Sync); err != nil {
+		return err
+	}
+
+	// 显式设置权限，不受 umask 与同名临时文件原有权限的影响
+	if err := os.Chmod(path, mode); err != nil {
+		return fmt.Errorf("设置文件权限失败: %w", err)
+	}
+	if err := ApplyFileOwner(path, attrs.Owner, attrs.Group); err != nil {
+		return fmt.Errorf("设置文件属主失败: %w", err)
+	}
+	return nil
+}
+
+// mkdirAllWithAttrs 创建 dir 及不存在的上级目录，只对新建的目录设置权限与属主
+func mkdirAllWithAttrs(dir string, mode os.FileMode, attrs FileAttrs) error {
+	var missing []string
+	for p := filepath.Clean(dir); ; p = filepath.Dir(p) {
+		if _, err := os.Stat(p); err == nil {
+			break
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		missing = append(missing, p)
+		if filepath.Dir(p) == p {
+			break
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, mode); err != nil {
+		return err
+	}
+	// 从最上层的新建目录开始设置
+	for i := len(missing) - 1; i >= 0; i-- {
+		if err := os.Chmod(missing[i], mode); err != nil {
+			return err
+		}
+		if err := ApplyFileOwner(missing[i], attrs.Owner, attrs.Group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeploySet 一次部署中要写入的全部部署文件
+// Write 先逐个与已有文件比对，有文件变化时才备份已有文件，随后写入全部文件；全部未变化时调用方跳过 reload（强制部署除外）
+type DeploySet struct {
+	Domain string
+	files  []deployFile
+}
+
+// deployFile 待写入的部署文件，same 判断已有文件内容是否未变化
+type deployFile struct {
+	path    string
+	content []byte
+	attrs   FileAttrs
+	same    func(existing []byte) bool
+}
+
+// Add 添加部署文件，按 SHA-256 与已有文件比对
+func (s *DeploySet) Add(path string, content []byte, attrs FileAttrs) {
+	s.files = append(s.files, deployFile{path: path, content: content, attrs: attrs, same: func(existing []byte) bool {
+		return sha256.Sum256(existing) == sha256.Sum256(content)
+	}})
+}
+
+// AddPKCS12 添加 PKCS#12 部署文件：每次编码使用随机盐值，已有文件解码后与证书链、私钥及算法比对
+func (s *DeploySet) AddPKCS12(path string, p12, fullchainPEM, keyPEM []byte, password string, legacy bool, attrs FileAttrs) {
+	s.files = append(s.files, deployFile{path: path, content: p12, attrs: attrs, same: func(existing []byte) bool {
+		return cert.PKCS12Matches(existing, fullchainPEM, keyPEM, password, legacy)
+	}})
+}
+
+// Unchanged 至少有一个部署文件且全部与已有文件内容相同时返回 true，已有文件不存在或读取失败视为已变化
+func (s *DeploySet) Unchanged() bool {
+	for _, f := range s.files {
+		existing, err := os.ReadFile(f.path)
+		if err != nil || !f.same(existing) {
+			return false
+		}
+	}
+	return len(s.files) > 0
+}
+
+// Write 写入全部部署文件，返回写入前是否全部未变化
+// 任一文件内容为空时不写入；有文件变化时先按 retention 备份已有文件（不大于 0 时不备份），备份失败时不写入；全部未变化时不备份，仍重写文件以应用属主与权限
+// 写入失败时同时返回已创建的备份，供调用方重试时沿用
+func (s *DeploySet) Write(retention int, now time.Time) (backup *DeployBackup, unchanged bool, err error) {
+	for _, f := range s.files {
+		if len(f.content) == 0 {
+			return nil, false, fmt.Errorf("%s 的内容为空", f.path)
+		}
+	}
+	unchanged = s.Unchanged()
+	if !unchanged {
+		paths := make([]string, len(s.files))
+		for i, f := range s.files {
+			paths[i] = f.path
+		}
+		if backup, err = BackupDeployedFiles(s.Domain, paths, retention, now); err != nil {
+			return nil, false, fmt.Errorf("备份部署文件失败: %w", err)
+		}
+	}
+	for _, f := range s.files {
+		if err := WriteDeployFile(f.path, f.content, f.attrs); err != nil {
+			return backup, false, fmt.Errorf("写入 %s 失败: %w", f.path, err)
+		}
+	}
+	return backup, unchanged, nil
+}
@@ -0,0 +1,262 @@
+//go:build !windows
+
+package client
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/cert"
+	"github.com/Catker/acmeDeliver/pkg/config"
+	"github.com/Catker/acmeDeliver/pkg/testutil"
+)
+
+func TestWriteDeployFileModes(t *testing.T) {
+	base := t.TempDir()
+	path := filepath.Join(base, "ssl", "example.com", "key.pem")
+
+	if err := WriteDeployFile(path, []byte("key"), FileAttrs{Mode: 0640, DirMode: 0750}); err != nil {
+		t.Fatalf("WriteDeployFile() error = %v", err)
+	}
+	for p, want := range map[string]os.FileMode{
+		path:                       0640,
+		filepath.Join(base, "ssl"): 0750,
+		filepath.Join(base, "ssl", "example.com"): 0750,
+	} {
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s 权限 = %o, want %o", p, got, want)
+		}
+	}
+
+	// 覆盖已有文件时同样设置权限，未配置时使用默认值
+	if err := WriteDeployFile(path, []byte("key2"), FileAttrs{}); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != DefaultDeployFileMode {
+		t.Errorf("默认权限 = %o, want %o", info.Mode().Perm(), DefaultDeployFileMode)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Error("临时文件应已被重命名")
+	}
+}
+
+func TestWriteDeployFileOwner(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("修改属主需要 root")
+	}
+	nobody, err := user.Lookup("nobody")
+	if err != nil {
+		t.Skip("系统中没有 nobody 用户")
+	}
+
+	dir := filepath.Join(t.TempDir(), "haproxy")
+	path := filepath.Join(dir, "fullchain.pem")
+	if err := WriteDeployFile(path, []byte("chain"), FileAttrs{Owner: "nobody"}); err != nil {
+		t.Fatalf("WriteDeployFile() error = %v", err)
+	}
+	for _, p := range []string{path, dir} {
+		info, _ := os.Stat(p)
+		if uid := strconv.Itoa(int(info.Sys().(*syscall.Stat_t).Uid)); uid != nobody.Uid {
+			t.Errorf("%s 属主 UID = %s, want %s", p, uid, nobody.Uid)
+		}
+	}
+}
+
+func TestDeploySetWrite(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "nested", "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+	newSet := func(cert, key string) *DeploySet {
+		set := &DeploySet{Domain: "example.com"}
+		set.Add(certPath, []byte(cert), FileAttrs{})
+		set.Add(keyPath, []byte(key), FileAttrs{Mode: 0600})
+		return set
+	}
+	backups := func() []string {
+		matches, _ := filepath.Glob(filepath.Join(dir, "*", "*.bak.*"))
+		more, _ := filepath.Glob(filepath.Join(dir, "*.bak.*"))
+		return append(matches, more...)
+	}
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// 首次写入：创建上级目录，没有已有文件可备份
+	if _, unchanged, err := newSet("cert-1", "key-1").Write(3, now); err != nil || unchanged {
+		t.Fatalf("首次 Write() unchanged = %v, error = %v", unchanged, err)
+	}
+	if data, _ := os.ReadFile(certPath); string(data) != "cert-1" {
+		t.Errorf("cert.pem = %q, want cert-1", data)
+	}
+
+	// 内容未变化：不备份
+	if backup, unchanged, err := newSet("cert-1", "key-1").Write(3, now.Add(time.Second)); err != nil || !unchanged || !backup.Empty() {
+		t.Errorf("内容未变化时 Write() backup = %v, unchanged = %v, error = %v", backup, unchanged, err)
+	}
+	if got := backups(); len(got) != 0 {
+		t.Errorf("内容未变化时不应备份，备份 = %v", got)
+	}
+
+	// 任一文件变化：备份全部已有文件后写入
+	backup, unchanged, err := newSet("cert-1", "key-2").Write(3, now.Add(2*time.Second))
+	if err != nil || unchanged || backup.Empty() {
+		t.Fatalf("内容变化时 Write() backup = %v, unchanged = %v, error = %v", backup, unchanged, err)
+	}
+	if got := backups(); len(got) != 2 {
+		t.Errorf("内容变化时应备份 2 个文件，备份 = %v", got)
+	}
+	if data, _ := os.ReadFile(keyPath); string(data) != "key-2" {
+		t.Errorf("key.pem = %q, want key-2", data)
+	}
+
+	// 任一文件内容为空时不写入
+	if _, _, err := newSet("cert-3", "").Write(3, now.Add(3*time.Second)); err == nil {
+		t.Error("内容为空时 Write() 应返回错误")
+	}
+	if data, _ := os.ReadFile(certPath); string(data) != "cert-1" {
+		t.Errorf("内容为空时不应写入其他文件，cert.pem = %q", data)
+	}
+}
+
+func TestSiteFileAttrs(t *testing.T) {
+	site := &config.SiteDeployConfig{FileOwner: "nginx", Group: "www-data", FileGroup: "ignored", CertMode: "0644", KeyMode: "0640", DirMode: "0750"}
+
+	certAttrs, keyAttrs := SiteFileAttrs(site, false), SiteFileAttrs(site, true)
+	want := FileAttrs{Owner: "nginx", Group: "www-data", Mode: 0644, DirMode: 0750}
+	if certAttrs != want {
+		t.Errorf("SiteFileAttrs(cert) = %+v, want %+v", certAttrs, want)
+	}
+	want.Mode = 0640
+	if keyAttrs != want {
+		t.Errorf("SiteFileAttrs(key) = %+v, want %+v", keyAttrs, want)
+	}
+}
+
+func TestDaemon_DeployCertFilesModes(t *testing.T) {
+	workDir, deployDir, site := setupStartupDeploy(t)
+	site.KeyMode, site.DirMode = "0600", "0750"
+	d := NewDaemon(&DaemonConfig{WorkDir: workDir})
+
+	if _, _, err := d.deployCertFiles("example.com", filepath.Join(workDir, "example.com"), &site, 0); err != nil {
+		t.Fatalf("deployCertFiles() error = %v", err)
+	}
+	for path, want := range map[string]os.FileMode{
+		filepath.Join(deployDir, "example.com", "cert.pem"): DefaultDeployFileMode,
+		filepath.Join(deployDir, "example.com", "key.pem"):  0600,
+		filepath.Join(deployDir, "example.com"):             0750,
+	} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s 权限 = %o, want %o", path, got, want)
+		}
+	}
+}
+
+func TestDaemon_DeployCertFilesCombined(t *testing.T) {
+	srcDir, deployDir := t.TempDir(), t.TempDir()
+	files := map[string]string{"cert.pem": "CERT\n", "key.pem": "KEY", "fullchain.pem": "CERT\nCHAIN\n"}
+	for name, content := range files {
+		os.WriteFile(filepath.Join(srcDir, name), []byte(content), 0644)
+	}
+	d := NewDaemon(&DaemonConfig{WorkDir: t.TempDir()})
+	site := &config.SiteDeployConfig{Domain: "example.com", CombinedPath: filepath.Join(deployDir, "{domain}.pem")}
+	if _, _, err := d.deployCertFiles("example.com", srcDir, site, 0); err != nil {
+		t.Fatalf("deployCertFiles() error = %v", err)
+	}
+
+	path := filepath.Join(deployDir, "example.com.pem")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "CERT\nCHAIN\nKEY\n"; string(data) != want {
+		t.Errorf("合并文件内容 = %q, want %q", data, want)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != DefaultCombinedFileMode {
+		t.Errorf("合并文件权限 = %o, want %o", info.Mode().Perm(), DefaultCombinedFileMode)
+	}
+	if err := verifySiteDeployment("example.com", srcDir, site); err != nil {
+		t.Errorf("verifySiteDeployment() error = %v", err)
+	}
+
+	// 私钥缺失时返回错误，不写入不完整的合并文件
+	os.Remove(filepath.Join(srcDir, "key.pem"))
+	site.CombinedPath = filepath.Join(deployDir, "partial.pem")
+	if _, _, err := d.deployCertFiles("example.com", srcDir, site, 0); err == nil {
+		t.Error("私钥缺失时 deployCertFiles() 应返回错误")
+	}
+	if _, err := os.Stat(site.CombinedPath); !os.IsNotExist(err) {
+		t.Errorf("私钥缺失时不应写入合并文件, stat error = %v", err)
+	}
+}
+
+func TestDaemon_DeployCertFilesPKCS12(t *testing.T) {
+	caPEM, caKeyPEM, err := testutil.GenerateCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, keyPEM, err := testutil.GenerateSignedCert(string(caPEM), string(caKeyPEM), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srcDir, deployDir := t.TempDir(), t.TempDir()
+	os.WriteFile(filepath.Join(srcDir, "cert.pem"), certPEM, 0644)
+	os.WriteFile(filepath.Join(srcDir, "key.pem"), keyPEM, 0600)
+	os.WriteFile(filepath.Join(srcDir, "fullchain.pem"), append(append([]byte{}, certPEM...), caPEM...), 0644)
+	passFile := filepath.Join(t.TempDir(), "p12.pass")
+	os.WriteFile(passFile, []byte("s3cret\n"), 0600)
+
+	d := NewDaemon(&DaemonConfig{WorkDir: t.TempDir()})
+	site := &config.SiteDeployConfig{
+		Domain:             "example.com",
+		PKCS12Path:         filepath.Join(deployDir, "{domain}.p12"),
+		PKCS12PasswordFile: passFile,
+		PKCS12Legacy:       true,
+		VerifyAfterDeploy:  true,
+	}
+	if _, _, err := d.deployCertFiles("example.com", srcDir, site, 0); err != nil {
+		t.Fatalf("deployCertFiles() error = %v", err)
+	}
+
+	path := filepath.Join(deployDir, "example.com.p12")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, leaf, ca, err := cert.DecodePKCS12(data, "s3cret")
+	if err != nil {
+		t.Fatalf("DecodePKCS12() error = %v", err)
+	}
+	if leaf.Subject.CommonName != "example.com" || len(ca) != 1 {
+		t.Errorf("证书链 = %s + %d 个中间证书", leaf.Subject.CommonName, len(ca))
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != DefaultCombinedFileMode {
+		t.Errorf("PKCS#12 文件权限 = %o, want %o", info.Mode().Perm(), DefaultCombinedFileMode)
+	}
+	if deploymentStale("example.com", srcDir, site) {
+		t.Error("部署后 deploymentStale() 应返回 false")
+	}
+}
+
+func TestDaemon_DeployCertFilesSyncOnWrite(t *testing.T) {
+	workDir, deployDir, site := setupStartupDeploy(t)
+	d := NewDaemon(&DaemonConfig{WorkDir: workDir, SyncOnWrite: true})
+
+	if _, _, err := d.deployCertFiles("example.com", filepath.Join(workDir, "example.com"), &site, 0); err != nil {
+		t.Fatalf("deployCertFiles() error = %v", err)
+	}
+	src, _ := os.ReadFile(filepath.Join(workDir, "example.com", "cert.pem"))
+	if got, err := os.ReadFile(filepath.Join(deployDir, "example.com", "cert.pem")); err != nil || string(got) != string(src) {
+		t.Errorf("部署的 cert.pem = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,87 @@
+package client
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/cert"
+	"github.com/Catker/acmeDeliver/pkg/config"
+)
+
+// ErrDeployVerificationFailed 部署文件与来源内容不一致（如文件系统错误、NFS 写缓存未提交）
+var ErrDeployVerificationFailed = errors.New("部署文件校验失败")
+
+// DeployVerifyDelay 部署后重新读取前的等待时间，留给 NFS 等网络文件系统提交写入
+const DeployVerifyDelay = 500 * time.Millisecond
+
+// deployVerifyDelay daemon 部署后校验前的等待时间，测试中可缩短
+var deployVerifyDelay = DeployVerifyDelay
+
+// VerifyDeployedFiles 等待 delay 后从部署路径重新读取文件，按 SHA-256 与期望内容比对
+// expected 为 部署路径 -> 期望内容；读取失败或内容不一致时返回包装 ErrDeployVerificationFailed 的错误
+func VerifyDeployedFiles(expected map[string][]byte, delay time.Duration) error {
+	if len(expected) == 0 {
+		return nil
+	}
+	time.Sleep(delay)
+
+	paths := make([]string, 0, len(expected))
+	for path := range expected {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("%w: 读取 %s 失败: %v", ErrDeployVerificationFailed, path, err)
+		}
+		if sha256.Sum256(data) != sha256.Sum256(expected[path]) {
+			return fmt.Errorf("%w: %s 内容与来源不一致", ErrDeployVerificationFailed, path)
+		}
+	}
+	return nil
+}
+
+// verifySiteDeployment 校验站点的部署文件与工作目录中的证书一致
+// 工作目录中不存在的来源文件不参与校验
+func verifySiteDeployment(domain, srcDir string, site *config.SiteDeployConfig) error {
+	read := func(name string) []byte {
+		data, _ := os.ReadFile(filepath.Join(srcDir, name))
+		return data
+	}
+
+	expected := make(map[string][]byte)
+	for _, target := range siteDeployTargets(domain, site) {
+		// PKCS#12 每次编码使用随机盐值，无法按内容比对
+		if target.pkcs12 {
+			continue
+		}
+		if target.combined != "" {
+			combined, err := cert.CombinePEM(target.combined, read("cert.pem"), read("key.pem"), read("fullchain.pem"))
+			if err != nil {
+				return fmt.Errorf("%w: 生成合并文件失败: %v", ErrDeployVerificationFailed, err)
+			}
+			expected[target.dst] = combined
+			continue
+		}
+		if len(target.srcs) == 1 {
+			if data := read(target.srcs[0]); data != nil {
+				expected[target.dst] = data
+			}
+			continue
+		}
+		// 证书包由 cert.pem 与 fullchain.pem 生成
+		bundle, err := cert.BundleCerts(read("cert.pem"), read("fullchain.pem"))
+		if err != nil {
+			return fmt.Errorf("%w: 生成证书包失败: %v", ErrDeployVerificationFailed, err)
+		}
+		expected[target.dst] = bundle
+	}
+	return VerifyDeployedFiles(expected, deployVerifyDelay)
+}
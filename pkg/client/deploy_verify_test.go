@@ -0,0 +1,70 @@
+package client
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Catker/acmeDeliver/pkg/config"
+)
+
+// withoutVerifyDelay 测试期间取消部署校验前的等待
+func withoutVerifyDelay(t *testing.T) {
+	t.Helper()
+	old := deployVerifyDelay
+	deployVerifyDelay = 0
+	t.Cleanup(func() { deployVerifyDelay = old })
+}
+
+func TestVerifyDeployedFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cert.pem")
+	os.WriteFile(path, []byte("cert"), 0644)
+
+	tests := []struct {
+		name     string
+		expected map[string][]byte
+		wantErr  bool
+	}{
+		{"一致", map[string][]byte{path: []byte("cert")}, false},
+		{"空列表", nil, false},
+		{"内容不一致", map[string][]byte{path: []byte("new-cert")}, true},
+		{"文件缺失", map[string][]byte{filepath.Join(dir, "missing.pem"): []byte("cert")}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyDeployedFiles(tt.expected, 0)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyDeployedFiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrDeployVerificationFailed) {
+				t.Errorf("VerifyDeployedFiles() error = %v, want ErrDeployVerificationFailed", err)
+			}
+		})
+	}
+}
+
+func TestDaemon_DeployCertFilesVerify(t *testing.T) {
+	withoutVerifyDelay(t)
+	workDir, deployDir, site := setupStartupDeploy(t)
+	srcDir := filepath.Join(workDir, "example.com")
+	site.VerifyAfterDeploy = true
+	d := NewDaemon(&DaemonConfig{WorkDir: workDir})
+
+	if _, _, err := d.deployCertFiles("example.com", srcDir, &site, 0); err != nil {
+		t.Fatalf("deployCertFiles() error = %v", err)
+	}
+
+	// 部署目标无法写入（路径被同名文件占用）时，无论是否开启校验都返回写入错误，重试后仍失败
+	blocked := config.SiteDeployConfig{Domain: "example.com", CertPath: filepath.Join(deployDir, "blocked", "cert.pem"), VerifyAfterDeploy: true}
+	os.WriteFile(filepath.Join(deployDir, "blocked"), []byte("file"), 0644)
+	if _, _, err := d.deployCertFilesWithRetry("example.com", srcDir, &blocked, 2); err == nil || !strings.Contains(err.Error(), blocked.CertPath) {
+		t.Errorf("deployCertFilesWithRetry() error = %v, want 包含 %s 的写入错误", err, blocked.CertPath)
+	}
+	blocked.VerifyAfterDeploy = false
+	if _, _, err := d.deployCertFiles("example.com", srcDir, &blocked, 0); err == nil {
+		t.Error("未开启校验时 deployCertFiles() 也应返回写入错误")
+	}
+}
@@ -0,0 +1,62 @@
+//go:build !windows
+
+package client
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestApplyFileOwner(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cert.pem")
+	if err := os.WriteFile(path, []byte("cert"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	current, err := user.Current()
+	if err != nil {
+		t.Skip(err)
+	}
+	group, err := user.LookupGroupId(current.Gid)
+	if err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		name    string
+		owner   string
+		group   string
+		wantErr bool
+	}{
+		{"未配置", "", "", false},
+		{"当前用户与组", current.Username, group.Name, false},
+		{"只设置属组", "", group.Name, false},
+		{"用户不存在", "acmedeliver-no-such-user", "", true},
+		{"用户组不存在", "", "acmedeliver-no-such-group", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ApplyFileOwner(path, tt.owner, tt.group); (err != nil) != tt.wantErr {
+				t.Errorf("ApplyFileOwner() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// 没有权限时只记录警告，root 运行时实际修改属主
+	if err := ApplyFileOwner(path, "nobody", ""); err != nil {
+		if _, lookupErr := user.Lookup("nobody"); lookupErr != nil {
+			t.Skip("系统中没有 nobody 用户")
+		}
+		t.Fatalf("ApplyFileOwner(nobody) error = %v, want nil", err)
+	}
+	if os.Geteuid() == 0 {
+		nobody, _ := user.Lookup("nobody")
+		info, _ := os.Stat(path)
+		if uid := strconv.Itoa(int(info.Sys().(*syscall.Stat_t).Uid)); uid != nobody.Uid {
+			t.Errorf("属主 UID = %s, want %s", uid, nobody.Uid)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+//go:build !windows
+
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// ApplyFileOwner 将部署文件的属主设置为 owner 与 group（用户名/组名或数字 ID），两者为空时不做修改
+// 用户或组不存在时返回错误；非 root 运行或没有修改属主的权限时记录警告并继续
+func ApplyFileOwner(path, owner, group string) error {
+	if owner == "" && group == "" {
+		return nil
+	}
+	uid, gid := -1, -1
+	if owner != "" {
+		u, err := user.Lookup(owner)
+		if err != nil {
+			return fmt.Errorf("查找用户 %s 失败: %w", owner, err)
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return fmt.Errorf("用户 %s 的 UID 无效: %s", owner, u.Uid)
+		}
+	}
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			return fmt.Errorf("查找用户组 %s 失败: %w", group, err)
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return fmt.Errorf("用户组 %s 的 GID 无效: %s", group, g.Gid)
+		}
+	}
+
+	if os.Geteuid() != 0 {
+		slog.Warn("非 root 运行，跳过设置部署文件属主", "path", path, "owner", owner, "group", group)
+		return nil
+	}
+	if err := os.Lchown(path, uid, gid); err != nil {
+		if errors.Is(err, fs.ErrPermission) {
+			slog.Warn("没有修改部署文件属主的权限，保持当前属主", "path", path, "owner", owner, "group", group, "error", err)
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// preserveOwner 将 info 记录的属主复制到 path，用于备份与回滚时保留原文件属主
+// 没有权限时静默忽略
+func preserveOwner(info os.FileInfo, path string) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		os.Lchown(path, int(st.Uid), int(st.Gid))
+	}
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package client
+
+import (
+	"log/slog"
+	"os"
+)
+
+// ApplyFileOwner Windows 不支持按用户名/组名设置文件属主，配置了 owner 或 group 时记录警告
+func ApplyFileOwner(path, owner, group string) error {
+	if owner != "" || group != "" {
+		slog.Warn("Windows 不支持 owner 与 group，已忽略", "path", path)
+	}
+	return nil
+}
+
+// preserveOwner Windows 上不复制文件属主
+func preserveOwner(info os.FileInfo, path string) {}
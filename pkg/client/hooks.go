@@ -0,0 +1,141 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/command"
+	"github.com/Catker/acmeDeliver/pkg/config"
+)
+
+// 站点部署钩子
+const (
+	HookPreCmd  = "precmd"  // 写入部署文件前执行，失败时取消部署
+	HookPostCmd = "postcmd" // reload 成功后执行，失败只记录日志
+)
+
+// SiteCommand 替换命令中的 {domain} 占位符
+func SiteCommand(cmd, domain string) string {
+	return strings.ReplaceAll(cmd, "{domain}", domain)
+}
+
+// RunHook 执行站点的 precmd 或 postcmd，输出逐行记录到日志
+// timeout 不大于 0 时使用 DefaultReloadTimeout；dryRun 时只记录将执行的命令
+func RunHook(hook, cmd, domain string, timeout time.Duration, dryRun bool) error {
+	if cmd == "" {
+		return nil
+	}
+	cmd = SiteCommand(cmd, domain)
+	if timeout <= 0 {
+		timeout = DefaultReloadTimeout
+	}
+	if dryRun {
+		slog.Info("[DryRun] 将执行部署钩子命令", "hook", hook, "domain", domain, "cmd", cmd)
+		return nil
+	}
+
+	slog.Info("执行部署钩子命令", "hook", hook, "domain", domain, "cmd", cmd)
+	err := command.ExecuteStreaming(context.Background(), cmd, timeout, func(line string) {
+		slog.Info("部署钩子命令输出", "hook", hook, "domain", domain, "line", line)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", hook, err)
+	}
+	return nil
+}
+
+// pendingDeploy 已写入部署文件、等待 reload 结果的域名
+// reload 成功后执行 postcmd，失败时恢复 backup 回滚部署
+type pendingDeploy struct {
+	domain  string
+	postCmd string
+	backup  *DeployBackup
+	ack     bool // 部署由服务端推送触发，回滚时通过 cert_ack 报告
+}
+
+// runPreCmd 执行站点的 precmd，失败时调用方应取消部署
+func (d *Daemon) runPreCmd(domain string, site *config.SiteDeployConfig) error {
+	return RunHook(HookPreCmd, site.PreCmd, domain, d.config.ReloadTimeout, false)
+}
+
+// scheduleReload 部署成功后经防抖触发站点的 reload，并登记 reload 结束后执行的 postcmd 与回滚
+// 站点没有 reload 命令时立即执行 postcmd；同一 reload 周期内同一域名只登记一次，保留最早的备份
+func (d *Daemon) scheduleReload(domain string, site *config.SiteDeployConfig, backup *DeployBackup, ack bool) {
+	if site.ReloadCmd == "" {
+		d.runPostCmd(domain, site.PostCmd)
+		return
+	}
+	if site.PostCmd != "" || !backup.Empty() {
+		d.hookMu.Lock()
+		pending := d.pendingDeploys[site.ReloadCmd]
+		if i := slices.IndexFunc(pending, func(p pendingDeploy) bool { return p.domain == domain }); i >= 0 {
+			pending[i].postCmd = site.PostCmd
+			pending[i].ack = pending[i].ack || ack
+			if pending[i].backup.Empty() {
+				pending[i].backup = backup
+			}
+		} else {
+			d.pendingDeploys[site.ReloadCmd] = append(pending, pendingDeploy{domain: domain, postCmd: site.PostCmd, backup: backup, ack: ack})
+		}
+		d.hookMu.Unlock()
+	}
+	d.reloadDebouncer.Trigger(site.ReloadCmd)
+}
+
+// onReloadResult reload 命令执行结束：记录状态，成功时执行等待该命令的 postcmd，失败时回滚有备份的部署
+func (d *Daemon) onReloadResult(cmd string, err error) {
+	d.state.recordReload(cmd, err)
+
+	d.hookMu.Lock()
+	pending := d.pendingDeploys[cmd]
+	delete(d.pendingDeploys, cmd)
+	d.hookMu.Unlock()
+
+	if err == nil {
+		for _, p := range pending {
+			d.runPostCmd(p.domain, p.postCmd)
+		}
+		return
+	}
+
+	var backups []*DeployBackup
+	for _, p := range pending {
+		if !p.backup.Empty() {
+			backups = append(backups, p.backup)
+		} else if p.postCmd != "" {
+			d.log().Warn("重载命令失败，跳过 postcmd", "domain", p.domain, "reload_cmd", cmd)
+		}
+	}
+	if len(backups) == 0 {
+		return
+	}
+
+	d.log().Warn("重载命令失败，回滚到部署前的证书", "reload_cmd", cmd, "domains", len(backups))
+	rollbackErr := RollbackDeployments(backups, func() error {
+		err := d.reloadDebouncer.run(cmd)
+		d.state.recordReload(cmd, err)
+		return err
+	})
+	deployErr := RollbackError(err, rollbackErr)
+	for _, p := range pending {
+		if p.backup.Empty() {
+			continue
+		}
+		d.log().Error("部署已回滚", "domain", p.domain, "error", deployErr)
+		d.state.recordDeploy(p.domain, deployErr)
+		if p.ack {
+			d.sendCertAck(p.domain, false, deployErr.Error())
+		}
+	}
+}
+
+// runPostCmd 执行 postcmd，失败只记录日志，不回滚部署
+func (d *Daemon) runPostCmd(domain, cmd string) {
+	if err := RunHook(HookPostCmd, cmd, domain, d.config.ReloadTimeout, false); err != nil {
+		d.log().Error("postcmd 执行失败", "domain", domain, "error", err)
+	}
+}
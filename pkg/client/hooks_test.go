@@ -0,0 +1,117 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/command"
+	"github.com/Catker/acmeDeliver/pkg/config"
+)
+
+func TestRunHook(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "{domain}.done")
+
+	tests := []struct {
+		name    string
+		cmd     string
+		dryRun  bool
+		wantErr bool
+	}{
+		{"空命令", "", false, false},
+		{"成功", "/bin/true", false, false},
+		{"非零退出", "/bin/false", false, true},
+		{"演练模式不执行", "/bin/false", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := RunHook(HookPreCmd, tt.cmd, "example.com", time.Second, tt.dryRun)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RunHook() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, ok := command.ExitCode(err); tt.wantErr && !ok {
+				t.Errorf("RunHook() error = %v, want 退出码错误", err)
+			}
+		})
+	}
+
+	// {domain} 占位符替换为实际域名
+	if err := RunHook(HookPostCmd, "touch "+marker, "example.com", time.Second, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "example.com.done")); err != nil {
+		t.Errorf("{domain} 未被替换: %v", err)
+	}
+	if err := RunHook(HookPostCmd, "touch "+marker, "dry.example.com", time.Second, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "dry.example.com.done")); !os.IsNotExist(err) {
+		t.Error("演练模式不应执行命令")
+	}
+}
+
+func TestDaemon_PostCmdAfterReload(t *testing.T) {
+	tests := []struct {
+		name      string
+		reloadCmd string
+		wantPost  bool
+	}{
+		{"reload 成功后执行", "/bin/true", true},
+		{"reload 失败时跳过", "/bin/false", false},
+		{"没有 reload 命令时立即执行", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			site := &config.SiteDeployConfig{
+				Domain:    "example.com",
+				ReloadCmd: tt.reloadCmd,
+				PostCmd:   "touch " + filepath.Join(dir, "{domain}.post"),
+			}
+			d := NewDaemon(&DaemonConfig{ReloadDebounce: time.Hour})
+
+			d.scheduleReload("example.com", site, nil, false)
+			d.scheduleReload("example.com", site, nil, false) // 同一 reload 周期内只执行一次
+			d.flushReloads()
+
+			_, err := os.Stat(filepath.Join(dir, "example.com.post"))
+			if (err == nil) != tt.wantPost {
+				t.Errorf("postcmd 已执行 = %v, want %v", err == nil, tt.wantPost)
+			}
+			if len(d.pendingDeploys) != 0 {
+				t.Errorf("reload 结束后仍有等待 reload 结果的部署: %v", d.pendingDeploys)
+			}
+		})
+	}
+}
+
+func TestDaemon_DeployFromWorkdirPreCmd(t *testing.T) {
+	for _, preCmd := range []string{"/bin/true", "/bin/false"} {
+		workDir, deployDir, site := setupStartupDeploy(t)
+		site.PreCmd = preCmd
+		d := NewDaemon(&DaemonConfig{WorkDir: workDir, Sites: []config.SiteDeployConfig{site}, ReloadDebounce: time.Hour})
+
+		d.deployFromWorkdir()
+		d.flushReloads()
+
+		ok := preCmd == "/bin/true"
+		if _, err := os.Stat(filepath.Join(deployDir, "example.com", "cert.pem")); (err == nil) != ok {
+			t.Errorf("precmd=%s: cert.pem 已部署 = %v", preCmd, err == nil)
+		}
+		if _, err := os.Stat(filepath.Join(deployDir, "reloaded")); (err == nil) != ok {
+			t.Errorf("precmd=%s: reload 已执行 = %v", preCmd, err == nil)
+		}
+		deploy := d.Status().Domains["example.com"].LastDeploy
+		if deploy == nil || deploy.OK != ok {
+			t.Errorf("precmd=%s: last_deploy = %+v", preCmd, deploy)
+		}
+	}
+}
+
+func TestSiteCommand(t *testing.T) {
+	if got := SiteCommand("/opt/check {domain} --name={domain}", "a.example.com"); got != "/opt/check a.example.com --name=a.example.com" {
+		t.Errorf("SiteCommand() = %q", got)
+	}
+}
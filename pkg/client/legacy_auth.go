@@ -0,0 +1,55 @@
+package client
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/Catker/acmeDeliver/pkg/security"
+)
+
+// 客户端默认以 HMAC-SHA256（security.SignatureV2）签名认证；旧版本服务端只接受 sha256(password + timestamp)，
+// 认证被拒绝且响应中不带签名版本时回退到 V1 签名重新认证一次，disable_legacy_auth 禁止回退
+
+// SetDisableLegacyAuth 设置是否禁止回退到旧版签名（对应配置 disable_legacy_auth）
+func (c *WSClient) SetDisableLegacyAuth(disabled bool) {
+	c.disableLegacyAuth = disabled
+}
+
+// SetDisableLegacyAuth 设置是否禁止回退到旧版签名（对应配置 disable_legacy_auth）
+func (c *APIClient) SetDisableLegacyAuth(disabled bool) {
+	c.disableLegacyAuth = disabled
+}
+
+// legacyAuthFallback 判断以 version 签名的认证被拒绝后能否回退到 V1 签名重试
+// serverVersion 为服务端返回的最高签名版本：新版本服务端拒绝 HMAC 签名说明密码错误或时间戳过期，不回退
+func legacyAuthFallback(logger *slog.Logger, version, serverVersion int, disabled bool) bool {
+	if version < security.SignatureV2 || serverVersion != 0 || disabled {
+		return false
+	}
+	logger.Warn("服务端不支持 HMAC 签名（旧版本服务端），回退到已弃用的 sha256(password + timestamp) 签名重新认证，请升级服务端")
+	return true
+}
+
+// legacyAuthError 禁止回退时旧版本服务端拒绝 HMAC 签名的认证错误附加说明，其余错误原样返回
+func legacyAuthError(err error, version, serverVersion int, disabled bool) error {
+	if version >= security.SignatureV2 && serverVersion == 0 && disabled {
+		return fmt.Errorf("%w（服务端不支持 HMAC 签名，已设置 disable_legacy_auth 不回退到旧版签名）", err)
+	}
+	return err
+}
+
+// retryLegacyAuth 旧版本服务端拒绝 HMAC 签名的认证时回退到旧版签名重新认证，返回是否已重新发送认证请求
+func (d *Daemon) retryLegacyAuth(serverVersion int) bool {
+	d.mu.Lock()
+	if !legacyAuthFallback(d.log(), d.authVersion, serverVersion, d.config.DisableLegacyAuth) {
+		d.mu.Unlock()
+		return false
+	}
+	d.authVersion = security.SignatureV1
+	d.mu.Unlock()
+
+	if err := d.authenticate(); err != nil {
+		d.log().Error("以旧版签名重新认证失败", "error", err)
+	}
+	return true
+}
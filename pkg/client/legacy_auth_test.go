@@ -0,0 +1,173 @@
+package client
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/Catker/acmeDeliver/pkg/security"
+	ws "github.com/Catker/acmeDeliver/pkg/websocket"
+)
+
+// newLegacyWSServer 启动模拟旧版本服务端的桩服务端：只接受 sha256(password + timestamp) 签名，
+// 认证响应不携带 signature_version，同步请求直接返回空结果；返回服务端与收到的认证请求的签名版本
+func newLegacyWSServer(t *testing.T, password string) (*httptest.Server, func() []int) {
+	t.Helper()
+	var mu sync.Mutex
+	var versions []int
+	verifier := security.NewSignatureVerifier(password)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var msg ws.Message
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			if msg.Type == ws.MsgTypeSyncRequest {
+				reply, _ := ws.NewMessage(ws.MsgTypeSyncResult, &ws.SyncResult{})
+				conn.WriteJSON(reply)
+				continue
+			}
+			if msg.Type != ws.MsgTypeAuth {
+				continue
+			}
+			var req ws.AuthRequest
+			msg.ParseData(&req)
+			mu.Lock()
+			versions = append(versions, req.SignatureVersion)
+			mu.Unlock()
+
+			ok, errMsg := verifier.VerifySignature(req.Signature, msg.Timestamp)
+			reply, _ := ws.NewMessage(ws.MsgTypeAuthResult, &ws.AuthResponse{Success: ok, Message: errMsg, ServerTime: time.Now().Unix()})
+			reply.RequestID = msg.RequestID
+			conn.WriteJSON(reply)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []int {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]int(nil), versions...)
+	}
+}
+
+func TestWSClient_LegacyAuthFallback(t *testing.T) {
+	for _, disabled := range []bool{false, true} {
+		server, versions := newLegacyWSServer(t, "test-password")
+		client := NewWSClient(server.URL, "test-password", nil)
+		client.SetDisableLegacyAuth(disabled)
+
+		err := client.Connect(context.Background())
+		client.Close()
+		got := versions()
+		if !disabled {
+			if err != nil {
+				t.Fatalf("旧版本服务端 Connect() error = %v, want 回退到旧版签名后认证成功", err)
+			}
+			if len(got) != 2 || got[0] != security.SignatureV2 || got[1] != security.SignatureV1 {
+				t.Errorf("认证请求的签名版本 = %v, want [2 1]", got)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), "disable_legacy_auth") {
+			t.Errorf("禁止回退时 Connect() error = %v, want 包含 disable_legacy_auth", err)
+		}
+		if len(got) != 1 {
+			t.Errorf("禁止回退时发送了 %d 次认证请求, want 1", len(got))
+		}
+	}
+}
+
+func TestLegacyAuthFallback(t *testing.T) {
+	tests := []struct {
+		name          string
+		version       int
+		serverVersion int
+		disabled      bool
+		want          bool
+	}{
+		{"旧版本服务端拒绝 HMAC 签名", security.SignatureV2, 0, false, true},
+		{"禁止回退", security.SignatureV2, 0, true, false},
+		{"新版本服务端拒绝（密码错误）", security.SignatureV2, security.SignatureV2, false, false},
+		{"已回退到旧版签名", security.SignatureV1, 0, false, false},
+		{"未收到响应", security.SignatureV2, -1, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := legacyAuthFallback(slog.Default(), tt.version, tt.serverVersion, tt.disabled); got != tt.want {
+				t.Errorf("legacyAuthFallback() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDaemon_ResyncLegacyAuthFallback(t *testing.T) {
+	server, versions := newLegacyWSServer(t, "test-password")
+	d := NewDaemon(&DaemonConfig{
+		ServerURL: server.URL,
+		Password:  "test-password",
+		ClientID:  "node-1",
+		WorkDir:   t.TempDir(),
+	})
+	if _, err := d.Resync(context.Background(), 5*time.Second); err != nil {
+		t.Fatalf("Resync() error = %v", err)
+	}
+	if got := versions(); len(got) != 2 || got[0] != security.SignatureV2 || got[1] != security.SignatureV1 {
+		t.Errorf("认证请求的签名版本 = %v, want [2 1]", got)
+	}
+}
+
+func TestAPIClient_LegacyAuthFallback(t *testing.T) {
+	verifier := security.NewSignatureVerifier("test-password")
+	var mu sync.Mutex
+	var versions []string
+	// 旧版本服务端：只接受 sha256(password + timestamp)，响应不携带 X-Acme-Signature-Version
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		versions = append(versions, r.Header.Get(headerSignatureVersion))
+		mu.Unlock()
+		timestamp, _ := strconv.ParseInt(r.Header.Get(headerTimestamp), 10, 64)
+		if ok, errMsg := verifier.VerifySignature(r.Header.Get(headerSignature), timestamp); !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"` + errMsg + `"}`))
+			return
+		}
+		w.Write([]byte(`{"domain":"example.com","sent":1}`))
+	}))
+	defer server.Close()
+
+	for _, disabled := range []bool{false, true} {
+		versions = nil
+		api := NewAPIClient(server.URL, "test-password", nil)
+		api.SetDisableLegacyAuth(disabled)
+		result, err := api.PushDomain(context.Background(), "example.com", "node-1")
+		if !disabled {
+			if err != nil || result.Sent != 1 {
+				t.Fatalf("旧版本服务端 PushDomain() = %+v, %v", result, err)
+			}
+			if len(versions) != 2 || versions[0] != "2" || versions[1] != "1" {
+				t.Errorf("请求的签名版本 = %v, want [2 1]", versions)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), "disable_legacy_auth") {
+			t.Errorf("禁止回退时 PushDomain() error = %v, want 包含 disable_legacy_auth", err)
+		}
+		if len(versions) != 1 {
+			t.Errorf("禁止回退时发送了 %d 次请求, want 1", len(versions))
+		}
+	}
+}
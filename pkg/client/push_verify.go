@@ -0,0 +1,122 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/cert"
+	"github.com/Catker/acmeDeliver/pkg/config"
+)
+
+// ErrPushVerificationFailed 推送的证书未通过校验（私钥不匹配、域名不符、已过期或比已部署证书更早过期）
+var ErrPushVerificationFailed = errors.New("推送证书校验失败")
+
+// VerifyPushedCert 校验推送的证书文件（以标准文件名为键）：
+// 叶子证书取自 cert.pem（缺失时取 fullchain.pem 的第一个证书），需与 key.pem 匹配、覆盖 domain、尚未过期，
+// 且过期时间不早于 deployedNotAfter（零值表示没有已部署的证书）
+func VerifyPushedCert(domain string, files map[string][]byte, deployedNotAfter, now time.Time) error {
+	certPEM, fullchainPEM, keyPEM := files[cert.CertFileName], files[cert.FullchainFileName], files[cert.KeyFileName]
+	if len(certPEM) == 0 {
+		certPEM = fullchainPEM
+	}
+	if len(certPEM) == 0 {
+		return fmt.Errorf("%w: 缺少 cert.pem 与 fullchain.pem", ErrPushVerificationFailed)
+	}
+	leaf, err := cert.ParseCertificate(certPEM)
+	if err != nil {
+		return fmt.Errorf("%w: 解析证书失败: %v", ErrPushVerificationFailed, err)
+	}
+	if len(fullchainPEM) > 0 {
+		first, err := cert.ParseCertificate(fullchainPEM)
+		if err != nil {
+			return fmt.Errorf("%w: 解析 fullchain.pem 失败: %v", ErrPushVerificationFailed, err)
+		}
+		if !bytes.Equal(first.Raw, leaf.Raw) {
+			return fmt.Errorf("%w: fullchain.pem 的第一个证书与 cert.pem 不一致", ErrPushVerificationFailed)
+		}
+	}
+
+	if len(keyPEM) == 0 {
+		return fmt.Errorf("%w: 缺少 key.pem", ErrPushVerificationFailed)
+	}
+	if err := cert.VerifyKeyPair(certPEM, keyPEM); err != nil {
+		return fmt.Errorf("%w: %v", ErrPushVerificationFailed, err)
+	}
+	if !cert.CoversDomain(leaf, domain) {
+		return fmt.Errorf("%w: 证书（%s）不包含域名 %s", ErrPushVerificationFailed, certNames(leaf.DNSNames, leaf.Subject.CommonName), domain)
+	}
+	if !now.Before(leaf.NotAfter) {
+		return fmt.Errorf("%w: 证书已于 %s 过期", ErrPushVerificationFailed, leaf.NotAfter.Format(time.RFC3339))
+	}
+	if leaf.NotAfter.Before(deployedNotAfter) {
+		return fmt.Errorf("%w: 证书过期时间 %s 早于已部署证书的 %s，拒绝降级",
+			ErrPushVerificationFailed, leaf.NotAfter.Format(time.RFC3339), deployedNotAfter.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// verifyPush 按 verify_push 校验推送的证书：strict 模式下返回校验错误，warn 模式下只记录警告
+func (d *Daemon) verifyPush(domain string, files map[string][]byte, site *config.SiteDeployConfig) error {
+	mode := d.config.VerifyPush
+	if mode != config.VerifyPushStrict && mode != config.VerifyPushWarn {
+		return nil
+	}
+	err := VerifyPushedCert(domain, files, deployedNotAfter(domain, site), time.Now())
+	if err == nil {
+		return nil
+	}
+	if mode == config.VerifyPushWarn {
+		d.log().Warn("推送的证书未通过校验，verify_push 为 warn，继续部署", "domain", domain, "error", err)
+		return nil
+	}
+	d.log().Error("推送的证书未通过校验，跳过部署，文件保留在工作目录中", "domain", domain, "error", err)
+	return err
+}
+
+// VerifySiteCT 站点启用 verify_ct 时校验证书内嵌的 SCT 由 ct_log_urls 中的 CT 日志签发，未通过时返回错误，调用方应取消部署
+// 使用 fullchain（没有时使用 cert）以取得计算 SCT 签名所需的颁发者证书
+func VerifySiteCT(site *config.SiteDeployConfig, certPEM, fullchainPEM []byte) error {
+	if site == nil || !site.VerifyCT {
+		return nil
+	}
+	chain := fullchainPEM
+	if len(chain) == 0 {
+		chain = certPEM
+	}
+	if err := cert.VerifyCTLog(chain, site.CTLogURLs); err != nil {
+		return fmt.Errorf("证书未通过 CT 日志校验，取消部署: %w", err)
+	}
+	return nil
+}
+
+// certNames 返回证书名称的描述，用于错误信息
+func certNames(dnsNames []string, commonName string) string {
+	if len(dnsNames) == 0 {
+		return "CN=" + commonName
+	}
+	return fmt.Sprintf("SAN=%v", dnsNames)
+}
+
+// deployedNotAfter 返回站点当前已部署证书的过期时间，依次读取 cert_path、fullchain_path、bundle_path（不读取私钥、合并文件与 PKCS#12 文件）
+// 没有站点配置或部署文件不存在、无法解析时返回零值
+func deployedNotAfter(domain string, site *config.SiteDeployConfig) time.Time {
+	if site == nil {
+		return time.Time{}
+	}
+	for _, target := range siteDeployTargets(domain, site) {
+		if target.srcs[0] == cert.KeyFileName || target.combined != "" || target.pkcs12 {
+			continue
+		}
+		data, err := os.ReadFile(target.dst)
+		if err != nil {
+			continue
+		}
+		if notAfter, err := cert.ParseCertificateExpiry(data); err == nil {
+			return notAfter
+		}
+	}
+	return time.Time{}
+}
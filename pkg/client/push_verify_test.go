@@ -0,0 +1,142 @@
+package client
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/cert"
+	"github.com/Catker/acmeDeliver/pkg/config"
+	"github.com/Catker/acmeDeliver/pkg/testutil"
+	ws "github.com/Catker/acmeDeliver/pkg/websocket"
+)
+
+// generatePushFiles 生成 domain 的证书文件（以标准文件名为键）
+func generatePushFiles(t *testing.T, domain string, validity time.Duration) map[string][]byte {
+	t.Helper()
+	certPEM, keyPEM, err := testutil.GenerateSelfSignedCert(domain, validity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string][]byte{cert.CertFileName: certPEM, cert.KeyFileName: keyPEM, cert.FullchainFileName: certPEM}
+}
+
+func TestVerifyPushedCert(t *testing.T) {
+	now := time.Now()
+	valid := generatePushFiles(t, "example.com", 90*24*time.Hour)
+	other := generatePushFiles(t, "example.com", 90*24*time.Hour)
+	wildcard := generatePushFiles(t, "*.example.com", 90*24*time.Hour)
+	expired := generatePushFiles(t, "example.com", 0)
+
+	mismatchedKey := map[string][]byte{cert.CertFileName: valid[cert.CertFileName], cert.KeyFileName: other[cert.KeyFileName]}
+	mismatchedChain := map[string][]byte{cert.CertFileName: valid[cert.CertFileName], cert.KeyFileName: valid[cert.KeyFileName], cert.FullchainFileName: other[cert.FullchainFileName]}
+	fullchainOnly := map[string][]byte{cert.KeyFileName: valid[cert.KeyFileName], cert.FullchainFileName: valid[cert.FullchainFileName]}
+
+	tests := []struct {
+		name     string
+		domain   string
+		files    map[string][]byte
+		deployed time.Time
+		wantErr  bool
+	}{
+		{"匹配", "example.com", valid, time.Time{}, false},
+		{"只有 fullchain.pem", "example.com", fullchainOnly, time.Time{}, false},
+		{"通配符证书", "www.example.com", wildcard, time.Time{}, false},
+		{"通配符证书不覆盖根域名", "example.com", wildcard, time.Time{}, true},
+		{"域名不符", "other.com", valid, time.Time{}, true},
+		{"私钥不匹配", "example.com", mismatchedKey, time.Time{}, true},
+		{"fullchain.pem 与 cert.pem 不一致", "example.com", mismatchedChain, time.Time{}, true},
+		{"缺少私钥", "example.com", map[string][]byte{cert.CertFileName: valid[cert.CertFileName]}, time.Time{}, true},
+		{"缺少证书", "example.com", map[string][]byte{cert.KeyFileName: valid[cert.KeyFileName]}, time.Time{}, true},
+		{"已过期", "example.com", expired, time.Time{}, true},
+		{"比已部署证书晚过期", "example.com", valid, now.Add(30 * 24 * time.Hour), false},
+		{"降级", "example.com", valid, now.Add(365 * 24 * time.Hour), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyPushedCert(tt.domain, tt.files, tt.deployed, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyPushedCert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrPushVerificationFailed) {
+				t.Errorf("VerifyPushedCert() error = %v, want ErrPushVerificationFailed", err)
+			}
+		})
+	}
+}
+
+func TestDaemon_HandleCertPushVerify(t *testing.T) {
+	files := generatePushFiles(t, "example.com", 90*24*time.Hour)
+	other := generatePushFiles(t, "example.com", 90*24*time.Hour)
+	pushed := map[string][]byte{cert.CertFileName: files[cert.CertFileName], cert.KeyFileName: other[cert.KeyFileName]}
+
+	tests := []struct {
+		mode       string
+		wantDeploy bool
+	}{
+		{config.VerifyPushStrict, false},
+		{config.VerifyPushWarn, true},
+		{config.VerifyPushOff, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			workDir, deployDir := t.TempDir(), t.TempDir()
+			site := config.SiteDeployConfig{Domain: "example.com", CertPath: filepath.Join(deployDir, "cert.pem")}
+			d := NewDaemon(&DaemonConfig{WorkDir: workDir, Sites: []config.SiteDeployConfig{site}, ReloadDebounce: time.Hour, VerifyPush: tt.mode})
+			d.handleCertPush(&ws.CertPushData{Domain: "example.com", Files: pushed}, false)
+			d.flushReloads()
+
+			// 校验失败时文件仍保存在工作目录中
+			if _, err := os.Stat(filepath.Join(workDir, "example.com", cert.KeyFileName)); err != nil {
+				t.Errorf("工作目录缺少 key.pem: %v", err)
+			}
+			_, err := os.Stat(site.CertPath)
+			if deployed := err == nil; deployed != tt.wantDeploy {
+				t.Errorf("deployed = %v, want %v", deployed, tt.wantDeploy)
+			}
+		})
+	}
+}
+
+func TestDeployedNotAfter(t *testing.T) {
+	deployDir := t.TempDir()
+	files := generatePushFiles(t, "example.com", 90*24*time.Hour)
+	site := &config.SiteDeployConfig{
+		Domain:   "example.com",
+		KeyPath:  filepath.Join(deployDir, "key.pem"),
+		CertPath: filepath.Join(deployDir, "cert.pem"),
+	}
+
+	if got := deployedNotAfter("example.com", site); !got.IsZero() {
+		t.Errorf("未部署时 deployedNotAfter() = %v, want 零值", got)
+	}
+	if got := deployedNotAfter("example.com", nil); !got.IsZero() {
+		t.Errorf("没有站点配置时 deployedNotAfter() = %v, want 零值", got)
+	}
+
+	os.WriteFile(site.KeyPath, files[cert.KeyFileName], 0600)
+	os.WriteFile(site.CertPath, files[cert.CertFileName], 0644)
+	leaf, err := cert.ParseCertificate(files[cert.CertFileName])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := deployedNotAfter("example.com", site); !got.Equal(leaf.NotAfter) {
+		t.Errorf("deployedNotAfter() = %v, want %v", got, leaf.NotAfter)
+	}
+}
+
+func TestVerifySiteCT(t *testing.T) {
+	files := generatePushFiles(t, "example.com", 90*24*time.Hour)
+
+	if err := VerifySiteCT(&config.SiteDeployConfig{Domain: "example.com"}, files[cert.CertFileName], files[cert.FullchainFileName]); err != nil {
+		t.Errorf("未启用 verify_ct 时不应校验: %v", err)
+	}
+
+	site := &config.SiteDeployConfig{Domain: "example.com", VerifyCT: true, CTLogURLs: []string{filepath.Join(t.TempDir(), "unused.json")}}
+	err := VerifySiteCT(site, files[cert.CertFileName], files[cert.FullchainFileName])
+	if !errors.Is(err, cert.ErrNoSCT) {
+		t.Errorf("没有 SCT 的证书应返回 ErrNoSCT, got %v", err)
+	}
+}
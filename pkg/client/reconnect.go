@@ -0,0 +1,72 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	ws "github.com/Catker/acmeDeliver/pkg/websocket"
+)
+
+// DefaultMaxReconnectAttempts 未设置 max_reconnect_attempts 时连续自动重连的次数上限
+const DefaultMaxReconnectAttempts = 3
+
+// SetAutoReconnect 设置发送请求时连接已断开是否自动重连（对应配置 auto_reconnect 与 max_reconnect_attempts）
+// 启用后连接断开时重新连接、认证并重发一次请求；收到响应前连续自动重连超过 maxAttempts 次时返回错误，
+// maxAttempts 不大于 0 时使用 DefaultMaxReconnectAttempts
+func (c *WSClient) SetAutoReconnect(enabled bool, maxAttempts int) {
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxReconnectAttempts
+	}
+	c.autoReconnect = enabled
+	c.maxReconnectAttempts = maxAttempts
+}
+
+// ReconnectAttempts 返回自上次收到响应以来的自动重连次数
+func (c *WSClient) ReconnectAttempts() int {
+	return int(c.reconnectAttempts.Load())
+}
+
+// Reconnect 关闭当前连接并重新执行 Connect（连接并认证，按 SetRetries 设置重试）
+// 等待中的响应通道按请求 ID 保存在客户端上，重连后继续有效
+func (c *WSClient) Reconnect(ctx context.Context) error {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	return c.reconnectLocked(ctx)
+}
+
+// reconnectLocked 重新连接，调用方需持有 connMu
+func (c *WSClient) reconnectLocked(ctx context.Context) error {
+	c.authenticated.Store(false)
+	c.Close()
+	return c.Connect(ctx)
+}
+
+// canAutoReconnect 判断 msg 发送失败或等待响应时断线后能否自动重连并重发
+// 认证消息在 Connect 内发送，不能再触发重连
+func (c *WSClient) canAutoReconnect(msg *ws.Message) bool {
+	return c.autoReconnect && msg.Type != ws.MsgTypeAuth
+}
+
+// reconnectAfter 因 cause 断线后自动重连，stale 为断开的连接的 done
+// 其他请求已完成重连（当前连接不再是 stale）时直接返回；连续重连超过上限时返回包装 cause 的错误
+func (c *WSClient) reconnectAfter(ctx context.Context, stale chan struct{}, cause error) error {
+	if n := int(c.reconnectAttempts.Add(1)); n > c.maxReconnectAttempts {
+		return fmt.Errorf("%w（已连续自动重连 %d 次，达到 max_reconnect_attempts）", cause, n-1)
+	}
+
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	c.mu.Lock()
+	current := c.done
+	c.mu.Unlock()
+	if current != stale {
+		return nil
+	}
+
+	slog.Info("与服务器的连接已断开，自动重连后重发请求", "attempt", c.reconnectAttempts.Load(), "max_attempts", c.maxReconnectAttempts, "error", cause)
+	if err := c.reconnectLocked(ctx); err != nil {
+		return fmt.Errorf("自动重连失败: %w", err)
+	}
+	return nil
+}
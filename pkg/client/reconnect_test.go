@@ -0,0 +1,155 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/Catker/acmeDeliver/pkg/testutil/wstest"
+	ws "github.com/Catker/acmeDeliver/pkg/websocket"
+)
+
+// dropClientConn 关闭客户端当前连接并等待读取循环退出，模拟两次请求之间连接断开
+func dropClientConn(c *WSClient) {
+	c.mu.Lock()
+	done := c.done
+	c.conn.Close()
+	c.mu.Unlock()
+	<-done
+}
+
+// newDroppingWSServer 启动桩服务端：认证立即成功，前 drops 个证书请求不响应并直接断开连接，之后正常响应
+// 返回服务端与收到的连接数
+func newDroppingWSServer(t *testing.T, drops int32) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var remaining, conns atomic.Int32
+	remaining.Store(drops)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conns.Add(1)
+
+		reply := func(requestID, msgType string, data interface{}) {
+			msg, _ := ws.NewMessage(msgType, data)
+			msg.RequestID = requestID
+			conn.WriteJSON(msg)
+		}
+		for {
+			var msg ws.Message
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			switch msg.Type {
+			case ws.MsgTypeAuth:
+				reply(msg.RequestID, ws.MsgTypeAuthResult, &ws.AuthResponse{Success: true})
+			case ws.MsgTypeCertRequest:
+				if remaining.Add(-1) >= 0 {
+					return // 请求处理中断开连接
+				}
+				reply(msg.RequestID, ws.MsgTypeCertResponse, &ws.CertResponse{Domain: "example.com", Files: map[string][]byte{"cert.pem": []byte("cert")}})
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &conns
+}
+
+func TestWSClient_AutoReconnectOnSendFailure(t *testing.T) {
+	for _, autoReconnect := range []bool{false, true} {
+		server := newTestWSServer(t, "a.example.com")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		client := NewWSClient(server.URL, wstest.DefaultPassword, nil)
+		client.SetAutoReconnect(autoReconnect, 0)
+		if err := client.Connect(ctx); err != nil {
+			t.Fatalf("Connect() error = %v", err)
+		}
+		dropClientConn(client)
+
+		// ListDomains 不经过 --retries 的重试，只有自动重连能恢复
+		domains, err := client.ListDomains(ctx)
+		if !autoReconnect {
+			if !errors.Is(err, errConnectionClosed) {
+				t.Errorf("未启用自动重连时 ListDomains() error = %v, want errConnectionClosed", err)
+			}
+		} else {
+			if err != nil || len(domains) != 1 {
+				t.Fatalf("自动重连后 ListDomains() = %+v, error = %v", domains, err)
+			}
+			if got := server.Attempts(); got != 2 {
+				t.Errorf("服务端收到 %d 次连接, want 2", got)
+			}
+			if got := client.ReconnectAttempts(); got != 0 {
+				t.Errorf("收到响应后 ReconnectAttempts() = %d, want 0", got)
+			}
+		}
+		client.Close()
+		cancel()
+	}
+}
+
+func TestWSClient_AutoReconnectMidRequest(t *testing.T) {
+	server, conns := newDroppingWSServer(t, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client := NewWSClient(server.URL, "test-password", nil)
+	client.SetAutoReconnect(true, 0)
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer client.Close()
+
+	// 服务端收到请求后断开，客户端重连并以同一请求 ID 重发
+	certs, err := client.DownloadCert(ctx, "example.com", false)
+	if err != nil {
+		t.Fatalf("DownloadCert() error = %v", err)
+	}
+	if string(certs.Cert) != "cert" {
+		t.Errorf("Cert = %q", certs.Cert)
+	}
+	if got := conns.Load(); got != 2 {
+		t.Errorf("服务端收到 %d 次连接, want 2", got)
+	}
+}
+
+func TestWSClient_AutoReconnectLimit(t *testing.T) {
+	server, conns := newDroppingWSServer(t, 100)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client := NewWSClient(server.URL, "test-password", nil)
+	client.SetAutoReconnect(true, 1)
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer client.Close()
+
+	// 每个请求最多重发一次
+	if _, err := client.DownloadCert(ctx, "example.com", false); !errors.Is(err, errConnectionClosed) {
+		t.Fatalf("重发后仍断开时 DownloadCert() error = %v, want errConnectionClosed", err)
+	}
+	if got := client.ReconnectAttempts(); got != 1 {
+		t.Errorf("ReconnectAttempts() = %d, want 1", got)
+	}
+
+	// 第二个请求先由 DownloadCert 重新连接，等待响应时再次断开，自动重连超过上限
+	_, err := client.DownloadCert(ctx, "example.com", false)
+	if err == nil || !strings.Contains(err.Error(), "max_reconnect_attempts") {
+		t.Errorf("超过 max_reconnect_attempts 时 DownloadCert() error = %v", err)
+	}
+	if got := conns.Load(); got != 3 {
+		t.Errorf("服务端收到 %d 次连接, want 3", got)
+	}
+}
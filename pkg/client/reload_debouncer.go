@@ -10,20 +10,32 @@ import (
 	"github.com/Catker/acmeDeliver/pkg/command"
 )
 
+// DefaultReloadTimeout 重载命令的默认执行超时
+const DefaultReloadTimeout = 15 * time.Second
+
 // ReloadDebouncer 实现 reload 命令的防抖功能
 // 用于 Daemon 模式，避免短时间内多个证书更新时重复执行 reload
 type ReloadDebouncer struct {
 	mu          sync.Mutex
 	timer       *time.Timer
 	delay       time.Duration
+	timeout     time.Duration       // 单个 reload 命令的执行超时
 	pendingCmds map[string]struct{} // 待执行的 reload 命令（去重）
 	executing   bool
+	idle        chan struct{} // 本轮执行结束时关闭
+
+	onResult func(cmd string, err error) // 每条命令执行结束后回调（可选）
 }
 
 // NewReloadDebouncer 创建新的防抖器
-func NewReloadDebouncer(delay time.Duration) *ReloadDebouncer {
+// timeout 为单个 reload 命令的执行超时，不大于 0 时使用 DefaultReloadTimeout
+func NewReloadDebouncer(delay, timeout time.Duration) *ReloadDebouncer {
+	if timeout <= 0 {
+		timeout = DefaultReloadTimeout
+	}
 	return &ReloadDebouncer{
 		delay:       delay,
+		timeout:     timeout,
 		pendingCmds: make(map[string]struct{}),
 	}
 }
@@ -62,6 +74,7 @@ func (r *ReloadDebouncer) execute() {
 		return
 	}
 	r.executing = true
+	r.idle = make(chan struct{})
 
 	// 复制待执行命令并清空队列
 	cmds := make([]string, 0, len(r.pendingCmds))
@@ -79,15 +92,77 @@ func (r *ReloadDebouncer) execute() {
 
 	r.mu.Lock()
 	r.executing = false
+	close(r.idle)
 	r.mu.Unlock()
 }
 
-// executeCmd 执行单个 reload 命令
+// Wait 等待正在执行的 reload 命令结束，超过 timeout 仍未结束时返回 false
+// 尚未到期的防抖队列不会被执行
+func (r *ReloadDebouncer) Wait(timeout time.Duration) bool {
+	r.mu.Lock()
+	if !r.executing {
+		r.mu.Unlock()
+		return true
+	}
+	idle := r.idle
+	r.mu.Unlock()
+
+	select {
+	case <-idle:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Flush 立即执行防抖队列中尚未到期的 reload 命令，并等待执行结束
+// 计时器触发的执行正在进行时先等待其结束，再执行期间新加入的命令；队列为空时立即返回，可重复调用
+func (r *ReloadDebouncer) Flush() {
+	for {
+		r.mu.Lock()
+		if r.timer != nil {
+			r.timer.Stop()
+			r.timer = nil
+		}
+		if r.executing {
+			idle := r.idle
+			r.mu.Unlock()
+			<-idle
+			continue
+		}
+		if len(r.pendingCmds) == 0 {
+			r.mu.Unlock()
+			return
+		}
+		r.mu.Unlock()
+
+		// 与并发的 Trigger 计时器竞争时 execute 可能直接返回，下一轮循环会等待并重新检查
+		r.execute()
+	}
+}
+
+// executeCmd 执行单个 reload 命令并回调 onResult
 func (r *ReloadDebouncer) executeCmd(cmd string) {
+	err := r.run(cmd)
+	if r.onResult != nil {
+		r.onResult(cmd, err)
+	}
+}
+
+// run 立即执行 reload 命令并记录输出，不经过防抖也不回调 onResult
+func (r *ReloadDebouncer) run(cmd string) error {
 	slog.Info("执行重载命令", "cmd", cmd)
-	if err := command.ExecuteWithStdio(context.Background(), cmd, 15*time.Second); err != nil {
-		slog.Error("重载命令执行失败", "cmd", cmd, "error", err)
+	err := command.ExecuteStreaming(context.Background(), cmd, r.timeout, func(line string) {
+		slog.Info("重载命令输出", "cmd", cmd, "line", line)
+	})
+	if err != nil {
+		if code, ok := command.ExitCode(err); ok {
+			slog.Error("重载命令执行失败", "cmd", cmd, "exit_code", code, "error", err)
+		} else {
+			slog.Error("重载命令执行失败", "cmd", cmd, "error", err)
+		}
 	} else {
 		slog.Info("重载命令执行成功", "cmd", cmd)
 	}
+	return err
 }
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReloadDebouncer_Timeout(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("需要 sleep")
+	}
+
+	r := NewReloadDebouncer(time.Millisecond, 200*time.Millisecond)
+	start := time.Now()
+	r.executeCmd("sleep 5")
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("reload 命令在 %v 后才结束，未按超时终止", elapsed)
+	}
+}
+
+func TestReloadDebouncer_Wait(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("需要 sleep")
+	}
+
+	r := NewReloadDebouncer(time.Millisecond, 5*time.Second)
+	if !r.Wait(time.Millisecond) {
+		t.Fatal("空闲时 Wait 应立即返回 true")
+	}
+
+	r.Trigger("sleep 1")
+	deadline := time.Now().Add(time.Second)
+	for !r.isExecuting() {
+		if time.Now().After(deadline) {
+			t.Fatal("reload 命令未开始执行")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if r.Wait(50 * time.Millisecond) {
+		t.Error("命令执行中 Wait 不应在超时前返回 true")
+	}
+	if !r.Wait(5 * time.Second) {
+		t.Error("命令结束后 Wait 应返回 true")
+	}
+}
+
+func TestReloadDebouncer_Flush(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "reloaded")
+	r := NewReloadDebouncer(time.Hour, time.Second)
+	r.Flush() // 队列为空时立即返回
+
+	r.Trigger("touch " + marker)
+	r.Flush()
+	if _, err := os.Stat(marker); err != nil {
+		t.Fatalf("Flush 后防抖中的 reload 未执行: %v", err)
+	}
+
+	// 重复调用不再执行已完成的命令
+	os.Remove(marker)
+	r.Flush()
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Error("重复 Flush 不应再次执行 reload")
+	}
+	r.mu.Lock()
+	timer := r.timer
+	r.mu.Unlock()
+	if timer != nil {
+		t.Error("Flush 后计时器应已停止")
+	}
+}
+
+func TestReloadDebouncer_FlushConcurrentTimer(t *testing.T) {
+	dir := t.TempDir()
+	r := NewReloadDebouncer(time.Millisecond, time.Second)
+
+	// 计时器触发的执行与 Flush 并发，每条命令都应在 Flush 返回前执行
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		r.Trigger("touch " + filepath.Join(dir, string(rune('a'+i))))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.Flush()
+		}()
+		time.Sleep(time.Millisecond)
+	}
+	wg.Wait()
+	r.Flush()
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 20 {
+		t.Errorf("执行了 %d 条 reload 命令, want 20", len(entries))
+	}
+	if r.isExecuting() {
+		t.Error("Flush 返回后不应仍在执行")
+	}
+}
+
+func (r *ReloadDebouncer) isExecuting() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.executing
+}
@@ -0,0 +1,125 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/Catker/acmeDeliver/pkg/config"
+	"github.com/Catker/acmeDeliver/pkg/domainmatch"
+	"github.com/Catker/acmeDeliver/pkg/workspace"
+)
+
+// ErrNoSiteConfig 下线域名时没有匹配的站点配置（可使用 force 跳过检查）
+var ErrNoSiteConfig = errors.New("未找到此域名的站点配置")
+
+// RemoveOptions 域名下线选项
+type RemoveOptions struct {
+	WorkDir       string                   // 工作目录
+	Domain        string                   // 要下线的域名
+	Site          *config.SiteDeployConfig // 匹配的站点配置，nil 表示没有
+	PurgeDeployed bool                     // 同时删除站点配置中的 cert_path / key_path / fullchain_path / bundle_path
+	Force         bool                     // 没有匹配的站点配置时仍删除工作目录；通配符站点时同时删除不含 {domain} 的共享部署文件
+	DryRun        bool                     // 只返回将要删除的内容，不实际删除
+}
+
+// RemovalResult 域名下线结果，DryRun 时为将要删除的内容
+type RemovalResult struct {
+	Domain    string   `json:"domain"`
+	Workspace string   `json:"workspace,omitempty"` // 删除的工作目录（不存在时为空）
+	Deployed  []string `json:"deployed,omitempty"`  // 删除的已部署文件
+	Skipped   []string `json:"skipped,omitempty"`   // 通配符站点中不含 {domain}、可能被其他域名共用而跳过的部署文件
+	DryRun    bool     `json:"dry_run,omitempty"`
+}
+
+// RemoveDomain 下线域名：删除工作目录中的域名目录，PurgeDeployed 时删除站点配置中的部署文件
+// 只删除工作目录下的域名目录与站点配置中明确列出的文件，不删除目录（包括部署文件所在的目录），也不跟随符号链接
+// 没有匹配的站点配置且未指定 Force 时返回 ErrNoSiteConfig，不删除任何内容
+// 站点为通配符匹配时，不含 {domain} 的部署路径由所有匹配的域名共用，未指定 Force 时跳过
+func RemoveDomain(opts RemoveOptions) (*RemovalResult, error) {
+	if opts.Site == nil && !opts.Force {
+		return nil, ErrNoSiteConfig
+	}
+	result := &RemovalResult{Domain: opts.Domain, DryRun: opts.DryRun}
+
+	// 先检查部署路径，任一路径是目录时不删除任何内容
+	var paths []string
+	if opts.PurgeDeployed && opts.Site != nil {
+		paths, result.Skipped = deployedPaths(opts.Site, opts.Domain, opts.Force)
+		if len(result.Skipped) > 0 {
+			slog.Warn("通配符站点的部署路径不含 {domain}，可能被其他域名共用，已跳过（使用 force 仍删除）",
+				"domain", opts.Domain, "site", opts.Site.Domain, "paths", result.Skipped)
+		}
+		for _, path := range paths {
+			if info, err := os.Lstat(path); err == nil && info.IsDir() {
+				return result, fmt.Errorf("%s 是目录，拒绝删除", path)
+			}
+		}
+	}
+
+	removed, err := workspace.RemoveDomain(opts.WorkDir, opts.Domain, opts.DryRun)
+	if err != nil {
+		return result, fmt.Errorf("删除工作目录失败: %w", err)
+	}
+	if removed {
+		result.Workspace = filepath.Join(opts.WorkDir, opts.Domain)
+	}
+
+	for _, path := range paths {
+		ok, err := removeDeployedFile(path, opts.DryRun)
+		if err != nil {
+			return result, err
+		}
+		if ok {
+			result.Deployed = append(result.Deployed, path)
+		}
+	}
+	return result, nil
+}
+
+// deployedPaths 返回站点配置中的部署文件路径（替换 {domain} 占位符，去除空值与重复项）
+// 通配符站点中不含 {domain} 的路径由所有匹配的域名共用，includeShared 为 false 时放入 skipped
+func deployedPaths(site *config.SiteDeployConfig, domain string, includeShared bool) (paths, skipped []string) {
+	shared := domainmatch.IsWildcard(site.Domain) && !includeShared
+	for _, path := range []string{site.CertPath, site.KeyPath, site.FullchainPath, site.BundlePath, site.CombinedPath, site.PKCS12Path} {
+		if path == "" {
+			continue
+		}
+		target := &paths
+		if shared && !strings.Contains(path, "{domain}") {
+			target = &skipped
+		}
+		path = filepath.Clean(strings.ReplaceAll(path, "{domain}", domain))
+		if !slices.Contains(*target, path) {
+			*target = append(*target, path)
+		}
+	}
+	return paths, skipped
+}
+
+// removeDeployedFile 删除单个部署文件，返回是否（dryRun 时为将要）删除
+// 文件不存在时返回 false；路径为目录时拒绝删除；符号链接只删除链接本身
+func removeDeployedFile(path string, dryRun bool) (bool, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("读取部署文件失败: %w", err)
+	}
+	if info.IsDir() {
+		return false, fmt.Errorf("%s 是目录，拒绝删除", path)
+	}
+	if dryRun {
+		return true, nil
+	}
+	if err := os.Remove(path); err != nil {
+		return false, fmt.Errorf("删除部署文件失败: %w", err)
+	}
+	slog.Debug("已删除部署文件", "path", path)
+	return true, nil
+}
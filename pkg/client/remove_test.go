@@ -0,0 +1,245 @@
+package client
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/Catker/acmeDeliver/pkg/config"
+)
+
+// removeFixture 下线测试的目录结构：工作目录中的两个域名，以及部署目录中的已部署文件和无关文件
+type removeFixture struct {
+	workDir   string
+	deployDir string
+	site      *config.SiteDeployConfig
+}
+
+func newRemoveFixture(t *testing.T) *removeFixture {
+	t.Helper()
+	f := &removeFixture{workDir: t.TempDir(), deployDir: t.TempDir()}
+	for _, domain := range []string{"example.com", "other.com"} {
+		writeTestFile(t, filepath.Join(f.workDir, domain, "cert.pem"), "cert")
+		writeTestFile(t, filepath.Join(f.workDir, domain, "time.log"), "1700000000")
+	}
+	for _, name := range []string{"cert.pem", "key.pem", "fullchain.pem", "nginx.conf"} {
+		writeTestFile(t, filepath.Join(f.deployDir, "example.com", name), name)
+	}
+	writeTestFile(t, filepath.Join(f.deployDir, "other.com", "cert.pem"), "other")
+
+	f.site = &config.SiteDeployConfig{
+		Domain:        "example.com",
+		CertPath:      filepath.Join(f.deployDir, "{domain}", "cert.pem"),
+		KeyPath:       filepath.Join(f.deployDir, "{domain}", "key.pem"),
+		FullchainPath: filepath.Join(f.deployDir, "{domain}", "fullchain.pem"),
+	}
+	return f
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// listFiles 返回目录下所有文件与目录的相对路径（不跟随符号链接）
+func listFiles(t *testing.T, root string) []string {
+	t.Helper()
+	var files []string
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if rel, _ := filepath.Rel(root, path); rel != "." {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files
+}
+
+func TestRemoveDomain(t *testing.T) {
+	tests := []struct {
+		name         string
+		noSite       bool
+		purge        bool
+		force        bool
+		dryRun       bool
+		wantErr      error
+		wantDeployed []string // 删除的部署文件（相对 deployDir）
+		wantWorkDir  []string // 剩余的工作目录内容
+		wantDeploy   []string // 剩余的部署目录内容
+	}{
+		{
+			name:        "只删除工作目录",
+			wantWorkDir: []string{"other.com", "other.com/cert.pem", "other.com/time.log"},
+			wantDeploy: []string{"example.com", "example.com/cert.pem", "example.com/fullchain.pem", "example.com/key.pem", "example.com/nginx.conf",
+				"other.com", "other.com/cert.pem"},
+		},
+		{
+			name:         "删除已部署文件",
+			purge:        true,
+			wantDeployed: []string{"example.com/cert.pem", "example.com/key.pem", "example.com/fullchain.pem"},
+			wantWorkDir:  []string{"other.com", "other.com/cert.pem", "other.com/time.log"},
+			wantDeploy:   []string{"example.com", "example.com/nginx.conf", "other.com", "other.com/cert.pem"},
+		},
+		{
+			name:         "演练模式不删除",
+			purge:        true,
+			dryRun:       true,
+			wantDeployed: []string{"example.com/cert.pem", "example.com/key.pem", "example.com/fullchain.pem"},
+			wantWorkDir: []string{"example.com", "example.com/cert.pem", "example.com/time.log",
+				"other.com", "other.com/cert.pem", "other.com/time.log"},
+			wantDeploy: []string{"example.com", "example.com/cert.pem", "example.com/fullchain.pem", "example.com/key.pem", "example.com/nginx.conf",
+				"other.com", "other.com/cert.pem"},
+		},
+		{
+			name:    "无站点配置时拒绝",
+			noSite:  true,
+			purge:   true,
+			wantErr: ErrNoSiteConfig,
+			wantWorkDir: []string{"example.com", "example.com/cert.pem", "example.com/time.log",
+				"other.com", "other.com/cert.pem", "other.com/time.log"},
+			wantDeploy: []string{"example.com", "example.com/cert.pem", "example.com/fullchain.pem", "example.com/key.pem", "example.com/nginx.conf",
+				"other.com", "other.com/cert.pem"},
+		},
+		{
+			name:        "无站点配置时 force 只删除工作目录",
+			noSite:      true,
+			purge:       true,
+			force:       true,
+			wantWorkDir: []string{"other.com", "other.com/cert.pem", "other.com/time.log"},
+			wantDeploy: []string{"example.com", "example.com/cert.pem", "example.com/fullchain.pem", "example.com/key.pem", "example.com/nginx.conf",
+				"other.com", "other.com/cert.pem"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newRemoveFixture(t)
+			site := f.site
+			if tt.noSite {
+				site = nil
+			}
+
+			result, err := RemoveDomain(RemoveOptions{
+				WorkDir: f.workDir, Domain: "example.com", Site: site,
+				PurgeDeployed: tt.purge, Force: tt.force, DryRun: tt.dryRun,
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RemoveDomain() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if result.Workspace != filepath.Join(f.workDir, "example.com") {
+					t.Errorf("Workspace = %q", result.Workspace)
+				}
+				var deployed []string
+				for _, path := range result.Deployed {
+					rel, _ := filepath.Rel(f.deployDir, path)
+					deployed = append(deployed, rel)
+				}
+				if !reflect.DeepEqual(deployed, tt.wantDeployed) {
+					t.Errorf("Deployed = %v, want %v", deployed, tt.wantDeployed)
+				}
+			}
+
+			if got := listFiles(t, f.workDir); !reflect.DeepEqual(got, tt.wantWorkDir) {
+				t.Errorf("工作目录剩余 %v, want %v", got, tt.wantWorkDir)
+			}
+			if got := listFiles(t, f.deployDir); !reflect.DeepEqual(got, tt.wantDeploy) {
+				t.Errorf("部署目录剩余 %v, want %v", got, tt.wantDeploy)
+			}
+		})
+	}
+}
+
+func TestRemoveDomainWildcardSiteSkipsSharedPaths(t *testing.T) {
+	f := newRemoveFixture(t)
+	shared := filepath.Join(f.deployDir, "fullchain.pem")
+	writeTestFile(t, shared, "shared")
+	site := &config.SiteDeployConfig{
+		Domain:        "*.com",
+		CertPath:      filepath.Join(f.deployDir, "{domain}", "cert.pem"),
+		FullchainPath: shared,
+	}
+
+	result, err := RemoveDomain(RemoveOptions{WorkDir: f.workDir, Domain: "example.com", Site: site, PurgeDeployed: true})
+	if err != nil {
+		t.Fatalf("RemoveDomain() error = %v", err)
+	}
+	if want := []string{filepath.Join(f.deployDir, "example.com", "cert.pem")}; !reflect.DeepEqual(result.Deployed, want) {
+		t.Errorf("Deployed = %v, want %v", result.Deployed, want)
+	}
+	if !reflect.DeepEqual(result.Skipped, []string{shared}) {
+		t.Errorf("Skipped = %v, want %v", result.Skipped, []string{shared})
+	}
+	if _, err := os.Stat(shared); err != nil {
+		t.Errorf("共享部署文件被删除: %v", err)
+	}
+
+	// force 时同时删除共享部署文件
+	result, err = RemoveDomain(RemoveOptions{WorkDir: f.workDir, Domain: "other.com", Site: site, PurgeDeployed: true, Force: true})
+	if err != nil {
+		t.Fatalf("RemoveDomain(force) error = %v", err)
+	}
+	if len(result.Skipped) != 0 || !reflect.DeepEqual(result.Deployed, []string{filepath.Join(f.deployDir, "other.com", "cert.pem"), shared}) {
+		t.Errorf("force: Deployed = %v, Skipped = %v", result.Deployed, result.Skipped)
+	}
+	if _, err := os.Stat(shared); !os.IsNotExist(err) {
+		t.Errorf("force 时应删除共享部署文件: %v", err)
+	}
+}
+
+func TestRemoveDomainNeverDeletesOutsideConfiguredPaths(t *testing.T) {
+	f := newRemoveFixture(t)
+	outside := t.TempDir()
+	writeTestFile(t, filepath.Join(outside, "target.pem"), "target")
+
+	// 部署路径是符号链接时只删除链接本身
+	link := filepath.Join(f.deployDir, "example.com", "link.pem")
+	if err := os.Symlink(filepath.Join(outside, "target.pem"), link); err != nil {
+		t.Fatal(err)
+	}
+	site := &config.SiteDeployConfig{Domain: "example.com", CertPath: link}
+	result, err := RemoveDomain(RemoveOptions{WorkDir: f.workDir, Domain: "example.com", Site: site, PurgeDeployed: true})
+	if err != nil {
+		t.Fatalf("RemoveDomain() error = %v", err)
+	}
+	if !reflect.DeepEqual(result.Deployed, []string{link}) {
+		t.Errorf("Deployed = %v", result.Deployed)
+	}
+	if got := listFiles(t, outside); !reflect.DeepEqual(got, []string{"target.pem"}) {
+		t.Errorf("符号链接目标被删除: %v", got)
+	}
+
+	// 部署路径指向目录时拒绝删除
+	site = &config.SiteDeployConfig{Domain: "other.com", CertPath: filepath.Join(f.deployDir, "{domain}")}
+	if _, err := RemoveDomain(RemoveOptions{WorkDir: f.workDir, Domain: "other.com", Site: site, PurgeDeployed: true}); err == nil {
+		t.Error("部署路径为目录时应返回错误")
+	}
+	if got := listFiles(t, filepath.Join(f.deployDir, "other.com")); !reflect.DeepEqual(got, []string{"cert.pem"}) {
+		t.Errorf("部署目录被删除: %v", got)
+	}
+
+	// 非法域名在删除任何文件前被拒绝
+	for _, domain := range []string{"..", "../other.com", "a/b", ""} {
+		site := &config.SiteDeployConfig{Domain: "*", CertPath: filepath.Join(f.deployDir, "other.com", "cert.pem")}
+		if _, err := RemoveDomain(RemoveOptions{WorkDir: f.workDir, Domain: domain, Site: site, PurgeDeployed: true, Force: true}); err == nil {
+			t.Errorf("RemoveDomain(%q) 应返回错误", domain)
+		}
+	}
+	if got := listFiles(t, filepath.Join(f.deployDir, "other.com")); !reflect.DeepEqual(got, []string{"cert.pem"}) {
+		t.Errorf("非法域名导致部署文件被删除: %v", got)
+	}
+	if got := listFiles(t, f.workDir); !reflect.DeepEqual(got, []string{"other.com", "other.com/cert.pem", "other.com/time.log"}) {
+		t.Errorf("工作目录剩余 %v", got)
+	}
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// defaultRetryBaseDelay 重试退避基数，第 n 次重试前等待 base * 2^(n-1) 加 [0, base) 随机延迟
+const defaultRetryBaseDelay = time.Second
+
+// SetRetries 设置 Connect、证书下载与状态查询的最大尝试次数（含首次），n 小于 1 时视为 1（不重试）
+// 仅网络错误与超时会重试，认证被拒绝、域名不存在等服务端明确返回的错误直接返回
+func (c *WSClient) SetRetries(n int) {
+	if n < 1 {
+		n = 1
+	}
+	c.retries = n
+}
+
+// retry 执行 fn，遇到可重试错误时按指数退避加随机抖动重试
+// 重试后仍失败时返回的错误包装最后一次的错误并注明尝试次数
+func (c *WSClient) retry(ctx context.Context, op string, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if attempt >= c.retries || !isRetryable(err) || ctx.Err() != nil {
+			if attempt > 1 {
+				return fmt.Errorf("%s失败（共尝试 %d 次）: %w", op, attempt, err)
+			}
+			return err
+		}
+
+		delay := backoff(attempt-1, c.retryBaseDelay)
+		if jitter, jerr := reconnectJitter(c.jitterRand, c.retryBaseDelay); jerr == nil {
+			delay += jitter
+		}
+		slog.Warn("操作失败，稍后重试", "op", op, "attempt", attempt, "max_attempts", c.retries, "delay", delay, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s失败（共尝试 %d 次）: %w", op, attempt, err)
+		case <-time.After(delay):
+		}
+	}
+}
+
+// isRetryable 判断错误是否为可重试的网络错误或超时
+func isRetryable(err error) bool {
+	if errors.Is(err, errMessageTooBig) {
+		return false // 重试仍会超限
+	}
+	if errors.Is(err, errRequestTimeout) || errors.Is(err, errConnectionClosed) || errors.Is(err, errServerUnavailable) {
+		return true
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, websocket.ErrCloseSent) {
+		return true
+	}
+	var netErr net.Error
+	var closeErr *websocket.CloseError
+	return errors.As(err, &netErr) || errors.As(err, &closeErr)
+}
@@ -0,0 +1,147 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/Catker/acmeDeliver/pkg/testutil/wstest"
+)
+
+// newRetryClient 创建缩短退避基数的客户端，避免测试等待
+func newRetryClient(url, password string, retries int) *WSClient {
+	c := NewWSClient(url, password, nil)
+	c.SetRetries(retries)
+	c.retryBaseDelay = time.Millisecond
+	return c
+}
+
+func TestWSClient_ConnectRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		retries      int
+		wantErr      bool
+		wantAttempts int
+	}{
+		{"第三次尝试成功", 3, false, 3},
+		{"尝试次数不足", 2, true, 2},
+		{"不重试", 1, true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := wstest.NewMockServer(t)
+			server.FailNext(2)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			client := newRetryClient(server.URL, wstest.DefaultPassword, tt.retries)
+			err := client.Connect(ctx)
+			defer client.Close()
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Connect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := server.Attempts(); got != tt.wantAttempts {
+				t.Errorf("服务端收到 %d 次连接, want %d", got, tt.wantAttempts)
+			}
+			if err == nil {
+				return
+			}
+			if !errors.Is(err, errServerUnavailable) {
+				t.Errorf("错误应包装最后一次尝试的错误: %v", err)
+			}
+			if tt.retries > 1 && !strings.Contains(err.Error(), fmt.Sprintf("共尝试 %d 次", tt.retries)) {
+				t.Errorf("错误应注明尝试次数: %v", err)
+			}
+		})
+	}
+}
+
+func TestWSClient_ConnectAuthRejectedNotRetried(t *testing.T) {
+	server := wstest.NewMockServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client := newRetryClient(server.URL, "wrong-password", 3)
+	if err := client.Connect(ctx); err == nil {
+		client.Close()
+		t.Fatal("密码错误时 Connect() 应失败")
+	}
+	if got := server.Attempts(); got != 1 {
+		t.Errorf("认证被拒绝不应重试，服务端收到 %d 次连接", got)
+	}
+}
+
+func TestWSClient_ReconnectAfterDrop(t *testing.T) {
+	server := newTestWSServer(t, "a.example.com", "b.example.com")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client := newRetryClient(server.URL, wstest.DefaultPassword, 3)
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.DownloadCert(ctx, "a.example.com", false); err != nil {
+		t.Fatalf("DownloadCert() error = %v", err)
+	}
+
+	// 两个域名之间连接断开
+	client.mu.Lock()
+	done := client.done
+	client.conn.Close()
+	client.mu.Unlock()
+	<-done
+
+	certs, err := client.DownloadCert(ctx, "b.example.com", false)
+	if err != nil {
+		t.Fatalf("断线后 DownloadCert() error = %v", err)
+	}
+	if string(certs.Cert) != "b.example.com" {
+		t.Errorf("Cert = %q", certs.Cert)
+	}
+	if got := server.Attempts(); got != 2 {
+		t.Errorf("服务端收到 %d 次连接, want 2", got)
+	}
+
+	// 域名不存在属于服务端明确返回的错误，不重试
+	_, err = client.DownloadCert(ctx, "missing.example.com", false)
+	if err == nil || strings.Contains(err.Error(), "共尝试") {
+		t.Errorf("域名不存在不应重试: %v", err)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"请求超时", errRequestTimeout, true},
+		{"连接断开", fmt.Errorf("发送失败: %w", errConnectionClosed), true},
+		{"服务端 503", fmt.Errorf("连接服务器失败: %w (HTTP 503)", errServerUnavailable), true},
+		{"连接被重置", &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}, true},
+		{"意外 EOF", io.ErrUnexpectedEOF, true},
+		{"WebSocket 关闭", &websocket.CloseError{Code: websocket.CloseAbnormalClosure}, true},
+		{"认证被拒绝", fmt.Errorf("认证失败: %w", errors.New("认证被拒绝: 签名无效")), false},
+		{"域名不存在", errors.New("服务器错误: 域名不存在"), false},
+		{"未认证", errors.New("未认证"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryable(tt.err); got != tt.want {
+				t.Errorf("isRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
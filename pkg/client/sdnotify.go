@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"time"
+)
+
+// systemd 服务通知（sd_notify 协议）：以 Type=notify 运行时 daemon 连接并认证后发送 READY=1，
+// 设置 WatchdogSec= 时按间隔发送 WATCHDOG=1，systemd 在超时未收到时重启 daemon
+
+// notifier 向服务管理器发送状态通知，测试中可替换
+type notifier interface {
+	Notify(state string) error
+}
+
+// systemdNotifier 向 NOTIFY_SOCKET 发送 sd_notify 消息
+// 未设置 NOTIFY_SOCKET（不在 systemd 下运行或服务类型不是 notify）时为空操作
+type systemdNotifier struct {
+	socket string
+}
+
+// newSystemdNotifier 按 NOTIFY_SOCKET 环境变量创建通知器
+func newSystemdNotifier(getenv func(string) string) *systemdNotifier {
+	return &systemdNotifier{socket: getenv("NOTIFY_SOCKET")}
+}
+
+// Notify 发送一条通知，state 为换行分隔的 KEY=VALUE（如 READY=1）
+func (n *systemdNotifier) Notify(state string) error {
+	if n.socket == "" {
+		return nil
+	}
+	addr := &net.UnixAddr{Name: n.socket, Net: "unixgram"}
+	// 以 @ 开头表示 Linux 抽象命名空间套接字
+	if addr.Name[0] == '@' {
+		addr.Name = "\x00" + addr.Name[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// systemdWatchdogInterval 返回 systemd 要求的 watchdog 超时（WATCHDOG_USEC），未启用时返回 0
+// 设置了 WATCHDOG_PID 时只有 pid 与之相同才启用，避免子进程继承环境变量后误发
+func systemdWatchdogInterval(getenv func(string) string, pid int) time.Duration {
+	usec, err := strconv.ParseInt(getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if s := getenv("WATCHDOG_PID"); s != "" {
+		if p, err := strconv.Atoi(s); err != nil || p != pid {
+			return 0
+		}
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// sdNotify 发送 systemd 通知，失败只记录日志
+func (d *Daemon) sdNotify(state string) {
+	if err := d.notifier.Notify(state); err != nil {
+		d.log().Debug("发送 systemd 通知失败", "state", state, "error", err)
+	}
+}
+
+// watchdog 每隔 watchdog 超时的一半发送 WATCHDOG=1
+// 已连接但服务端静默超过两倍 PongTimeout 时停止发送：heartbeat 本应在 PongTimeout 后断开重连，
+// 仍未恢复说明消息处理已阻塞，由 systemd 重启 daemon
+func (d *Daemon) watchdog(ctx context.Context) {
+	ticker := time.NewTicker(d.watchdogInterval / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if silence := time.Since(d.getLastPong()); d.Status().Connected && silence > 2*d.config.PongTimeout {
+				d.log().Warn("daemon 长时间未处理服务端消息，停止发送 systemd watchdog 通知", "silence", silence.Round(time.Second))
+				continue
+			}
+			d.sdNotify("WATCHDOG=1")
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/testutil/wstest"
+)
+
+// recordingNotifier 记录收到的 systemd 通知
+type recordingNotifier struct {
+	mu     sync.Mutex
+	states []string
+}
+
+func (n *recordingNotifier) Notify(state string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.states = append(n.states, state)
+	return nil
+}
+
+// snapshot 返回已收到的通知
+func (n *recordingNotifier) snapshot() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return slices.Clone(n.states)
+}
+
+// count 返回以 prefix 开头的通知数
+func (n *recordingNotifier) count(prefix string) int {
+	var c int
+	for _, s := range n.snapshot() {
+		if strings.HasPrefix(s, prefix) {
+			c++
+		}
+	}
+	return c
+}
+
+func TestSystemdNotifier(t *testing.T) {
+	// 未设置 NOTIFY_SOCKET 时为空操作
+	if err := newSystemdNotifier(func(string) string { return "" }).Notify("READY=1"); err != nil {
+		t.Errorf("未设置 NOTIFY_SOCKET 时 Notify() error = %v", err)
+	}
+
+	socket := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("不支持 unixgram 套接字: %v", err)
+	}
+	defer conn.Close()
+
+	n := newSystemdNotifier(func(key string) string {
+		if key == "NOTIFY_SOCKET" {
+			return socket
+		}
+		return ""
+	})
+	if err := n.Notify("READY=1\nSTATUS=ok"); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	size, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:size]); got != "READY=1\nSTATUS=ok" {
+		t.Errorf("收到通知 %q", got)
+	}
+}
+
+func TestSystemdWatchdogInterval(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want time.Duration
+	}{
+		{"未启用", map[string]string{}, 0},
+		{"启用", map[string]string{"WATCHDOG_USEC": "30000000"}, 30 * time.Second},
+		{"PID 匹配", map[string]string{"WATCHDOG_USEC": "1000000", "WATCHDOG_PID": "42"}, time.Second},
+		{"PID 不匹配", map[string]string{"WATCHDOG_USEC": "1000000", "WATCHDOG_PID": "43"}, 0},
+		{"无效值", map[string]string{"WATCHDOG_USEC": "abc"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := systemdWatchdogInterval(func(key string) string { return tt.env[key] }, 42)
+			if got != tt.want {
+				t.Errorf("systemdWatchdogInterval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// waitNotify 等待收到至少 n 条以 prefix 开头的通知
+func waitNotify(t *testing.T, notifier *recordingNotifier, prefix string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for notifier.count(prefix) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("未收到 %d 条 %s 通知，已收到 %q", n, prefix, notifier.snapshot())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDaemon_SdNotifyLifecycle(t *testing.T) {
+	for _, available := range []bool{true, false} {
+		server := wstest.NewMockServer(t)
+		if !available {
+			server.SetUnavailable(http.StatusServiceUnavailable)
+		}
+		d := NewDaemon(&DaemonConfig{
+			ServerURL:         server.WSURL(),
+			Password:          wstest.DefaultPassword,
+			ClientID:          "node-1",
+			WorkDir:           t.TempDir(),
+			Subscribe:         []string{"a.example.com"},
+			ReconnectInterval: 10 * time.Millisecond,
+			HeartbeatInterval: time.Minute,
+		})
+		notifier := &recordingNotifier{}
+		d.notifier = notifier
+		d.watchdogInterval = 40 * time.Millisecond
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- d.Run(ctx) }()
+
+		if available {
+			waitNotify(t, notifier, "READY=1", 1)
+		}
+		// 连接不上服务端时 daemon 仍在正常重连，watchdog 照常发送
+		waitNotify(t, notifier, "WATCHDOG=1", 2)
+		cancel()
+		if err := <-done; err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+
+		states := notifier.snapshot()
+		if got := notifier.count("READY=1"); available != (got > 0) {
+			t.Errorf("服务端可用 = %v 时收到 %d 条 READY=1 通知", available, got)
+		}
+		if states[len(states)-1] != "STOPPING=1" {
+			t.Errorf("退出时最后一条通知 = %q, want STOPPING=1", states[len(states)-1])
+		}
+	}
+}
+
+func TestDaemon_WatchdogStopsWhenStalled(t *testing.T) {
+	d := NewDaemon(&DaemonConfig{HeartbeatInterval: 10 * time.Millisecond, PongTimeout: 20 * time.Millisecond})
+	notifier := &recordingNotifier{}
+	d.notifier = notifier
+	d.watchdogInterval = 20 * time.Millisecond
+
+	// 已连接且长时间未收到服务端消息，视为消息处理阻塞
+	d.state.setConnected(true)
+	d.pongMu.Lock()
+	d.lastPong = time.Now().Add(-time.Minute)
+	d.pongMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	d.watchdog(ctx)
+	if got := notifier.count("WATCHDOG=1"); got != 0 {
+		t.Errorf("消息处理阻塞时仍发送了 %d 条 WATCHDOG=1", got)
+	}
+
+	// 恢复后继续发送
+	d.updateLastPong()
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	d.watchdog(ctx)
+	if notifier.count("WATCHDOG=1") == 0 {
+		t.Error("收到服务端消息后应恢复发送 WATCHDOG=1")
+	}
+}
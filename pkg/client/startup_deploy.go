@@ -0,0 +1,123 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Catker/acmeDeliver/pkg/cert"
+	"github.com/Catker/acmeDeliver/pkg/config"
+	"github.com/Catker/acmeDeliver/pkg/workspace"
+)
+
+// deployTarget 站点的一个部署文件及其在工作目录中的来源文件
+type deployTarget struct {
+	dst  string   // 部署路径（已替换 {domain}）
+	srcs []string // 来源文件名，任一比部署文件新即需要重新部署
+	// combined 非空时为合并文件，值为组成顺序（如 "fullchain,key"）
+	combined string
+	// pkcs12 为 true 时为 PKCS#12 文件：每次编码使用随机盐值，内容无法与来源直接比对
+	pkcs12 bool
+}
+
+// siteDeployTargets 返回站点配置中的全部部署文件
+func siteDeployTargets(domain string, site *config.SiteDeployConfig) []deployTarget {
+	var targets []deployTarget
+	add := func(dst string, srcs ...string) {
+		if dst != "" {
+			targets = append(targets, deployTarget{dst: strings.ReplaceAll(dst, "{domain}", domain), srcs: srcs})
+		}
+	}
+	add(site.CertPath, "cert.pem")
+	add(site.KeyPath, "key.pem")
+	add(site.FullchainPath, "fullchain.pem")
+	add(site.BundlePath, "cert.pem", "fullchain.pem")
+	if site.CombinedPath != "" {
+		// 组成顺序已在加载配置时校验
+		if parts, err := cert.ParseCombinedOrder(site.CombinedOrder); err == nil {
+			srcs := make([]string, len(parts))
+			for i, part := range parts {
+				srcs[i] = part + ".pem"
+			}
+			add(site.CombinedPath, srcs...)
+			targets[len(targets)-1].combined = strings.Join(parts, ",")
+		}
+	}
+	if site.PKCS12Path != "" {
+		add(site.PKCS12Path, "key.pem", "fullchain.pem")
+		targets[len(targets)-1].pkcs12 = true
+	}
+	return targets
+}
+
+// deploymentStale 判断已部署的文件是否缺失或比工作目录中的证书旧
+// 工作目录中不存在的来源文件不参与比较
+func deploymentStale(domain, srcDir string, site *config.SiteDeployConfig) bool {
+	for _, target := range siteDeployTargets(domain, site) {
+		dstInfo, dstErr := os.Stat(target.dst)
+		for _, name := range target.srcs {
+			srcInfo, err := os.Stat(filepath.Join(srcDir, name))
+			if err != nil {
+				continue
+			}
+			if dstErr != nil || dstInfo.ModTime().Before(srcInfo.ModTime()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// deployFromWorkdir 启动时（连接服务端之前）重新部署工作目录中已下载的证书
+// 部署文件缺失或比工作目录旧时（如证书路径位于 tmpfs、系统重装后）才部署，reload 经防抖统一触发
+// 无需等待服务端再次推送即可恢复服务
+func (d *Daemon) deployFromWorkdir() {
+	d.mu.RLock()
+	workDir, sites, retention := d.config.WorkDir, d.config.Sites, d.config.BackupRetention
+	d.mu.RUnlock()
+
+	local, err := workspace.ListDomains(workDir)
+	if err != nil {
+		d.log().Warn("扫描工作目录失败，跳过启动部署", "workdir", workDir, "error", err)
+		return
+	}
+	domains := make([]string, 0, len(local))
+	for domain := range local {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+
+	deployed := 0
+	for _, domain := range domains {
+		site := config.FindSite(sites, domain)
+		if site == nil {
+			continue
+		}
+		srcDir, err := safeDomainDir(workDir, domain)
+		if err != nil || !deploymentStale(domain, srcDir, site) {
+			continue
+		}
+		var backup *DeployBackup
+		var unchanged bool
+		err = d.runPreCmd(domain, site)
+		if err == nil {
+			backup, unchanged, err = d.deployCertFiles(domain, srcDir, site, retention)
+		}
+		d.state.recordDeploy(domain, err)
+		if err != nil {
+			d.log().Error("启动时部署证书失败", "domain", domain, "error", err)
+			continue
+		}
+		if unchanged {
+			d.log().Debug("部署文件内容均未变化，启动时无需 reload", "domain", domain)
+			continue
+		}
+		d.log().Info("启动时已重新部署工作目录中的证书", "domain", domain)
+		deployed++
+		d.scheduleReload(domain, site, backup, false)
+	}
+	if deployed == 0 {
+		d.log().Debug("部署文件均为最新，启动时无需部署")
+	}
+}
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/config"
+)
+
+// setupStartupDeploy 创建已下载证书的工作目录与空的部署目录
+func setupStartupDeploy(t *testing.T) (workDir, deployDir string, site config.SiteDeployConfig) {
+	t.Helper()
+	workDir, deployDir = t.TempDir(), t.TempDir()
+	for _, domain := range []string{"example.com", "nosite.org"} {
+		dir := filepath.Join(workDir, domain)
+		os.MkdirAll(dir, 0755)
+		for name, content := range map[string]string{"cert.pem": "cert", "key.pem": "key", "fullchain.pem": "chain", "time.log": "1700000000"} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	site = config.SiteDeployConfig{
+		Domain:        "example.com",
+		CertPath:      filepath.Join(deployDir, "{domain}", "cert.pem"),
+		KeyPath:       filepath.Join(deployDir, "{domain}", "key.pem"),
+		FullchainPath: filepath.Join(deployDir, "{domain}", "fullchain.pem"),
+		ReloadCmd:     "touch " + filepath.Join(deployDir, "reloaded"),
+	}
+	return workDir, deployDir, site
+}
+
+func TestDaemon_DeployFromWorkdir(t *testing.T) {
+	workDir, deployDir, site := setupStartupDeploy(t)
+	marker := filepath.Join(deployDir, "reloaded")
+	d := NewDaemon(&DaemonConfig{WorkDir: workDir, Sites: []config.SiteDeployConfig{site}, ReloadDebounce: time.Hour})
+
+	d.deployFromWorkdir()
+	d.flushReloads()
+	for _, name := range []string{"cert.pem", "key.pem", "fullchain.pem"} {
+		if _, err := os.Stat(filepath.Join(deployDir, "example.com", name)); err != nil {
+			t.Errorf("%s 未部署: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Fatalf("部署后 reload 未执行: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(deployDir, "nosite.org")); !os.IsNotExist(err) {
+		t.Error("没有站点配置的域名不应部署")
+	}
+
+	// 部署文件已是最新，再次启动不重复部署
+	os.Remove(marker)
+	d.deployFromWorkdir()
+	d.flushReloads()
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Error("部署文件已是最新时不应再次部署与 reload")
+	}
+
+	// 部署文件缺失或比工作目录旧时重新部署
+	os.Remove(filepath.Join(deployDir, "example.com", "key.pem"))
+	d.deployFromWorkdir()
+	d.flushReloads()
+	if _, err := os.Stat(filepath.Join(deployDir, "example.com", "key.pem")); err != nil {
+		t.Errorf("缺失的 key.pem 未重新部署: %v", err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("重新部署后 reload 未执行: %v", err)
+	}
+
+	// 工作目录证书比部署文件新但内容相同时重新写入，不执行 reload
+	os.Remove(marker)
+	future := time.Now().Add(time.Hour)
+	os.Chtimes(filepath.Join(workDir, "example.com", "cert.pem"), future, future)
+	d.deployFromWorkdir()
+	d.flushReloads()
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Error("部署文件内容未变化时不应 reload")
+	}
+
+	os.WriteFile(filepath.Join(workDir, "example.com", "cert.pem"), []byte("new cert"), 0644)
+	future = future.Add(time.Hour)
+	os.Chtimes(filepath.Join(workDir, "example.com", "cert.pem"), future, future)
+	d.deployFromWorkdir()
+	d.flushReloads()
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("工作目录证书更新后未重新部署: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(deployDir, "example.com", "cert.pem")); string(data) != "new cert" {
+		t.Errorf("cert.pem = %q, want new cert", data)
+	}
+}
+
+func TestDeploymentStale(t *testing.T) {
+	workDir, deployDir, site := setupStartupDeploy(t)
+	srcDir := filepath.Join(workDir, "example.com")
+
+	if !deploymentStale("example.com", srcDir, &site) {
+		t.Error("部署文件缺失时应判定为需要部署")
+	}
+	bundleOnly := config.SiteDeployConfig{Domain: "example.com", BundlePath: filepath.Join(deployDir, "bundle.pem")}
+	os.WriteFile(bundleOnly.BundlePath, []byte("bundle"), 0644)
+	if deploymentStale("example.com", srcDir, &bundleOnly) {
+		t.Error("证书包比工作目录新时不应判定为需要部署")
+	}
+	past := time.Now().Add(-time.Hour)
+	os.Chtimes(bundleOnly.BundlePath, past, past)
+	if !deploymentStale("example.com", srcDir, &bundleOnly) {
+		t.Error("证书包比工作目录旧时应判定为需要部署")
+	}
+	if deploymentStale("example.com", filepath.Join(workDir, "missing"), &site) {
+		t.Error("工作目录中没有证书时不应判定为需要部署")
+	}
+}
+
+func TestDaemon_RunDeploysOnStart(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		workDir, deployDir, site := setupStartupDeploy(t)
+		d := NewDaemon(&DaemonConfig{WorkDir: workDir, Sites: []config.SiteDeployConfig{site}, DeployOnStart: enabled})
+
+		// context 已取消，Run 只执行启动部署后退出
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := d.Run(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(deployDir, "example.com", "cert.pem")); (err == nil) != enabled {
+			t.Errorf("deploy_on_start=%v: cert.pem 存在 = %v", enabled, err == nil)
+		}
+	}
+}
+
+func TestDaemon_RunFlushesReloadsOnExit(t *testing.T) {
+	workDir, deployDir, site := setupStartupDeploy(t)
+	d := NewDaemon(&DaemonConfig{WorkDir: workDir, Sites: []config.SiteDeployConfig{site}, DeployOnStart: true, ReloadDebounce: time.Hour})
+
+	// 防抖尚未到期即退出，reload 仍应在 Run 返回前执行
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := d.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(deployDir, "reloaded")); err != nil {
+		t.Errorf("退出时防抖中的 reload 未执行: %v", err)
+	}
+}
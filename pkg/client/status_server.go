@@ -0,0 +1,69 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// 本地状态接口 /healthz 的状态
+const (
+	statusHealthOK           = "ok"
+	statusHealthDisconnected = "disconnected"
+)
+
+// StatusHealthResponse 本地状态接口 /healthz 响应
+type StatusHealthResponse struct {
+	Status    string `json:"status"`
+	Connected bool   `json:"connected"`
+}
+
+// statusHandler 本地状态接口：/healthz 已连接服务端时返回 200，否则返回 503；/status 返回完整的 DaemonStatus
+func (d *Daemon) statusHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		status := d.state.Snapshot()
+		if status.Connected {
+			writeStatusJSON(w, http.StatusOK, StatusHealthResponse{Status: statusHealthOK, Connected: true})
+			return
+		}
+		writeStatusJSON(w, http.StatusServiceUnavailable, StatusHealthResponse{Status: statusHealthDisconnected})
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		writeStatusJSON(w, http.StatusOK, d.state.Snapshot())
+	})
+	return mux
+}
+
+// serveStatus 在 StatusListen 上启动本地状态接口，返回的函数用于关闭
+// 接口不做认证，应只监听回环地址
+func (d *Daemon) serveStatus() (func(), error) {
+	ln, err := net.Listen("tcp", d.config.StatusListen)
+	if err != nil {
+		return nil, fmt.Errorf("监听状态接口失败: %w", err)
+	}
+	srv := &http.Server{Handler: d.statusHandler(), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			d.log().Error("状态接口异常退出", "error", err)
+		}
+	}()
+	d.log().Info("本地状态接口已启动", "addr", ln.Addr().String())
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}, nil
+}
+
+// writeStatusJSON 输出 JSON 响应
+func writeStatusJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -13,6 +13,8 @@ import (
 type TLSConfig struct {
 	CaFile             string // CA 证书路径（用于验证服务端身份）
 	InsecureSkipVerify bool   // 跳过证书验证（仅开发环境使用）
+	CertFile           string // 客户端证书路径（mTLS，需与 KeyFile 同时设置）
+	KeyFile            string // 客户端私钥路径（mTLS）
 }
 
 // BuildTLSConfig 构建 TLS 配置
@@ -26,7 +28,7 @@ func BuildTLSConfig(cfg *TLSConfig) (*tls.Config, error) {
 	}
 
 	// 无自定义配置时返回 nil，使用系统默认
-	if cfg.CaFile == "" && !cfg.InsecureSkipVerify {
+	if cfg.CaFile == "" && !cfg.InsecureSkipVerify && cfg.CertFile == "" && cfg.KeyFile == "" {
 		return nil, nil
 	}
 
@@ -54,5 +56,18 @@ func BuildTLSConfig(cfg *TLSConfig) (*tls.Config, error) {
 		slog.Info("🔒 已加载自定义 CA 证书", "file", cfg.CaFile)
 	}
 
+	// 加载客户端证书（服务端要求 mTLS 时在握手中出示）
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return nil, fmt.Errorf("客户端证书与私钥必须同时配置")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载客户端证书失败: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+		slog.Debug("🔒 已加载客户端证书", "file", cfg.CertFile)
+	}
+
 	return tlsConfig, nil
 }
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/testutil"
+	"github.com/Catker/acmeDeliver/pkg/testutil/wstest"
+)
+
+func TestWSClient_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey, err := testutil.GenerateCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCert, clientKey, err := testutil.GenerateSignedCert(string(ca), string(caKey), "client.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca)
+
+	server := wstest.NewMockServer(t, wstest.WithTLS(&tls.Config{
+		ClientCAs:  pool,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+	}))
+	server.WriteFile(t, "example.com", "cert.pem", []byte("example.com"))
+
+	// 服务端证书由 httptest 生成，写入文件作为客户端信任的 CA
+	serverCA := filepath.Join(dir, "server-ca.pem")
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+	files := map[string][]byte{
+		serverCA: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}),
+		certFile: clientCert,
+		keyFile:  clientKey,
+	}
+	for path, data := range files {
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewWSClient(server.URL, "test-password", &TLSConfig{CaFile: serverCA, CertFile: certFile, KeyFile: keyFile})
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("出示有效客户端证书时 Connect() error = %v", err)
+	}
+	defer client.Close()
+	certs, err := client.DownloadCert(ctx, "example.com", false)
+	if err != nil {
+		t.Fatalf("DownloadCert() error = %v", err)
+	}
+	if string(certs.Cert) != "example.com" {
+		t.Errorf("Cert = %q", certs.Cert)
+	}
+
+	// 未出示客户端证书时在 TLS 握手阶段被拒绝
+	noCert := NewWSClient(server.URL, "test-password", &TLSConfig{CaFile: serverCA})
+	noCert.SetConnectTimeout(2 * time.Second)
+	if err := noCert.Connect(ctx); err == nil {
+		noCert.Close()
+		t.Fatal("未出示客户端证书时 Connect() 应失败")
+	}
+}
+
+func TestBuildTLSConfig_ClientCert(t *testing.T) {
+	dir := t.TempDir()
+	cert, key, err := testutil.GenerateSelfSignedCert("client.example.com", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+	os.WriteFile(certFile, cert, 0600)
+	os.WriteFile(keyFile, key, 0600)
+
+	tests := []struct {
+		name    string
+		cfg     *TLSConfig
+		wantErr bool
+	}{
+		{"证书与私钥", &TLSConfig{CertFile: certFile, KeyFile: keyFile}, false},
+		{"缺少私钥", &TLSConfig{CertFile: certFile}, true},
+		{"缺少证书", &TLSConfig{KeyFile: keyFile}, true},
+		{"证书文件不存在", &TLSConfig{CertFile: filepath.Join(dir, "missing.pem"), KeyFile: keyFile}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := BuildTLSConfig(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BuildTLSConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && len(got.Certificates) != 1 {
+				t.Errorf("Certificates = %d, want 1", len(got.Certificates))
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package command
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// AllowlistKind 白名单条目的匹配方式
+type AllowlistKind int
+
+const (
+	// AllowExact 命令与条目完全一致
+	AllowExact AllowlistKind = iota
+	// AllowGlob 按 path.Match 语义匹配（* 与 ? 不匹配 /）
+	AllowGlob
+	// AllowPrefix 命令以条目开头
+	AllowPrefix
+)
+
+// String 返回匹配方式的名称
+func (k AllowlistKind) String() string {
+	switch k {
+	case AllowGlob:
+		return "glob"
+	case AllowPrefix:
+		return "prefix"
+	default:
+		return "exact"
+	}
+}
+
+// AllowlistEntry 命令白名单条目
+type AllowlistEntry struct {
+	Kind    AllowlistKind
+	Pattern string
+}
+
+// ParseAllowlistEntry 解析白名单条目并自动识别匹配方式：
+// 以空格结尾为前缀匹配（如 "systemctl reload "），含 * 或 ? 为 glob（如 "/usr/bin/systemctl reload *"），其余为完全匹配
+func ParseAllowlistEntry(s string) (AllowlistEntry, error) {
+	if strings.TrimSpace(s) == "" {
+		return AllowlistEntry{}, fmt.Errorf("白名单条目为空")
+	}
+	switch {
+	case strings.HasSuffix(s, " "):
+		return AllowlistEntry{Kind: AllowPrefix, Pattern: strings.TrimLeft(s, " ")}, nil
+	case strings.ContainsAny(s, "*?"):
+		pattern := strings.TrimSpace(s)
+		if _, err := path.Match(pattern, ""); err != nil {
+			return AllowlistEntry{}, fmt.Errorf("白名单条目 %q 不是有效的 glob: %w", s, err)
+		}
+		return AllowlistEntry{Kind: AllowGlob, Pattern: pattern}, nil
+	default:
+		return AllowlistEntry{Kind: AllowExact, Pattern: strings.TrimSpace(s)}, nil
+	}
+}
+
+// Match 判断命令是否匹配条目，命令首尾空白被忽略
+func (e AllowlistEntry) Match(cmd string) bool {
+	cmd = strings.TrimSpace(cmd)
+	switch e.Kind {
+	case AllowGlob:
+		ok, _ := path.Match(e.Pattern, cmd)
+		return ok
+	case AllowPrefix:
+		return strings.HasPrefix(cmd, e.Pattern)
+	default:
+		return cmd == e.Pattern
+	}
+}
+
+// Allowlist 命令白名单，用于限制可执行的重载命令
+// 未配置任何条目时不限制
+type Allowlist struct {
+	entries []AllowlistEntry
+}
+
+// NewAllowlist 按 ParseAllowlistEntry 的规则解析全部条目
+func NewAllowlist(patterns []string) (*Allowlist, error) {
+	a := &Allowlist{entries: make([]AllowlistEntry, 0, len(patterns))}
+	for _, p := range patterns {
+		entry, err := ParseAllowlistEntry(p)
+		if err != nil {
+			return nil, err
+		}
+		a.entries = append(a.entries, entry)
+	}
+	return a, nil
+}
+
+// IsAllowed 判断命令是否匹配任一条目；白名单为 nil 或为空时总是允许
+func (a *Allowlist) IsAllowed(cmd string) bool {
+	if a == nil || len(a.entries) == 0 {
+		return true
+	}
+	for _, e := range a.entries {
+		if e.Match(cmd) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check 命令不在白名单中时返回包装 ErrNotAllowed 的错误
+func (a *Allowlist) Check(cmd string) error {
+	if !a.IsAllowed(cmd) {
+		return fmt.Errorf("%w: %q", ErrNotAllowed, cmd)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package command
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseAllowlistEntry(t *testing.T) {
+	tests := []struct {
+		in          string
+		wantKind    AllowlistKind
+		wantPattern string
+		wantErr     bool
+	}{
+		{"systemctl reload nginx", AllowExact, "systemctl reload nginx", false},
+		{"/usr/bin/systemctl reload *", AllowGlob, "/usr/bin/systemctl reload *", false},
+		{"nginx -s reload?", AllowGlob, "nginx -s reload?", false},
+		{"systemctl reload ", AllowPrefix, "systemctl reload ", false},
+		{"systemctl restart * ", AllowPrefix, "systemctl restart * ", false},
+		{"", AllowExact, "", true},
+		{"   ", AllowExact, "", true},
+		{"systemctl reload [nginx", AllowExact, "", false},
+		{"systemctl reload [*", AllowGlob, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseAllowlistEntry(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAllowlistEntry(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Kind != tt.wantKind {
+				t.Errorf("ParseAllowlistEntry(%q).Kind = %v, want %v", tt.in, got.Kind, tt.wantKind)
+			}
+			if tt.wantPattern != "" && got.Pattern != tt.wantPattern {
+				t.Errorf("ParseAllowlistEntry(%q).Pattern = %q, want %q", tt.in, got.Pattern, tt.wantPattern)
+			}
+		})
+	}
+}
+
+func TestAllowlist_IsAllowed(t *testing.T) {
+	allowlist, err := NewAllowlist([]string{
+		"nginx -s reload",
+		"/usr/bin/systemctl reload *",
+		"/opt/app/bin/reload ",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		cmd  string
+		want bool
+	}{
+		{"完全匹配", "nginx -s reload", true},
+		{"完全匹配忽略首尾空白", "  nginx -s reload\n", true},
+		{"完全匹配不接受多余参数", "nginx -s reload -c /tmp/evil.conf", false},
+		{"glob 匹配", "/usr/bin/systemctl reload nginx", true},
+		{"glob 的 * 不匹配 /", "/usr/bin/systemctl reload /etc/evil", false},
+		{"glob 不匹配其他子命令", "/usr/bin/systemctl stop nginx", false},
+		{"前缀匹配", "/opt/app/bin/reload --graceful --timeout 30", true},
+		{"前缀不匹配相似命令", "/opt/app/bin/reloader", false},
+		{"不匹配任何条目", "rm -rf /var/www", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := allowlist.IsAllowed(tt.cmd); got != tt.want {
+				t.Errorf("IsAllowed(%q) = %v, want %v", tt.cmd, got, tt.want)
+			}
+			err := allowlist.Check(tt.cmd)
+			if tt.want != (err == nil) || (err != nil && !errors.Is(err, ErrNotAllowed)) {
+				t.Errorf("Check(%q) = %v", tt.cmd, err)
+			}
+		})
+	}
+}
+
+func TestAllowlist_Empty(t *testing.T) {
+	var nilList *Allowlist
+	empty, err := NewAllowlist(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range []*Allowlist{nilList, empty} {
+		if !a.IsAllowed("any command") {
+			t.Error("未配置白名单时应允许所有命令")
+		}
+	}
+	if _, err := NewAllowlist([]string{"ok", "bad [*"}); err == nil {
+		t.Error("包含无效 glob 时 NewAllowlist 应返回错误")
+	}
+}
@@ -0,0 +1,47 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 命令执行的哨兵错误，调用方可使用 errors.Is 判断
+var (
+	// ErrParse 命令解析失败（含不安全字符、引号不匹配、空命令等）
+	ErrParse = errors.New("命令解析失败")
+	// ErrTimeout 命令执行超时
+	ErrTimeout = errors.New("命令执行超时")
+	// ErrNotAllowed 命令不在白名单（allowed_reload_cmds）中
+	ErrNotAllowed = errors.New("命令不在白名单中")
+)
+
+// ExitError 命令以非零退出码结束
+// 调用方可使用 errors.As 获取退出码和输出
+type ExitError struct {
+	// Code 进程退出码
+	Code int
+	// Output 命令输出：Execute 为 stdout + stderr，ExecuteCaptured 为 stderr，ExecuteWithStdio 与 ExecuteStreaming 时为空
+	Output string
+	// Err 原始错误（*exec.ExitError）
+	Err error
+}
+
+// Error 实现 error 接口
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("命令退出码 %d", e.Code)
+}
+
+// Unwrap 返回原始错误
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode 从错误中提取命令退出码
+// 错误不是 ExitError 时返回 -1, false
+func ExitCode(err error) (int, bool) {
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code, true
+	}
+	return -1, false
+}
@@ -2,13 +2,26 @@
 package command
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"time"
 )
 
+// 流式执行的限制
+const (
+	// maxStreamLineSize 单行输出上限，超出后丢弃剩余输出
+	maxStreamLineSize = 1024 * 1024
+	// streamWaitDelay 进程退出（或被终止）后等待输出管道关闭的时间，
+	// 防止后台子进程继承管道导致 ExecuteStreaming 无法返回
+	streamWaitDelay = 2 * time.Second
+)
+
 // Execute 安全执行命令
 // 使用 Parse 解析命令，避免 shell 注入风险
 // 包含超时保护，防止命令阻塞
@@ -20,11 +33,12 @@ import (
 //
 // 返回:
 //   - output: 命令输出（stdout + stderr）
-//   - error: 执行错误
+//   - error: 执行错误，可通过 errors.Is(err, ErrParse/ErrTimeout)
+//     或 errors.As(err, *ExitError) 区分错误类型
 func Execute(ctx context.Context, cmd string, timeout time.Duration) (string, error) {
 	cmdBin, args, err := Parse(cmd)
 	if err != nil {
-		return "", fmt.Errorf("命令解析失败: %w", err)
+		return "", fmt.Errorf("%w: %v", ErrParse, err)
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, timeout)
@@ -33,15 +47,38 @@ func Execute(ctx context.Context, cmd string, timeout time.Duration) (string, er
 	execCmd := exec.CommandContext(ctx, cmdBin, args...)
 	output, err := execCmd.CombinedOutput()
 
-	if ctx.Err() == context.DeadlineExceeded {
-		return string(output), fmt.Errorf("命令执行超时 (%v)", timeout)
-	}
+	return string(output), wrapRunError(ctx, err, string(output), timeout)
+}
 
+// ExecuteCaptured 安全执行命令，分别捕获 stdout 和 stderr
+// 适用于需要区分正常输出与警告/错误输出的场景（如部署后的 reload 命令）
+//
+// 参数:
+//   - ctx: 上下文，用于取消控制
+//   - cmd: 命令字符串
+//   - timeout: 执行超时时间
+//
+// 返回:
+//   - stdout: 标准输出
+//   - stderr: 标准错误输出
+//   - error: 执行错误，类型同 Execute（ExitError.Output 为 stderr）
+func ExecuteCaptured(ctx context.Context, cmd string, timeout time.Duration) (string, string, error) {
+	cmdBin, args, err := Parse(cmd)
 	if err != nil {
-		return string(output), fmt.Errorf("命令执行失败: %w", err)
+		return "", "", fmt.Errorf("%w: %v", ErrParse, err)
 	}
 
-	return string(output), nil
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	execCmd := exec.CommandContext(ctx, cmdBin, args...)
+	execCmd.Stdout = &stdout
+	execCmd.Stderr = &stderr
+
+	err = execCmd.Run()
+
+	return stdout.String(), stderr.String(), wrapRunError(ctx, err, stderr.String(), timeout)
 }
 
 // ExecuteWithStdio 执行命令并将输出直接写入 stdout/stderr
@@ -53,11 +90,11 @@ func Execute(ctx context.Context, cmd string, timeout time.Duration) (string, er
 //   - timeout: 执行超时时间
 //
 // 返回:
-//   - error: 执行错误
+//   - error: 执行错误，类型同 Execute
 func ExecuteWithStdio(ctx context.Context, cmd string, timeout time.Duration) error {
 	cmdBin, args, err := Parse(cmd)
 	if err != nil {
-		return fmt.Errorf("命令解析失败: %w", err)
+		return fmt.Errorf("%w: %v", ErrParse, err)
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, timeout)
@@ -69,9 +106,79 @@ func ExecuteWithStdio(ctx context.Context, cmd string, timeout time.Duration) er
 
 	err = execCmd.Run()
 
+	return wrapRunError(ctx, err, "", timeout)
+}
+
+// ExecuteStreaming 执行命令，逐行将 stdout 和 stderr 的输出传给 onLine
+// 适用于输出进度的长时间命令（如平滑重启），输出在命令运行期间实时回调
+// onLine 在返回前串行调用完毕；超时或 ctx 取消时终止子进程
+//
+// 参数:
+//   - ctx: 上下文，用于取消控制
+//   - cmd: 命令字符串
+//   - timeout: 执行超时时间
+//   - onLine: 每行输出的回调（不含换行符），为 nil 时丢弃输出
+//
+// 返回:
+//   - error: 执行错误，类型同 Execute（ExitError.Output 为空）
+func ExecuteStreaming(ctx context.Context, cmd string, timeout time.Duration, onLine func(line string)) error {
+	cmdBin, args, err := Parse(cmd)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrParse, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	pr, pw := io.Pipe()
+	execCmd := exec.CommandContext(ctx, cmdBin, args...)
+	execCmd.Stdout = pw
+	execCmd.Stderr = pw
+	execCmd.WaitDelay = streamWaitDelay
+
+	if err := execCmd.Start(); err != nil {
+		pw.Close()
+		return wrapRunError(ctx, err, "", timeout)
+	}
+
+	waitErr := make(chan error, 1)
+	go func() {
+		err := execCmd.Wait()
+		pw.Close()
+		waitErr <- err
+	}()
+
+	scanner := bufio.NewScanner(pr)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLineSize)
+	for scanner.Scan() {
+		if onLine != nil {
+			onLine(scanner.Text())
+		}
+	}
+	// 行过长时 Scanner 提前结束，丢弃剩余输出以免子进程写管道阻塞
+	io.Copy(io.Discard, pr)
+
+	return wrapRunError(ctx, <-waitErr, "", timeout)
+}
+
+// wrapRunError 将命令运行结果转换为带类型的错误
+func wrapRunError(ctx context.Context, err error, output string, timeout time.Duration) error {
 	if ctx.Err() == context.DeadlineExceeded {
-		return fmt.Errorf("命令执行超时 (%v)", timeout)
+		return fmt.Errorf("%w (%v)", ErrTimeout, timeout)
+	}
+
+	if err == nil {
+		return nil
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return fmt.Errorf("命令执行失败: %w", &ExitError{
+			Code:   exitErr.ExitCode(),
+			Output: output,
+			Err:    exitErr,
+		})
 	}
 
-	return err
+	return fmt.Errorf("命令执行失败: %w", err)
 }
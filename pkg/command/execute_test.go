@@ -0,0 +1,249 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecuteTypedErrors(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("需要 sh")
+	}
+
+	tests := []struct {
+		name     string
+		cmd      string
+		timeout  time.Duration
+		wantErr  error
+		wantExit int // -1 表示不应为 ExitError
+	}{
+		{"success", "sh -c 'exit 0'", 5 * time.Second, nil, -1},
+		{"bad parse", "echo hello; rm -rf /", 5 * time.Second, ErrParse, -1},
+		{"unbalanced quote", `echo "hello`, 5 * time.Second, ErrParse, -1},
+		{"timeout", "sleep 5", 100 * time.Millisecond, ErrTimeout, -1},
+		{"non-zero exit", "sh -c 'echo boom\nexit 3'", 5 * time.Second, nil, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := Execute(context.Background(), tt.cmd, tt.timeout)
+
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Execute() error = %v, want %v", err, tt.wantErr)
+			}
+
+			code, isExit := ExitCode(err)
+			if tt.wantExit < 0 {
+				if isExit {
+					t.Errorf("Execute() 不应返回 ExitError, got code %d", code)
+				}
+				if tt.wantErr == nil && err != nil {
+					t.Errorf("Execute() unexpected error = %v", err)
+				}
+				return
+			}
+
+			var exitErr *ExitError
+			if !errors.As(err, &exitErr) {
+				t.Fatalf("Execute() error = %v, want *ExitError", err)
+			}
+			if exitErr.Code != tt.wantExit {
+				t.Errorf("ExitError.Code = %d, want %d", exitErr.Code, tt.wantExit)
+			}
+			if !strings.Contains(exitErr.Output, "boom") || !strings.Contains(output, "boom") {
+				t.Errorf("ExitError.Output = %q, output = %q, want contains boom", exitErr.Output, output)
+			}
+			if errors.Is(err, ErrTimeout) || errors.Is(err, ErrParse) {
+				t.Errorf("非零退出不应匹配 ErrTimeout/ErrParse: %v", err)
+			}
+		})
+	}
+}
+
+func TestExecuteWithStdioTypedErrors(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("需要 sh")
+	}
+
+	if err := ExecuteWithStdio(context.Background(), "", time.Second); !errors.Is(err, ErrParse) {
+		t.Errorf("空命令 error = %v, want ErrParse", err)
+	}
+	if err := ExecuteWithStdio(context.Background(), "sleep 5", 100*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Errorf("超时 error = %v, want ErrTimeout", err)
+	}
+	if code, ok := ExitCode(ExecuteWithStdio(context.Background(), "sh -c 'exit 7'", 5*time.Second)); !ok || code != 7 {
+		t.Errorf("ExitCode = %d, %v, want 7, true", code, ok)
+	}
+}
+
+func TestExecuteStreaming(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("需要 sh")
+	}
+
+	tests := []struct {
+		name      string
+		cmd       string
+		timeout   time.Duration
+		wantLines []string
+		wantErr   error
+		wantExit  int // -1 表示不应为 ExitError
+	}{
+		{"多行输出", "sh -c 'echo one\necho two\nprintf three'", 5 * time.Second, []string{"one", "two", "three"}, nil, -1},
+		{"非零退出", "sh -c 'echo boom\nexit 5'", 5 * time.Second, []string{"boom"}, nil, 5},
+		{"超时", "sh -c 'echo started\nsleep 5'", 200 * time.Millisecond, []string{"started"}, ErrTimeout, -1},
+		{"解析失败", "echo a; echo b", 5 * time.Second, nil, ErrParse, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []string
+			err := ExecuteStreaming(context.Background(), tt.cmd, tt.timeout, func(line string) {
+				lines = append(lines, line)
+			})
+
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ExecuteStreaming() error = %v, want %v", err, tt.wantErr)
+			}
+			if code, ok := ExitCode(err); tt.wantExit >= 0 && (!ok || code != tt.wantExit) {
+				t.Errorf("ExitCode = %d, %v, want %d", code, ok, tt.wantExit)
+			}
+			if tt.wantErr == nil && tt.wantExit < 0 && err != nil {
+				t.Errorf("ExecuteStreaming() unexpected error = %v", err)
+			}
+			// stdout 与 stderr 交错顺序不确定，只比较集合
+			if strings.Join(sortedCopy(lines), "\n") != strings.Join(sortedCopy(tt.wantLines), "\n") {
+				t.Errorf("lines = %q, want %q", lines, tt.wantLines)
+			}
+		})
+	}
+}
+
+func TestExecuteStreamingStderr(t *testing.T) {
+	t.Setenv("GO_WANT_HELPER_PROCESS", "1")
+	t.Setenv("HELPER_LINE_SEPARATOR", "\n")
+	helper := os.Args[0] + " -test.run=^TestHelperProcess$"
+
+	var lines []string
+	if err := ExecuteStreaming(context.Background(), helper, 10*time.Second, func(line string) {
+		lines = append(lines, line)
+	}); err != nil {
+		t.Fatalf("ExecuteStreaming() error = %v", err)
+	}
+	if got := strings.Join(sortedCopy(lines), ","); got != "to-stderr,to-stdout" {
+		t.Errorf("lines = %q, want stdout 与 stderr 各一行", lines)
+	}
+}
+
+func TestExecuteStreamingLive(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("需要 sh")
+	}
+
+	// 第一行应在命令结束前回调
+	start := time.Now()
+	var first time.Duration
+	err := ExecuteStreaming(context.Background(), "sh -c 'echo first\nsleep 1\necho second'", 5*time.Second, func(line string) {
+		if line == "first" {
+			first = time.Since(start)
+		}
+	})
+	if err != nil {
+		t.Fatalf("ExecuteStreaming() error = %v", err)
+	}
+	if first == 0 || first >= time.Second {
+		t.Errorf("第一行在 %v 后才回调，应在命令结束前实时输出", first)
+	}
+}
+
+func TestExecuteStreamingCancel(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("需要 sh")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := ExecuteStreaming(ctx, "sleep 5", 10*time.Second, nil)
+	if err == nil {
+		t.Fatal("取消后应返回错误")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("取消后 %v 才返回，子进程未被及时终止", elapsed)
+	}
+}
+
+func sortedCopy(s []string) []string {
+	c := append([]string(nil), s...)
+	sort.Strings(c)
+	return c
+}
+
+// TestHelperProcess 供 ExecuteCaptured 测试调用的辅助进程
+// 通过环境变量 GO_WANT_HELPER_PROCESS 启用，分别向 stdout/stderr 写入内容，
+// HELPER_LINE_SEPARATOR 追加在每段输出之后
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	sep := os.Getenv("HELPER_LINE_SEPARATOR")
+	fmt.Fprint(os.Stdout, "to-stdout"+sep)
+	fmt.Fprint(os.Stderr, "to-stderr"+sep)
+	code, _ := strconv.Atoi(os.Getenv("HELPER_EXIT_CODE"))
+	os.Exit(code)
+}
+
+func TestExecuteCaptured(t *testing.T) {
+	t.Setenv("GO_WANT_HELPER_PROCESS", "1")
+	helper := os.Args[0] + " -test.run=^TestHelperProcess$"
+
+	tests := []struct {
+		name     string
+		exitCode string
+		wantExit int // -1 表示成功
+	}{
+		{"success", "0", -1},
+		{"non-zero exit", "4", 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HELPER_EXIT_CODE", tt.exitCode)
+
+			stdout, stderr, err := ExecuteCaptured(context.Background(), helper, 10*time.Second)
+			if stdout != "to-stdout" {
+				t.Errorf("stdout = %q, want %q", stdout, "to-stdout")
+			}
+			if stderr != "to-stderr" {
+				t.Errorf("stderr = %q, want %q", stderr, "to-stderr")
+			}
+
+			if tt.wantExit < 0 {
+				if err != nil {
+					t.Errorf("ExecuteCaptured() error = %v", err)
+				}
+				return
+			}
+			var exitErr *ExitError
+			if !errors.As(err, &exitErr) {
+				t.Fatalf("ExecuteCaptured() error = %v, want *ExitError", err)
+			}
+			if exitErr.Code != tt.wantExit || exitErr.Output != "to-stderr" {
+				t.Errorf("ExitError = {Code: %d, Output: %q}, want {%d, %q}", exitErr.Code, exitErr.Output, tt.wantExit, "to-stderr")
+			}
+		})
+	}
+
+	if _, _, err := ExecuteCaptured(context.Background(), "echo a; echo b", time.Second); !errors.Is(err, ErrParse) {
+		t.Errorf("不安全命令 error = %v, want ErrParse", err)
+	}
+}
@@ -8,8 +8,8 @@ import (
 	"github.com/google/shlex"
 )
 
-// validateCommand 验证命令是否安全
-func validateCommand(cmd string) error {
+// ValidateCommand 验证命令是否安全，Parse 在解析前调用，配置校验也据此提前检查重载命令
+func ValidateCommand(cmd string) error {
 	// 检查危险字符和模式
 	dangerousPatterns := []string{
 		";",  // 命令分隔符
@@ -43,7 +43,7 @@ func validateCommand(cmd string) error {
 // 使用 github.com/google/shlex 进行词法分析，正确处理单引号、双引号和转义字符。
 // 该函数首先验证命令安全性，拒绝包含 shell 特殊字符的命令。
 func Parse(cmd string) (string, []string, error) {
-	if err := validateCommand(cmd); err != nil {
+	if err := ValidateCommand(cmd); err != nil {
 		return "", nil, err
 	}
 
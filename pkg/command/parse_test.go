@@ -28,9 +28,9 @@ func TestValidateCommand(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := validateCommand(tt.cmd)
+			err := ValidateCommand(tt.cmd)
 			if (err != nil) != tt.wantError {
-				t.Errorf("validateCommand() error = %v, wantError %v", err, tt.wantError)
+				t.Errorf("ValidateCommand() error = %v, wantError %v", err, tt.wantError)
 			}
 		})
 	}
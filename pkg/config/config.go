@@ -1,18 +1,23 @@
 package config
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
-	"github.com/fsnotify/fsnotify"
+	"github.com/Catker/acmeDeliver/pkg/cert"
+	"github.com/Catker/acmeDeliver/pkg/command"
+	"github.com/Catker/acmeDeliver/pkg/domainmatch"
 	"github.com/google/uuid"
-	"gopkg.in/yaml.v3"
 )
 
 // 环境变量辅助函数
@@ -43,32 +48,80 @@ func getEnvBool(key string, fallback bool) bool {
 
 // Config 配置结构
 type Config struct {
-	Port        string        `yaml:"port"`
-	Bind        string        `yaml:"bind"`
-	BaseDir     string        `yaml:"base_dir"`
-	Key         string        `yaml:"key"`
-	TLS         bool          `yaml:"tls"`
-	TLSPort     string        `yaml:"tls_port"`
-	CertFile    string        `yaml:"cert_file"`
-	KeyFile     string        `yaml:"key_file"`
-	IPWhitelist string        `yaml:"ip_whitelist"`     // IP白名单，逗号分隔（支持热重载）
-	TrustProxy  bool          `yaml:"trust_proxy"`      // 是否信任代理头 X-Forwarded-For/X-Real-IP（支持热重载）
-	ConfigFile  string        `yaml:"-"`                // 配置文件路径
-	Client      *ClientConfig `yaml:"client,omitempty"` // 客户端配置（可选）
+	Port     string   `yaml:"port" json:"port" toml:"port"`
+	Bind     string   `yaml:"bind" json:"bind" toml:"bind"`
+	BaseDir  string   `yaml:"base_dir" json:"base_dir" toml:"base_dir"`
+	BaseDirs []string `yaml:"base_dirs,omitempty" json:"base_dirs,omitempty" toml:"base_dirs,omitempty"` // 证书目录列表，按顺序查找域名，同名域名以靠前的目录为准；设置后忽略 base_dir
+	Key      string   `yaml:"key" json:"key" toml:"key"`
+	TLS      bool     `yaml:"tls" json:"tls" toml:"tls"`
+	TLSPort  string   `yaml:"tls_port" json:"tls_port" toml:"tls_port"`
+	CertFile string   `yaml:"cert_file" json:"cert_file" toml:"cert_file"`
+	KeyFile  string   `yaml:"key_file" json:"key_file" toml:"key_file"`
+	// 客户端证书 CA（mTLS）：设置后 TLS 端口要求客户端出示由该 CA 签发的证书，在签名认证之外额外校验
+	ClientCAFile string   `yaml:"client_ca_file,omitempty" json:"client_ca_file,omitempty" toml:"client_ca_file,omitempty"`
+	IPWhitelist  string   `yaml:"ip_whitelist" json:"ip_whitelist" toml:"ip_whitelist"`                // IP白名单，逗号分隔（支持热重载）
+	TrustProxy   bool     `yaml:"trust_proxy" json:"trust_proxy" toml:"trust_proxy"`                   // 是否信任代理头 X-Forwarded-For/X-Real-IP（支持热重载）
+	TimeRange    int      `yaml:"time_range" json:"time_range,omitempty" toml:"time_range,omitzero"`   // 已废弃：签名时间窗口固定为 30 秒，仅为兼容旧配置文件保留
+	ExpandEnv    bool     `yaml:"expand_env" json:"expand_env,omitempty" toml:"expand_env,omitzero"`   // 加载时展开配置值中的 ${VAR} / ${VAR:-default}
+	Include      []string `yaml:"include,omitempty" json:"include,omitempty" toml:"include,omitempty"` // 合并的其他配置文件（glob，相对于当前文件）
+	// 重复客户端 ID 处理策略：evict（默认，踢出旧连接）/ reject（拒绝新连接）/ allow（仅告警）
+	DuplicatePolicy string `yaml:"duplicate_policy,omitempty" json:"duplicate_policy,omitempty" toml:"duplicate_policy,omitempty"`
+	// 是否允许客户端订阅 "*"、"**." 多级通配符与 "*.com" 等顶级域名通配符，默认拒绝（支持热重载）
+	AllowWildcardSubscribe bool `yaml:"allow_wildcard_subscribe,omitempty" json:"allow_wildcard_subscribe,omitempty" toml:"allow_wildcard_subscribe,omitzero"`
+	// 拒绝旧版本客户端的 sha256(password + timestamp) 签名，只接受 HMAC-SHA256 签名（支持热重载）
+	DisableLegacyAuth bool `yaml:"disable_legacy_auth,omitempty" json:"disable_legacy_auth,omitempty" toml:"disable_legacy_auth,omitzero"`
+	// WebSocket 端点路径，默认 /ws，反向代理挂载在子路径时修改（需重启）
+	WSPath string `yaml:"ws_path,omitempty" json:"ws_path,omitempty" toml:"ws_path,omitempty"`
+	// WebSocket permessage-deflate 压缩（证书 JSON/base64 载荷压缩率较高）
+	WSCompression      bool `yaml:"ws_compression" json:"ws_compression" toml:"ws_compression"`
+	WSCompressionLevel int  `yaml:"ws_compression_level,omitempty" json:"ws_compression_level,omitempty" toml:"ws_compression_level,omitzero"` // 压缩级别 -2~9，0 表示默认
+	// 最长可接受的客户端静默时间（秒），超时视为连接失效并断开，默认 90
+	PongTimeout int `yaml:"pong_timeout,omitempty" json:"pong_timeout,omitempty" toml:"pong_timeout,omitzero"`
+	// 单条 WebSocket 消息的大小上限（字节），默认 10485760（10 MB），超过时以 close 1009 断开（对新连接生效）
+	MaxMessageSize int `yaml:"max_message_size,omitempty" json:"max_message_size,omitempty" toml:"max_message_size,omitzero"`
+	// 定时巡检证书目录的间隔（秒），对 time.log 更新但未推送过的域名补推，0 表示禁用
+	ProactivePushInterval int `yaml:"proactive_push_interval,omitempty" json:"proactive_push_interval,omitempty" toml:"proactive_push_interval,omitzero"`
+	// 清理任务的执行间隔（小时），删除证书目录中超过 cleanup_archive_days 的归档域名目录，0 表示禁用
+	CleanupIntervalHours int `yaml:"cleanup_interval_hours,omitempty" json:"cleanup_interval_hours,omitempty" toml:"cleanup_interval_hours,omitzero"`
+	// 归档域名目录（<base_dir>/.archive/）的保留天数，默认 30
+	CleanupArchiveDays int `yaml:"cleanup_archive_days,omitempty" json:"cleanup_archive_days,omitempty" toml:"cleanup_archive_days,omitzero"`
+	// 仍在使用的域名列表，配置后清理任务将证书目录中其他域名目录移动到归档目录；为空时不归档任何域名（支持热重载）
+	ActiveDomains []string `yaml:"active_domains,omitempty" json:"active_domains,omitempty" toml:"active_domains,omitempty"`
+	// 证书目录监控防抖时间（秒），默认 5（支持热重载）
+	WatchDebounce int `yaml:"watch_debounce,omitempty" json:"watch_debounce,omitempty" toml:"watch_debounce,omitzero"`
+	// 密钥轮换时等待客户端用新密钥重新认证的最长时间（秒），默认 60（支持热重载）
+	KeyRotationWindow int `yaml:"key_rotation_window,omitempty" json:"key_rotation_window,omitempty" toml:"key_rotation_window,omitzero"`
+	// 单个上传证书文件的大小上限（字节），默认 102400（支持热重载）
+	MaxCertSizeBytes int `yaml:"max_cert_size_bytes,omitempty" json:"max_cert_size_bytes,omitempty" toml:"max_cert_size_bytes,omitzero"`
+	// 上传证书写入域名目录时 fsync 文件及所在目录，防止断电后留下空文件（支持热重载）
+	SyncOnWrite bool `yaml:"sync_on_write,omitempty" json:"sync_on_write,omitempty" toml:"sync_on_write,omitzero"`
+	// 状态查询 CRL 检查的缓存有效期（秒），默认 3600（支持热重载）
+	CRLCacheTTL int `yaml:"crl_cache_ttl,omitempty" json:"crl_cache_ttl,omitempty" toml:"crl_cache_ttl,omitzero"`
+	// 证书推送的出站速率上限（字节/秒），所有客户端共享，0 表示不限速（支持热重载）
+	PushBytesPerSec int `yaml:"push_bytes_per_sec,omitempty" json:"push_bytes_per_sec,omitempty" toml:"push_bytes_per_sec,omitzero"`
+	// 管理接口令牌（Authorization: Bearer），与证书访问密钥 key 分开，为空时禁用管理接口（支持热重载）
+	AdminToken string `yaml:"admin_token,omitempty" json:"admin_token,omitempty" toml:"admin_token,omitempty"`
+	// Redis 地址，设置后经发布/订阅在多个服务端实例间转发证书推送（需重启）
+	RedisURL string `yaml:"redis_url,omitempty" json:"redis_url,omitempty" toml:"redis_url,omitempty"`
+	// 证书目录中的文件名（支持 {domain} 占位符），未设置的使用 cert.pem / key.pem / fullchain.pem / time.log（支持热重载）
+	CertFileNames cert.FileNames `yaml:"cert_filenames,omitempty" json:"cert_filenames,omitempty" toml:"cert_filenames,omitempty"`
+	// 日志配置（level 支持热重载，其余需重启）
+	Logging    LoggingConfig `yaml:"logging,omitempty" json:"logging,omitempty" toml:"logging,omitempty"`
+	ConfigFile string        `yaml:"-" json:"-" toml:"-"`                                              // 配置文件路径
+	Client     *ClientConfig `yaml:"client,omitempty" json:"client,omitempty" toml:"client,omitempty"` // 客户端配置（可选）
 }
 
 var (
 	GlobalConfig    *Config
 	mu              sync.RWMutex
 	reloadCallbacks []func(*Config)
+	// fileConfig 最近一次从配置文件加载的内容（默认值 + 文件），重载时据此判断哪些字段发生变化
+	fileConfig *Config
 )
 
-// InitConfig 初始化服务端配置
-// 优先级：命令行 > 环境变量 > 配置文件 > 默认值
-// 返回错误时调用方应自行处理（如 os.Exit）
-func InitConfig() error {
-	cfg := &Config{
-		// 默认值
+// defaultConfig 返回服务端默认配置
+func defaultConfig() *Config {
+	return &Config{
 		Port:     "9090",
 		Bind:     "",
 		BaseDir:  "./",
@@ -78,6 +131,13 @@ func InitConfig() error {
 		CertFile: "cert.pem",
 		KeyFile:  "key.pem",
 	}
+}
+
+// InitConfig 初始化服务端配置
+// 优先级：命令行 > 环境变量 > 配置文件 > 默认值
+// 返回错误时调用方应自行处理（如 os.Exit）；指定 --print-config 时输出合并后的配置（已隐藏敏感值）并返回 ErrConfigPrinted
+func InitConfig() error {
+	cfg := defaultConfig()
 
 	// 1. 先解析 -c 参数以获取配置文件路径
 	flag.StringVar(&cfg.ConfigFile, "c", "", "配置文件路径")
@@ -90,7 +150,10 @@ func InitConfig() error {
 	flag.StringVar(&cfg.CertFile, "cert", cfg.CertFile, "TLS证书文件")
 	flag.StringVar(&cfg.KeyFile, "key", cfg.KeyFile, "TLS私钥文件")
 	flag.StringVar(&cfg.IPWhitelist, "whitelist", cfg.IPWhitelist, "IP白名单（逗号分隔，支持CIDR）")
+	lax := flag.Bool("lax-config", false, "宽松模式：忽略配置文件中的未知字段")
+	printConfig := flag.Bool("print-config", false, "以 YAML 输出合并配置文件、环境变量与命令行后的有效配置（隐藏密码）并退出")
 	flag.Parse()
+	SetLaxConfig(*lax)
 
 	// 命令行参数暂存
 	cliArgs := make(map[string]string)
@@ -111,6 +174,10 @@ func InitConfig() error {
 		if err := loadFromFile(cfg, cfg.ConfigFile); err != nil {
 			return fmt.Errorf("加载配置文件失败: %w", err)
 		}
+		snapshot := *cfg
+		mu.Lock()
+		fileConfig = &snapshot
+		mu.Unlock()
 		slog.Info("已加载配置文件", "file", cfg.ConfigFile)
 	}
 
@@ -118,13 +185,39 @@ func InitConfig() error {
 	cfg.Port = getEnvStr("ACMEDELIVER_PORT", cfg.Port)
 	cfg.Bind = getEnvStr("ACMEDELIVER_BIND", cfg.Bind)
 	cfg.BaseDir = getEnvStr("ACMEDELIVER_BASE_DIR", cfg.BaseDir)
+	if dirsEnv := getEnvStr("ACMEDELIVER_BASE_DIRS", ""); dirsEnv != "" {
+		cfg.BaseDirs = strings.Split(dirsEnv, ",")
+	}
+	if domainsEnv := getEnvStr("ACMEDELIVER_ACTIVE_DOMAINS", ""); domainsEnv != "" {
+		cfg.ActiveDomains = strings.Split(domainsEnv, ",")
+	}
 	cfg.Key = getEnvStr("ACMEDELIVER_KEY", cfg.Key)
 	cfg.TLS = getEnvBool("ACMEDELIVER_TLS", cfg.TLS)
 	cfg.TLSPort = getEnvStr("ACMEDELIVER_TLS_PORT", cfg.TLSPort)
 	cfg.CertFile = getEnvStr("ACMEDELIVER_CERT_FILE", cfg.CertFile)
 	cfg.KeyFile = getEnvStr("ACMEDELIVER_KEY_FILE", cfg.KeyFile)
+	cfg.ClientCAFile = getEnvStr("ACMEDELIVER_CLIENT_CA_FILE", cfg.ClientCAFile)
 	cfg.IPWhitelist = getEnvStr("ACMEDELIVER_IP_WHITELIST", cfg.IPWhitelist)
 	cfg.TrustProxy = getEnvBool("ACMEDELIVER_TRUST_PROXY", cfg.TrustProxy)
+	cfg.DuplicatePolicy = getEnvStr("ACMEDELIVER_DUPLICATE_POLICY", cfg.DuplicatePolicy)
+	cfg.AllowWildcardSubscribe = getEnvBool("ACMEDELIVER_ALLOW_WILDCARD_SUBSCRIBE", cfg.AllowWildcardSubscribe)
+	cfg.DisableLegacyAuth = getEnvBool("ACMEDELIVER_DISABLE_LEGACY_AUTH", cfg.DisableLegacyAuth)
+	cfg.WSCompression = getEnvBool("ACMEDELIVER_WS_COMPRESSION", cfg.WSCompression)
+	cfg.PongTimeout = getEnvInt("ACMEDELIVER_PONG_TIMEOUT", cfg.PongTimeout)
+	cfg.MaxMessageSize = getEnvInt("ACMEDELIVER_MAX_MESSAGE_SIZE", cfg.MaxMessageSize)
+	cfg.ProactivePushInterval = getEnvInt("ACMEDELIVER_PROACTIVE_PUSH_INTERVAL", cfg.ProactivePushInterval)
+	cfg.CleanupIntervalHours = getEnvInt("ACMEDELIVER_CLEANUP_INTERVAL_HOURS", cfg.CleanupIntervalHours)
+	cfg.CleanupArchiveDays = getEnvInt("ACMEDELIVER_CLEANUP_ARCHIVE_DAYS", cfg.CleanupArchiveDays)
+	cfg.WatchDebounce = getEnvInt("ACMEDELIVER_WATCH_DEBOUNCE", cfg.WatchDebounce)
+	cfg.KeyRotationWindow = getEnvInt("ACMEDELIVER_KEY_ROTATION_WINDOW", cfg.KeyRotationWindow)
+	cfg.AdminToken = getEnvStr("ACMEDELIVER_ADMIN_TOKEN", cfg.AdminToken)
+	cfg.WSPath = getEnvStr("ACMEDELIVER_WS_PATH", cfg.WSPath)
+	cfg.RedisURL = getEnvStr("ACMEDELIVER_REDIS_URL", cfg.RedisURL)
+	cfg.MaxCertSizeBytes = getEnvInt("ACMEDELIVER_MAX_CERT_SIZE_BYTES", cfg.MaxCertSizeBytes)
+	cfg.SyncOnWrite = getEnvBool("ACMEDELIVER_SYNC_ON_WRITE", cfg.SyncOnWrite)
+	cfg.CRLCacheTTL = getEnvInt("ACMEDELIVER_CRL_CACHE_TTL", cfg.CRLCacheTTL)
+	cfg.PushBytesPerSec = getEnvInt("ACMEDELIVER_PUSH_BYTES_PER_SEC", cfg.PushBytesPerSec)
+	applyLoggingEnv(&cfg.Logging)
 
 	// 4. 命令行参数再次覆盖（最高优先级）
 	for name, value := range cliArgs {
@@ -152,6 +245,14 @@ func InitConfig() error {
 		}
 	}
 
+	// 输出有效配置：在自动生成密钥之前，未设置的 key 输出为空
+	if *printConfig {
+		if err := PrintConfig(printOutput, cfg.Redacted()); err != nil {
+			return fmt.Errorf("输出配置失败: %w", err)
+		}
+		return ErrConfigPrinted
+	}
+
 	// 设置密码：空密码时自动生成
 	if cfg.Key == "" {
 		cfg.Key = GenerateSecureKey()
@@ -174,80 +275,103 @@ func InitConfig() error {
 		go watchConfig(cfg.ConfigFile)
 	}
 
-	slog.Info("配置已加载", "port", cfg.Port, "baseDir", cfg.BaseDir)
+	slog.Info("配置已加载", "port", cfg.Port, "baseDirs", CertDirs(cfg))
 	return nil
 }
 
+// DefaultWSPath WebSocket 端点的默认路径
+const DefaultWSPath = "/ws"
+
+// NormalizeWSPath 规范化 WebSocket 端点路径：为空时返回 DefaultWSPath，补全开头的 /，去除末尾的 /
+func NormalizeWSPath(path string) string {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return DefaultWSPath
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if len(path) > 1 {
+		path = strings.TrimRight(path, "/")
+	}
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// ValidateWSPath 校验 ws_path：不能为根路径，也不能与 /healthz、/api/ 等内置路由冲突
+func ValidateWSPath(path string) error {
+	p := NormalizeWSPath(path)
+	if p == "/" || p == "/healthz" || p == "/api" || strings.HasPrefix(p, "/api/") {
+		return fmt.Errorf("ws_path 不能为 %q（与内置路由冲突）", p)
+	}
+	if strings.ContainsAny(p, "?# ") {
+		return fmt.Errorf("ws_path 只能包含路径，当前值: %q", path)
+	}
+	return nil
+}
+
+// CertDirs 返回证书目录列表：配置了 base_dirs 时按顺序返回其中的非空目录，否则只返回 base_dir
+func CertDirs(cfg *Config) []string {
+	var dirs []string
+	for _, dir := range cfg.BaseDirs {
+		if dir = strings.TrimSpace(dir); dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	if len(dirs) == 0 {
+		return []string{cfg.BaseDir}
+	}
+	return dirs
+}
+
 // loadFromFile 从文件加载配置
 func loadFromFile(cfg *Config, path string) error {
-	data, err := os.ReadFile(path)
+	data, format, _, err := readConfigTree(path)
 	if err != nil {
 		return err
 	}
-
-	return yaml.Unmarshal(data, cfg)
+	return decodeConfig(data, format, cfg)
 }
 
 // watchConfig 监听配置文件变化
 func watchConfig(path string) {
-	watcher, err := fsnotify.NewWatcher()
+	watcher, err := newConfigWatcher(path)
 	if err != nil {
 		slog.Warn("创建文件监听器失败", "error", err)
 		return
 	}
-	defer watcher.Close()
-
-	if err := watcher.Add(path); err != nil {
-		slog.Warn("监听配置文件失败", "error", err)
+	if len(watcher.dirs) == 0 {
+		watcher.watcher.Close()
 		return
 	}
 
-	slog.Info("🔄 配置文件热重载已启用", "path", path)
+	slog.Info("🔄 配置文件热重载已启用", "path", path, "files", len(watcher.files))
 
-	for {
-		select {
-		case event, ok := <-watcher.Events:
-			if !ok {
-				return
-			}
-			if event.Op&fsnotify.Write == fsnotify.Write {
-				slog.Info("📝 检测到配置文件变化，正在重新加载...", "file", event.Name)
-				reloadConfig(path)
-			}
-		case err, ok := <-watcher.Errors:
-			if !ok {
-				return
-			}
-			slog.Warn("文件监听错误", "error", err)
-		}
-	}
+	watcher.run(nil, func() { reloadConfig(path) })
 }
 
 // reloadConfig 重新加载配置
+// 仅应用配置文件中实际发生变化的字段，避免覆盖命令行/环境变量设置的值
 func reloadConfig(path string) {
-	newCfgFromFile := &Config{}
-	if err := loadFromFile(newCfgFromFile, path); err != nil {
+	newFileCfg := defaultConfig()
+	if err := loadFromFile(newFileCfg, path); err != nil {
 		slog.Error("❌ 配置文件重载失败", "error", err)
 		return
 	}
 
 	mu.Lock()
-	// 创建一个新配置的副本，以保留不可热重载的字段
-	newActiveCfg := *GlobalConfig
-
-	// 只更新支持热重载的配置项
-	newActiveCfg.IPWhitelist = newCfgFromFile.IPWhitelist
-	newActiveCfg.TrustProxy = newCfgFromFile.TrustProxy
-	GlobalConfig = &newActiveCfg
+	newActiveCfg, result := applyReload(GlobalConfig, fileConfig, newFileCfg)
+	GlobalConfig = newActiveCfg
+	fileConfig = newFileCfg
 	mu.Unlock()
 
-	slog.Info("✅ 配置文件重载成功",
-		"ipWhitelist", newActiveCfg.IPWhitelist,
-		"trustProxy", newActiveCfg.TrustProxy)
+	result.log()
 
 	// 调用回调函数
 	for _, callback := range reloadCallbacks {
-		callback(&newActiveCfg)
+		callback(newActiveCfg)
 	}
 }
 
@@ -270,49 +394,239 @@ func GenerateSecureKey() string {
 
 // ClientConfig 客户端配置结构
 type ClientConfig struct {
-	Server   string `yaml:"server"`
-	Password string `yaml:"password"`
-	WorkDir  string `yaml:"workdir"`
-	IPMode   int    `yaml:"ip_mode"` // 0=默认, 4=IPv4, 6=IPv6
-	Debug    bool   `yaml:"debug"`
+	Server   string `yaml:"server" json:"server" toml:"server"`
+	WSPath   string `yaml:"ws_path,omitempty" json:"ws_path,omitempty" toml:"ws_path,omitempty"` // WebSocket 端点路径，需与服务端一致，默认 /ws
+	Password string `yaml:"password" json:"password" toml:"password"`
+	ClientID string `yaml:"client_id,omitempty" json:"client_id,omitempty" toml:"client_id,omitempty"` // 客户端标识，留空时依次回退到主机名、随机 UUID
+	WorkDir  string `yaml:"workdir" json:"workdir" toml:"workdir"`
+	IPMode   int    `yaml:"ip_mode" json:"ip_mode" toml:"ip_mode"` // 0=默认, 4=IPv4, 6=IPv6
+	Debug    bool   `yaml:"debug" json:"debug" toml:"debug"`
 	// 全局域名列表，用于 --list 和无参数时处理所有域名
-	Domains []string `yaml:"domains,omitempty"`
+	Domains []string `yaml:"domains,omitempty" json:"domains,omitempty" toml:"domains,omitempty"`
 	// 默认的重载/重启服务命令
-	DefaultReloadCmd string `yaml:"default_reload_cmd,omitempty"`
+	DefaultReloadCmd string `yaml:"default_reload_cmd,omitempty" json:"default_reload_cmd,omitempty" toml:"default_reload_cmd,omitempty"`
+	// 重载命令白名单：以空格结尾为前缀匹配，含 * 或 ? 为 glob，其余为完全匹配；为空时不限制
+	AllowedReloadCmds []string `yaml:"allowed_reload_cmds,omitempty" json:"allowed_reload_cmds,omitempty" toml:"allowed_reload_cmds,omitempty"`
+	// 单条 WebSocket 消息的大小上限（字节），0 表示默认 10 MB
+	MaxMessageSize int `yaml:"max_message_size,omitempty" json:"max_message_size,omitempty" toml:"max_message_size,omitzero"`
 
 	// TLS 配置（用于自签证书场景）
-	TLSCaFile             string `yaml:"tls_ca_file"`              // 信任的 CA 证书路径
-	TLSInsecureSkipVerify bool   `yaml:"tls_insecure_skip_verify"` // 跳过证书验证（仅开发用）
+	TLSCaFile             string `yaml:"tls_ca_file" json:"tls_ca_file" toml:"tls_ca_file"`                                        // 信任的 CA 证书路径
+	TLSInsecureSkipVerify bool   `yaml:"tls_insecure_skip_verify" json:"tls_insecure_skip_verify" toml:"tls_insecure_skip_verify"` // 跳过证书验证（仅开发用）
+	// 客户端证书（mTLS），服务端配置了 client_ca_file 时必须设置，两者需同时配置
+	ClientCertFile string `yaml:"client_cert_file,omitempty" json:"client_cert_file,omitempty" toml:"client_cert_file,omitempty"`
+	ClientKeyFile  string `yaml:"client_key_file,omitempty" json:"client_key_file,omitempty" toml:"client_key_file,omitempty"`
+
+	// 各类操作的超时（秒），0 表示使用默认值
+	Timeouts TimeoutsConfig `yaml:"timeouts,omitempty" json:"timeouts,omitempty" toml:"timeouts,omitempty"`
+	// 已废弃：请使用 timeouts.request，仅在 timeouts.request 未设置时生效
+	RequestTimeout int `yaml:"request_timeout,omitempty" json:"request_timeout,omitempty" toml:"request_timeout,omitzero"`
+	// 部署历史（<workdir>/<domain>/deploy_history.jsonl）保留天数，每次写入时清理更早的记录，0 表示全部保留
+	DeployHistoryRetentionDays int `yaml:"deploy_history_retention_days,omitempty" json:"deploy_history_retention_days,omitempty" toml:"deploy_history_retention_days,omitzero"`
+	// 覆盖部署文件前备份为 <部署路径>.bak.<时间戳>，每个文件保留的备份数；reload 失败时恢复备份并重新 reload
+	// 0 表示默认 3 份，负数表示不备份
+	DeployBackupCount int `yaml:"deploy_backup_count,omitempty" json:"deploy_backup_count,omitempty" toml:"deploy_backup_count,omitzero"`
+	// daemon 部署推送的证书前的校验：strict（默认，校验失败时不部署）/ warn（只记录警告）/ off
+	VerifyPush string `yaml:"verify_push,omitempty" json:"verify_push,omitempty" toml:"verify_push,omitempty"`
+	// 写入工作目录与部署文件时 fsync 文件及所在目录，防止写入后立即断电或崩溃留下空文件或丢失文件，代价是写入变慢
+	SyncOnWrite bool `yaml:"sync_on_write,omitempty" json:"sync_on_write,omitempty" toml:"sync_on_write,omitzero"`
+	// 一次性命令发送请求时连接已断开（或等待响应时断开）则重新连接、认证并重发一次请求
+	// 收到响应前连续自动重连的次数上限为 max_reconnect_attempts，0 表示默认 3
+	AutoReconnect        bool `yaml:"auto_reconnect,omitempty" json:"auto_reconnect,omitempty" toml:"auto_reconnect,omitzero"`
+	MaxReconnectAttempts int  `yaml:"max_reconnect_attempts,omitempty" json:"max_reconnect_attempts,omitempty" toml:"max_reconnect_attempts,omitzero"`
+	// 认证默认使用 HMAC-SHA256 签名，旧版本服务端拒绝时回退到 sha256(password + timestamp)；设置后不回退
+	DisableLegacyAuth bool `yaml:"disable_legacy_auth,omitempty" json:"disable_legacy_auth,omitempty" toml:"disable_legacy_auth,omitzero"`
+	// --deploy 等待其他实例释放工作目录文件锁的秒数，超时后失败，0 表示默认 10
+	LockTimeoutSeconds int `yaml:"lock_timeout_seconds,omitempty" json:"lock_timeout_seconds,omitempty" toml:"lock_timeout_seconds,omitzero"`
+	// --force-domain、--rotate-key 调用服务端管理接口使用的令牌（与服务端 admin_token 一致）
+	AdminToken string `yaml:"admin_token,omitempty" json:"admin_token,omitempty" toml:"admin_token,omitempty"`
 
 	// Daemon 模式配置
-	Daemon DaemonModeConfig `yaml:"daemon,omitempty"`
+	Daemon DaemonModeConfig `yaml:"daemon,omitempty" json:"daemon,omitempty" toml:"daemon,omitempty"`
 	// 订阅的域名列表（Daemon 模式使用，Pull 模式使用 Domains 或 -d 参数）
-	Subscribe []string `yaml:"subscribe,omitempty"`
+	Subscribe []string `yaml:"subscribe,omitempty" json:"subscribe,omitempty" toml:"subscribe,omitempty"`
 	// 站点部署配置（CLI 和 Daemon 模式共用）
-	Sites []SiteDeployConfig `yaml:"sites,omitempty"`
+	Sites []SiteDeployConfig `yaml:"sites,omitempty" json:"sites,omitempty" toml:"sites,omitempty"`
+	// 日志配置（level 支持热重载，debug 为 true 时固定为 debug 级别）
+	Logging LoggingConfig `yaml:"logging,omitempty" json:"logging,omitempty" toml:"logging,omitempty"`
+}
+
+// LoggingConfig 日志配置，服务端与客户端共用
+type LoggingConfig struct {
+	Level      string `yaml:"level,omitempty" json:"level,omitempty" toml:"level,omitempty"`                     // debug / info / warn / error，默认 info
+	Format     string `yaml:"format,omitempty" json:"format,omitempty" toml:"format,omitempty"`                  // text / json
+	Output     string `yaml:"output,omitempty" json:"output,omitempty" toml:"output,omitempty"`                  // stdout（默认）/ stderr / 日志文件路径
+	MaxSizeMB  int    `yaml:"max_size_mb,omitempty" json:"max_size_mb,omitempty" toml:"max_size_mb,omitzero"`    // 单个日志文件达到该大小（MB）后轮转，0 表示不轮转
+	MaxBackups int    `yaml:"max_backups,omitempty" json:"max_backups,omitempty" toml:"max_backups,omitzero"`    // 保留的历史文件数，0 表示不限
+	MaxAgeDays int    `yaml:"max_age_days,omitempty" json:"max_age_days,omitempty" toml:"max_age_days,omitzero"` // 历史文件保留天数，0 表示不限
+	AddSource  bool   `yaml:"add_source,omitempty" json:"add_source,omitempty" toml:"add_source,omitempty"`      // 日志中记录源码位置
+}
+
+// TimeoutsConfig 客户端超时配置（秒），0 表示使用默认值
+type TimeoutsConfig struct {
+	Connect  int `yaml:"connect,omitempty" json:"connect,omitempty" toml:"connect,omitzero"`    // 建立连接与认证，默认 10
+	Request  int `yaml:"request,omitempty" json:"request,omitempty" toml:"request,omitzero"`    // 证书下载（默认 30）、状态与列表查询（默认 10）
+	Reload   int `yaml:"reload,omitempty" json:"reload,omitempty" toml:"reload,omitzero"`       // 重载命令执行，默认 15
+	Shutdown int `yaml:"shutdown,omitempty" json:"shutdown,omitempty" toml:"shutdown,omitzero"` // daemon 退出时执行防抖中的重载命令并等待结束，默认 30
 }
 
 // DaemonModeConfig Daemon 模式配置
 type DaemonModeConfig struct {
-	Enabled           bool `yaml:"enabled"`
-	ReconnectInterval int  `yaml:"reconnect_interval"` // 重连间隔（秒）
-	HeartbeatInterval int  `yaml:"heartbeat_interval"` // 心跳间隔（秒）
-	ReloadDebounce    int  `yaml:"reload_debounce"`    // Reload 防抖延迟（秒），默认 5 秒
-	SyncInterval      int  `yaml:"sync_interval"`      // 定时同步间隔（秒），0 禁用，默认 3600（1小时）
+	Enabled                   bool `yaml:"enabled" json:"enabled" toml:"enabled"`
+	ReconnectInterval         int  `yaml:"reconnect_interval" json:"reconnect_interval" toml:"reconnect_interval"`                               // 重连间隔（秒）
+	HeartbeatInterval         int  `yaml:"heartbeat_interval" json:"heartbeat_interval" toml:"heartbeat_interval"`                               // 心跳间隔（秒）
+	ReloadDebounce            int  `yaml:"reload_debounce" json:"reload_debounce" toml:"reload_debounce"`                                        // Reload 防抖延迟（秒），默认 5 秒
+	SyncInterval              int  `yaml:"sync_interval" json:"sync_interval" toml:"sync_interval"`                                              // 定时同步间隔（秒），0/未设置=默认 3600（1小时），负数=禁用
+	SyncIntervalMinutes       int  `yaml:"sync_interval_minutes" json:"sync_interval_minutes" toml:"sync_interval_minutes"`                      // 定时同步间隔（分钟），正数时优先于 sync_interval，负数=禁用
+	PongTimeout               int  `yaml:"pong_timeout" json:"pong_timeout" toml:"pong_timeout"`                                                 // 最长可接受的服务端静默时间（秒），超时后断开重连，默认 90
+	ReconnectJitterMaxSeconds int  `yaml:"reconnect_jitter_max_seconds" json:"reconnect_jitter_max_seconds" toml:"reconnect_jitter_max_seconds"` // 首次重连的随机延迟上限（秒），0/未设置=默认 10，负数=禁用
+	CleanupWorkdir            bool `yaml:"cleanup_workdir" json:"cleanup_workdir" toml:"cleanup_workdir"`                                        // 订阅列表缩减后删除工作目录中不再订阅的域名目录，默认 false
+	// 启动时（连接前）重新部署工作目录中部署文件缺失或比工作目录旧的证书，未设置时默认 true
+	DeployOnStart *bool `yaml:"deploy_on_start,omitempty" json:"deploy_on_start,omitempty" toml:"deploy_on_start,omitempty"`
+	// 本地状态接口监听地址（/healthz 与 /status），如 127.0.0.1:9091，空表示不启用；接口不做认证，应只监听回环地址
+	StatusListen string `yaml:"status_listen,omitempty" json:"status_listen,omitempty" toml:"status_listen,omitempty"`
+}
+
+// DefaultDeployBackupCount 未设置 deploy_backup_count 时每个部署文件保留的备份数
+const DefaultDeployBackupCount = 3
+
+// DeployBackupRetention 每个部署文件保留的备份数，0 表示不备份
+func (c *ClientConfig) DeployBackupRetention() int {
+	switch {
+	case c.DeployBackupCount < 0:
+		return 0
+	case c.DeployBackupCount == 0:
+		return DefaultDeployBackupCount
+	default:
+		return c.DeployBackupCount
+	}
+}
+
+// DefaultLockTimeoutSeconds 未设置 lock_timeout_seconds 时等待工作目录文件锁的秒数
+const DefaultLockTimeoutSeconds = 10
+
+// LockTimeout 等待工作目录文件锁的时长
+func (c *ClientConfig) LockTimeout() time.Duration {
+	if c.LockTimeoutSeconds == 0 {
+		return DefaultLockTimeoutSeconds * time.Second
+	}
+	return time.Duration(c.LockTimeoutSeconds) * time.Second
+}
+
+// verify_push 的取值
+const (
+	VerifyPushStrict = "strict" // 校验失败时保留工作目录中的文件，不部署，并回复失败的 cert_ack
+	VerifyPushWarn   = "warn"   // 校验失败时只记录警告，照常部署
+	VerifyPushOff    = "off"    // 不校验
+)
+
+// VerifyPushMode 返回推送证书的校验模式，未设置时为 strict
+func (c *ClientConfig) VerifyPushMode() string {
+	if c.VerifyPush == "" {
+		return VerifyPushStrict
+	}
+	return c.VerifyPush
+}
+
+// DeployOnStartEnabled 启动时是否重新部署工作目录中的证书（未设置 deploy_on_start 时开启）
+func (c DaemonModeConfig) DeployOnStartEnabled() bool {
+	return c.DeployOnStart == nil || *c.DeployOnStart
 }
 
 // SiteDeployConfig 站点部署配置
 type SiteDeployConfig struct {
-	Domain        string `yaml:"domain"`
-	CertPath      string `yaml:"cert_path"`
-	KeyPath       string `yaml:"key_path"`
-	FullchainPath string `yaml:"fullchain_path"`
-	ReloadCmd     string `yaml:"reloadcmd"`
+	Domain        string `yaml:"domain" json:"domain" toml:"domain"`
+	CertPath      string `yaml:"cert_path" json:"cert_path" toml:"cert_path"`
+	KeyPath       string `yaml:"key_path" json:"key_path" toml:"key_path"`
+	FullchainPath string `yaml:"fullchain_path" json:"fullchain_path" toml:"fullchain_path"`
+	// 单文件证书包：叶子证书在前、中间证书随后（HAProxy、部分 Java 配置使用）
+	BundlePath string `yaml:"bundle_path,omitempty" json:"bundle_path,omitempty" toml:"bundle_path,omitempty"`
+	// PKCS#12（.pfx/.p12）：由私钥与 fullchain 生成，权限 0600（IIS、Java 等使用）
+	// 密码取自 pkcs12_password 或 pkcs12_password_file（文件首行），都未设置时为空密码；
+	// pkcs12_legacy 改用 3DES/RC2-40 + SHA-1，供 Java 8u301 之前等不支持 AES 加密的程序读取
+	PKCS12Path         string `yaml:"pkcs12_path,omitempty" json:"pkcs12_path,omitempty" toml:"pkcs12_path,omitempty"`
+	PKCS12Password     string `yaml:"pkcs12_password,omitempty" json:"pkcs12_password,omitempty" toml:"pkcs12_password,omitempty"`
+	PKCS12PasswordFile string `yaml:"pkcs12_password_file,omitempty" json:"pkcs12_password_file,omitempty" toml:"pkcs12_password_file,omitempty"`
+	PKCS12Legacy       bool   `yaml:"pkcs12_legacy,omitempty" json:"pkcs12_legacy,omitempty" toml:"pkcs12_legacy,omitempty"`
+	// 合并文件：按 combined_order（逗号分隔的 cert、key、fullchain，默认 "fullchain,key"）拼接，含私钥，权限同 key_mode（默认 0600）
+	CombinedPath  string `yaml:"combined_path,omitempty" json:"combined_path,omitempty" toml:"combined_path,omitempty"`
+	CombinedOrder string `yaml:"combined_order,omitempty" json:"combined_order,omitempty" toml:"combined_order,omitempty"`
+	ReloadCmd     string `yaml:"reloadcmd" json:"reloadcmd" toml:"reloadcmd"`
+	// 写入部署文件前执行，非零退出时取消部署；reload 成功后执行 postcmd，失败只记录日志。均支持 {domain} 占位符
+	PreCmd  string `yaml:"precmd,omitempty" json:"precmd,omitempty" toml:"precmd,omitempty"`
+	PostCmd string `yaml:"postcmd,omitempty" json:"postcmd,omitempty" toml:"postcmd,omitempty"`
+	// 部署后重新读取部署文件，按 SHA-256 与来源内容比对（用于 NFS 等网络文件系统）
+	VerifyAfterDeploy bool `yaml:"verify_after_deploy,omitempty" json:"verify_after_deploy,omitempty" toml:"verify_after_deploy,omitempty"`
+	// 部署前校验证书内嵌的 SCT 由 ct_log_urls 中的 CT 日志签发，未通过时不部署
+	// ct_log_urls 为 Google v3 格式的日志列表（URL 或本地文件），为空时使用 Google 发布的日志列表
+	VerifyCT  bool     `yaml:"verify_ct,omitempty" json:"verify_ct,omitempty" toml:"verify_ct,omitempty"`
+	CTLogURLs []string `yaml:"ct_log_urls,omitempty" json:"ct_log_urls,omitempty" toml:"ct_log_urls,omitempty"`
+	// 部署文件及新建上级目录的属主与属组（用户名/组名或数字 ID），为空时保持进程的用户；非 root 运行时记录警告并跳过，仅 Unix 系统支持
+	Owner string `yaml:"owner,omitempty" json:"owner,omitempty" toml:"owner,omitempty"`
+	Group string `yaml:"group,omitempty" json:"group,omitempty" toml:"group,omitempty"`
+	// 已废弃：请使用 owner / group，仅在 owner / group 未设置时生效
+	FileOwner string `yaml:"file_owner,omitempty" json:"file_owner,omitempty" toml:"file_owner,omitempty"`
+	FileGroup string `yaml:"file_group,omitempty" json:"file_group,omitempty" toml:"file_group,omitempty"`
+	// 八进制权限字符串（如 "0640"）：cert_mode 用于证书、证书链与证书包，key_mode 用于私钥（默认均为 0644），
+	// dir_mode 用于部署时新建的上级目录（默认 0755）
+	CertMode string `yaml:"cert_mode,omitempty" json:"cert_mode,omitempty" toml:"cert_mode,omitempty"`
+	KeyMode  string `yaml:"key_mode,omitempty" json:"key_mode,omitempty" toml:"key_mode,omitempty"`
+	DirMode  string `yaml:"dir_mode,omitempty" json:"dir_mode,omitempty" toml:"dir_mode,omitempty"`
 }
 
-// ClientConfigFile 客户端配置文件结构（用于 YAML 解析）
-type ClientConfigFile struct {
-	Client *ClientConfig `yaml:"client"`
+// OwnerName 返回部署文件的属主，未设置 owner 时使用已废弃的 file_owner
+func (s *SiteDeployConfig) OwnerName() string {
+	if s.Owner != "" {
+		return s.Owner
+	}
+	return s.FileOwner
+}
+
+// GroupName 返回部署文件的属组，未设置 group 时使用已废弃的 file_group
+func (s *SiteDeployConfig) GroupName() string {
+	if s.Group != "" {
+		return s.Group
+	}
+	return s.FileGroup
+}
+
+// FileModes 返回 cert_mode、key_mode 与 dir_mode 对应的权限，未设置（或无效）的返回 0，由部署方使用默认值
+// 配置加载时已由 ValidateClientConfig 校验
+func (s *SiteDeployConfig) FileModes() (certMode, keyMode, dirMode os.FileMode) {
+	certMode, _ = ParseFileMode(s.CertMode)
+	keyMode, _ = ParseFileMode(s.KeyMode)
+	dirMode, _ = ParseFileMode(s.DirMode)
+	return certMode, keyMode, dirMode
+}
+
+// PKCS12Secret 返回 PKCS#12 文件的密码：设置了 pkcs12_password_file 时读取其首行，否则为 pkcs12_password（可为空）
+func (s *SiteDeployConfig) PKCS12Secret() (string, error) {
+	if s.PKCS12PasswordFile == "" {
+		return s.PKCS12Password, nil
+	}
+	return ReadPasswordFile(s.PKCS12PasswordFile)
+}
+
+// ReadPasswordFile 读取密码文件的首行（去掉行尾的 \r）
+func ReadPasswordFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("读取密码文件失败: %w", err)
+	}
+	line, _, _ := strings.Cut(string(data), "\n")
+	return strings.TrimRight(line, "\r"), nil
+}
+
+// ParseFileMode 解析八进制权限字符串（如 "0640"、"750"），只允许权限位（不超过 0777），空字符串返回 0
+func ParseFileMode(s string) (os.FileMode, error) {
+	if s == "" {
+		return 0, nil
+	}
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("无效的权限 %q，应为 0000~0777 的八进制数，如 \"0640\"", s)
+	}
+	return os.FileMode(mode), nil
 }
 
 // LoadClientConfigUnvalidated 加载客户端配置但不做最终校验
@@ -321,9 +635,9 @@ type ClientConfigFile struct {
 func LoadClientConfigUnvalidated(configPath string) (*ClientConfig, error) {
 	cfg := &ClientConfig{
 		// 默认值
-		Server:           "http://localhost:9090",
+		Server:           DefaultClientServer,
 		Password:         "", // 空密码，允许命令行后续覆盖
-		WorkDir:          "/tmp/acme",
+		WorkDir:          DefaultClientWorkDir,
 		IPMode:           0,
 		Debug:            false,
 		Domains:          []string{},
@@ -332,14 +646,14 @@ func LoadClientConfigUnvalidated(configPath string) (*ClientConfig, error) {
 
 	// 1. 从配置文件加载
 	if configPath != "" {
-		data, err := os.ReadFile(configPath)
+		data, format, _, err := readConfigTree(configPath)
 		if err != nil {
 			return nil, err
 		}
 
-		// 尝试解析为客户端配置文件格式
-		var fileCfg ClientConfigFile
-		if err := yaml.Unmarshal(data, &fileCfg); err != nil {
+		// 按完整配置结构解析，兼容服务端/客户端共用同一配置文件的场景
+		var fileCfg Config
+		if err := decodeConfig(data, format, &fileCfg); err != nil {
 			return nil, err
 		}
 
@@ -347,17 +661,20 @@ func LoadClientConfigUnvalidated(configPath string) (*ClientConfig, error) {
 			cfg = fileCfg.Client
 			// 确保有默认值
 			if cfg.Server == "" {
-				cfg.Server = "http://localhost:9090"
+				cfg.Server = DefaultClientServer
 			}
 			if cfg.WorkDir == "" {
-				cfg.WorkDir = "/tmp/acme"
+				cfg.WorkDir = DefaultClientWorkDir
 			}
 		}
 	}
 
 	// 2. 从环境变量覆盖
 	cfg.Server = getEnvStr("ACMEDELIVER_SERVER", cfg.Server)
+	cfg.WSPath = getEnvStr("ACMEDELIVER_WS_PATH", cfg.WSPath)
+	cfg.MaxMessageSize = getEnvInt("ACMEDELIVER_MAX_MESSAGE_SIZE", cfg.MaxMessageSize)
 	cfg.Password = getEnvStr("ACMEDELIVER_PASSWORD", cfg.Password)
+	cfg.ClientID = getEnvStr("ACMEDELIVER_CLIENT_ID", cfg.ClientID)
 	cfg.WorkDir = getEnvStr("ACMEDELIVER_WORKDIR", cfg.WorkDir)
 	cfg.IPMode = getEnvInt("ACMEDELIVER_IP_MODE", cfg.IPMode)
 	cfg.Debug = getEnvBool("ACMEDELIVER_DEBUG", cfg.Debug)
@@ -365,9 +682,30 @@ func LoadClientConfigUnvalidated(configPath string) (*ClientConfig, error) {
 	// TLS 配置环境变量
 	cfg.TLSCaFile = getEnvStr("ACMEDELIVER_TLS_CA_FILE", cfg.TLSCaFile)
 	cfg.TLSInsecureSkipVerify = getEnvBool("ACMEDELIVER_TLS_INSECURE_SKIP_VERIFY", cfg.TLSInsecureSkipVerify)
+	cfg.ClientCertFile = getEnvStr("ACMEDELIVER_CLIENT_CERT_FILE", cfg.ClientCertFile)
+	cfg.ClientKeyFile = getEnvStr("ACMEDELIVER_CLIENT_KEY_FILE", cfg.ClientKeyFile)
+
+	// 超时配置环境变量（兼容已废弃的 request_timeout）
+	if cfg.Timeouts.Request == 0 {
+		cfg.Timeouts.Request = cfg.RequestTimeout
+	}
+	cfg.Timeouts.Connect = getEnvInt("ACMEDELIVER_CONNECT_TIMEOUT", cfg.Timeouts.Connect)
+	cfg.Timeouts.Request = getEnvInt("ACMEDELIVER_REQUEST_TIMEOUT", cfg.Timeouts.Request)
+	cfg.Timeouts.Reload = getEnvInt("ACMEDELIVER_RELOAD_TIMEOUT", cfg.Timeouts.Reload)
+	cfg.Timeouts.Shutdown = getEnvInt("ACMEDELIVER_SHUTDOWN_TIMEOUT", cfg.Timeouts.Shutdown)
+	cfg.DeployHistoryRetentionDays = getEnvInt("ACMEDELIVER_DEPLOY_HISTORY_RETENTION_DAYS", cfg.DeployHistoryRetentionDays)
+	cfg.DeployBackupCount = getEnvInt("ACMEDELIVER_DEPLOY_BACKUP_COUNT", cfg.DeployBackupCount)
+	cfg.VerifyPush = getEnvStr("ACMEDELIVER_VERIFY_PUSH", cfg.VerifyPush)
+	cfg.SyncOnWrite = getEnvBool("ACMEDELIVER_SYNC_ON_WRITE", cfg.SyncOnWrite)
+	cfg.AutoReconnect = getEnvBool("ACMEDELIVER_AUTO_RECONNECT", cfg.AutoReconnect)
+	cfg.MaxReconnectAttempts = getEnvInt("ACMEDELIVER_MAX_RECONNECT_ATTEMPTS", cfg.MaxReconnectAttempts)
+	cfg.DisableLegacyAuth = getEnvBool("ACMEDELIVER_DISABLE_LEGACY_AUTH", cfg.DisableLegacyAuth)
+	cfg.LockTimeoutSeconds = getEnvInt("ACMEDELIVER_LOCK_TIMEOUT_SECONDS", cfg.LockTimeoutSeconds)
+	cfg.AdminToken = getEnvStr("ACMEDELIVER_ADMIN_TOKEN", cfg.AdminToken)
 
 	// 新增：环境变量支持
 	cfg.DefaultReloadCmd = getEnvStr("ACMEDELIVER_DEFAULT_RELOAD_CMD", cfg.DefaultReloadCmd)
+	applyLoggingEnv(&cfg.Logging)
 
 	// 支持从环境变量读取域名列表（逗号分隔）
 	if domainsEnv := getEnvStr("ACMEDELIVER_DOMAINS", ""); domainsEnv != "" {
@@ -381,18 +719,237 @@ func LoadClientConfigUnvalidated(configPath string) (*ClientConfig, error) {
 	return cfg, nil
 }
 
-// ValidateClientConfig 校验客户端配置合法性
-func ValidateClientConfig(cfg *ClientConfig) error {
+// applyLoggingEnv 从环境变量覆盖日志配置
+func applyLoggingEnv(cfg *LoggingConfig) {
+	cfg.Level = getEnvStr("ACMEDELIVER_LOG_LEVEL", cfg.Level)
+	cfg.Format = getEnvStr("ACMEDELIVER_LOG_FORMAT", cfg.Format)
+	cfg.Output = getEnvStr("ACMEDELIVER_LOG_OUTPUT", cfg.Output)
+}
+
+// ValidateClientConfig 校验客户端配置合法性，返回全部违规项（每项为 *ConfigValidationError），合法时返回 nil
+func ValidateClientConfig(cfg *ClientConfig) []error {
+	var errs []error
+	add := func(field, format string, args ...any) {
+		errs = append(errs, &ConfigValidationError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
 	// 校验密码必须设置
 	if cfg.Password == "" {
-		return fmt.Errorf("未配置密码，请设置:\n  • 配置文件: client.password\n  • 环境变量: export ACMEDELIVER_PASSWORD=your-password\n  • 命令行参数: -k your-password")
+		add("password", "未配置密码，请设置:\n  • 配置文件: client.password\n  • 环境变量: export ACMEDELIVER_PASSWORD=your-password\n  • 命令行参数: -k your-password")
 	}
 
-	// 校验 WorkDir 必须为绝对路径（lockfile 库要求）
-	if cfg.WorkDir != "" && !filepath.IsAbs(cfg.WorkDir) {
-		return fmt.Errorf("workdir 必须使用绝对路径，当前值: %q（lockfile 库要求）", cfg.WorkDir)
+	if err := validateServerURL(cfg.Server); err != nil {
+		add("server", "server 不是有效的服务端地址: %v", err)
+	}
+
+	// 校验 WorkDir 必须为绝对路径（lockfile 库要求），且已存在或可被创建
+	if cfg.WorkDir != "" {
+		if !filepath.IsAbs(cfg.WorkDir) {
+			add("workdir", "workdir 必须使用绝对路径，当前值: %q（lockfile 库要求）", cfg.WorkDir)
+		} else if err := checkDirCreatable(cfg.WorkDir); err != nil {
+			add("workdir", "workdir 不可用: %v", err)
+		}
 	}
 
+	if cfg.MaxMessageSize < 0 {
+		add("max_message_size", "max_message_size 不能为负数（0 使用默认值 10 MB），当前值: %d", cfg.MaxMessageSize)
+	}
+	if cfg.RequestTimeout < 0 {
+		add("request_timeout", "request_timeout 不能为负数，当前值: %d", cfg.RequestTimeout)
+	}
+	if cfg.MaxReconnectAttempts < 0 {
+		add("max_reconnect_attempts", "max_reconnect_attempts 不能为负数（0 使用默认值 3），当前值: %d", cfg.MaxReconnectAttempts)
+	}
+	if cfg.LockTimeoutSeconds < 0 {
+		add("lock_timeout_seconds", "lock_timeout_seconds 不能为负数（0 使用默认值 10），当前值: %d", cfg.LockTimeoutSeconds)
+	}
+	if err := validateTimeouts(cfg.Timeouts); err != nil {
+		add("timeouts", "%v", err)
+	}
+	if cfg.DeployHistoryRetentionDays < 0 {
+		add("deploy_history_retention_days", "deploy_history_retention_days 不能为负数（0 表示全部保留），当前值: %d", cfg.DeployHistoryRetentionDays)
+	}
+	switch cfg.VerifyPush {
+	case "", VerifyPushStrict, VerifyPushWarn, VerifyPushOff:
+	default:
+		add("verify_push", "verify_push 只能为 strict、warn 或 off，当前值: %q", cfg.VerifyPush)
+	}
+	if err := ValidateWSPath(cfg.WSPath); err != nil {
+		add("ws_path", "%v", err)
+	}
+	if cfg.Daemon.StatusListen != "" {
+		if _, _, err := net.SplitHostPort(cfg.Daemon.StatusListen); err != nil {
+			add("daemon.status_listen", "daemon.status_listen 不是有效的监听地址: %v", err)
+		}
+	}
+	if (cfg.ClientCertFile == "") != (cfg.ClientKeyFile == "") {
+		add("client_cert_file", "client_cert_file 与 client_key_file 必须同时配置")
+	}
+	if cfg.DefaultReloadCmd != "" {
+		if err := command.ValidateCommand(cfg.DefaultReloadCmd); err != nil {
+			add("default_reload_cmd", "default_reload_cmd 不安全: %v", err)
+		}
+	}
+
+	for i, site := range cfg.Sites {
+		prefix := fmt.Sprintf("sites[%d]", i)
+		if err := domainmatch.ValidatePattern(site.Domain); err != nil {
+			add(prefix+".domain", "%s.domain: %v", prefix, err)
+		}
+		for _, p := range []struct {
+			name  string
+			value string
+		}{
+			{"cert_path", site.CertPath},
+			{"key_path", site.KeyPath},
+			{"fullchain_path", site.FullchainPath},
+			{"bundle_path", site.BundlePath},
+			{"combined_path", site.CombinedPath},
+			{"pkcs12_path", site.PKCS12Path},
+			{"pkcs12_password_file", site.PKCS12PasswordFile},
+		} {
+			if p.value != "" && !filepath.IsAbs(p.value) {
+				add(prefix+"."+p.name, "%s.%s 必须使用绝对路径，当前值: %q", prefix, p.name, p.value)
+			}
+		}
+		if _, err := cert.ParseCombinedOrder(site.CombinedOrder); err != nil {
+			add(prefix+".combined_order", "%s.combined_order: %v", prefix, err)
+		}
+		if site.PKCS12Password != "" && site.PKCS12PasswordFile != "" {
+			add(prefix+".pkcs12_password", "%s.pkcs12_password 与 pkcs12_password_file 不能同时设置", prefix)
+		}
+		for _, m := range []struct {
+			name  string
+			value string
+		}{
+			{"cert_mode", site.CertMode},
+			{"key_mode", site.KeyMode},
+			{"dir_mode", site.DirMode},
+		} {
+			if _, err := ParseFileMode(m.value); err != nil {
+				add(prefix+"."+m.name, "%s.%s: %v", prefix, m.name, err)
+			}
+		}
+		if site.ReloadCmd != "" {
+			if err := command.ValidateCommand(site.ReloadCmd); err != nil {
+				add(prefix+".reloadcmd", "%s.reloadcmd 不安全: %v", prefix, err)
+			}
+		}
+		for _, hook := range []struct {
+			name  string
+			value string
+		}{
+			{"precmd", site.PreCmd},
+			{"postcmd", site.PostCmd},
+		} {
+			if hook.value == "" {
+				continue
+			}
+			if _, _, err := command.Parse(hook.value); err != nil {
+				add(prefix+"."+hook.name, "%s.%s 无效: %v", prefix, hook.name, err)
+			}
+		}
+	}
+	if err := ValidateSites(cfg.Sites); err != nil {
+		add("sites", "%v", err)
+	}
+	errs = append(errs, reloadAllowlistErrors(cfg)...)
+
+	return errs
+}
+
+// ValidateReloadAllowlist 校验 allowed_reload_cmds 合法，且 default_reload_cmd 与站点的 reloadcmd 均在白名单中
+func ValidateReloadAllowlist(cfg *ClientConfig) error {
+	return errors.Join(reloadAllowlistErrors(cfg)...)
+}
+
+// reloadAllowlistErrors 返回重载命令白名单的全部违规项
+func reloadAllowlistErrors(cfg *ClientConfig) []error {
+	allowlist, err := command.NewAllowlist(cfg.AllowedReloadCmds)
+	if err != nil {
+		return []error{&ConfigValidationError{Field: "allowed_reload_cmds", Message: fmt.Sprintf("allowed_reload_cmds: %v", err)}}
+	}
+
+	var errs []error
+	check := func(field, cmd string) {
+		if cmd == "" {
+			return
+		}
+		if err := allowlist.Check(cmd); err != nil {
+			errs = append(errs, &ConfigValidationError{Field: field, Message: fmt.Sprintf("%s %v（请检查 allowed_reload_cmds）", field, err)})
+		}
+	}
+	check("default_reload_cmd", cfg.DefaultReloadCmd)
+	for i, site := range cfg.Sites {
+		check(fmt.Sprintf("sites[%d].reloadcmd", i), site.ReloadCmd)
+	}
+	return errs
+}
+
+// ConfigValidationError 单项配置校验失败，Field 为配置项路径（如 sites[0].cert_path）
+type ConfigValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ConfigValidationError) Error() string {
+	return e.Message
+}
+
+// validateServerURL 校验服务端地址为带主机名的 http(s)/ws(s) URL
+func validateServerURL(server string) error {
+	if server == "" {
+		return fmt.Errorf("地址为空")
+	}
+	u, err := url.Parse(server)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "http", "https", "ws", "wss":
+	default:
+		return fmt.Errorf("不支持的协议 %q（可选 http、https、ws、wss）", u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("缺少主机名")
+	}
+	return nil
+}
+
+// checkDirCreatable 检查目录已存在，或其最近的已存在上级为目录（即可被创建），不产生副作用
+func checkDirCreatable(dir string) error {
+	for p := filepath.Clean(dir); ; p = filepath.Dir(p) {
+		info, err := os.Stat(p)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s 不是目录", p)
+			}
+			return nil
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		if parent := filepath.Dir(p); parent == p {
+			return err
+		}
+	}
+}
+
+// validateTimeouts 校验超时配置，各项必须为正数或 0（使用默认值）
+func validateTimeouts(t TimeoutsConfig) error {
+	for _, item := range []struct {
+		name  string
+		value int
+	}{
+		{"connect", t.Connect},
+		{"request", t.Request},
+		{"reload", t.Reload},
+		{"shutdown", t.Shutdown},
+	} {
+		if item.value < 0 {
+			return fmt.Errorf("timeouts.%s 必须为正数秒数（0 使用默认值），当前值: %d", item.name, item.value)
+		}
+	}
 	return nil
 }
 
@@ -403,7 +960,7 @@ func LoadClientConfig(configPath string) (*ClientConfig, error) {
 		return nil, err
 	}
 
-	if err := ValidateClientConfig(cfg); err != nil {
+	if err := errors.Join(ValidateClientConfig(cfg)...); err != nil {
 		return nil, err
 	}
 
@@ -417,6 +974,8 @@ func GenerateExampleConfig() string {
 port: "9090"
 bind: ""  # 留空表示绑定所有接口
 base_dir: "./"
+# 多个证书目录（可选，设置后忽略 base_dir）：按顺序查找域名，同名域名以靠前的目录为准
+# base_dirs: ["/home/acme", "/etc/letsencrypt/live"]
 key: "your-strong-password-here"
 
 # TLS 配置
@@ -424,6 +983,7 @@ tls: false
 tls_port: "9443"
 cert_file: "cert.pem"
 key_file: "key.pem"
+# client_ca_file: "/path/to/client-ca.crt"  # mTLS：TLS 端口要求客户端出示由该 CA 签发的证书（需重启）
 
 # 安全配置（支持热重载）
 ip_whitelist: ""  # 示例: "192.168.1.0/24,10.0.0.50,127.0.0.1,::1"
@@ -432,12 +992,88 @@ trust_proxy: false  # 是否信任反向代理头 (X-Forwarded-For, X-Real-IP)
                     # ⚠️ 仅当服务部署在可信反向代理（如 Nginx、Caddy）后面时才设为 true
                     # ⚠️ 直接暴露公网时必须为 false，否则攻击者可伪造 IP 绕过白名单
 
+# 重复客户端 ID 处理策略（多个 daemon 使用相同 client_id 时）
+# evict: 踢出旧连接（默认，daemon 重启时旧连接可能尚未超时） / reject: 拒绝新连接 / allow: 允许并记录警告
+duplicate_policy: "evict"
+
+# 是否允许客户端订阅 "*"、"**.example.com" 与顶级域名通配符（如 "*.com"）等大范围通配符
+# 默认 false：订阅这些模式的认证被拒绝，订阅更新中的这些模式不生效；"*.example.com" 不受限制
+# allow_wildcard_subscribe: false
+
+# 拒绝旧版本客户端的 sha256(password + timestamp) 签名，只接受 HMAC-SHA256 签名（默认 false）
+# 默认接受旧签名并记录弃用警告；所有客户端升级后建议开启（支持热重载）
+# disable_legacy_auth: false
+
+# WebSocket 端点路径，默认 /ws；反向代理挂载在子路径（如 /acme/ws）时修改，客户端 ws_path 需一致（需重启）
+# ws_path: "/ws"
+
+# WebSocket 压缩（permessage-deflate），证书推送约可减少 40% 传输量，代价是少量 CPU
+ws_compression: false
+# ws_compression_level: 1  # 压缩级别 -2~9（1 最快，9 最小）
+
+# 单条 WebSocket 消息的大小上限（字节），默认 10485760（10 MB）；内存受限时可调小，证书链很长时可调大（对新连接生效）
+# 超过上限时以 close 1009 断开，客户端的 max_message_size 限制服务端推送的消息大小
+# max_message_size: 10485760
+
+# 归档域名目录（DELETE /api/v1/domains/{domain} 移动到 <base_dir>/.archive/）的清理间隔（小时），0 表示禁用
+# cleanup_interval_hours: 24
+# cleanup_archive_days: 30  # 归档保留天数，默认 30
+# active_domains: [example.com, www.example.com]  # 配置后清理任务将不在列表中的域名目录归档，为空时不归档（支持热重载）
+
+# 证书目录监控防抖时间（秒），默认 5（支持热重载）
+# watch_debounce: 5
+
+# 密钥轮换时等待客户端用新密钥重新认证的最长时间（秒），默认 60（支持热重载）
+# key_rotation_window: 60
+
+# 证书上传接口单个文件的大小上限（字节），默认 102400（支持热重载）
+# max_cert_size_bytes: 102400
+
+# 上传证书写入域名目录时 fsync 文件及所在目录，防止断电后留下空文件，代价是写入变慢（支持热重载）
+# sync_on_write: false
+
+# 状态查询（--status --check-crl）CRL 缓存有效期（秒），默认 3600（支持热重载）
+# crl_cache_ttl: 3600
+
+# 证书目录中的文件名，未设置的使用 cert.pem / key.pem / fullchain.pem / time.log（支持热重载）
+# 支持 {domain} 占位符；如直接使用 acme.sh 的默认输出文件，无需重命名。客户端需为支持该功能的版本
+# 未配置时按每个域名目录中的文件自动识别 certbot（privkey.pem）、acme.sh（{domain}.cer、{domain}.key、fullchain.cer）
+# 与 Caddy（{domain}.crt、{domain}.key）的布局，存在 key.pem 时按标准文件名读取
+# cert_filenames:
+#   cert: "{domain}.cer"
+#   key: "{domain}.key"
+#   fullchain: "fullchain.cer"
+#   time_log: "time.log"
+
+# 证书推送的出站速率上限（字节/秒），大量证书同时续期时平滑推送流量，0 表示不限速（支持热重载）
+# push_bytes_per_sec: 1048576
+
+# 管理接口令牌（推送、下线、上传、归档域名，轮换密钥，断开客户端），以 Authorization: Bearer 认证，与 key 分开；为空时禁用（支持热重载）
+# admin_token: "your-admin-token"
+
+# 多实例协调：多个服务端部署在负载均衡后时，经 Redis 发布/订阅把证书推送转发给连接在其他实例上的客户端（需重启）
+# 支持 redis:// 与 rediss://（TLS），可在 URL 中携带密码：redis://:password@redis:6379
+# redis_url: "redis://127.0.0.1:6379"
+
+# 日志配置（level 支持热重载，其余需重启；客户端在 client.logging 中配置）
+# logging:
+#   level: info        # debug / info / warn / error
+#   format: text       # text / json
+#   output: stdout     # stdout / stderr / 日志文件路径
+#   max_size_mb: 10    # 单个日志文件达到该大小后轮转，0 表示不轮转
+#   max_backups: 5     # 保留的历史文件数，0 表示不限
+#   max_age_days: 30   # 历史文件保留天数，0 表示不限
+#   add_source: false  # 记录源码位置
+
 # 注：状态查询功能现已通过 WebSocket 实现，使用 acmedeliver-client --status 命令
 
 # 客户端配置（可选）
 client:
   server: "http://localhost:9090"
+  # ws_path: "/ws"  # WebSocket 端点路径，需与服务端 ws_path 一致（server 已以该路径结尾时不再追加）
+  # max_message_size: 10485760  # 单条 WebSocket 消息的大小上限（字节），默认 10 MB，超过时断开并报告明确错误
   password: "your-strong-password-here"
+  # client_id: "web-01"  # 客户端标识，留空时使用主机名（同机多实例时务必配置）
   workdir: "/tmp/acme"  # 必须使用绝对路径
   ip_mode: 0  # 0=默认, 4=IPv4, 6=IPv6
   debug: false
@@ -446,6 +1082,48 @@ client:
   # 当服务端使用自签证书时，客户端需要指定信任的 CA 证书
   # tls_ca_file: "/path/to/ca.crt"              # 信任的 CA 证书路径
   # tls_insecure_skip_verify: false             # 跳过证书验证（仅开发用，生产环境禁用）
+  # client_cert_file: "/path/to/client.crt"     # mTLS 客户端证书（服务端配置了 client_ca_file 时必须设置）
+  # client_key_file: "/path/to/client.key"      # mTLS 客户端私钥
+
+  # (可选) 超时配置（秒），0 或不设置使用默认值
+  # timeouts:
+  #   connect: 10    # 建立连接与认证
+  #   request: 60    # 证书下载（默认 30）、状态与列表查询（默认 10）
+  #   reload: 15     # 重载命令执行
+  #   shutdown: 30   # daemon 退出时执行防抖中的重载命令并等待结束
+
+  # (可选) 部署历史保留天数，0 或不设置表示全部保留
+  # 每次部署成功后向 <workdir>/<域名>/deploy_history.jsonl 追加一条记录，写入时清理更早的记录
+  # deploy_history_retention_days: 90
+
+  # (可选) 覆盖部署文件前备份为 <部署路径>.bak.<时间戳>，每个文件保留的备份数（默认 3，-1 不备份）
+  # 重载命令失败时恢复备份并重新执行重载命令
+  # deploy_backup_count: 3
+
+  # (可选) daemon 部署推送的证书前校验：私钥匹配、域名覆盖（支持通配符）、未过期且不早于已部署证书过期
+  # strict（默认）校验失败时保留工作目录中的文件供排查、不部署，并向服务端回复失败的 cert_ack；warn 只记录警告；off 不校验
+  # verify_push: strict
+
+  # (可选) 写入工作目录与部署文件时，rename 前 fsync 文件、rename 后 fsync 所在目录
+  # 防止写入后立即断电或崩溃留下空文件或丢失文件，代价是每次写入多一次磁盘同步
+  # sync_on_write: false
+
+  # (可选) 一次性命令（--deploy、--status 等）连接断开时自动重连、认证并重发一次请求
+  # 收到响应前连续自动重连最多 max_reconnect_attempts 次（默认 3）
+  # auto_reconnect: false
+  # max_reconnect_attempts: 3
+
+  # (可选) 认证默认使用 HMAC-SHA256 签名，旧版本服务端拒绝时回退到已弃用的 sha256(password + timestamp) 签名
+  # 设置为 true 后不回退，连接旧版本服务端时认证失败
+  # disable_legacy_auth: false
+
+  # (可选) --deploy 时工作目录文件锁被其他实例持有，每 500 毫秒重试一次，最多等待的秒数（默认 10）
+  # 超时后失败，错误信息包含持有锁的进程 PID
+  # lock_timeout_seconds: 10
+
+  # (可选) --force-domain、--rotate-key 调用服务端管理接口使用的令牌，需与服务端 admin_token 一致
+  # 也可通过环境变量 ACMEDELIVER_ADMIN_TOKEN 设置
+  # admin_token: "your-admin-token"
 
   # (可选) 全局管理的域名列表
   # Pull 模式：用于 --list 命令和无 -d 参数时处理所有域名
@@ -456,11 +1134,23 @@ client:
   # (可选) 部署后执行的默认重载命令
   default_reload_cmd: "systemctl reload nginx"
 
+  # (可选) 重载命令白名单，为空时不限制；default_reload_cmd、--reload-cmd 与站点 reloadcmd 须匹配其中一项
+  # 以空格结尾为前缀匹配，含 * 或 ? 为 glob（* 不匹配 /），其余为完全匹配
+  # allowed_reload_cmds:
+  #   - "systemctl reload *"
+  #   - "/opt/app/bin/reload "
+
   # ========== Daemon 模式配置（WebSocket 推送） ==========
   daemon:
     enabled: false              # 是否启用 daemon 模式
     reconnect_interval: 30      # WebSocket 断线重连间隔（秒）
     heartbeat_interval: 60      # 心跳检测间隔（秒）
+    pong_timeout: 90            # 服务端最长静默时间（秒），超时后断开重连
+    sync_interval_minutes: 0    # 定时同步间隔（分钟），即使没有收到推送也定期同步，0 使用 sync_interval（默认 1 小时）
+    reconnect_jitter_max_seconds: 10  # 首次重连前的随机延迟上限（秒），避免服务端重启后客户端同时重连，-1 禁用
+    cleanup_workdir: false      # 热重载缩减 subscribe 后删除 workdir 中不再订阅的域名目录
+    deploy_on_start: true       # 启动时重新部署 workdir 中部署文件缺失或过期的证书（如证书路径位于 tmpfs）
+    # status_listen: "127.0.0.1:9091"  # 本地状态接口（/healthz、/status），默认不启用；状态同时写入 workdir/daemon-status.json
 
   # daemon 模式下订阅的域名列表
   subscribe:
@@ -484,6 +1174,34 @@ client:
       key_path: "/etc/apache2/ssl/api/key.pem"
       fullchain_path: "/etc/apache2/ssl/api/fullchain.pem"
       reloadcmd: "systemctl reload apache2"
+      # verify_after_deploy: true   # 部署后重新读取并按 SHA-256 比对（NFS 等网络文件系统）
+      # verify_ct: true             # 部署前校验证书的 SCT 由 CT 日志签发，ct_log_urls 为空时使用 Google 的日志列表
+      # precmd: "/usr/local/bin/check-queue {domain}"   # 写入部署文件前执行，非零退出时取消部署
+      # postcmd: "/usr/local/bin/smoke-test {domain}"   # reload 成功后执行，失败只记录日志
+      # owner: "root"                # 部署文件及新建目录的属主（仅 Unix），非 root 运行时记录警告并跳过
+      # group: "nginx"               # 部署文件及新建目录的属组（仅 Unix）
+      # key_mode: "0640"             # 私钥权限（八进制），cert_mode 用于其余文件，默认均为 0644
+      # dir_mode: "0750"             # 部署时新建目录的权限，默认 0755
+
+    # 单文件证书包（HAProxy 等）：叶子证书在前、中间证书随后，不含私钥
+    # - domain: "lb.example.com"
+    #   bundle_path: "/etc/haproxy/certs/{domain}.pem"
+    #   key_path: "/etc/haproxy/certs/{domain}.pem.key"
+    #   reloadcmd: "systemctl reload haproxy"
+
+    # 合并文件：按 combined_order 拼接证书与私钥（默认 "fullchain,key"），权限默认 0600
+    # - domain: "edge.example.com"
+    #   combined_path: "/etc/haproxy/certs/{domain}.pem"
+    #   combined_order: "fullchain,key"
+    #   reloadcmd: "systemctl reload haproxy"
+
+    # PKCS#12（IIS、Java 等）：由私钥与 fullchain 生成，权限默认 0600
+    # 密码取自 pkcs12_password 或 pkcs12_password_file（首行）；pkcs12_legacy 供 Java 8u301 之前等旧程序读取
+    # - domain: "java.example.com"
+    #   pkcs12_path: "/opt/tomcat/conf/{domain}.p12"
+    #   pkcs12_password_file: "/etc/acmedeliver/p12.pass"
+    #   pkcs12_legacy: true
+    #   reloadcmd: "systemctl restart tomcat"
 `
 	return example
 }
@@ -525,17 +1243,18 @@ func (w *ClientConfigWatcher) Start() error {
 		return nil
 	}
 
-	watcher, err := fsnotify.NewWatcher()
+	watcher, err := newConfigWatcher(w.configPath)
 	if err != nil {
 		return err
 	}
-
-	if err := watcher.Add(w.configPath); err != nil {
-		watcher.Close()
-		return err
+	if len(watcher.dirs) == 0 {
+		watcher.watcher.Close()
+		return fmt.Errorf("监听配置文件失败: %s", w.configPath)
 	}
 
-	go w.watchLoop(watcher)
+	slog.Info("🔄 客户端配置热重载已启用", "path", w.configPath)
+
+	go watcher.run(w.stop, w.reloadConfig)
 	return nil
 }
 
@@ -544,33 +1263,6 @@ func (w *ClientConfigWatcher) Stop() {
 	close(w.stop)
 }
 
-// watchLoop 监听循环
-func (w *ClientConfigWatcher) watchLoop(watcher *fsnotify.Watcher) {
-	defer watcher.Close()
-
-	slog.Info("🔄 客户端配置热重载已启用", "path", w.configPath)
-
-	for {
-		select {
-		case <-w.stop:
-			return
-		case event, ok := <-watcher.Events:
-			if !ok {
-				return
-			}
-			if event.Op&fsnotify.Write == fsnotify.Write {
-				slog.Info("📝 检测到客户端配置文件变化，正在重新加载...")
-				w.reloadConfig()
-			}
-		case err, ok := <-watcher.Errors:
-			if !ok {
-				return
-			}
-			slog.Warn("客户端配置文件监听错误", "error", err)
-		}
-	}
-}
-
 // reloadConfig 重新加载配置
 func (w *ClientConfigWatcher) reloadConfig() {
 	newCfg, err := LoadClientConfig(w.configPath)
@@ -585,6 +1277,9 @@ func (w *ClientConfigWatcher) reloadConfig() {
 	// 只更新支持热重载的配置项
 	updatedCfg := *oldCfg
 
+	// 热重载: server 服务端地址（daemon 断开当前连接并重连新地址）
+	updatedCfg.Server = newCfg.Server
+
 	// 热重载: subscribe 订阅列表
 	updatedCfg.Subscribe = newCfg.Subscribe
 
@@ -601,6 +1296,9 @@ func (w *ClientConfigWatcher) reloadConfig() {
 		updatedCfg.Daemon.ReconnectInterval = newCfg.Daemon.ReconnectInterval
 	}
 
+	// 热重载: logging.level
+	updatedCfg.Logging.Level = newCfg.Logging.Level
+
 	w.current = &updatedCfg
 	callbacks := make([]func(*ClientConfig, *ClientConfig), len(w.callbacks))
 	copy(callbacks, w.callbacks)
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -84,6 +85,70 @@ client:
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "workdir 必须使用绝对路径")
 	})
+
+	t.Run("6. request_timeout from file and env", func(t *testing.T) {
+		configFile := createTempConfig(t, testClientConfigContent+"  request_timeout: 60\n")
+		cfg, err := LoadClientConfig(configFile)
+		assert.NoError(t, err)
+		assert.Equal(t, 60, cfg.Timeouts.Request, "Deprecated request_timeout should fill timeouts.request")
+
+		t.Setenv("ACMEDELIVER_REQUEST_TIMEOUT", "5")
+		cfg, err = LoadClientConfig(configFile)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, cfg.Timeouts.Request, "Env request_timeout should override file")
+	})
+
+	t.Run("7. Negative request_timeout should fail", func(t *testing.T) {
+		configFile := createTempConfig(t, testClientConfigContent+"  request_timeout: -1\n")
+		_, err := LoadClientConfig(configFile)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "request_timeout")
+	})
+
+	t.Run("8. timeouts block from file and env", func(t *testing.T) {
+		configFile := createTempConfig(t, testClientConfigContent+`  request_timeout: 99
+  timeouts:
+    connect: 20
+    request: 120
+    reload: 90
+`)
+		cfg, err := LoadClientConfig(configFile)
+		assert.NoError(t, err)
+		assert.Equal(t, TimeoutsConfig{Connect: 20, Request: 120, Reload: 90}, cfg.Timeouts, "timeouts.request takes precedence over request_timeout")
+
+		t.Setenv("ACMEDELIVER_CONNECT_TIMEOUT", "30")
+		t.Setenv("ACMEDELIVER_RELOAD_TIMEOUT", "45")
+		t.Setenv("ACMEDELIVER_SHUTDOWN_TIMEOUT", "60")
+		cfg, err = LoadClientConfig(configFile)
+		assert.NoError(t, err)
+		assert.Equal(t, TimeoutsConfig{Connect: 30, Request: 120, Reload: 45, Shutdown: 60}, cfg.Timeouts)
+	})
+
+	t.Run("9. Negative timeouts should fail", func(t *testing.T) {
+		for _, name := range []string{"connect", "request", "reload", "shutdown"} {
+			configFile := createTempConfig(t, testClientConfigContent+"  timeouts:\n    "+name+": -5\n")
+			_, err := LoadClientConfig(configFile)
+			assert.Error(t, err, name)
+			assert.Contains(t, err.Error(), "timeouts."+name)
+		}
+	})
+
+	t.Run("10. deploy_history_retention_days from file and env", func(t *testing.T) {
+		configFile := createTempConfig(t, testClientConfigContent+"  deploy_history_retention_days: 30\n")
+		cfg, err := LoadClientConfig(configFile)
+		assert.NoError(t, err)
+		assert.Equal(t, 30, cfg.DeployHistoryRetentionDays)
+
+		t.Setenv("ACMEDELIVER_DEPLOY_HISTORY_RETENTION_DAYS", "7")
+		cfg, err = LoadClientConfig(configFile)
+		assert.NoError(t, err)
+		assert.Equal(t, 7, cfg.DeployHistoryRetentionDays)
+
+		t.Setenv("ACMEDELIVER_DEPLOY_HISTORY_RETENTION_DAYS", "-1")
+		_, err = LoadClientConfig(configFile)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "deploy_history_retention_days")
+	})
 }
 
 // resetFlags 重置全局状态以允许隔离测试
@@ -180,6 +245,316 @@ func TestInitServerConfigPriority(t *testing.T) {
 		assert.NotEmpty(t, cfg.Key)
 		assert.Regexp(t, `[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`, cfg.Key)
 	})
+
+	t.Run("7. base_dirs from environment", func(t *testing.T) {
+		t.Setenv("ACMEDELIVER_BASE_DIRS", "/srv/a, /srv/b")
+
+		runInit("-d", "/srv/ignored")
+		cfg := GetConfig()
+
+		assert.Equal(t, []string{"/srv/a", "/srv/b"}, CertDirs(cfg))
+	})
+}
+
+func TestCertDirs(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want []string
+	}{
+		{"仅 base_dir", Config{BaseDir: "/srv/certs"}, []string{"/srv/certs"}},
+		{"base_dirs 优先", Config{BaseDir: "/srv/certs", BaseDirs: []string{"/srv/a", "/srv/b"}}, []string{"/srv/a", "/srv/b"}},
+		{"忽略空目录", Config{BaseDir: "/srv/certs", BaseDirs: []string{" ", "/srv/b "}}, []string{"/srv/b"}},
+		{"全部为空时回退 base_dir", Config{BaseDir: "/srv/certs", BaseDirs: []string{""}}, []string{"/srv/certs"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, CertDirs(&tt.cfg))
+		})
+	}
+}
+
+func TestDaemonDeployOnStart(t *testing.T) {
+	tests := []struct {
+		name   string
+		daemon string
+		want   bool
+	}{
+		{"未设置默认开启", "", true},
+		{"显式开启", "\n  daemon:\n    deploy_on_start: true", true},
+		{"显式关闭", "\n  daemon:\n    deploy_on_start: false", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := createTempConfig(t, "client:\n  password: test"+tt.daemon+"\n")
+			cfg, err := LoadClientConfig(configFile)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, cfg.Daemon.DeployOnStartEnabled())
+		})
+	}
+}
+
+func TestDeployBackupRetention(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  string
+		want int
+	}{
+		{"未设置使用默认值", "", "", DefaultDeployBackupCount},
+		{"配置文件", "  deploy_backup_count: 5\n", "", 5},
+		{"环境变量优先", "  deploy_backup_count: 5\n", "1", 1},
+		{"负数不备份", "  deploy_backup_count: -1\n", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv("ACMEDELIVER_DEPLOY_BACKUP_COUNT", tt.env)
+			}
+			configFile := createTempConfig(t, "client:\n  password: test\n"+tt.file)
+			cfg, err := LoadClientConfig(configFile)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, cfg.DeployBackupRetention())
+		})
+	}
+}
+
+func TestLockTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		env     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"未设置使用默认值", "", "", DefaultLockTimeoutSeconds * time.Second, false},
+		{"配置文件", "  lock_timeout_seconds: 30\n", "", 30 * time.Second, false},
+		{"环境变量优先", "  lock_timeout_seconds: 30\n", "5", 5 * time.Second, false},
+		{"负数无效", "  lock_timeout_seconds: -1\n", "", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv("ACMEDELIVER_LOCK_TIMEOUT_SECONDS", tt.env)
+			}
+			configFile := createTempConfig(t, "client:\n  password: test\n"+tt.file)
+			cfg, err := LoadClientConfig(configFile)
+			if tt.wantErr {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), "lock_timeout_seconds")
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, cfg.LockTimeout())
+		})
+	}
+}
+
+func TestVerifyPushMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		env     string
+		want    string
+		wantErr bool
+	}{
+		{"未设置使用 strict", "", "", VerifyPushStrict, false},
+		{"配置文件", "  verify_push: warn\n", "", VerifyPushWarn, false},
+		{"环境变量优先", "  verify_push: warn\n", "off", VerifyPushOff, false},
+		{"非法值", "  verify_push: lenient\n", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv("ACMEDELIVER_VERIFY_PUSH", tt.env)
+			}
+			configFile := createTempConfig(t, "client:\n  password: test\n"+tt.file)
+			cfg, err := LoadClientConfig(configFile)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), "verify_push")
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, cfg.VerifyPushMode())
+		})
+	}
+}
+
+func TestParseFileMode(t *testing.T) {
+	tests := []struct {
+		mode    string
+		want    os.FileMode
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"0640", 0640, false},
+		{"750", 0750, false},
+		{"0o640", 0, true},
+		{"0888", 0, true},
+		{"4755", 0, true},
+		{"rw-r-----", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFileMode(tt.mode)
+		assert.Equal(t, tt.wantErr, err != nil, tt.mode)
+		assert.Equal(t, tt.want, got, tt.mode)
+	}
+
+	configFile := createTempConfig(t, "client:\n  password: test\n  sites:\n    - domain: example.com\n      key_mode: \"0999\"\n")
+	_, err := LoadClientConfig(configFile)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "sites[0].key_mode")
+}
+
+func TestValidateCombinedOrder(t *testing.T) {
+	for order, wantErr := range map[string]bool{
+		"":                   false,
+		"fullchain,key":      false,
+		"key, cert":          false,
+		"cert,fullchain,key": false,
+		"fullchain,chain":    true,
+		"key,key":            true,
+	} {
+		configFile := createTempConfig(t, "client:\n  password: test\n  sites:\n    - domain: example.com\n      combined_path: /etc/haproxy/certs/example.com.pem\n      combined_order: \""+order+"\"\n")
+		_, err := LoadClientConfig(configFile)
+		assert.Equal(t, wantErr, err != nil, order)
+		if wantErr && err != nil {
+			assert.Contains(t, err.Error(), "sites[0].combined_order")
+		}
+	}
+}
+
+func TestPKCS12Password(t *testing.T) {
+	passFile := filepath.Join(t.TempDir(), "p12.pass")
+	assert.NoError(t, os.WriteFile(passFile, []byte("s3cret\r\nignored\n"), 0600))
+
+	site := SiteDeployConfig{PKCS12PasswordFile: passFile}
+	password, err := site.PKCS12Secret()
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cret", password)
+
+	site = SiteDeployConfig{PKCS12Password: "inline"}
+	password, err = site.PKCS12Secret()
+	assert.NoError(t, err)
+	assert.Equal(t, "inline", password)
+
+	// 密码与密码文件不能同时设置
+	configFile := createTempConfig(t, "client:\n  password: test\n  sites:\n    - domain: example.com\n      pkcs12_path: /etc/ssl/example.com.p12\n      pkcs12_password: inline\n      pkcs12_password_file: "+passFile+"\n")
+	_, err = LoadClientConfig(configFile)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "sites[0].pkcs12_password")
+	}
+}
+
+func TestWSPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{"", "/ws", false},
+		{"/ws", "/ws", false},
+		{"acme/ws", "/acme/ws", false},
+		{"/acme/ws/", "/acme/ws", false},
+		{"/", "/", true},
+		{"/healthz", "/healthz", true},
+		{"/api/v1/ws", "/api/v1/ws", true},
+		{"/ws?x=1", "/ws?x=1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.want, NormalizeWSPath(tt.path))
+			if tt.wantErr {
+				assert.Error(t, ValidateWSPath(tt.path))
+			} else {
+				assert.NoError(t, ValidateWSPath(tt.path))
+			}
+		})
+	}
+}
+
+func TestValidateClientConfig(t *testing.T) {
+	valid := func() *ClientConfig {
+		return &ClientConfig{
+			Server:   "https://acme.example.com:9090",
+			Password: "secret",
+			WorkDir:  filepath.Join(t.TempDir(), "not-yet-created"),
+			Sites: []SiteDeployConfig{{
+				Domain:    "*.example.com",
+				CertPath:  "/etc/nginx/ssl/cert.pem",
+				KeyPath:   "/etc/nginx/ssl/key.pem",
+				ReloadCmd: "systemctl reload nginx",
+			}},
+			Daemon: DaemonModeConfig{StatusListen: "127.0.0.1:9091"},
+		}
+	}
+
+	assert.Empty(t, ValidateClientConfig(valid()))
+
+	fileDir := filepath.Join(t.TempDir(), "file")
+	assert.NoError(t, os.WriteFile(fileDir, nil, 0644))
+
+	cfg := valid()
+	cfg.Password = ""
+	cfg.Server = "localhost:9090"
+	cfg.WorkDir = filepath.Join(fileDir, "sub")
+	cfg.Daemon.StatusListen = "9091"
+	cfg.Sites = []SiteDeployConfig{
+		{Domain: "bad_domain.com", CertPath: "cert.pem", ReloadCmd: "nginx -s reload; rm -rf /"},
+		{Domain: "example.com", FullchainPath: "ssl/fullchain.pem", PreCmd: "check-queue; rm -rf /", PostCmd: "smoke-test {domain}"},
+	}
+
+	var fields []string
+	for _, err := range ValidateClientConfig(cfg) {
+		var verr *ConfigValidationError
+		if assert.ErrorAs(t, err, &verr) {
+			fields = append(fields, verr.Field)
+		}
+	}
+	assert.Equal(t, []string{
+		"password",
+		"server",
+		"workdir",
+		"daemon.status_listen",
+		"sites[0].domain",
+		"sites[0].cert_path",
+		"sites[0].reloadcmd",
+		"sites[1].fullchain_path",
+		"sites[1].precmd",
+	}, fields)
+}
+
+func TestValidateReloadAllowlist(t *testing.T) {
+	cfg := &ClientConfig{
+		DefaultReloadCmd:  "systemctl reload nginx",
+		AllowedReloadCmds: []string{"systemctl reload *", "/opt/app/reload ", "nginx -s reload"},
+		Sites: []SiteDeployConfig{
+			{Domain: "a.example.com", ReloadCmd: "/opt/app/reload --graceful"},
+			{Domain: "b.example.com", ReloadCmd: "nginx -s reload"},
+			{Domain: "c.example.com", ReloadCmd: "systemctl restart nginx"},
+			{Domain: "d.example.com"},
+		},
+	}
+
+	var fields []string
+	for _, err := range reloadAllowlistErrors(cfg) {
+		var verr *ConfigValidationError
+		if assert.ErrorAs(t, err, &verr) {
+			fields = append(fields, verr.Field)
+			assert.Contains(t, verr.Message, "allowed_reload_cmds")
+		}
+	}
+	assert.Equal(t, []string{"sites[2].reloadcmd"}, fields)
+	assert.Error(t, ValidateReloadAllowlist(cfg))
+
+	// 未配置白名单时不限制
+	cfg.AllowedReloadCmds = nil
+	assert.NoError(t, ValidateReloadAllowlist(cfg))
+
+	cfg.AllowedReloadCmds = []string{"systemctl reload [*"}
+	assert.Len(t, reloadAllowlistErrors(cfg), 1)
 }
 
 func TestClientConfigWatcher(t *testing.T) {
@@ -280,4 +655,166 @@ client:
 		// 验证不可热重载的配置未变化
 		assert.Equal(t, initialCfg.Server, currentCfg.Server)
 	})
+
+	t.Run("HotReloadServer", func(t *testing.T) {
+		configFile := createTempConfig(t, "client:\n  server: \"http://old.example.com:9090\"\n  password: \"test\"\n")
+		initialCfg, err := LoadClientConfig(configFile)
+		assert.NoError(t, err)
+
+		watcher := NewClientConfigWatcher(configFile, initialCfg)
+		var gotOld, gotNew string
+		watcher.RegisterCallback(func(old, new *ClientConfig) {
+			gotOld, gotNew = old.Server, new.Server
+		})
+
+		assert.NoError(t, os.WriteFile(configFile, []byte("client:\n  server: \"https://new.example.com:9443\"\n  password: \"test\"\n"), 0644))
+		watcher.reloadConfig()
+
+		assert.Equal(t, "http://old.example.com:9090", gotOld)
+		assert.Equal(t, "https://new.example.com:9443", gotNew)
+	})
+}
+
+func TestStrictConfigUnknownFields(t *testing.T) {
+	t.Run("Top level misspelled key", func(t *testing.T) {
+		configFile := createTempConfig(t, `
+client:
+  server: "http://file-config:1111"
+  pasword: "file-password"
+`)
+		_, err := LoadClientConfigUnvalidated(configFile)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "未知字段 pasword，是否想写 password?")
+		assert.Contains(t, err.Error(), "第 4 行")
+	})
+
+	t.Run("Nested site misspelled key", func(t *testing.T) {
+		configFile := createTempConfig(t, `
+client:
+  password: "file-password"
+  sites:
+    - domain: "example.com"
+      cert_path: "/etc/nginx/ssl/cert.pem"
+      reload_cmd: "systemctl reload nginx"
+`)
+		_, err := LoadClientConfigUnvalidated(configFile)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "未知字段 reload_cmd，是否想写 reloadcmd?")
+	})
+
+	t.Run("Server config misspelled key", func(t *testing.T) {
+		cfg := &Config{}
+		err := loadFromFile(cfg, createTempConfig(t, "port: \"7070\"\nip_whitlist: \"10.0.0.0/8\"\n"))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "未知字段 ip_whitlist，是否想写 ip_whitelist?")
+	})
+
+	t.Run("No suggestion for unrelated key", func(t *testing.T) {
+		cfg := &Config{}
+		err := loadFromFile(cfg, createTempConfig(t, "completely_unrelated: true\n"))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "未知字段 completely_unrelated")
+		assert.NotContains(t, err.Error(), "是否想写")
+	})
+
+	t.Run("Lax mode ignores unknown keys", func(t *testing.T) {
+		SetLaxConfig(true)
+		defer SetLaxConfig(false)
+
+		configFile := createTempConfig(t, `
+client:
+  password: "file-password"
+  sites:
+    - domain: "example.com"
+      reload_cmd: "systemctl reload nginx"
+`)
+		cfg, err := LoadClientConfigUnvalidated(configFile)
+		assert.NoError(t, err)
+		assert.Equal(t, "file-password", cfg.Password)
+		assert.Equal(t, "", cfg.Sites[0].ReloadCmd)
+	})
+
+	t.Run("Environment only is unaffected", func(t *testing.T) {
+		t.Setenv("ACMEDELIVER_PASSWORD", "env-password")
+		cfg, err := LoadClientConfig("")
+		assert.NoError(t, err)
+		assert.Equal(t, "env-password", cfg.Password)
+	})
+
+	t.Run("Empty file is accepted", func(t *testing.T) {
+		cfg, err := LoadClientConfigUnvalidated(createTempConfig(t, ""))
+		assert.NoError(t, err)
+		assert.Equal(t, "http://localhost:9090", cfg.Server)
+	})
+}
+
+func TestExampleConfigsAreStrictlyValid(t *testing.T) {
+	assert.NoError(t, loadFromFile(&Config{}, "../../config.yaml.example"))
+
+	_, err := LoadClientConfigUnvalidated("../../client-config.yaml.example")
+	assert.NoError(t, err)
+}
+
+func TestConfigEnvExpansion(t *testing.T) {
+	t.Run("Expand variables and defaults", func(t *testing.T) {
+		t.Setenv("ACME_HOME", "/srv/acme")
+		t.Setenv("ACME_PASS", "s3cret")
+		configFile := createTempConfig(t, `
+expand_env: true
+client:
+  server: "${ACME_SERVER:-http://fallback:9090}"
+  password: "${ACME_PASS}"
+  workdir: "${ACME_HOME}/work"
+  default_reload_cmd: "echo $$HOME"
+`)
+		cfg, err := LoadClientConfig(configFile)
+		assert.NoError(t, err)
+		assert.Equal(t, "http://fallback:9090", cfg.Server)
+		assert.Equal(t, "s3cret", cfg.Password)
+		assert.Equal(t, "/srv/acme/work", cfg.WorkDir)
+		assert.Equal(t, "echo $HOME", cfg.DefaultReloadCmd)
+	})
+
+	t.Run("Override layer still wins over expanded values", func(t *testing.T) {
+		t.Setenv("ACME_PASS", "from-file-template")
+		t.Setenv("ACMEDELIVER_PASSWORD", "from-override")
+		configFile := createTempConfig(t, `
+expand_env: true
+client:
+  password: "${ACME_PASS}"
+`)
+		cfg, err := LoadClientConfig(configFile)
+		assert.NoError(t, err)
+		assert.Equal(t, "from-override", cfg.Password)
+	})
+
+	t.Run("Missing variable reports line", func(t *testing.T) {
+		configFile := createTempConfig(t, `expand_env: true
+client:
+  password: "${ACME_UNSET_FOR_TEST}"
+`)
+		_, err := LoadClientConfigUnvalidated(configFile)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "第 3 行: 环境变量 ACME_UNSET_FOR_TEST 未设置")
+	})
+
+	t.Run("Disabled by default", func(t *testing.T) {
+		t.Setenv("ACME_PASS", "s3cret")
+		configFile := createTempConfig(t, `
+client:
+  password: "${ACME_PASS}"
+`)
+		cfg, err := LoadClientConfigUnvalidated(configFile)
+		assert.NoError(t, err)
+		assert.Equal(t, "${ACME_PASS}", cfg.Password)
+	})
+
+	t.Run("Server config", func(t *testing.T) {
+		t.Setenv("ACME_PORT", "7171")
+		cfg := &Config{}
+		err := loadFromFile(cfg, createTempConfig(t, "expand_env: true\nport: \"${ACME_PORT}\"\nkey: \"${ACME_KEY:-default-key}\"\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, "7171", cfg.Port)
+		assert.Equal(t, "default-key", cfg.Key)
+	})
 }
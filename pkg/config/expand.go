@@ -0,0 +1,61 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// expandEnvSwitch 仅用于预读顶层 expand_env 开关
+type expandEnvSwitch struct {
+	ExpandEnv bool `yaml:"expand_env" json:"expand_env" toml:"expand_env"`
+}
+
+// prepareConfigData 在解析前对配置文件原始内容做预处理
+// 顶层 expand_env: true 时展开 ${VAR} / ${VAR:-default}，$$ 表示字面量 $
+func prepareConfigData(data []byte, format string) ([]byte, error) {
+	var sw expandEnvSwitch
+	// 此处仅探测开关，语法错误留给正式解析阶段报告
+	if err := unmarshalLax(data, format, &sw); err != nil || !sw.ExpandEnv {
+		return data, nil
+	}
+	return expandEnv(data)
+}
+
+// expandEnv 按行展开环境变量，便于在错误信息中给出 YAML 行号
+// 未设置且没有默认值的变量视为错误
+func expandEnv(data []byte) ([]byte, error) {
+	lines := bytes.Split(data, []byte("\n"))
+	var errs []string
+
+	for i, line := range lines {
+		if bytes.IndexByte(line, '$') < 0 {
+			continue
+		}
+		expanded := os.Expand(string(line), func(name string) string {
+			// $$ 转义为字面量 $
+			if name == "$" {
+				return "$"
+			}
+			// ${VAR:-default}：未设置或为空时使用默认值
+			if key, def, ok := strings.Cut(name, ":-"); ok {
+				if v := os.Getenv(key); v != "" {
+					return v
+				}
+				return def
+			}
+			v, ok := os.LookupEnv(name)
+			if !ok {
+				errs = append(errs, fmt.Sprintf("第 %d 行: 环境变量 %s 未设置且没有默认值", i+1, name))
+			}
+			return v
+		})
+		lines[i] = []byte(expanded)
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("配置文件环境变量展开失败（可使用 ${VAR:-默认值}，字面量 $ 请写作 $$）:\n  %s", strings.Join(errs, "\n  "))
+	}
+	return bytes.Join(lines, []byte("\n")), nil
+}
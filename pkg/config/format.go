@@ -0,0 +1,196 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// 配置文件格式
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+	FormatTOML = "toml"
+)
+
+// ParseFormat 解析配置格式名称（--format 参数），空值视为 yaml
+func ParseFormat(s string) (string, error) {
+	switch strings.ToLower(s) {
+	case "", "yaml", "yml":
+		return FormatYAML, nil
+	case FormatJSON:
+		return FormatJSON, nil
+	case FormatTOML:
+		return FormatTOML, nil
+	default:
+		return "", fmt.Errorf("不支持的配置格式: %q（可选 yaml/json/toml）", s)
+	}
+}
+
+// formatFromPath 按扩展名判断配置格式，未知扩展名按 YAML 处理以兼容旧配置
+func formatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".toml":
+		return FormatTOML
+	default:
+		return FormatYAML
+	}
+}
+
+// jsonUnknownFieldPattern 匹配 encoding/json DisallowUnknownFields 的错误
+var jsonUnknownFieldPattern = regexp.MustCompile(`^json: unknown field "(.+)"$`)
+
+// decodeConfig 按格式解析配置
+// 严格模式下拒绝未知字段，并给出最接近的合法字段名
+func decodeConfig(data []byte, format string, out interface{}) error {
+	switch format {
+	case FormatJSON:
+		if laxConfig.Load() {
+			if len(bytes.TrimSpace(data)) == 0 {
+				return nil
+			}
+			return json.Unmarshal(data, out)
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(out); err != nil {
+			// 空文件视为无配置
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if m := jsonUnknownFieldPattern.FindStringSubmatch(err.Error()); m != nil {
+				return unknownKeysError([]string{m[1]}, out)
+			}
+			return err
+		}
+		return nil
+
+	case FormatTOML:
+		md, err := toml.Decode(string(data), out)
+		if err != nil {
+			return err
+		}
+		if laxConfig.Load() {
+			return nil
+		}
+		var unknown []string
+		for _, key := range md.Undecoded() {
+			unknown = append(unknown, key.String())
+		}
+		if len(unknown) > 0 {
+			return unknownKeysError(unknown, out)
+		}
+		return nil
+
+	default:
+		return decodeYAML(data, out)
+	}
+}
+
+// unknownKeysError 构造 JSON/TOML 未知字段错误
+// 这两种格式不提供字段所属类型，按全部合法字段名给出建议
+func unknownKeysError(keys []string, out interface{}) error {
+	known := make(map[string][]string)
+	collectYAMLKeys(reflect.TypeOf(out), known)
+	var candidates []string
+	for _, names := range known {
+		candidates = append(candidates, names...)
+	}
+
+	lines := make([]string, 0, len(keys))
+	for _, key := range keys {
+		// TOML 返回完整路径（如 client.sites.reload_cmd），按最后一级字段名匹配
+		field := key
+		if i := strings.LastIndex(key, "."); i >= 0 {
+			field = key[i+1:]
+		}
+		if suggestion := suggestKey(field, candidates); suggestion != "" {
+			lines = append(lines, fmt.Sprintf("未知字段 %s，是否想写 %s?", key, suggestion))
+		} else {
+			lines = append(lines, fmt.Sprintf("未知字段 %s", key))
+		}
+	}
+	return errors.New("配置文件包含未知字段（可使用 --lax-config 忽略）:\n  " + strings.Join(lines, "\n  "))
+}
+
+// unmarshalLax 宽松解析配置，用于探测开关字段和 include 合并
+func unmarshalLax(data []byte, format string, out interface{}) error {
+	switch format {
+	case FormatJSON:
+		if len(bytes.TrimSpace(data)) == 0 {
+			return nil
+		}
+		return json.Unmarshal(data, out)
+	case FormatTOML:
+		return toml.Unmarshal(data, out)
+	default:
+		return yaml.Unmarshal(data, out)
+	}
+}
+
+// normalizeValue 将 JSON/TOML 解析出的通用结构统一为 YAML 解析的形态
+// （TOML 的表数组为 []map[string]interface{}，合并逻辑要求 []interface{}）
+func normalizeValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			val[k] = normalizeValue(item)
+		}
+		return val
+	case []map[string]interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = normalizeValue(item)
+		}
+		return out
+	case []interface{}:
+		for i, item := range val {
+			val[i] = normalizeValue(item)
+		}
+		return val
+	default:
+		return v
+	}
+}
+
+// GenerateConfig 按指定格式生成示例配置
+// YAML 保留注释；JSON/TOML 由示例配置转换而来
+func GenerateConfig(format string) (string, error) {
+	example := GenerateExampleConfig()
+	if format == FormatYAML {
+		return example, nil
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal([]byte(example), &cfg); err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(&cfg); err != nil {
+			return "", err
+		}
+	case FormatTOML:
+		if err := toml.NewEncoder(&buf).Encode(&cfg); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("不支持的配置格式: %q", format)
+	}
+	return buf.String(), nil
+}
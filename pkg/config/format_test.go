@@ -0,0 +1,202 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const roundTripYAML = `
+port: "7070"
+key: "file-key"
+tls: true
+ip_whitelist: "10.0.0.0/8"
+duplicate_policy: "evict"
+pong_timeout: 60
+client:
+  server: "wss://acme.example.com:9443"
+  password: "file-password"
+  workdir: "/var/lib/acme"
+  ip_mode: 4
+  debug: true
+  domains: ["example.com", "www.example.com"]
+  daemon:
+    enabled: true
+    heartbeat_interval: 30
+    pong_timeout: 90
+  subscribe: ["example.com"]
+  sites:
+    - domain: "example.com"
+      cert_path: "/etc/nginx/ssl/cert.pem"
+      key_path: "/etc/nginx/ssl/key.pem"
+      reloadcmd: "systemctl reload nginx"
+    - domain: "*.example.com"
+      fullchain_path: "/etc/nginx/ssl/{domain}/fullchain.pem"
+`
+
+const roundTripJSON = `{
+  "port": "7070",
+  "key": "file-key",
+  "tls": true,
+  "ip_whitelist": "10.0.0.0/8",
+  "duplicate_policy": "evict",
+  "pong_timeout": 60,
+  "client": {
+    "server": "wss://acme.example.com:9443",
+    "password": "file-password",
+    "workdir": "/var/lib/acme",
+    "ip_mode": 4,
+    "debug": true,
+    "domains": ["example.com", "www.example.com"],
+    "daemon": {"enabled": true, "heartbeat_interval": 30, "pong_timeout": 90},
+    "subscribe": ["example.com"],
+    "sites": [
+      {
+        "domain": "example.com",
+        "cert_path": "/etc/nginx/ssl/cert.pem",
+        "key_path": "/etc/nginx/ssl/key.pem",
+        "reloadcmd": "systemctl reload nginx"
+      },
+      {"domain": "*.example.com", "fullchain_path": "/etc/nginx/ssl/{domain}/fullchain.pem"}
+    ]
+  }
+}`
+
+const roundTripTOML = `
+port = "7070"
+key = "file-key"
+tls = true
+ip_whitelist = "10.0.0.0/8"
+duplicate_policy = "evict"
+pong_timeout = 60
+
+[client]
+server = "wss://acme.example.com:9443"
+password = "file-password"
+workdir = "/var/lib/acme"
+ip_mode = 4
+debug = true
+domains = ["example.com", "www.example.com"]
+subscribe = ["example.com"]
+
+[client.daemon]
+enabled = true
+heartbeat_interval = 30
+pong_timeout = 90
+
+[[client.sites]]
+domain = "example.com"
+cert_path = "/etc/nginx/ssl/cert.pem"
+key_path = "/etc/nginx/ssl/key.pem"
+reloadcmd = "systemctl reload nginx"
+
+[[client.sites]]
+domain = "*.example.com"
+fullchain_path = "/etc/nginx/ssl/{domain}/fullchain.pem"
+`
+
+// writeNamedConfig 写入指定文件名的配置文件
+func writeNamedConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestConfigFormatsRoundTrip(t *testing.T) {
+	sources := map[string]string{
+		"config.yaml": roundTripYAML,
+		"config.json": roundTripJSON,
+		"config.toml": roundTripTOML,
+	}
+
+	servers := make(map[string]*Config)
+	clients := make(map[string]*ClientConfig)
+	for name, content := range sources {
+		path := writeNamedConfig(t, name, content)
+
+		srv := &Config{}
+		assert.NoError(t, loadFromFile(srv, path), name)
+		servers[name] = srv
+
+		cli, err := LoadClientConfig(path)
+		assert.NoError(t, err, name)
+		clients[name] = cli
+	}
+
+	want := servers["config.yaml"]
+	assert.Equal(t, "7070", want.Port)
+	assert.Equal(t, "evict", want.DuplicatePolicy)
+	if assert.NotNil(t, want.Client) {
+		assert.Len(t, want.Client.Sites, 2)
+		assert.Equal(t, 30, want.Client.Daemon.HeartbeatInterval)
+	}
+
+	for _, name := range []string{"config.json", "config.toml"} {
+		assert.Equal(t, want, servers[name], "服务端配置 %s 应与 YAML 一致", name)
+		assert.Equal(t, clients["config.yaml"], clients[name], "客户端配置 %s 应与 YAML 一致", name)
+	}
+}
+
+func TestConfigFormatsUnknownFields(t *testing.T) {
+	t.Run("JSON", func(t *testing.T) {
+		path := writeNamedConfig(t, "config.json", `{"client": {"password": "x", "sites": [{"domain": "a.com", "reload_cmd": "true"}]}}`)
+		_, err := LoadClientConfigUnvalidated(path)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "未知字段 reload_cmd，是否想写 reloadcmd?")
+	})
+
+	t.Run("TOML", func(t *testing.T) {
+		path := writeNamedConfig(t, "config.toml", "[client]\npasword = \"x\"\n")
+		_, err := LoadClientConfigUnvalidated(path)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "未知字段 client.pasword，是否想写 password?")
+	})
+
+	t.Run("Lax mode", func(t *testing.T) {
+		SetLaxConfig(true)
+		defer SetLaxConfig(false)
+
+		path := writeNamedConfig(t, "config.toml", "[client]\npassword = \"x\"\npasword = \"y\"\n")
+		cfg, err := LoadClientConfigUnvalidated(path)
+		assert.NoError(t, err)
+		assert.Equal(t, "x", cfg.Password)
+	})
+}
+
+func TestConfigIncludeMixedFormats(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": "include: [\"sites.toml\", \"extra.json\"]\nclient:\n  password: \"main\"\n  subscribe: [\"a.example.com\"]\n",
+		"sites.toml":  "[client]\nsubscribe = [\"b.example.com\"]\n\n[[client.sites]]\ndomain = \"b.example.com\"\n\n[client.daemon]\nheartbeat_interval = 45\n",
+		"extra.json":  `{"client": {"debug": true, "sites": [{"domain": "c.example.com"}]}}`,
+	})
+
+	cfg, err := LoadClientConfig(filepath.Join(dir, "config.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.example.com", "b.example.com"}, cfg.Subscribe)
+	assert.Equal(t, 45, cfg.Daemon.HeartbeatInterval)
+	assert.True(t, cfg.Debug)
+	if assert.Len(t, cfg.Sites, 2) {
+		assert.Equal(t, "b.example.com", cfg.Sites[0].Domain)
+		assert.Equal(t, "c.example.com", cfg.Sites[1].Domain)
+	}
+}
+
+func TestGenerateConfig(t *testing.T) {
+	yamlCfg := &Config{}
+	assert.NoError(t, loadFromFile(yamlCfg, writeNamedConfig(t, "config.yaml", GenerateExampleConfig())))
+
+	for _, format := range []string{FormatJSON, FormatTOML} {
+		out, err := GenerateConfig(format)
+		assert.NoError(t, err)
+
+		cfg := &Config{}
+		assert.NoError(t, loadFromFile(cfg, writeNamedConfig(t, "config."+format, out)), format)
+		assert.Equal(t, yamlCfg, cfg, "%s 示例配置应与 YAML 示例一致", format)
+	}
+
+	_, err := ParseFormat("ini")
+	assert.Error(t, err)
+}
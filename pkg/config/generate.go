@@ -0,0 +1,123 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// 未配置时客户端使用的服务端地址与工作目录
+const (
+	DefaultClientServer  = "http://localhost:9090"
+	DefaultClientWorkDir = "/tmp/acme"
+)
+
+// siteGuess 常见 Web 服务器的证书目录约定：配置目录存在时按该约定生成部署路径
+type siteGuess struct {
+	confDir   string // 存在时认为安装了该服务器
+	sslDir    string // 证书目录，{domain} 为域名占位符
+	reloadCmd string
+}
+
+// siteGuesses 按优先级排列，都未检测到时使用第一项（nginx）
+var siteGuesses = []siteGuess{
+	{"/etc/nginx", "/etc/nginx/ssl/{domain}", "systemctl reload nginx"},
+	{"/etc/apache2", "/etc/apache2/ssl/{domain}", "systemctl reload apache2"},
+	{"/etc/httpd", "/etc/httpd/ssl/{domain}", "systemctl reload httpd"},
+}
+
+// GuessSiteDeployConfig 按本机已安装的 Web 服务器推测 domain 的部署路径与重载命令
+// 路径使用 {domain} 占位符；未检测到已知服务器时使用 nginx 的约定 /etc/nginx/ssl/{domain}/
+func GuessSiteDeployConfig(domain string) SiteDeployConfig {
+	guess := siteGuesses[0]
+	for _, g := range siteGuesses {
+		if info, err := os.Stat(g.confDir); err == nil && info.IsDir() {
+			guess = g
+			break
+		}
+	}
+	return SiteDeployConfig{
+		Domain:        domain,
+		CertPath:      guess.sslDir + "/cert.pem",
+		KeyPath:       guess.sslDir + "/key.pem",
+		FullchainPath: guess.sslDir + "/fullchain.pem",
+		ReloadCmd:     guess.reloadCmd,
+	}
+}
+
+// GenerateClientConfig 生成客户端 YAML 配置，可直接由 LoadClientConfig 加载
+// domains 与 subscribe 取自站点域名；站点的 reloadcmd 以注释形式输出，确认后取消注释即可生效
+// server、workDir 为空时使用 DefaultClientServer、DefaultClientWorkDir；password 需替换为服务端的 key
+func GenerateClientConfig(sites []SiteDeployConfig, workDir, server string) string {
+	if server == "" {
+		server = DefaultClientServer
+	}
+	if workDir == "" {
+		workDir = DefaultClientWorkDir
+	}
+
+	var b strings.Builder
+	b.WriteString("# acmeDeliver 客户端配置（由 --gen-client-config 生成）\n")
+	b.WriteString("client:\n")
+	fmt.Fprintf(&b, "  server: %s\n", strconv.Quote(server))
+	b.WriteString("  password: \"your-strong-password-here\"  # 替换为服务端的 key\n")
+	b.WriteString("  # client_id: \"web-01\"  # 客户端标识，留空时使用主机名\n")
+	fmt.Fprintf(&b, "  workdir: %s  # 必须使用绝对路径\n", strconv.Quote(workDir))
+	b.WriteString("  ip_mode: 0  # 0=默认, 4=IPv4, 6=IPv6\n")
+	b.WriteString("  debug: false\n")
+
+	b.WriteString("\n  # Pull 模式（--deploy、--list）管理的域名\n")
+	writeYAMLDomains(&b, "domains", sites)
+
+	b.WriteString("\n  daemon:\n")
+	b.WriteString("    enabled: false              # 是否启用 daemon 模式\n")
+	b.WriteString("    reconnect_interval: 30      # WebSocket 断线重连间隔（秒）\n")
+	b.WriteString("    heartbeat_interval: 60      # 心跳检测间隔（秒）\n")
+	b.WriteString("\n  # daemon 模式下订阅的域名\n")
+	writeYAMLDomains(&b, "subscribe", sites)
+
+	b.WriteString("\n  # 站点部署配置：路径中的 {domain} 自动替换为实际域名\n")
+	b.WriteString("  # 确认重载命令后取消 reloadcmd 的注释\n")
+	if len(sites) == 0 {
+		b.WriteString("  sites: []\n")
+		return b.String()
+	}
+	b.WriteString("  sites:\n")
+	for _, site := range sites {
+		fmt.Fprintf(&b, "    - domain: %s\n", strconv.Quote(site.Domain))
+		for _, p := range []struct {
+			name  string
+			value string
+		}{
+			{"cert_path", site.CertPath},
+			{"key_path", site.KeyPath},
+			{"fullchain_path", site.FullchainPath},
+			{"bundle_path", site.BundlePath},
+			{"combined_path", site.CombinedPath},
+			{"pkcs12_path", site.PKCS12Path},
+		} {
+			if p.value != "" {
+				fmt.Fprintf(&b, "      %s: %s\n", p.name, strconv.Quote(p.value))
+			}
+		}
+		reloadCmd := site.ReloadCmd
+		if reloadCmd == "" {
+			reloadCmd = siteGuesses[0].reloadCmd
+		}
+		fmt.Fprintf(&b, "      # reloadcmd: %s\n", strconv.Quote(reloadCmd))
+	}
+	return b.String()
+}
+
+// writeYAMLDomains 以 YAML 列表写入站点域名，没有站点时写入空列表
+func writeYAMLDomains(b *strings.Builder, key string, sites []SiteDeployConfig) {
+	if len(sites) == 0 {
+		fmt.Fprintf(b, "  %s: []\n", key)
+		return
+	}
+	fmt.Fprintf(b, "  %s:\n", key)
+	for _, site := range sites {
+		fmt.Fprintf(b, "    - %s\n", strconv.Quote(site.Domain))
+	}
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateClientConfig(t *testing.T) {
+	sites := []SiteDeployConfig{GuessSiteDeployConfig("a.com"), GuessSiteDeployConfig("*.b.com")}
+	content := GenerateClientConfig(sites, t.TempDir(), "https://acme.example.com:9443")
+
+	cfg, err := LoadClientConfig(createTempConfig(t, content))
+	require.NoError(t, err, content)
+	assert.Equal(t, "https://acme.example.com:9443", cfg.Server)
+	assert.Equal(t, []string{"a.com", "*.b.com"}, cfg.Domains)
+	assert.Equal(t, []string{"a.com", "*.b.com"}, cfg.Subscribe)
+	require.Len(t, cfg.Sites, 2)
+	assert.Equal(t, sites[0].CertPath, cfg.Sites[0].CertPath)
+	assert.Contains(t, cfg.Sites[0].CertPath, "{domain}")
+	// reloadcmd 以注释形式输出
+	assert.Empty(t, cfg.Sites[0].ReloadCmd)
+	assert.Contains(t, content, "# reloadcmd: \""+sites[0].ReloadCmd+"\"")
+}
+
+func TestGenerateClientConfigDefaults(t *testing.T) {
+	content := GenerateClientConfig(nil, "", "")
+	assert.Contains(t, content, DefaultClientServer)
+
+	cfg, err := LoadClientConfig(createTempConfig(t, content))
+	require.NoError(t, err, content)
+	assert.Equal(t, DefaultClientWorkDir, cfg.WorkDir)
+	assert.Empty(t, cfg.Sites)
+}
@@ -0,0 +1,222 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// concatListKeys 合并时拼接（而非覆盖）的列表字段
+var concatListKeys = map[string]bool{
+	"sites":     true,
+	"subscribe": true,
+	"domains":   true,
+}
+
+// includeSwitch 仅用于预读顶层 include / expand_env
+type includeSwitch struct {
+	Include   []string `yaml:"include" json:"include" toml:"include"`
+	ExpandEnv bool     `yaml:"expand_env" json:"expand_env" toml:"expand_env"`
+}
+
+// readConfigTree 读取配置文件及其 include 的所有文件
+// 返回配置内容及其格式，以及参与合并的全部文件（绝对路径，按合并顺序）
+// 存在 include 时各文件可以使用不同格式，合并结果统一为 YAML
+func readConfigTree(path string) ([]byte, string, []string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	format := formatFromPath(path)
+
+	var sw includeSwitch
+	if err := unmarshalLax(data, format, &sw); err != nil || len(sw.Include) == 0 {
+		// 无 include：保持单文件行为，解析错误留给正式解析阶段报告
+		data, err := prepareConfigData(data, format)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		return data, format, []string{absPath}, nil
+	}
+
+	m := &configMerger{
+		merged:   make(map[string]interface{}),
+		siteFrom: make(map[string]string),
+	}
+	if err := m.loadFile(absPath, false); err != nil {
+		return nil, "", nil, err
+	}
+	delete(m.merged, "include")
+
+	out, err := yaml.Marshal(m.merged)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("序列化合并后的配置失败: %w", err)
+	}
+	return out, FormatYAML, m.files, nil
+}
+
+// configMerger 按顺序深度合并配置文件
+// 标量后者覆盖前者；sites/subscribe/domains 列表拼接并检测重复域名
+type configMerger struct {
+	merged   map[string]interface{}
+	files    []string
+	stack    []string          // 当前 include 链，用于检测循环
+	siteFrom map[string]string // 站点域名 -> 首次定义所在文件
+}
+
+// loadFile 合并单个文件，随后依次合并其 include 的文件
+// expand 为 true 时表示上层文件已启用 expand_env，子文件继承该设置
+func (m *configMerger) loadFile(path string, expand bool) error {
+	for _, p := range m.stack {
+		if p == path {
+			chain := append(append([]string{}, m.stack...), path)
+			return fmt.Errorf("检测到循环 include: %s", strings.Join(chain, " -> "))
+		}
+	}
+	m.stack = append(m.stack, path)
+	defer func() { m.stack = m.stack[:len(m.stack)-1] }()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	format := formatFromPath(path)
+	var sw includeSwitch
+	if err := unmarshalLax(data, format, &sw); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	expand = expand || sw.ExpandEnv
+	if expand {
+		if data, err = expandEnv(data); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	// 逐文件严格校验，保证错误信息中的行号对应原文件
+	if err := decodeConfig(data, format, &Config{}); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	var doc map[string]interface{}
+	if err := unmarshalLax(data, format, &doc); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	normalizeValue(doc)
+	if err := m.mergeMap(m.merged, doc, path); err != nil {
+		return err
+	}
+	m.files = append(m.files, path)
+
+	dir := filepath.Dir(path)
+	for _, pattern := range sw.Include {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("%s: include 路径 %q 无效: %w", path, pattern, err)
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("%s: include 路径 %q 未匹配到任何文件", path, pattern)
+		}
+		for _, match := range matches {
+			if err := m.loadFile(match, expand); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mergeMap 将 src 深度合并到 dst
+func (m *configMerger) mergeMap(dst, src map[string]interface{}, file string) error {
+	for key, srcVal := range src {
+		if key == "include" {
+			continue
+		}
+
+		if concatListKeys[key] {
+			if srcList, ok := srcVal.([]interface{}); ok {
+				dstList, _ := dst[key].([]interface{})
+				merged, err := m.concatList(key, dstList, srcList, file)
+				if err != nil {
+					return err
+				}
+				dst[key] = merged
+				continue
+			}
+		}
+
+		// 嵌套 map 逐层合并（目标不存在时新建，以便对其中的列表做重复检测）
+		if srcMap, ok := srcVal.(map[string]interface{}); ok {
+			dstMap, ok := dst[key].(map[string]interface{})
+			if !ok {
+				dstMap = make(map[string]interface{})
+				dst[key] = dstMap
+			}
+			if err := m.mergeMap(dstMap, srcMap, file); err != nil {
+				return err
+			}
+			continue
+		}
+
+		dst[key] = srcVal
+	}
+	return nil
+}
+
+// concatList 拼接列表字段
+// sites 中重复的域名视为错误；subscribe/domains 中的重复项去重并告警
+func (m *configMerger) concatList(key string, dst, src []interface{}, file string) ([]interface{}, error) {
+	if key == "sites" {
+		for _, item := range src {
+			site, _ := item.(map[string]interface{})
+			domain, _ := site["domain"].(string)
+			if domain != "" {
+				if prev, ok := m.siteFrom[domain]; ok {
+					return nil, fmt.Errorf("站点 %s 重复定义（%s 与 %s）", domain, prev, file)
+				}
+				m.siteFrom[domain] = file
+			}
+			dst = append(dst, item)
+		}
+		return dst, nil
+	}
+
+	seen := make(map[string]bool, len(dst))
+	for _, item := range dst {
+		seen[fmt.Sprint(item)] = true
+	}
+	for _, item := range src {
+		k := fmt.Sprint(item)
+		if seen[k] {
+			slog.Warn("合并配置时发现重复域名，已忽略", "key", key, "domain", k, "file", file)
+			continue
+		}
+		seen[k] = true
+		dst = append(dst, item)
+	}
+	return dst, nil
+}
+
+// configFileSet 返回配置文件及其 include 的全部文件（绝对路径）
+// 解析失败时仅返回主文件，保证修复配置后仍能触发重载
+func configFileSet(path string) []string {
+	_, _, files, err := readConfigTree(path)
+	if err != nil || len(files) == 0 {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		return []string{path}
+	}
+	return files
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeConfigFiles 在临时目录中写入一组配置文件，返回目录路径
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestConfigInclude(t *testing.T) {
+	t.Run("Merge semantics", func(t *testing.T) {
+		dir := writeConfigFiles(t, map[string]string{
+			"config.yaml": `
+include:
+  - "sites/*.yaml"
+client:
+  server: "http://main:9090"
+  password: "main-password"
+  debug: false
+  subscribe: ["a.example.com"]
+  sites:
+    - domain: "a.example.com"
+      cert_path: "/a/cert.pem"
+  daemon:
+    heartbeat_interval: 30
+    reconnect_interval: 5
+`,
+			"sites/team-b.yaml": `
+client:
+  debug: true
+  subscribe: ["b.example.com", "a.example.com"]
+  sites:
+    - domain: "b.example.com"
+      cert_path: "/b/cert.pem"
+  daemon:
+    heartbeat_interval: 60
+`,
+			"sites/team-c.yaml": `
+client:
+  password: "team-c-password"
+  sites:
+    - domain: "c.example.com"
+      cert_path: "/c/cert.pem"
+`,
+		})
+
+		cfg, err := LoadClientConfig(filepath.Join(dir, "config.yaml"))
+		assert.NoError(t, err)
+
+		// 标量：后合并的文件覆盖前者
+		assert.Equal(t, "http://main:9090", cfg.Server)
+		assert.Equal(t, "team-c-password", cfg.Password)
+		assert.True(t, cfg.Debug)
+		// 嵌套 map 深度合并
+		assert.Equal(t, 60, cfg.Daemon.HeartbeatInterval)
+		assert.Equal(t, 5, cfg.Daemon.ReconnectInterval)
+		// 列表拼接，subscribe 重复项去重
+		assert.Equal(t, []string{"a.example.com", "b.example.com"}, cfg.Subscribe)
+		if assert.Len(t, cfg.Sites, 3) {
+			assert.Equal(t, "a.example.com", cfg.Sites[0].Domain)
+			assert.Equal(t, "b.example.com", cfg.Sites[1].Domain)
+			assert.Equal(t, "c.example.com", cfg.Sites[2].Domain)
+		}
+	})
+
+	t.Run("Duplicate site domain", func(t *testing.T) {
+		dir := writeConfigFiles(t, map[string]string{
+			"config.yaml": "include: [\"extra.yaml\"]\nclient:\n  sites:\n    - domain: \"a.example.com\"\n",
+			"extra.yaml":  "client:\n  sites:\n    - domain: \"a.example.com\"\n",
+		})
+		_, err := LoadClientConfigUnvalidated(filepath.Join(dir, "config.yaml"))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "站点 a.example.com 重复定义")
+	})
+
+	t.Run("Include cycle", func(t *testing.T) {
+		dir := writeConfigFiles(t, map[string]string{
+			"a.yaml": "include: [\"b.yaml\"]\n",
+			"b.yaml": "include: [\"a.yaml\"]\n",
+		})
+		_, err := LoadClientConfigUnvalidated(filepath.Join(dir, "a.yaml"))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "检测到循环 include")
+	})
+
+	t.Run("Missing glob", func(t *testing.T) {
+		dir := writeConfigFiles(t, map[string]string{
+			"config.yaml": "include: [\"conf.d/*.yaml\"]\n",
+		})
+		_, err := LoadClientConfigUnvalidated(filepath.Join(dir, "config.yaml"))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "未匹配到任何文件")
+	})
+
+	t.Run("Unknown field in included file", func(t *testing.T) {
+		dir := writeConfigFiles(t, map[string]string{
+			"config.yaml": "include: [\"extra.yaml\"]\n",
+			"extra.yaml":  "client:\n  sites:\n    - domain: \"a.example.com\"\n      reload_cmd: \"true\"\n",
+		})
+		_, err := LoadClientConfigUnvalidated(filepath.Join(dir, "config.yaml"))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "extra.yaml")
+		assert.Contains(t, err.Error(), "第 4 行: 未知字段 reload_cmd")
+	})
+
+	t.Run("Server config", func(t *testing.T) {
+		dir := writeConfigFiles(t, map[string]string{
+			"config.yaml":   "include: [\"override.yaml\"]\nport: \"7070\"\nkey: \"main-key\"\ntls: true\n",
+			"override.yaml": "port: \"8080\"\ntls: false\n",
+		})
+		cfg := &Config{}
+		assert.NoError(t, loadFromFile(cfg, filepath.Join(dir, "config.yaml")))
+		assert.Equal(t, "8080", cfg.Port)
+		assert.Equal(t, "main-key", cfg.Key)
+		assert.False(t, cfg.TLS)
+	})
+}
+
+func TestClientConfigWatcherIncludedFile(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": "include: [\"sites.yaml\"]\nclient:\n  password: \"test\"\n",
+		"sites.yaml":  "client:\n  sites:\n    - domain: \"old.example.com\"\n",
+	})
+	configFile := filepath.Join(dir, "config.yaml")
+
+	initialCfg, err := LoadClientConfig(configFile)
+	assert.NoError(t, err)
+
+	watcher := NewClientConfigWatcher(configFile, initialCfg)
+	reloaded := make(chan *ClientConfig, 8)
+	watcher.RegisterCallback(func(old, new *ClientConfig) {
+		select {
+		case reloaded <- new:
+		default:
+		}
+	})
+	assert.NoError(t, watcher.Start())
+	defer watcher.Stop()
+
+	// 修改被 include 的文件应触发重载
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "sites.yaml"),
+		[]byte("client:\n  sites:\n    - domain: \"new.example.com\"\n"), 0644))
+
+	// WriteFile 先截断再写入，可能先触发一次读到空文件的重载，等待最终内容
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case cfg := <-reloaded:
+			if len(cfg.Sites) == 1 && cfg.Sites[0].Domain == "new.example.com" {
+				return
+			}
+		case <-timeout:
+			t.Fatal("修改 include 文件后未触发配置重载")
+		}
+	}
+}
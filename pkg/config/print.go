@@ -0,0 +1,74 @@
+package config
+
+import (
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RedactedValue 打印配置时替换密码、令牌等敏感值，未设置的值保持为空
+const RedactedValue = "******"
+
+// ErrConfigPrinted InitConfig 已按 --print-config 输出合并后的配置，调用方应直接退出
+var ErrConfigPrinted = errors.New("已输出配置")
+
+// printOutput --print-config 的输出位置，测试中可替换
+var printOutput io.Writer = os.Stdout
+
+// redact 非空时返回 RedactedValue
+func redact(s string) string {
+	if s == "" {
+		return ""
+	}
+	return RedactedValue
+}
+
+// redactURL 将 URL 中的密码替换为 xxxxx（如 redis://:password@host），无法解析时整体隐藏
+func redactURL(s string) string {
+	if s == "" {
+		return ""
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return RedactedValue
+	}
+	return u.Redacted()
+}
+
+// Redacted 返回隐藏了 key、admin_token、redis_url 中的密码与客户端密码的配置副本
+func (c *Config) Redacted() *Config {
+	out := *c
+	out.Key = redact(c.Key)
+	out.AdminToken = redact(c.AdminToken)
+	out.RedisURL = redactURL(c.RedisURL)
+	if c.Client != nil {
+		out.Client = c.Client.Redacted()
+	}
+	return &out
+}
+
+// Redacted 返回隐藏了 password、admin_token 与站点 pkcs12_password 的配置副本
+func (c *ClientConfig) Redacted() *ClientConfig {
+	out := *c
+	out.Password = redact(c.Password)
+	out.AdminToken = redact(c.AdminToken)
+	out.Sites = slices.Clone(c.Sites)
+	for i := range out.Sites {
+		out.Sites[i].PKCS12Password = redact(out.Sites[i].PKCS12Password)
+	}
+	return &out
+}
+
+// PrintConfig 以 YAML 输出配置，调用方负责先隐藏敏感值（Redacted）
+func PrintConfig(w io.Writer, cfg any) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(cfg); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestInitConfigPrintConfig(t *testing.T) {
+	var buf bytes.Buffer
+	oldOutput, oldArgs := printOutput, os.Args
+	printOutput = &buf
+	t.Cleanup(func() { printOutput, os.Args = oldOutput, oldArgs })
+
+	configFile := createTempConfig(t, testServerConfigContent+"admin_token: \"file-token\"\nredis_url: \"redis://:redis-pass@127.0.0.1:6379/0\"\n")
+	t.Setenv("ACMEDELIVER_PORT", "8080")
+	t.Setenv("ACMEDELIVER_BIND", "0.0.0.0")
+	os.Args = []string{"test", "-c", configFile, "-b", "127.0.0.2", "--print-config"}
+	resetFlags()
+
+	err := InitConfig()
+	require.True(t, errors.Is(err, ErrConfigPrinted), "InitConfig() error = %v", err)
+
+	var printed Config
+	require.NoError(t, yaml.Unmarshal(buf.Bytes(), &printed))
+	assert.Equal(t, "8080", printed.Port, "环境变量应覆盖配置文件")
+	assert.Equal(t, "127.0.0.2", printed.Bind, "命令行应覆盖环境变量")
+	assert.Equal(t, RedactedValue, printed.Key)
+	assert.Equal(t, RedactedValue, printed.AdminToken)
+	assert.Equal(t, "redis://:xxxxx@127.0.0.1:6379/0", printed.RedisURL)
+	for _, secret := range []string{"file-key", "file-token", "redis-pass"} {
+		assert.NotContains(t, buf.String(), secret)
+	}
+}
+
+func TestClientConfigRedacted(t *testing.T) {
+	cfg := &ClientConfig{
+		Server:     "https://acme.example.com",
+		Password:   "client-password",
+		AdminToken: "client-admin-token",
+		Sites: []SiteDeployConfig{
+			{Domain: "a.example.com", PKCS12Password: "p12-password"},
+			{Domain: "b.example.com"},
+		},
+	}
+	redacted := cfg.Redacted()
+	assert.Equal(t, RedactedValue, redacted.Password)
+	assert.Equal(t, RedactedValue, redacted.AdminToken)
+	assert.Equal(t, RedactedValue, redacted.Sites[0].PKCS12Password)
+	assert.Empty(t, redacted.Sites[1].PKCS12Password, "未设置的密码保持为空")
+	assert.Equal(t, "client-password", cfg.Password, "不应修改原配置")
+	assert.Equal(t, "p12-password", cfg.Sites[0].PKCS12Password, "不应修改原配置")
+
+	var buf bytes.Buffer
+	require.NoError(t, PrintConfig(&buf, (&Config{Client: cfg}).Redacted()))
+	assert.NotContains(t, buf.String(), "client-password")
+	assert.NotContains(t, buf.String(), "client-admin-token")
+	assert.Contains(t, buf.String(), "server: https://acme.example.com")
+}
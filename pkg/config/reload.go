@@ -0,0 +1,145 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+)
+
+// hotReloadFields 支持热重载的服务端配置项（yaml 字段名）
+var hotReloadFields = map[string]bool{
+	"ip_whitelist":             true,
+	"trust_proxy":              true,
+	"key":                      true,
+	"duplicate_policy":         true,
+	"allow_wildcard_subscribe": true,
+	"disable_legacy_auth":      true,
+	"ws_compression":           true,
+	"ws_compression_level":     true,
+	"pong_timeout":             true,
+	"max_message_size":         true,
+	"watch_debounce":           true,
+	"key_rotation_window":      true,
+	"max_cert_size_bytes":      true,
+	"sync_on_write":            true,
+	"active_domains":           true,
+	"crl_cache_ttl":            true,
+	"push_bytes_per_sec":       true,
+	"admin_token":              true,
+	"cert_filenames":           true,
+	"logging.level":            true,
+}
+
+// restartRequiredFields 需要重启服务才能生效的配置项（yaml 字段名）
+var restartRequiredFields = map[string]bool{
+	"port":                    true,
+	"bind":                    true,
+	"base_dir":                true,
+	"base_dirs":               true,
+	"tls":                     true,
+	"tls_port":                true,
+	"cert_file":               true,
+	"key_file":                true,
+	"client_ca_file":          true,
+	"ws_path":                 true,
+	"proactive_push_interval": true,
+	"cleanup_interval_hours":  true,
+	"cleanup_archive_days":    true,
+	"redis_url":               true,
+	"logging.format":          true,
+	"logging.output":          true,
+	"logging.max_size_mb":     true,
+	"logging.max_backups":     true,
+	"logging.max_age_days":    true,
+	"logging.add_source":      true,
+}
+
+// sensitiveFields 日志中不输出取值的配置项
+var sensitiveFields = map[string]bool{
+	"key":         true,
+	"admin_token": true,
+	"redis_url":   true, // 可能包含密码
+}
+
+// ReloadResult 一次配置重载的结果
+type ReloadResult struct {
+	Applied         []string // 已热重载的字段
+	RestartRequired []string // 已变更但需重启才能生效的字段（未应用）
+}
+
+// applyReload 比较前后两次配置文件内容，生成新的运行配置
+// 仅处理文件中实际变化的字段：可热重载的字段写入新配置，需重启的字段保持原值并记录
+func applyReload(active, oldFile, newFile *Config) (*Config, ReloadResult) {
+	next := *active
+	if oldFile == nil {
+		oldFile = active
+	}
+
+	var result ReloadResult
+	diffFields("", reflect.ValueOf(&next).Elem(), reflect.ValueOf(oldFile).Elem(), reflect.ValueOf(newFile).Elem(), &result)
+	return &next, result
+}
+
+// diffFields 逐字段比较前后两次配置，嵌套结构体的字段以 "父字段.子字段" 命名
+func diffFields(prefix string, next, oldVal, newVal reflect.Value, result *ReloadResult) {
+	t := next.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := prefix + strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		before, after := oldVal.Field(i), newVal.Field(i)
+
+		if !hotReloadFields[name] && !restartRequiredFields[name] {
+			if before.Kind() == reflect.Struct && hasNestedFields(name) {
+				diffFields(name+".", next.Field(i), before, after, result)
+			}
+			continue
+		}
+
+		if reflect.DeepEqual(before.Interface(), after.Interface()) {
+			continue
+		}
+
+		if hotReloadFields[name] {
+			next.Field(i).Set(after)
+			result.Applied = append(result.Applied, name)
+			continue
+		}
+		if sensitiveFields[name] {
+			result.RestartRequired = append(result.RestartRequired, name)
+		} else {
+			result.RestartRequired = append(result.RestartRequired,
+				fmt.Sprintf("%s: %v → %v", name, before.Interface(), after.Interface()))
+		}
+	}
+}
+
+// hasNestedFields 判断结构体字段下是否有需要比较的子字段
+func hasNestedFields(name string) bool {
+	prefix := name + "."
+	for field := range hotReloadFields {
+		if strings.HasPrefix(field, prefix) {
+			return true
+		}
+	}
+	for field := range restartRequiredFields {
+		if strings.HasPrefix(field, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// log 输出重载结果，需重启的变更以警告级别逐项列出
+func (r ReloadResult) log() {
+	if len(r.Applied) > 0 {
+		slog.Info("✅ 配置文件重载成功", "applied", strings.Join(r.Applied, ", "))
+	} else {
+		slog.Info("✅ 配置文件重载成功，无可热重载的变更")
+	}
+
+	if len(r.RestartRequired) > 0 {
+		slog.Warn("⚠️ 以下配置变更需要重启服务才能生效，本次未应用",
+			"count", len(r.RestartRequired),
+			"fields", strings.Join(r.RestartRequired, "; "))
+	}
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyReload(t *testing.T) {
+	// 运行配置：key 来自命令行，与文件不同
+	active := defaultConfig()
+	active.Key = "cli-key"
+	active.IPWhitelist = "10.0.0.1"
+
+	oldFile := defaultConfig()
+	oldFile.Key = "file-key"
+	oldFile.IPWhitelist = "10.0.0.1"
+
+	tests := []struct {
+		name        string
+		modify      func(c *Config)
+		wantApplied []string
+		wantRestart []string
+		check       func(t *testing.T, next *Config)
+	}{
+		{
+			name:        "无变化",
+			modify:      func(c *Config) {},
+			wantApplied: nil,
+			wantRestart: nil,
+			check: func(t *testing.T, next *Config) {
+				// 文件未改动的字段不覆盖命令行设置
+				assert.Equal(t, "cli-key", next.Key)
+			},
+		},
+		{
+			name: "热重载字段",
+			modify: func(c *Config) {
+				c.Key = "new-key"
+				c.IPWhitelist = "10.0.0.2"
+				c.TrustProxy = true
+				c.DuplicatePolicy = "evict"
+				c.WatchDebounce = 10
+				c.PongTimeout = 60
+			},
+			wantApplied: []string{"key", "ip_whitelist", "trust_proxy", "duplicate_policy", "pong_timeout", "watch_debounce"},
+			check: func(t *testing.T, next *Config) {
+				assert.Equal(t, "new-key", next.Key)
+				assert.Equal(t, "10.0.0.2", next.IPWhitelist)
+				assert.True(t, next.TrustProxy)
+				assert.Equal(t, "evict", next.DuplicatePolicy)
+				assert.Equal(t, 10, next.WatchDebounce)
+				assert.Equal(t, 60, next.PongTimeout)
+			},
+		},
+		{
+			name: "需重启字段",
+			modify: func(c *Config) {
+				c.Port = "9191"
+				c.Bind = "127.0.0.1"
+				c.BaseDir = "/srv/certs"
+				c.TLS = true
+			},
+			wantRestart: []string{"port: 9090 → 9191", "bind:  → 127.0.0.1", "base_dir: ./ → /srv/certs", "tls: false → true"},
+			check: func(t *testing.T, next *Config) {
+				assert.Equal(t, "9090", next.Port)
+				assert.Equal(t, "", next.Bind)
+				assert.Equal(t, "./", next.BaseDir)
+				assert.False(t, next.TLS)
+			},
+		},
+		{
+			name: "混合变更",
+			modify: func(c *Config) {
+				c.IPWhitelist = ""
+				c.TLSPort = "8443"
+			},
+			wantApplied: []string{"ip_whitelist"},
+			wantRestart: []string{"tls_port: 9443 → 8443"},
+			check: func(t *testing.T, next *Config) {
+				assert.Equal(t, "", next.IPWhitelist)
+				assert.Equal(t, "9443", next.TLSPort)
+			},
+		},
+		{
+			name: "嵌套字段",
+			modify: func(c *Config) {
+				c.Logging.Level = "debug"
+				c.Logging.Output = "/var/log/acmedeliver.log"
+			},
+			wantApplied: []string{"logging.level"},
+			wantRestart: []string{"logging.output:  → /var/log/acmedeliver.log"},
+			check: func(t *testing.T, next *Config) {
+				assert.Equal(t, "debug", next.Logging.Level)
+				assert.Equal(t, "", next.Logging.Output)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newFile := *oldFile
+			tt.modify(&newFile)
+
+			next, result := applyReload(active, oldFile, &newFile)
+			assert.Equal(t, tt.wantApplied, result.Applied)
+			assert.Equal(t, tt.wantRestart, result.RestartRequired)
+			tt.check(t, next)
+
+			// 原运行配置不应被修改
+			assert.Equal(t, "cli-key", active.Key)
+		})
+	}
+}
+
+func TestReloadConfigAppliesFileChanges(t *testing.T) {
+	path := createTempConfig(t, "port: \"9090\"\nkey: \"old-key\"\nip_whitelist: \"10.0.0.1\"\n")
+
+	initial := defaultConfig()
+	assert.NoError(t, loadFromFile(initial, path))
+	snapshot := *initial
+
+	mu.Lock()
+	prevGlobal, prevFile, prevCallbacks := GlobalConfig, fileConfig, reloadCallbacks
+	GlobalConfig, fileConfig, reloadCallbacks = initial, &snapshot, nil
+	mu.Unlock()
+	defer func() {
+		mu.Lock()
+		GlobalConfig, fileConfig, reloadCallbacks = prevGlobal, prevFile, prevCallbacks
+		mu.Unlock()
+	}()
+
+	var got *Config
+	RegisterReloadCallback(func(c *Config) { got = c })
+
+	assert.NoError(t, os.WriteFile(path, []byte("port: \"9191\"\nkey: \"new-key\"\nip_whitelist: \"10.0.0.1\"\n"), 0644))
+	reloadConfig(path)
+
+	if assert.NotNil(t, got) {
+		assert.Equal(t, "new-key", got.Key)
+		assert.Equal(t, "9090", got.Port, "端口变更需要重启，不应热重载")
+	}
+	assert.Equal(t, got, GetConfig())
+}
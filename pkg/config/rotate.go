@@ -0,0 +1,254 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 顶层 key 字段所在行（按格式）
+var (
+	yamlKeyLine = regexp.MustCompile(`(?m)^key:[ \t]*([^\n]*)$`)
+	tomlKeyLine = regexp.MustCompile(`(?m)^key[ \t]*=[ \t]*([^\n]*)$`)
+	tomlTable   = regexp.MustCompile(`(?m)^[ \t]*\[`)
+	jsonKeyPair = regexp.MustCompile(`"key"[ \t]*:[ \t]*("(?:[^"\\]|\\.)*")`)
+)
+
+// client 段中 password 字段所在行（按格式），站点的 pkcs12_password 不会匹配
+var (
+	yamlClientSection = regexp.MustCompile(`(?m)^client:[ \t]*(#[^\n]*)?$`)
+	yamlTopLevelLine  = regexp.MustCompile(`(?m)^[^ \t#\n]`)
+	yamlPasswordLine  = regexp.MustCompile(`(?m)^[ \t]+password:[ \t]*([^\n]*)$`)
+	tomlClientTable   = regexp.MustCompile(`(?m)^[ \t]*\[client\][ \t]*(#[^\n]*)?$`)
+	tomlPasswordLine  = regexp.MustCompile(`(?m)^[ \t]*password[ \t]*=[ \t]*([^\n]*)$`)
+	jsonPasswordPair  = regexp.MustCompile(`"password"[ \t]*:[ \t]*("(?:[^"\\]|\\.)*")`)
+)
+
+// UpdateKey 提交轮换后的认证密钥
+// 内存中的配置立即更新；密钥来自配置文件时同时以临时文件 + rename 的方式原子写回，
+// 返回值表示是否已写回配置文件。密钥来自命令行或环境变量时仅更新内存并告警
+func UpdateKey(newKey string) (bool, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if GlobalConfig == nil {
+		return false, fmt.Errorf("配置尚未初始化")
+	}
+	oldKey := GlobalConfig.Key
+	updated := *GlobalConfig
+	updated.Key = newKey
+	GlobalConfig = &updated
+
+	if updated.ConfigFile == "" || fileConfig == nil {
+		slog.Warn("未使用配置文件，新密钥仅在内存中生效，重启前请更新启动参数或环境变量")
+		return false, nil
+	}
+	if fileConfig.Key != oldKey {
+		slog.Warn("当前密钥来自命令行或环境变量，新密钥未写入配置文件，重启前请同步更新",
+			"config", updated.ConfigFile)
+		return false, nil
+	}
+
+	path, err := persistKey(updated.ConfigFile, newKey)
+	if err != nil {
+		return false, err
+	}
+	// 同步文件快照，避免随后的文件变更事件被当作配置修改
+	fileConfig.Key = newKey
+	slog.Info("🔑 新密钥已写入配置文件", "file", path)
+	return true, nil
+}
+
+// persistKey 将新密钥写入定义了顶层 key 的配置文件
+// 存在 include 时后合并的文件优先级更高，因此从后往前查找
+func persistKey(path, newKey string) (string, error) {
+	files := configFileSet(path)
+	for i := len(files) - 1; i >= 0; i-- {
+		data, err := os.ReadFile(files[i])
+		if err != nil {
+			return "", err
+		}
+		updated, found, err := replaceKey(data, formatFromPath(files[i]), newKey)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", files[i], err)
+		}
+		if !found {
+			continue
+		}
+		if err := writeFileAtomic(files[i], updated); err != nil {
+			return "", err
+		}
+		return files[i], nil
+	}
+	return "", fmt.Errorf("配置文件中未找到顶层 key 字段")
+}
+
+// replaceKey 替换配置内容中顶层 key 字段的值，保留其余内容不变
+func replaceKey(data []byte, format, newKey string) ([]byte, bool, error) {
+	content := string(data)
+	quoted := strconv.Quote(newKey)
+
+	var loc []int
+	switch format {
+	case FormatJSON:
+		loc = jsonKeyPair.FindStringSubmatchIndex(content)
+	case FormatTOML:
+		// 顶层字段只能出现在第一个表头之前
+		head := content
+		if m := tomlTable.FindStringIndex(content); m != nil {
+			head = content[:m[0]]
+		}
+		loc = tomlKeyLine.FindStringSubmatchIndex(head)
+	default:
+		loc = yamlKeyLine.FindStringSubmatchIndex(content)
+	}
+	if loc == nil {
+		return nil, false, nil
+	}
+	return replaceValue(content, loc, format, "key", quoted)
+}
+
+// replaceValue 将 content 中 loc[2]:loc[3] 处的字段值替换为 quoted，保留 YAML/TOML 的行尾注释
+func replaceValue(content string, loc []int, format, field, quoted string) ([]byte, bool, error) {
+	old := content[loc[2]:loc[3]]
+	if strings.Contains(old, "${") {
+		return nil, false, fmt.Errorf("%s 引用了环境变量，无法自动写回", field)
+	}
+	if format != FormatJSON {
+		quoted += trailingComment(old)
+	}
+	return []byte(content[:loc[2]] + quoted + content[loc[3]:]), true, nil
+}
+
+// UpdateClientPassword 将密钥轮换后的新密码写回客户端配置文件
+// oldPassword 为轮换前使用的密码：与配置文件中的 password 不一致说明密码来自命令行或环境变量，此时不修改文件并告警，
+// 返回值表示是否已写回配置文件。与 UpdateKey 相同，存在 include 时写入定义 password 的文件
+func UpdateClientPassword(configPath, oldPassword, newPassword string) (bool, error) {
+	data, format, _, err := readConfigTree(configPath)
+	if err != nil {
+		return false, err
+	}
+	var fileCfg Config
+	if err := decodeConfig(data, format, &fileCfg); err != nil {
+		return false, err
+	}
+	if fileCfg.Client == nil || fileCfg.Client.Password != oldPassword {
+		slog.Warn("当前密码来自命令行或环境变量，新密码未写入配置文件，重启前请同步更新", "config", configPath)
+		return false, nil
+	}
+
+	files := configFileSet(configPath)
+	for i := len(files) - 1; i >= 0; i-- {
+		data, err := os.ReadFile(files[i])
+		if err != nil {
+			return false, err
+		}
+		updated, found, err := replacePassword(data, formatFromPath(files[i]), newPassword)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", files[i], err)
+		}
+		if !found {
+			continue
+		}
+		if err := writeFileAtomic(files[i], updated); err != nil {
+			return false, err
+		}
+		slog.Info("🔑 新密码已写入配置文件", "file", files[i])
+		return true, nil
+	}
+	return false, fmt.Errorf("配置文件中未找到 client.password 字段")
+}
+
+// replacePassword 替换配置内容中 client 段 password 字段的值，保留其余内容不变
+func replacePassword(data []byte, format, newPassword string) ([]byte, bool, error) {
+	content := string(data)
+
+	// section 为 client 段在 content 中的起止位置
+	var section [2]int
+	var pattern *regexp.Regexp
+	switch format {
+	case FormatJSON:
+		section, pattern = [2]int{0, len(content)}, jsonPasswordPair
+	case FormatTOML:
+		m := tomlClientTable.FindStringIndex(content)
+		if m == nil {
+			return nil, false, nil
+		}
+		section, pattern = [2]int{m[1], len(content)}, tomlPasswordLine
+		if next := tomlTable.FindStringIndex(content[m[1]:]); next != nil {
+			section[1] = m[1] + next[0]
+		}
+	default:
+		m := yamlClientSection.FindStringIndex(content)
+		if m == nil {
+			return nil, false, nil
+		}
+		section, pattern = [2]int{m[1], len(content)}, yamlPasswordLine
+		if next := yamlTopLevelLine.FindStringIndex(content[m[1]:]); next != nil {
+			section[1] = m[1] + next[0]
+		}
+	}
+
+	loc := pattern.FindStringSubmatchIndex(content[section[0]:section[1]])
+	if loc == nil {
+		return nil, false, nil
+	}
+	for i := range loc {
+		loc[i] += section[0]
+	}
+	return replaceValue(content, loc, format, "password", strconv.Quote(newPassword))
+}
+
+// trailingComment 返回 YAML/TOML 值之后的行尾注释（含前导空白），跳过引号内的 #
+func trailingComment(value string) string {
+	start := 0
+	if len(value) > 0 && (value[0] == '"' || value[0] == '\'') {
+		quote := value[0]
+		for i := 1; i < len(value); i++ {
+			if quote == '"' && value[i] == '\\' {
+				i++
+				continue
+			}
+			if value[i] == quote {
+				start = i + 1
+				break
+			}
+		}
+	}
+	idx := strings.Index(value[start:], "#")
+	if idx < 0 {
+		return ""
+	}
+	comment := value[start+idx:]
+	// 保留注释前的空白
+	pos := start + idx
+	for pos > start && (value[pos-1] == ' ' || value[pos-1] == '\t') {
+		pos--
+	}
+	if pos == start+idx && start == 0 {
+		// 未加引号的值中紧贴的 # 属于值本身
+		return ""
+	}
+	return value[pos:start+idx] + comment
+}
+
+// writeFileAtomic 写入临时文件后重命名，保留原文件权限
+func writeFileAtomic(path string, content []byte) error {
+	perm := os.FileMode(0600)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, content, perm); err != nil {
+		return fmt.Errorf("写入临时文件失败: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath) // 清理临时文件
+		return fmt.Errorf("重命名文件失败: %w", err)
+	}
+	return nil
+}
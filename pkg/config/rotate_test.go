@@ -0,0 +1,230 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplaceKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		in      string
+		want    string
+		found   bool
+		wantErr bool
+	}{
+		{
+			name:   "yaml 保留行尾注释",
+			format: FormatYAML,
+			in:     "port: \"9090\"\nkey: \"old # x\"  # 认证密钥\nclient:\n  password: \"old\"\n",
+			want:   "port: \"9090\"\nkey: \"new-key\"  # 认证密钥\nclient:\n  password: \"old\"\n",
+			found:  true,
+		},
+		{
+			name:   "yaml 无引号",
+			format: FormatYAML,
+			in:     "key: old\n",
+			want:   "key: \"new-key\"\n",
+			found:  true,
+		},
+		{
+			name:   "yaml 无顶层 key",
+			format: FormatYAML,
+			in:     "port: \"9090\"\nclient:\n  key: \"x\"\n",
+			found:  false,
+		},
+		{
+			name:   "json",
+			format: FormatJSON,
+			in:     "{\n  \"port\": \"9090\",\n  \"key\": \"old\\\"key\"\n}\n",
+			want:   "{\n  \"port\": \"9090\",\n  \"key\": \"new-key\"\n}\n",
+			found:  true,
+		},
+		{
+			name:   "toml",
+			format: FormatTOML,
+			in:     "port = \"9090\"\nkey = \"old\"\n\n[client]\npassword = \"old\"\n",
+			want:   "port = \"9090\"\nkey = \"new-key\"\n\n[client]\npassword = \"old\"\n",
+			found:  true,
+		},
+		{
+			name:   "toml 表内的 key 不是顶层字段",
+			format: FormatTOML,
+			in:     "port = \"9090\"\n\n[client]\nkey = \"old\"\n",
+			found:  false,
+		},
+		{
+			name:    "引用环境变量",
+			format:  FormatYAML,
+			in:      "key: \"${ACME_KEY}\"\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found, err := replaceKey([]byte(tt.in), tt.format, "new-key")
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.found, found)
+			if tt.found {
+				assert.Equal(t, tt.want, string(got))
+			}
+		})
+	}
+}
+
+// withConfigState 临时替换全局配置状态
+func withConfigState(t *testing.T, global, file *Config) {
+	t.Helper()
+	mu.Lock()
+	prevGlobal, prevFile := GlobalConfig, fileConfig
+	GlobalConfig, fileConfig = global, file
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		GlobalConfig, fileConfig = prevGlobal, prevFile
+		mu.Unlock()
+	})
+}
+
+func TestUpdateKeyPersistsToIncludedFile(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "config.yaml")
+	secret := filepath.Join(dir, "secret.toml")
+	require.NoError(t, os.WriteFile(main, []byte("port: \"9090\"\ninclude: [\"secret.toml\"]\n"), 0644))
+	require.NoError(t, os.WriteFile(secret, []byte("key = \"old-key\"\n"), 0600))
+
+	cfg := defaultConfig()
+	require.NoError(t, loadFromFile(cfg, main))
+	cfg.ConfigFile = main
+	snapshot := *cfg
+	withConfigState(t, cfg, &snapshot)
+
+	persisted, err := UpdateKey("new-key")
+	require.NoError(t, err)
+	assert.True(t, persisted)
+	assert.Equal(t, "new-key", GetConfig().Key)
+
+	data, err := os.ReadFile(secret)
+	require.NoError(t, err)
+	assert.Equal(t, "key = \"new-key\"\n", string(data))
+	info, err := os.Stat(secret)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "应保留原文件权限")
+
+	reloaded := defaultConfig()
+	require.NoError(t, loadFromFile(reloaded, main))
+	assert.Equal(t, "new-key", reloaded.Key)
+}
+
+func TestUpdateKeySkipsOverriddenKey(t *testing.T) {
+	path := createTempConfig(t, "key: \"file-key\"\n")
+
+	cfg := defaultConfig()
+	require.NoError(t, loadFromFile(cfg, path))
+	cfg.ConfigFile = path
+	snapshot := *cfg
+	cfg.Key = "env-key" // 环境变量覆盖
+	withConfigState(t, cfg, &snapshot)
+
+	persisted, err := UpdateKey("new-key")
+	require.NoError(t, err)
+	assert.False(t, persisted)
+	assert.Equal(t, "new-key", GetConfig().Key)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "key: \"file-key\"\n", string(data), "密钥来自环境变量时不应修改配置文件")
+}
+
+func TestReplacePassword(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		in     string
+		want   string
+		found  bool
+	}{
+		{
+			name:   "yaml 只替换 client 段",
+			format: FormatYAML,
+			in:     "key: \"server\"\nclient:\n  sites:\n    - domain: a.com\n      pkcs12_password: \"p12\"\n  password: \"old\"  # 认证密码\nother:\n  password: \"x\"\n",
+			want:   "key: \"server\"\nclient:\n  sites:\n    - domain: a.com\n      pkcs12_password: \"p12\"\n  password: \"new-password\"  # 认证密码\nother:\n  password: \"x\"\n",
+			found:  true,
+		},
+		{
+			name:   "yaml 无 client 段",
+			format: FormatYAML,
+			in:     "key: \"server\"\n",
+			found:  false,
+		},
+		{
+			name:   "toml",
+			format: FormatTOML,
+			in:     "key = \"server\"\n\n[client]\nserver = \"wss://a\"\npassword = \"old\"\n\n[[client.sites]]\npkcs12_password = \"p12\"\n",
+			want:   "key = \"server\"\n\n[client]\nserver = \"wss://a\"\npassword = \"new-password\"\n\n[[client.sites]]\npkcs12_password = \"p12\"\n",
+			found:  true,
+		},
+		{
+			name:   "json",
+			format: FormatJSON,
+			in:     "{\"client\": {\"pkcs12_password\": \"p12\", \"password\": \"old\"}}\n",
+			want:   "{\"client\": {\"pkcs12_password\": \"p12\", \"password\": \"new-password\"}}\n",
+			found:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found, err := replacePassword([]byte(tt.in), tt.format, "new-password")
+			require.NoError(t, err)
+			assert.Equal(t, tt.found, found)
+			if tt.found {
+				assert.Equal(t, tt.want, string(got))
+			}
+		})
+	}
+}
+
+func TestUpdateClientPasswordPersistsToIncludedFile(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "config.yaml")
+	secret := filepath.Join(dir, "secret.yaml")
+	require.NoError(t, os.WriteFile(main, []byte("include: [\"secret.yaml\"]\nclient:\n  server: \"wss://acme.example.com\"\n"), 0644))
+	require.NoError(t, os.WriteFile(secret, []byte("client:\n  password: \"old-password\"\n"), 0600))
+
+	persisted, err := UpdateClientPassword(main, "old-password", "new-password")
+	require.NoError(t, err)
+	assert.True(t, persisted)
+
+	info, err := os.Stat(secret)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "应保留原文件权限")
+	reloaded, err := LoadClientConfigUnvalidated(main)
+	require.NoError(t, err)
+	assert.Equal(t, "new-password", reloaded.Password)
+	assert.Equal(t, "wss://acme.example.com", reloaded.Server)
+}
+
+func TestUpdateClientPasswordSkipsOverriddenPassword(t *testing.T) {
+	content := "client:\n  password: \"file-password\"\n"
+	path := createTempConfig(t, content)
+
+	// 运行中的密码来自命令行或环境变量
+	persisted, err := UpdateClientPassword(path, "env-password", "new-password")
+	require.NoError(t, err)
+	assert.False(t, persisted)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(data), "密码来自环境变量时不应修改配置文件")
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/Catker/acmeDeliver/pkg/domainmatch"
+)
+
+// FindSite 在站点列表中查找域名对应的站点配置
+// 匹配规则见 domainmatch.Match：*.example.com 仅匹配一级子域名，**.example.com 匹配任意层级
+// 多个站点同时匹配时取最具体者（精确 > 后缀更长的通配符 > *. > **.），与配置顺序无关
+func FindSite(sites []SiteDeployConfig, domain string) *SiteDeployConfig {
+	patterns := make([]string, len(sites))
+	for i, site := range sites {
+		patterns[i] = site.Domain
+	}
+
+	best, ok := domainmatch.BestMatch(patterns, domain)
+	if !ok {
+		return nil
+	}
+	for i := range sites {
+		if sites[i].Domain == best {
+			return &sites[i]
+		}
+	}
+	return nil
+}
+
+// ValidateSites 校验站点配置，同一 domain 重复配置时返回错误
+// 精确域名与通配符（或通配符之间）存在重叠时，记录实际生效的匹配顺序
+func ValidateSites(sites []SiteDeployConfig) error {
+	seen := make(map[string]int, len(sites))
+	for i, site := range sites {
+		if j, ok := seen[site.Domain]; ok {
+			return fmt.Errorf("站点配置重复: sites[%d] 与 sites[%d] 的 domain 均为 %q", j, i, site.Domain)
+		}
+		seen[site.Domain] = i
+	}
+
+	for _, site := range sites {
+		var shadowed []string
+		for _, other := range sites {
+			if other.Domain != site.Domain && domainmatch.IsWildcard(other.Domain) && domainmatch.Match(other.Domain, site.Domain) {
+				shadowed = append(shadowed, other.Domain)
+			}
+		}
+		if len(shadowed) == 0 {
+			continue
+		}
+		sort.Slice(shadowed, func(i, j int) bool {
+			return domainmatch.Specificity(shadowed[i]) > domainmatch.Specificity(shadowed[j])
+		})
+		slog.Info("站点配置存在重叠，按匹配优先级生效",
+			"domain", site.Domain,
+			"order", strings.Join(append([]string{site.Domain}, shadowed...), " > "))
+	}
+	return nil
+}
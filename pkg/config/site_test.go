@@ -0,0 +1,113 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindSite(t *testing.T) {
+	tests := []struct {
+		name   string
+		sites  []string
+		domain string
+		want   string // 期望匹配的站点 domain，空表示无匹配
+	}{
+		{"精确匹配", []string{"example.com"}, "example.com", "example.com"},
+		{"精确优先于前置通配符", []string{"*.example.com", "api.example.com"}, "api.example.com", "api.example.com"},
+		{"精确优先于后置通配符", []string{"api.example.com", "*.example.com"}, "api.example.com", "api.example.com"},
+		{"通配符匹配子域名", []string{"*.example.com"}, "www.example.com", "*.example.com"},
+		{"单级通配符不匹配多级子域名", []string{"*.example.com"}, "a.b.example.com", ""},
+		{"多级通配符匹配任意层级", []string{"**.example.com"}, "a.b.example.com", "**.example.com"},
+		{"单级通配符优先于多级", []string{"**.example.com", "*.example.com"}, "www.example.com", "*.example.com"},
+		{"更长后缀的多级通配符优先", []string{"*.example.com", "**.api.example.com"}, "v1.api.example.com", "**.api.example.com"},
+		{"最长后缀优先", []string{"*.example.com", "*.api.example.com"}, "v1.api.example.com", "*.api.example.com"},
+		{"最长后缀优先（顺序无关）", []string{"*.api.example.com", "*.example.com"}, "v1.api.example.com", "*.api.example.com"},
+		{"通配符不匹配根域名", []string{"*.example.com"}, "example.com", ""},
+		{"不匹配相似后缀", []string{"*.example.com"}, "badexample.com", ""},
+		{"无匹配", []string{"other.com"}, "example.com", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sites []SiteDeployConfig
+			for _, d := range tt.sites {
+				sites = append(sites, SiteDeployConfig{Domain: d})
+			}
+
+			site := FindSite(sites, tt.domain)
+			if tt.want == "" {
+				assert.Nil(t, site)
+				return
+			}
+			if assert.NotNil(t, site) {
+				assert.Equal(t, tt.want, site.Domain)
+			}
+		})
+	}
+}
+
+func TestValidateSites(t *testing.T) {
+	tests := []struct {
+		name    string
+		sites   []string
+		wantErr bool
+	}{
+		{"无站点", nil, false},
+		{"互不重叠", []string{"example.com", "*.example.org"}, false},
+		{"精确与通配符重叠", []string{"*.example.com", "api.example.com"}, false},
+		{"通配符嵌套", []string{"**.example.com", "*.api.example.com"}, false},
+		{"重复精确域名", []string{"api.example.com", "*.example.com", "api.example.com"}, true},
+		{"重复通配符", []string{"*.example.com", "*.example.com"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sites []SiteDeployConfig
+			for _, d := range tt.sites {
+				sites = append(sites, SiteDeployConfig{Domain: d})
+			}
+
+			err := ValidateSites(sites)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+const duplicateSitesConfig = `
+client:
+  password: "test"
+  sites:
+    - domain: "api.example.com"
+      cert_path: "/etc/a/cert.pem"
+    - domain: "api.example.com"
+      cert_path: "/etc/b/cert.pem"
+`
+
+func TestLoadClientConfigRejectsDuplicateSites(t *testing.T) {
+	_, err := LoadClientConfig(createTempConfig(t, duplicateSitesConfig))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "api.example.com")
+	}
+}
+
+func TestClientConfigWatcherRejectsDuplicateSites(t *testing.T) {
+	configFile := createTempConfig(t, "client:\n  password: \"test\"\n  sites:\n    - domain: \"api.example.com\"\n")
+	initialCfg, err := LoadClientConfig(configFile)
+	assert.NoError(t, err)
+
+	watcher := NewClientConfigWatcher(configFile, initialCfg)
+	called := false
+	watcher.RegisterCallback(func(old, new *ClientConfig) { called = true })
+
+	assert.NoError(t, os.WriteFile(configFile, []byte(duplicateSitesConfig), 0644))
+	watcher.reloadConfig()
+
+	assert.False(t, called, "重复站点配置不应被热重载")
+	assert.Equal(t, initialCfg, watcher.current)
+}
@@ -0,0 +1,156 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+)
+
+// laxConfig 宽松模式：忽略配置文件中的未知字段（--lax-config）
+var laxConfig atomic.Bool
+
+// SetLaxConfig 设置是否以宽松模式解析配置文件
+// 默认严格模式，拼写错误的字段会直接报错，避免配置静默失效
+func SetLaxConfig(lax bool) {
+	laxConfig.Store(lax)
+}
+
+// unknownFieldPattern 匹配 yaml.v3 KnownFields 模式下的未知字段错误
+var unknownFieldPattern = regexp.MustCompile(`^line (\d+): field (\S+) not found in type (\S+)$`)
+
+// decodeYAML 解析 YAML 配置
+// 严格模式下拒绝未知字段，并给出最接近的合法字段名
+func decodeYAML(data []byte, out interface{}) error {
+	if laxConfig.Load() {
+		return yaml.Unmarshal(data, out)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(out); err != nil {
+		// 空文件视为无配置
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return explainUnknownFields(err, out)
+	}
+	return nil
+}
+
+// explainUnknownFields 将未知字段错误改写为带建议的中文提示
+// 其他类型的错误原样返回
+func explainUnknownFields(err error, out interface{}) error {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return err
+	}
+
+	known := make(map[string][]string)
+	collectYAMLKeys(reflect.TypeOf(out), known)
+
+	var unknown, others []string
+	for _, msg := range typeErr.Errors {
+		m := unknownFieldPattern.FindStringSubmatch(msg)
+		if m == nil {
+			others = append(others, msg)
+			continue
+		}
+		line, field, typeName := m[1], m[2], m[3]
+		if suggestion := suggestKey(field, known[typeName]); suggestion != "" {
+			unknown = append(unknown, fmt.Sprintf("第 %s 行: 未知字段 %s，是否想写 %s?", line, field, suggestion))
+		} else {
+			unknown = append(unknown, fmt.Sprintf("第 %s 行: 未知字段 %s", line, field))
+		}
+	}
+
+	if len(unknown) == 0 {
+		return err
+	}
+	msg := "配置文件包含未知字段（可使用 --lax-config 忽略）:\n  " + strings.Join(unknown, "\n  ")
+	if len(others) > 0 {
+		msg += "\n  " + strings.Join(others, "\n  ")
+	}
+	return errors.New(msg)
+}
+
+// collectYAMLKeys 递归收集结构体类型的 YAML 字段名，按类型名（如 config.SiteDeployConfig）索引
+func collectYAMLKeys(t reflect.Type, known map[string][]string) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	if _, ok := known[t.String()]; ok {
+		return
+	}
+
+	keys := []string{}
+	known[t.String()] = keys
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		keys = append(keys, name)
+		collectYAMLKeys(f.Type, known)
+	}
+	known[t.String()] = keys
+}
+
+// suggestKey 返回编辑距离最近的合法字段名，差异过大时返回空字符串
+func suggestKey(field string, candidates []string) string {
+	best, bestDist := "", -1
+	for _, c := range candidates {
+		d := levenshtein(field, c)
+		if bestDist < 0 || d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+
+	// 允许的最大距离：至少 2，长字段按长度的 1/3 放宽
+	maxDist := len(field) / 3
+	if maxDist < 2 {
+		maxDist = 2
+	}
+	if bestDist < 0 || bestDist > maxDist {
+		return ""
+	}
+	return best
+}
+
+// levenshtein 计算两个字符串的编辑距离
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
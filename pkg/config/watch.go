@@ -0,0 +1,163 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// configWatchDebounce 配置文件事件防抖时间
+// 编辑器保存（vim 的备份+重命名）、Ansible 的临时文件+rename 都会在短时间内产生多个事件，合并为一次重载
+var configWatchDebounce = 300 * time.Millisecond
+
+// configWatcher 监听配置文件（含 include 文件）所在目录
+// 直接监听文件在原子替换（rename）后会指向已删除的 inode 导致热重载失效，
+// 因此改为监听父目录并按文件名过滤事件
+type configWatcher struct {
+	path    string // 主配置文件
+	watcher *fsnotify.Watcher
+	files   map[string]bool // 目标文件（绝对路径）
+	dirs    map[string]bool // 已监听的目录
+	hash    string          // 最近一次加载时全部配置文件内容的摘要
+}
+
+// newConfigWatcher 创建配置文件监听器并监听当前配置文件集合所在目录
+func newConfigWatcher(path string) (*configWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &configWatcher{
+		path:    path,
+		watcher: watcher,
+		files:   make(map[string]bool),
+		dirs:    make(map[string]bool),
+	}
+	w.sync()
+	w.hash = w.contentHash()
+	return w, nil
+}
+
+// sync 使监听目录与当前 include 文件集合保持一致
+func (w *configWatcher) sync() {
+	files := configFileSet(w.path)
+
+	w.files = make(map[string]bool, len(files))
+	dirs := make(map[string]bool)
+	for _, f := range files {
+		w.files[filepath.Clean(f)] = true
+		dirs[filepath.Dir(f)] = true
+	}
+
+	for dir := range dirs {
+		if w.dirs[dir] {
+			continue
+		}
+		if err := w.watcher.Add(dir); err != nil {
+			slog.Warn("监听配置目录失败", "dir", dir, "error", err)
+			continue
+		}
+		w.dirs[dir] = true
+	}
+	for dir := range w.dirs {
+		if !dirs[dir] {
+			w.watcher.Remove(dir)
+			delete(w.dirs, dir)
+		}
+	}
+}
+
+// contentHash 计算全部配置文件内容的摘要，读取失败的文件按缺失处理
+func (w *configWatcher) contentHash() string {
+	files := make([]string, 0, len(w.files))
+	for f := range w.files {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+
+	h := sha256.New()
+	for _, f := range files {
+		h.Write([]byte(f))
+		h.Write([]byte{0})
+		if data, err := os.ReadFile(f); err == nil {
+			h.Write(data)
+		} else {
+			h.Write([]byte("<missing>"))
+		}
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// relevant 判断事件是否涉及被监听的配置文件
+func (w *configWatcher) relevant(event fsnotify.Event) bool {
+	if !w.files[filepath.Clean(event.Name)] {
+		return false
+	}
+	return event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove|fsnotify.Chmod) != 0
+}
+
+// run 事件循环：防抖后内容确有变化时调用 reload，直到 stop 关闭或监听器出错退出
+func (w *configWatcher) run(stop <-chan struct{}, reload func()) {
+	defer w.watcher.Close()
+
+	var timer *time.Timer
+	var timerC <-chan time.Time
+
+	for {
+		select {
+		case <-stop:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if !w.relevant(event) {
+				continue
+			}
+			slog.Debug("配置文件事件", "file", event.Name, "op", event.Op.String())
+			if timer == nil {
+				timer = time.NewTimer(configWatchDebounce)
+			} else {
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(configWatchDebounce)
+			}
+			timerC = timer.C
+
+		case <-timerC:
+			timerC = nil
+			hash := w.contentHash()
+			if hash == w.hash {
+				slog.Debug("配置文件内容未变化，跳过重载", "path", w.path)
+				continue
+			}
+			slog.Info("📝 检测到配置文件变化，正在重新加载...", "path", w.path)
+			reload()
+			// include 列表可能变化，重新同步监听目录并记录新内容摘要
+			w.sync()
+			w.hash = w.contentHash()
+
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			slog.Warn("配置文件监听错误", "error", err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startTestConfigWatcher 启动配置监听，返回重载计数
+func startTestConfigWatcher(t *testing.T, path string) *atomic.Int32 {
+	t.Helper()
+	w, err := newConfigWatcher(path)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	var reloads atomic.Int32
+	stop := make(chan struct{})
+	go w.run(stop, func() { reloads.Add(1) })
+	t.Cleanup(func() { close(stop) })
+	return &reloads
+}
+
+// waitReloads 等待事件处理完成后返回重载次数
+func waitReloads(reloads *atomic.Int32) int {
+	time.Sleep(configWatchDebounce * 4)
+	return int(reloads.Load())
+}
+
+// replaceAtomically 模拟 Ansible 等工具：写入临时文件后 rename 覆盖目标
+func replaceAtomically(t *testing.T, path, content string) {
+	t.Helper()
+	tmp := filepath.Join(filepath.Dir(path), ".tmp-"+filepath.Base(path))
+	assert.NoError(t, os.WriteFile(tmp, []byte(content), 0644))
+	assert.NoError(t, os.Rename(tmp, path))
+}
+
+func TestConfigWatcherAtomicRename(t *testing.T) {
+	path := createTempConfig(t, "port: \"9090\"\n")
+	reloads := startTestConfigWatcher(t, path)
+
+	replaceAtomically(t, path, "port: \"9191\"\n")
+	assert.Equal(t, 1, waitReloads(reloads), "rename 替换应触发一次重载")
+
+	// rename 之后监听仍然有效
+	replaceAtomically(t, path, "port: \"9292\"\n")
+	assert.Equal(t, 2, waitReloads(reloads), "再次 rename 替换应继续触发重载")
+}
+
+func TestConfigWatcherVimStyleSave(t *testing.T) {
+	path := createTempConfig(t, "port: \"9090\"\n")
+	reloads := startTestConfigWatcher(t, path)
+
+	// vim（backupcopy=no）：原文件重命名为备份，写入新文件，删除备份
+	backup := path + "~"
+	assert.NoError(t, os.Rename(path, backup))
+	assert.NoError(t, os.WriteFile(path, []byte("port: \"9191\"\n"), 0644))
+	assert.NoError(t, os.Chmod(path, 0600))
+	assert.NoError(t, os.Remove(backup))
+
+	assert.Equal(t, 1, waitReloads(reloads), "一次保存产生的多个事件应合并为一次重载")
+}
+
+func TestConfigWatcherSkipsUnchangedContent(t *testing.T) {
+	content := "port: \"9090\"\n"
+	path := createTempConfig(t, content)
+	reloads := startTestConfigWatcher(t, path)
+
+	// 内容不变的写入、权限变化与 rename 均不触发重载
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	assert.NoError(t, os.Chmod(path, 0600))
+	replaceAtomically(t, path, content)
+	assert.Equal(t, 0, waitReloads(reloads))
+
+	// 同目录下的其他文件不触发重载
+	assert.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(path), "other.yaml"), []byte("x: 1\n"), 0644))
+	assert.Equal(t, 0, waitReloads(reloads))
+}
+
+func TestClientConfigWatcherAtomicRename(t *testing.T) {
+	configFile := createTempConfig(t, "client:\n  password: \"test\"\n  subscribe: [\"old.example.com\"]\n")
+	initialCfg, err := LoadClientConfig(configFile)
+	assert.NoError(t, err)
+
+	watcher := NewClientConfigWatcher(configFile, initialCfg)
+	reloaded := make(chan *ClientConfig, 8)
+	watcher.RegisterCallback(func(old, new *ClientConfig) {
+		reloaded <- new
+	})
+	assert.NoError(t, watcher.Start())
+	defer watcher.Stop()
+
+	replaceAtomically(t, configFile, "client:\n  password: \"test\"\n  subscribe: [\"new.example.com\"]\n")
+
+	select {
+	case cfg := <-reloaded:
+		assert.Equal(t, []string{"new.example.com"}, cfg.Subscribe)
+	case <-time.After(5 * time.Second):
+		t.Fatal("rename 替换配置文件后未触发重载")
+	}
+
+	time.Sleep(configWatchDebounce * 4)
+	assert.Len(t, reloaded, 0, "rename 替换只应触发一次重载")
+}
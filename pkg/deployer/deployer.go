@@ -4,12 +4,12 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
 	"log/slog"
 
+	"github.com/Catker/acmeDeliver/pkg/cert"
 	"github.com/Catker/acmeDeliver/pkg/client"
 	"github.com/Catker/acmeDeliver/pkg/command"
 )
@@ -20,8 +20,32 @@ type DeploymentConfig struct {
 	CertPath      string `yaml:"cert_path"`      // 证书路径（可选，支持 {domain} 占位符）
 	KeyPath       string `yaml:"key_path"`       // 私钥路径（可选，支持 {domain} 占位符）
 	FullchainPath string `yaml:"fullchain_path"` // 证书链路径（可选，支持 {domain} 占位符）
+	BundlePath    string `yaml:"bundle_path"`    // 单文件证书包路径：叶子证书 + 中间证书（可选，支持 {domain} 占位符）
+	CombinedPath  string `yaml:"combined_path"`  // 合并文件路径：按 CombinedOrder 拼接证书与私钥（可选，支持 {domain} 占位符）
+	CombinedOrder string `yaml:"combined_order"` // 合并文件的组成顺序，为空时使用 cert.DefaultCombinedOrder（"fullchain,key"）
 	ReloadCmd     string `yaml:"reloadcmd"`      // 重载命令（可选）
 	SkipReload    bool   // 跳过 reload（批量部署时使用，最后统一执行）
+	Force         bool   // 强制部署：部署文件内容均未变化时仍执行 reload
+
+	PKCS12Path     string // PKCS#12 文件路径：由私钥与证书链生成（可选，支持 {domain} 占位符）
+	PKCS12Password string // PKCS#12 文件的密码（已从 pkcs12_password_file 读取），可为空
+	PKCS12Legacy   bool   // 使用 3DES/RC2-40 + SHA-1 的旧版算法
+
+	VerifyAfterDeploy bool // 写入后重新读取部署文件并与来源内容比对，不一致时不执行 reload
+
+	FileOwner string      // 部署文件及新建目录的属主（可选，仅 Unix，非 root 运行时跳过）
+	FileGroup string      // 部署文件及新建目录的属组（可选，仅 Unix，非 root 运行时跳过）
+	CertMode  os.FileMode // 证书、证书链与证书包的权限，0 表示 0644
+	KeyMode   os.FileMode // 私钥、合并文件与 PKCS#12 文件的权限，0 表示私钥 0644、其余 0600
+	DirMode   os.FileMode // 新建上级目录的权限，0 表示 0755
+
+	SyncOnWrite bool // rename 前 fsync 文件、rename 后 fsync 所在目录，防止崩溃后留下空文件或丢失文件
+
+	// BackupRetention 覆盖前备份已有部署文件，每个文件保留的备份数；0 表示不备份
+	// 执行 reload 时（非批量模式）reload 失败会恢复备份并重新执行 reload
+	BackupRetention int
+
+	ReloadTimeout time.Duration // 重载命令执行超时，0 表示使用 client.DefaultReloadTimeout
 }
 
 // Deployer 定义了部署证书的标准接口
@@ -33,7 +57,7 @@ type Deployer interface {
 // 配置驱动：如果配置了任何路径就部署，否则跳过
 func NewDeployer(cfg DeploymentConfig) (Deployer, error) {
 	// 如果没有配置任何路径，返回 NoOpDeployer
-	if cfg.CertPath == "" && cfg.KeyPath == "" && cfg.FullchainPath == "" {
+	if cfg.CertPath == "" && cfg.KeyPath == "" && cfg.FullchainPath == "" && cfg.BundlePath == "" && cfg.CombinedPath == "" && cfg.PKCS12Path == "" {
 		slog.Debug("未配置任何部署路径，跳过部署")
 		return &NoOpDeployer{}, nil
 	}
@@ -51,7 +75,37 @@ func (d *NoOpDeployer) Deploy(certs *client.CertificateFiles, dryRun bool) error
 // ConfigDrivenDeployer 配置驱动的部署器
 // 根据配置的路径决定写入哪些文件
 type ConfigDrivenDeployer struct {
-	cfg DeploymentConfig
+	cfg       DeploymentConfig
+	backup    *client.DeployBackup
+	unchanged bool
+}
+
+// Backup 返回最近一次 Deploy 覆盖前的备份，未备份时返回 nil
+// 批量模式下调用方统一执行 reload，失败时据此回滚
+func (d *ConfigDrivenDeployer) Backup() *client.DeployBackup {
+	return d.backup
+}
+
+// BackupOf 返回部署器最近一次部署前的备份，部署器不支持备份时返回 nil
+func BackupOf(d Deployer) *client.DeployBackup {
+	if b, ok := d.(interface{ Backup() *client.DeployBackup }); ok {
+		return b.Backup()
+	}
+	return nil
+}
+
+// Unchanged 返回最近一次 Deploy 的全部部署文件内容是否均未变化（此时已跳过 reload），Force 时总是 false
+// 批量模式下调用方据此跳过统一执行的 reload
+func (d *ConfigDrivenDeployer) Unchanged() bool {
+	return d.unchanged
+}
+
+// UnchangedOf 返回部署器最近一次部署的文件内容是否均未变化，部署器不支持比对时返回 false
+func UnchangedOf(d Deployer) bool {
+	if u, ok := d.(interface{ Unchanged() bool }); ok {
+		return u.Unchanged()
+	}
+	return false
 }
 
 // replacePath 替换路径中的 {domain} 占位符
@@ -67,6 +121,10 @@ func (d *ConfigDrivenDeployer) Deploy(certs *client.CertificateFiles, dryRun boo
 	certPath := d.replacePath(d.cfg.CertPath)
 	keyPath := d.replacePath(d.cfg.KeyPath)
 	fullchainPath := d.replacePath(d.cfg.FullchainPath)
+	bundlePath := d.replacePath(d.cfg.BundlePath)
+	combinedPath := d.replacePath(d.cfg.CombinedPath)
+	pkcs12Path := d.replacePath(d.cfg.PKCS12Path)
+	d.unchanged = false
 
 	if dryRun {
 		slog.Info("[DryRun] 配置驱动部署模式 - 将要执行以下操作:", "domain", d.cfg.Domain)
@@ -79,6 +137,15 @@ func (d *ConfigDrivenDeployer) Deploy(certs *client.CertificateFiles, dryRun boo
 		if fullchainPath != "" {
 			slog.Info("[DryRun] 写入证书链文件", "path", fullchainPath, "size", len(certs.Fullchain))
 		}
+		if bundlePath != "" {
+			slog.Info("[DryRun] 写入证书包文件", "path", bundlePath)
+		}
+		if combinedPath != "" {
+			slog.Info("[DryRun] 写入合并文件", "path", combinedPath, "order", d.combinedOrder(), "mode", fmt.Sprintf("%#o", d.combinedMode()))
+		}
+		if pkcs12Path != "" {
+			slog.Info("[DryRun] 写入 PKCS#12 文件", "path", pkcs12Path, "legacy", d.cfg.PKCS12Legacy, "mode", fmt.Sprintf("%#o", d.combinedMode()))
+		}
 		if d.cfg.ReloadCmd != "" {
 			slog.Info("[DryRun] 执行重载命令", "command", d.cfg.ReloadCmd)
 		}
@@ -87,43 +154,97 @@ func (d *ConfigDrivenDeployer) Deploy(certs *client.CertificateFiles, dryRun boo
 
 	slog.Info("开始部署证书", "domain", d.cfg.Domain)
 
-	// 写入证书文件（如果配置了）
+	// 按配置生成全部部署文件，写入前逐个与已有文件比对：有变化时先备份已有文件，全部未变化时跳过重载
+	set := client.DeploySet{Domain: d.cfg.Domain}
+	certAttrs, keyAttrs, combinedAttrs := d.fileAttrs(d.cfg.CertMode), d.fileAttrs(d.cfg.KeyMode), d.fileAttrs(d.combinedMode())
+
+	// 证书文件（如果配置了）
 	if certPath != "" {
 		if len(certs.Cert) == 0 {
 			return fmt.Errorf("证书内容为空，无法写入 cert_path")
 		}
-		if err := d.writeFile(certPath, certs.Cert); err != nil {
-			return fmt.Errorf("写入证书文件失败: %w", err)
-		}
-		slog.Info("证书已写入", "path", certPath)
+		set.Add(certPath, certs.Cert, certAttrs)
 	}
 
-	// 写入私钥文件（如果配置了）
+	// 私钥文件（如果配置了）
 	if keyPath != "" {
 		if len(certs.Key) == 0 {
 			return fmt.Errorf("私钥内容为空，无法写入 key_path")
 		}
-		if err := d.writeFile(keyPath, certs.Key); err != nil {
-			return fmt.Errorf("写入私钥文件失败: %w", err)
-		}
-		slog.Info("私钥已写入", "path", keyPath)
+		set.Add(keyPath, certs.Key, keyAttrs)
 	}
 
-	// 写入证书链文件（如果配置了）
+	// 证书链文件（如果配置了）
 	if fullchainPath != "" {
 		if len(certs.Fullchain) == 0 {
 			return fmt.Errorf("证书链内容为空，无法写入 fullchain_path")
 		}
-		if err := d.writeFile(fullchainPath, certs.Fullchain); err != nil {
-			return fmt.Errorf("写入证书链文件失败: %w", err)
+		set.Add(fullchainPath, certs.Fullchain, certAttrs)
+	}
+
+	// 证书包文件（如果配置了）
+	if bundlePath != "" {
+		bundle, err := cert.BundleCerts(certs.Cert, certs.Fullchain)
+		if err != nil {
+			return fmt.Errorf("生成证书包失败: %w", err)
+		}
+		set.Add(bundlePath, bundle, certAttrs)
+	}
+
+	// 合并文件（如果配置了），在内存中拼接，任一组成部分为空时不写入
+	if combinedPath != "" {
+		combined, err := cert.CombinePEM(d.cfg.CombinedOrder, certs.Cert, certs.Key, certs.Fullchain)
+		if err != nil {
+			return fmt.Errorf("生成合并文件失败: %w", err)
 		}
-		slog.Info("证书链已写入", "path", fullchainPath)
+		set.Add(combinedPath, combined, combinedAttrs)
+	}
+
+	// PKCS#12 文件（如果配置了），编码前校验证书与私钥是否匹配
+	if pkcs12Path != "" {
+		chain := certs.Fullchain
+		if len(chain) == 0 {
+			chain = certs.Cert
+		}
+		p12, err := cert.PEMToPKCS12(chain, certs.Key, d.cfg.PKCS12Password, d.cfg.PKCS12Legacy)
+		if err != nil {
+			return fmt.Errorf("生成 PKCS#12 文件失败: %w", err)
+		}
+		set.AddPKCS12(pkcs12Path, p12, chain, certs.Key, d.cfg.PKCS12Password, d.cfg.PKCS12Legacy, combinedAttrs)
+	}
+
+	backup, unchanged, err := set.Write(d.cfg.BackupRetention, time.Now())
+	d.backup = backup
+	if err != nil {
+		return fmt.Errorf("写入部署文件失败: %w", err)
+	}
+	slog.Info("部署文件已写入", "domain", d.cfg.Domain, "cert", certPath, "key", keyPath, "fullchain", fullchainPath,
+		"bundle", bundlePath, "combined", combinedPath, "pkcs12", pkcs12Path)
+
+	// 校验部署文件（如果配置了），失败时不执行重载
+	if d.cfg.VerifyAfterDeploy {
+		if err := VerifyDeployment(d.cfg, certs); err != nil {
+			return err
+		}
+		slog.Info("部署文件校验通过", "domain", d.cfg.Domain)
+	}
+
+	// 部署文件内容均未变化时无需重载（强制部署除外）
+	d.unchanged = unchanged && !d.cfg.Force
+	if d.unchanged {
+		slog.Info("部署文件内容均未变化，跳过重载", "domain", d.cfg.Domain)
+		return nil
 	}
 
 	// 执行重载命令（如果配置了且不跳过）
 	if d.cfg.ReloadCmd != "" && !d.cfg.SkipReload {
 		if err := d.runReloadCmd(); err != nil {
-			return fmt.Errorf("执行重载命令失败: %w", err)
+			if d.backup.Empty() {
+				return fmt.Errorf("执行重载命令失败: %w", err)
+			}
+			slog.Warn("重载命令失败，回滚到部署前的证书", "domain", d.cfg.Domain, "error", err)
+			rollbackErr := client.RollbackDeployments([]*client.DeployBackup{d.backup}, d.runReloadCmd)
+			return client.RollbackError(err, rollbackErr)
 		}
 	}
 
@@ -131,50 +252,94 @@ func (d *ConfigDrivenDeployer) Deploy(certs *client.CertificateFiles, dryRun boo
 	return nil
 }
 
-// writeFile 安全地写入文件，设置正确的权限
-func (d *ConfigDrivenDeployer) writeFile(path string, content []byte) error {
-	if path == "" {
-		return fmt.Errorf("文件路径不能为空")
+// ErrDeployVerificationFailed 部署文件与来源内容不一致（如文件系统错误、NFS 写缓存未提交）
+var ErrDeployVerificationFailed = client.ErrDeployVerificationFailed
+
+// verifyDelay 校验前的等待时间，测试中可缩短
+var verifyDelay = client.DeployVerifyDelay
+
+// VerifyDeployment 等待 500ms（留给 NFS 提交写入）后重新读取配置中的部署文件，
+// 按 SHA-256 与 certs 中的来源内容比对，不一致或读取失败时返回包装 ErrDeployVerificationFailed 的错误
+// PKCS#12 文件每次编码使用随机盐值，不参与比对
+func VerifyDeployment(cfg DeploymentConfig, certs *client.CertificateFiles) error {
+	d := &ConfigDrivenDeployer{cfg: cfg}
+	expected := make(map[string][]byte)
+	if path := d.replacePath(cfg.CertPath); path != "" {
+		expected[path] = certs.Cert
 	}
-	if len(content) == 0 {
-		return fmt.Errorf("文件内容为空")
+	if path := d.replacePath(cfg.KeyPath); path != "" {
+		expected[path] = certs.Key
 	}
-
-	// 确保目录存在
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("创建目录失败: %w", err)
+	if path := d.replacePath(cfg.FullchainPath); path != "" {
+		expected[path] = certs.Fullchain
+	}
+	if path := d.replacePath(cfg.BundlePath); path != "" {
+		bundle, err := cert.BundleCerts(certs.Cert, certs.Fullchain)
+		if err != nil {
+			return fmt.Errorf("%w: 生成证书包失败: %v", ErrDeployVerificationFailed, err)
+		}
+		expected[path] = bundle
 	}
+	if path := d.replacePath(cfg.CombinedPath); path != "" {
+		combined, err := cert.CombinePEM(cfg.CombinedOrder, certs.Cert, certs.Key, certs.Fullchain)
+		if err != nil {
+			return fmt.Errorf("%w: 生成合并文件失败: %v", ErrDeployVerificationFailed, err)
+		}
+		expected[path] = combined
+	}
+	return client.VerifyDeployedFiles(expected, verifyDelay)
+}
 
-	// 写入临时文件然后重命名，确保原子性
-	tempPath := path + ".tmp"
-	if err := os.WriteFile(tempPath, content, 0644); err != nil {
-		return fmt.Errorf("写入临时文件失败: %w", err)
+// combinedOrder 返回合并文件的组成顺序，未配置时为 cert.DefaultCombinedOrder
+func (d *ConfigDrivenDeployer) combinedOrder() string {
+	if d.cfg.CombinedOrder == "" {
+		return cert.DefaultCombinedOrder
 	}
+	return d.cfg.CombinedOrder
+}
 
-	if err := os.Rename(tempPath, path); err != nil {
-		os.Remove(tempPath) // 清理临时文件
-		return fmt.Errorf("重命名文件失败: %w", err)
+// combinedMode 返回合并文件与 PKCS#12 文件的权限：含私钥，使用 KeyMode，未设置时为 client.DefaultCombinedFileMode
+func (d *ConfigDrivenDeployer) combinedMode() os.FileMode {
+	if d.cfg.KeyMode == 0 {
+		return client.DefaultCombinedFileMode
 	}
+	return d.cfg.KeyMode
+}
 
-	return nil
+// fileAttrs 返回部署文件的属主与权限，mode 为 0 时使用默认 0644
+func (d *ConfigDrivenDeployer) fileAttrs(mode os.FileMode) client.FileAttrs {
+	return client.FileAttrs{
+		Owner:   d.cfg.FileOwner,
+		Group:   d.cfg.FileGroup,
+		Mode:    mode,
+		DirMode: d.cfg.DirMode,
+		Sync:    d.cfg.SyncOnWrite,
+	}
 }
 
-// runReloadCmd 执行重载命令（15秒超时）
-// 委托给 command.Execute 实现，避免代码重复
+// runReloadCmd 执行重载命令（默认 15 秒超时，可由 ReloadTimeout 调整）
+// 委托给 command.ExecuteCaptured 实现，分别记录 stdout 与 stderr
 func (d *ConfigDrivenDeployer) runReloadCmd() error {
 	if d.cfg.ReloadCmd == "" {
 		return nil
 	}
 
-	slog.Info("执行重载命令", "cmd", d.cfg.ReloadCmd)
+	timeout := d.cfg.ReloadTimeout
+	if timeout <= 0 {
+		timeout = client.DefaultReloadTimeout
+	}
+	slog.Info("执行重载命令", "cmd", d.cfg.ReloadCmd, "timeout", timeout)
 
-	output, err := command.Execute(context.Background(), d.cfg.ReloadCmd, 15*time.Second)
+	stdout, stderr, err := command.ExecuteCaptured(context.Background(), d.cfg.ReloadCmd, timeout)
 	if err != nil {
-		slog.Error("重载命令执行失败", "error", err, "output", output)
+		slog.Error("重载命令执行失败", "error", err, "stderr", stderr, "stdout", stdout)
 		return fmt.Errorf("重载命令失败: %w", err)
 	}
 
-	slog.Info("重载命令执行成功", "output", output)
+	// 命令成功但有 stderr 输出（如 nginx 的配置警告），单独以警告级别记录
+	if strings.TrimSpace(stderr) != "" {
+		slog.Warn("重载命令输出了警告信息", "stderr", stderr)
+	}
+	slog.Info("重载命令执行成功", "output", stdout)
 	return nil
 }
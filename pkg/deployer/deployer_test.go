@@ -1,11 +1,17 @@
 package deployer
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
+	"time"
 
+	"github.com/Catker/acmeDeliver/pkg/cert"
 	"github.com/Catker/acmeDeliver/pkg/client"
+	"github.com/Catker/acmeDeliver/pkg/command"
+	"github.com/Catker/acmeDeliver/pkg/testutil"
 )
 
 func TestNewDeployer_NoOpDeployer(t *testing.T) {
@@ -161,67 +167,6 @@ func TestConfigDrivenDeployer_Deploy_EmptyContent(t *testing.T) {
 	}
 }
 
-func TestConfigDrivenDeployer_WriteFile(t *testing.T) {
-	// 使用临时目录
-	tmpDir := t.TempDir()
-
-	tests := []struct {
-		name    string
-		path    string
-		content []byte
-		wantErr bool
-	}{
-		{
-			name:    "正常写入",
-			path:    filepath.Join(tmpDir, "test.pem"),
-			content: []byte("test content"),
-			wantErr: false,
-		},
-		{
-			name:    "创建子目录并写入",
-			path:    filepath.Join(tmpDir, "subdir", "nested", "cert.pem"),
-			content: []byte("nested content"),
-			wantErr: false,
-		},
-		{
-			name:    "空路径",
-			path:    "",
-			content: []byte("content"),
-			wantErr: true,
-		},
-		{
-			name:    "空内容",
-			path:    filepath.Join(tmpDir, "empty.pem"),
-			content: []byte{},
-			wantErr: true,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			d := &ConfigDrivenDeployer{}
-			err := d.writeFile(tt.path, tt.content)
-
-			if (err != nil) != tt.wantErr {
-				t.Errorf("writeFile() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-
-			if !tt.wantErr && tt.path != "" {
-				// 验证文件已写入且内容正确
-				data, err := os.ReadFile(tt.path)
-				if err != nil {
-					t.Errorf("读取写入的文件失败: %v", err)
-					return
-				}
-				if string(data) != string(tt.content) {
-					t.Errorf("文件内容 = %q, want %q", string(data), string(tt.content))
-				}
-			}
-		})
-	}
-}
-
 func TestConfigDrivenDeployer_Deploy_FullFlow(t *testing.T) {
 	// 使用临时目录进行完整流程测试
 	tmpDir := t.TempDir()
@@ -269,6 +214,48 @@ func TestConfigDrivenDeployer_Deploy_FullFlow(t *testing.T) {
 	}
 }
 
+func TestConfigDrivenDeployer_Deploy_Bundle(t *testing.T) {
+	caPEM, caKeyPEM, err := testutil.GenerateCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafPEM, keyPEM, err := testutil.GenerateSignedCert(string(caPEM), string(caKeyPEM), "bundle.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpDir := t.TempDir()
+	deployer, err := NewDeployer(DeploymentConfig{
+		Domain:     "bundle.example.com",
+		BundlePath: filepath.Join(tmpDir, "{domain}.pem"),
+		SkipReload: true,
+	})
+	if err != nil {
+		t.Fatalf("NewDeployer() error = %v", err)
+	}
+	if _, ok := deployer.(*ConfigDrivenDeployer); !ok {
+		t.Fatalf("仅配置 bundle_path 时 NewDeployer() = %T, want *ConfigDrivenDeployer", deployer)
+	}
+
+	// fullchain 中 CA 在前、叶子证书重复，证书包应为叶子证书 + CA
+	certs := &client.CertificateFiles{
+		Cert:      leafPEM,
+		Key:       keyPEM,
+		Fullchain: append(append([]byte{}, caPEM...), leafPEM...),
+	}
+	if err := deployer.Deploy(certs, false); err != nil {
+		t.Fatalf("Deploy() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "bundle.example.com.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := string(leafPEM) + string(caPEM); string(data) != want {
+		t.Errorf("证书包内容 = \n%s\nwant\n%s", data, want)
+	}
+}
+
 func TestConfigDrivenDeployer_Deploy_PartialConfig(t *testing.T) {
 	// 测试只配置部分路径的情况
 	tmpDir := t.TempDir()
@@ -308,3 +295,395 @@ func TestConfigDrivenDeployer_Deploy_PartialConfig(t *testing.T) {
 		t.Error("key.pem 不应存在（未配置）")
 	}
 }
+
+func TestConfigDrivenDeployer_ReloadTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		wantErr bool
+	}{
+		{"超时短于命令耗时", 200 * time.Millisecond, true},
+		{"默认超时", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &ConfigDrivenDeployer{cfg: DeploymentConfig{ReloadCmd: "sleep 1", ReloadTimeout: tt.timeout}}
+			err := d.runReloadCmd()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("runReloadCmd() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, command.ErrTimeout) {
+				t.Errorf("runReloadCmd() error = %v, want ErrTimeout", err)
+			}
+		})
+	}
+}
+
+func TestVerifyDeployment(t *testing.T) {
+	old := verifyDelay
+	verifyDelay = 0
+	t.Cleanup(func() { verifyDelay = old })
+
+	caPEM, caKeyPEM, err := testutil.GenerateCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafPEM, keyPEM, err := testutil.GenerateSignedCert(string(caPEM), string(caKeyPEM), "verify.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs := &client.CertificateFiles{Cert: leafPEM, Key: keyPEM, Fullchain: append(append([]byte{}, leafPEM...), caPEM...)}
+
+	tests := []struct {
+		name    string
+		tamper  func(dir string)
+		wantErr bool
+	}{
+		{"内容一致", func(string) {}, false},
+		{"私钥被改写", func(dir string) {
+			os.WriteFile(filepath.Join(dir, "verify.example.com", "key.pem"), []byte("stale"), 0644)
+		}, true},
+		{"证书包被截断", func(dir string) { os.WriteFile(filepath.Join(dir, "bundle.pem"), leafPEM, 0644) }, true},
+		{"部署文件缺失", func(dir string) { os.Remove(filepath.Join(dir, "verify.example.com", "cert.pem")) }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			cfg := DeploymentConfig{
+				Domain:        "verify.example.com",
+				CertPath:      filepath.Join(tmpDir, "{domain}", "cert.pem"),
+				KeyPath:       filepath.Join(tmpDir, "{domain}", "key.pem"),
+				FullchainPath: filepath.Join(tmpDir, "{domain}", "fullchain.pem"),
+				BundlePath:    filepath.Join(tmpDir, "bundle.pem"),
+				SkipReload:    true,
+			}
+			if err := (&ConfigDrivenDeployer{cfg: cfg}).Deploy(certs, false); err != nil {
+				t.Fatalf("Deploy() error = %v", err)
+			}
+			tt.tamper(tmpDir)
+
+			err := VerifyDeployment(cfg, certs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyDeployment() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrDeployVerificationFailed) {
+				t.Errorf("VerifyDeployment() error = %v, want ErrDeployVerificationFailed", err)
+			}
+		})
+	}
+}
+
+func TestConfigDrivenDeployer_Deploy_VerifyAfterDeploy(t *testing.T) {
+	old := verifyDelay
+	verifyDelay = 0
+	t.Cleanup(func() { verifyDelay = old })
+
+	tmpDir := t.TempDir()
+	marker := filepath.Join(tmpDir, "reloaded")
+	deployer, err := NewDeployer(DeploymentConfig{
+		Domain:            "example.com",
+		CertPath:          filepath.Join(tmpDir, "cert.pem"),
+		KeyPath:           filepath.Join(tmpDir, "key.pem"),
+		ReloadCmd:         "touch " + marker,
+		VerifyAfterDeploy: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := deployer.Deploy(&client.CertificateFiles{Cert: []byte("cert"), Key: []byte("key")}, false); err != nil {
+		t.Fatalf("Deploy() error = %v", err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("校验通过后应执行重载命令: %v", err)
+	}
+}
+
+func TestConfigDrivenDeployer_Deploy_ReloadRollback(t *testing.T) {
+	tmpDir := t.TempDir()
+	certPath := filepath.Join(tmpDir, "cert.pem")
+	if err := os.WriteFile(certPath, []byte("old cert"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 模拟 reload 失败：部署新证书后退出码 1，恢复旧证书后成功
+	script := filepath.Join(tmpDir, "reload.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\ngrep -q 'old cert' "+certPath+" || exit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	certs := &client.CertificateFiles{Cert: []byte("new cert")}
+
+	tests := []struct {
+		name      string
+		retention int
+		want      string
+	}{
+		{"回滚到部署前的证书", 3, "old cert"},
+		{"未启用备份时不回滚", 0, "new cert"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.WriteFile(certPath, []byte("old cert"), 0644)
+			d, _ := NewDeployer(DeploymentConfig{Domain: "example.com", CertPath: certPath, ReloadCmd: script, BackupRetention: tt.retention})
+
+			err := d.Deploy(certs, false)
+			if _, ok := command.ExitCode(err); !ok {
+				t.Fatalf("Deploy() error = %v, want reload 退出码错误", err)
+			}
+			if data, _ := os.ReadFile(certPath); string(data) != tt.want {
+				t.Errorf("cert.pem = %q, want %q", data, tt.want)
+			}
+			if got := BackupOf(d).Empty(); got != (tt.retention == 0) {
+				t.Errorf("BackupOf().Empty() = %v", got)
+			}
+		})
+	}
+
+	matches, _ := filepath.Glob(certPath + ".bak.*")
+	if len(matches) != 1 {
+		t.Errorf("备份文件 = %v, want 1 份", matches)
+	}
+}
+
+func TestConfigDrivenDeployer_Deploy_FileOwnerNotFound(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows 不支持 file_owner")
+	}
+	certPath := filepath.Join(t.TempDir(), "cert.pem")
+	d, _ := NewDeployer(DeploymentConfig{Domain: "example.com", CertPath: certPath, FileOwner: "acmedeliver-no-such-user"})
+
+	if err := d.Deploy(&client.CertificateFiles{Cert: []byte("cert")}, false); err == nil {
+		t.Fatal("Deploy() 用户不存在时应返回错误")
+	}
+}
+
+func TestConfigDrivenDeployer_Deploy_FileModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows 不支持 Unix 权限位")
+	}
+	tmpDir := t.TempDir()
+	dir := filepath.Join(tmpDir, "ssl", "{domain}")
+	d, _ := NewDeployer(DeploymentConfig{
+		Domain:        "example.com",
+		CertPath:      filepath.Join(dir, "cert.pem"),
+		KeyPath:       filepath.Join(dir, "key.pem"),
+		FullchainPath: filepath.Join(dir, "fullchain.pem"),
+		CertMode:      0644,
+		KeyMode:       0640,
+		DirMode:       0750,
+	})
+	if err := d.Deploy(&client.CertificateFiles{Cert: []byte("cert"), Key: []byte("key"), Fullchain: []byte("chain")}, false); err != nil {
+		t.Fatalf("Deploy() error = %v", err)
+	}
+
+	domainDir := filepath.Join(tmpDir, "ssl", "example.com")
+	for path, want := range map[string]os.FileMode{
+		filepath.Join(domainDir, "cert.pem"):      0644,
+		filepath.Join(domainDir, "key.pem"):       0640,
+		filepath.Join(domainDir, "fullchain.pem"): 0644,
+		domainDir:                    0750,
+		filepath.Join(tmpDir, "ssl"): 0750,
+	} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s 权限 = %o, want %o", path, got, want)
+		}
+	}
+}
+
+func TestConfigDrivenDeployer_Deploy_Combined(t *testing.T) {
+	certs := &client.CertificateFiles{Cert: []byte("CERT\n"), Key: []byte("KEY\n"), Fullchain: []byte("CERT\nCHAIN")}
+	tests := []struct {
+		order string
+		want  string
+	}{
+		{"", "CERT\nCHAIN\nKEY\n"},
+		{"key,cert", "KEY\nCERT\n"},
+		{"cert,fullchain,key", "CERT\nCERT\nCHAIN\nKEY\n"},
+	}
+	for _, tt := range tests {
+		tmpDir := t.TempDir()
+		d, _ := NewDeployer(DeploymentConfig{
+			Domain:        "example.com",
+			CombinedPath:  filepath.Join(tmpDir, "{domain}.pem"),
+			CombinedOrder: tt.order,
+		})
+		if err := d.Deploy(certs, false); err != nil {
+			t.Fatalf("order %q: Deploy() error = %v", tt.order, err)
+		}
+		path := filepath.Join(tmpDir, "example.com.pem")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("order %q: 合并文件内容 = %q, want %q", tt.order, data, tt.want)
+		}
+		if runtime.GOOS != "windows" {
+			if info, _ := os.Stat(path); info.Mode().Perm() != client.DefaultCombinedFileMode {
+				t.Errorf("order %q: 合并文件权限 = %o, want %o", tt.order, info.Mode().Perm(), client.DefaultCombinedFileMode)
+			}
+		}
+	}
+}
+
+func TestConfigDrivenDeployer_Deploy_PKCS12(t *testing.T) {
+	caPEM, caKeyPEM, err := testutil.GenerateCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, keyPEM, err := testutil.GenerateSignedCert(string(caPEM), string(caKeyPEM), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs := &client.CertificateFiles{Cert: certPEM, Key: keyPEM, Fullchain: append(append([]byte{}, certPEM...), caPEM...)}
+
+	for _, legacy := range []bool{false, true} {
+		tmpDir := t.TempDir()
+		d, _ := NewDeployer(DeploymentConfig{
+			Domain:            "example.com",
+			PKCS12Path:        filepath.Join(tmpDir, "{domain}.p12"),
+			PKCS12Password:    "s3cret",
+			PKCS12Legacy:      legacy,
+			VerifyAfterDeploy: true,
+		})
+		if err := d.Deploy(certs, false); err != nil {
+			t.Fatalf("legacy=%v: Deploy() error = %v", legacy, err)
+		}
+		path := filepath.Join(tmpDir, "example.com.p12")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, leaf, ca, err := cert.DecodePKCS12(data, "s3cret")
+		if err != nil {
+			t.Fatalf("legacy=%v: DecodePKCS12() error = %v", legacy, err)
+		}
+		if leaf.Subject.CommonName != "example.com" || len(ca) != 1 {
+			t.Errorf("legacy=%v: 证书链 = %s + %d 个中间证书", legacy, leaf.Subject.CommonName, len(ca))
+		}
+		if runtime.GOOS != "windows" {
+			if info, _ := os.Stat(path); info.Mode().Perm() != client.DefaultCombinedFileMode {
+				t.Errorf("legacy=%v: PKCS#12 文件权限 = %o, want %o", legacy, info.Mode().Perm(), client.DefaultCombinedFileMode)
+			}
+		}
+	}
+
+	// 证书与私钥不匹配时不写入
+	_, otherKey, _ := testutil.GenerateSelfSignedCert("example.com", time.Hour)
+	path := filepath.Join(t.TempDir(), "mismatch.p12")
+	d, _ := NewDeployer(DeploymentConfig{Domain: "example.com", PKCS12Path: path})
+	if err := d.Deploy(&client.CertificateFiles{Cert: certPEM, Key: otherKey, Fullchain: certs.Fullchain}, false); err == nil {
+		t.Error("证书与私钥不匹配时 Deploy() 应返回错误")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("证书与私钥不匹配时不应写入 PKCS#12 文件, stat error = %v", err)
+	}
+}
+
+func TestConfigDrivenDeployer_Deploy_CombinedEmptyPart(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "combined.pem")
+	d, _ := NewDeployer(DeploymentConfig{Domain: "example.com", CombinedPath: path})
+	err := d.Deploy(&client.CertificateFiles{Cert: []byte("CERT"), Fullchain: []byte("CHAIN")}, false)
+	if err == nil {
+		t.Fatal("私钥为空时 Deploy() 应返回错误")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("私钥为空时不应写入合并文件, stat error = %v", err)
+	}
+
+	// dry-run 不写入
+	if err := d.Deploy(&client.CertificateFiles{Cert: []byte("CERT"), Key: []byte("KEY"), Fullchain: []byte("CHAIN")}, true); err != nil {
+		t.Fatalf("dry-run Deploy() error = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("dry-run 不应写入合并文件, stat error = %v", err)
+	}
+}
+
+func TestConfigDrivenDeployer_Deploy_SyncOnWrite(t *testing.T) {
+	tmpDir := t.TempDir()
+	d, _ := NewDeployer(DeploymentConfig{
+		Domain:        "example.com",
+		CertPath:      filepath.Join(tmpDir, "{domain}", "cert.pem"),
+		KeyPath:       filepath.Join(tmpDir, "{domain}", "key.pem"),
+		FullchainPath: filepath.Join(tmpDir, "{domain}", "fullchain.pem"),
+		SyncOnWrite:   true,
+	})
+	certs := &client.CertificateFiles{Cert: []byte("cert"), Key: []byte("key"), Fullchain: []byte("chain")}
+	if err := d.Deploy(certs, false); err != nil {
+		t.Fatalf("Deploy() error = %v", err)
+	}
+	for name, want := range map[string]string{"cert.pem": "cert", "key.pem": "key", "fullchain.pem": "chain"} {
+		if data, err := os.ReadFile(filepath.Join(tmpDir, "example.com", name)); err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v, want %q", name, data, err, want)
+		}
+	}
+}
+
+func TestConfigDrivenDeployer_Deploy_SkipsReloadWhenUnchanged(t *testing.T) {
+	caPEM, caKeyPEM, err := testutil.GenerateCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newCerts := func() *client.CertificateFiles {
+		certPEM, keyPEM, err := testutil.GenerateSignedCert(string(caPEM), string(caKeyPEM), "example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &client.CertificateFiles{Cert: certPEM, Key: keyPEM, Fullchain: append(append([]byte{}, certPEM...), caPEM...)}
+	}
+	tmpDir := t.TempDir()
+	marker := filepath.Join(tmpDir, "reloaded")
+	cfg := DeploymentConfig{
+		Domain:        "example.com",
+		CertPath:      filepath.Join(tmpDir, "cert.pem"),
+		KeyPath:       filepath.Join(tmpDir, "key.pem"),
+		FullchainPath: filepath.Join(tmpDir, "fullchain.pem"),
+		PKCS12Path:    filepath.Join(tmpDir, "example.com.p12"),
+		ReloadCmd:     "touch " + marker,
+	}
+	cfg.BackupRetention = 3
+	// deploy 部署 certs，返回是否执行了 reload 与 UnchangedOf 的结果
+	deploy := func(cfg DeploymentConfig, certs *client.CertificateFiles) (reloaded, unchanged bool) {
+		t.Helper()
+		os.Remove(marker)
+		d, _ := NewDeployer(cfg)
+		if err := d.Deploy(certs, false); err != nil {
+			t.Fatalf("Deploy() error = %v", err)
+		}
+		_, err := os.Stat(marker)
+		return err == nil, UnchangedOf(d)
+	}
+
+	certs := newCerts()
+	if reloaded, unchanged := deploy(cfg, certs); !reloaded || unchanged {
+		t.Errorf("首次部署 reloaded = %v, unchanged = %v, want true, false", reloaded, unchanged)
+	}
+	if reloaded, unchanged := deploy(cfg, certs); reloaded || !unchanged {
+		t.Errorf("内容未变化时 reloaded = %v, unchanged = %v, want false, true", reloaded, unchanged)
+	}
+	// 首次部署没有已有文件，内容未变化时也不备份
+	if backups, _ := filepath.Glob(filepath.Join(tmpDir, "*.bak.*")); len(backups) != 0 {
+		t.Errorf("内容未变化时不应备份部署文件，备份 = %v", backups)
+	}
+
+	force := cfg
+	force.Force = true
+	if reloaded, unchanged := deploy(force, certs); !reloaded || unchanged {
+		t.Errorf("强制部署时 reloaded = %v, unchanged = %v, want true, false", reloaded, unchanged)
+	}
+
+	// 任一部署文件变化（包括 PKCS#12 的算法）即执行 reload
+	legacy := cfg
+	legacy.PKCS12Legacy = true
+	if reloaded, _ := deploy(legacy, certs); !reloaded {
+		t.Error("PKCS#12 算法变化时应执行 reload")
+	}
+	if reloaded, unchanged := deploy(legacy, newCerts()); !reloaded || unchanged {
+		t.Errorf("证书更新后 reloaded = %v, unchanged = %v, want true, false", reloaded, unchanged)
+	}
+}
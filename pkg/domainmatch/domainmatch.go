@@ -0,0 +1,109 @@
+// Package domainmatch 提供订阅与站点配置共用的域名模式匹配
+package domainmatch
+
+import (
+	"fmt"
+	"strings"
+)
+
+// exactSpecificity 精确域名的基础优先级，高于任何通配符
+const exactSpecificity = 1 << 20
+
+// Match 判断域名是否匹配模式
+//   - example.com    仅匹配自身
+//   - *.example.com  匹配恰好一级子域名（a.example.com），与 DNS 通配符规则一致
+//   - **.example.com 匹配任意层级子域名（a.example.com、a.b.example.com）
+//   - *              匹配所有域名（全局订阅）
+//
+// 通配符均不匹配 example.com 本身
+func Match(pattern, domain string) bool {
+	switch {
+	case pattern == "*":
+		return true
+	case strings.HasPrefix(pattern, "**."):
+		suffix := pattern[2:] // .example.com
+		return len(domain) > len(suffix) && strings.HasSuffix(domain, suffix)
+	case strings.HasPrefix(pattern, "*."):
+		label, ok := strings.CutSuffix(domain, pattern[1:])
+		return ok && label != "" && !strings.Contains(label, ".")
+	default:
+		return pattern == domain
+	}
+}
+
+// IsWildcard 判断模式是否包含通配符
+func IsWildcard(pattern string) bool {
+	return pattern == "*" || strings.HasPrefix(pattern, "*.") || strings.HasPrefix(pattern, "**.")
+}
+
+// Specificity 返回模式的具体程度，数值越大越优先
+// 精确域名最高；通配符按后缀长度排序，后缀相同时 *. 优先于 **.；* 最低
+func Specificity(pattern string) int {
+	switch {
+	case pattern == "*":
+		return 0
+	case strings.HasPrefix(pattern, "**."):
+		return 2 * len(pattern[2:])
+	case strings.HasPrefix(pattern, "*."):
+		return 2*len(pattern[1:]) + 1
+	default:
+		return exactSpecificity + len(pattern)
+	}
+}
+
+// BestMatch 返回 patterns 中匹配 domain 且最具体的模式（见 Specificity），与顺序无关
+// 具体程度相同（即重复模式）时取靠前者；无匹配时返回 false
+func BestMatch(patterns []string, domain string) (string, bool) {
+	best, found := "", false
+	for _, pattern := range patterns {
+		if !Match(pattern, domain) {
+			continue
+		}
+		if !found || Specificity(pattern) > Specificity(best) {
+			best, found = pattern, true
+		}
+	}
+	return best, found
+}
+
+// ValidatePattern 校验模式是否为合法的主机名或通配符（*、*.example.com、**.example.com）
+// 主机名由点分隔的标签组成，每个标签 1~63 个字母、数字或连字符，且不以连字符开头或结尾，总长不超过 253
+func ValidatePattern(pattern string) error {
+	host := pattern
+	switch {
+	case pattern == "*":
+		return nil
+	case strings.HasPrefix(pattern, "**."):
+		host = pattern[3:]
+	case strings.HasPrefix(pattern, "*."):
+		host = pattern[2:]
+	}
+	if err := validateHostname(host); err != nil {
+		return fmt.Errorf("无效的域名 %q: %w", pattern, err)
+	}
+	return nil
+}
+
+// validateHostname 校验主机名格式
+func validateHostname(host string) error {
+	if host == "" {
+		return fmt.Errorf("域名为空")
+	}
+	if len(host) > 253 {
+		return fmt.Errorf("长度超过 253")
+	}
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 {
+			return fmt.Errorf("标签 %q 长度必须为 1~63", label)
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("标签 %q 不能以连字符开头或结尾", label)
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return fmt.Errorf("标签 %q 包含非法字符 %q", label, c)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package domainmatch
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		domain  string
+		want    bool
+	}{
+		// 精确匹配
+		{"example.com", "example.com", true},
+		{"example.com", "www.example.com", false},
+
+		// 单级通配符
+		{"*.example.com", "a.example.com", true},
+		{"*.example.com", "a.b.example.com", false},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", ".example.com", false},
+		{"*.example.com", "badexample.com", false},
+		{"*.api.example.com", "v1.api.example.com", true},
+
+		// 任意层级通配符
+		{"**.example.com", "a.example.com", true},
+		{"**.example.com", "a.b.example.com", true},
+		{"**.example.com", "x.y.z.example.com", true},
+		{"**.example.com", "example.com", false},
+		{"**.example.com", "badexample.com", false},
+
+		// 全局
+		{"*", "example.com", true},
+		{"*", "a.b.example.com", true},
+
+		// 非法模式不匹配
+		{"*example.com", "aexample.com", false},
+		{"a.*.example.com", "a.b.example.com", false},
+	}
+
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.domain); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.domain, got, tt.want)
+		}
+	}
+}
+
+func TestIsWildcard(t *testing.T) {
+	for pattern, want := range map[string]bool{
+		"*":              true,
+		"*.example.com":  true,
+		"**.example.com": true,
+		"example.com":    false,
+		"*example.com":   false,
+	} {
+		if got := IsWildcard(pattern); got != want {
+			t.Errorf("IsWildcard(%q) = %v, want %v", pattern, got, want)
+		}
+	}
+}
+
+func TestSpecificityOrder(t *testing.T) {
+	// 期望的优先级从高到低
+	want := []string{
+		"a.example.com",
+		"example.com",
+		"*.api.example.com",
+		"**.api.example.com",
+		"*.example.com",
+		"**.example.com",
+		"*",
+	}
+
+	got := append([]string(nil), want...)
+	sort.Slice(got, func(i, j int) bool { return got[i] < got[j] }) // 打乱原有顺序
+	sort.SliceStable(got, func(i, j int) bool { return Specificity(got[i]) > Specificity(got[j]) })
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("按 Specificity 排序 = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestBestMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		domain   string
+		want     string
+		wantOK   bool
+	}{
+		{"无模式", nil, "example.com", "", false},
+		{"无匹配", []string{"other.com", "*.other.com"}, "example.com", "", false},
+		{"精确优先于通配符", []string{"*", "*.example.com", "api.example.com"}, "api.example.com", "api.example.com", true},
+		{"精确优先（顺序无关）", []string{"api.example.com", "**.example.com"}, "api.example.com", "api.example.com", true},
+		{"单级优先于多级", []string{"**.example.com", "*.example.com"}, "www.example.com", "*.example.com", true},
+		{"更长后缀优先", []string{"*.example.com", "**.api.example.com"}, "v1.api.example.com", "**.api.example.com", true},
+		{"单级不匹配多级子域名时回退到多级", []string{"*.example.com", "**.example.com"}, "a.b.example.com", "**.example.com", true},
+		{"全局订阅兜底", []string{"*.example.org", "*"}, "example.com", "*", true},
+		{"通配符不匹配根域名", []string{"*.example.com", "**.example.com"}, "example.com", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := BestMatch(tt.patterns, tt.domain)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("BestMatch(%v, %q) = (%q, %v), want (%q, %v)", tt.patterns, tt.domain, got, ok, tt.want, tt.wantOK)
+			}
+
+			// 结果不应依赖模式顺序
+			reversed := make([]string, len(tt.patterns))
+			for i, p := range tt.patterns {
+				reversed[len(tt.patterns)-1-i] = p
+			}
+			if got, ok := BestMatch(reversed, tt.domain); got != tt.want || ok != tt.wantOK {
+				t.Errorf("倒序 BestMatch(%v, %q) = (%q, %v), want (%q, %v)", reversed, tt.domain, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestValidatePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		wantErr bool
+	}{
+		{"example.com", false},
+		{"a-b.example.com", false},
+		{"localhost", false},
+		{"*", false},
+		{"*.example.com", false},
+		{"**.example.com", false},
+		{"", true},
+		{"*example.com", true},
+		{"a.*.example.com", true},
+		{"example..com", true},
+		{"example.com.", true},
+		{"-a.example.com", true},
+		{"a_b.example.com", true},
+		{"exa mple.com", true},
+		{"../etc", true},
+		{strings.Repeat("a", 64) + ".com", true},
+	}
+	for _, tt := range tests {
+		if err := ValidatePattern(tt.pattern); (err != nil) != tt.wantErr {
+			t.Errorf("ValidatePattern(%q) error = %v, wantErr %v", tt.pattern, err, tt.wantErr)
+		}
+	}
+}
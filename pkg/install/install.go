@@ -0,0 +1,305 @@
+// Package install 生成 systemd unit 或 OpenRC 服务脚本并安装到系统
+package install
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// InitSystem 服务管理器类型
+type InitSystem string
+
+const (
+	Systemd InitSystem = "systemd"
+	OpenRC  InitSystem = "openrc"
+)
+
+// 检测与安装使用的系统路径，测试时替换
+var (
+	systemdRunDir  = "/run/systemd/system" // 存在时表示以 systemd 启动（同 sd_booted）
+	openrcRunDir   = "/run/openrc"
+	openrcRunner   = "/sbin/openrc-run"
+	systemdUnitDir = "/etc/systemd/system"
+	openrcInitDir  = "/etc/init.d"
+
+	runCommand = func(name string, args ...string) error {
+		cmd := exec.Command(name, args...)
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		return cmd.Run()
+	}
+)
+
+// Service 服务描述
+type Service struct {
+	Name        string   // 服务名，同时作为 unit 文件名与 OpenRC 脚本名
+	Description string   // 服务说明
+	ExecPath    string   // 可执行文件的绝对路径
+	Args        []string // 启动参数
+	User        string   // 运行用户，空表示 root
+	Group       string   // 运行用户组，空表示用户的主组
+	WorkingDir  string   // 工作目录（配置中的相对路径以此为基准）
+
+	// systemd 安全加固
+	// ProtectSystem 为 strict 时整个文件系统只读，需写入的目录列在 ReadWritePaths 中；
+	// 为 true 时仅 /usr、/boot 只读，适合需要向 /etc 部署证书的客户端
+	ProtectSystem  string
+	ReadWritePaths []string // ProtectSystem 下仍可写入的路径，不存在时忽略
+	PrivateTmp     bool     // 使用独立的 /tmp
+}
+
+// ParseInitSystem 解析 --init-system 取值，空值时自动检测
+func ParseInitSystem(s string) (InitSystem, error) {
+	switch InitSystem(s) {
+	case "":
+		return Detect()
+	case Systemd, OpenRC:
+		return InitSystem(s), nil
+	default:
+		return "", fmt.Errorf("未知的 init 系统: %q（可选 systemd、openrc）", s)
+	}
+}
+
+// Detect 检测当前系统使用的服务管理器
+func Detect() (InitSystem, error) {
+	if info, err := os.Stat(systemdRunDir); err == nil && info.IsDir() {
+		return Systemd, nil
+	}
+	for _, p := range []string{openrcRunDir, openrcRunner} {
+		if _, err := os.Stat(p); err == nil {
+			return OpenRC, nil
+		}
+	}
+	return "", fmt.Errorf("未检测到 systemd 或 OpenRC，请使用 --init-system 指定")
+}
+
+// Path 返回服务文件的安装路径
+func Path(init InitSystem, name string) string {
+	if init == OpenRC {
+		return filepath.Join(openrcInitDir, name)
+	}
+	return filepath.Join(systemdUnitDir, name+".service")
+}
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"systemdQuote": systemdQuote,
+	"systemdPath":  systemdPath,
+	"shellQuote":   shellQuote,
+}).Parse(`{{define "systemd"}}# 由 {{.Name}} --install-service 生成
+[Unit]
+Description={{.Description}}
+Documentation=https://github.com/Catker/acmeDeliver
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+ExecStart={{systemdQuote .ExecPath}}{{range .Args}} {{systemdQuote .}}{{end}}
+ExecReload=/bin/kill -HUP $MAINPID
+{{- if .User}}
+User={{.User}}
+{{- end}}
+{{- if .Group}}
+Group={{.Group}}
+{{- end}}
+{{- if .WorkingDir}}
+WorkingDirectory={{systemdPath .WorkingDir}}
+{{- end}}
+Restart=on-failure
+RestartSec=5
+
+# 安全加固：不启用 PrivateDevices、ProtectHome 等会影响重载命令（如 systemctl reload nginx）的选项
+NoNewPrivileges=true
+{{- if .ProtectSystem}}
+ProtectSystem={{.ProtectSystem}}
+{{- end}}
+{{- range .ReadWritePaths}}
+ReadWritePaths={{systemdQuote (printf "-%s" .)}}
+{{- end}}
+{{- if .PrivateTmp}}
+PrivateTmp=true
+{{- end}}
+ProtectKernelTunables=true
+ProtectKernelModules=true
+ProtectControlGroups=true
+RestrictSUIDSGID=true
+LockPersonality=true
+
+[Install]
+WantedBy=multi-user.target
+{{end}}
+{{- define "openrc"}}#!/sbin/openrc-run
+# 由 {{.Name}} --install-service 生成
+
+name="{{.Name}}"
+description="{{.Description}}"
+command={{shellQuote .ExecPath}}
+command_args="{{range $i, $a := .Args}}{{if $i}} {{end}}{{shellQuote $a}}{{end}}"
+{{- if .User}}
+command_user="{{.User}}{{if .Group}}:{{.Group}}{{end}}"
+{{- end}}
+{{- if .WorkingDir}}
+directory={{shellQuote .WorkingDir}}
+{{- end}}
+supervisor=supervise-daemon
+respawn_delay=5
+respawn_max=0
+output_log="/var/log/{{.Name}}.log"
+error_log="/var/log/{{.Name}}.log"
+extra_started_commands="reload"
+
+depend() {
+	need net
+	after firewall
+}
+
+reload() {
+	ebegin "Reopening ${name} log"
+	supervise-daemon "${RC_SVCNAME}" --signal HUP
+	eend $?
+}
+{{end}}`))
+
+// Render 按服务管理器渲染服务文件
+func Render(init InitSystem, svc *Service) (string, error) {
+	if err := validate(svc); err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, string(init), svc); err != nil {
+		return "", fmt.Errorf("渲染 %s 服务文件失败: %w", init, err)
+	}
+	return buf.String(), nil
+}
+
+// validate 校验服务描述，拒绝可能破坏文件结构或在 shell 中展开的换行、引号、反斜杠与 $
+func validate(svc *Service) error {
+	if svc.Name == "" || strings.ContainsAny(svc.Name, "/ \t") {
+		return fmt.Errorf("无效的服务名: %q", svc.Name)
+	}
+	if !filepath.IsAbs(svc.ExecPath) {
+		return fmt.Errorf("可执行文件必须为绝对路径: %q", svc.ExecPath)
+	}
+	fields := append([]string{svc.Name, svc.Description, svc.ExecPath, svc.User, svc.Group, svc.WorkingDir, svc.ProtectSystem}, svc.Args...)
+	fields = append(fields, svc.ReadWritePaths...)
+	for _, f := range fields {
+		if strings.ContainsAny(f, "\r\n\x00\"$\\`") {
+			return fmt.Errorf("服务参数不能包含换行、双引号、反斜杠、$ 或反引号: %q", f)
+		}
+	}
+	return nil
+}
+
+// systemdQuote 按 systemd 命令行规则转义参数：% 需写成 %%，含空白、单引号或分号时整体加双引号
+// （validate 已拒绝双引号与反斜杠）
+func systemdQuote(s string) string {
+	s = systemdPath(s)
+	if s != "" && !strings.ContainsAny(s, " \t';") {
+		return s
+	}
+	return `"` + s + `"`
+}
+
+// systemdPath 转义 WorkingDirectory 等按字面解析的路径，只需将 % 写成 %%
+func systemdPath(s string) string {
+	return strings.ReplaceAll(s, "%", "%%")
+}
+
+// shellQuote 为 POSIX shell 加单引号，不含特殊字符时原样返回
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=,@+") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Install 写入服务文件并返回其路径
+// systemd 下随后执行 daemon-reload；enable 为 true 时设置开机启动（systemctl enable / rc-update add）
+func Install(init InitSystem, svc *Service, enable bool) (string, error) {
+	content, err := Render(init, svc)
+	if err != nil {
+		return "", err
+	}
+
+	path := Path(init, svc.Name)
+	mode := os.FileMode(0644)
+	if init == OpenRC {
+		mode = 0755
+	}
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		return "", fmt.Errorf("写入服务文件失败: %w", err)
+	}
+	// WriteFile 不修改已存在文件的权限
+	if err := os.Chmod(path, mode); err != nil {
+		return "", err
+	}
+
+	if init == Systemd {
+		if err := runCommand("systemctl", "daemon-reload"); err != nil {
+			return path, fmt.Errorf("systemctl daemon-reload 失败: %w", err)
+		}
+	}
+	if !enable {
+		return path, nil
+	}
+	if init == OpenRC {
+		err = runCommand("rc-update", "add", svc.Name, "default")
+	} else {
+		err = runCommand("systemctl", "enable", svc.Name+".service")
+	}
+	if err != nil {
+		return path, fmt.Errorf("设置开机启动失败: %w", err)
+	}
+	return path, nil
+}
+
+// StartHint 返回安装后启动服务的命令
+func StartHint(init InitSystem, name string) string {
+	if init == OpenRC {
+		return "rc-service " + name + " start"
+	}
+	return "systemctl start " + name
+}
+
+// Executable 返回当前可执行文件解析符号链接后的绝对路径，用于 ExecStart
+func Executable() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(exe)
+}
+
+// Run 执行 --install-service：dryRun 时将服务文件输出到 w，否则安装并在 w 中提示后续操作
+// initName 为 --init-system 取值，空值时自动检测
+func Run(w io.Writer, initName string, svc *Service, dryRun, enable bool) error {
+	init, err := ParseInitSystem(initName)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		content, err := Render(init, svc)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, content)
+		return err
+	}
+
+	path, err := Install(init, svc, enable)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "✅ 已写入 %s 服务文件: %s\n", init, path)
+	if !enable {
+		fmt.Fprintln(w, "   使用 --enable 设置开机启动")
+	}
+	fmt.Fprintf(w, "   启动服务: %s\n", StartHint(init, svc.Name))
+	return nil
+}
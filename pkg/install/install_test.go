@@ -0,0 +1,243 @@
+package install
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testService() *Service {
+	return &Service{
+		Name:           "acmedeliver-server",
+		Description:    "acmeDeliver 证书分发服务端",
+		ExecPath:       "/usr/local/bin/acmedeliver-server",
+		Args:           []string{"-c", "/etc/acme deliver/config.yaml"},
+		User:           "acmedeliver",
+		Group:          "acmedeliver",
+		WorkingDir:     "/var/lib/acmedeliver",
+		ProtectSystem:  "strict",
+		ReadWritePaths: []string{"/var/certs", "/srv/acme certs"},
+		PrivateTmp:     true,
+	}
+}
+
+func TestRenderSystemd(t *testing.T) {
+	got, err := Render(Systemd, testService())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`ExecStart=/usr/local/bin/acmedeliver-server -c "/etc/acme deliver/config.yaml"`,
+		"ExecReload=/bin/kill -HUP $MAINPID",
+		"User=acmedeliver",
+		"Group=acmedeliver",
+		"WorkingDirectory=/var/lib/acmedeliver",
+		"Restart=on-failure",
+		"NoNewPrivileges=true",
+		"ProtectSystem=strict",
+		"ReadWritePaths=-/var/certs",
+		`ReadWritePaths="-/srv/acme certs"`,
+		"PrivateTmp=true",
+		"WantedBy=multi-user.target",
+	} {
+		if !strings.Contains(got, "\n"+line+"\n") {
+			t.Errorf("unit 缺少 %q:\n%s", line, got)
+		}
+	}
+
+	// 未设置的可选项不输出
+	svc := testService()
+	svc.User, svc.Group, svc.WorkingDir, svc.ProtectSystem = "", "", "", ""
+	svc.ReadWritePaths, svc.PrivateTmp = nil, false
+	svc.Args = []string{"-c", "/etc/acmedeliver/100%.yaml", "--daemon"}
+	got, err = Render(Systemd, svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"User=", "Group=", "WorkingDirectory=", "ProtectSystem=", "ReadWritePaths=", "PrivateTmp="} {
+		if strings.Contains(got, "\n"+key) {
+			t.Errorf("未设置时不应输出 %s", key)
+		}
+	}
+	if !strings.Contains(got, "ExecStart=/usr/local/bin/acmedeliver-server -c /etc/acmedeliver/100%%.yaml --daemon\n") {
+		t.Errorf("ExecStart 中的 %% 应转义为 %%%%:\n%s", got)
+	}
+	if strings.Contains(got, "\n\n\n") {
+		t.Errorf("unit 中不应有连续空行:\n%s", got)
+	}
+}
+
+func TestRenderOpenRC(t *testing.T) {
+	got, err := Render(OpenRC, testService())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "#!/sbin/openrc-run\n") {
+		t.Errorf("脚本应以 openrc-run 解释器开头:\n%s", got)
+	}
+	for _, line := range []string{
+		`command=/usr/local/bin/acmedeliver-server`,
+		`command_args="-c '/etc/acme deliver/config.yaml'"`,
+		`command_user="acmedeliver:acmedeliver"`,
+		`directory=/var/lib/acmedeliver`,
+		`supervisor=supervise-daemon`,
+		`output_log="/var/log/acmedeliver-server.log"`,
+	} {
+		if !strings.Contains(got, "\n"+line+"\n") {
+			t.Errorf("脚本缺少 %q:\n%s", line, got)
+		}
+	}
+}
+
+func TestRenderRejectsUnsafeValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Service)
+	}{
+		{"服务名含斜杠", func(s *Service) { s.Name = "../evil" }},
+		{"相对路径可执行文件", func(s *Service) { s.ExecPath = "acmedeliver-server" }},
+		{"用户名含换行", func(s *Service) { s.User = "root\nExecStartPre=/bin/sh" }},
+		{"参数含 $", func(s *Service) { s.Args = []string{"-c", "$HOME/config.yaml"} }},
+		{"路径含双引号", func(s *Service) { s.ReadWritePaths = []string{`/var/"certs`} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := testService()
+			tt.modify(svc)
+			for _, init := range []InitSystem{Systemd, OpenRC} {
+				if _, err := Render(init, svc); err == nil {
+					t.Errorf("Render(%s) 应拒绝", init)
+				}
+			}
+		})
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		in, systemd, shell string
+	}{
+		{"/etc/acmedeliver/config.yaml", "/etc/acmedeliver/config.yaml", "/etc/acmedeliver/config.yaml"},
+		{"/etc/acme deliver/config.yaml", `"/etc/acme deliver/config.yaml"`, `'/etc/acme deliver/config.yaml'`},
+		{"it's", `"it's"`, `'it'\''s'`},
+		{"50%", "50%%", "'50%'"},
+		{"", `""`, "''"},
+	}
+	for _, tt := range tests {
+		if got := systemdQuote(tt.in); got != tt.systemd {
+			t.Errorf("systemdQuote(%q) = %s, want %s", tt.in, got, tt.systemd)
+		}
+		if got := shellQuote(tt.in); got != tt.shell {
+			t.Errorf("shellQuote(%q) = %s, want %s", tt.in, got, tt.shell)
+		}
+	}
+}
+
+// stubSystem 将检测与安装路径指向临时目录，并记录执行的命令
+func stubSystem(t *testing.T) (root string, commands *[]string) {
+	t.Helper()
+	root = t.TempDir()
+	saved := []string{systemdRunDir, openrcRunDir, openrcRunner, systemdUnitDir, openrcInitDir}
+	savedRun := runCommand
+	t.Cleanup(func() {
+		systemdRunDir, openrcRunDir, openrcRunner, systemdUnitDir, openrcInitDir = saved[0], saved[1], saved[2], saved[3], saved[4]
+		runCommand = savedRun
+	})
+	systemdRunDir = filepath.Join(root, "run/systemd/system")
+	openrcRunDir = filepath.Join(root, "run/openrc")
+	openrcRunner = filepath.Join(root, "sbin/openrc-run")
+	systemdUnitDir = filepath.Join(root, "etc/systemd/system")
+	openrcInitDir = filepath.Join(root, "etc/init.d")
+	for _, dir := range []string{systemdUnitDir, openrcInitDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	commands = &[]string{}
+	runCommand = func(name string, args ...string) error {
+		*commands = append(*commands, strings.Join(append([]string{name}, args...), " "))
+		return nil
+	}
+	return root, commands
+}
+
+func TestDetect(t *testing.T) {
+	stubSystem(t)
+	if _, err := Detect(); err == nil {
+		t.Error("没有任何服务管理器时应返回错误")
+	}
+
+	if err := os.MkdirAll(openrcRunDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Detect(); err != nil || got != OpenRC {
+		t.Errorf("Detect() = %q, %v, want openrc", got, err)
+	}
+
+	if err := os.MkdirAll(systemdRunDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Detect(); err != nil || got != Systemd {
+		t.Errorf("Detect() = %q, %v, want systemd", got, err)
+	}
+
+	if got, err := ParseInitSystem("openrc"); err != nil || got != OpenRC {
+		t.Errorf("ParseInitSystem(openrc) = %q, %v", got, err)
+	}
+	if _, err := ParseInitSystem("upstart"); err == nil {
+		t.Error("ParseInitSystem(upstart) 应返回错误")
+	}
+}
+
+func TestInstall(t *testing.T) {
+	_, commands := stubSystem(t)
+	svc := testService()
+
+	path, err := Install(Systemd, svc, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(systemdUnitDir, "acmedeliver-server.service") {
+		t.Errorf("unit 路径 = %s", path)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("unit 文件权限 = %v, %v, want 0644", info.Mode().Perm(), err)
+	}
+	want := []string{"systemctl daemon-reload", "systemctl enable acmedeliver-server.service"}
+	if !reflect.DeepEqual(*commands, want) {
+		t.Errorf("执行的命令 = %v, want %v", *commands, want)
+	}
+
+	*commands = nil
+	path, err = Install(OpenRC, svc, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("OpenRC 脚本应可执行: %v, %v", info.Mode().Perm(), err)
+	}
+	if len(*commands) != 0 {
+		t.Errorf("未指定 enable 时 OpenRC 不应执行命令: %v", *commands)
+	}
+}
+
+func TestRunDryRun(t *testing.T) {
+	_, commands := stubSystem(t)
+	var out bytes.Buffer
+	if err := Run(&out, "systemd", testService(), true, true); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "[Service]") {
+		t.Errorf("dry-run 应输出 unit 内容:\n%s", out.String())
+	}
+	if _, err := os.Stat(Path(Systemd, "acmedeliver-server")); !os.IsNotExist(err) {
+		t.Error("dry-run 不应写入文件")
+	}
+	if len(*commands) != 0 {
+		t.Errorf("dry-run 不应执行命令: %v", *commands)
+	}
+}
@@ -0,0 +1,200 @@
+// Package logging 提供服务端与客户端共用的日志初始化：级别、格式、输出目标与文件轮转
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/Catker/acmeDeliver/pkg/config"
+)
+
+// 日志格式
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Logger 已初始化的日志输出
+// 级别可在运行中调整；输出到文件时支持重新打开以配合 logrotate
+type Logger struct {
+	level  *slog.LevelVar
+	writer *RotatingWriter // 输出到 stdout/stderr 时为 nil
+}
+
+// ParseLevel 解析日志级别，空值视为 info
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "debug":
+		return slog.LevelDebug, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("未知的日志级别: %q（可选 debug/info/warn/error）", s)
+	}
+}
+
+// Setup 按配置创建日志输出并设为 slog 默认 Logger
+// format 为空时使用 text；output 为空或 stdout 时输出到标准输出
+// extra 中的 Handler（如 ringlog.RingHandler）与主输出同时接收日志，各自按自身级别过滤
+func Setup(cfg config.LoggingConfig, extra ...slog.Handler) (*Logger, error) {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+	l := &Logger{level: new(slog.LevelVar)}
+	l.level.Set(level)
+
+	var out io.Writer
+	switch cfg.Output {
+	case "", "stdout":
+		out = os.Stdout
+	case "stderr":
+		out = os.Stderr
+	default:
+		l.writer, err = NewRotatingWriter(cfg.Output, cfg.MaxSizeMB, cfg.MaxBackups, cfg.MaxAgeDays)
+		if err != nil {
+			return nil, err
+		}
+		out = l.writer
+	}
+
+	opts := &slog.HandlerOptions{Level: l.level, AddSource: cfg.AddSource}
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "", FormatText:
+		handler = slog.NewTextHandler(out, opts)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(out, opts)
+	default:
+		l.Close()
+		return nil, fmt.Errorf("未知的日志格式: %q（可选 text/json）", cfg.Format)
+	}
+
+	if len(extra) > 0 {
+		handler = newMultiHandler(append([]slog.Handler{handler}, extra...)...)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return l, nil
+}
+
+// multiHandler 将日志记录分发给多个 Handler
+type multiHandler []slog.Handler
+
+func newMultiHandler(handlers ...slog.Handler) multiHandler {
+	return multiHandler(handlers)
+}
+
+// Enabled 任一 Handler 接收该级别即返回 true
+func (m multiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range m {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle 将记录交给所有接收该级别的 Handler，返回遇到的第一个错误
+func (m multiHandler) Handle(ctx context.Context, r slog.Record) error {
+	var firstErr error
+	for _, h := range m {
+		if !h.Enabled(ctx, r.Level) {
+			continue
+		}
+		if err := h.Handle(ctx, r.Clone()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (m multiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(multiHandler, len(m))
+	for i, h := range m {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (m multiHandler) WithGroup(name string) slog.Handler {
+	out := make(multiHandler, len(m))
+	for i, h := range m {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
+
+// SetLevel 调整日志级别（用于配置热重载）
+func (l *Logger) SetLevel(level string) error {
+	lv, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	if l.level.Level() != lv {
+		l.level.Set(lv)
+		slog.Info("🔄 日志级别已更新", "level", lv.String())
+	}
+	return nil
+}
+
+// Level 返回当前日志级别
+func (l *Logger) Level() slog.Level {
+	return l.level.Level()
+}
+
+// Reopen 重新打开日志文件，输出到 stdout/stderr 时为空操作
+func (l *Logger) Reopen() error {
+	if l.writer == nil {
+		return nil
+	}
+	return l.writer.Reopen()
+}
+
+// ReopenOnSIGHUP 收到 SIGHUP 时重新打开日志文件，返回停止监听的函数
+// 仅在输出到文件时监听，避免改变终端挂断时的默认行为
+func (l *Logger) ReopenOnSIGHUP() (stop func()) {
+	if l.writer == nil {
+		return func() {}
+	}
+
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-ch:
+				if err := l.Reopen(); err != nil {
+					fmt.Fprintln(os.Stderr, "重新打开日志文件失败:", err)
+					continue
+				}
+				slog.Info("收到 SIGHUP，已重新打开日志文件")
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
+
+// Close 关闭日志文件
+func (l *Logger) Close() error {
+	if l.writer == nil {
+		return nil
+	}
+	return l.writer.Close()
+}
@@ -0,0 +1,120 @@
+package logging
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Catker/acmeDeliver/pkg/config"
+	"github.com/Catker/acmeDeliver/pkg/ringlog"
+)
+
+// keepDefaultLogger 测试结束后恢复 slog 默认 Logger
+func keepDefaultLogger(t *testing.T) {
+	t.Helper()
+	prev := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(prev) })
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    slog.Level
+		wantErr bool
+	}{
+		{"", slog.LevelInfo, false},
+		{"info", slog.LevelInfo, false},
+		{"DEBUG", slog.LevelDebug, false},
+		{"warn", slog.LevelWarn, false},
+		{"warning", slog.LevelWarn, false},
+		{"error", slog.LevelError, false},
+		{"verbose", slog.LevelInfo, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseLevel(tt.in)
+		if tt.wantErr {
+			assert.Error(t, err, tt.in)
+			continue
+		}
+		assert.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+}
+
+func TestSetupFileOutput(t *testing.T) {
+	keepDefaultLogger(t)
+	path := filepath.Join(t.TempDir(), "acmedeliver.log")
+
+	logger, err := Setup(config.LoggingConfig{Level: "warn", Format: "json", Output: path})
+	require.NoError(t, err)
+	defer logger.Close()
+
+	slog.Info("不应输出")
+	slog.Warn("告警", "domain", "example.com")
+
+	// 热重载调整级别
+	require.NoError(t, logger.SetLevel("debug"))
+	assert.Equal(t, slog.LevelDebug, logger.Level())
+	slog.Debug("调试")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	out := string(data)
+	assert.NotContains(t, out, "不应输出")
+	assert.Contains(t, out, `"msg":"告警"`)
+	assert.Contains(t, out, `"domain":"example.com"`)
+	assert.Contains(t, out, `"msg":"调试"`)
+}
+
+func TestSetupRejectsInvalid(t *testing.T) {
+	keepDefaultLogger(t)
+
+	_, err := Setup(config.LoggingConfig{Level: "loud"})
+	assert.Error(t, err)
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	_, err = Setup(config.LoggingConfig{Format: "xml", Output: path})
+	if assert.Error(t, err) {
+		assert.True(t, strings.Contains(err.Error(), "xml"))
+	}
+}
+
+func TestSetLevelRejectsInvalid(t *testing.T) {
+	keepDefaultLogger(t)
+
+	logger, err := Setup(config.LoggingConfig{Output: "stderr"})
+	require.NoError(t, err)
+	assert.Error(t, logger.SetLevel("loud"))
+	assert.Equal(t, slog.LevelInfo, logger.Level(), "无效级别不应改变当前级别")
+	assert.NoError(t, logger.Reopen(), "标准输出无需重新打开")
+}
+
+func TestSetupExtraHandlers(t *testing.T) {
+	keepDefaultLogger(t)
+	path := filepath.Join(t.TempDir(), "acmedeliver.log")
+	ring := ringlog.NewRingHandler(10, slog.LevelInfo)
+
+	logger, err := Setup(config.LoggingConfig{Level: "warn", Format: "json", Output: path}, ring)
+	require.NoError(t, err)
+	defer logger.Close()
+
+	// 各 Handler 按自身级别过滤：INFO 只进入环形缓冲区
+	slog.Info("仅缓冲区")
+	slog.With("domain", "example.com").Warn("两者都有")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "仅缓冲区")
+	assert.Contains(t, string(data), `"msg":"两者都有"`)
+
+	records := ring.Records()
+	require.Len(t, records, 2)
+	assert.Equal(t, "仅缓冲区", records[0].Message)
+	assert.Equal(t, "example.com", records[1].Attrs["domain"])
+}
@@ -0,0 +1,181 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat 历史日志文件名中的时间格式：<文件名>.<时间>[-序号]
+const backupTimeFormat = "20060102-150405.000"
+
+// RotatingWriter 按大小轮转的日志文件写入器
+// 超过 maxSize 时将当前文件重命名为带时间戳的历史文件，并按数量与保留天数清理旧文件
+type RotatingWriter struct {
+	path       string
+	maxSize    int64         // 字节，0 表示不轮转
+	maxBackups int           // 0 表示不限数量
+	maxAge     time.Duration // 0 表示不限时间
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	now  func() time.Time
+}
+
+// NewRotatingWriter 创建日志文件写入器，文件不存在时自动创建（含父目录）
+func NewRotatingWriter(path string, maxSizeMB, maxBackups, maxAgeDays int) (*RotatingWriter, error) {
+	w := &RotatingWriter{
+		path:       path,
+		maxSize:    int64(maxSizeMB) * 1024 * 1024,
+		maxBackups: maxBackups,
+		maxAge:     time.Duration(maxAgeDays) * 24 * time.Hour,
+		now:        time.Now,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write 实现 io.Writer，写入前检查是否需要轮转
+func (w *RotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	if w.maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Reopen 重新打开日志文件
+// 供 logrotate 等外部工具移走日志文件后（SIGHUP）继续写入新文件
+func (w *RotatingWriter) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+	}
+	return w.open()
+}
+
+// Close 关闭日志文件
+func (w *RotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// open 以追加方式打开日志文件，调用方必须持有 w.mu
+func (w *RotatingWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.path), 0755); err != nil {
+		return fmt.Errorf("创建日志目录失败: %w", err)
+	}
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("打开日志文件失败: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("读取日志文件信息失败: %w", err)
+	}
+	w.file = f
+	w.size = info.Size()
+	return nil
+}
+
+// rotate 将当前文件重命名为历史文件并打开新文件，调用方必须持有 w.mu
+func (w *RotatingWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+
+	backup := w.path + "." + w.now().Format(backupTimeFormat)
+	// 同一毫秒内多次轮转时追加序号，避免覆盖
+	for i := 1; ; i++ {
+		if _, err := os.Stat(backup); os.IsNotExist(err) {
+			break
+		}
+		backup = fmt.Sprintf("%s.%s-%d", w.path, w.now().Format(backupTimeFormat), i)
+	}
+	if err := os.Rename(w.path, backup); err != nil {
+		return fmt.Errorf("轮转日志文件失败: %w", err)
+	}
+
+	if err := w.open(); err != nil {
+		return err
+	}
+	w.cleanup()
+	return nil
+}
+
+// backups 返回现有的历史文件，按从新到旧排序
+func (w *RotatingWriter) backups() []string {
+	matches, _ := filepath.Glob(w.path + ".*")
+	var files []string
+	for _, m := range matches {
+		if _, ok := backupTime(w.path, m); ok {
+			files = append(files, m)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+	return files
+}
+
+// cleanup 按 maxBackups 与 maxAge 删除过期的历史文件
+func (w *RotatingWriter) cleanup() {
+	if w.maxBackups <= 0 && w.maxAge <= 0 {
+		return
+	}
+
+	cutoff := w.now().Add(-w.maxAge)
+	for i, f := range w.backups() {
+		expired := w.maxBackups > 0 && i >= w.maxBackups
+		if w.maxAge > 0 {
+			if t, _ := backupTime(w.path, f); t.Before(cutoff) {
+				expired = true
+			}
+		}
+		if expired {
+			os.Remove(f)
+		}
+	}
+}
+
+// backupTime 解析历史文件名中的时间，非历史文件返回 false
+func backupTime(path, name string) (time.Time, bool) {
+	suffix := strings.TrimPrefix(name, path+".")
+	if suffix == name || len(suffix) < len(backupTimeFormat) {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(backupTimeFormat, suffix[:len(backupTimeFormat)], time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,115 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestWriter 创建以字节为单位限制大小的写入器
+func newTestWriter(t *testing.T, maxSize int64, maxBackups int) (*RotatingWriter, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "logs", "app.log")
+	w, err := NewRotatingWriter(path, 0, maxBackups, 0)
+	require.NoError(t, err)
+	w.maxSize = maxSize
+	t.Cleanup(func() { w.Close() })
+	return w, path
+}
+
+func TestRotatingWriterRotatesAtSizeThreshold(t *testing.T) {
+	w, path := newTestWriter(t, 100, 0)
+
+	first := strings.Repeat("a", 60) + "\n"
+	second := strings.Repeat("b", 38) + "\n"
+	_, err := w.Write([]byte(first))
+	require.NoError(t, err)
+	// 未超过阈值（60 + 39 = 99）时不轮转
+	_, err = w.Write([]byte(second))
+	require.NoError(t, err)
+	assert.Empty(t, w.backups())
+
+	third := "c\n"
+	_, err = w.Write([]byte(third))
+	require.NoError(t, err)
+
+	backups := w.backups()
+	require.Len(t, backups, 1, "超过阈值应轮转一次")
+	old, err := os.ReadFile(backups[0])
+	require.NoError(t, err)
+	assert.Equal(t, first+second, string(old))
+
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, third, string(current))
+}
+
+func TestRotatingWriterKeepsMaxBackups(t *testing.T) {
+	w, _ := newTestWriter(t, 10, 2)
+
+	for i := 0; i < 5; i++ {
+		_, err := w.Write([]byte("0123456789"))
+		require.NoError(t, err)
+	}
+
+	assert.Len(t, w.backups(), 2, "应只保留 max_backups 个历史文件")
+}
+
+func TestRotatingWriterRemovesExpiredBackups(t *testing.T) {
+	w, path := newTestWriter(t, 10, 0)
+	w.maxAge = 24 * time.Hour
+
+	stale := path + "." + time.Now().Add(-48*time.Hour).Format(backupTimeFormat)
+	require.NoError(t, os.WriteFile(stale, []byte("old"), 0644))
+	unrelated := path + ".bak"
+	require.NoError(t, os.WriteFile(unrelated, []byte("keep"), 0644))
+
+	for i := 0; i < 2; i++ {
+		_, err := w.Write([]byte("0123456789"))
+		require.NoError(t, err)
+	}
+
+	assert.NoFileExists(t, stale, "超过 max_age_days 的历史文件应被删除")
+	assert.FileExists(t, unrelated, "非历史文件不应被删除")
+	assert.Len(t, w.backups(), 1)
+}
+
+func TestRotatingWriterReopen(t *testing.T) {
+	w, path := newTestWriter(t, 0, 0)
+
+	_, err := w.Write([]byte("before\n"))
+	require.NoError(t, err)
+
+	// 模拟 logrotate 移走文件后发送 SIGHUP
+	moved := path + ".1"
+	require.NoError(t, os.Rename(path, moved))
+	require.NoError(t, w.Reopen())
+	_, err = w.Write([]byte("after\n"))
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(moved)
+	require.NoError(t, err)
+	assert.Equal(t, "before\n", string(data))
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "after\n", string(data))
+}
+
+func TestRotatingWriterResumesExistingSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Repeat("x", 90)), 0644))
+
+	w, err := NewRotatingWriter(path, 0, 0, 0)
+	require.NoError(t, err)
+	defer w.Close()
+	w.maxSize = 100
+
+	_, err = w.Write([]byte(strings.Repeat("y", 20)))
+	require.NoError(t, err)
+	assert.Len(t, w.backups(), 1, "已有内容应计入大小，重启后仍按阈值轮转")
+}
@@ -0,0 +1,161 @@
+// Package ringlog 提供在内存中保留最近 N 条日志的 slog.Handler，
+// 用于在健康检查失败时返回上下文，无需登录服务器查看日志文件
+package ringlog
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCapacity 默认保留的日志条数
+const DefaultCapacity = 200
+
+// redacted 敏感字段的替换值
+const redacted = "[REDACTED]"
+
+// Record 一条日志记录，属性已展开并脱敏
+type Record struct {
+	Time    time.Time         `json:"time"`
+	Level   string            `json:"level"`
+	Message string            `json:"msg"`
+	Attrs   map[string]string `json:"attrs,omitempty"`
+}
+
+// ring 环形缓冲区，由同一 RingHandler 派生的 Handler 共享
+type ring struct {
+	mu      sync.RWMutex
+	records []Record
+	next    int  // 下一条写入位置
+	full    bool // 是否已写满一轮
+}
+
+// RingHandler 将日志记录写入固定容量的环形缓冲区，写满后覆盖最旧的记录
+type RingHandler struct {
+	ring   *ring
+	level  slog.Leveler
+	attrs  []slog.Attr // WithAttrs 预置的属性（键已带分组前缀）
+	prefix string      // WithGroup 累积的分组前缀，如 "req."
+}
+
+// NewRingHandler 创建保留最近 capacity 条记录的 Handler，capacity <= 0 时使用 DefaultCapacity
+// level 为 nil 时记录 INFO 及以上级别
+func NewRingHandler(capacity int, level slog.Leveler) *RingHandler {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	if level == nil {
+		level = slog.LevelInfo
+	}
+	return &RingHandler{
+		ring:  &ring{records: make([]Record, capacity)},
+		level: level,
+	}
+}
+
+// Enabled 实现 slog.Handler
+func (h *RingHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+// Handle 实现 slog.Handler，敏感字段在写入缓冲区前脱敏
+func (h *RingHandler) Handle(_ context.Context, r slog.Record) error {
+	rec := Record{
+		Time:    r.Time,
+		Level:   r.Level.String(),
+		Message: r.Message,
+	}
+	if len(h.attrs) > 0 || r.NumAttrs() > 0 {
+		rec.Attrs = make(map[string]string, len(h.attrs)+r.NumAttrs())
+		for _, a := range h.attrs {
+			addAttr(rec.Attrs, "", a)
+		}
+		r.Attrs(func(a slog.Attr) bool {
+			addAttr(rec.Attrs, h.prefix, a)
+			return true
+		})
+	}
+
+	h.ring.mu.Lock()
+	h.ring.records[h.ring.next] = rec
+	h.ring.next = (h.ring.next + 1) % len(h.ring.records)
+	if h.ring.next == 0 {
+		h.ring.full = true
+	}
+	h.ring.mu.Unlock()
+	return nil
+}
+
+// WithAttrs 实现 slog.Handler，返回的 Handler 与原 Handler 共享缓冲区
+func (h *RingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.attrs = make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	h2.attrs = append(h2.attrs, h.attrs...)
+	for _, a := range attrs {
+		a.Key = h.prefix + a.Key
+		h2.attrs = append(h2.attrs, a)
+	}
+	return &h2
+}
+
+// WithGroup 实现 slog.Handler，分组以 "." 连接到属性键上
+func (h *RingHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+	return &h2
+}
+
+// Records 按时间顺序（旧到新）返回缓冲区中的记录副本
+func (h *RingHandler) Records() []Record {
+	h.ring.mu.RLock()
+	defer h.ring.mu.RUnlock()
+
+	if !h.ring.full {
+		return append([]Record(nil), h.ring.records[:h.ring.next]...)
+	}
+	out := make([]Record, 0, len(h.ring.records))
+	out = append(out, h.ring.records[h.ring.next:]...)
+	return append(out, h.ring.records[:h.ring.next]...)
+}
+
+// addAttr 展开属性（含嵌套分组）写入 m，敏感字段替换为 [REDACTED]
+func addAttr(m map[string]string, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		group := prefix
+		if a.Key != "" {
+			group += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			addAttr(m, group, ga)
+		}
+		return
+	}
+
+	key := prefix + a.Key
+	if isSensitive(a.Key) {
+		m[key] = redacted
+		return
+	}
+	m[key] = a.Value.String()
+}
+
+// isSensitive 判断属性键是否可能包含密码或密钥
+func isSensitive(key string) bool {
+	k := strings.ToLower(key)
+	for _, word := range []string{"password", "passwd", "secret", "token"} {
+		if strings.Contains(k, word) {
+			return true
+		}
+	}
+	// key、key_preview、new_key 等认证密钥字段
+	return k == "key" || strings.HasPrefix(k, "key_") || strings.HasSuffix(k, "_key")
+}
@@ -0,0 +1,137 @@
+package ringlog
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"testing"
+)
+
+func messages(records []Record) []string {
+	out := make([]string, len(records))
+	for i, r := range records {
+		out[i] = r.Message
+	}
+	return out
+}
+
+func TestRingHandlerWrapAround(t *testing.T) {
+	h := NewRingHandler(3, nil)
+	logger := slog.New(h)
+
+	if got := h.Records(); len(got) != 0 {
+		t.Fatalf("空缓冲区 Records() = %v", got)
+	}
+
+	logger.Info("m1")
+	logger.Info("m2")
+	if got := fmt.Sprint(messages(h.Records())); got != "[m1 m2]" {
+		t.Errorf("未写满时 Records() = %s, want [m1 m2]", got)
+	}
+
+	for i := 3; i <= 7; i++ {
+		logger.Info(fmt.Sprintf("m%d", i))
+	}
+	if got := fmt.Sprint(messages(h.Records())); got != "[m5 m6 m7]" {
+		t.Errorf("写满后 Records() = %s, want [m5 m6 m7]", got)
+	}
+}
+
+func TestRingHandlerDefaults(t *testing.T) {
+	h := NewRingHandler(0, nil)
+	if len(h.ring.records) != DefaultCapacity {
+		t.Errorf("容量 = %d, want %d", len(h.ring.records), DefaultCapacity)
+	}
+
+	logger := slog.New(h)
+	logger.Debug("调试")
+	logger.Warn("告警")
+	records := h.Records()
+	if len(records) != 1 || records[0].Message != "告警" || records[0].Level != "WARN" {
+		t.Errorf("默认只记录 INFO 及以上, Records() = %+v", records)
+	}
+}
+
+func TestRingHandlerAttrs(t *testing.T) {
+	h := NewRingHandler(10, slog.LevelDebug)
+	logger := slog.New(h).With("client_id", "web-01").WithGroup("req")
+
+	logger.Info("认证失败",
+		"password", "hunter2",
+		"key_preview", "abcdefgh...",
+		slog.Group("auth", slog.String("token", "t0ken"), slog.Int("attempt", 3)),
+		"error", errors.New("签名不匹配"),
+	)
+
+	attrs := h.Records()[0].Attrs
+	want := map[string]string{
+		"client_id":        "web-01",
+		"req.password":     redacted,
+		"req.key_preview":  redacted,
+		"req.auth.token":   redacted,
+		"req.auth.attempt": "3",
+		"req.error":        "签名不匹配",
+	}
+	if len(attrs) != len(want) {
+		t.Errorf("Attrs = %v, want %v", attrs, want)
+	}
+	for k, v := range want {
+		if attrs[k] != v {
+			t.Errorf("Attrs[%q] = %q, want %q", k, attrs[k], v)
+		}
+	}
+}
+
+func TestIsSensitive(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"password", true},
+		{"Password", true},
+		{"new_password", true},
+		{"secret", true},
+		{"token", true},
+		{"key", true},
+		{"new_key", true},
+		{"key_preview", true},
+		{"domain", false},
+		{"keys", false},
+		{"monkey", false},
+		{"client_id", false},
+	}
+
+	for _, tt := range tests {
+		if got := isSensitive(tt.key); got != tt.want {
+			t.Errorf("isSensitive(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestRingHandlerConcurrent(t *testing.T) {
+	h := NewRingHandler(50, nil)
+	logger := slog.New(h)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				logger.Info("写入", "worker", i, "seq", j)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				h.Records()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := len(h.Records()); got != 50 {
+		t.Errorf("len(Records()) = %d, want 50", got)
+	}
+}
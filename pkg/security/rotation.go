@@ -0,0 +1,60 @@
+package security
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ErrKeyRotationInvalid 密钥轮换消息无法用当前密钥解开（非本服务端发出或已被篡改）
+var ErrKeyRotationInvalid = errors.New("密钥轮换消息校验失败")
+
+// rotationAEAD 以旧密钥派生 AES-256-GCM
+func rotationAEAD(oldKey string) (cipher.AEAD, error) {
+	sum := sha256.Sum256([]byte(oldKey))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// SealRotationKey 使用旧密钥加密并认证新密钥
+// 时间戳作为附加认证数据，防止将消息挪用到其他时间戳
+// 新密钥不以明文下发，避免 ws:// 明文连接上泄露
+func SealRotationKey(oldKey, newKey string, timestamp int64) (string, error) {
+	aead, err := rotationAEAD(oldKey)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("生成随机数失败: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(newKey), []byte(strconv.FormatInt(timestamp, 10)))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// OpenRotationKey 使用旧密钥解开 SealRotationKey 生成的新密钥
+// 旧密钥不匹配或内容被篡改时返回 ErrKeyRotationInvalid
+func OpenRotationKey(oldKey, sealed string, timestamp int64) (string, error) {
+	aead, err := rotationAEAD(oldKey)
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < aead.NonceSize() {
+		return "", ErrKeyRotationInvalid
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, []byte(strconv.FormatInt(timestamp, 10)))
+	if err != nil {
+		return "", ErrKeyRotationInvalid
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,52 @@
+package security
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSealOpenRotationKey(t *testing.T) {
+	sealed, err := SealRotationKey("old-key", "new-key", 1700000000)
+	if err != nil {
+		t.Fatalf("加密新密钥失败: %v", err)
+	}
+	if strings.Contains(sealed, "new-key") {
+		t.Error("新密钥不应以明文出现在消息中")
+	}
+
+	got, err := OpenRotationKey("old-key", sealed, 1700000000)
+	if err != nil {
+		t.Fatalf("解开新密钥失败: %v", err)
+	}
+	if got != "new-key" {
+		t.Errorf("新密钥 = %q, 期望 new-key", got)
+	}
+}
+
+func TestOpenRotationKeyRejectsInvalid(t *testing.T) {
+	sealed, err := SealRotationKey("old-key", "new-key", 1700000000)
+	if err != nil {
+		t.Fatalf("加密新密钥失败: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		oldKey    string
+		sealed    string
+		timestamp int64
+	}{
+		{"旧密钥不匹配", "other-key", sealed, 1700000000},
+		{"时间戳被篡改", "old-key", sealed, 1700000001},
+		{"非 base64", "old-key", "!!!", 1700000000},
+		{"内容过短", "old-key", "AAAA", 1700000000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := OpenRotationKey(tt.oldKey, tt.sealed, tt.timestamp); !errors.Is(err, ErrKeyRotationInvalid) {
+				t.Errorf("期望 ErrKeyRotationInvalid，实际 %v", err)
+			}
+		})
+	}
+}
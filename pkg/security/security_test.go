@@ -1,6 +1,7 @@
 package security
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -35,6 +36,46 @@ func TestIPWhitelist_IsAllowed(t *testing.T) {
 	}
 }
 
+func TestIPWhitelist_IsAllowedMixedFamilies(t *testing.T) {
+	tests := []struct {
+		name      string
+		whitelist string
+		ip        string
+		want      bool
+	}{
+		// IPv4 地址与 IPv4 网段
+		{"IPv4 地址匹配 IPv4 网段", "192.168.1.0/24", "192.168.1.1", true},
+		{"IPv4 地址不在 IPv4 网段内", "192.168.1.0/24", "192.168.2.1", false},
+		{"IPv4 地址匹配单个 IPv4", "192.168.1.1", "192.168.1.1", true},
+
+		// IPv4 映射的 IPv6 地址与 IPv4 网段
+		{"映射地址匹配 IPv4 网段", "192.168.1.0/24", "::ffff:192.168.1.1", true},
+		{"映射地址不在 IPv4 网段内", "192.168.1.0/24", "::ffff:192.168.2.1", false},
+		{"映射地址匹配单个 IPv4", "192.168.1.1", "::ffff:192.168.1.1", true},
+		{"十六进制映射地址匹配单个 IPv4", "192.168.1.1", "::ffff:c0a8:101", true},
+
+		// 纯 IPv6 地址与 IPv6 网段
+		{"IPv6 地址匹配 IPv6 网段", "fd00::/8", "fd12::1", true},
+		{"IPv6 地址不在 IPv6 网段内", "fd00::/8", "2001:db8::1", false},
+		{"IPv6 地址按规范形式匹配", "2001:DB8::0001", "2001:db8::1", true},
+		{"IPv6 网段不匹配 IPv4 地址", "fd00::/8", "192.168.1.1", false},
+
+		// 混合：IPv4 映射写法的白名单与 IPv4 地址
+		{"IPv4 地址匹配映射写法的网段", "::ffff:192.168.1.0/120", "192.168.1.1", true},
+		{"IPv4 地址匹配映射写法的单个地址", "::ffff:192.168.1.1", "192.168.1.1", true},
+		{"映射地址不匹配 IPv6 网段", "fd00::/8", "::ffff:192.168.1.1", false},
+		{"IPv4 网段不匹配纯 IPv6 地址", "0.0.0.0/0", "2001:db8::1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wl := NewIPWhitelist(tt.whitelist)
+			if got := wl.IsAllowed(tt.ip); got != tt.want {
+				t.Errorf("NewIPWhitelist(%q).IsAllowed(%q) = %v, want %v", tt.whitelist, tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestIPWhitelist_Update(t *testing.T) {
 	wl := NewIPWhitelist("192.168.1.0/24")
 
@@ -50,3 +91,23 @@ func TestIPWhitelist_Update(t *testing.T) {
 		t.Error("IsAllowed() should return false for IP not in updated whitelist")
 	}
 }
+
+func TestIPWhitelist_GetEntries(t *testing.T) {
+	wl := NewIPWhitelist("10.0.0.2, 192.168.1.0/24,10.0.0.1,fd00::/8")
+
+	if got, want := wl.GetIPs(), []string{"10.0.0.1", "10.0.0.2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetIPs() = %v, want %v", got, want)
+	}
+	if got, want := wl.GetCIDRs(), []string{"192.168.1.0/24", "fd00::/8"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetCIDRs() = %v, want %v", got, want)
+	}
+
+	// 热重载后返回新的快照
+	wl.Update("172.16.0.0/12")
+	if got := wl.GetIPs(); len(got) != 0 {
+		t.Errorf("GetIPs() after Update = %v, want empty", got)
+	}
+	if got, want := wl.GetCIDRs(), []string{"172.16.0.0/12"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetCIDRs() after Update = %v, want %v", got, want)
+	}
+}
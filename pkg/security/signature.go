@@ -2,6 +2,7 @@
 package security
 
 import (
+	"crypto/hmac"
 	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/hex"
@@ -14,6 +15,12 @@ const (
 	DefaultTimestampTolerance int64 = 30
 )
 
+// 签名版本
+const (
+	SignatureV1 = 1 // sha256(password + timestamp)，已弃用，仅为兼容旧版本保留
+	SignatureV2 = 2 // HMAC-SHA256(password, timestamp)
+)
+
 // SignatureVerifier 签名验证器
 type SignatureVerifier struct {
 	password           string
@@ -36,29 +43,65 @@ func NewSignatureVerifierWithTolerance(password string, tolerance int64) *Signat
 	}
 }
 
-// GenerateSignature 生成签名: sha256(password + timestamp)
+// GenerateSignature 生成 V1 签名: sha256(password + timestamp)
+// 不是 HMAC，仅用于与旧版本服务端、客户端兼容，新代码使用 GenerateHMAC
 func (v *SignatureVerifier) GenerateSignature(timestamp int64) string {
 	timestampStr := strconv.FormatInt(timestamp, 10)
 	hash := sha256.Sum256([]byte(v.password + timestampStr))
 	return hex.EncodeToString(hash[:])
 }
 
-// VerifySignature 验证签名
+// GenerateHMAC 生成 V2 签名: HMAC-SHA256(password, timestamp)
+func (v *SignatureVerifier) GenerateHMAC(timestamp int64) string {
+	mac := hmac.New(sha256.New, []byte(v.password))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// GenerateSignatureVersion 按签名版本生成签名，SignatureV2 使用 HMAC，其余使用 V1
+func (v *SignatureVerifier) GenerateSignatureVersion(timestamp int64, version int) string {
+	if version >= SignatureV2 {
+		return v.GenerateHMAC(timestamp)
+	}
+	return v.GenerateSignature(timestamp)
+}
+
+// VerifySignature 验证 V1 签名
 // 返回值: 是否验证通过, 错误描述（如果失败）
 func (v *SignatureVerifier) VerifySignature(signature string, timestamp int64) (bool, string) {
-	// 检查时间戳是否在容差范围内
-	now := time.Now().Unix()
-	if timestamp < now-v.timestampTolerance || timestamp > now+v.timestampTolerance {
+	if !v.timestampValid(timestamp) {
 		return false, "时间戳已过期"
 	}
-
-	// 生成预期签名
-	expectedSig := v.GenerateSignature(timestamp)
-
-	// 使用恒定时间比较防止时序攻击
-	if subtle.ConstantTimeCompare([]byte(signature), []byte(expectedSig)) != 1 {
+	if !v.matches(signature, timestamp, SignatureV1) {
 		return false, "签名验证失败"
 	}
-
 	return true, ""
 }
+
+// VerifyVersioned 按客户端声明的签名版本验证签名
+// version 为 SignatureV2 时只接受 HMAC 签名；旧客户端未声明版本（0 或 SignatureV1）时先按 V2 再按 V1 验证
+// 返回通过验证的签名版本（由调用方决定是否接受 SignatureV1），失败时返回 0 与错误描述
+func (v *SignatureVerifier) VerifyVersioned(signature string, timestamp int64, version int) (int, string) {
+	if !v.timestampValid(timestamp) {
+		return 0, "时间戳已过期"
+	}
+	if v.matches(signature, timestamp, SignatureV2) {
+		return SignatureV2, ""
+	}
+	if version < SignatureV2 && v.matches(signature, timestamp, SignatureV1) {
+		return SignatureV1, ""
+	}
+	return 0, "签名验证失败"
+}
+
+// timestampValid 检查时间戳是否在容差范围内
+func (v *SignatureVerifier) timestampValid(timestamp int64) bool {
+	now := time.Now().Unix()
+	return timestamp >= now-v.timestampTolerance && timestamp <= now+v.timestampTolerance
+}
+
+// matches 使用恒定时间比较防止时序攻击
+func (v *SignatureVerifier) matches(signature string, timestamp int64, version int) bool {
+	expectedSig := v.GenerateSignatureVersion(timestamp, version)
+	return subtle.ConstantTimeCompare([]byte(signature), []byte(expectedSig)) == 1
+}
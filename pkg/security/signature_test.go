@@ -120,3 +120,48 @@ func TestSignatureVerifier_CustomTolerance(t *testing.T) {
 		t.Errorf("期望 '时间戳已过期' 错误，得到 %v", errMsg)
 	}
 }
+
+func TestSignatureVerifier_GenerateHMAC(t *testing.T) {
+	verifier := NewSignatureVerifier("testpassword")
+	// hex(HMAC-SHA256("testpassword", "1234567890"))
+	want := "320305207e98e92d13cb126b146ba24523c476bb944f2df6ad3ea44ef10f322a"
+	if got := verifier.GenerateHMAC(1234567890); got != want {
+		t.Errorf("GenerateHMAC() = %s, want %s", got, want)
+	}
+	if verifier.GenerateHMAC(1234567890) == verifier.GenerateSignature(1234567890) {
+		t.Error("HMAC 签名不应与 V1 签名相同")
+	}
+	if got := verifier.GenerateSignatureVersion(1234567890, SignatureV2); got != want {
+		t.Errorf("GenerateSignatureVersion(V2) = %s, want HMAC 签名", got)
+	}
+}
+
+func TestSignatureVerifier_VerifyVersioned(t *testing.T) {
+	verifier := NewSignatureVerifier("testpassword")
+	now := time.Now().Unix()
+	v1, v2 := verifier.GenerateSignature(now), verifier.GenerateHMAC(now)
+
+	tests := []struct {
+		name        string
+		signature   string
+		timestamp   int64
+		version     int
+		wantVersion int
+		wantErr     string
+	}{
+		{"V2 签名", v2, now, SignatureV2, SignatureV2, ""},
+		{"未声明版本的 HMAC 签名", v2, now, 0, SignatureV2, ""},
+		{"旧客户端的 V1 签名", v1, now, 0, SignatureV1, ""},
+		{"声明 V2 但使用 V1 签名", v1, now, SignatureV2, 0, "签名验证失败"},
+		{"错误签名", "invalid", now, 0, 0, "签名验证失败"},
+		{"过期时间戳", verifier.GenerateHMAC(now - 60), now - 60, SignatureV2, 0, "时间戳已过期"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, errMsg := verifier.VerifyVersioned(tt.signature, tt.timestamp, tt.version)
+			if version != tt.wantVersion || errMsg != tt.wantErr {
+				t.Errorf("VerifyVersioned() = %d, %q, want %d, %q", version, errMsg, tt.wantVersion, tt.wantErr)
+			}
+		})
+	}
+}
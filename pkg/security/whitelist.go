@@ -2,6 +2,7 @@ package security
 
 import (
 	"net"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -48,10 +49,23 @@ func (wl *IPWhitelist) parseWhitelist(whitelist string) {
 		}
 
 		// 单个IP地址
-		wl.ips[entry] = true
+		wl.ips[normalizeIP(entry)] = true
 	}
 }
 
+// normalizeIP 返回 IP 的规范形式，IPv4 映射的 IPv6 地址（如 ::ffff:192.168.1.1）转换为 IPv4
+// 无法解析时原样返回
+func normalizeIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.String()
+	}
+	return parsed.String()
+}
+
 // IsAllowed 检查IP是否在白名单中
 func (wl *IPWhitelist) IsAllowed(ip string) bool {
 	if !wl.enabled {
@@ -61,16 +75,19 @@ func (wl *IPWhitelist) IsAllowed(ip string) bool {
 	wl.mu.RLock()
 	defer wl.mu.RUnlock()
 
-	// 检查单个IP
-	if wl.ips[ip] {
+	// 检查单个IP（IPv4 映射的 IPv6 地址与对应的 IPv4 地址视为相同）
+	if wl.ips[normalizeIP(ip)] {
 		return true
 	}
 
-	// 检查CIDR网段
+	// 检查CIDR网段：IPv4 映射的 IPv6 地址按 IPv4 匹配，IPv4 网段写成 ::ffff:a.b.c.d/n 时同样适用
 	parsedIP := net.ParseIP(ip)
 	if parsedIP == nil {
 		return false
 	}
+	if v4 := parsedIP.To4(); v4 != nil {
+		parsedIP = v4
+	}
 
 	for _, ipNet := range wl.cidrs {
 		if ipNet.Contains(parsedIP) {
@@ -103,3 +120,28 @@ func (wl *IPWhitelist) IsEnabled() bool {
 	defer wl.mu.RUnlock()
 	return wl.enabled
 }
+
+// GetIPs 返回当前生效的单个 IP 列表快照（已排序）
+func (wl *IPWhitelist) GetIPs() []string {
+	wl.mu.RLock()
+	defer wl.mu.RUnlock()
+
+	ips := make([]string, 0, len(wl.ips))
+	for ip := range wl.ips {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	return ips
+}
+
+// GetCIDRs 返回当前生效的 CIDR 网段列表快照（按配置顺序）
+func (wl *IPWhitelist) GetCIDRs() []string {
+	wl.mu.RLock()
+	defer wl.mu.RUnlock()
+
+	cidrs := make([]string, 0, len(wl.cidrs))
+	for _, ipNet := range wl.cidrs {
+		cidrs = append(cidrs, ipNet.String())
+	}
+	return cidrs
+}
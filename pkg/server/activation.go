@@ -0,0 +1,118 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// systemd socket activation（sd_listen_fds 协议）：systemd 预先绑定端口，
+// 通过 LISTEN_PID / LISTEN_FDS / LISTEN_FDNAMES 环境变量把监听套接字从文件描述符 3 起依次交给服务进程
+
+// listenFDsStart systemd 传入的第一个文件描述符（SD_LISTEN_FDS_START）
+const listenFDsStart = 3
+
+// 按名称识别 HTTP 与 TLS 监听套接字（socket 单元的 FileDescriptorName=）
+const (
+	activationNameHTTP = "http"
+	activationNameTLS  = "https"
+)
+
+// activatedListener systemd 传入的监听套接字，name 取自 LISTEN_FDNAMES
+type activatedListener struct {
+	name string
+	ln   net.Listener
+}
+
+// activationEnv socket activation 读取的进程环境，测试中替换为伪造的环境
+type activationEnv struct {
+	getenv   func(string) string
+	unsetenv func(string) error
+	pid      int
+	file     func(fd uintptr, name string) *os.File
+}
+
+// processActivationEnv 当前进程的真实环境
+func processActivationEnv() activationEnv {
+	return activationEnv{getenv: os.Getenv, unsetenv: os.Unsetenv, pid: os.Getpid(), file: os.NewFile}
+}
+
+// listeners 返回 systemd 传入的监听套接字
+// LISTEN_PID 不是当前进程或 LISTEN_FDS 为空时表示未被 socket 激活，返回 nil；
+// 读取后清除这些环境变量，避免 reload 命令等子进程误用
+func (e activationEnv) listeners() ([]activatedListener, error) {
+	pid, err := strconv.Atoi(e.getenv("LISTEN_PID"))
+	if err != nil || pid != e.pid {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(e.getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(e.getenv("LISTEN_FDNAMES"), ":")
+	for _, key := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES"} {
+		e.unsetenv(key)
+	}
+
+	activated := make([]activatedListener, 0, n)
+	for i := 0; i < n; i++ {
+		fd := listenFDsStart + i
+		var name string
+		if i < len(names) {
+			name = names[i]
+		}
+		// net.FileListener 复制文件描述符，原描述符随即关闭
+		f := e.file(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			closeActivated(activated)
+			return nil, fmt.Errorf("systemd 传入的文件描述符 %d 不是监听套接字: %w", fd, err)
+		}
+		activated = append(activated, activatedListener{name: name, ln: ln})
+	}
+	return activated, nil
+}
+
+// closeActivated 关闭已创建的监听套接字
+func closeActivated(activated []activatedListener) {
+	for _, a := range activated {
+		a.ln.Close()
+	}
+}
+
+// selectListeners 从 systemd 传入的监听套接字中选出 HTTP 与 TLS 端口使用的套接字
+// 名为 http / https 的按名称分配，其余按传入顺序依次分配给尚未分配的 HTTP、TLS（启用 TLS 时）端口；
+// 没有分到套接字的端口仍按配置绑定，多余的套接字关闭并记录警告
+func selectListeners(activated []activatedListener, tlsEnabled bool) (httpLn, tlsLn net.Listener) {
+	var rest []activatedListener
+	for _, a := range activated {
+		switch {
+		case a.name == activationNameHTTP && httpLn == nil:
+			httpLn = a.ln
+		case a.name == activationNameTLS && tlsEnabled && tlsLn == nil:
+			tlsLn = a.ln
+		default:
+			rest = append(rest, a)
+		}
+	}
+
+	for _, a := range rest {
+		if a.name != activationNameHTTP && a.name != activationNameTLS {
+			if httpLn == nil {
+				httpLn = a.ln
+				continue
+			}
+			if tlsEnabled && tlsLn == nil {
+				tlsLn = a.ln
+				continue
+			}
+		}
+		slog.Warn("忽略多余的 systemd 监听套接字", "name", a.name, "addr", a.ln.Addr().String())
+		a.ln.Close()
+	}
+	return httpLn, tlsLn
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeActivationEnv 伪造 systemd 传入的环境：files 依次对应文件描述符 3、4、…
+func fakeActivationEnv(t *testing.T, vars map[string]string, files ...*os.File) activationEnv {
+	t.Helper()
+	return activationEnv{
+		getenv:   func(key string) string { return vars[key] },
+		unsetenv: func(key string) error { delete(vars, key); return nil },
+		pid:      1234,
+		file: func(fd uintptr, name string) *os.File {
+			i := int(fd) - listenFDsStart
+			if i < 0 || i >= len(files) {
+				t.Fatalf("读取了未传入的文件描述符 %d", fd)
+			}
+			return files[i]
+		},
+	}
+}
+
+// listenerFile 创建本地监听套接字并返回其文件与地址
+func listenerFile(t *testing.T) (*os.File, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	f, err := ln.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, ln.Addr().String()
+}
+
+func TestActivationEnv_Listeners(t *testing.T) {
+	t.Run("未激活", func(t *testing.T) {
+		for _, vars := range []map[string]string{
+			{},
+			{"LISTEN_PID": "999", "LISTEN_FDS": "1"}, // 传给其他进程的
+			{"LISTEN_PID": "1234", "LISTEN_FDS": "0"},
+		} {
+			got, err := fakeActivationEnv(t, vars).listeners()
+			if err != nil || got != nil {
+				t.Errorf("env %v: listeners() = %v, %v, want nil", vars, got, err)
+			}
+		}
+	})
+
+	t.Run("已激活", func(t *testing.T) {
+		httpFile, httpAddr := listenerFile(t)
+		tlsFile, tlsAddr := listenerFile(t)
+		vars := map[string]string{"LISTEN_PID": "1234", "LISTEN_FDS": "2", "LISTEN_FDNAMES": "http:https"}
+
+		got, err := fakeActivationEnv(t, vars, httpFile, tlsFile).listeners()
+		if err != nil {
+			t.Fatalf("listeners() error = %v", err)
+		}
+		defer closeActivated(got)
+		if len(got) != 2 {
+			t.Fatalf("listeners() 返回 %d 个套接字, want 2", len(got))
+		}
+		if got[0].name != "http" || got[0].ln.Addr().String() != httpAddr {
+			t.Errorf("got[0] = %s %s, want http %s", got[0].name, got[0].ln.Addr(), httpAddr)
+		}
+		if got[1].name != "https" || got[1].ln.Addr().String() != tlsAddr {
+			t.Errorf("got[1] = %s %s, want https %s", got[1].name, got[1].ln.Addr(), tlsAddr)
+		}
+		if len(vars) != 0 {
+			t.Errorf("读取后未清除环境变量: %v", vars)
+		}
+	})
+
+	t.Run("不是套接字", func(t *testing.T) {
+		f, err := os.Create(filepath.Join(t.TempDir(), "plain"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		vars := map[string]string{"LISTEN_PID": "1234", "LISTEN_FDS": "1"}
+		if _, err := fakeActivationEnv(t, vars, f).listeners(); err == nil {
+			t.Error("普通文件作为监听套接字时应返回错误")
+		}
+	})
+}
+
+// stubListener 只用于区分身份的监听套接字
+type stubListener struct {
+	net.Listener
+	closed bool
+}
+
+func (l *stubListener) Addr() net.Addr { return &net.TCPAddr{} }
+func (l *stubListener) Close() error   { l.closed = true; return nil }
+
+func TestSelectListeners(t *testing.T) {
+	tests := []struct {
+		name       string
+		names      []string
+		tlsEnabled bool
+		wantHTTP   int // 期望的 activated 下标，-1 表示按配置绑定
+		wantTLS    int
+		wantClosed []int
+	}{
+		{"未激活", nil, true, -1, -1, nil},
+		{"单个未命名套接字", []string{""}, false, 0, -1, nil},
+		{"按顺序分配", []string{"a.socket", "a.socket"}, true, 0, 1, nil},
+		{"按名称分配", []string{"https", "http"}, true, 1, 0, nil},
+		{"只传入 TLS", []string{"https"}, true, -1, 0, nil},
+		{"未启用 TLS 时忽略 https", []string{"http", "https"}, false, 0, -1, []int{1}},
+		{"多余的套接字", []string{"", "", ""}, true, 0, 1, []int{2}},
+		{"名称优先于顺序", []string{"", "http"}, true, 1, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var activated []activatedListener
+			for _, name := range tt.names {
+				activated = append(activated, activatedListener{name: name, ln: &stubListener{}})
+			}
+			pick := func(i int) net.Listener {
+				if i < 0 {
+					return nil
+				}
+				return activated[i].ln
+			}
+
+			httpLn, tlsLn := selectListeners(activated, tt.tlsEnabled)
+			if httpLn != pick(tt.wantHTTP) {
+				t.Errorf("HTTP 监听套接字 = %v, want 下标 %d", httpLn, tt.wantHTTP)
+			}
+			if tlsLn != pick(tt.wantTLS) {
+				t.Errorf("TLS 监听套接字 = %v, want 下标 %d", tlsLn, tt.wantTLS)
+			}
+			for _, i := range tt.wantClosed {
+				if !activated[i].ln.(*stubListener).closed {
+					t.Errorf("多余的套接字 %d 未关闭", i)
+				}
+			}
+		})
+	}
+}
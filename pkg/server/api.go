@@ -0,0 +1,264 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Catker/acmeDeliver/pkg/config"
+	"github.com/Catker/acmeDeliver/pkg/security"
+	"github.com/Catker/acmeDeliver/pkg/websocket"
+)
+
+// REST API 认证请求头
+// 签名算法与 WebSocket 认证一致：X-Acme-Signature-Version 为 2 时为 HMAC-SHA256(key, timestamp)，
+// 旧版本客户端不携带该请求头，签名为 sha256(key + timestamp)
+// 服务端在响应中以 X-Acme-Signature-Version 返回支持的最高签名版本
+const (
+	HeaderTimestamp        = "X-Acme-Timestamp"
+	HeaderSignature        = "X-Acme-Signature"
+	HeaderSignatureVersion = "X-Acme-Signature-Version"
+)
+
+// apiPrefix REST API 路径前缀
+const apiPrefix = "/api/v1/"
+
+// registerAPI 注册 REST API 路由
+// 修改证书、密钥或客户端状态的接口使用 admin_token 认证：所有客户端共享 key，不能用于管理操作
+// 所有 API 路由与 WebSocket 一样先经过 IP 白名单校验
+func (s *Server) registerAPI(mux *http.ServeMux) {
+	api := http.NewServeMux()
+	api.HandleFunc(apiPrefix+"security/whitelist", s.requireSignature(s.handleWhitelist))
+	api.HandleFunc(apiPrefix+"domains/", s.requireAdminToken(s.handleDomainAPI))
+	api.HandleFunc(apiPrefix+"admin/rotate-key", s.requireAdminToken(s.handleRotateKey))
+	api.HandleFunc(apiPrefix+"clients/", s.requireAdminToken(s.handleClientAPI))
+	mux.Handle(apiPrefix, s.requireWhitelist(api))
+}
+
+// requireWhitelist 校验请求方 IP 是否在白名单内，trust_proxy 的处理与 WebSocket 连接一致
+func (s *Server) requireWhitelist(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientIP := websocket.ClientIP(r, s.serveConfig().TrustProxy)
+		if !s.whitelist.IsAllowed(clientIP) {
+			slog.Warn("IP 白名单拒绝 REST API 请求", "path", r.URL.Path, "ip", clientIP)
+			writeJSONError(w, http.StatusForbidden, "IP 不在白名单内")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireSignature 校验请求头中的时间戳签名
+func (s *Server) requireSignature(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderSignatureVersion, strconv.Itoa(security.SignatureV2))
+		timestamp, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if err != nil {
+			writeJSONError(w, http.StatusUnauthorized, "缺少或无效的时间戳")
+			return
+		}
+
+		version, _ := strconv.Atoi(r.Header.Get(HeaderSignatureVersion))
+		version, errMsg := s.signatureVerifier().VerifyVersioned(r.Header.Get(HeaderSignature), timestamp, version)
+		if version == security.SignatureV1 {
+			if s.serveConfig().DisableLegacyAuth {
+				version, errMsg = 0, "服务端已禁用旧版签名（disable_legacy_auth），请升级客户端"
+			} else {
+				slog.Warn("REST API 请求使用已弃用的 sha256(key + timestamp) 签名，请升级客户端", "path", r.URL.Path, "remote", r.RemoteAddr)
+			}
+		}
+		if version == 0 {
+			slog.Warn("REST API 认证失败", "path", r.URL.Path, "remote", r.RemoteAddr, "reason", errMsg)
+			writeJSONError(w, http.StatusUnauthorized, errMsg)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// requireAdminToken 校验 Authorization: Bearer 请求头中的管理接口令牌
+// 管理接口与证书访问接口分开认证，未配置 admin_token 时拒绝所有请求
+func (s *Server) requireAdminToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := s.currentAdminToken()
+		if token == "" {
+			writeJSONError(w, http.StatusForbidden, "未配置 admin_token，管理接口已禁用")
+			return
+		}
+
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			slog.Warn("管理接口认证失败", "path", r.URL.Path, "remote", r.RemoteAddr)
+			writeJSONError(w, http.StatusUnauthorized, "无效的管理令牌")
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// DomainPushResponse 手动推送接口响应
+type DomainPushResponse struct {
+	Domain   string `json:"domain"`
+	ClientID string `json:"client_id,omitempty"` // 定向推送的客户端 ID（为空表示广播）
+	Sent     int    `json:"sent"`                // 成功入队的连接数
+}
+
+// handleDomainAPI 处理 /api/v1/domains/{domain} 与 /api/v1/domains/{domain}/... 请求
+func (s *Server) handleDomainAPI(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, apiPrefix+"domains/")
+	parts := strings.Split(rest, "/")
+
+	switch {
+	case len(parts) == 1 && parts[0] != "":
+		if r.Method != http.MethodDelete {
+			writeJSONError(w, http.StatusMethodNotAllowed, "仅支持 DELETE")
+			return
+		}
+		s.handleDomainDelete(w, parts[0])
+	case len(parts) == 2 && parts[1] == "push":
+		if r.Method != http.MethodPost {
+			writeJSONError(w, http.StatusMethodNotAllowed, "仅支持 POST")
+			return
+		}
+		s.handleDomainPush(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "upload":
+		if r.Method != http.MethodPost {
+			writeJSONError(w, http.StatusMethodNotAllowed, "仅支持 POST")
+			return
+		}
+		s.uploader.Upload(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "revoke":
+		if r.Method != http.MethodPost {
+			writeJSONError(w, http.StatusMethodNotAllowed, "仅支持 POST")
+			return
+		}
+		s.handleDomainRevoke(w, r, parts[0])
+	default:
+		writeJSONError(w, http.StatusNotFound, "未知的接口")
+	}
+}
+
+// handleDomainPush 立即推送指定域名的当前证书
+// 指定 client_id 查询参数时，以 admin_push 消息定向推送给该客户端；否则广播给所有订阅者
+func (s *Server) handleDomainPush(w http.ResponseWriter, r *http.Request, domain string) {
+	data, err := websocket.LoadCertPushData(config.CertDirs(s.config), domain)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "读取证书失败: "+err.Error())
+		return
+	}
+
+	resp := DomainPushResponse{Domain: domain}
+	if clientID := r.URL.Query().Get("client_id"); clientID != "" {
+		msg, err := websocket.NewMessage(websocket.MsgTypeAdminPush, data)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		resp.ClientID = clientID
+		resp.Sent = s.hub.SendToClient(clientID, msg)
+		if resp.Sent == 0 {
+			writeJSONError(w, http.StatusNotFound, "客户端不在线: "+clientID)
+			return
+		}
+	} else {
+		resp.Sent = s.hub.BroadcastCert(domain, data)
+		s.pushed.record(domain, data.Timestamp)
+	}
+
+	slog.Info("📤 手动推送证书", "domain", domain, "client_id", resp.ClientID, "sent", resp.Sent)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleDomainRevoke 通知订阅该域名的 daemon 下线域名
+// 查询参数 purge_deployed=true 时同时删除已部署文件，force=true 时没有站点配置的 daemon 也删除工作目录
+func (s *Server) handleDomainRevoke(w http.ResponseWriter, r *http.Request, domain string) {
+	if _, err := websocket.SafeDomainDir(config.CertDirs(s.config)[0], domain); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "无效的域名: "+err.Error())
+		return
+	}
+	query := r.URL.Query()
+	purge, _ := strconv.ParseBool(query.Get("purge_deployed"))
+	force, _ := strconv.ParseBool(query.Get("force"))
+
+	sent := s.hub.BroadcastRevoke(&websocket.CertRevokeData{Domain: domain, PurgeDeployed: purge, Force: force})
+	slog.Info("🗑️ 通知域名下线", "domain", domain, "purge_deployed", purge, "force", force, "sent", sent)
+	writeJSON(w, http.StatusOK, DomainPushResponse{Domain: domain, Sent: sent})
+}
+
+// defaultKickReason 未指定原因时关闭帧中的说明
+const defaultKickReason = "已被管理员断开"
+
+// ClientKickResponse 断开客户端接口响应
+type ClientKickResponse struct {
+	ClientID string `json:"client_id"`
+	Reason   string `json:"reason"`
+}
+
+// handleClientAPI 处理 /api/v1/clients/{id}/... 请求
+func (s *Server) handleClientAPI(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, apiPrefix+"clients/")
+	parts := strings.Split(rest, "/")
+
+	switch {
+	case len(parts) == 2 && parts[0] != "" && parts[1] == "kick":
+		if r.Method != http.MethodPost {
+			writeJSONError(w, http.StatusMethodNotAllowed, "仅支持 POST")
+			return
+		}
+		s.handleClientKick(w, r, parts[0])
+	default:
+		writeJSONError(w, http.StatusNotFound, "未知的接口")
+	}
+}
+
+// handleClientKick 强制断开指定 ID 的客户端，原因由 reason 查询参数指定
+func (s *Server) handleClientKick(w http.ResponseWriter, r *http.Request, clientID string) {
+	reason := r.URL.Query().Get("reason")
+	if reason == "" {
+		reason = defaultKickReason
+	}
+	if !s.hub.Kick(clientID, reason) {
+		writeJSONError(w, http.StatusNotFound, "客户端不在线: "+clientID)
+		return
+	}
+	writeJSON(w, http.StatusOK, ClientKickResponse{ClientID: clientID, Reason: reason})
+}
+
+// WhitelistResponse 白名单查询接口响应
+type WhitelistResponse struct {
+	Enabled bool     `json:"enabled"`
+	IPs     []string `json:"ips"`
+	CIDRs   []string `json:"cidrs"`
+}
+
+// handleWhitelist 返回内存中当前生效的 IP 白名单，用于确认热重载结果
+func (s *Server) handleWhitelist(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "仅支持 GET")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, WhitelistResponse{
+		Enabled: s.whitelist.IsEnabled(),
+		IPs:     s.whitelist.GetIPs(),
+		CIDRs:   s.whitelist.GetCIDRs(),
+	})
+}
+
+// writeJSON 输出 JSON 响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeJSONError 输出 JSON 错误响应
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,320 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/config"
+	"github.com/Catker/acmeDeliver/pkg/security"
+)
+
+// testAdminToken 测试服务器的管理接口令牌
+const testAdminToken = "admin-secret"
+
+// newTestAPIServer 创建仅挂载 REST API 的测试服务器，证书访问密钥为 test-key，管理接口令牌为 testAdminToken
+func newTestAPIServer(t *testing.T, baseDir string) (*Server, *httptest.Server) {
+	t.Helper()
+
+	srv, err := NewServer(&config.Config{BaseDir: baseDir, Key: "test-key", AdminToken: testAdminToken})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	t.Cleanup(func() { srv.watcher.Stop() })
+
+	mux := http.NewServeMux()
+	srv.registerAPI(mux)
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return srv, ts
+}
+
+// signedRequest 构造带签名头的请求
+func signedRequest(t *testing.T, method, url, key string) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, security.NewSignatureVerifier(key).GenerateSignature(timestamp))
+	return req
+}
+
+// adminRequest 构造携带管理令牌的请求，token 为空时不设置 Authorization
+func adminRequest(t *testing.T, method, url, token string) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+func TestAdminAPIRejectsClientSignature(t *testing.T) {
+	baseDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(baseDir, "example.com"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	_, ts := newTestAPIServer(t, baseDir)
+
+	// 所有客户端共享 key，有效的客户端签名不能用于修改证书、轮换密钥或断开客户端
+	endpoints := []struct{ method, path string }{
+		{http.MethodPost, "/api/v1/admin/rotate-key"},
+		{http.MethodDelete, "/api/v1/domains/example.com"},
+		{http.MethodPost, "/api/v1/domains/example.com/push"},
+		{http.MethodPost, "/api/v1/domains/example.com/revoke"},
+		{http.MethodPost, "/api/v1/domains/example.com/upload"},
+		{http.MethodPost, "/api/v1/clients/node-1/kick"},
+	}
+	for _, ep := range endpoints {
+		resp, err := http.DefaultClient.Do(signedRequest(t, ep.method, ts.URL+ep.path, "test-key"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s %s 使用客户端签名 status = %d, want %d", ep.method, ep.path, resp.StatusCode, http.StatusUnauthorized)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "example.com")); err != nil {
+		t.Errorf("域名目录不应被归档: %v", err)
+	}
+}
+
+func TestRequireSignatureVersions(t *testing.T) {
+	srv, ts := newTestAPIServer(t, t.TempDir())
+	url := ts.URL + "/api/v1/security/whitelist"
+
+	// hmacRequest 构造 HMAC 签名（V2）的请求
+	hmacRequest := func() *http.Request {
+		req := signedRequest(t, http.MethodGet, url, "test-key")
+		timestamp, _ := strconv.ParseInt(req.Header.Get(HeaderTimestamp), 10, 64)
+		req.Header.Set(HeaderSignature, security.NewSignatureVerifier("test-key").GenerateHMAC(timestamp))
+		req.Header.Set(HeaderSignatureVersion, "2")
+		return req
+	}
+	status := func(req *http.Request) int {
+		t.Helper()
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got := resp.Header.Get(HeaderSignatureVersion); got != "2" {
+			t.Errorf("响应头 %s = %q, want 2", HeaderSignatureVersion, got)
+		}
+		return resp.StatusCode
+	}
+
+	if got := status(hmacRequest()); got != http.StatusOK {
+		t.Errorf("HMAC 签名 status = %d, want %d", got, http.StatusOK)
+	}
+	if got := status(signedRequest(t, http.MethodGet, url, "test-key")); got != http.StatusOK {
+		t.Errorf("旧版签名 status = %d, want %d", got, http.StatusOK)
+	}
+
+	srv.mu.Lock()
+	srv.wsConfig.DisableLegacyAuth = true
+	srv.mu.Unlock()
+	if got := status(hmacRequest()); got != http.StatusOK {
+		t.Errorf("禁用旧版签名后 HMAC 签名 status = %d, want %d", got, http.StatusOK)
+	}
+	if got := status(signedRequest(t, http.MethodGet, url, "test-key")); got != http.StatusUnauthorized {
+		t.Errorf("禁用旧版签名后旧版签名 status = %d, want %d", got, http.StatusUnauthorized)
+	}
+}
+
+func TestDomainPushAPI(t *testing.T) {
+	baseDir := t.TempDir()
+	domainDir := filepath.Join(baseDir, "example.com")
+	if err := os.MkdirAll(domainDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(domainDir, "cert.pem"), []byte("cert"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, ts := newTestAPIServer(t, baseDir)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		wantStatus int
+	}{
+		{"错误令牌被拒绝", http.MethodPost, "/api/v1/domains/example.com/push", "wrong-token", http.StatusUnauthorized},
+		{"广播推送", http.MethodPost, "/api/v1/domains/example.com/push", testAdminToken, http.StatusOK},
+		{"不支持 GET", http.MethodGet, "/api/v1/domains/example.com/push", testAdminToken, http.StatusMethodNotAllowed},
+		{"域名不存在", http.MethodPost, "/api/v1/domains/missing.com/push", testAdminToken, http.StatusNotFound},
+		{"定向客户端不在线", http.MethodPost, "/api/v1/domains/example.com/push?client_id=node-1", testAdminToken, http.StatusNotFound},
+		{"通知下线", http.MethodPost, "/api/v1/domains/example.com/revoke?purge_deployed=true", testAdminToken, http.StatusOK},
+		{"下线不支持 GET", http.MethodGet, "/api/v1/domains/example.com/revoke", testAdminToken, http.StatusMethodNotAllowed},
+		{"下线非法域名", http.MethodPost, "/api/v1/domains/a..b/revoke", testAdminToken, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.DefaultClient.Do(adminRequest(t, tt.method, ts.URL+tt.path, tt.token))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestWhitelistAPI(t *testing.T) {
+	srv, ts := newTestAPIServer(t, t.TempDir())
+	srv.whitelist.Update("127.0.0.1,192.168.1.0/24")
+
+	resp, err := http.DefaultClient.Do(signedRequest(t, http.MethodGet, ts.URL+"/api/v1/security/whitelist", "wrong-key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("未签名请求 status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+
+	resp, err = http.DefaultClient.Do(signedRequest(t, http.MethodGet, ts.URL+"/api/v1/security/whitelist", "test-key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	var got WhitelistResponse
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := WhitelistResponse{Enabled: true, IPs: []string{"127.0.0.1"}, CIDRs: []string{"192.168.1.0/24"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("响应 = %+v, want %+v", got, want)
+	}
+}
+
+func TestAPIRejectsIPOutsideWhitelist(t *testing.T) {
+	srv, ts := newTestAPIServer(t, t.TempDir())
+	srv.whitelist.Update("10.0.0.1")
+	verifier := srv.signatureVerifier()
+
+	requests := []*http.Request{
+		signedRequest(t, http.MethodGet, ts.URL+"/api/v1/security/whitelist", "test-key"),
+		adminRequest(t, http.MethodPost, ts.URL+"/api/v1/admin/rotate-key", testAdminToken),
+		adminRequest(t, http.MethodGet, ts.URL+"/api/v1/unknown", testAdminToken),
+	}
+	for _, req := range requests {
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("%s %s status = %d, want %d", req.Method, req.URL.Path, resp.StatusCode, http.StatusForbidden)
+		}
+	}
+	if srv.signatureVerifier() != verifier {
+		t.Error("白名单外的请求不应轮换密钥")
+	}
+}
+
+func TestClientKickAPI(t *testing.T) {
+	srv, ts := newTestAPIServer(t, t.TempDir())
+
+	// kickRequest 发送携带管理令牌的断开请求
+	kickRequest := func(method, path, token string) int {
+		t.Helper()
+		resp, err := http.DefaultClient.Do(adminRequest(t, method, ts.URL+path, token))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// 未配置 admin_token 时管理接口禁用
+	srv.applyConfig(&config.Config{BaseDir: srv.config.BaseDir, Key: "test-key"})
+	if status := kickRequest(http.MethodPost, "/api/v1/clients/node-1/kick", "anything"); status != http.StatusForbidden {
+		t.Fatalf("未配置 admin_token 时 status = %d, want %d", status, http.StatusForbidden)
+	}
+
+	srv.applyConfig(&config.Config{BaseDir: srv.config.BaseDir, Key: "test-key", AdminToken: testAdminToken})
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		wantStatus int
+	}{
+		{"缺少令牌", http.MethodPost, "/api/v1/clients/node-1/kick", "", http.StatusUnauthorized},
+		{"错误令牌", http.MethodPost, "/api/v1/clients/node-1/kick", "wrong", http.StatusUnauthorized},
+		{"证书访问密钥不能用于管理接口", http.MethodPost, "/api/v1/clients/node-1/kick", "test-key", http.StatusUnauthorized},
+		{"客户端不在线", http.MethodPost, "/api/v1/clients/node-1/kick?reason=compromised", testAdminToken, http.StatusNotFound},
+		{"不支持 GET", http.MethodGet, "/api/v1/clients/node-1/kick", testAdminToken, http.StatusMethodNotAllowed},
+		{"未知的接口", http.MethodPost, "/api/v1/clients/node-1", testAdminToken, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := kickRequest(tt.method, tt.path, tt.token); status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestDomainDeleteAPI(t *testing.T) {
+	baseDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(baseDir, "example.com"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	_, ts := newTestAPIServer(t, baseDir)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{"不支持 GET", http.MethodGet, "/api/v1/domains/example.com", http.StatusMethodNotAllowed},
+		{"非法域名", http.MethodDelete, "/api/v1/domains/.archive", http.StatusBadRequest},
+		{"域名不存在", http.MethodDelete, "/api/v1/domains/missing.com", http.StatusNotFound},
+		{"归档域名目录", http.MethodDelete, "/api/v1/domains/example.com", http.StatusOK},
+		{"重复删除", http.MethodDelete, "/api/v1/domains/example.com", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		resp, err := http.DefaultClient.Do(adminRequest(t, tt.method, ts.URL+tt.path, testAdminToken))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, resp.StatusCode, tt.wantStatus)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(baseDir, "example.com")); !os.IsNotExist(err) {
+		t.Errorf("删除后域名目录仍存在: %v", err)
+	}
+	archived, _ := filepath.Glob(filepath.Join(baseDir, ".archive", "example.com.*"))
+	if len(archived) != 1 {
+		t.Errorf("归档目录 = %v, want 1 个", archived)
+	}
+}
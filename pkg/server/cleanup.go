@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/config"
+	"github.com/Catker/acmeDeliver/pkg/watcher"
+	"github.com/Catker/acmeDeliver/pkg/websocket"
+)
+
+// defaultCleanupArchiveDays 未配置 cleanup_archive_days 时归档域名目录的保留天数
+const defaultCleanupArchiveDays = 30
+
+// cleanupArchiveAge 返回归档域名目录的保留时间
+func cleanupArchiveAge(cfg *config.Config) time.Duration {
+	days := cfg.CleanupArchiveDays
+	if days <= 0 {
+		days = defaultCleanupArchiveDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// cleanupLoop 定时将各证书目录中不在 active_domains 内的域名目录归档，并删除超过保留期的归档域名目录
+func (s *Server) cleanupLoop(ctx context.Context, interval, maxAge time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	slog.Info("🧹 归档清理任务已启用", "interval", interval, "retention", maxAge)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.archiveOrphans()
+			s.purgeArchives(maxAge)
+		}
+	}
+}
+
+// archiveOrphans 将各证书目录中不在 active_domains 内的域名目录移动到归档目录，返回归档的数量
+// 未配置 active_domains 时不做任何处理，避免把所有域名当作孤儿归档
+func (s *Server) archiveOrphans() int {
+	active := s.currentActiveDomains()
+	if len(active) == 0 {
+		return 0
+	}
+
+	archived := 0
+	for _, dir := range config.CertDirs(s.config) {
+		domains, err := watcher.NewDirectoryCleaner(dir).CleanOrphans(active)
+		if err != nil {
+			slog.Warn("归档孤儿域名目录失败", "dir", dir, "error", err)
+		}
+		if len(domains) > 0 {
+			slog.Info("🗄️ 已归档不在 active_domains 中的域名目录", "dir", dir, "domains", domains)
+		}
+		archived += len(domains)
+	}
+	return archived
+}
+
+// purgeArchives 删除各证书目录中超过 maxAge 的归档域名目录，返回删除的数量
+func (s *Server) purgeArchives(maxAge time.Duration) int {
+	purged := 0
+	for _, dir := range config.CertDirs(s.config) {
+		n, err := watcher.NewDirectoryCleaner(dir).PurgeArchive(maxAge)
+		if err != nil {
+			slog.Warn("清理过期归档失败", "dir", dir, "error", err)
+		}
+		purged += n
+	}
+	return purged
+}
+
+// DomainArchiveResponse 删除域名接口响应
+type DomainArchiveResponse struct {
+	Domain   string   `json:"domain"`
+	Archived []string `json:"archived"` // 归档后的目录路径
+}
+
+// handleDomainDelete 将域名目录从所有证书目录移动到各自的归档目录（.archive/<domain>.<时间戳>/）
+// 不通知客户端；需要客户端同时下线时另行调用 revoke 接口
+func (s *Server) handleDomainDelete(w http.ResponseWriter, domain string) {
+	dirs := config.CertDirs(s.config)
+	if _, err := websocket.SafeDomainDir(dirs[0], domain); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "无效的域名: "+err.Error())
+		return
+	}
+	// 隐藏目录（如归档目录本身）不是域名
+	if strings.HasPrefix(domain, ".") {
+		writeJSONError(w, http.StatusBadRequest, "无效的域名: "+domain)
+		return
+	}
+
+	resp := DomainArchiveResponse{Domain: domain}
+	for _, dir := range dirs {
+		path, err := watcher.NewDirectoryCleaner(dir).Archive(domain)
+		if errors.Is(err, watcher.ErrDomainNotFound) {
+			continue
+		}
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "归档域名目录失败: "+err.Error())
+			return
+		}
+		resp.Archived = append(resp.Archived, path)
+	}
+	if len(resp.Archived) == 0 {
+		writeJSONError(w, http.StatusNotFound, "域名不存在: "+domain)
+		return
+	}
+
+	slog.Info("🗄️ 域名目录已归档", "domain", domain, "archived", resp.Archived)
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/config"
+)
+
+func TestCleanupArchiveAge(t *testing.T) {
+	if got := cleanupArchiveAge(&config.Config{}); got != 30*24*time.Hour {
+		t.Errorf("默认保留时间 = %v, want 720h", got)
+	}
+	if got := cleanupArchiveAge(&config.Config{CleanupArchiveDays: 7}); got != 7*24*time.Hour {
+		t.Errorf("cleanup_archive_days=7 保留时间 = %v, want 168h", got)
+	}
+}
+
+func TestArchiveOrphans(t *testing.T) {
+	dirs := []string{t.TempDir(), t.TempDir()}
+	for _, dir := range dirs {
+		os.MkdirAll(filepath.Join(dir, "example.com"), 0755)
+		os.MkdirAll(filepath.Join(dir, "old.com"), 0755)
+	}
+
+	// 未配置 active_domains 时不归档
+	s := &Server{config: &config.Config{BaseDirs: dirs}}
+	if got := s.archiveOrphans(); got != 0 {
+		t.Errorf("未配置 active_domains 时 archiveOrphans() = %d, want 0", got)
+	}
+
+	s.activeDomains = []string{"example.com"}
+	if got := s.archiveOrphans(); got != 2 {
+		t.Errorf("archiveOrphans() = %d, want 2", got)
+	}
+	for _, dir := range dirs {
+		if _, err := os.Stat(filepath.Join(dir, "example.com")); err != nil {
+			t.Errorf("仍在使用的域名被归档: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "old.com")); !os.IsNotExist(err) {
+			t.Errorf("old.com 未被归档: %v", err)
+		}
+		if matches, _ := filepath.Glob(filepath.Join(dir, ".archive", "old.com.*")); len(matches) != 1 {
+			t.Errorf("归档目录 = %v, want 1 个 old.com 归档", matches)
+		}
+	}
+}
+
+func TestPurgeArchives(t *testing.T) {
+	dirs := []string{t.TempDir(), t.TempDir()}
+	old := "old.com." + time.Now().Add(-48*time.Hour).UTC().Format("20060102T150405Z")
+	recent := "new.com." + time.Now().UTC().Format("20060102T150405Z")
+	for _, dir := range dirs {
+		os.MkdirAll(filepath.Join(dir, ".archive", old), 0755)
+		os.MkdirAll(filepath.Join(dir, ".archive", recent), 0755)
+	}
+
+	s := &Server{config: &config.Config{BaseDirs: dirs}}
+	if got := s.purgeArchives(24 * time.Hour); got != 2 {
+		t.Errorf("purgeArchives() = %d, want 2", got)
+	}
+	for _, dir := range dirs {
+		if _, err := os.Stat(filepath.Join(dir, ".archive", recent)); err != nil {
+			t.Errorf("未过期的归档被删除: %v", err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/Catker/acmeDeliver/pkg/config"
+	"github.com/Catker/acmeDeliver/pkg/ringlog"
+)
+
+// 健康状态
+const (
+	healthOK        = "ok"
+	healthUnhealthy = "unhealthy"
+)
+
+// HealthResponse /healthz 响应
+// 不健康时附带最近的日志（已脱敏），便于无需登录服务器即可定位问题
+type HealthResponse struct {
+	Status     string            `json:"status"`
+	Checks     map[string]string `json:"checks"`
+	RecentLogs []ringlog.Record  `json:"recent_logs,omitempty"`
+}
+
+// SetLogRing 设置保存最近日志的环形缓冲区，/healthz 不健康时返回其中的记录
+// 须在 Run 之前调用
+func (s *Server) SetLogRing(ring *ringlog.RingHandler) {
+	s.logRing = ring
+}
+
+// handleHealthz 检查证书目录可读且服务未处于关闭流程，健康返回 200，否则返回 503
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	resp := HealthResponse{Status: healthOK, Checks: s.healthChecks()}
+	for _, result := range resp.Checks {
+		if result != healthOK {
+			resp.Status = healthUnhealthy
+		}
+	}
+	if resp.Status == healthOK {
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	// 日志可能包含域名与客户端地址，启用白名单时仅返回给白名单内的请求方
+	if s.logRing != nil && s.whitelist.IsAllowed(remoteHost(r)) {
+		resp.RecentLogs = s.logRing.Records()
+	}
+	writeJSON(w, http.StatusServiceUnavailable, resp)
+}
+
+// healthChecks 执行各项检查，返回 检查项 -> "ok" 或失败原因
+func (s *Server) healthChecks() map[string]string {
+	checks := map[string]string{
+		"base_dir": healthOK,
+		"shutdown": healthOK,
+	}
+	for _, dir := range config.CertDirs(s.config) {
+		if _, err := os.ReadDir(dir); err != nil {
+			checks["base_dir"] = err.Error()
+			break
+		}
+	}
+	if s.shuttingDown.Load() {
+		checks["shutdown"] = "服务正在关闭"
+	}
+	return checks
+}
+
+// remoteHost 返回请求的来源 IP（不含端口）
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
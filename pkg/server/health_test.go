@@ -0,0 +1,92 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Catker/acmeDeliver/pkg/config"
+	"github.com/Catker/acmeDeliver/pkg/ringlog"
+)
+
+// getHealthz 请求 /healthz 并解析响应
+func getHealthz(t *testing.T, srv *Server, remoteAddr string) (int, HealthResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.RemoteAddr = remoteAddr
+	rec := httptest.NewRecorder()
+	srv.handleHealthz(rec, req)
+
+	var resp HealthResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("解析响应失败: %v, body = %s", err, rec.Body.String())
+	}
+	return rec.Code, resp
+}
+
+func TestHealthz(t *testing.T) {
+	baseDir := t.TempDir()
+	srv, err := NewServer(&config.Config{BaseDir: baseDir, Key: "test-key"})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	t.Cleanup(func() { srv.watcher.Stop() })
+
+	ring := ringlog.NewRingHandler(ringlog.DefaultCapacity, nil)
+	srv.SetLogRing(ring)
+	logger := slog.New(ring)
+	logger.Error("读取证书失败", "domain", "example.com", "password", "hunter2")
+
+	// 健康时不返回日志
+	code, resp := getHealthz(t, srv, "127.0.0.1:1234")
+	if code != http.StatusOK || resp.Status != healthOK || len(resp.RecentLogs) != 0 {
+		t.Fatalf("健康时 = %d %+v", code, resp)
+	}
+
+	// 证书目录不可读时返回 503 并附带脱敏后的最近日志
+	srv.config.BaseDir = filepath.Join(baseDir, "missing")
+	code, resp = getHealthz(t, srv, "127.0.0.1:1234")
+	if code != http.StatusServiceUnavailable || resp.Status != healthUnhealthy || resp.Checks["base_dir"] == healthOK {
+		t.Fatalf("目录缺失时 = %d %+v", code, resp)
+	}
+	if len(resp.RecentLogs) != 1 || resp.RecentLogs[0].Message != "读取证书失败" {
+		t.Fatalf("RecentLogs = %+v", resp.RecentLogs)
+	}
+	if got := resp.RecentLogs[0].Attrs["password"]; got == "hunter2" {
+		t.Error("日志中的密码未脱敏")
+	}
+
+	// 启用白名单时，白名单外的请求方看不到日志
+	srv.whitelist.Update("10.0.0.1")
+	if _, resp = getHealthz(t, srv, "192.168.1.1:1234"); len(resp.RecentLogs) != 0 {
+		t.Errorf("白名单外请求不应返回日志: %+v", resp.RecentLogs)
+	}
+	if _, resp = getHealthz(t, srv, "10.0.0.1:1234"); len(resp.RecentLogs) != 1 {
+		t.Errorf("白名单内请求应返回日志: %+v", resp.RecentLogs)
+	}
+}
+
+func TestHealthzShuttingDown(t *testing.T) {
+	baseDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(baseDir, "example.com"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	srv, err := NewServer(&config.Config{BaseDir: baseDir, Key: "test-key"})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	t.Cleanup(func() { srv.watcher.Stop() })
+
+	srv.shuttingDown.Store(true)
+	code, resp := getHealthz(t, srv, "127.0.0.1:1234")
+	if code != http.StatusServiceUnavailable || resp.Checks["shutdown"] == healthOK {
+		t.Errorf("关闭中 = %d %+v", code, resp)
+	}
+	if resp.RecentLogs != nil {
+		t.Errorf("未设置 ring 时不应返回日志: %+v", resp.RecentLogs)
+	}
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/Catker/acmeDeliver/pkg/websocket"
+)
+
+// Redis 多实例协调
+// 多个服务端部署在负载均衡后时，证书推送经 Redis 发布到 acmedeliver:cert:push:<domain>，
+// 各实例订阅 acmedeliver:cert:push:* 并转发给本实例的客户端；消息携带实例 ID，忽略自己发布的消息
+
+const (
+	redisChannelPrefix = "acmedeliver:cert:push:"
+	redisTimeout       = 5 * time.Second // 连接与发布的超时
+	redisRetryInterval = 5 * time.Second // 订阅中断后的重连间隔
+)
+
+// redisCertMessage 经 Redis 转发的证书推送
+type redisCertMessage struct {
+	Instance string                  `json:"instance"` // 发布者的实例 ID
+	Data     *websocket.CertPushData `json:"data"`
+}
+
+// redisBroker 经 Redis 发布/订阅在多个服务端实例间转发证书推送，实现 websocket.CertPublisher
+type redisBroker struct {
+	client        *redis.Client
+	addr          string // Redis 地址，用于日志
+	instanceID    string
+	deliver       func(domain string, data *websocket.CertPushData) // 处理来自其他实例的推送
+	retryInterval time.Duration
+}
+
+// newRedisBroker 创建 Redis 转发器，instanceID 用于识别并忽略本实例发布的消息
+// redis_url 支持 redis://[user:password@]host[:port] 与 rediss://（TLS），发布/订阅与数据库编号无关
+func newRedisBroker(rawURL, instanceID string, deliver func(domain string, data *websocket.CertPushData)) (*redisBroker, error) {
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("无效的 redis_url: %w", err)
+	}
+	opts.DialTimeout = redisTimeout
+	return &redisBroker{
+		client:        redis.NewClient(opts),
+		addr:          opts.Addr,
+		instanceID:    instanceID,
+		deliver:       deliver,
+		retryInterval: redisRetryInterval,
+	}, nil
+}
+
+// PublishCert 将证书推送发布到 acmedeliver:cert:push:<domain>
+// 由 Hub 在本实例推送后异步调用，失败时只记录错误（本实例的推送不受影响）
+func (b *redisBroker) PublishCert(domain string, data *websocket.CertPushData) {
+	payload, err := json.Marshal(redisCertMessage{Instance: b.instanceID, Data: data})
+	if err != nil {
+		slog.Error("序列化 Redis 推送消息失败", "domain", domain, "error", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	receivers, err := b.client.Publish(ctx, redisChannelPrefix+domain, payload).Result()
+	if err != nil {
+		slog.Error("发布证书推送到 Redis 失败，其他实例的客户端不会收到此次推送",
+			"domain", domain, "redis", b.addr, "error", err)
+		return
+	}
+	slog.Debug("证书推送已发布到 Redis", "domain", domain, "receivers", receivers)
+}
+
+// Run 订阅所有域名的推送并转发来自其他实例的消息，连接中断后自动重连，直到 ctx 取消
+func (b *redisBroker) Run(ctx context.Context) {
+	for {
+		err := b.subscribe(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Warn("Redis 订阅中断，稍后重连", "redis", b.addr, "error", err, "retry_in", b.retryInterval)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(b.retryInterval):
+		}
+	}
+}
+
+// subscribe 建立订阅并持续读取消息，返回导致中断的错误
+func (b *redisBroker) subscribe(ctx context.Context) error {
+	pubsub := b.client.PSubscribe(ctx, redisChannelPrefix+"*")
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		// 读取消息时不响应 ctx 取消，关闭订阅以结束阻塞的读取
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		pubsub.Close()
+	}()
+
+	// 第一条回复为订阅确认，连接或认证失败时在此返回
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return err
+	}
+	slog.Info("🔗 已订阅 Redis 证书推送", "redis", b.addr, "instance", b.instanceID)
+
+	for {
+		msg, err := pubsub.ReceiveMessage(ctx)
+		if err != nil {
+			return err
+		}
+		b.handleMessage(msg.Channel, msg.Payload)
+	}
+}
+
+// handleMessage 转发其他实例发布的证书推送，忽略本实例发布的消息
+func (b *redisBroker) handleMessage(channel, payload string) {
+	domain := strings.TrimPrefix(channel, redisChannelPrefix)
+	var msg redisCertMessage
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil || msg.Data == nil {
+		slog.Warn("忽略无效的 Redis 推送消息", "channel", channel, "error", err)
+		return
+	}
+	if msg.Instance == b.instanceID {
+		return
+	}
+	slog.Debug("收到其他实例的证书推送", "domain", domain, "instance", msg.Instance)
+	b.deliver(domain, msg.Data)
+}
+
+// Close 关闭 Redis 连接池（订阅随 Run 的 ctx 取消而关闭）
+func (b *redisBroker) Close() error {
+	return b.client.Close()
+}
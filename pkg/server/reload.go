@@ -0,0 +1,93 @@
+package server
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/cert"
+	"github.com/Catker/acmeDeliver/pkg/config"
+	"github.com/Catker/acmeDeliver/pkg/security"
+	"github.com/Catker/acmeDeliver/pkg/websocket"
+)
+
+// defaultWatchDebounce 证书目录监控的默认防抖时间
+const defaultWatchDebounce = 5 * time.Second
+
+// watchDebounce 返回配置的监控防抖时间
+func watchDebounce(cfg *config.Config) time.Duration {
+	if cfg.WatchDebounce <= 0 {
+		return defaultWatchDebounce
+	}
+	return time.Duration(cfg.WatchDebounce) * time.Second
+}
+
+// applyConfig 将可热重载的配置应用到各组件
+// 新密钥与 WebSocket 参数仅对之后建立的连接和请求生效，已认证的连接不受影响
+func (s *Server) applyConfig(cfg *config.Config) {
+	s.whitelist.Update(cfg.IPWhitelist)
+
+	if policy, err := websocket.ParseDuplicatePolicy(cfg.DuplicatePolicy); err != nil {
+		slog.Warn("重复客户端策略无效，保持原策略", "error", err)
+	} else {
+		s.hub.SetDuplicatePolicy(policy)
+	}
+	s.hub.SetPushRate(cfg.PushBytesPerSec)
+	s.hub.SetAllowWildcardSubscribe(cfg.AllowWildcardSubscribe)
+
+	s.watcher.SetDebounce(watchDebounce(cfg))
+	s.uploader.SetMaxSize(int64(cfg.MaxCertSizeBytes))
+	s.uploader.SetSyncOnWrite(cfg.SyncOnWrite)
+	cert.SetCRLCacheTTL(time.Duration(cfg.CRLCacheTTL) * time.Second)
+	if err := cfg.CertFileNames.Validate(); err != nil {
+		slog.Warn("证书文件名配置无效，保持原配置", "error", err)
+	} else {
+		cert.SetFileNames(cfg.CertFileNames)
+	}
+
+	s.mu.Lock()
+	s.verifier = security.NewSignatureVerifier(cfg.Key)
+	s.wsConfig = &websocket.ServeConfig{
+		Password:         cfg.Key,
+		BaseDirs:         config.CertDirs(s.config), // 证书目录需重启生效
+		Whitelist:        s.whitelist,
+		TrustProxy:       cfg.TrustProxy,
+		Compression:      cfg.WSCompression,
+		CompressionLevel: cfg.WSCompressionLevel,
+		PongTimeout:      time.Duration(cfg.PongTimeout) * time.Second,
+		MaxMessageSize:   int64(cfg.MaxMessageSize),
+
+		DisableLegacyAuth: cfg.DisableLegacyAuth,
+	}
+	s.rotationWindow = keyRotationWindow(cfg)
+	s.adminToken = cfg.AdminToken
+	s.activeDomains = cfg.ActiveDomains
+	s.mu.Unlock()
+}
+
+// serveConfig 返回当前的 WebSocket 连接参数
+func (s *Server) serveConfig() *websocket.ServeConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.wsConfig
+}
+
+// currentAdminToken 返回当前的管理接口令牌
+func (s *Server) currentAdminToken() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.adminToken
+}
+
+// currentActiveDomains 返回当前配置的仍在使用的域名
+func (s *Server) currentActiveDomains() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.activeDomains
+}
+
+// signatureVerifier 返回当前密钥对应的签名校验器
+func (s *Server) signatureVerifier() *security.SignatureVerifier {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.verifier
+}
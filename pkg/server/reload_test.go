@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/config"
+)
+
+func TestApplyConfigHotReload(t *testing.T) {
+	srv, ts := newTestAPIServer(t, t.TempDir())
+
+	doRequest := func(key string) int {
+		resp, err := http.DefaultClient.Do(signedRequest(t, http.MethodGet, ts.URL+"/api/v1/security/whitelist", key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if got := doRequest("test-key"); got != http.StatusOK {
+		t.Fatalf("旧密钥 status = %d, want 200", got)
+	}
+
+	srv.applyConfig(&config.Config{
+		BaseDir:       "/ignored",
+		Key:           "rotated-key",
+		IPWhitelist:   "127.0.0.1,10.0.0.1",
+		TrustProxy:    true,
+		PongTimeout:   30,
+		WatchDebounce: 2,
+	})
+
+	if got := doRequest("test-key"); got != http.StatusUnauthorized {
+		t.Errorf("重载后旧密钥 status = %d, want 401", got)
+	}
+	if got := doRequest("rotated-key"); got != http.StatusOK {
+		t.Errorf("重载后新密钥 status = %d, want 200", got)
+	}
+
+	wsCfg := srv.serveConfig()
+	if wsCfg.Password != "rotated-key" || !wsCfg.TrustProxy || wsCfg.PongTimeout != 30*time.Second {
+		t.Errorf("serveConfig() = %+v, 未应用新配置", wsCfg)
+	}
+	if len(wsCfg.BaseDirs) != 1 || wsCfg.BaseDirs[0] != srv.config.BaseDir {
+		t.Errorf("BaseDirs = %q, 需重启的字段不应热更新", wsCfg.BaseDirs)
+	}
+	if !srv.whitelist.IsAllowed("10.0.0.1") || srv.whitelist.IsAllowed("10.0.0.2") {
+		t.Error("IP 白名单未更新")
+	}
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/config"
+	"github.com/Catker/acmeDeliver/pkg/security"
+	"github.com/Catker/acmeDeliver/pkg/websocket"
+)
+
+// defaultKeyRotationWindow 等待客户端用新密钥重新认证的默认时间
+const defaultKeyRotationWindow = 60 * time.Second
+
+// keyRotationWindow 返回配置的密钥轮换等待时间
+func keyRotationWindow(cfg *config.Config) time.Duration {
+	if cfg.KeyRotationWindow <= 0 {
+		return defaultKeyRotationWindow
+	}
+	return time.Duration(cfg.KeyRotationWindow) * time.Second
+}
+
+// RotateKeyRequest 密钥轮换请求（请求体可省略）
+type RotateKeyRequest struct {
+	Key    string `json:"key,omitempty"`    // 新密钥，留空时自动生成
+	Window int    `json:"window,omitempty"` // 等待重新认证的秒数，覆盖 key_rotation_window
+}
+
+// RotateKeyResponse 密钥轮换结果
+type RotateKeyResponse struct {
+	Key             string   `json:"key"`               // 已生效的新密钥
+	Notified        int      `json:"notified"`          // 收到轮换消息的连接数
+	Reauthenticated []string `json:"reauthenticated"`   // 已用新密钥重新认证的客户端
+	Missing         []string `json:"missing"`           // 未在窗口内重新认证的客户端，需手动更新密码
+	Persisted       bool     `json:"persisted"`         // 新密钥是否已写回配置文件
+	Warning         string   `json:"warning,omitempty"` // 写回配置文件失败等提示
+}
+
+// handleRotateKey 轮换认证密钥
+// 先向在线 daemon 下发用旧密钥加密的新密钥，等待其重新认证（最长 key_rotation_window），再提交新密钥
+// 一旦开始下发，无论请求方是否断开都会提交，避免已切换密钥的 daemon 与服务端不一致
+func (s *Server) handleRotateKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "仅支持 POST")
+		return
+	}
+
+	var req RotateKeyRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeJSONError(w, http.StatusBadRequest, "无效的请求体: "+err.Error())
+		return
+	}
+
+	oldKey := s.serveConfig().Password
+	newKey := req.Key
+	if newKey == "" {
+		newKey = config.GenerateSecureKey()
+	}
+	if newKey == oldKey {
+		writeJSONError(w, http.StatusBadRequest, "新密钥不能与当前密钥相同")
+		return
+	}
+
+	window := s.keyRotationWindow()
+	if req.Window > 0 {
+		window = time.Duration(req.Window) * time.Second
+	}
+
+	notified, err := s.hub.BeginKeyRotation(oldKey, newKey)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, websocket.ErrKeyRotationInProgress) {
+			status = http.StatusConflict
+		}
+		writeJSONError(w, status, err.Error())
+		return
+	}
+	result := s.hub.WaitKeyRotation(window)
+
+	// 先提交新密钥再结束轮换，保证任何时刻新密钥都可用于认证
+	resp := RotateKeyResponse{
+		Key:             newKey,
+		Notified:        notified,
+		Reauthenticated: result.Reauthenticated,
+		Missing:         result.Missing,
+	}
+	resp.Persisted, err = s.commitKey(newKey)
+	s.hub.EndKeyRotation()
+	if err != nil {
+		resp.Warning = "新密钥未写回配置文件: " + err.Error()
+		slog.Error("写回新密钥失败", "error", err)
+	}
+
+	slog.Info("🔑 密钥轮换完成",
+		"notified", notified,
+		"reauthenticated", len(resp.Reauthenticated),
+		"missing", resp.Missing,
+		"persisted", resp.Persisted)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// commitKey 使新密钥对新连接和 REST API 生效，并尝试写回配置文件
+func (s *Server) commitKey(newKey string) (bool, error) {
+	s.mu.Lock()
+	wsConfig := *s.wsConfig
+	wsConfig.Password = newKey
+	s.wsConfig = &wsConfig
+	s.verifier = security.NewSignatureVerifier(newKey)
+	s.mu.Unlock()
+
+	return config.UpdateKey(newKey)
+}
+
+// keyRotationWindow 返回当前的密钥轮换等待时间
+func (s *Server) keyRotationWindow() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rotationWindow
+}
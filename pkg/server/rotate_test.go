@@ -0,0 +1,80 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRotateKeyAPI(t *testing.T) {
+	srv, ts := newTestAPIServer(t, t.TempDir())
+
+	req := adminRequest(t, http.MethodPost, ts.URL+"/api/v1/admin/rotate-key", testAdminToken)
+	req.Body = io.NopCloser(strings.NewReader(`{"key":"rotated-key","window":1}`))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	var got RotateKeyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Key != "rotated-key" || got.Notified != 0 || len(got.Missing) != 0 {
+		t.Errorf("响应 = %+v", got)
+	}
+	if got.Persisted {
+		t.Error("未加载配置文件时不应写回")
+	}
+
+	// 新密钥对 REST API 和新连接生效，旧密钥失效
+	if srv.serveConfig().Password != "rotated-key" {
+		t.Errorf("WebSocket 密钥 = %q, want rotated-key", srv.serveConfig().Password)
+	}
+	for key, want := range map[string]int{"test-key": http.StatusUnauthorized, "rotated-key": http.StatusOK} {
+		resp, err := http.DefaultClient.Do(signedRequest(t, http.MethodGet, ts.URL+"/api/v1/security/whitelist", key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("key=%s status = %d, want %d", key, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestRotateKeyAPIRejectsInvalid(t *testing.T) {
+	_, ts := newTestAPIServer(t, t.TempDir())
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		want   int
+	}{
+		{"非 POST", http.MethodGet, "", http.StatusMethodNotAllowed},
+		{"与当前密钥相同", http.MethodPost, `{"key":"test-key"}`, http.StatusBadRequest},
+		{"无效请求体", http.MethodPost, `{`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := adminRequest(t, tt.method, ts.URL+"/api/v1/admin/rotate-key", testAdminToken)
+			req.Body = io.NopCloser(strings.NewReader(tt.body))
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
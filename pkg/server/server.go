@@ -6,15 +6,18 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
-	"strconv"
-	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Catker/acmeDeliver/pkg/cert"
 	"github.com/Catker/acmeDeliver/pkg/config"
 	"github.com/Catker/acmeDeliver/pkg/handler"
+	"github.com/Catker/acmeDeliver/pkg/ringlog"
 	"github.com/Catker/acmeDeliver/pkg/security"
 	"github.com/Catker/acmeDeliver/pkg/watcher"
 	"github.com/Catker/acmeDeliver/pkg/websocket"
+	"github.com/google/uuid"
 )
 
 // Server 服务器实例，封装所有依赖
@@ -24,12 +27,55 @@ type Server struct {
 	config    *config.Config
 	whitelist *security.IPWhitelist
 	watcher   *watcher.CertWatcher
+	pushed    *pushTracker // 各域名最近一次推送的证书时间戳
+	uploader  *CertUploadHandler
+	logRing   *ringlog.RingHandler // 最近日志，/healthz 不健康时返回（可选）
+	broker    *redisBroker         // 多实例协调，未配置 redis_url 时为 nil
+
+	shuttingDown atomic.Bool // 已进入关闭流程，/healthz 返回不健康
+
+	// 可热重载的认证与连接参数
+	mu             sync.RWMutex
+	verifier       *security.SignatureVerifier
+	wsConfig       *websocket.ServeConfig
+	rotationWindow time.Duration // 密钥轮换等待重新认证的时间
+	adminToken     string        // 管理接口令牌，为空时禁用管理接口
+	activeDomains  []string      // 仍在使用的域名，清理任务归档其他域名目录，为空时不归档
+}
+
+// newMux 注册首页、健康检查、WebSocket 端点（ws_path，默认 /ws）与 REST 管理接口路由
+func (s *Server) newMux(cfg *config.Config) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", handler.HandleHome)
+	mux.HandleFunc("/healthz", s.handleHealthz)
+
+	// WebSocket 端点
+	mux.HandleFunc(config.NormalizeWSPath(cfg.WSPath), func(w http.ResponseWriter, r *http.Request) {
+		// 每次连接读取最新参数以支持热重载
+		websocket.ServeWs(s.hub, s.serveConfig(), w, r)
+	})
+
+	// REST 管理接口
+	s.registerAPI(mux)
+	return mux
 }
 
 // NewServer 创建服务器实例
 func NewServer(cfg *config.Config) (*Server, error) {
 	// 初始化 WebSocket Hub
+	duplicatePolicy, err := websocket.ParseDuplicatePolicy(cfg.DuplicatePolicy)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.ValidateWSPath(cfg.WSPath); err != nil {
+		return nil, err
+	}
+	if err := cfg.CertFileNames.Validate(); err != nil {
+		return nil, err
+	}
 	hub := websocket.NewHub()
+	hub.SetDuplicatePolicy(duplicatePolicy)
+	hub.SetPushRate(cfg.PushBytesPerSec)
 	go hub.Run()
 	slog.Info("📡 WebSocket Hub 已启动")
 
@@ -40,7 +86,7 @@ func NewServer(cfg *config.Config) (*Server, error) {
 	}
 
 	// 初始化证书目录监控
-	certWatcher, err := watcher.NewCertWatcher(cfg.BaseDir, 5*time.Second)
+	certWatcher, err := watcher.NewCertWatcher(config.CertDirs(cfg), watchDebounce(cfg))
 	if err != nil {
 		return nil, err
 	}
@@ -50,18 +96,39 @@ func NewServer(cfg *config.Config) (*Server, error) {
 		config:    cfg,
 		whitelist: whitelist,
 		watcher:   certWatcher,
+		pushed:    newPushTracker(),
+		uploader:  NewCertUploadHandler(config.CertDirs(cfg), int64(cfg.MaxCertSizeBytes)),
+	}
+	srv.applyConfig(cfg)
+
+	// 多实例协调：经 Redis 转发证书推送
+	if cfg.RedisURL != "" {
+		broker, err := newRedisBroker(cfg.RedisURL, uuid.NewString(), srv.deliverRemoteCert)
+		if err != nil {
+			return nil, err
+		}
+		srv.broker = broker
+		hub.SetCertPublisher(broker)
+		slog.Info("🔗 已启用 Redis 多实例协调", "redis", broker.addr, "instance", broker.instanceID)
 	}
 
 	return srv, nil
 }
 
+// deliverRemoteCert 将其他实例经 Redis 转发的证书推送给本实例的订阅者
+func (s *Server) deliverRemoteCert(domain string, data *websocket.CertPushData) {
+	sent := s.hub.BroadcastCertLocal(domain, data)
+	s.pushed.record(domain, data.Timestamp)
+	slog.Info("📥 转发其他实例的证书推送", "domain", domain, "clients", sent, "timestamp", data.Timestamp)
+}
+
 // Run 启动服务器（阻塞直到上下文取消或启动失败）
 func (s *Server) Run(ctx context.Context) error {
 	cfg := s.config
 
-	// 注册配置热重载回调 - 更新白名单
+	// 注册配置热重载回调 - 更新白名单、密钥及连接参数
 	config.RegisterReloadCallback(func(newCfg *config.Config) {
-		s.whitelist.Update(newCfg.IPWhitelist)
+		s.applyConfig(newCfg)
 		if s.whitelist.IsEnabled() {
 			slog.Info("🔄 IP 白名单已更新", "whitelist", newCfg.IPWhitelist)
 		} else {
@@ -72,16 +139,13 @@ func (s *Server) Run(ctx context.Context) error {
 	// 设置证书变更回调 - 推送到订阅的客户端
 	s.watcher.OnChange(func(domain string, files map[string][]byte) {
 		// 从 time.log 读取实际时间戳，保持与服务端一致
+		names := cert.DomainFileNames(domain)
+		if dir, ok := cert.FindDomainDir(config.CertDirs(s.config), domain); ok {
+			names = cert.DomainDirFileNames(dir, domain)
+		}
 		var timestamp int64
-		if timeContent, ok := files["time.log"]; ok {
-			ts := string(timeContent)
-			// 只取前10位（Unix 时间戳）
-			if len(ts) > 10 {
-				ts = ts[:10]
-			}
-			if t, err := strconv.ParseInt(strings.TrimSpace(ts), 10, 64); err == nil {
-				timestamp = t
-			}
+		if t, ok := cert.ParseTimeLog(files[names.TimeLog]); ok {
+			timestamp = t
 		}
 		// 如果没有 time.log 或解析失败，使用当前时间
 		if timestamp == 0 {
@@ -92,8 +156,10 @@ func (s *Server) Run(ctx context.Context) error {
 			Domain:    domain,
 			Files:     files,
 			Timestamp: timestamp,
+			FileNames: websocket.NonDefaultFileNames(names),
 		}
 		sent := s.hub.BroadcastCert(domain, data)
+		s.pushed.record(domain, timestamp)
 		slog.Info("📤 证书推送", "domain", domain, "clients", sent, "timestamp", timestamp)
 	})
 
@@ -101,25 +167,40 @@ func (s *Server) Run(ctx context.Context) error {
 	if err := s.watcher.Start(); err != nil {
 		return err
 	}
-	slog.Info("👀 证书目录监控已启动", "dir", cfg.BaseDir)
+	slog.Info("👀 证书目录监控已启动", "dirs", config.CertDirs(cfg))
+
+	if s.broker != nil {
+		go s.broker.Run(ctx)
+	}
+
+	// 定时巡检：补推 watcher 未捕获的证书更新
+	if cfg.ProactivePushInterval > 0 {
+		s.seedPushTracker()
+		go s.proactivePushLoop(ctx, time.Duration(cfg.ProactivePushInterval)*time.Second)
+	}
+
+	// 定时清理：删除超过保留期的归档域名目录
+	if cfg.CleanupIntervalHours > 0 {
+		go s.cleanupLoop(ctx, time.Duration(cfg.CleanupIntervalHours)*time.Hour, cleanupArchiveAge(cfg))
+	}
 
 	// 设置路由
-	mux := http.NewServeMux()
-	mux.HandleFunc("/", handler.HandleHome)
+	mux := s.newMux(cfg)
+	wsPath := config.NormalizeWSPath(cfg.WSPath)
 
-	// WebSocket 端点
-	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		// 读取最新配置以支持 trust_proxy 热重载
-		currentCfg := config.GetConfig()
-		trustProxy := cfg.TrustProxy
-		if currentCfg != nil {
-			trustProxy = currentCfg.TrustProxy
-		}
-		websocket.ServeWs(s.hub, cfg.Key, cfg.BaseDir, s.whitelist, trustProxy, w, r)
-	})
+	// systemd socket activation：使用 systemd 传入的监听套接字，未激活时按配置绑定端口
+	activated, err := processActivationEnv().listeners()
+	if err != nil {
+		return err
+	}
+	httpLn, tlsLn := selectListeners(activated, cfg.TLS)
 
 	// 创建 HTTP 服务器
 	httpAddr := cfg.Bind + ":" + cfg.Port
+	if httpLn != nil {
+		httpAddr = httpLn.Addr().String()
+		slog.Info("🔌 HTTP 使用 systemd 传入的监听套接字", "addr", httpAddr)
+	}
 	httpServer := &http.Server{
 		Addr:    httpAddr,
 		Handler: mux,
@@ -131,15 +212,33 @@ func (s *Server) Run(ctx context.Context) error {
 	// 错误通道用于 goroutine 错误传递
 	errChan := make(chan error, 2)
 
+	if !cfg.TLS && cfg.ClientCAFile != "" {
+		slog.Warn("已配置 client_ca_file 但未启用 TLS，客户端证书认证不会生效")
+	}
 	if cfg.TLS {
+		tlsConfig, err := buildTLSConfig(cfg)
+		if err != nil {
+			return err
+		}
 		tlsAddr := cfg.Bind + ":" + cfg.TLSPort
+		if tlsLn != nil {
+			tlsAddr = tlsLn.Addr().String()
+			slog.Info("🔌 TLS 使用 systemd 传入的监听套接字", "addr", tlsAddr)
+		}
 		tlsServer = &http.Server{
-			Addr:    tlsAddr,
-			Handler: mux,
+			Addr:      tlsAddr,
+			Handler:   mux,
+			TLSConfig: tlsConfig,
 		}
 		go func() {
 			slog.Info("🔒 TLS服务器启动", "addr", "https://"+tlsAddr)
-			if err := tlsServer.ListenAndServeTLS(cfg.CertFile, cfg.KeyFile); err != nil && err != http.ErrServerClosed {
+			var err error
+			if tlsLn != nil {
+				err = tlsServer.ServeTLS(tlsLn, cfg.CertFile, cfg.KeyFile)
+			} else {
+				err = tlsServer.ListenAndServeTLS(cfg.CertFile, cfg.KeyFile)
+			}
+			if err != nil && err != http.ErrServerClosed {
 				slog.Error("TLS服务器启动失败", "error", err)
 				errChan <- fmt.Errorf("TLS服务器启动失败: %w", err)
 			}
@@ -150,9 +249,15 @@ func (s *Server) Run(ctx context.Context) error {
 	go func() {
 		slog.Info("🚀 HTTP服务器启动",
 			"addr", "http://"+httpAddr,
-			"certDir", cfg.BaseDir,
-			"wsEndpoint", "ws://"+httpAddr+"/ws")
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			"certDirs", config.CertDirs(cfg),
+			"wsEndpoint", "ws://"+httpAddr+wsPath)
+		var err error
+		if httpLn != nil {
+			err = httpServer.Serve(httpLn)
+		} else {
+			err = httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			slog.Error("HTTP服务器启动失败", "error", err)
 			errChan <- fmt.Errorf("HTTP服务器启动失败: %w", err)
 		}
@@ -165,6 +270,7 @@ func (s *Server) Run(ctx context.Context) error {
 	case <-ctx.Done():
 		slog.Info("🛑 收到关闭请求，开始优雅关闭...", "reason", ctx.Err())
 	}
+	s.shuttingDown.Store(true)
 
 	// 创建关闭超时上下文
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -186,6 +292,12 @@ func (s *Server) Run(ctx context.Context) error {
 		return s.watcher.Stop()
 	})
 
+	if s.broker != nil {
+		shutdown.AddFunc("Redis", func(ctx context.Context) error {
+			return s.broker.Close()
+		})
+	}
+
 	// 执行优雅关闭
 	shutdown.Shutdown(shutdownCtx)
 
@@ -2,9 +2,11 @@ package server
 
 import (
 	"context"
+	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/Catker/acmeDeliver/pkg/client"
 	"github.com/Catker/acmeDeliver/pkg/config"
 )
 
@@ -39,3 +41,53 @@ func TestServerRun_RespectsContextCancellation(t *testing.T) {
 		t.Fatal("Run(ctx) 未在上下文取消后及时退出")
 	}
 }
+
+func TestWSPathRoundTrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		serverPath string // 服务端 ws_path
+		clientPath string // 客户端 ws_path
+		urlSuffix  string // 客户端 server 地址的后缀
+		wantErr    bool
+	}{
+		{"默认路径", "", "", "", false},
+		{"server 地址已包含默认路径", "", "", "/ws", false},
+		{"自定义路径", "/acme/ws", "/acme/ws", "", false},
+		{"自定义路径不带前导斜杠", "/acme/ws", "acme/ws/", "", false},
+		{"server 地址已包含自定义路径", "/acme/ws", "/acme/ws", "/acme/ws", false},
+		{"客户端未配置自定义路径", "/acme/ws", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{BaseDir: t.TempDir(), Key: "test-key", WSPath: tt.serverPath}
+			srv, err := NewServer(cfg)
+			if err != nil {
+				t.Fatalf("NewServer() error = %v", err)
+			}
+			t.Cleanup(func() { srv.watcher.Stop() })
+			ts := httptest.NewServer(srv.newMux(cfg))
+			defer ts.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			c := client.NewWSClient(ts.URL+tt.urlSuffix, "test-key", nil)
+			c.SetWSPath(tt.clientPath)
+			err = c.Connect(ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Connect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				c.Close()
+			}
+		})
+	}
+}
+
+func TestNewServerRejectsConflictingWSPath(t *testing.T) {
+	for _, path := range []string{"/", "/healthz", "/api/v1/ws"} {
+		if _, err := NewServer(&config.Config{BaseDir: t.TempDir(), Key: "test-key", WSPath: path}); err == nil {
+			t.Errorf("ws_path %q 应被拒绝", path)
+		}
+	}
+}
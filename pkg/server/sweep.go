@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/config"
+	"github.com/Catker/acmeDeliver/pkg/websocket"
+	"github.com/Catker/acmeDeliver/pkg/workspace"
+)
+
+// pushTracker 记录每个域名最近一次推送的证书时间戳（time.log），避免重复推送
+type pushTracker struct {
+	mu   sync.Mutex
+	last map[string]int64
+}
+
+// newPushTracker 创建推送记录器
+func newPushTracker() *pushTracker {
+	return &pushTracker{last: make(map[string]int64)}
+}
+
+// record 记录域名已推送的时间戳（仅向前推进）
+func (p *pushTracker) record(domain string, timestamp int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if timestamp > p.last[domain] {
+		p.last[domain] = timestamp
+	}
+}
+
+// isNewer 判断时间戳是否比最近一次推送更新
+func (p *pushTracker) isNewer(domain string, timestamp int64) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return timestamp > p.last[domain]
+}
+
+// seedPushTracker 以证书目录的当前状态初始化推送记录
+// 启动时已有的证书由客户端连接后的同步请求获取，无需在首次巡检时重复推送
+func (s *Server) seedPushTracker() {
+	for domain, ts := range s.listDomains() {
+		s.pushed.record(domain, ts)
+	}
+}
+
+// proactivePushLoop 定时巡检证书目录，补推 watcher 未捕获的证书更新
+func (s *Server) proactivePushLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	slog.Info("⏰ 主动推送巡检已启用", "interval", interval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.sweepDomains()
+		}
+	}
+}
+
+// sweepDomains 对 time.log 比上次推送更新的域名重新广播证书
+// 返回本轮补推的域名数量
+func (s *Server) sweepDomains() int {
+	pushed := 0
+	for domain, ts := range s.listDomains() {
+		if ts == 0 || !s.pushed.isNewer(domain, ts) {
+			continue
+		}
+
+		data, err := websocket.LoadCertPushData(config.CertDirs(s.config), domain)
+		if err != nil {
+			slog.Warn("巡检读取证书失败", "domain", domain, "error", err)
+			continue
+		}
+
+		sent := s.hub.BroadcastCert(domain, data)
+		s.pushed.record(domain, ts)
+		pushed++
+		slog.Info("📤 巡检发现未推送的证书更新，已补推", "domain", domain, "timestamp", ts, "clients", sent)
+	}
+	return pushed
+}
+
+// listDomains 合并所有证书目录中的域名及其 time.log 时间戳，同名域名以靠前的目录为准
+// 无法读取的目录记录警告后跳过
+func (s *Server) listDomains() map[string]int64 {
+	merged := make(map[string]int64)
+	for _, dir := range config.CertDirs(s.config) {
+		domains, err := workspace.ListDomains(dir)
+		if err != nil {
+			slog.Warn("读取证书目录失败", "dir", dir, "error", err)
+			continue
+		}
+		for domain, ts := range domains {
+			if _, ok := merged[domain]; !ok {
+				merged[domain] = ts
+			}
+		}
+	}
+	return merged
+}
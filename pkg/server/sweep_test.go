@@ -0,0 +1,70 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// writeDomainCert 写入测试域名的证书与 time.log
+func writeDomainCert(t *testing.T, baseDir, domain string, timestamp int64) {
+	t.Helper()
+	dir := filepath.Join(baseDir, domain)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cert.pem"), []byte("cert-"+strconv.FormatInt(timestamp, 10)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "time.log"), []byte(strconv.FormatInt(timestamp, 10)), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSweepDomains(t *testing.T) {
+	baseDir := t.TempDir()
+	writeDomainCert(t, baseDir, "example.com", 1700000000)
+	writeDomainCert(t, baseDir, "other.com", 1700000000)
+
+	srv, _ := newTestAPIServer(t, baseDir)
+	srv.seedPushTracker()
+
+	// 启动时已存在的证书不重复推送
+	if got := srv.sweepDomains(); got != 0 {
+		t.Fatalf("首次巡检推送 %d 个域名, want 0", got)
+	}
+
+	// time.log 更新（watcher 未捕获）后，下一次巡检补推
+	writeDomainCert(t, baseDir, "example.com", 1700086400)
+	if got := srv.sweepDomains(); got != 1 {
+		t.Fatalf("time.log 更新后巡检推送 %d 个域名, want 1", got)
+	}
+	if srv.pushed.isNewer("example.com", 1700086400) {
+		t.Error("补推后应记录最新时间戳")
+	}
+
+	// 已补推的域名不再重复推送
+	if got := srv.sweepDomains(); got != 0 {
+		t.Errorf("重复巡检推送 %d 个域名, want 0", got)
+	}
+
+	// 新增域名同样会被推送
+	writeDomainCert(t, baseDir, "new.com", 1700086400)
+	if got := srv.sweepDomains(); got != 1 {
+		t.Errorf("新增域名后巡检推送 %d 个域名, want 1", got)
+	}
+}
+
+func TestPushTracker_RecordOnlyMovesForward(t *testing.T) {
+	p := newPushTracker()
+	p.record("example.com", 200)
+	p.record("example.com", 100)
+
+	if p.isNewer("example.com", 200) {
+		t.Error("较旧的时间戳不应覆盖已记录的时间戳")
+	}
+	if !p.isNewer("example.com", 201) {
+		t.Error("更新的时间戳应判定为需要推送")
+	}
+}
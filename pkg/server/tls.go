@@ -0,0 +1,34 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/Catker/acmeDeliver/pkg/config"
+)
+
+// buildTLSConfig 构建 TLS 端口的服务器配置
+// 配置了 client_ca_file 时要求客户端出示由该 CA 签发的证书（mTLS），未配置时返回 nil 使用默认配置
+func buildTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	if cfg.ClientCAFile == "" {
+		return nil, nil
+	}
+
+	caPEM, err := os.ReadFile(cfg.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("加载客户端 CA 证书失败: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("解析客户端 CA 证书失败: 无效的 PEM 格式")
+	}
+
+	slog.Info("🔒 已启用客户端证书认证（mTLS）", "client_ca_file", cfg.ClientCAFile)
+	return &tls.Config{
+		ClientCAs:  pool,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+	}, nil
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Catker/acmeDeliver/pkg/config"
+	"github.com/Catker/acmeDeliver/pkg/testutil"
+)
+
+func TestBuildTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca, _, err := testutil.GenerateCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile := filepath.Join(dir, "ca.pem")
+	badFile := filepath.Join(dir, "bad.pem")
+	os.WriteFile(caFile, ca, 0644)
+	os.WriteFile(badFile, []byte("not pem"), 0644)
+
+	tests := []struct {
+		name    string
+		caFile  string
+		wantNil bool
+		wantErr bool
+	}{
+		{"未配置 client_ca_file", "", true, false},
+		{"有效 CA", caFile, false, false},
+		{"文件不存在", filepath.Join(dir, "missing.pem"), true, true},
+		{"无效 PEM", badFile, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildTLSConfig(&config.Config{ClientCAFile: tt.caFile})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buildTLSConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (got == nil) != tt.wantNil {
+				t.Fatalf("buildTLSConfig() = %v, wantNil %v", got, tt.wantNil)
+			}
+			if got != nil && got.ClientAuth != tls.RequireAndVerifyClientCert {
+				t.Errorf("ClientAuth = %v, want RequireAndVerifyClientCert", got.ClientAuth)
+			}
+		})
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey, err := testutil.GenerateCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caFile, ca, 0644); err != nil {
+		t.Fatal(err)
+	}
+	tlsConfig, err := buildTLSConfig(&config.Config{ClientCAFile: caFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	ts.TLS = tlsConfig
+	ts.StartTLS()
+	defer ts.Close()
+
+	// newClient 创建信任测试服务端证书、可选出示客户端证书的 HTTP 客户端
+	newClient := func(certs ...tls.Certificate) *http.Client {
+		roots := x509.NewCertPool()
+		roots.AddCert(ts.Certificate())
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+	}
+
+	signed, signedKey, err := testutil.GenerateSignedCert(string(ca), string(caKey), "client.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	validCert, err := tls.X509KeyPair(signed, signedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherCA, otherKey, err := testutil.GenerateCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreign, foreignKey, err := testutil.GenerateSignedCert(string(otherCA), string(otherKey), "client.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreignCert, err := tls.X509KeyPair(foreign, foreignKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := newClient(validCert).Get(ts.URL)
+	if err != nil {
+		t.Fatalf("出示有效客户端证书时请求失败: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+
+	// 未出示证书或证书不是由 client_ca_file 签发时在 TLS 握手阶段被拒绝
+	for name, client := range map[string]*http.Client{"无客户端证书": newClient(), "其他 CA 签发": newClient(foreignCert)} {
+		if resp, err := client.Get(ts.URL); err == nil {
+			resp.Body.Close()
+			t.Errorf("%s: 请求应在 TLS 层失败, status = %d", name, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,496 @@
+package server
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/cert"
+	"github.com/Catker/acmeDeliver/pkg/websocket"
+	"github.com/Catker/acmeDeliver/pkg/workspace"
+)
+
+// DefaultMaxCertSize 单个上传文件的默认大小上限（100 KB）
+const DefaultMaxCertSize = 100 * 1024
+
+// 上传文件槽位
+const (
+	slotCert      = "cert.pem"
+	slotKey       = "key.pem"
+	slotFullchain = "fullchain.pem"
+)
+
+// uploadSlots 允许上传的文件槽位（multipart 字段名即目标文件名）
+var uploadSlots = map[string]bool{slotCert: true, slotKey: true, slotFullchain: true}
+
+// allowedUploadTypes 允许的文件 Content-Type
+// 各类客户端对 .pem 文件的取值并不统一，这里只拒绝明显不是证书的类型
+var allowedUploadTypes = map[string]bool{
+	"":                                  true,
+	"text/plain":                        true,
+	"application/octet-stream":          true,
+	"application/x-pem-file":            true,
+	"application/pem-certificate-chain": true,
+	"application/x-x509-ca-cert":        true,
+	"application/x-x509-user-cert":      true,
+	"application/pkix-cert":             true,
+}
+
+// FieldError 字段级校验错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// UploadErrorResponse 证书上传校验失败的响应
+type UploadErrorResponse struct {
+	Error  string       `json:"error"`
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// CertUploadResponse 证书上传成功的响应
+type CertUploadResponse struct {
+	Domain      string   `json:"domain"`
+	Files       []string `json:"files"`
+	Fingerprint string   `json:"fingerprint,omitempty"` // 叶子证书 SHA-256 指纹（仅上传私钥时为空）
+	Timestamp   int64    `json:"timestamp"`
+}
+
+// CertUploadHandler 处理证书上传：校验 PEM 内容后写入域名目录
+// 写入 time.log 后由证书目录监控推送给订阅的客户端
+type CertUploadHandler struct {
+	baseDirs    []string
+	maxSize     atomic.Int64
+	syncOnWrite atomic.Bool
+	now         func() time.Time
+
+	mu sync.Mutex // 串行化一致性检查与写入，避免并发上传混合两次上传的证书与私钥
+}
+
+// NewCertUploadHandler 创建证书上传处理器
+// maxSize 为单个文件的大小上限（字节），<= 0 时使用默认值
+// 域名已存在于某个证书目录时写入该目录，否则写入第一个目录
+func NewCertUploadHandler(baseDirs []string, maxSize int64) *CertUploadHandler {
+	h := &CertUploadHandler{baseDirs: baseDirs, now: time.Now}
+	h.SetMaxSize(maxSize)
+	return h
+}
+
+// SetMaxSize 更新单个文件的大小上限（用于配置热重载）
+func (h *CertUploadHandler) SetMaxSize(maxSize int64) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxCertSize
+	}
+	h.maxSize.Store(maxSize)
+}
+
+// SetSyncOnWrite 设置写入后是否 fsync 文件及所在目录（对应配置 sync_on_write）
+func (h *CertUploadHandler) SetSyncOnWrite(sync bool) {
+	h.syncOnWrite.Store(sync)
+}
+
+// MaxSize 返回当前单个文件的大小上限
+func (h *CertUploadHandler) MaxSize() int64 {
+	return h.maxSize.Load()
+}
+
+// uploadedFile 已读取并解析的上传文件
+type uploadedFile struct {
+	data  []byte
+	certs []*x509.Certificate
+	keys  []crypto.PrivateKey
+}
+
+// Upload 处理 multipart/form-data 上传请求
+// 字段名为目标文件名（cert.pem / key.pem / fullchain.pem），至少上传一个
+func (h *CertUploadHandler) Upload(w http.ResponseWriter, r *http.Request, domain string) {
+	domainDir, err := websocket.FindDomainDir(h.baseDirs, domain)
+	if errors.Is(err, os.ErrNotExist) {
+		domainDir, err = websocket.SafeDomainDir(h.baseDirs[0], domain)
+	}
+	if err != nil {
+		writeUploadError(w, http.StatusBadRequest, "无效的域名", FieldError{Field: "domain", Message: err.Error()})
+		return
+	}
+
+	maxSize := h.MaxSize()
+	// 整个请求体上限：所有槽位的文件加上 multipart 头部开销
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize*int64(len(uploadSlots))+64*1024)
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "需要 multipart/form-data 请求: "+err.Error())
+		return
+	}
+
+	files := make(map[string]*uploadedFile)
+	var fieldErrs []FieldError
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				writeJSONError(w, http.StatusRequestEntityTooLarge, "请求体过大")
+				return
+			}
+			writeJSONError(w, http.StatusBadRequest, "解析上传内容失败: "+err.Error())
+			return
+		}
+
+		name := part.FormName()
+		data, readErr := readPart(part, maxSize)
+		part.Close()
+
+		switch {
+		case !uploadSlots[name]:
+			fieldErrs = append(fieldErrs, FieldError{Field: name, Message: "未知的上传字段（可选 cert.pem / key.pem / fullchain.pem）"})
+		case files[name] != nil:
+			fieldErrs = append(fieldErrs, FieldError{Field: name, Message: "重复上传同一文件"})
+		case readErr != nil:
+			var maxErr *http.MaxBytesError
+			if errors.As(readErr, &maxErr) {
+				writeJSONError(w, http.StatusRequestEntityTooLarge, "请求体过大")
+				return
+			}
+			fieldErrs = append(fieldErrs, FieldError{Field: name, Message: readErr.Error()})
+		default:
+			if msg := checkUploadType(part.Header.Get("Content-Type"), data); msg != "" {
+				fieldErrs = append(fieldErrs, FieldError{Field: name, Message: msg})
+				continue
+			}
+			f, msg := parseUploadedPEM(data)
+			if msg != "" {
+				fieldErrs = append(fieldErrs, FieldError{Field: name, Message: msg})
+				continue
+			}
+			if msg := checkSlot(name, f); msg != "" {
+				fieldErrs = append(fieldErrs, FieldError{Field: name, Message: msg})
+				continue
+			}
+			files[name] = f
+		}
+	}
+
+	if len(files) == 0 && len(fieldErrs) == 0 {
+		writeUploadError(w, http.StatusBadRequest, "至少需要上传一个文件（cert.pem / key.pem / fullchain.pem）")
+		return
+	}
+	if len(fieldErrs) > 0 {
+		slog.Warn("证书上传校验失败", "domain", domain, "remote", r.RemoteAddr, "errors", len(fieldErrs))
+		writeUploadError(w, http.StatusUnprocessableEntity, "证书文件校验失败", fieldErrs...)
+		return
+	}
+
+	// 只上传部分文件时与域名目录中已有的其他文件做一致性检查，检查与写入之间不允许其他上传介入
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if fieldErrs := checkConsistency(files, existingFiles(domainDir, domain, files)); len(fieldErrs) > 0 {
+		slog.Warn("证书上传校验失败", "domain", domain, "remote", r.RemoteAddr, "errors", len(fieldErrs))
+		writeUploadError(w, http.StatusUnprocessableEntity, "证书文件校验失败", fieldErrs...)
+		return
+	}
+
+	resp, err := h.save(domain, domainDir, files)
+	if err != nil {
+		slog.Error("保存上传证书失败", "domain", domain, "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "保存证书失败: "+err.Error())
+		return
+	}
+
+	slog.Info("📥 证书已上传", "domain", domain, "files", resp.Files, "fingerprint", resp.Fingerprint)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// save 将校验通过的文件写入域名目录
+// 先写证书与指纹，最后写 time.log，确保监控推送时读到的是完整的新证书
+func (h *CertUploadHandler) save(domain, domainDir string, files map[string]*uploadedFile) (*CertUploadResponse, error) {
+	if err := os.MkdirAll(domainDir, 0755); err != nil {
+		return nil, err
+	}
+
+	// 按域名目录实际使用的文件名写入（配置的 cert_filenames 或自动识别的布局），确保上传后的证书能被请求与推送读取
+	names := cert.DomainDirFileNames(domainDir, domain)
+	targets := map[string]string{slotCert: names.Cert, slotFullchain: names.Fullchain, slotKey: names.Key}
+
+	syncOnWrite := h.syncOnWrite.Load()
+	resp := &CertUploadResponse{Domain: domain, Timestamp: h.now().Unix()}
+	for _, name := range []string{slotCert, slotFullchain, slotKey} {
+		f, ok := files[name]
+		if !ok {
+			continue
+		}
+		perm := os.FileMode(0644)
+		if name == slotKey {
+			perm = 0600
+		}
+		if err := workspace.WriteFileAtomic(filepath.Join(domainDir, targets[name]), f.data, perm, syncOnWrite); err != nil {
+			return nil, err
+		}
+		resp.Files = append(resp.Files, targets[name])
+	}
+
+	if leaf := leafCertificate(files); leaf != nil {
+		resp.Fingerprint = Fingerprint(leaf)
+		if err := workspace.WriteFileAtomic(filepath.Join(domainDir, "fingerprint.txt"), []byte(resp.Fingerprint+"\n"), 0644, syncOnWrite); err != nil {
+			return nil, err
+		}
+	}
+
+	timeLog := []byte(strconv.FormatInt(resp.Timestamp, 10) + "\n")
+	if err := workspace.WriteFileAtomic(filepath.Join(domainDir, names.TimeLog), timeLog, 0644, syncOnWrite); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Fingerprint 计算证书的 SHA-256 指纹（冒号分隔的大写十六进制）
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// readPart 读取单个文件，超过大小上限时返回错误
+func readPart(r io.Reader, maxSize int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("文件超过大小上限 %d 字节", maxSize)
+	}
+	return data, nil
+}
+
+// checkUploadType 校验声明的 Content-Type 与实际内容类型
+func checkUploadType(contentType string, data []byte) string {
+	mediaType := ""
+	if contentType != "" {
+		mt, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return "无效的 Content-Type: " + contentType
+		}
+		mediaType = mt
+	}
+	if !allowedUploadTypes[mediaType] {
+		return "不支持的文件类型: " + mediaType
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return "文件为空"
+	}
+	if !strings.HasPrefix(http.DetectContentType(data), "text/plain") {
+		return "文件不是 PEM 文本（DER 格式请先转换为 PEM）"
+	}
+	return ""
+}
+
+// parseUploadedPEM 解析 PEM 文件，仅允许 CERTIFICATE 与 PRIVATE KEY 类型的块
+// 块之外出现非空白内容时视为非法文件
+func parseUploadedPEM(data []byte) (*uploadedFile, string) {
+	f := &uploadedFile{data: data}
+	rest := data
+	for index := 1; ; index++ {
+		rest = bytes.TrimSpace(rest)
+		if len(rest) == 0 {
+			break
+		}
+		if !bytes.HasPrefix(rest, []byte("-----BEGIN ")) {
+			return nil, "包含 PEM 块之外的内容"
+		}
+		block, next := pem.Decode(rest)
+		if block == nil {
+			return nil, fmt.Sprintf("第 %d 个 PEM 块格式错误", index)
+		}
+		rest = next
+
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Sprintf("第 %d 个证书解析失败: %v", index, err)
+			}
+			f.certs = append(f.certs, cert)
+		case "PRIVATE KEY", "RSA PRIVATE KEY", "EC PRIVATE KEY":
+			if len(block.Headers) > 0 {
+				return nil, "不支持加密的私钥"
+			}
+			key, err := parsePrivateKey(block)
+			if err != nil {
+				return nil, fmt.Sprintf("第 %d 个私钥解析失败: %v", index, err)
+			}
+			f.keys = append(f.keys, key)
+		case "ENCRYPTED PRIVATE KEY":
+			return nil, "不支持加密的私钥"
+		default:
+			return nil, fmt.Sprintf("不允许的 PEM 类型 %q（仅支持 CERTIFICATE 与 PRIVATE KEY）", block.Type)
+		}
+	}
+	if len(f.certs) == 0 && len(f.keys) == 0 {
+		return nil, "未找到 PEM 内容"
+	}
+	return f, ""
+}
+
+// parsePrivateKey 按块类型解析私钥
+func parsePrivateKey(block *pem.Block) (crypto.PrivateKey, error) {
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+}
+
+// checkSlot 检查文件内容与槽位是否匹配（如私钥误传到 cert.pem）
+func checkSlot(slot string, f *uploadedFile) string {
+	switch slot {
+	case slotCert, slotFullchain:
+		if len(f.keys) > 0 {
+			return slot + " 中包含私钥，私钥应上传到 key.pem"
+		}
+		if slot == slotCert && len(f.certs) > 1 {
+			return "cert.pem 应只包含叶子证书，完整证书链请上传到 fullchain.pem"
+		}
+		if f.certs[0].IsCA {
+			return "第一个证书是 CA 证书，应为域名的叶子证书"
+		}
+	case slotKey:
+		if len(f.certs) > 0 {
+			return "key.pem 中包含证书，证书应上传到 cert.pem 或 fullchain.pem"
+		}
+		if len(f.keys) > 1 {
+			return "key.pem 只能包含一个私钥"
+		}
+	}
+	return ""
+}
+
+// existingFiles 读取域名目录中本次未上传的证书文件，用于与上传的文件做一致性检查
+// 文件不存在、无法解析或内容与槽位不符时忽略（该槽位不参与检查）
+func existingFiles(domainDir, domain string, uploaded map[string]*uploadedFile) map[string]*uploadedFile {
+	names := cert.DomainDirFileNames(domainDir, domain)
+	paths := map[string]string{slotCert: names.Cert, slotFullchain: names.Fullchain, slotKey: names.Key}
+
+	existing := make(map[string]*uploadedFile)
+	for slot, name := range paths {
+		if uploaded[slot] != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(domainDir, name))
+		if err != nil {
+			continue
+		}
+		f, msg := parseUploadedPEM(data)
+		if msg != "" || (slot == slotKey && len(f.keys) == 0) || (slot != slotKey && len(f.certs) == 0) {
+			continue
+		}
+		existing[slot] = f
+	}
+	return existing
+}
+
+// checkConsistency 检查各文件之间是否一致：私钥与证书匹配、证书链叶子与 cert.pem 相同
+// existing 为域名目录中未被本次上传覆盖的文件，至少一方是本次上传的文件时才检查，错误归到上传的字段
+func checkConsistency(files, existing map[string]*uploadedFile) []FieldError {
+	merged := make(map[string]*uploadedFile, len(files)+len(existing))
+	for name, f := range existing {
+		merged[name] = f
+	}
+	for name, f := range files {
+		merged[name] = f
+	}
+
+	var errs []FieldError
+	cert, fullchain := merged[slotCert], merged[slotFullchain]
+	if cert != nil && fullchain != nil && !cert.certs[0].Equal(fullchain.certs[0]) {
+		switch {
+		case files[slotFullchain] == nil:
+			errs = append(errs, FieldError{Field: slotCert, Message: "cert.pem 与域名目录中已有的 fullchain.pem 的叶子证书不一致，请同时上传 fullchain.pem"})
+		case files[slotCert] == nil:
+			errs = append(errs, FieldError{Field: slotFullchain, Message: "证书链的叶子证书与域名目录中已有的 cert.pem 不一致，请同时上传 cert.pem"})
+		default:
+			errs = append(errs, FieldError{Field: slotFullchain, Message: "证书链的叶子证书与 cert.pem 不一致"})
+		}
+	}
+
+	leafSlot := leafSlot(merged)
+	if key := merged[slotKey]; key != nil && leafSlot != "" {
+		if !publicKeyMatches(key.keys[0], merged[leafSlot].certs[0].PublicKey) {
+			switch {
+			case files[slotKey] == nil:
+				errs = append(errs, FieldError{Field: leafSlot, Message: "证书公钥与域名目录中已有的 key.pem 不匹配，请同时上传 key.pem"})
+			case files[leafSlot] == nil:
+				errs = append(errs, FieldError{Field: slotKey, Message: "私钥与域名目录中已有的 " + leafSlot + " 不匹配，请同时上传证书"})
+			default:
+				errs = append(errs, FieldError{Field: slotKey, Message: "私钥与证书公钥不匹配"})
+			}
+		}
+	}
+	return errs
+}
+
+// leafSlot 返回叶子证书所在的槽位，优先取 cert.pem，没有证书时返回空
+func leafSlot(files map[string]*uploadedFile) string {
+	for _, name := range []string{slotCert, slotFullchain} {
+		if f, ok := files[name]; ok && len(f.certs) > 0 {
+			return name
+		}
+	}
+	return ""
+}
+
+// leafCertificate 返回上传内容中的叶子证书，优先取 cert.pem
+func leafCertificate(files map[string]*uploadedFile) *x509.Certificate {
+	if slot := leafSlot(files); slot != "" {
+		return files[slot].certs[0]
+	}
+	return nil
+}
+
+// publicKeyMatches 判断私钥是否与证书公钥对应
+func publicKeyMatches(key crypto.PrivateKey, pub crypto.PublicKey) bool {
+	var public crypto.PublicKey
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		public = &k.PublicKey
+	case *ecdsa.PrivateKey:
+		public = &k.PublicKey
+	case ed25519.PrivateKey:
+		public = k.Public()
+	default:
+		return false
+	}
+	eq, ok := public.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && eq.Equal(pub)
+}
+
+// writeUploadError 输出带字段级详情的错误响应
+func writeUploadError(w http.ResponseWriter, status int, message string, fields ...FieldError) {
+	writeJSON(w, status, UploadErrorResponse{Error: message, Fields: fields})
+}
@@ -0,0 +1,389 @@
+package server
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/testutil"
+)
+
+// testPKI 测试用证书与私钥（PEM）
+type testPKI struct {
+	ca, leaf, key, otherKey []byte
+	leafCert                *x509.Certificate
+}
+
+// newTestPKI 生成 CA、由其签发的叶子证书及对应私钥
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+
+	ca, caKey, err := testutil.GenerateCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, key, err := testutil.GenerateSignedCert(string(ca), string(caKey), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(leaf)
+	leafCert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testPKI{ca: ca, leaf: leaf, key: key, otherKey: caKey, leafCert: leafCert}
+}
+
+// uploadPart 单个上传字段
+type uploadPart struct {
+	field       string
+	contentType string
+	data        []byte
+}
+
+// newUploadRequest 构造 multipart 上传请求
+func newUploadRequest(t *testing.T, url string, parts ...uploadPart) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, p := range parts {
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Disposition", `form-data; name="`+p.field+`"; filename="`+p.field+`"`)
+		if p.contentType != "" {
+			h.Set("Content-Type", p.contentType)
+		}
+		w, err := mw.CreatePart(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(p.data)
+	}
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, url, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestCertUploadValidation(t *testing.T) {
+	pki := newTestPKI(t)
+	fullchain := append(append([]byte{}, pki.leaf...), pki.ca...)
+
+	tests := []struct {
+		name       string
+		parts      []uploadPart
+		wantStatus int
+		wantField  string // 期望出现在字段错误中的字段
+	}{
+		{
+			name:       "完整上传",
+			parts:      []uploadPart{{slotCert, "", pki.leaf}, {slotKey, "", pki.key}, {slotFullchain, "application/x-pem-file", fullchain}},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "仅上传证书链",
+			parts:      []uploadPart{{slotFullchain, "", fullchain}},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "私钥误传到 cert.pem",
+			parts:      []uploadPart{{slotCert, "", pki.key}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantField:  slotCert,
+		},
+		{
+			name:       "证书误传到 key.pem",
+			parts:      []uploadPart{{slotKey, "", pki.leaf}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantField:  slotKey,
+		},
+		{
+			name:       "cert.pem 包含证书链",
+			parts:      []uploadPart{{slotCert, "", fullchain}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantField:  slotCert,
+		},
+		{
+			name:       "私钥与证书不匹配",
+			parts:      []uploadPart{{slotCert, "", pki.leaf}, {slotKey, "", pki.otherKey}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantField:  slotKey,
+		},
+		{
+			name:       "证书链叶子与 cert.pem 不一致",
+			parts:      []uploadPart{{slotCert, "", pki.leaf}, {slotFullchain, "", pki.ca}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantField:  slotFullchain,
+		},
+		{
+			name:       "不允许的 PEM 类型",
+			parts:      []uploadPart{{slotCert, "", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte("x")})}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantField:  slotCert,
+		},
+		{
+			name:       "PEM 块之外有内容",
+			parts:      []uploadPart{{slotCert, "", append([]byte("#!/bin/sh\nrm -rf /\n"), pki.leaf...)}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantField:  slotCert,
+		},
+		{
+			name:       "二进制文件",
+			parts:      []uploadPart{{slotCert, "", pki.leafCert.Raw}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantField:  slotCert,
+		},
+		{
+			name:       "不支持的 Content-Type",
+			parts:      []uploadPart{{slotCert, "image/png", pki.leaf}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantField:  slotCert,
+		},
+		{
+			name:       "超过大小上限",
+			parts:      []uploadPart{{slotCert, "", append(append([]byte{}, pki.leaf...), strings.Repeat("\n", 4096)...)}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantField:  slotCert,
+		},
+		{
+			name:       "未知字段",
+			parts:      []uploadPart{{"ca.pem", "", pki.ca}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantField:  "ca.pem",
+		},
+		{
+			name:       "没有文件",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseDir := t.TempDir()
+			h := NewCertUploadHandler([]string{baseDir}, 4096)
+
+			rec := httptest.NewRecorder()
+			h.Upload(rec, newUploadRequest(t, "/", tt.parts...), "example.com")
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body = %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantField != "" {
+				var resp UploadErrorResponse
+				if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+					t.Fatal(err)
+				}
+				found := false
+				for _, f := range resp.Fields {
+					if f.Field == tt.wantField {
+						found = true
+					}
+				}
+				if !found {
+					t.Errorf("fields = %+v, want error for %q", resp.Fields, tt.wantField)
+				}
+			}
+			if rec.Code != http.StatusOK {
+				if _, err := os.Stat(filepath.Join(baseDir, "example.com")); !os.IsNotExist(err) {
+					t.Errorf("校验失败时不应写入任何文件")
+				}
+			}
+		})
+	}
+}
+
+func TestCertUploadWritesFiles(t *testing.T) {
+	pki := newTestPKI(t)
+	baseDir := t.TempDir()
+	h := NewCertUploadHandler([]string{baseDir}, 0)
+	h.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	rec := httptest.NewRecorder()
+	req := newUploadRequest(t, "/", uploadPart{slotCert, "", pki.leaf}, uploadPart{slotKey, "", pki.key})
+	h.Upload(rec, req, "example.com")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	var resp CertUploadResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	wantFP := Fingerprint(pki.leafCert)
+	if resp.Fingerprint != wantFP || resp.Timestamp != 1700000000 {
+		t.Errorf("response = %+v", resp)
+	}
+
+	domainDir := filepath.Join(baseDir, "example.com")
+	wantFiles := map[string]string{
+		slotCert:          string(pki.leaf),
+		slotKey:           string(pki.key),
+		"fingerprint.txt": wantFP + "\n",
+		"time.log":        "1700000000\n",
+	}
+	for name, want := range wantFiles {
+		got, err := os.ReadFile(filepath.Join(domainDir, name))
+		if err != nil {
+			t.Errorf("读取 %s 失败: %v", name, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	info, err := os.Stat(filepath.Join(domainDir, slotKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("key.pem perm = %o, want 600", perm)
+	}
+}
+
+func TestCertUploadChecksExistingFiles(t *testing.T) {
+	pki, other := newTestPKI(t), newTestPKI(t)
+	baseDir := t.TempDir()
+	h := NewCertUploadHandler([]string{baseDir}, 0)
+	upload := func(parts ...uploadPart) (int, UploadErrorResponse) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.Upload(rec, newUploadRequest(t, "/", parts...), "example.com")
+		var resp UploadErrorResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp
+	}
+
+	if code, resp := upload(uploadPart{slotCert, "", pki.leaf}, uploadPart{slotKey, "", pki.key}); code != http.StatusOK {
+		t.Fatalf("首次上传 status = %d, %+v", code, resp)
+	}
+
+	// 只上传一个文件时与域名目录中已有的另一个文件比对
+	tests := []struct {
+		name      string
+		part      uploadPart
+		wantField string
+	}{
+		{"私钥与已有证书不匹配", uploadPart{slotKey, "", other.key}, slotKey},
+		{"证书与已有私钥不匹配", uploadPart{slotCert, "", other.leaf}, slotCert},
+		{"证书链叶子与已有 cert.pem 不一致", uploadPart{slotFullchain, "", append(append([]byte{}, other.leaf...), other.ca...)}, slotFullchain},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, resp := upload(tt.part)
+			if code != http.StatusUnprocessableEntity {
+				t.Fatalf("status = %d, want %d", code, http.StatusUnprocessableEntity)
+			}
+			if len(resp.Fields) == 0 || resp.Fields[0].Field != tt.wantField || !strings.Contains(resp.Fields[0].Message, "已有") {
+				t.Errorf("fields = %+v, want %s 与已有文件不一致", resp.Fields, tt.wantField)
+			}
+		})
+	}
+	if data, _ := os.ReadFile(filepath.Join(baseDir, "example.com", slotKey)); string(data) != string(pki.key) {
+		t.Error("校验失败时不应覆盖已有的私钥")
+	}
+
+	// 与已有文件匹配的单文件上传可以通过
+	if code, resp := upload(uploadPart{slotFullchain, "", append(append([]byte{}, pki.leaf...), pki.ca...)}); code != http.StatusOK {
+		t.Errorf("匹配的证书链上传 status = %d, %+v", code, resp)
+	}
+}
+
+func TestCertUploadMultipleBaseDirs(t *testing.T) {
+	pki := newTestPKI(t)
+	first, second := t.TempDir(), t.TempDir()
+	if err := os.MkdirAll(filepath.Join(second, "existing.com"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	h := NewCertUploadHandler([]string{first, second}, 0)
+
+	tests := []struct {
+		domain  string
+		wantDir string
+	}{
+		{"existing.com", second}, // 已存在的域名写入其所在目录
+		{"new.com", first},       // 新域名写入第一个目录
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.Upload(rec, newUploadRequest(t, "/", uploadPart{slotCert, "", pki.leaf}), tt.domain)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+			}
+			if _, err := os.Stat(filepath.Join(tt.wantDir, tt.domain, slotCert)); err != nil {
+				t.Errorf("证书未写入 %s: %v", tt.wantDir, err)
+			}
+		})
+	}
+}
+
+func TestCertUploadRejectsInvalidDomain(t *testing.T) {
+	baseDir := t.TempDir()
+	h := NewCertUploadHandler([]string{baseDir}, 0)
+
+	rec := httptest.NewRecorder()
+	h.Upload(rec, newUploadRequest(t, "/"), "..")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestCertUploadSetMaxSize(t *testing.T) {
+	h := NewCertUploadHandler([]string{t.TempDir()}, 0)
+	if got := h.MaxSize(); got != DefaultMaxCertSize {
+		t.Errorf("MaxSize() = %d, want %d", got, DefaultMaxCertSize)
+	}
+	h.SetMaxSize(2048)
+	if got := h.MaxSize(); got != 2048 {
+		t.Errorf("MaxSize() = %d, want 2048", got)
+	}
+	h.SetMaxSize(-1)
+	if got := h.MaxSize(); got != DefaultMaxCertSize {
+		t.Errorf("MaxSize() = %d, want %d", got, DefaultMaxCertSize)
+	}
+}
+
+func TestCertUploadAPIRoute(t *testing.T) {
+	pki := newTestPKI(t)
+	baseDir := t.TempDir()
+	_, ts := newTestAPIServer(t, baseDir)
+
+	unsigned := newUploadRequest(t, ts.URL+"/api/v1/domains/example.com/upload", uploadPart{slotCert, "", pki.leaf})
+	unsigned.RequestURI = ""
+	resp, err := http.DefaultClient.Do(unsigned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("未携带管理令牌上传 status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+
+	signed := adminRequest(t, http.MethodPost, ts.URL+"/api/v1/domains/example.com/upload", testAdminToken)
+	body := newUploadRequest(t, "/", uploadPart{slotCert, "", pki.leaf})
+	signed.Body = body.Body
+	signed.ContentLength = body.ContentLength
+	signed.Header.Set("Content-Type", body.Header.Get("Content-Type"))
+	resp, err = http.DefaultClient.Do(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("携带管理令牌上传 status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "example.com", "fingerprint.txt")); err != nil {
+		t.Errorf("应写入 fingerprint.txt: %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+// Package testutil 提供测试共用的证书生成工具
+//
+// 本包只依赖标准库，pkg/cert 等底层包的测试也可以直接引用；
+// 需要真实 WebSocket 服务端的测试使用子包 wstest（依赖 pkg/websocket）
+package testutil
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// 证书有效期
+const (
+	caValidity   = 365 * 24 * time.Hour
+	leafValidity = 90 * 24 * time.Hour // 与 Let's Encrypt 一致
+)
+
+// GenerateSelfSignedCert 生成 domain 的自签名证书及 PKCS#8 私钥（PEM）
+// 证书在 validity 后过期；validity 不大于 0 时生成已过期的证书
+func GenerateSelfSignedCert(domain string, validity time.Duration) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	template, err := leafTemplate(domain, validity)
+	if err != nil {
+		return nil, nil, err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encode(der, key)
+}
+
+// GenerateCA 生成自签名 CA 证书及 PKCS#8 私钥（PEM），可签发证书与 CRL
+func GenerateCA() (caPEM, caKeyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	template, err := caTemplate("acmeDeliver Test CA")
+	if err != nil {
+		return nil, nil, err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encode(der, key)
+}
+
+// GenerateIntermediateCA 使用 CA（PEM）签发名为 name 的中间 CA，可继续用于 GenerateSignedCert 或再签发中间 CA
+func GenerateIntermediateCA(ca, caKey, name string) (caPEM, caKeyPEM []byte, err error) {
+	parent, err := parseCertificate([]byte(ca))
+	if err != nil {
+		return nil, nil, fmt.Errorf("解析 CA 证书失败: %w", err)
+	}
+	signer, err := parsePrivateKey([]byte(caKey))
+	if err != nil {
+		return nil, nil, fmt.Errorf("解析 CA 私钥失败: %w", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	template, err := caTemplate(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encode(der, key)
+}
+
+// GenerateSignedCert 使用 GenerateCA 生成的 CA（PEM）为 domain 签发证书，有效期 90 天
+func GenerateSignedCert(ca, caKey, domain string) (certPEM, keyPEM []byte, err error) {
+	caCert, err := parseCertificate([]byte(ca))
+	if err != nil {
+		return nil, nil, fmt.Errorf("解析 CA 证书失败: %w", err)
+	}
+	signer, err := parsePrivateKey([]byte(caKey))
+	if err != nil {
+		return nil, nil, fmt.Errorf("解析 CA 私钥失败: %w", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	template, err := leafTemplate(domain, leafValidity)
+	if err != nil {
+		return nil, nil, err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, signer)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encode(der, key)
+}
+
+// caTemplate 构造名为 name 的 CA 证书模板
+func caTemplate(name string) (*x509.Certificate, error) {
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}, nil
+}
+
+// leafTemplate 构造 domain 的叶子证书模板，可同时用作服务端与客户端（mTLS）证书
+func leafTemplate(domain string, validity time.Duration) (*x509.Certificate, error) {
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	notAfter := now.Add(validity)
+	notBefore := now.Add(-time.Hour)
+	if !notAfter.After(notBefore) {
+		notBefore = notAfter.Add(-time.Hour)
+	}
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}, nil
+}
+
+// randomSerial 生成 128 位随机序列号，避免同一测试中多张证书序列号重复
+func randomSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+// encode 将证书 DER 与私钥编码为 PEM
+func encode(der []byte, key *ecdsa.PrivateKey) (certPEM, keyPEM []byte, err error) {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), nil
+}
+
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("不是 PEM 格式的证书")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("不是 PEM 格式的私钥")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("不支持的私钥类型 %T", key)
+	}
+	return signer, nil
+}
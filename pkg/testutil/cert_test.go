@@ -0,0 +1,126 @@
+package testutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+	"time"
+)
+
+func TestGenerateSelfSignedCert(t *testing.T) {
+	tests := []struct {
+		name     string
+		validity time.Duration
+		expired  bool
+	}{
+		{"有效证书", 30 * 24 * time.Hour, false},
+		{"零有效期视为已过期", 0, true},
+		{"负有效期", -time.Hour, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			certPEM, keyPEM, err := GenerateSelfSignedCert("example.com", tt.validity)
+			if err != nil {
+				t.Fatalf("GenerateSelfSignedCert() error = %v", err)
+			}
+			if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+				t.Fatalf("证书与私钥不匹配: %v", err)
+			}
+			cert, err := parseCertificate(certPEM)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cert.Subject.CommonName != "example.com" || len(cert.DNSNames) != 1 || cert.DNSNames[0] != "example.com" {
+				t.Errorf("Subject = %v, DNSNames = %v", cert.Subject, cert.DNSNames)
+			}
+			if got := time.Now().After(cert.NotAfter); got != tt.expired {
+				t.Errorf("过期 = %v, want %v (NotAfter = %v)", got, tt.expired, cert.NotAfter)
+			}
+			if !cert.NotBefore.Before(cert.NotAfter) {
+				t.Errorf("NotBefore %v 应早于 NotAfter %v", cert.NotBefore, cert.NotAfter)
+			}
+		})
+	}
+}
+
+func TestGenerateSignedCert(t *testing.T) {
+	caPEM, caKeyPEM, err := GenerateCA()
+	if err != nil {
+		t.Fatalf("GenerateCA() error = %v", err)
+	}
+	certPEM, keyPEM, err := GenerateSignedCert(string(caPEM), string(caKeyPEM), "example.com")
+	if err != nil {
+		t.Fatalf("GenerateSignedCert() error = %v", err)
+	}
+	if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		t.Fatalf("证书与私钥不匹配: %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caPEM) {
+		t.Fatal("无法加载 CA 证书")
+	}
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{DNSName: "example.com", Roots: roots}); err != nil {
+		t.Errorf("证书链验证失败: %v", err)
+	}
+
+	// 其他 CA 签发的证书不应通过验证
+	otherCA, otherKey, err := GenerateCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPEM, _, err := GenerateSignedCert(string(otherCA), string(otherKey), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := parseCertificate(otherPEM)
+	if _, err := other.Verify(x509.VerifyOptions{DNSName: "example.com", Roots: roots}); err == nil {
+		t.Error("其他 CA 签发的证书不应通过验证")
+	}
+}
+
+func TestGenerateSignedCertInvalidCA(t *testing.T) {
+	caPEM, caKeyPEM, err := GenerateCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := GenerateSignedCert("not pem", string(caKeyPEM), "example.com"); err == nil {
+		t.Error("CA 证书无效时应返回错误")
+	}
+	if _, _, err := GenerateSignedCert(string(caPEM), "not pem", "example.com"); err == nil {
+		t.Error("CA 私钥无效时应返回错误")
+	}
+}
+
+func TestGenerateIntermediateCA(t *testing.T) {
+	caPEM, caKeyPEM, err := GenerateCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	interPEM, interKeyPEM, err := GenerateIntermediateCA(string(caPEM), string(caKeyPEM), "Intermediate")
+	if err != nil {
+		t.Fatalf("GenerateIntermediateCA() error = %v", err)
+	}
+	certPEM, _, err := GenerateSignedCert(string(interPEM), string(interKeyPEM), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(caPEM)
+	intermediates := x509.NewCertPool()
+	intermediates.AppendCertsFromPEM(interPEM)
+	inter, _ := parseCertificate(interPEM)
+	if inter.Subject.CommonName != "Intermediate" || !inter.IsCA {
+		t.Errorf("中间 CA Subject = %v, IsCA = %v", inter.Subject, inter.IsCA)
+	}
+	cert, _ := parseCertificate(certPEM)
+	if _, err := cert.Verify(x509.VerifyOptions{DNSName: "example.com", Roots: roots, Intermediates: intermediates}); err != nil {
+		t.Errorf("证书链验证失败: %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+// Package wstest 提供基于真实 Hub 的 WebSocket 测试服务端
+//
+// 与 pkg/testutil 分开是因为本包依赖 pkg/websocket，
+// 而 pkg/websocket 依赖 pkg/cert，放在一起会让这两个包的测试产生循环引用
+package wstest
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/security"
+	"github.com/Catker/acmeDeliver/pkg/testutil"
+	ws "github.com/Catker/acmeDeliver/pkg/websocket"
+)
+
+// DefaultPassword 未指定 WithPassword 时使用的认证密码
+const DefaultPassword = "test-password"
+
+// MockServer 在随机端口上运行的 WebSocket 服务端（真实 Hub + HTTP 服务）
+type MockServer struct {
+	*httptest.Server
+	Hub      *ws.Hub
+	BaseDir  string // 服务端证书目录
+	Password string
+
+	cfg         ws.ServeConfig
+	tlsConfig   *tls.Config  // 非 nil 时以 TLS 启动
+	unavailable atomic.Int32 // 非 0 时直接返回该 HTTP 状态码，不升级连接
+	failNext    atomic.Int32 // 剩余需要返回 503 的连接请求数
+	attempts    atomic.Int32 // 收到的连接请求数
+}
+
+// Option 配置 MockServer
+type Option func(*MockServer)
+
+// WithPassword 设置认证密码
+func WithPassword(password string) Option {
+	return func(m *MockServer) { m.Password = password }
+}
+
+// WithServeConfig 在启动前修改 ServeConfig（如启用压缩、缩短 PongTimeout）
+func WithServeConfig(fn func(*ws.ServeConfig)) Option {
+	return func(m *MockServer) { fn(&m.cfg) }
+}
+
+// WithTLS 以 TLS 启动服务端（URL 为 https://，WSURL 为 wss://），服务端证书由 httptest 生成
+// tlsConfig 可用于设置 ClientCAs / ClientAuth 以测试 mTLS
+func WithTLS(tlsConfig *tls.Config) Option {
+	return func(m *MockServer) { m.tlsConfig = tlsConfig }
+}
+
+// NewMockServer 启动 MockServer，测试结束时自动关闭
+func NewMockServer(t *testing.T, opts ...Option) *MockServer {
+	t.Helper()
+
+	m := &MockServer{
+		Hub:      ws.NewHub(),
+		BaseDir:  t.TempDir(),
+		Password: DefaultPassword,
+	}
+	m.cfg.Whitelist = security.NewIPWhitelist("")
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.cfg.Password = m.Password
+	m.cfg.BaseDirs = []string{m.BaseDir}
+
+	go m.Hub.Run()
+	if m.tlsConfig != nil {
+		m.Server = httptest.NewUnstartedServer(http.HandlerFunc(m.serve))
+		m.Server.TLS = m.tlsConfig
+		m.Server.StartTLS()
+	} else {
+		m.Server = httptest.NewServer(http.HandlerFunc(m.serve))
+	}
+	t.Cleanup(m.Server.Close)
+	return m
+}
+
+func (m *MockServer) serve(w http.ResponseWriter, r *http.Request) {
+	m.attempts.Add(1)
+	if code := m.unavailable.Load(); code != 0 {
+		http.Error(w, http.StatusText(int(code)), int(code))
+		return
+	}
+	for n := m.failNext.Load(); n > 0; n = m.failNext.Load() {
+		if m.failNext.CompareAndSwap(n, n-1) {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+	}
+	ws.ServeWs(m.Hub, &m.cfg, w, r)
+}
+
+// WSURL 返回 ws:// 形式的服务端地址
+func (m *MockServer) WSURL() string {
+	return "ws" + strings.TrimPrefix(m.URL, "http")
+}
+
+// SetUnavailable 使后续连接请求直接返回 code（如 503），传 0 恢复正常
+func (m *MockServer) SetUnavailable(code int) {
+	m.unavailable.Store(int32(code))
+}
+
+// FailNext 使接下来的 n 个连接请求返回 503，之后恢复正常
+func (m *MockServer) FailNext(n int) {
+	m.failNext.Store(int32(n))
+}
+
+// Attempts 返回服务端收到的连接请求数
+func (m *MockServer) Attempts() int {
+	return int(m.attempts.Load())
+}
+
+// WriteFile 在证书目录下写入 domain/name
+func (m *MockServer) WriteFile(t *testing.T, domain, name string, content []byte) {
+	t.Helper()
+	dir := filepath.Join(m.BaseDir, domain)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// AddDomain 为 domain 生成一套有效证书（cert.pem、key.pem、fullchain.pem、time.log），
+// 返回证书与私钥 PEM
+func (m *MockServer) AddDomain(t *testing.T, domain string) (certPEM, keyPEM []byte) {
+	t.Helper()
+	certPEM, keyPEM, err := testutil.GenerateSelfSignedCert(domain, 90*24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.WriteFile(t, domain, "cert.pem", certPEM)
+	m.WriteFile(t, domain, "key.pem", keyPEM)
+	m.WriteFile(t, domain, "fullchain.pem", certPEM)
+	m.WriteFile(t, domain, "time.log", []byte(strconv.FormatInt(time.Now().Unix(), 10)))
+	return certPEM, keyPEM
+}
@@ -0,0 +1,93 @@
+package wstest
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/Catker/acmeDeliver/pkg/security"
+	ws "github.com/Catker/acmeDeliver/pkg/websocket"
+)
+
+func TestMockServer(t *testing.T) {
+	m := NewMockServer(t, WithPassword("secret"))
+	m.AddDomain(t, "example.com")
+	for _, name := range []string{"cert.pem", "key.pem", "fullchain.pem", "time.log"} {
+		if _, err := os.Stat(filepath.Join(m.BaseDir, "example.com", name)); err != nil {
+			t.Errorf("AddDomain 未写入 %s: %v", name, err)
+		}
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(m.WSURL(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	ts := time.Now().Unix()
+	msg, _ := ws.NewMessage(ws.MsgTypeAuth, &ws.AuthRequest{
+		ClientID:  "cli",
+		Signature: security.NewSignatureVerifier("secret").GenerateSignature(ts),
+	})
+	if err := conn.WriteJSON(msg); err != nil {
+		t.Fatal(err)
+	}
+	var resp ws.Message
+	if err := conn.ReadJSON(&resp); err != nil {
+		t.Fatal(err)
+	}
+	var result ws.AuthResponse
+	if err := resp.ParseData(&result); err != nil || !result.Success {
+		t.Errorf("使用 WithPassword 设置的密码认证失败: %+v, %v", result, err)
+	}
+}
+
+func TestMockServerUnavailable(t *testing.T) {
+	m := NewMockServer(t)
+	m.SetUnavailable(http.StatusServiceUnavailable)
+
+	_, resp, err := websocket.DefaultDialer.Dial(m.WSURL(), nil)
+	if err == nil {
+		t.Fatal("不可用时连接应失败")
+	}
+	if resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("响应 = %v, want 503", resp)
+	}
+
+	m.SetUnavailable(0)
+	conn, _, err := websocket.DefaultDialer.Dial(m.WSURL(), nil)
+	if err != nil {
+		t.Fatalf("恢复后连接失败: %v", err)
+	}
+	conn.Close()
+
+	if got := m.Attempts(); got != 2 {
+		t.Errorf("Attempts() = %d, want 2", got)
+	}
+}
+
+func TestMockServerFailNext(t *testing.T) {
+	m := NewMockServer(t)
+	m.FailNext(2)
+
+	for i := 0; i < 2; i++ {
+		_, resp, err := websocket.DefaultDialer.Dial(m.WSURL(), nil)
+		if err == nil {
+			t.Fatalf("第 %d 次连接应失败", i+1)
+		}
+		if resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("第 %d 次响应 = %v, want 503", i+1, resp)
+		}
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(m.WSURL(), nil)
+	if err != nil {
+		t.Fatalf("第 3 次连接应成功: %v", err)
+	}
+	conn.Close()
+}
@@ -0,0 +1,151 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ArchiveDirName 证书目录中存放已归档域名目录的子目录，以 "." 开头，不会被当作域名
+const ArchiveDirName = ".archive"
+
+// archiveTimeFormat 归档目录名中的时间戳，按字典序排序即按时间排序
+const archiveTimeFormat = "20060102T150405Z"
+
+// ErrDomainNotFound 证书目录中不存在该域名
+var ErrDomainNotFound = errors.New("域名目录不存在")
+
+// DirectoryCleaner 清理证书目录中已不再使用的域名目录
+// 域名目录不直接删除，而是移动到 <BaseDir>/.archive/<domain>.<时间戳>/，归档超过保留期后由 PurgeArchive 删除
+type DirectoryCleaner struct {
+	baseDir string
+	now     func() time.Time // 当前时间，测试中可替换
+}
+
+// NewDirectoryCleaner 创建 baseDir 的目录清理器
+func NewDirectoryCleaner(baseDir string) *DirectoryCleaner {
+	return &DirectoryCleaner{baseDir: baseDir, now: time.Now}
+}
+
+// ArchiveDir 返回归档目录路径
+func (c *DirectoryCleaner) ArchiveDir() string {
+	return filepath.Join(c.baseDir, ArchiveDirName)
+}
+
+// CleanOrphans 将 BaseDir 中不在 activeDomains 内的域名目录移动到归档目录，返回归档的域名（按名称排序）
+// 隐藏目录（包括归档目录本身）与普通文件不处理；单个目录归档失败时记录警告并继续，最后返回第一个错误
+func (c *DirectoryCleaner) CleanOrphans(activeDomains []string) ([]string, error) {
+	entries, err := os.ReadDir(c.baseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var archived []string
+	var firstErr error
+	for _, entry := range entries {
+		domain := entry.Name()
+		if !entry.IsDir() || strings.HasPrefix(domain, ".") || slices.Contains(activeDomains, domain) {
+			continue
+		}
+		if _, err := c.Archive(domain); err != nil {
+			slog.Warn("归档域名目录失败", "domain", domain, "error", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		archived = append(archived, domain)
+	}
+	return archived, firstErr
+}
+
+// Archive 将域名目录移动到 <BaseDir>/.archive/<domain>.<时间戳>/，返回归档后的路径
+// 同一秒内重复归档同名域名时，目录名追加 -1、-2 等序号
+// 域名目录不存在时返回 ErrDomainNotFound；拒绝包含路径分隔符、".." 或以 "." 开头的域名
+func (c *DirectoryCleaner) Archive(domain string) (string, error) {
+	if domain == "" || strings.HasPrefix(domain, ".") || strings.ContainsAny(domain, `/\`) {
+		return "", fmt.Errorf("无效的域名: %q", domain)
+	}
+	src := filepath.Join(c.baseDir, domain)
+	info, err := os.Stat(src)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrDomainNotFound
+		}
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", ErrDomainNotFound
+	}
+
+	if err := os.MkdirAll(c.ArchiveDir(), 0700); err != nil {
+		return "", fmt.Errorf("创建归档目录失败: %w", err)
+	}
+	base := filepath.Join(c.ArchiveDir(), domain+"."+c.now().UTC().Format(archiveTimeFormat))
+	dst := base
+	for n := 1; ; n++ {
+		if _, err := os.Lstat(dst); os.IsNotExist(err) {
+			break
+		} else if err != nil {
+			return "", fmt.Errorf("检查归档目录失败: %w", err)
+		}
+		dst = fmt.Sprintf("%s-%d", base, n)
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return "", fmt.Errorf("移动域名目录失败: %w", err)
+	}
+	slog.Info("域名目录已归档", "domain", domain, "archive", dst)
+	return dst, nil
+}
+
+// PurgeArchive 删除归档时间早于 maxAge 之前的归档目录，返回删除的数量
+// 归档时间取自目录名中的时间戳，无法解析的条目不处理；归档目录不存在时返回 0
+func (c *DirectoryCleaner) PurgeArchive(maxAge time.Duration) (int, error) {
+	entries, err := os.ReadDir(c.ArchiveDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	cutoff := c.now().Add(-maxAge)
+	purged := 0
+	var firstErr error
+	for _, entry := range entries {
+		archivedAt, ok := archiveTime(entry.Name())
+		if !ok || !archivedAt.Before(cutoff) {
+			continue
+		}
+		path := filepath.Join(c.ArchiveDir(), entry.Name())
+		if err := os.RemoveAll(path); err != nil {
+			slog.Warn("删除过期归档失败", "path", path, "error", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		slog.Info("已删除过期归档", "path", path, "archived_at", archivedAt)
+		purged++
+	}
+	return purged, firstErr
+}
+
+// archiveTime 从 <domain>.<时间戳>[-序号] 形式的归档目录名中解析归档时间
+func archiveTime(name string) (time.Time, bool) {
+	i := strings.LastIndex(name, ".")
+	if i <= 0 {
+		return time.Time{}, false
+	}
+	ts, _, _ := strings.Cut(name[i+1:], "-")
+	t, err := time.Parse(archiveTimeFormat, ts)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
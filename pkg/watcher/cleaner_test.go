@@ -0,0 +1,127 @@
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestCleaner 创建当前时间固定为 now 的目录清理器，并在 baseDir 中创建 domains 目录
+func newTestCleaner(t *testing.T, now time.Time, domains ...string) (*DirectoryCleaner, string) {
+	t.Helper()
+	baseDir := t.TempDir()
+	for _, domain := range domains {
+		if err := os.MkdirAll(filepath.Join(baseDir, domain), 0755); err != nil {
+			t.Fatal(err)
+		}
+		os.WriteFile(filepath.Join(baseDir, domain, "cert.pem"), []byte("cert"), 0644)
+	}
+	c := NewDirectoryCleaner(baseDir)
+	c.now = func() time.Time { return now }
+	return c, baseDir
+}
+
+func TestDirectoryCleaner_CleanOrphans(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	c, baseDir := newTestCleaner(t, now, "a.com", "b.com", "c.com", ".hidden")
+	os.WriteFile(filepath.Join(baseDir, "notes.txt"), []byte("x"), 0644)
+
+	archived, err := c.CleanOrphans([]string{"b.com"})
+	if err != nil {
+		t.Fatalf("CleanOrphans() error = %v", err)
+	}
+	if want := []string{"a.com", "c.com"}; !reflect.DeepEqual(archived, want) {
+		t.Errorf("CleanOrphans() = %v, want %v", archived, want)
+	}
+
+	for path, exists := range map[string]bool{
+		filepath.Join(baseDir, "a.com"):                                          false,
+		filepath.Join(baseDir, "b.com"):                                          true,
+		filepath.Join(baseDir, ".hidden"):                                        true,
+		filepath.Join(baseDir, "notes.txt"):                                      true,
+		filepath.Join(baseDir, ".archive", "a.com.20260102T030405Z", "cert.pem"): true,
+		filepath.Join(baseDir, ".archive", "c.com.20260102T030405Z"):             true,
+	} {
+		if _, err := os.Stat(path); (err == nil) != exists {
+			t.Errorf("%s 存在 = %v, want %v", path, err == nil, exists)
+		}
+	}
+
+	// 归档目录本身不会被再次归档
+	if archived, err := c.CleanOrphans(nil); err != nil || !reflect.DeepEqual(archived, []string{"b.com"}) {
+		t.Errorf("CleanOrphans(nil) = %v, %v, want [b.com]", archived, err)
+	}
+}
+
+func TestDirectoryCleaner_Archive(t *testing.T) {
+	c, _ := newTestCleaner(t, time.Now(), "example.com")
+
+	if _, err := c.Archive("missing.com"); !errors.Is(err, ErrDomainNotFound) {
+		t.Errorf("Archive(missing.com) error = %v, want ErrDomainNotFound", err)
+	}
+	for _, domain := range []string{"", ".archive", "../etc", `a\b`} {
+		if _, err := c.Archive(domain); err == nil || errors.Is(err, ErrDomainNotFound) {
+			t.Errorf("Archive(%q) error = %v, want 无效的域名", domain, err)
+		}
+	}
+
+	path, err := c.Archive("example.com")
+	if err != nil {
+		t.Fatalf("Archive() error = %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(path, "cert.pem")); err != nil || string(data) != "cert" {
+		t.Errorf("归档后的 cert.pem = %q, %v", data, err)
+	}
+
+	// 同一秒内再次归档同名域名时追加序号
+	for i, want := range []string{path + "-1", path + "-2"} {
+		os.MkdirAll(filepath.Join(filepath.Dir(filepath.Dir(path)), "example.com"), 0755)
+		got, err := c.Archive("example.com")
+		if err != nil {
+			t.Fatalf("第 %d 次重复归档 error = %v", i+1, err)
+		}
+		if got != want {
+			t.Errorf("第 %d 次重复归档路径 = %s, want %s", i+1, got, want)
+		}
+	}
+}
+
+func TestDirectoryCleaner_PurgeArchive(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	c, baseDir := newTestCleaner(t, now)
+	archiveDir := filepath.Join(baseDir, ".archive")
+	for _, name := range []string{
+		"old.com.20260101T000000Z",    // 59 天前
+		"old.com.20260101T000000Z-1",  // 同一秒内的重复归档
+		"recent.com.20260220T000000Z", // 9 天前
+		"unknown",                     // 无法解析，不处理
+		"bad.com.not-a-timestamp",
+	} {
+		os.MkdirAll(filepath.Join(archiveDir, name), 0755)
+	}
+
+	purged, err := c.PurgeArchive(30 * 24 * time.Hour)
+	if err != nil {
+		t.Fatalf("PurgeArchive() error = %v", err)
+	}
+	if purged != 2 {
+		t.Errorf("PurgeArchive() = %d, want 2", purged)
+	}
+	entries, _ := os.ReadDir(archiveDir)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if want := []string{"bad.com.not-a-timestamp", "recent.com.20260220T000000Z", "unknown"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("剩余归档 = %v, want %v", names, want)
+	}
+
+	// 没有归档目录时不报错
+	empty, _ := newTestCleaner(t, now)
+	if n, err := empty.PurgeArchive(time.Hour); n != 0 || err != nil {
+		t.Errorf("无归档目录时 PurgeArchive() = %d, %v", n, err)
+	}
+}
@@ -11,11 +11,13 @@ import (
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+
+	"github.com/Catker/acmeDeliver/pkg/cert"
 )
 
 // CertWatcher 证书目录监控器
 type CertWatcher struct {
-	baseDir  string
+	baseDirs []string // 证书目录，同一域名存在于多个目录时以前面的目录为准
 	watcher  *fsnotify.Watcher
 	onChange func(domain string, files map[string][]byte)
 	debounce time.Duration
@@ -28,15 +30,15 @@ type CertWatcher struct {
 	stop chan struct{}
 }
 
-// NewCertWatcher 创建新的证书监控器
-func NewCertWatcher(baseDir string, debounce time.Duration) (*CertWatcher, error) {
+// NewCertWatcher 创建新的证书监控器，监控 baseDirs 中的所有证书目录
+func NewCertWatcher(baseDirs []string, debounce time.Duration) (*CertWatcher, error) {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, err
 	}
 
 	return &CertWatcher{
-		baseDir:    baseDir,
+		baseDirs:   baseDirs,
 		watcher:    watcher,
 		debounce:   debounce,
 		lastUpdate: make(map[string]time.Time),
@@ -44,6 +46,13 @@ func NewCertWatcher(baseDir string, debounce time.Duration) (*CertWatcher, error
 	}, nil
 }
 
+// SetDebounce 更新防抖时间（支持配置热重载）
+func (w *CertWatcher) SetDebounce(debounce time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.debounce = debounce
+}
+
 // OnChange 设置证书变更回调
 func (w *CertWatcher) OnChange(callback func(domain string, files map[string][]byte)) {
 	w.onChange = callback
@@ -51,19 +60,22 @@ func (w *CertWatcher) OnChange(callback func(domain string, files map[string][]b
 
 // Start 开始监控
 func (w *CertWatcher) Start() error {
-	// 添加基础目录
-	if err := w.addWatchDir(w.baseDir); err != nil {
-		return err
-	}
+	for _, baseDir := range w.baseDirs {
+		// 添加基础目录
+		if err := w.addWatchDir(baseDir); err != nil {
+			return err
+		}
 
-	// 添加所有现有的域名目录
-	entries, err := os.ReadDir(w.baseDir)
-	if err != nil {
-		slog.Warn("读取证书目录失败", "dir", w.baseDir, "error", err)
-	} else {
+		// 添加所有现有的域名目录
+		entries, err := os.ReadDir(baseDir)
+		if err != nil {
+			slog.Warn("读取证书目录失败", "dir", baseDir, "error", err)
+			continue
+		}
 		for _, entry := range entries {
-			if entry.IsDir() {
-				domainPath := filepath.Join(w.baseDir, entry.Name())
+			// 隐藏目录（如 DirectoryCleaner 的归档目录）不是域名目录
+			if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+				domainPath := filepath.Join(baseDir, entry.Name())
 				if err := w.addWatchDir(domainPath); err != nil {
 					slog.Warn("添加域名目录监控失败", "dir", domainPath, "error", err)
 				}
@@ -74,7 +86,7 @@ func (w *CertWatcher) Start() error {
 	// 启动事件处理协程
 	go w.eventLoop()
 
-	slog.Info("证书目录监控已启动", "baseDir", w.baseDir, "debounce", w.debounce)
+	slog.Info("证书目录监控已启动", "baseDirs", w.baseDirs, "debounce", w.debounce)
 	return nil
 }
 
@@ -135,12 +147,15 @@ func (w *CertWatcher) handleEvent(event fsnotify.Event, pending map[string]time.
 	path := event.Name
 
 	// 判断是否是域名目录下的文件
-	relPath, err := filepath.Rel(w.baseDir, path)
-	if err != nil {
+	baseDir, relPath, ok := w.locate(path)
+	if !ok {
 		return
 	}
 
 	parts := strings.Split(relPath, string(filepath.Separator))
+	if strings.HasPrefix(parts[0], ".") {
+		return
+	}
 	if len(parts) == 1 {
 		// baseDir 下的直接子项只可能是：
 		// 1. 新建域名目录：需要补挂 watcher，并触发一次目录扫描
@@ -158,6 +173,9 @@ func (w *CertWatcher) handleEvent(event fsnotify.Event, pending map[string]time.
 		if err := w.addWatchDir(path); err != nil {
 			slog.Warn("添加新域名目录监控失败", "dir", path, "error", err)
 		}
+		if w.shadowed(baseDir, domain) {
+			return
+		}
 
 		pending[domain] = time.Now()
 		slog.Debug("检测到新域名目录", "domain", domain, "dir", path)
@@ -165,24 +183,53 @@ func (w *CertWatcher) handleEvent(event fsnotify.Event, pending map[string]time.
 	}
 
 	domain := parts[0]
+	if w.shadowed(baseDir, domain) {
+		return
+	}
 	pending[domain] = time.Now()
 	slog.Debug("检测到证书文件变化", "domain", domain, "file", filepath.Base(path))
 }
 
+// locate 返回 path 所在的证书目录及其相对路径，不在任何证书目录内时 ok 为 false
+func (w *CertWatcher) locate(path string) (baseDir, relPath string, ok bool) {
+	for _, dir := range w.baseDirs {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		return dir, rel, true
+	}
+	return "", "", false
+}
+
+// shadowed 判断 baseDir 中的域名是否被排在前面的证书目录中的同名域名覆盖，被覆盖的域名变化不触发推送
+func (w *CertWatcher) shadowed(baseDir, domain string) bool {
+	dir, ok := cert.FindDomainDir(w.baseDirs, domain)
+	if !ok || dir == filepath.Join(baseDir, domain) {
+		return false
+	}
+	slog.Debug("域名已被优先级更高的证书目录覆盖，忽略变化", "domain", domain, "dir", baseDir, "effective", dir)
+	return true
+}
+
 // processPending 处理待处理的域名更新
 func (w *CertWatcher) processPending(pending map[string]time.Time) {
 	now := time.Now()
 
+	w.mu.Lock()
+	debounce := w.debounce
+	w.mu.Unlock()
+
 	for domain, lastEvent := range pending {
 		// 检查是否超过防抖时间
-		if now.Sub(lastEvent) < w.debounce {
+		if now.Sub(lastEvent) < debounce {
 			continue
 		}
 
 		// 检查是否在全局防抖时间内已处理过
 		w.mu.Lock()
 		if lastProcess, ok := w.lastUpdate[domain]; ok {
-			if now.Sub(lastProcess) < w.debounce {
+			if now.Sub(lastProcess) < debounce {
 				w.mu.Unlock()
 				delete(pending, domain)
 				continue
@@ -209,9 +256,12 @@ func (w *CertWatcher) processPending(pending map[string]time.Time) {
 	}
 }
 
-// readCertFiles 读取域名的所有证书文件
+// readCertFiles 读取域名的所有证书文件，域名存在于多个证书目录时读取排在前面的目录
 func (w *CertWatcher) readCertFiles(domain string) (map[string][]byte, error) {
-	domainPath := filepath.Join(w.baseDir, domain)
+	domainPath, ok := cert.FindDomainDir(w.baseDirs, domain)
+	if !ok {
+		return nil, os.ErrNotExist
+	}
 
 	entries, err := os.ReadDir(domainPath)
 	if err != nil {
@@ -226,7 +276,7 @@ func (w *CertWatcher) readCertFiles(domain string) (map[string][]byte, error) {
 
 		name := entry.Name()
 		// 只读取证书相关文件
-		if !isCertFile(name) {
+		if !isCertFile(name, domain) {
 			continue
 		}
 
@@ -242,8 +292,14 @@ func (w *CertWatcher) readCertFiles(domain string) (map[string][]byte, error) {
 	return files, nil
 }
 
-// isCertFile 判断是否是证书相关文件
-func isCertFile(name string) bool {
+// isCertFile 判断是否是证书相关文件，包括 cert.SetFileNames 配置的域名文件名
+func isCertFile(name, domain string) bool {
+	for _, configured := range cert.DomainFileNames(domain).List() {
+		if name == configured {
+			return true
+		}
+	}
+
 	certFiles := []string{
 		"cert.pem",
 		"key.pem",
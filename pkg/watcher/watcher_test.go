@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+
+	"github.com/Catker/acmeDeliver/pkg/cert"
 )
 
 func TestIsCertFile(t *testing.T) {
@@ -43,7 +45,7 @@ func TestIsCertFile(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := isCertFile(tt.name)
+			got := isCertFile(tt.name, "example.com")
 			if got != tt.want {
 				t.Errorf("isCertFile(%q) = %v, want %v", tt.name, got, tt.want)
 			}
@@ -54,14 +56,14 @@ func TestIsCertFile(t *testing.T) {
 func TestNewCertWatcher(t *testing.T) {
 	tmpDir := t.TempDir()
 
-	watcher, err := NewCertWatcher(tmpDir, 2*time.Second)
+	watcher, err := NewCertWatcher([]string{tmpDir}, 2*time.Second)
 	if err != nil {
 		t.Fatalf("NewCertWatcher() error = %v", err)
 	}
 	defer watcher.Stop()
 
-	if watcher.baseDir != tmpDir {
-		t.Errorf("watcher.baseDir = %q, want %q", watcher.baseDir, tmpDir)
+	if len(watcher.baseDirs) != 1 || watcher.baseDirs[0] != tmpDir {
+		t.Errorf("watcher.baseDirs = %q, want [%q]", watcher.baseDirs, tmpDir)
 	}
 	if watcher.debounce != 2*time.Second {
 		t.Errorf("watcher.debounce = %v, want 2s", watcher.debounce)
@@ -103,7 +105,7 @@ func TestCertWatcher_ReadCertFiles(t *testing.T) {
 		}
 	}
 
-	watcher, err := NewCertWatcher(tmpDir, time.Second)
+	watcher, err := NewCertWatcher([]string{tmpDir}, time.Second)
 	if err != nil {
 		t.Fatalf("NewCertWatcher() error = %v", err)
 	}
@@ -146,7 +148,7 @@ func TestCertWatcher_ReadCertFiles_EmptyDir(t *testing.T) {
 		t.Fatalf("创建域名目录失败: %v", err)
 	}
 
-	watcher, err := NewCertWatcher(tmpDir, time.Second)
+	watcher, err := NewCertWatcher([]string{tmpDir}, time.Second)
 	if err != nil {
 		t.Fatalf("NewCertWatcher() error = %v", err)
 	}
@@ -165,7 +167,7 @@ func TestCertWatcher_ReadCertFiles_EmptyDir(t *testing.T) {
 func TestCertWatcher_ReadCertFiles_NonExistDir(t *testing.T) {
 	tmpDir := t.TempDir()
 
-	watcher, err := NewCertWatcher(tmpDir, time.Second)
+	watcher, err := NewCertWatcher([]string{tmpDir}, time.Second)
 	if err != nil {
 		t.Fatalf("NewCertWatcher() error = %v", err)
 	}
@@ -180,7 +182,7 @@ func TestCertWatcher_ReadCertFiles_NonExistDir(t *testing.T) {
 func TestCertWatcher_OnChange(t *testing.T) {
 	tmpDir := t.TempDir()
 
-	watcher, err := NewCertWatcher(tmpDir, time.Second)
+	watcher, err := NewCertWatcher([]string{tmpDir}, time.Second)
 	if err != nil {
 		t.Fatalf("NewCertWatcher() error = %v", err)
 	}
@@ -198,7 +200,7 @@ func TestCertWatcher_OnChange(t *testing.T) {
 func TestCertWatcher_HandleEvent_NewDomainDirAddsWatch(t *testing.T) {
 	tmpDir := t.TempDir()
 
-	watcher, err := NewCertWatcher(tmpDir, time.Second)
+	watcher, err := NewCertWatcher([]string{tmpDir}, time.Second)
 	if err != nil {
 		t.Fatalf("NewCertWatcher() error = %v", err)
 	}
@@ -232,7 +234,7 @@ func TestCertWatcher_HandleEvent_NewDomainDirAddsWatch(t *testing.T) {
 func TestCertWatcher_HandleEvent_IgnoresBaseDirFile(t *testing.T) {
 	tmpDir := t.TempDir()
 
-	watcher, err := NewCertWatcher(tmpDir, time.Second)
+	watcher, err := NewCertWatcher([]string{tmpDir}, time.Second)
 	if err != nil {
 		t.Fatalf("NewCertWatcher() error = %v", err)
 	}
@@ -254,6 +256,57 @@ func TestCertWatcher_HandleEvent_IgnoresBaseDirFile(t *testing.T) {
 	}
 }
 
+func TestCertWatcher_MultipleBaseDirs(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	write := func(baseDir, domain, content string) string {
+		t.Helper()
+		dir := filepath.Join(baseDir, domain)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, "cert.pem")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	write(first, "both.com", "first")
+	shadowedPath := write(second, "both.com", "second")
+	onlySecondPath := write(second, "only-second.com", "only-second")
+
+	watcher, err := NewCertWatcher([]string{first, second}, time.Second)
+	if err != nil {
+		t.Fatalf("NewCertWatcher() error = %v", err)
+	}
+	defer watcher.Stop()
+
+	// 两个目录都有时读取第一个目录
+	files, err := watcher.readCertFiles("both.com")
+	if err != nil || string(files["cert.pem"]) != "first" {
+		t.Errorf("readCertFiles(both.com) = %q, %v, want first", files["cert.pem"], err)
+	}
+	files, err = watcher.readCertFiles("only-second.com")
+	if err != nil || string(files["cert.pem"]) != "only-second" {
+		t.Errorf("readCertFiles(only-second.com) = %q, %v", files["cert.pem"], err)
+	}
+
+	pending := make(map[string]time.Time)
+	watcher.handleEvent(fsnotify.Event{Name: onlySecondPath, Op: fsnotify.Write}, pending)
+	if _, ok := pending["only-second.com"]; !ok {
+		t.Error("第二个目录中独有域名的变化应触发推送")
+	}
+
+	// 被第一个目录覆盖的同名域名变化不触发推送
+	watcher.handleEvent(fsnotify.Event{Name: shadowedPath, Op: fsnotify.Write}, pending)
+	if _, ok := pending["both.com"]; ok {
+		t.Error("被覆盖的域名变化不应触发推送")
+	}
+	watcher.handleEvent(fsnotify.Event{Name: filepath.Join(first, "both.com", "cert.pem"), Op: fsnotify.Write}, pending)
+	if _, ok := pending["both.com"]; !ok {
+		t.Error("第一个目录中域名的变化应触发推送")
+	}
+}
+
 func containsWatch(watches []string, target string) bool {
 	for _, watch := range watches {
 		if watch == target {
@@ -262,3 +315,15 @@ func containsWatch(watches []string, target string) bool {
 	}
 	return false
 }
+
+func TestIsCertFileConfiguredNames(t *testing.T) {
+	cert.SetFileNames(cert.FileNames{TimeLog: "{domain}.stamp"})
+	t.Cleanup(func() { cert.SetFileNames(cert.FileNames{}) })
+
+	if !isCertFile("example.com.stamp", "example.com") {
+		t.Error("配置的时间戳文件应视为证书文件")
+	}
+	if isCertFile("other.com.stamp", "example.com") {
+		t.Error("其他域名的时间戳文件不应视为证书文件")
+	}
+}
@@ -1,14 +1,17 @@
 package websocket
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net"
 	"net/http"
 	"os"
 	"path/filepath"
-	"strconv"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -23,32 +26,55 @@ const (
 	// 写入等待超时
 	writeWait = 10 * time.Second
 
-	// 读取下一个 pong 消息的等待时间
-	pongWait = 120 * time.Second
+	// 默认的 pong 等待时间（对应配置 pong_timeout），超过此时间无响应视为连接失效
+	DefaultPongTimeout = 90 * time.Second
 
-	// 发送 ping 的周期，必须小于 pongWait
-	pingPeriod = (pongWait * 9) / 10
+	// DefaultMaxMessageSize 默认的单条消息大小上限（对应配置 max_message_size），证书链较长或 SAN 较多时可能较大
+	DefaultMaxMessageSize = 10 * 1024 * 1024 // 10MB
 
-	// 最大消息大小
-	maxMessageSize = 10 * 1024 * 1024 // 10MB (证书文件可能较大)
+	// 状态请求中 CRL 检查的单次 HTTP 超时
+	crlFetchTimeout = 10 * time.Second
 )
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true // 直接允许所有来源（已有 IP 白名单保护）
-	},
+// newUpgrader 创建 WebSocket 升级器
+// compression 为 true 时协商 permessage-deflate 压缩
+func newUpgrader(compression bool) *websocket.Upgrader {
+	return &websocket.Upgrader{
+		ReadBufferSize:    1024,
+		WriteBufferSize:   1024,
+		EnableCompression: compression,
+		CheckOrigin: func(r *http.Request) bool {
+			return true // 直接允许所有来源（已有 IP 白名单保护）
+		},
+	}
+}
+
+// ServeConfig WebSocket 服务端连接参数
+type ServeConfig struct {
+	Password         string                // 认证密码
+	BaseDirs         []string              // 证书目录，按顺序查找域名，同名域名以前面的目录为准
+	Whitelist        *security.IPWhitelist // IP 白名单
+	TrustProxy       bool                  // 是否信任 X-Forwarded-For/X-Real-IP 头部
+	Compression      bool                  // 是否启用 permessage-deflate 压缩
+	CompressionLevel int                   // 压缩级别（-2~9，0 表示使用库默认值）
+	PongTimeout      time.Duration         // 最长可接受的静默时间，0 表示使用 DefaultPongTimeout
+	MaxMessageSize   int64                 // 单条消息大小上限（字节），0 表示使用 DefaultMaxMessageSize
+
+	// DisableLegacyAuth 拒绝 V1 签名（sha256(password + timestamp)），只接受 HMAC 签名
+	DisableLegacyAuth bool
 }
 
 // Client 表示一个 WebSocket 客户端连接
 type Client struct {
-	ID      string // 客户端标识
-	hub     *Hub   // 所属的 Hub
-	conn    *websocket.Conn
-	send    chan *Message // 发送消息缓冲区
-	domains []string      // 订阅的域名列表
-	baseDir string        // 证书目录（用于响应 CLI 请求）
+	ID       string // 客户端标识
+	hub      *Hub   // 所属的 Hub
+	conn     *websocket.Conn
+	send     chan *Message // 发送消息缓冲区
+	domains  []string      // 订阅的域名列表
+	baseDirs []string      // 证书目录（用于响应 CLI 请求）
+
+	pongWait       time.Duration // 等待 pong 的最长时间，ping 周期为其 9/10
+	maxMessageSize int64         // 单条消息大小上限，超过时断开连接（close 1009）
 
 	// 状态查询字段
 	RemoteIP    string    // 客户端 IP 地址
@@ -56,46 +82,76 @@ type Client struct {
 
 	authenticated bool       // 是否已认证
 	mu            sync.Mutex // 保护 conn 的并发写入
+
+	logger *slog.Logger // 携带 client_id 与 remote_ip 的日志记录器
 }
 
-// NewClient 创建新的客户端连接
-func NewClient(hub *Hub, conn *websocket.Conn) *Client {
+// NewClient 创建新的客户端连接，remoteIP 为客户端地址
+func NewClient(hub *Hub, conn *websocket.Conn, remoteIP string) *Client {
 	return &Client{
-		hub:  hub,
-		conn: conn,
-		send: make(chan *Message, 256),
+		hub:            hub,
+		conn:           conn,
+		send:           make(chan *Message, 256),
+		pongWait:       DefaultPongTimeout,
+		maxMessageSize: DefaultMaxMessageSize,
+		RemoteIP:       remoteIP,
+		logger:         clientLogger("", remoteIP),
+	}
+}
+
+// clientLogger 返回携带客户端标识的日志记录器，认证前 id 为空时不记录 client_id
+func clientLogger(id, remoteIP string) *slog.Logger {
+	if id == "" {
+		return slog.Default().With("remote_ip", remoteIP)
 	}
+	return slog.Default().With("client_id", id, "remote_ip", remoteIP)
+}
+
+// setID 设置认证后的客户端标识，并更新日志记录器
+func (c *Client) setID(id string) {
+	c.ID = id
+	c.logger = clientLogger(id, c.RemoteIP)
 }
 
 // ServeWs 处理 WebSocket 升级请求
-// trustProxy 控制是否信任 X-Forwarded-For/X-Real-IP 头部
-func ServeWs(hub *Hub, password, baseDir string, whitelist *security.IPWhitelist, trustProxy bool, w http.ResponseWriter, r *http.Request) {
+func ServeWs(hub *Hub, cfg *ServeConfig, w http.ResponseWriter, r *http.Request) {
 	// IP 白名单验证（在 WebSocket 升级之前）
-	clientIP := extractClientIP(r, trustProxy)
-	if !whitelist.IsAllowed(clientIP) {
+	clientIP := ClientIP(r, cfg.TrustProxy)
+	if !cfg.Whitelist.IsAllowed(clientIP) {
 		slog.Warn("IP 白名单拒绝连接", "ip", clientIP)
 		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := newUpgrader(cfg.Compression).Upgrade(w, r, nil)
 	if err != nil {
 		slog.Error("WebSocket 升级失败", "error", err)
 		return
 	}
 
-	slog.Debug("WebSocket 连接已建立", "ip", clientIP)
+	if cfg.Compression && cfg.CompressionLevel != 0 {
+		if err := conn.SetCompressionLevel(cfg.CompressionLevel); err != nil {
+			slog.Warn("设置压缩级别失败，使用默认级别", "level", cfg.CompressionLevel, "error", err)
+		}
+	}
 
-	client := NewClient(hub, conn)
-	client.baseDir = baseDir
-	client.RemoteIP = clientIP
+	client := NewClient(hub, conn, clientIP)
+	client.logger.Debug("WebSocket 连接已建立")
+	client.baseDirs = cfg.BaseDirs
 	client.ConnectedAt = time.Now()
+	if cfg.PongTimeout > 0 {
+		client.pongWait = cfg.PongTimeout
+	}
+	if cfg.MaxMessageSize > 0 {
+		client.maxMessageSize = cfg.MaxMessageSize
+	}
 
 	// 创建认证处理器
 	authHandler := &AuthHandler{
-		client:   client,
-		verifier: security.NewSignatureVerifier(password),
-		hub:      hub,
+		client:        client,
+		verifier:      security.NewSignatureVerifier(cfg.Password),
+		hub:           hub,
+		disableLegacy: cfg.DisableLegacyAuth,
 	}
 
 	// 启动读写协程
@@ -103,11 +159,11 @@ func ServeWs(hub *Hub, password, baseDir string, whitelist *security.IPWhitelist
 	go client.readPump(authHandler)
 }
 
-// extractClientIP 从请求中提取客户端真实 IP
+// ClientIP 从请求中提取客户端真实 IP，WebSocket 与 REST API 的白名单校验共用
 // trustProxy 控制是否信任反向代理头部 (X-Forwarded-For, X-Real-IP)
 // 安全注意：仅当服务部署在可信反向代理后时才应设置 trustProxy=true
 // 否则攻击者可伪造这些头部绕过 IP 白名单
-func extractClientIP(r *http.Request, trustProxy bool) string {
+func ClientIP(r *http.Request, trustProxy bool) string {
 	// 始终先获取直连 IP（这是唯一可信的来源）
 	remoteIP := extractRemoteAddr(r)
 
@@ -147,75 +203,134 @@ func extractRemoteAddr(r *http.Request) string {
 
 // AuthHandler 处理客户端认证
 type AuthHandler struct {
-	client   *Client
-	verifier *security.SignatureVerifier
-	hub      *Hub
+	client        *Client
+	verifier      *security.SignatureVerifier
+	hub           *Hub
+	disableLegacy bool // 拒绝 V1 签名
+}
+
+// verify 按请求声明的签名版本验证签名，V1 签名在 disableLegacy 时拒绝，否则记录弃用警告
+func (h *AuthHandler) verify(verifier *security.SignatureVerifier, req *AuthRequest, timestamp int64) (bool, string) {
+	version, errMsg := verifier.VerifyVersioned(req.Signature, timestamp, req.SignatureVersion)
+	switch version {
+	case 0:
+		return false, errMsg
+	case security.SignatureV1:
+		if h.disableLegacy {
+			h.client.logger.Warn("拒绝旧版签名认证（disable_legacy_auth）", "client_id", req.ClientID)
+			return false, "服务端已禁用旧版签名（disable_legacy_auth），请升级客户端"
+		}
+		h.client.logger.Warn("客户端使用已弃用的 sha256(password + timestamp) 签名认证，请升级客户端", "client_id", req.ClientID)
+	}
+	return true, ""
 }
 
 // HandleAuth 处理认证请求
 func (h *AuthHandler) HandleAuth(msg *Message) bool {
 	var req AuthRequest
 	if err := msg.ParseData(&req); err != nil {
-		h.sendAuthResult(false, "无效的认证数据")
+		h.sendAuthResult(msg.RequestID, false, "无效的认证数据")
 		return false
 	}
 
-	// 使用统一的签名验证器
-	ok, errMsg := h.verifier.VerifySignature(req.Signature, msg.Timestamp)
+	// 密钥轮换期间已认证连接使用新密钥重新认证，不重复注册
+	rotationVerifier := h.hub.rotationVerifier()
+	if h.client.authenticated && rotationVerifier != nil {
+		if ok, errMsg := h.verify(rotationVerifier, &req, msg.Timestamp); !ok {
+			h.sendAuthResult(msg.RequestID, false, errMsg)
+			return false
+		}
+		h.hub.completeReauth(h.client)
+		h.sendAuthResult(msg.RequestID, true, "已使用新密钥重新认证")
+		return true
+	}
+
+	// 使用统一的签名验证器；轮换期间新密钥同样有效
+	ok, errMsg := h.verify(h.verifier, &req, msg.Timestamp)
+	rotated := false
+	if !ok && rotationVerifier != nil {
+		if ok, _ = h.verify(rotationVerifier, &req, msg.Timestamp); ok {
+			rotated = true
+		}
+	}
 	if !ok {
-		h.sendAuthResult(false, errMsg)
+		h.sendAuthResult(msg.RequestID, false, errMsg)
+		return false
+	}
+
+	if err := h.hub.checkSubscription(req.Domains); err != nil {
+		h.client.logger.Warn("拒绝订阅通配符域名的客户端", "client_id", req.ClientID, "error", err)
+		h.sendAuthResult(msg.RequestID, false, err.Error())
 		return false
 	}
 
 	// 认证成功
-	h.client.ID = req.ClientID
+	h.client.setID(req.ClientID)
 	h.client.domains = req.Domains
-	h.client.authenticated = true
 
-	// 注册到 Hub
-	h.hub.Register(h.client)
+	// 注册到 Hub（可能因客户端 ID 重复被拒绝）
+	if err := h.hub.Register(h.client); err != nil {
+		h.sendAuthResult(msg.RequestID, false, err.Error())
+		return false
+	}
+	h.client.authenticated = true
+	if rotated {
+		h.hub.completeReauth(h.client)
+	}
 
-	h.sendAuthResult(true, "认证成功")
+	h.sendAuthResult(msg.RequestID, true, "认证成功")
 	return true
 }
 
-func (h *AuthHandler) sendAuthResult(success bool, message string) {
+func (h *AuthHandler) sendAuthResult(requestID string, success bool, message string) {
 	resp := &AuthResponse{
-		Success: success,
-		Message: message,
+		Success:          success,
+		Message:          message,
+		ServerTime:       time.Now().Unix(),
+		SignatureVersion: security.SignatureV2,
 	}
 	msg, _ := NewMessage(MsgTypeAuthResult, resp)
+	msg.RequestID = requestID
 	h.client.sendMessage(msg)
 }
 
 // readPump 从 WebSocket 读取消息
 func (c *Client) readPump(authHandler *AuthHandler) {
+	var readErr error
 	defer func() {
+		switch {
+		case errors.Is(readErr, websocket.ErrReadLimit):
+			c.logger.Warn("客户端消息超过 max_message_size，已断开连接（close 1009）", "limit", c.maxMessageSize)
+		case websocket.IsCloseError(readErr, websocket.CloseMessageTooBig):
+			c.logger.Warn("客户端拒绝了超过其 max_message_size 的消息，请调大客户端的 max_message_size")
+		}
 		if c.authenticated {
-			c.hub.Unregister(c)
+			c.hub.Unregister(c, readErr)
+		} else {
+			reason, _ := classifyDisconnect(readErr)
+			c.logger.Debug("未认证连接已断开", "reason", reason, "error", readErr)
 		}
 		c.conn.Close()
 	}()
 
-	c.conn.SetReadLimit(maxMessageSize)
-	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetReadLimit(c.maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(c.pongWait))
 	c.conn.SetPongHandler(func(string) error {
-		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		c.conn.SetReadDeadline(time.Now().Add(c.pongWait))
 		return nil
 	})
 
 	for {
 		_, data, err := c.conn.ReadMessage()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				slog.Warn("WebSocket 读取错误", "client_id", c.ID, "error", err)
-			}
+			// 断开原因由 Hub 注销时统一记录
+			readErr = err
 			break
 		}
 
 		var msg Message
 		if err := json.Unmarshal(data, &msg); err != nil {
-			slog.Warn("无效的消息格式", "error", err)
+			c.logger.Warn("无效的消息格式", "error", err)
 			continue
 		}
 
@@ -225,6 +340,11 @@ func (c *Client) readPump(authHandler *AuthHandler) {
 
 // handleMessage 处理收到的消息
 func (c *Client) handleMessage(msg *Message, authHandler *AuthHandler) {
+	if !versionSupported(msg.Version) {
+		c.sendVersionError(msg.RequestID, msg.Version)
+		return
+	}
+
 	switch msg.Type {
 	case MsgTypeAuth:
 		authHandler.HandleAuth(msg)
@@ -238,10 +358,17 @@ func (c *Client) handleMessage(msg *Message, authHandler *AuthHandler) {
 		// 处理证书接收确认
 		var ack CertAck
 		if err := msg.ParseData(&ack); err == nil {
-			slog.Debug("收到证书确认",
-				"client_id", c.ID,
-				"domain", ack.Domain,
-				"success", ack.Success)
+			if ack.Success {
+				c.logger.Debug("收到证书确认",
+					"domain", ack.Domain,
+					"success", ack.Success,
+					"message", ack.Message)
+			} else {
+				// 部署失败或 reload 失败后已回滚
+				c.logger.Warn("客户端部署证书失败",
+					"domain", ack.Domain,
+					"message", ack.Message)
+			}
 		}
 
 	case MsgTypeSubscribe:
@@ -257,16 +384,26 @@ func (c *Client) handleMessage(msg *Message, authHandler *AuthHandler) {
 		// 处理订阅更新
 		var req SubscribeRequest
 		if err := msg.ParseData(&req); err != nil {
-			slog.Warn("无效的订阅请求数据", "client_id", c.ID, "error", err)
+			c.logger.Warn("无效的订阅请求数据", "error", err)
+			return
+		}
+		result := c.hub.UpdateSubscription(c, req.Domains)
+		if len(result.Rejected) > 0 {
+			c.logger.Warn("拒绝部分订阅", "accepted", result.Accepted, "rejected", result.Rejected)
+		}
+		resp, err := NewMessage(MsgTypeSubscribeResult, result)
+		if err != nil {
+			c.logger.Error("创建订阅结果消息失败", "error", err)
 			return
 		}
-		c.hub.UpdateSubscription(c, req.Domains)
-		slog.Debug("客户端订阅更新请求已处理", "client_id", c.ID, "domains", req.Domains)
+		resp.RequestID = msg.RequestID
+		c.sendMessage(resp)
+		c.logger.Debug("客户端订阅更新请求已处理", "domains", req.Domains)
 
 	case MsgTypeCertRequest:
 		// 处理证书请求（CLI 模式）
 		if !c.authenticated {
-			c.sendAuthError()
+			c.sendAuthError(msg.RequestID)
 			return
 		}
 		c.handleCertRequest(msg)
@@ -274,15 +411,23 @@ func (c *Client) handleMessage(msg *Message, authHandler *AuthHandler) {
 	case MsgTypeStatusRequest:
 		// 处理状态请求（CLI 模式）
 		if !c.authenticated {
-			c.sendAuthError()
+			c.sendAuthError(msg.RequestID)
 			return
 		}
 		c.handleStatusRequest(msg)
 
+	case MsgTypeListRequest:
+		// 处理域名列表请求（CLI 模式）
+		if !c.authenticated {
+			c.sendAuthError(msg.RequestID)
+			return
+		}
+		c.handleListRequest(msg)
+
 	case MsgTypeSyncRequest:
 		// 处理证书同步请求（Daemon 模式）
 		if !c.authenticated {
-			c.sendAuthError()
+			c.sendAuthError(msg.RequestID)
 			return
 		}
 		c.handleSyncRequest(msg)
@@ -290,14 +435,15 @@ func (c *Client) handleMessage(msg *Message, authHandler *AuthHandler) {
 	default:
 		if !c.authenticated {
 			// 未认证的客户端只能发送认证请求
-			c.sendAuthError()
+			c.sendAuthError(msg.RequestID)
 		}
 	}
 }
 
 // writePump 向 WebSocket 写入消息
 func (c *Client) writePump() {
-	ticker := time.NewTicker(pingPeriod)
+	// 发送 ping 的周期必须小于 pongWait
+	ticker := time.NewTicker(c.pongWait * 9 / 10)
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
@@ -315,7 +461,7 @@ func (c *Client) writePump() {
 
 			data, err := json.Marshal(msg)
 			if err != nil {
-				slog.Error("消息序列化失败", "error", err)
+				c.logger.Error("消息序列化失败", "error", err)
 				continue
 			}
 
@@ -324,7 +470,7 @@ func (c *Client) writePump() {
 			c.mu.Unlock()
 
 			if err != nil {
-				slog.Warn("WebSocket 写入失败", "client_id", c.ID, "error", err)
+				c.logger.Warn("WebSocket 写入失败", "error", err)
 				return
 			}
 
@@ -340,6 +486,36 @@ func (c *Client) writePump() {
 	}
 }
 
+// closeWithReason 发送携带状态码与原因的关闭帧，原因超出关闭帧长度限制时截断
+// WriteControl 可与 writePump 的写入并发调用
+func (c *Client) closeWithReason(code int, reason string) {
+	if c.conn == nil {
+		return
+	}
+	payload := websocket.FormatCloseMessage(code, truncateCloseReason(reason))
+	if err := c.conn.WriteControl(websocket.CloseMessage, payload, time.Now().Add(writeWait)); err != nil {
+		c.logger.Debug("发送关闭帧失败", "error", err)
+	}
+}
+
+// maxCloseReasonLen 关闭帧原因的最大字节数（控制帧载荷 125 字节减去 2 字节状态码）
+const maxCloseReasonLen = 123
+
+// truncateCloseReason 按 UTF-8 字符边界将原因截断到 maxCloseReasonLen 字节以内
+func truncateCloseReason(reason string) string {
+	if len(reason) <= maxCloseReasonLen {
+		return reason
+	}
+	end := 0
+	for i := range reason {
+		if i > maxCloseReasonLen {
+			break
+		}
+		end = i
+	}
+	return reason[:end]
+}
+
 // sendMessage 发送消息到客户端
 func (c *Client) sendMessage(msg *Message) {
 	data, err := json.Marshal(msg)
@@ -355,11 +531,24 @@ func (c *Client) sendMessage(msg *Message) {
 }
 
 // sendAuthError 发送认证错误响应
-func (c *Client) sendAuthError() {
+func (c *Client) sendAuthError(requestID string) {
 	errMsg, _ := NewMessage(MsgTypeError, &ErrorData{
 		Code:    401,
 		Message: "请先进行认证",
 	})
+	errMsg.RequestID = requestID
+	c.sendMessage(errMsg)
+}
+
+// sendVersionError 发送协议版本不支持的错误响应，并附带服务端支持的版本
+func (c *Client) sendVersionError(requestID string, version int) {
+	c.logger.Warn("客户端协议版本不受支持", "version", version, "supported", SupportedProtocolVersions)
+	errMsg, _ := NewMessage(MsgTypeError, &ErrorData{
+		Code:              ErrCodeVersionNotSupported,
+		Message:           fmt.Sprintf("VersionNotSupported: 不支持协议版本 %d", version),
+		SupportedVersions: SupportedProtocolVersions,
+	})
+	errMsg.RequestID = requestID
 	c.sendMessage(errMsg)
 }
 
@@ -367,77 +556,114 @@ func (c *Client) sendAuthError() {
 func (c *Client) handleCertRequest(msg *Message) {
 	var req CertRequest
 	if err := msg.ParseData(&req); err != nil {
-		c.sendCertResponse(req.Domain, nil, 0, "无效的请求数据")
+		c.sendCertResponse(msg.RequestID, req.Domain, nil, 0, "无效的请求数据")
 		return
 	}
 
 	if req.Domain == "" {
-		c.sendCertResponse("", nil, 0, "域名不能为空")
+		c.sendCertResponse(msg.RequestID, "", nil, 0, "域名不能为空")
 		return
 	}
 
-	slog.Debug("处理证书请求", "client_id", c.ID, "domain", req.Domain, "force", req.Force)
+	c.logger.Debug("处理证书请求", "domain", req.Domain, "force", req.Force)
 
-	domainDir, err := safeDomainDir(c.baseDir, req.Domain)
-	if err != nil {
-		c.sendCertResponse(req.Domain, nil, 0, "域名非法")
+	domainDir, err := FindDomainDir(c.baseDirs, req.Domain)
+	if errors.Is(err, os.ErrNotExist) {
+		c.sendCertResponse(msg.RequestID, req.Domain, nil, 0, "域名不存在")
 		return
 	}
-
-	// 读取证书文件
-	if _, err := os.Stat(domainDir); os.IsNotExist(err) {
-		c.sendCertResponse(req.Domain, nil, 0, "域名不存在")
+	if err != nil {
+		c.sendCertResponse(msg.RequestID, req.Domain, nil, 0, "域名非法")
 		return
 	}
 
 	// 读取所有证书文件
-	files := make(map[string][]byte)
-	certFiles := []string{"cert.pem", "key.pem", "fullchain.pem", "time.log"}
-
-	for _, filename := range certFiles {
-		filePath := filepath.Join(domainDir, filename)
-		content, err := os.ReadFile(filePath)
-		if err == nil {
-			files[filename] = content
-		}
-	}
-
+	files, names := readCertFiles(domainDir, req.Domain)
 	if len(files) == 0 {
-		c.sendCertResponse(req.Domain, nil, 0, "没有可用的证书文件")
+		c.sendCertResponse(msg.RequestID, req.Domain, nil, 0, "没有可用的证书文件")
 		return
 	}
 
 	// 获取时间戳
 	var timestamp int64
-	if timeContent, ok := files["time.log"]; ok {
-		// 解析时间戳
-		ts := string(timeContent)
-		ts = ts[:min(len(ts), 10)] // 只取前10位
-		if t, err := strconv.ParseInt(ts, 10, 64); err == nil {
-			timestamp = t
-		}
+	if t, ok := cert.ParseTimeLog(files[names.TimeLog]); ok {
+		timestamp = t
+	}
+
+	// 客户端已是最新：只返回时间戳，避免重复传输证书与私钥
+	// 校验和始终返回，客户端据此判断本地文件是否与服务端一致
+	resp := &CertResponse{Domain: req.Domain, Timestamp: timestamp, Checksums: FileChecksums(files), FileNames: NonDefaultFileNames(names)}
+	delete(resp.Checksums, names.TimeLog)
+	if !req.Force && req.Timestamp > 0 && timestamp > 0 && req.Timestamp >= timestamp {
+		c.replyCert(msg.RequestID, resp)
+		c.logger.Debug("客户端证书已是最新", "domain", req.Domain, "timestamp", timestamp)
+		return
 	}
 
-	c.sendCertResponse(req.Domain, files, timestamp, "")
-	slog.Info("证书请求已处理", "client_id", c.ID, "domain", req.Domain, "files", len(files))
+	resp.Files = files
+	c.replyCert(msg.RequestID, resp)
+	c.logger.Info("证书请求已处理", "domain", req.Domain, "files", len(files))
 }
 
 // sendCertResponse 发送证书响应
-func (c *Client) sendCertResponse(domain string, files map[string][]byte, timestamp int64, errMsg string) {
-	resp := &CertResponse{
+func (c *Client) sendCertResponse(requestID, domain string, files map[string][]byte, timestamp int64, errMsg string) {
+	c.replyCert(requestID, &CertResponse{
 		Domain:    domain,
 		Files:     files,
 		Timestamp: timestamp,
 		Error:     errMsg,
-	}
+	})
+}
+
+// replyCert 发送构造好的证书响应
+func (c *Client) replyCert(requestID string, resp *CertResponse) {
 	msg, _ := NewMessage(MsgTypeCertResponse, resp)
+	msg.RequestID = requestID
 	c.sendMessage(msg)
 }
 
+// readCertFiles 按 cert.DomainDirFileNames 读取域名目录中的证书文件，返回 实际文件名 -> 内容 与使用的文件名
+func readCertFiles(domainDir, domain string) (map[string][]byte, cert.FileNames) {
+	names := cert.DomainDirFileNames(domainDir, domain)
+	files := make(map[string][]byte)
+	for _, filename := range names.List() {
+		content, err := os.ReadFile(filepath.Join(domainDir, filename))
+		if err == nil {
+			files[filename] = content
+		}
+	}
+	return files, names
+}
+
+// NonDefaultFileNames 文件名与标准文件名不同时返回其副本，供消息携带；否则返回 nil，消息与旧版本保持一致
+func NonDefaultFileNames(names cert.FileNames) *cert.FileNames {
+	if names.IsDefault() {
+		return nil
+	}
+	return &names
+}
+
+// FileChecksums 计算证书文件的 SHA-256（十六进制），time.log 不参与计算
+func FileChecksums(files map[string][]byte) map[string]string {
+	sums := make(map[string]string, len(files))
+	for name, content := range files {
+		if name == cert.TimeLogFileName {
+			continue
+		}
+		sum := sha256.Sum256(content)
+		sums[name] = hex.EncodeToString(sum[:])
+	}
+	return sums
+}
+
 // handleStatusRequest 处理状态请求（CLI 模式）
 // 返回服务器运行状态：在线客户端 + 证书状态
 func (c *Client) handleStatusRequest(msg *Message) {
-	slog.Debug("处理状态请求", "client_id", c.ID)
+	var req StatusRequest
+	if err := msg.ParseData(&req); err != nil {
+		c.logger.Warn("无效的状态请求数据", "error", err)
+	}
+	c.logger.Debug("处理状态请求", "check_crl", req.CheckCRL)
 
 	// 收集客户端状态
 	clientStatus := c.hub.GetClientStatus()
@@ -452,14 +678,23 @@ func (c *Client) handleStatusRequest(msg *Message) {
 	}
 
 	// 收集证书状态
-	domains := cert.CollectAllDomainStatus(c.baseDir)
+	domains := cert.CollectAllDomainStatus(c.baseDirs)
+	if !req.CheckCRL {
+		c.sendStatusResponse(msg.RequestID, clients, domains, "")
+		c.logger.Info("状态请求已处理", "clients", len(clients), "domains", len(domains))
+		return
+	}
 
-	c.sendStatusResponse(clients, domains, "")
-	slog.Info("状态请求已处理", "client_id", c.ID, "clients", len(clients), "domains", len(domains))
+	// CRL 需逐个下载，在独立 goroutine 中执行，避免阻塞读循环导致心跳超时
+	go func() {
+		cert.CheckDomainStatusCRL(c.baseDirs, domains, crlFetchTimeout)
+		c.sendStatusResponse(msg.RequestID, clients, domains, "")
+		c.logger.Info("状态请求已处理（含 CRL 检查）", "clients", len(clients), "domains", len(domains))
+	}()
 }
 
 // sendStatusResponse 发送状态响应
-func (c *Client) sendStatusResponse(clients []ClientStatusInfo, domains []DomainStatus, errMsg string) {
+func (c *Client) sendStatusResponse(requestID string, clients []ClientStatusInfo, domains []DomainStatus, errMsg string) {
 	resp := &StatusResponse{
 		GeneratedAt: time.Now().Unix(),
 		Clients:     clients,
@@ -467,19 +702,82 @@ func (c *Client) sendStatusResponse(clients []ClientStatusInfo, domains []Domain
 		Error:       errMsg,
 	}
 	msg, _ := NewMessage(MsgTypeStatusResponse, resp)
+	msg.RequestID = requestID
 	c.sendMessage(msg)
 }
 
+// handleListRequest 处理域名列表请求（CLI 模式）
+func (c *Client) handleListRequest(msg *Message) {
+	resp := &ListResponse{Domains: []DomainEntry{}}
+	domains, err := ListDomainEntries(c.baseDirs)
+	if err != nil {
+		c.logger.Warn("读取证书目录失败", "error", err)
+		resp.Error = "读取证书目录失败"
+	} else {
+		resp.Domains = domains
+	}
+
+	reply, _ := NewMessage(MsgTypeListResponse, resp)
+	reply.RequestID = msg.RequestID
+	c.sendMessage(reply)
+	c.logger.Info("域名列表请求已处理", "domains", len(resp.Domains))
+}
+
+// ListDomainEntries 列出各证书目录下的域名目录及其时间戳与文件（跳过隐藏目录与隐藏文件），结果按域名排序
+// 同一域名存在于多个目录时只列出排在前面的目录中的文件；任一目录无法读取时返回错误
+func ListDomainEntries(baseDirs []string) ([]DomainEntry, error) {
+	domains := make([]DomainEntry, 0)
+	seen := make(map[string]bool)
+	for _, baseDir := range baseDirs {
+		entries, err := os.ReadDir(baseDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || seen[entry.Name()] {
+				continue
+			}
+			seen[entry.Name()] = true
+			if item, ok := domainEntry(baseDir, entry.Name()); ok {
+				domains = append(domains, item)
+			}
+		}
+	}
+	sort.Slice(domains, func(i, j int) bool { return domains[i].Domain < domains[j].Domain })
+	return domains, nil
+}
+
+// domainEntry 读取单个域名目录的时间戳与文件列表
+func domainEntry(baseDir, domain string) (DomainEntry, bool) {
+	domainDir, err := SafeDomainDir(baseDir, domain)
+	if err != nil {
+		return DomainEntry{}, false
+	}
+	files, err := os.ReadDir(domainDir)
+	if err != nil {
+		slog.Warn("读取域名目录失败", "domain", domain, "error", err)
+		return DomainEntry{}, false
+	}
+
+	item := DomainEntry{Domain: domain, Timestamp: readTimestamp(domainDir), Files: []string{}}
+	for _, f := range files {
+		if f.Type().IsRegular() && !strings.HasPrefix(f.Name(), ".") {
+			item.Files = append(item.Files, f.Name())
+		}
+	}
+	return item, true
+}
+
 // handleSyncRequest 处理证书同步请求（Daemon 模式）
 // 比对客户端提交的时间戳，推送需要更新的证书
 func (c *Client) handleSyncRequest(msg *Message) {
 	var req SyncRequest
 	if err := msg.ParseData(&req); err != nil {
-		slog.Warn("无效的同步请求数据", "client_id", c.ID, "error", err)
+		c.logger.Warn("无效的同步请求数据", "error", err)
 		return
 	}
 
-	slog.Info("处理证书同步请求", "client_id", c.ID, "domains", len(req.Timestamps))
+	c.logger.Info("处理证书同步请求", "domains", len(req.Timestamps))
 
 	pushedCount := 0
 
@@ -509,36 +807,48 @@ func (c *Client) handleSyncRequest(msg *Message) {
 		}
 	}
 
-	slog.Info("证书同步请求处理完成", "client_id", c.ID, "pushed", pushedCount)
+	c.sendSyncResult(pushedCount)
+
+	// daemon 会定时发送同步请求，没有需要推送的证书时只记录 DEBUG 日志
+	if pushedCount == 0 {
+		c.logger.Debug("证书同步请求处理完成，没有需要推送的证书")
+		return
+	}
+	c.logger.Info("证书同步请求处理完成", "pushed", pushedCount)
+}
+
+// sendSyncResult 在本次同步的推送之后发送同步结果
+// 与推送使用同一发送队列以保证顺序，队列已满时放弃（客户端按超时处理）
+func (c *Client) sendSyncResult(pushed int) {
+	msg, err := NewMessage(MsgTypeSyncResult, &SyncResult{Pushed: pushed})
+	if err != nil {
+		return
+	}
+	select {
+	case c.send <- msg:
+	default:
+		c.logger.Warn("发送同步结果失败：发送缓冲区已满")
+	}
 }
 
 // syncAllDomains 同步所有域名（用于全局订阅 "*"）
 func (c *Client) syncAllDomains(clientTimestamps map[string]int64) int {
-	entries, err := os.ReadDir(c.baseDir)
+	entries, err := ListDomainEntries(c.baseDirs)
 	if err != nil {
-		slog.Warn("读取证书目录失败", "error", err)
+		c.logger.Warn("读取证书目录失败", "error", err)
 		return 0
 	}
 
 	pushedCount := 0
 	for _, entry := range entries {
-		if !entry.IsDir() {
+		// 服务端无时间戳的域名跳过
+		if entry.Timestamp == 0 {
 			continue
 		}
-		domain := entry.Name()
 
-		// 读取服务端时间戳
-		serverTS := c.readServerTimestamp(domain)
-		if serverTS == 0 {
-			continue
-		}
-
-		// 获取客户端时间戳（不存在则为 0）
-		clientTS := clientTimestamps[domain]
-
-		// 比对时间戳
-		if serverTS > clientTS {
-			if c.pushCertToDomain(domain) {
+		// 比对时间戳（客户端不存在则为 0）
+		if entry.Timestamp > clientTimestamps[entry.Domain] {
+			if c.pushCertToDomain(entry.Domain) {
 				pushedCount++
 			}
 		}
@@ -547,91 +857,96 @@ func (c *Client) syncAllDomains(clientTimestamps map[string]int64) int {
 	return pushedCount
 }
 
-// readServerTimestamp 读取服务端指定域名的时间戳
+// readServerTimestamp 读取服务端指定域名的时间戳，域名不存在时返回 0
 func (c *Client) readServerTimestamp(domain string) int64 {
-	domainDir, err := safeDomainDir(c.baseDir, domain)
+	domainDir, err := FindDomainDir(c.baseDirs, domain)
 	if err != nil {
-		slog.Warn("非法域名，跳过时间戳读取", "domain", domain)
+		if !errors.Is(err, os.ErrNotExist) {
+			c.logger.Warn("非法域名，跳过时间戳读取", "domain", domain)
+		}
 		return 0
 	}
-	timeLogPath := filepath.Join(domainDir, "time.log")
-	content, err := os.ReadFile(timeLogPath)
+	return readTimestamp(domainDir)
+}
+
+// readTimestamp 读取域名目录中 time.log 的时间戳，不存在或格式错误时返回 0
+func readTimestamp(domainDir string) int64 {
+	content, err := os.ReadFile(filepath.Join(domainDir, "time.log"))
 	if err != nil {
 		return 0
 	}
-
-	ts := strings.TrimSpace(string(content))
-	if len(ts) > 10 {
-		ts = ts[:10]
-	}
-
-	if t, err := strconv.ParseInt(ts, 10, 64); err == nil {
-		return t
-	}
-	return 0
+	t, _ := cert.ParseTimeLog(content)
+	return t
 }
 
 // pushCertToDomain 推送指定域名的证书给当前客户端
 func (c *Client) pushCertToDomain(domain string) bool {
-	domainDir, err := safeDomainDir(c.baseDir, domain)
+	data, err := LoadCertPushData(c.baseDirs, domain)
 	if err != nil {
-		slog.Warn("非法域名，跳过证书推送", "domain", domain)
+		c.logger.Warn("读取证书失败，跳过证书推送", "domain", domain, "error", err)
 		return false
 	}
 
-	// 读取证书文件
-	files := make(map[string][]byte)
-	certFiles := []string{"cert.pem", "key.pem", "fullchain.pem", "time.log"}
+	msg, err := NewMessage(MsgTypeCertPush, data)
+	if err != nil {
+		return false
+	}
 
-	for _, filename := range certFiles {
-		filePath := filepath.Join(domainDir, filename)
-		content, err := os.ReadFile(filePath)
-		if err == nil {
-			files[filename] = content
-		}
+	// 发送消息
+	select {
+	case c.send <- msg:
+		c.logger.Debug("同步推送证书", "domain", domain)
+		return true
+	default:
+		c.logger.Warn("同步推送证书失败：发送缓冲区已满", "domain", domain)
+		return false
+	}
+}
+
+// LoadCertPushData 按 FindDomainDir 的优先级读取指定域名的证书文件，构建推送数据
+// 供同步推送和管理员手动推送共用
+func LoadCertPushData(baseDirs []string, domain string) (*CertPushData, error) {
+	domainDir, err := FindDomainDir(baseDirs, domain)
+	if err != nil {
+		return nil, err
 	}
 
+	// 读取证书文件
+	files, names := readCertFiles(domainDir, domain)
 	if len(files) == 0 {
-		return false
+		return nil, errors.New("没有可用的证书文件")
 	}
 
 	// 获取时间戳
 	var timestamp int64
-	if timeContent, ok := files["time.log"]; ok {
-		ts := strings.TrimSpace(string(timeContent))
-		if len(ts) > 10 {
-			ts = ts[:10]
-		}
-		if t, err := strconv.ParseInt(ts, 10, 64); err == nil {
-			timestamp = t
-		}
+	if t, ok := cert.ParseTimeLog(files[names.TimeLog]); ok {
+		timestamp = t
 	}
 
-	// 构建推送消息
-	data := &CertPushData{
+	return &CertPushData{
 		Domain:    domain,
 		Files:     files,
 		Timestamp: timestamp,
-	}
+		FileNames: NonDefaultFileNames(names),
+	}, nil
+}
 
-	msg, err := NewMessage(MsgTypeCertPush, data)
-	if err != nil {
-		return false
+// FindDomainDir 校验域名并按顺序在各证书目录中查找，返回第一个存在的域名目录
+// 同一域名存在于多个目录时以排在前面的目录为准；均不存在时返回 os.ErrNotExist
+func FindDomainDir(baseDirs []string, domain string) (string, error) {
+	for _, baseDir := range baseDirs {
+		if _, err := SafeDomainDir(baseDir, domain); err != nil {
+			return "", err
+		}
 	}
-
-	// 发送消息
-	select {
-	case c.send <- msg:
-		slog.Debug("同步推送证书", "client_id", c.ID, "domain", domain)
-		return true
-	default:
-		slog.Warn("同步推送证书失败：发送缓冲区已满", "client_id", c.ID, "domain", domain)
-		return false
+	if dir, ok := cert.FindDomainDir(baseDirs, domain); ok {
+		return dir, nil
 	}
+	return "", os.ErrNotExist
 }
 
-// safeDomainDir 校验域名并返回安全的域名目录
-func safeDomainDir(baseDir, domain string) (string, error) {
+// SafeDomainDir 校验域名并返回安全的域名目录（禁止路径分隔符与路径穿越）
+func SafeDomainDir(baseDir, domain string) (string, error) {
 	if domain == "" {
 		return "", errors.New("empty domain")
 	}
@@ -0,0 +1,610 @@
+package websocket
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/Catker/acmeDeliver/pkg/cert"
+	"github.com/Catker/acmeDeliver/pkg/security"
+)
+
+// writeDomainFiles 在 baseDir 下创建域名目录及文件（文件内容即文件名）
+func writeDomainFiles(t *testing.T, baseDir, domain string, files ...string) {
+	t.Helper()
+	dir := filepath.Join(baseDir, domain)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(dir, f), []byte(f), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestListDomainEntries(t *testing.T) {
+	baseDir := t.TempDir()
+	writeDomainFiles(t, baseDir, "example.com", "cert.pem", "key.pem", "fullchain.pem", ".lock")
+	os.WriteFile(filepath.Join(baseDir, "example.com", "time.log"), []byte("1700000000\n"), 0644)
+	writeDomainFiles(t, baseDir, "empty.org")
+	writeDomainFiles(t, baseDir, ".git", "HEAD")
+	os.WriteFile(filepath.Join(baseDir, "README"), []byte("x"), 0644)
+
+	got, err := ListDomainEntries([]string{baseDir})
+	if err != nil {
+		t.Fatalf("ListDomainEntries() error = %v", err)
+	}
+	want := []DomainEntry{
+		{Domain: "empty.org", Files: []string{}},
+		{Domain: "example.com", Timestamp: 1700000000, Files: []string{"cert.pem", "fullchain.pem", "key.pem", "time.log"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListDomainEntries() = %+v, want %+v", got, want)
+	}
+
+	if _, err := ListDomainEntries([]string{baseDir, filepath.Join(baseDir, "missing")}); err == nil {
+		t.Error("目录不存在时应返回错误")
+	}
+}
+
+// newMultiBaseDirs 创建两个证书目录：only-second.com 只在第二个目录中，both.com 两个目录中都有
+func newMultiBaseDirs(t *testing.T) []string {
+	t.Helper()
+	first, second := t.TempDir(), t.TempDir()
+	writeDomainFiles(t, first, "both.com", "cert.pem")
+	writeDomainFiles(t, second, "both.com", "cert.pem", "key.pem")
+	writeDomainFiles(t, second, "only-second.com", "cert.pem")
+	os.WriteFile(filepath.Join(first, "both.com", "time.log"), []byte("1700000001"), 0644)
+	os.WriteFile(filepath.Join(second, "both.com", "time.log"), []byte("1700000002"), 0644)
+	return []string{first, second}
+}
+
+func TestFindDomainDir(t *testing.T) {
+	baseDirs := newMultiBaseDirs(t)
+
+	tests := []struct {
+		name    string
+		domain  string
+		want    string
+		wantErr error
+	}{
+		{"只在第二个目录", "only-second.com", filepath.Join(baseDirs[1], "only-second.com"), nil},
+		{"两个目录都有时以第一个为准", "both.com", filepath.Join(baseDirs[0], "both.com"), nil},
+		{"不存在", "missing.com", "", os.ErrNotExist},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FindDomainDir(baseDirs, tt.domain)
+			if got != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Errorf("FindDomainDir(%q) = %q, %v, want %q, %v", tt.domain, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := FindDomainDir(baseDirs, "../both.com"); err == nil || errors.Is(err, os.ErrNotExist) {
+		t.Errorf("路径穿越应返回校验错误: %v", err)
+	}
+}
+
+func TestListDomainEntriesMultipleDirs(t *testing.T) {
+	baseDirs := newMultiBaseDirs(t)
+
+	got, err := ListDomainEntries(baseDirs)
+	if err != nil {
+		t.Fatalf("ListDomainEntries() error = %v", err)
+	}
+	want := []DomainEntry{
+		{Domain: "both.com", Timestamp: 1700000001, Files: []string{"cert.pem", "time.log"}},
+		{Domain: "only-second.com", Files: []string{"cert.pem"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListDomainEntries() = %+v, want %+v", got, want)
+	}
+
+	data, err := LoadCertPushData(baseDirs, "both.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Timestamp != 1700000001 || data.Files["key.pem"] != nil {
+		t.Errorf("both.com 应读取第一个目录: %+v", data)
+	}
+	if data, err := LoadCertPushData(baseDirs, "only-second.com"); err != nil || string(data.Files["cert.pem"]) != "cert.pem" {
+		t.Errorf("only-second.com = %+v, %v", data, err)
+	}
+}
+
+func TestLoadCertPushDataDetectsLayout(t *testing.T) {
+	baseDir := t.TempDir()
+	writeDomainFiles(t, baseDir, "example.com", "cert.pem", "privkey.pem", "chain.pem", "fullchain.pem")
+
+	data, err := LoadCertPushData([]string{baseDir}, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.FileNames == nil || data.FileNames.Key != "privkey.pem" {
+		t.Fatalf("certbot 布局应携带文件名映射: %+v", data.FileNames)
+	}
+	files := cert.ToStandard(data.FileNames, data.Files)
+	if string(files["key.pem"]) != "privkey.pem" || string(files["fullchain.pem"]) != "fullchain.pem" {
+		t.Errorf("映射为标准文件名后 = %v", files)
+	}
+}
+
+func TestHandleListRequest(t *testing.T) {
+	baseDir := t.TempDir()
+	writeDomainFiles(t, baseDir, "example.com", "cert.pem")
+
+	hub := NewHub()
+	go hub.Run()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeWs(hub, &ServeConfig{Password: "test-password", BaseDirs: []string{baseDir}, Whitelist: security.NewIPWhitelist("")}, w, r)
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	// roundTrip 发送消息并读取下一条响应
+	roundTrip := func(msgType, requestID string, data interface{}) *Message {
+		t.Helper()
+		msg, _ := NewMessage(msgType, data)
+		msg.RequestID = requestID
+		if err := conn.WriteJSON(msg); err != nil {
+			t.Fatal(err)
+		}
+		var resp Message
+		if err := conn.ReadJSON(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return &resp
+	}
+
+	// 未认证时拒绝
+	if resp := roundTrip(MsgTypeListRequest, "req-0", nil); resp.Type != MsgTypeError {
+		t.Fatalf("未认证时响应类型 = %s, want %s", resp.Type, MsgTypeError)
+	}
+
+	ts := time.Now().Unix()
+	auth := &AuthRequest{ClientID: "cli", Signature: security.NewSignatureVerifier("test-password").GenerateSignature(ts)}
+	if resp := roundTrip(MsgTypeAuth, "req-1", auth); resp.Type != MsgTypeAuthResult {
+		t.Fatalf("认证响应类型 = %s", resp.Type)
+	}
+
+	resp := roundTrip(MsgTypeListRequest, "req-2", nil)
+	if resp.Type != MsgTypeListResponse || resp.RequestID != "req-2" {
+		t.Fatalf("响应 = %s/%s, want %s/req-2", resp.Type, resp.RequestID, MsgTypeListResponse)
+	}
+	var list ListResponse
+	if err := resp.ParseData(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Domains) != 1 || list.Domains[0].Domain != "example.com" || list.Error != "" {
+		t.Errorf("ListResponse = %+v", list)
+	}
+}
+
+func TestHandleMessageVersion(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeWs(hub, &ServeConfig{Password: "test-password", BaseDirs: []string{t.TempDir()}, Whitelist: security.NewIPWhitelist("")}, w, r)
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	send := func(version int, requestID string) *Message {
+		t.Helper()
+		ts := time.Now().Unix()
+		msg, _ := NewMessage(MsgTypeAuth, &AuthRequest{ClientID: "cli", Signature: security.NewSignatureVerifier("test-password").GenerateSignature(ts)})
+		msg.Timestamp = ts
+		msg.RequestID = requestID
+		msg.Version = version
+		if err := conn.WriteJSON(msg); err != nil {
+			t.Fatal(err)
+		}
+		var resp Message
+		if err := conn.ReadJSON(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return &resp
+	}
+
+	// 高于服务端支持的版本被拒绝，并返回支持的版本列表
+	resp := send(CurrentProtocolVersion+1, "req-1")
+	var errData ErrorData
+	if err := resp.ParseData(&errData); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Type != MsgTypeError || resp.RequestID != "req-1" || errData.Code != ErrCodeVersionNotSupported ||
+		!reflect.DeepEqual(errData.SupportedVersions, SupportedProtocolVersions) {
+		t.Errorf("高版本响应 = %s/%s %+v", resp.Type, resp.RequestID, errData)
+	}
+
+	// 未携带版本的旧客户端与当前版本均可认证
+	for i, version := range []int{0, CurrentProtocolVersion} {
+		if resp := send(version, fmt.Sprintf("req-ok-%d", i)); resp.Type != MsgTypeAuthResult {
+			t.Errorf("version %d 响应类型 = %s, want %s", version, resp.Type, MsgTypeAuthResult)
+		}
+	}
+}
+
+func TestKickSendsCloseReason(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeWs(hub, &ServeConfig{Password: "test-password", BaseDirs: []string{t.TempDir()}, Whitelist: security.NewIPWhitelist("")}, w, r)
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	ts := time.Now().Unix()
+	msg, _ := NewMessage(MsgTypeAuth, &AuthRequest{ClientID: "cli", Signature: security.NewSignatureVerifier("test-password").GenerateSignature(ts)})
+	msg.Timestamp = ts
+	if err := conn.WriteJSON(msg); err != nil {
+		t.Fatal(err)
+	}
+	var resp Message
+	if err := conn.ReadJSON(&resp); err != nil || resp.Type != MsgTypeAuthResult {
+		t.Fatalf("认证响应 = %s, error = %v", resp.Type, err)
+	}
+
+	if !hub.Kick("cli", "compromised") {
+		t.Fatal("Kick() = false, want true")
+	}
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.ClosePolicyViolation || closeErr.Text != "compromised" {
+		t.Fatalf("ReadMessage() error = %v, want 关闭帧 %d compromised", err, websocket.ClosePolicyViolation)
+	}
+	if len(hub.GetClientStatus()) != 0 {
+		t.Error("被断开的客户端仍在 Hub 中")
+	}
+}
+
+func TestMaxMessageSize(t *testing.T) {
+	const limit = 1024
+	hub := NewHub()
+	go hub.Run()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeWs(hub, &ServeConfig{BaseDirs: []string{t.TempDir()}, Whitelist: security.NewIPWhitelist(""), MaxMessageSize: limit}, w, r)
+	}))
+	defer server.Close()
+
+	// ping 的 data 不影响处理，用于填充消息大小
+	sendPing := func(padding int) error {
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+		msg, _ := NewMessage(MsgTypePing, strings.Repeat("x", padding))
+		if err := conn.WriteJSON(msg); err != nil {
+			t.Fatal(err)
+		}
+		var resp Message
+		if err := conn.ReadJSON(&resp); err != nil {
+			return err
+		}
+		if resp.Type != MsgTypePong {
+			t.Errorf("响应类型 = %s, want %s", resp.Type, MsgTypePong)
+		}
+		return nil
+	}
+
+	if err := sendPing(limit / 2); err != nil {
+		t.Errorf("未超限的消息被拒绝: %v", err)
+	}
+	err := sendPing(limit * 2)
+	if !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+		t.Errorf("超限消息的读取错误 = %v, want 关闭帧 %d", err, websocket.CloseMessageTooBig)
+	}
+}
+
+func TestFileChecksums(t *testing.T) {
+	got := FileChecksums(map[string][]byte{
+		"cert.pem": []byte("abc"),
+		"time.log": []byte("1700000000"),
+	})
+	want := map[string]string{"cert.pem": "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FileChecksums() = %v, want %v", got, want)
+	}
+}
+
+func TestWildcardSubscribe(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeWs(hub, &ServeConfig{Password: "test-password", BaseDirs: []string{t.TempDir()}, Whitelist: security.NewIPWhitelist("")}, w, r)
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	roundTrip := func(msgType string, data interface{}) *Message {
+		t.Helper()
+		ts := time.Now().Unix()
+		msg, _ := NewMessage(msgType, data)
+		msg.Timestamp = ts
+		if err := conn.WriteJSON(msg); err != nil {
+			t.Fatal(err)
+		}
+		var resp Message
+		if err := conn.ReadJSON(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return &resp
+	}
+	auth := func(domains ...string) *AuthResponse {
+		t.Helper()
+		ts := time.Now().Unix()
+		req := &AuthRequest{ClientID: "cli", Domains: domains, Signature: security.NewSignatureVerifier("test-password").GenerateSignature(ts)}
+		var result AuthResponse
+		if err := roundTrip(MsgTypeAuth, req).ParseData(&result); err != nil {
+			t.Fatal(err)
+		}
+		return &result
+	}
+
+	// 默认拒绝订阅 "*" 的认证
+	if result := auth("example.com", "*"); result.Success || !strings.Contains(result.Message, "allow_wildcard_subscribe") {
+		t.Fatalf("默认应拒绝订阅 *: %+v", result)
+	}
+	// "**.com" 匹配顶级域名下任意层级的域名，与 "*" 一样需要开启
+	if result := auth("example.com", "**.com"); result.Success || !strings.Contains(result.Message, "allow_wildcard_subscribe") {
+		t.Fatalf("默认应拒绝订阅 **.com: %+v", result)
+	}
+	if len(hub.GetClientStatus()) != 0 {
+		t.Fatal("被拒绝的客户端不应注册到 Hub")
+	}
+
+	hub.SetAllowWildcardSubscribe(true)
+	if result := auth("*"); !result.Success {
+		t.Fatalf("允许后订阅 * 应认证成功: %+v", result)
+	}
+	if subs := hub.GetSubscribers("any.example.org"); len(subs) != 1 {
+		t.Errorf("全局订阅者数量 = %d, want 1", len(subs))
+	}
+
+	// 关闭后只订阅 "*" 的更新被全部拒绝，保留原有订阅
+	hub.SetAllowWildcardSubscribe(false)
+	subscribe := func(domains ...string) *SubscribeResult {
+		t.Helper()
+		resp := roundTrip(MsgTypeSubscribe, &SubscribeRequest{Domains: domains})
+		if resp.Type != MsgTypeSubscribeResult {
+			t.Fatalf("订阅响应类型 = %s, want %s", resp.Type, MsgTypeSubscribeResult)
+		}
+		var result SubscribeResult
+		if err := resp.ParseData(&result); err != nil {
+			t.Fatal(err)
+		}
+		return &result
+	}
+	result := subscribe("*")
+	if len(result.Accepted) != 0 || len(result.Rejected) != 1 || !strings.Contains(result.Rejected[0].Reason, "allow_wildcard_subscribe") {
+		t.Fatalf("订阅 * 的结果 = %+v, want 被拒绝", result)
+	}
+	if status := hub.GetClientStatus(); len(status) != 1 || !reflect.DeepEqual(status[0].Domains, []string{"*"}) {
+		t.Errorf("全部拒绝后订阅 = %+v, want 保留 [*]", status)
+	}
+	if result := subscribe("**.com"); len(result.Accepted) != 0 || len(result.Rejected) != 1 {
+		t.Fatalf("订阅 **.com 的结果 = %+v, want 被拒绝", result)
+	}
+
+	// 部分被拒绝时只有被接受的域名生效
+	result = subscribe("example.com", "*", "*.example.org", "bad_domain")
+	if want := []string{"example.com", "*.example.org"}; !reflect.DeepEqual(result.Accepted, want) {
+		t.Errorf("Accepted = %v, want %v", result.Accepted, want)
+	}
+	var rejected []string
+	for _, r := range result.Rejected {
+		rejected = append(rejected, r.Domain)
+	}
+	if want := []string{"*", "bad_domain"}; !reflect.DeepEqual(rejected, want) {
+		t.Errorf("Rejected = %v, want %v", rejected, want)
+	}
+	if status := hub.GetClientStatus(); len(status) != 1 || !reflect.DeepEqual(status[0].Domains, result.Accepted) {
+		t.Errorf("部分拒绝后订阅 = %+v, want %v", status, result.Accepted)
+	}
+	if subs := hub.GetSubscribers("other.com"); len(subs) != 0 {
+		t.Errorf("被拒绝的 * 不应生效, 订阅者数量 = %d", len(subs))
+	}
+}
+
+func TestAuthResultIncludesServerTime(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeWs(hub, &ServeConfig{Password: "test-password", BaseDirs: []string{t.TempDir()}, Whitelist: security.NewIPWhitelist("")}, w, r)
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	// 客户端时钟慢 2 分钟，认证失败时同样返回服务端时间
+	ts := time.Now().Add(-2 * time.Minute).Unix()
+	msg, _ := NewMessage(MsgTypeAuth, &AuthRequest{ClientID: "cli", Signature: security.NewSignatureVerifier("test-password").GenerateSignature(ts)})
+	msg.Timestamp = ts
+	if err := conn.WriteJSON(msg); err != nil {
+		t.Fatal(err)
+	}
+	var resp Message
+	if err := conn.ReadJSON(&resp); err != nil {
+		t.Fatal(err)
+	}
+	var result AuthResponse
+	if err := resp.ParseData(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Success || result.Message != "时间戳已过期" {
+		t.Fatalf("认证结果 = %+v, want 时间戳已过期", result)
+	}
+	if now := time.Now().Unix(); result.ServerTime < now-5 || result.ServerTime > now {
+		t.Errorf("ServerTime = %d, want 接近 %d", result.ServerTime, now)
+	}
+}
+
+func TestAuthSignatureVersions(t *testing.T) {
+	verifier := security.NewSignatureVerifier("test-password")
+	tests := []struct {
+		name          string
+		version       int
+		sign          func(int64) string
+		disableLegacy bool
+		want          bool
+	}{
+		{"HMAC 签名", security.SignatureV2, verifier.GenerateHMAC, false, true},
+		{"旧客户端 V1 签名", 0, verifier.GenerateSignature, false, true},
+		{"禁用旧版签名时 HMAC 签名", security.SignatureV2, verifier.GenerateHMAC, true, true},
+		{"禁用旧版签名时 V1 签名", 0, verifier.GenerateSignature, true, false},
+		{"声明 V2 但使用 V1 签名", security.SignatureV2, verifier.GenerateSignature, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewHub()
+			go hub.Run()
+			cfg := &ServeConfig{Password: "test-password", BaseDirs: []string{t.TempDir()}, Whitelist: security.NewIPWhitelist(""), DisableLegacyAuth: tt.disableLegacy}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ServeWs(hub, cfg, w, r)
+			}))
+			defer server.Close()
+
+			conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+			ts := time.Now().Unix()
+			msg, _ := NewMessage(MsgTypeAuth, &AuthRequest{ClientID: "cli", Signature: tt.sign(ts), SignatureVersion: tt.version})
+			msg.Timestamp = ts
+			if err := conn.WriteJSON(msg); err != nil {
+				t.Fatal(err)
+			}
+			var resp Message
+			if err := conn.ReadJSON(&resp); err != nil {
+				t.Fatal(err)
+			}
+			var result AuthResponse
+			if err := resp.ParseData(&result); err != nil {
+				t.Fatal(err)
+			}
+			if result.Success != tt.want {
+				t.Errorf("认证结果 = %+v, want success = %v", result, tt.want)
+			}
+			if result.SignatureVersion != security.SignatureV2 {
+				t.Errorf("SignatureVersion = %d, want %d", result.SignatureVersion, security.SignatureV2)
+			}
+			if tt.disableLegacy && !tt.want && !strings.Contains(result.Message, "disable_legacy_auth") {
+				t.Errorf("拒绝旧版签名的消息 = %q", result.Message)
+			}
+		})
+	}
+}
+
+func TestHandleSyncRequestResult(t *testing.T) {
+	baseDir := t.TempDir()
+	writeDomainFiles(t, baseDir, "example.com", "cert.pem")
+	if err := os.WriteFile(filepath.Join(baseDir, "example.com", "time.log"), []byte("1700000000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	hub := NewHub()
+	go hub.Run()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeWs(hub, &ServeConfig{Password: "test-password", BaseDirs: []string{baseDir}, Whitelist: security.NewIPWhitelist("")}, w, r)
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	send := func(msgType string, data interface{}) {
+		t.Helper()
+		msg, _ := NewMessage(msgType, data)
+		if err := conn.WriteJSON(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// readUntilResult 读取消息直到同步结果，返回期间收到的推送域名与结果
+	readUntilResult := func() ([]string, SyncResult) {
+		t.Helper()
+		var pushed []string
+		for {
+			var msg Message
+			if err := conn.ReadJSON(&msg); err != nil {
+				t.Fatal(err)
+			}
+			switch msg.Type {
+			case MsgTypeCertPush:
+				var data CertPushData
+				msg.ParseData(&data)
+				pushed = append(pushed, data.Domain)
+			case MsgTypeSyncResult:
+				var result SyncResult
+				if err := msg.ParseData(&result); err != nil {
+					t.Fatal(err)
+				}
+				return pushed, result
+			}
+		}
+	}
+
+	ts := time.Now().Unix()
+	send(MsgTypeAuth, &AuthRequest{ClientID: "node-1", Domains: []string{"example.com"}, Signature: security.NewSignatureVerifier("test-password").GenerateSignature(ts)})
+
+	// 本地已是最新：不推送，仍返回同步结果
+	send(MsgTypeSyncRequest, &SyncRequest{Timestamps: map[string]int64{"example.com": 1700000000}})
+	if pushed, result := readUntilResult(); len(pushed) != 0 || result.Pushed != 0 {
+		t.Errorf("本地已是最新时推送 = %v, 结果 = %+v", pushed, result)
+	}
+
+	// 时间戳为 0（--resync）：总是推送，同步结果在推送之后
+	send(MsgTypeSyncRequest, &SyncRequest{Timestamps: map[string]int64{"example.com": 0}})
+	if pushed, result := readUntilResult(); len(pushed) != 1 || pushed[0] != "example.com" || result.Pushed != 1 {
+		t.Errorf("强制同步推送 = %v, 结果 = %+v", pushed, result)
+	}
+}
@@ -0,0 +1,146 @@
+package websocket
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// countingConn 统计从网络读取的原始字节数
+type countingConn struct {
+	net.Conn
+	read *int64
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	atomic.AddInt64(c.read, int64(n))
+	return n, err
+}
+
+// typicalCertPush 构造典型的证书推送载荷（RSA 2048，cert/key/fullchain 序列化后约 8KB）
+func typicalCertPush(tb testing.TB) *Message {
+	tb.Helper()
+
+	// 生成 leaf + 两级中间证书，每张证书使用独立密钥，避免重复内容虚高压缩率
+	var chain []byte
+	var certPEM, keyPEM []byte
+	for i, cn := range []string{"example.com", "Test Intermediate R1", "Test Intermediate R2"} {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(int64(i + 1)),
+			Subject:      pkix.Name{CommonName: cn},
+			DNSNames:     []string{cn},
+			NotBefore:    time.Now(),
+			NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		block := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+		chain = append(chain, block...)
+		if i == 0 {
+			certPEM = block
+			keyPEM = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+		}
+	}
+
+	msg, err := NewMessage(MsgTypeCertPush, &CertPushData{
+		Domain: "example.com",
+		Files: map[string][]byte{
+			"cert.pem":      certPEM,
+			"key.pem":       keyPEM,
+			"fullchain.pem": chain,
+		},
+		Timestamp: time.Now().Unix(),
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return msg
+}
+
+// BenchmarkCertPushCompression 对比启用/禁用 permessage-deflate 时单次推送的线上字节数
+func BenchmarkCertPushCompression(b *testing.B) {
+	msg := typicalCertPush(b)
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, compression := range []bool{false, true} {
+		name := "off"
+		if compression {
+			name = "on"
+		}
+		b.Run(name, func(b *testing.B) {
+			upgrader := newUpgrader(compression)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				conn, err := upgrader.Upgrade(w, r, nil)
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				for {
+					if _, _, err := conn.ReadMessage(); err != nil {
+						return
+					}
+					if err := conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+						return
+					}
+				}
+			}))
+			defer server.Close()
+
+			var read int64
+			dialer := websocket.Dialer{
+				EnableCompression: true,
+				NetDialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+					if err != nil {
+						return nil, err
+					}
+					return &countingConn{Conn: conn, read: &read}, nil
+				},
+			}
+			conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer conn.Close()
+
+			atomic.StoreInt64(&read, 0)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := conn.WriteMessage(websocket.TextMessage, []byte("next")); err != nil {
+					b.Fatal(err)
+				}
+				if _, _, err := conn.ReadMessage(); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+
+			b.ReportMetric(float64(len(payload)), "payload-bytes/op")
+			b.ReportMetric(float64(atomic.LoadInt64(&read))/float64(b.N), "wire-bytes/op")
+		})
+	}
+}
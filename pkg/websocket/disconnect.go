@@ -0,0 +1,63 @@
+package websocket
+
+import (
+	"errors"
+	"net"
+
+	"github.com/gorilla/websocket"
+)
+
+// DisconnectReason 客户端断开原因
+type DisconnectReason string
+
+const (
+	// DisconnectNormal 客户端正常关闭（close 1000）
+	DisconnectNormal DisconnectReason = "normal_close"
+	// DisconnectGoingAway 客户端离开（close 1001，如进程退出、页面关闭）
+	DisconnectGoingAway DisconnectReason = "going_away"
+	// DisconnectPingTimeout 超过 pongWait 未收到任何数据或 pong
+	DisconnectPingTimeout DisconnectReason = "ping_timeout"
+	// DisconnectAbnormal 连接异常中断，未收到关闭帧（close 1006）
+	DisconnectAbnormal DisconnectReason = "abnormal_closure"
+	// DisconnectClosed 客户端以其他关闭码关闭连接
+	DisconnectClosed DisconnectReason = "closed"
+	// DisconnectMessageTooBig 消息超过 max_message_size：客户端发送的消息超限，或客户端拒绝了超过其限制的消息（close 1009）
+	DisconnectMessageTooBig DisconnectReason = "message_too_big"
+	// DisconnectError 读取出错（协议错误等）
+	DisconnectError DisconnectReason = "read_error"
+)
+
+// classifyDisconnect 根据 readPump 的读取错误判断断开原因
+// 返回原因和关闭码（非关闭帧导致的断开关闭码为 0）
+func classifyDisconnect(err error) (DisconnectReason, int) {
+	if err == nil {
+		return DisconnectNormal, 0
+	}
+
+	if errors.Is(err, websocket.ErrReadLimit) {
+		return DisconnectMessageTooBig, 0
+	}
+
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		switch closeErr.Code {
+		case websocket.CloseMessageTooBig:
+			return DisconnectMessageTooBig, closeErr.Code
+		case websocket.CloseNormalClosure:
+			return DisconnectNormal, closeErr.Code
+		case websocket.CloseGoingAway:
+			return DisconnectGoingAway, closeErr.Code
+		case websocket.CloseAbnormalClosure:
+			return DisconnectAbnormal, closeErr.Code
+		default:
+			return DisconnectClosed, closeErr.Code
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return DisconnectPingTimeout, 0
+	}
+
+	return DisconnectError, 0
+}
@@ -0,0 +1,78 @@
+package websocket
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestClassifyDisconnect(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		want     DisconnectReason
+		wantCode int
+	}{
+		{"无错误", nil, DisconnectNormal, 0},
+		{"正常关闭", &websocket.CloseError{Code: websocket.CloseNormalClosure}, DisconnectNormal, 1000},
+		{"客户端离开", &websocket.CloseError{Code: websocket.CloseGoingAway}, DisconnectGoingAway, 1001},
+		{"异常中断", &websocket.CloseError{Code: websocket.CloseAbnormalClosure, Text: io.ErrUnexpectedEOF.Error()}, DisconnectAbnormal, 1006},
+		{"其他关闭码", &websocket.CloseError{Code: websocket.CloseInternalServerErr}, DisconnectClosed, 1011},
+		{"对端拒绝超限消息", &websocket.CloseError{Code: websocket.CloseMessageTooBig}, DisconnectMessageTooBig, 1009},
+		{"消息超过读取上限", websocket.ErrReadLimit, DisconnectMessageTooBig, 0},
+		{"读超时", fmt.Errorf("read tcp: %w", os.ErrDeadlineExceeded), DisconnectPingTimeout, 0},
+		{"其他读取错误", errors.New("websocket: read limit exceeded"), DisconnectError, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, code := classifyDisconnect(tt.err)
+			if got != tt.want || code != tt.wantCode {
+				t.Errorf("classifyDisconnect() = (%q, %d), want (%q, %d)", got, code, tt.want, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestHub_UnregisterLogsReason(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantLevel string
+		wantLog   string
+	}{
+		{"正常关闭", &websocket.CloseError{Code: websocket.CloseNormalClosure}, "INFO", "reason=normal_close"},
+		{"心跳超时", os.ErrDeadlineExceeded, "WARN", "reason=ping_timeout"},
+		{"异常中断", &websocket.CloseError{Code: websocket.CloseAbnormalClosure}, "WARN", "reason=abnormal_closure"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			old := slog.Default()
+			slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+			defer slog.SetDefault(old)
+
+			hub := NewHub()
+			client := newTestClient("node-1", "10.0.0.1", "example.com")
+			if err := hub.registerClient(client); err != nil {
+				t.Fatal(err)
+			}
+			buf.Reset()
+
+			hub.unregisterClient(client, tt.err)
+
+			out := buf.String()
+			if !strings.Contains(out, "level="+tt.wantLevel) || !strings.Contains(out, tt.wantLog) {
+				t.Errorf("日志 = %q, want level=%s 且包含 %q", out, tt.wantLevel, tt.wantLog)
+			}
+		})
+	}
+}
@@ -1,11 +1,56 @@
 package websocket
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/Catker/acmeDeliver/pkg/domainmatch"
+)
+
+// DuplicatePolicy 重复客户端 ID 的处理策略
+type DuplicatePolicy string
+
+const (
+	// DuplicatePolicyAllow 允许重复（仅记录警告）
+	DuplicatePolicyAllow DuplicatePolicy = "allow"
+	// DuplicatePolicyReject 拒绝新连接
+	DuplicatePolicyReject DuplicatePolicy = "reject"
+	// DuplicatePolicyEvict 踢出旧连接，保留新连接（默认）
+	DuplicatePolicyEvict DuplicatePolicy = "evict"
 )
 
+// ParseDuplicatePolicy 解析重复 ID 处理策略，空值视为 evict
+// daemon 重启时旧连接可能尚未超时，evict 保证同一 ID 只保留最新的连接；allow 会让旧连接继续接收推送
+func ParseDuplicatePolicy(s string) (DuplicatePolicy, error) {
+	switch DuplicatePolicy(s) {
+	case "", DuplicatePolicyEvict:
+		return DuplicatePolicyEvict, nil
+	case DuplicatePolicyAllow, DuplicatePolicyReject:
+		return DuplicatePolicy(s), nil
+	default:
+		return DuplicatePolicyEvict, fmt.Errorf("未知的 duplicate_policy: %q（可选 allow/reject/evict）", s)
+	}
+}
+
+// registration 客户端注册请求
+type registration struct {
+	client *Client
+	result chan error
+}
+
+// unregistration 客户端注销请求
+type unregistration struct {
+	client *Client
+	err    error // 导致断开的读取错误，用于判断断开原因
+}
+
 // Hub 客户端连接管理中心
 // 维护所有在线客户端连接，提供按域名查找订阅者的能力
 type Hub struct {
@@ -16,10 +61,25 @@ type Hub struct {
 	subscriptions map[string]map[*Client]bool
 
 	// 客户端注册通道
-	register chan *Client
+	register chan *registration
 
 	// 客户端注销通道
-	unregister chan *Client
+	unregister chan *unregistration
+
+	// 重复客户端 ID 处理策略
+	duplicatePolicy DuplicatePolicy
+
+	// 进行中的密钥轮换（无轮换时为 nil）
+	rotation *keyRotation
+
+	// 证书推送的出站限速（未限速时为 nil）
+	pushLimiter *pushLimiter
+
+	// 多实例部署时向其他服务端实例转发证书推送（单实例时为 nil）
+	certPublisher CertPublisher
+
+	// 是否允许订阅 "*"（接收所有域名的证书）
+	allowWildcardSubscribe bool
 
 	// 互斥锁
 	mu sync.RWMutex
@@ -28,30 +88,161 @@ type Hub struct {
 // NewHub 创建新的 Hub
 func NewHub() *Hub {
 	return &Hub{
-		clients:       make(map[*Client]bool),
-		subscriptions: make(map[string]map[*Client]bool),
-		register:      make(chan *Client),
-		unregister:    make(chan *Client),
+		clients:         make(map[*Client]bool),
+		subscriptions:   make(map[string]map[*Client]bool),
+		register:        make(chan *registration),
+		unregister:      make(chan *unregistration),
+		duplicatePolicy: DuplicatePolicyEvict,
+	}
+}
+
+// SetDuplicatePolicy 设置重复客户端 ID 处理策略
+func (h *Hub) SetDuplicatePolicy(policy DuplicatePolicy) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.duplicatePolicy = policy
+}
+
+// SetPushRate 设置证书推送的出站速率（字节/秒），所有推送共享，不大于 0 时不限速
+func (h *Hub) SetPushRate(bytesPerSec int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if bytesPerSec <= 0 {
+		h.pushLimiter = nil
+		return
+	}
+	h.pushLimiter = newPushLimiter(bytesPerSec, time.Now())
+}
+
+// getPushLimiter 返回当前的推送限速器
+func (h *Hub) getPushLimiter() *pushLimiter {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.pushLimiter
+}
+
+// ErrWildcardSubscribe 未开启 allow_wildcard_subscribe 时订阅大范围通配符返回的错误
+var ErrWildcardSubscribe = errors.New(`服务端未开启 allow_wildcard_subscribe，不允许订阅 "*"（所有域名）、"**." 或顶级域名通配符（如 "*.com"）`)
+
+// isWildcardSubscription 判断订阅模式是否受 allow_wildcard_subscribe 限制
+// "*"、任意 "**." 模式（匹配任意层级）以及后缀只有一级的 "*." 模式（如 "*.com"）都可能覆盖不相关的域名
+func isWildcardSubscription(pattern string) bool {
+	switch {
+	case pattern == "*", strings.HasPrefix(pattern, "**."):
+		return true
+	case strings.HasPrefix(pattern, "*."):
+		return !strings.Contains(pattern[2:], ".")
+	default:
+		return false
 	}
 }
 
+// checkWildcardSubscription 未允许大范围通配符订阅时拒绝 isWildcardSubscription 的模式
+func checkWildcardSubscription(pattern string, allowWildcard bool) error {
+	if !allowWildcard && isWildcardSubscription(pattern) {
+		return ErrWildcardSubscribe
+	}
+	return nil
+}
+
+// SetAllowWildcardSubscribe 设置是否允许订阅 "*" 等大范围通配符（见 isWildcardSubscription）
+// 关闭时仅拒绝之后的认证与订阅更新，已有的订阅不受影响
+func (h *Hub) SetAllowWildcardSubscribe(allow bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.allowWildcardSubscribe = allow
+}
+
+// checkSubscription 校验认证请求订阅的域名，未允许时拒绝大范围通配符
+func (h *Hub) checkSubscription(domains []string) error {
+	h.mu.RLock()
+	allowWildcard := h.allowWildcardSubscribe
+	h.mu.RUnlock()
+	for _, domain := range domains {
+		if err := checkWildcardSubscription(domain, allowWildcard); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filterSubscription 按订阅策略拆分域名：拒绝无效的域名模式，未允许时拒绝大范围通配符
+func (h *Hub) filterSubscription(domains []string) *SubscribeResult {
+	h.mu.RLock()
+	allowWildcard := h.allowWildcardSubscribe
+	h.mu.RUnlock()
+
+	result := &SubscribeResult{Accepted: make([]string, 0, len(domains))}
+	for _, domain := range domains {
+		reason := checkWildcardSubscription(domain, allowWildcard)
+		if reason == nil {
+			reason = domainmatch.ValidatePattern(domain)
+		}
+		if reason != nil {
+			result.Rejected = append(result.Rejected, RejectedDomain{Domain: domain, Reason: reason.Error()})
+			continue
+		}
+		result.Accepted = append(result.Accepted, domain)
+	}
+	return result
+}
+
+// CertPublisher 将证书推送转发给其他服务端实例（如经 Redis 发布/订阅）
+// PublishCert 在本实例推送完成后于独立的 goroutine 中调用，实现需并发安全；发布失败或阻塞不影响本实例的推送
+type CertPublisher interface {
+	PublishCert(domain string, data *CertPushData)
+}
+
+// SetCertPublisher 设置证书推送的跨实例转发，nil 表示仅推送本实例的客户端
+func (h *Hub) SetCertPublisher(p CertPublisher) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.certPublisher = p
+}
+
 // Run 运行 Hub 主循环
 func (h *Hub) Run() {
 	for {
 		select {
-		case client := <-h.register:
-			h.registerClient(client)
-		case client := <-h.unregister:
-			h.unregisterClient(client)
+		case reg := <-h.register:
+			reg.result <- h.registerClient(reg.client)
+		case unreg := <-h.unregister:
+			h.unregisterClient(unreg.client, unreg.err)
 		}
 	}
 }
 
 // registerClient 注册客户端
-func (h *Hub) registerClient(client *Client) {
+// 按 duplicatePolicy 处理已存在相同 ID 的连接，拒绝时返回错误
+func (h *Hub) registerClient(client *Client) error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	for existing := range h.clients {
+		if existing == client || existing.ID != client.ID {
+			continue
+		}
+		switch h.duplicatePolicy {
+		case DuplicatePolicyReject:
+			slog.Warn("客户端 ID 重复，拒绝新连接",
+				"client_id", client.ID,
+				"existing_ip", existing.RemoteIP,
+				"new_ip", client.RemoteIP)
+			return fmt.Errorf("客户端 ID %q 已在线（来自 %s）", client.ID, existing.RemoteIP)
+		case DuplicatePolicyEvict:
+			slog.Warn("客户端 ID 重复，踢出旧连接",
+				"client_id", client.ID,
+				"existing_ip", existing.RemoteIP,
+				"new_ip", client.RemoteIP)
+			h.evictClient(existing, fmt.Sprintf("客户端 ID %q 已被来自 %s 的新连接取代", client.ID, client.RemoteIP))
+		default:
+			slog.Warn("客户端 ID 重复",
+				"client_id", client.ID,
+				"existing_ip", existing.RemoteIP,
+				"new_ip", client.RemoteIP)
+		}
+	}
+
 	h.clients[client] = true
 
 	// 为客户端订阅的域名建立索引
@@ -66,10 +257,28 @@ func (h *Hub) registerClient(client *Client) {
 		"client_id", client.ID,
 		"domains", client.domains,
 		"total_clients", len(h.clients))
+	return nil
+}
+
+// evictClient 踢出客户端：先发送错误说明，再移除并关闭发送通道
+// writePump 会先发完缓冲区中的错误消息，再发送关闭帧
+// 调用方必须持有 h.mu 写锁
+func (h *Hub) evictClient(client *Client, reason string) {
+	if errMsg, err := NewMessage(MsgTypeError, &ErrorData{
+		Code:    409,
+		Message: reason,
+	}); err == nil {
+		select {
+		case client.send <- errMsg:
+		default:
+		}
+	}
+	h.removeClient(client)
 }
 
 // unregisterClient 注销客户端
-func (h *Hub) unregisterClient(client *Client) {
+// err 为导致断开的读取错误，据此在日志中区分正常关闭、心跳超时与异常断开
+func (h *Hub) unregisterClient(client *Client, err error) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
@@ -77,6 +286,36 @@ func (h *Hub) unregisterClient(client *Client) {
 		return
 	}
 
+	h.removeClient(client)
+
+	reason, code := classifyDisconnect(err)
+	attrs := []any{
+		"client_id", client.ID,
+		"remote_ip", client.RemoteIP,
+		"reason", reason,
+		"total_clients", len(h.clients),
+	}
+	if !client.ConnectedAt.IsZero() {
+		attrs = append(attrs, "connected_for", time.Since(client.ConnectedAt).Round(time.Second))
+	}
+	if code != 0 {
+		attrs = append(attrs, "close_code", code)
+	}
+
+	switch reason {
+	case DisconnectNormal, DisconnectGoingAway:
+		slog.Info("客户端已断开", attrs...)
+	default:
+		if err != nil {
+			attrs = append(attrs, "error", err)
+		}
+		slog.Warn("客户端异常断开", attrs...)
+	}
+}
+
+// removeClient 从 Hub 中移除客户端并关闭其发送通道
+// 调用方必须持有 h.mu 写锁
+func (h *Hub) removeClient(client *Client) {
 	// 从域名订阅中移除
 	for _, domain := range client.domains {
 		if subs, ok := h.subscriptions[domain]; ok {
@@ -87,16 +326,43 @@ func (h *Hub) unregisterClient(client *Client) {
 		}
 	}
 
+	h.forgetRotationClient(client)
 	delete(h.clients, client)
 	close(client.send)
+}
 
-	slog.Info("客户端已断开",
-		"client_id", client.ID,
-		"total_clients", len(h.clients))
+// Kick 强制断开指定 ID 的客户端：发送携带原因的关闭帧后注销
+// 同一 ID 存在多个连接时全部断开，客户端不在线时返回 false
+func (h *Hub) Kick(clientID, reason string) bool {
+	h.mu.RLock()
+	var kicked []*Client
+	for client := range h.clients {
+		if client.ID == clientID {
+			kicked = append(kicked, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, client := range kicked {
+		slog.Warn("强制断开客户端",
+			"client_id", client.ID,
+			"remote_ip", client.RemoteIP,
+			"reason", reason)
+		client.closeWithReason(websocket.ClosePolicyViolation, reason)
+		h.unregisterClient(client, &websocket.CloseError{Code: websocket.ClosePolicyViolation, Text: reason})
+	}
+	return len(kicked) > 0
 }
 
-// UpdateSubscription 更新客户端订阅的域名
-func (h *Hub) UpdateSubscription(client *Client, newDomains []string) {
+// UpdateSubscription 更新客户端订阅的域名，返回接受与拒绝的域名
+// 只有被接受的域名生效；请求的域名全部被拒绝时保留原有订阅
+func (h *Hub) UpdateSubscription(client *Client, newDomains []string) *SubscribeResult {
+	result := h.filterSubscription(newDomains)
+	if len(result.Accepted) == 0 && len(result.Rejected) > 0 {
+		return result
+	}
+	newDomains = result.Accepted
+
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
@@ -124,16 +390,21 @@ func (h *Hub) UpdateSubscription(client *Client, newDomains []string) {
 	slog.Info("客户端订阅已更新",
 		"client_id", client.ID,
 		"domains", client.domains)
+	return result
 }
 
 // Register 注册客户端 (外部调用)
-func (h *Hub) Register(client *Client) {
-	h.register <- client
+// 返回错误表示注册被拒绝（如客户端 ID 重复且策略为 reject）
+func (h *Hub) Register(client *Client) error {
+	reg := &registration{client: client, result: make(chan error, 1)}
+	h.register <- reg
+	return <-reg.result
 }
 
 // Unregister 注销客户端 (外部调用)
-func (h *Hub) Unregister(client *Client) {
-	h.unregister <- client
+// err 为导致断开的错误（如 ReadMessage 返回值），用于记录断开原因
+func (h *Hub) Unregister(client *Client, err error) {
+	h.unregister <- &unregistration{client: client, err: err}
 }
 
 // ClientStatus 客户端状态信息（用于外部查询）
@@ -162,34 +433,19 @@ func (h *Hub) GetClientStatus() []ClientStatus {
 }
 
 // GetSubscribers 获取订阅指定域名的所有客户端
-// 支持三种匹配模式：
-// 1. 精确匹配：domain == "example.com"
-// 2. 通配符匹配：pattern == "*.example.com" 匹配 "api.example.com"
-// 3. 全局订阅：pattern == "*" 匹配所有域名
+// 匹配规则见 domainmatch.Match：
+// 1. 精确匹配：pattern == "example.com"
+// 2. 单级通配符：pattern == "*.example.com" 匹配 "api.example.com"，不匹配 "a.b.example.com"
+// 3. 多级通配符：pattern == "**.example.com" 匹配任意层级子域名
+// 4. 全局订阅：pattern == "*" 匹配所有域名
 func (h *Hub) GetSubscribers(domain string) []*Client {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
 	// 使用 map 去重 - O(1) 查找复杂度
 	clientSet := make(map[*Client]struct{})
-
-	// 全局订阅匹配 ("*" 订阅所有域名)
-	if subs, ok := h.subscriptions["*"]; ok {
-		for client := range subs {
-			clientSet[client] = struct{}{}
-		}
-	}
-
-	// 精确匹配
-	if subs, ok := h.subscriptions[domain]; ok {
-		for client := range subs {
-			clientSet[client] = struct{}{}
-		}
-	}
-
-	// 通配符匹配 (*.example.com)
 	for pattern, subs := range h.subscriptions {
-		if matchWildcard(pattern, domain) {
+		if domainmatch.Match(pattern, domain) {
 			for client := range subs {
 				clientSet[client] = struct{}{} // 自动去重
 			}
@@ -206,7 +462,21 @@ func (h *Hub) GetSubscribers(domain string) []*Client {
 }
 
 // BroadcastCert 向订阅指定域名的所有客户端推送证书
+// 设置了 CertPublisher 时在本实例推送后异步转发给其他服务端实例（即使本实例没有订阅者），返回本实例成功入队的连接数
 func (h *Hub) BroadcastCert(domain string, data *CertPushData) int {
+	sent := h.BroadcastCertLocal(domain, data)
+
+	h.mu.RLock()
+	publisher := h.certPublisher
+	h.mu.RUnlock()
+	if publisher != nil {
+		go publisher.PublishCert(domain, data)
+	}
+	return sent
+}
+
+// BroadcastCertLocal 仅向本实例中订阅指定域名的客户端推送证书，用于转发来自其他实例的推送（避免再次转发）
+func (h *Hub) BroadcastCertLocal(domain string, data *CertPushData) int {
 	subscribers := h.GetSubscribers(domain)
 	if len(subscribers) == 0 {
 		slog.Debug("没有客户端订阅此域名", "domain", domain)
@@ -219,8 +489,22 @@ func (h *Hub) BroadcastCert(domain string, data *CertPushData) int {
 		return 0
 	}
 
+	// 限速时按消息序列化后的大小逐个入队
+	limiter := h.getPushLimiter()
+	size := 0
+	if limiter != nil {
+		if raw, err := json.Marshal(msg); err == nil {
+			size = len(raw)
+		}
+	}
+
 	sent := 0
 	for _, client := range subscribers {
+		if limiter != nil {
+			if wait := limiter.reserve(size, time.Now()); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
 		select {
 		case client.send <- msg:
 			sent++
@@ -240,18 +524,47 @@ func (h *Hub) BroadcastCert(domain string, data *CertPushData) int {
 	return sent
 }
 
-// matchWildcard 检查域名是否匹配通配符模式
-// 支持 *.example.com 形式的通配符
-func matchWildcard(pattern, domain string) bool {
-	if len(pattern) < 2 || pattern[0] != '*' || pattern[1] != '.' {
-		return false
+// BroadcastRevoke 向订阅该域名的客户端发送域名下线消息，返回成功入队的连接数
+func (h *Hub) BroadcastRevoke(data *CertRevokeData) int {
+	msg, err := NewMessage(MsgTypeCertRevoke, data)
+	if err != nil {
+		slog.Error("创建下线消息失败", "error", err)
+		return 0
 	}
 
-	suffix := pattern[1:] // .example.com
-	if len(domain) <= len(suffix) {
-		return false
+	sent := 0
+	for _, client := range h.GetSubscribers(data.Domain) {
+		select {
+		case client.send <- msg:
+			sent++
+		default:
+			slog.Warn("客户端发送缓冲区已满，跳过下线消息",
+				"client_id", client.ID,
+				"domain", data.Domain)
+		}
 	}
+	return sent
+}
 
-	// 检查域名是否以 .example.com 结尾
-	return domain[len(domain)-len(suffix):] == suffix
+// SendToClient 向指定 ID 的客户端发送消息
+// 同一 ID 存在多个连接时全部发送，返回成功入队的连接数
+func (h *Hub) SendToClient(clientID string, msg *Message) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	sent := 0
+	for client := range h.clients {
+		if client.ID != clientID {
+			continue
+		}
+		select {
+		case client.send <- msg:
+			sent++
+		default:
+			slog.Warn("客户端发送缓冲区已满，跳过消息",
+				"client_id", client.ID,
+				"type", msg.Type)
+		}
+	}
+	return sent
 }
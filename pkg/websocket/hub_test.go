@@ -0,0 +1,404 @@
+package websocket
+
+import (
+	"bytes"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestClient 创建不带网络连接的测试客户端
+func newTestClient(id, ip string, domains ...string) *Client {
+	return &Client{
+		ID:       id,
+		RemoteIP: ip,
+		domains:  domains,
+		send:     make(chan *Message, 8),
+		logger:   clientLogger(id, ip),
+	}
+}
+
+func TestParseDuplicatePolicy(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    DuplicatePolicy
+		wantErr bool
+	}{
+		{"", DuplicatePolicyEvict, false},
+		{"allow", DuplicatePolicyAllow, false},
+		{"reject", DuplicatePolicyReject, false},
+		{"evict", DuplicatePolicyEvict, false},
+		{"kick", DuplicatePolicyEvict, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDuplicatePolicy(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseDuplicatePolicy(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseDuplicatePolicy(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	if got := NewHub().duplicatePolicy; got != DuplicatePolicyEvict {
+		t.Errorf("NewHub() 默认策略 = %q, want evict", got)
+	}
+}
+
+func TestHub_DuplicatePolicyReject(t *testing.T) {
+	hub := NewHub()
+	hub.SetDuplicatePolicy(DuplicatePolicyReject)
+
+	first := newTestClient("node-1", "10.0.0.1", "example.com")
+	second := newTestClient("node-1", "10.0.0.2", "example.com")
+
+	if err := hub.registerClient(first); err != nil {
+		t.Fatalf("首个客户端注册失败: %v", err)
+	}
+	if err := hub.registerClient(second); err == nil {
+		t.Fatal("重复 ID 的客户端应被拒绝")
+	}
+
+	status := hub.GetClientStatus()
+	if len(status) != 1 || status[0].RemoteIP != "10.0.0.1" {
+		t.Fatalf("应只保留首个客户端, got %+v", status)
+	}
+	if subs := hub.GetSubscribers("example.com"); len(subs) != 1 || subs[0] != first {
+		t.Errorf("订阅者应只包含首个客户端, got %d", len(subs))
+	}
+}
+
+func TestHub_DuplicatePolicyEvict(t *testing.T) {
+	hub := NewHub()
+	hub.SetDuplicatePolicy(DuplicatePolicyEvict)
+
+	first := newTestClient("node-1", "10.0.0.1", "example.com")
+	second := newTestClient("node-1", "10.0.0.2", "example.com")
+
+	if err := hub.registerClient(first); err != nil {
+		t.Fatalf("首个客户端注册失败: %v", err)
+	}
+	if err := hub.registerClient(second); err != nil {
+		t.Fatalf("evict 策略下新客户端应注册成功: %v", err)
+	}
+
+	status := hub.GetClientStatus()
+	if len(status) != 1 || status[0].RemoteIP != "10.0.0.2" {
+		t.Fatalf("应只保留新客户端, got %+v", status)
+	}
+
+	// 被踢出的客户端应先收到错误消息，随后发送通道关闭
+	msg, ok := <-first.send
+	if !ok {
+		t.Fatal("被踢出的客户端应收到错误消息")
+	}
+	if msg.Type != MsgTypeError {
+		t.Fatalf("消息类型 = %q, want %q", msg.Type, MsgTypeError)
+	}
+	var errData ErrorData
+	if err := msg.ParseData(&errData); err != nil || errData.Code != 409 {
+		t.Errorf("错误数据 = %+v, err = %v", errData, err)
+	}
+	if _, ok := <-first.send; ok {
+		t.Error("被踢出客户端的发送通道应已关闭")
+	}
+
+	// 旧连接随后的注销不应影响新连接
+	hub.unregisterClient(first, nil)
+	if len(hub.GetClientStatus()) != 1 {
+		t.Error("旧连接注销后新连接应仍在线")
+	}
+}
+
+func TestHub_DuplicatePolicyAllow(t *testing.T) {
+	hub := NewHub()
+	hub.SetDuplicatePolicy(DuplicatePolicyAllow)
+
+	if err := hub.registerClient(newTestClient("node-1", "10.0.0.1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := hub.registerClient(newTestClient("node-1", "10.0.0.2")); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(hub.GetClientStatus()); got != 2 {
+		t.Errorf("allow 策略下应保留两个连接, got %d", got)
+	}
+}
+
+func TestHub_GetSubscribersWildcard(t *testing.T) {
+	hub := NewHub()
+	clients := map[string]*Client{
+		"exact":  newTestClient("exact", "10.0.0.1", "a.b.example.com"),
+		"single": newTestClient("single", "10.0.0.2", "*.example.com"),
+		"deep":   newTestClient("deep", "10.0.0.3", "**.example.com"),
+		"global": newTestClient("global", "10.0.0.4", "*"),
+	}
+	for _, c := range clients {
+		if err := hub.registerClient(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		domain string
+		want   []string
+	}{
+		{"a.example.com", []string{"single", "deep", "global"}},
+		{"a.b.example.com", []string{"exact", "deep", "global"}},
+		{"example.com", []string{"global"}},
+		{"other.org", []string{"global"}},
+	}
+
+	for _, tt := range tests {
+		got := make(map[string]bool)
+		for _, c := range hub.GetSubscribers(tt.domain) {
+			got[c.ID] = true
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("GetSubscribers(%q) = %v, want %v", tt.domain, got, tt.want)
+			continue
+		}
+		for _, id := range tt.want {
+			if !got[id] {
+				t.Errorf("GetSubscribers(%q) 缺少 %s, got %v", tt.domain, id, got)
+			}
+		}
+	}
+}
+
+func TestClientLogger(t *testing.T) {
+	var buf bytes.Buffer
+	old := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(old) })
+
+	c := NewClient(nil, nil, "10.0.0.1")
+	c.logger.Info("认证前")
+	c.setID("web-01")
+	c.logger.Info("认证后")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("日志行数 = %d, want 2: %s", len(lines), buf.String())
+	}
+	if strings.Contains(lines[0], "client_id") || !strings.Contains(lines[0], "remote_ip=10.0.0.1") {
+		t.Errorf("认证前日志 = %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "client_id=web-01") || !strings.Contains(lines[1], "remote_ip=10.0.0.1") {
+		t.Errorf("认证后日志 = %q", lines[1])
+	}
+	if c.ID != "web-01" {
+		t.Errorf("ID = %q, want web-01", c.ID)
+	}
+}
+
+func TestHub_BroadcastRevoke(t *testing.T) {
+	hub := NewHub()
+	subscriber := newTestClient("node-1", "10.0.0.1", "*.example.com")
+	other := newTestClient("node-2", "10.0.0.2", "other.org")
+	for _, c := range []*Client{subscriber, other} {
+		if err := hub.registerClient(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if sent := hub.BroadcastRevoke(&CertRevokeData{Domain: "a.example.com", PurgeDeployed: true}); sent != 1 {
+		t.Fatalf("BroadcastRevoke() = %d, want 1", sent)
+	}
+	msg := <-subscriber.send
+	var data CertRevokeData
+	if err := msg.ParseData(&data); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != MsgTypeCertRevoke || data.Domain != "a.example.com" || !data.PurgeDeployed {
+		t.Errorf("下线消息 = %s %+v", msg.Type, data)
+	}
+	if len(other.send) != 0 {
+		t.Error("未订阅该域名的客户端不应收到下线消息")
+	}
+}
+
+// recordingPublisher 记录转发给其他实例的证书推送，release 非 nil 时阻塞到其关闭
+type recordingPublisher struct {
+	published chan string
+	release   chan struct{}
+}
+
+func newRecordingPublisher() *recordingPublisher {
+	return &recordingPublisher{published: make(chan string, 10)}
+}
+
+func (p *recordingPublisher) PublishCert(domain string, data *CertPushData) {
+	if p.release != nil {
+		<-p.release
+	}
+	p.published <- domain
+}
+
+// next 等待下一次转发并返回域名
+func (p *recordingPublisher) next(t *testing.T) string {
+	t.Helper()
+	select {
+	case domain := <-p.published:
+		return domain
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待转发超时")
+		return ""
+	}
+}
+
+func TestHub_BroadcastCertPublisher(t *testing.T) {
+	hub := NewHub()
+	publisher := newRecordingPublisher()
+	hub.SetCertPublisher(publisher)
+	subscriber := newTestClient("node-1", "10.0.0.1", "example.com")
+	if err := hub.registerClient(subscriber); err != nil {
+		t.Fatal(err)
+	}
+
+	if sent := hub.BroadcastCert("example.com", &CertPushData{Domain: "example.com"}); sent != 1 {
+		t.Errorf("BroadcastCert() = %d, want 1", sent)
+	}
+	if got := publisher.next(t); got != "example.com" {
+		t.Errorf("转发的域名 = %q, want example.com", got)
+	}
+	if sent := hub.BroadcastCert("other.org", &CertPushData{Domain: "other.org"}); sent != 0 {
+		t.Errorf("BroadcastCert() = %d, want 0", sent)
+	}
+	if got := publisher.next(t); got != "other.org" {
+		t.Errorf("转发的域名 = %q，本实例没有订阅者时也应转发", got)
+	}
+
+	if sent := hub.BroadcastCertLocal("example.com", &CertPushData{Domain: "example.com"}); sent != 1 {
+		t.Errorf("BroadcastCertLocal() = %d, want 1", sent)
+	}
+	select {
+	case domain := <-publisher.published:
+		t.Errorf("BroadcastCertLocal 不应转发给其他实例: %s", domain)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestHub_BroadcastCertSlowPublisher(t *testing.T) {
+	hub := NewHub()
+	publisher := newRecordingPublisher()
+	publisher.release = make(chan struct{})
+	hub.SetCertPublisher(publisher)
+	subscriber := newTestClient("node-1", "10.0.0.1", "example.com")
+	if err := hub.registerClient(subscriber); err != nil {
+		t.Fatal(err)
+	}
+
+	// 转发阻塞（如 Redis 不可达）时本实例的推送仍立即完成
+	done := make(chan int, 1)
+	go func() { done <- hub.BroadcastCert("example.com", &CertPushData{Domain: "example.com"}) }()
+	select {
+	case sent := <-done:
+		if sent != 1 {
+			t.Errorf("BroadcastCert() = %d, want 1", sent)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("BroadcastCert 被阻塞的转发拖住")
+	}
+
+	close(publisher.release)
+	if got := publisher.next(t); got != "example.com" {
+		t.Errorf("转发的域名 = %q, want example.com", got)
+	}
+}
+
+func TestHub_Kick(t *testing.T) {
+	hub := NewHub()
+	hub.SetDuplicatePolicy(DuplicatePolicyAllow) // 同一 ID 保留多个连接，验证全部被断开
+	first := newTestClient("node-1", "10.0.0.1", "example.com")
+	second := newTestClient("node-1", "10.0.0.2", "example.com")
+	other := newTestClient("node-2", "10.0.0.3", "example.com")
+	for _, c := range []*Client{first, second, other} {
+		if err := hub.registerClient(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if hub.Kick("missing", "test") {
+		t.Error("客户端不在线时 Kick() 应返回 false")
+	}
+	if !hub.Kick("node-1", "compromised") {
+		t.Fatal("Kick() = false, want true")
+	}
+
+	// 同一 ID 的所有连接都被注销并关闭发送通道
+	for _, c := range []*Client{first, second} {
+		if _, ok := <-c.send; ok {
+			t.Errorf("%s 的发送通道未关闭", c.RemoteIP)
+		}
+	}
+	subs := hub.GetSubscribers("example.com")
+	if len(subs) != 1 || subs[0] != other {
+		t.Errorf("剩余订阅者 = %v, want [node-2]", subs)
+	}
+	if hub.Kick("node-1", "again") {
+		t.Error("已断开的客户端再次 Kick() 应返回 false")
+	}
+}
+
+func TestTruncateCloseReason(t *testing.T) {
+	tests := []struct {
+		name   string
+		reason string
+		want   string
+	}{
+		{"短原因", "compromised", "compromised"},
+		{"正好上限", strings.Repeat("a", maxCloseReasonLen), strings.Repeat("a", maxCloseReasonLen)},
+		{"ASCII 超长", strings.Repeat("a", 200), strings.Repeat("a", maxCloseReasonLen)},
+		{"多字节字符不被截断", strings.Repeat("断", 50), strings.Repeat("断", 41)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncateCloseReason(tt.reason); got != tt.want {
+				t.Errorf("truncateCloseReason() = %q (%d 字节), want %q", got, len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestHub_FilterSubscription(t *testing.T) {
+	tests := []struct {
+		name          string
+		allowWildcard bool
+		domains       []string
+		wantAccepted  []string
+		wantRejected  []string
+	}{
+		{"全部接受", false, []string{"example.com", "*.example.com", "*.a.example.org"}, []string{"example.com", "*.example.com", "*.a.example.org"}, nil},
+		{"未允许时拒绝 *", false, []string{"example.com", "*"}, []string{"example.com"}, []string{"*"}},
+		{"未允许时拒绝 **.", false, []string{"**.com", "**.example.org", "*.example.com"}, []string{"*.example.com"}, []string{"**.com", "**.example.org"}},
+		{"未允许时拒绝顶级域名通配符", false, []string{"*.com", "*.example.com"}, []string{"*.example.com"}, []string{"*.com"}},
+		{"允许时接受 *", true, []string{"example.com", "*"}, []string{"example.com", "*"}, nil},
+		{"允许时接受 **. 与顶级域名通配符", true, []string{"**.com", "*.com"}, []string{"**.com", "*.com"}, nil},
+		{"拒绝无效的域名模式", true, []string{"bad_domain", "a.*.example.com", "", "ok.example.com"}, []string{"ok.example.com"}, []string{"bad_domain", "a.*.example.com", ""}},
+		{"空列表", false, nil, []string{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewHub()
+			hub.SetAllowWildcardSubscribe(tt.allowWildcard)
+			result := hub.filterSubscription(tt.domains)
+			if !reflect.DeepEqual(result.Accepted, tt.wantAccepted) {
+				t.Errorf("Accepted = %v, want %v", result.Accepted, tt.wantAccepted)
+			}
+			var rejected []string
+			for _, r := range result.Rejected {
+				if r.Reason == "" {
+					t.Errorf("%q 缺少拒绝原因", r.Domain)
+				}
+				rejected = append(rejected, r.Domain)
+			}
+			if !reflect.DeepEqual(rejected, tt.wantRejected) {
+				t.Errorf("Rejected = %v, want %v", rejected, tt.wantRejected)
+			}
+		})
+	}
+}
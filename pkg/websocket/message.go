@@ -13,29 +13,40 @@ type DomainStatus = cert.DomainStatus
 
 // 消息类型常量
 const (
-	MsgTypeAuth       = "auth"        // 客户端认证请求
-	MsgTypeAuthResult = "auth_result" // 认证结果响应
-	MsgTypeSubscribe  = "subscribe"   // 订阅域名
-	MsgTypeCertPush   = "cert_push"   // 推送证书
-	MsgTypeCertAck    = "cert_ack"    // 证书接收确认
-	MsgTypePing       = "ping"        // 心跳请求
-	MsgTypePong       = "pong"        // 心跳响应
-	MsgTypeError      = "error"       // 错误消息
+	MsgTypeAuth            = "auth"             // 客户端认证请求
+	MsgTypeAuthResult      = "auth_result"      // 认证结果响应
+	MsgTypeSubscribe       = "subscribe"        // 订阅域名
+	MsgTypeSubscribeResult = "subscribe_result" // 订阅更新结果（接受与拒绝的域名）
+	MsgTypeCertPush        = "cert_push"        // 推送证书
+	MsgTypeCertAck         = "cert_ack"         // 证书接收确认
+	MsgTypePing            = "ping"             // 心跳请求
+	MsgTypePong            = "pong"             // 心跳响应
+	MsgTypeError           = "error"            // 错误消息
 
 	// CLI 一次性操作消息类型
 	MsgTypeCertRequest    = "cert_request"    // 请求下载证书
 	MsgTypeCertResponse   = "cert_response"   // 证书响应
 	MsgTypeStatusRequest  = "status_request"  // 请求服务器状态
 	MsgTypeStatusResponse = "status_response" // 状态响应
+	MsgTypeListRequest    = "list_request"    // 请求域名列表
+	MsgTypeListResponse   = "list_response"   // 域名列表响应
 
 	// Daemon 模式证书同步
 	MsgTypeSyncRequest = "sync_request" // 证书同步请求（客户端发送本地时间戳，服务端推送差异证书）
+	MsgTypeSyncResult  = "sync_result"  // 证书同步完成（在本次同步的全部推送之后发送）
+
+	// 运维操作
+	MsgTypeAdminPush   = "admin_push"   // 管理员定向推送证书（服务端 → 指定 daemon，数据格式同 cert_push）
+	MsgTypeKeyRotation = "key_rotation" // 认证密钥轮换（服务端 → 已认证 daemon，收到后使用新密钥重新认证）
+	MsgTypeCertRevoke  = "cert_revoke"  // 域名下线（服务端 → 订阅的 daemon，删除工作目录，可选删除已部署文件）
 )
 
 // Message WebSocket 消息结构
 type Message struct {
 	Type      string          `json:"type"`
 	Timestamp int64           `json:"timestamp"`
+	RequestID string          `json:"request_id,omitempty"` // 请求 ID（UUID v4），响应原样带回用于关联请求
+	Version   int             `json:"version,omitempty"`    // 协议版本，未携带（0）时按版本 1 处理
 	Data      json.RawMessage `json:"data,omitempty"`
 }
 
@@ -59,14 +70,20 @@ func NewMessage(msgType string, data interface{}) (*Message, error) {
 // AuthRequest 认证请求数据
 type AuthRequest struct {
 	ClientID  string   `json:"client_id"` // 客户端标识
-	Signature string   `json:"signature"` // 签名 = sha256(password + timestamp)
+	Signature string   `json:"signature"` // 签名，按 SignatureVersion 计算
 	Domains   []string `json:"domains"`   // 订阅的域名列表
+	// SignatureVersion 签名版本：security.SignatureV2 = HMAC-SHA256(password, timestamp)；
+	// 旧版本客户端不携带（0），签名为 sha256(password + timestamp)
+	SignatureVersion int `json:"signature_version,omitempty"`
 }
 
 // AuthResponse 认证响应数据
 type AuthResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message,omitempty"`
+	Success    bool   `json:"success"`
+	Message    string `json:"message,omitempty"`
+	ServerTime int64  `json:"server_time,omitempty"` // 服务端当前时间（Unix 秒），认证失败时同样返回，供客户端检测时钟偏差
+	// SignatureVersion 服务端支持的最高签名版本，旧版本服务端不携带（0），客户端据此决定是否回退到 V1 签名
+	SignatureVersion int `json:"signature_version,omitempty"`
 }
 
 // CertPushData 证书推送数据
@@ -74,6 +91,8 @@ type CertPushData struct {
 	Domain    string            `json:"domain"`    // 域名
 	Files     map[string][]byte `json:"files"`     // 文件名 -> 文件内容
 	Timestamp int64             `json:"timestamp"` // 证书更新时间戳
+	// FileNames 服务端配置了非标准文件名时携带，客户端据此将 Files 映射为标准文件名
+	FileNames *cert.FileNames `json:"file_names,omitempty"`
 }
 
 // CertAck 证书接收确认
@@ -83,15 +102,45 @@ type CertAck struct {
 	Message string `json:"message,omitempty"`
 }
 
+// CertAckUnchanged 部署文件内容均未变化、客户端跳过 reload 时 CertAck.Message 的取值
+const CertAckUnchanged = "unchanged"
+
 // SubscribeRequest 订阅请求数据（用于动态更新订阅）
 type SubscribeRequest struct {
 	Domains []string `json:"domains"` // 新的订阅域名列表
 }
 
+// SubscribeResult 订阅更新结果
+// 被拒绝的域名不会生效；全部被拒绝时服务端保留原有订阅
+type SubscribeResult struct {
+	Accepted []string         `json:"accepted"`           // 已生效的域名
+	Rejected []RejectedDomain `json:"rejected,omitempty"` // 被拒绝的域名及原因
+}
+
+// RejectedDomain 被拒绝订阅的域名
+type RejectedDomain struct {
+	Domain string `json:"domain"`
+	Reason string `json:"reason"`
+}
+
+// KeyRotationData 密钥轮换数据
+// 新密钥使用旧密钥加密并认证（security.SealRotationKey），附加认证数据为消息时间戳
+type KeyRotationData struct {
+	SealedKey string `json:"sealed_key"`
+}
+
+// CertRevokeData 域名下线数据
+type CertRevokeData struct {
+	Domain        string `json:"domain"`
+	PurgeDeployed bool   `json:"purge_deployed,omitempty"` // 同时删除站点配置中的部署文件
+	Force         bool   `json:"force,omitempty"`          // 没有匹配的站点配置时仍删除工作目录
+}
+
 // ErrorData 错误消息数据
 type ErrorData struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	Code              int    `json:"code"`
+	Message           string `json:"message"`
+	SupportedVersions []int  `json:"supported_versions,omitempty"` // 服务端支持的协议版本（仅 409 VersionNotSupported）
 }
 
 // ParseData 解析消息数据到指定类型
@@ -108,8 +157,9 @@ func (m *Message) ParseData(v interface{}) error {
 
 // CertRequest CLI 模式证书请求
 type CertRequest struct {
-	Domain string `json:"domain"`          // 请求的域名
-	Force  bool   `json:"force,omitempty"` // 强制更新（忽略时间戳检查）
+	Domain    string `json:"domain"`              // 请求的域名
+	Force     bool   `json:"force,omitempty"`     // 强制更新：忽略时间戳，总是重新读取并返回全部证书文件
+	Timestamp int64  `json:"timestamp,omitempty"` // 客户端本地时间戳，不早于服务端时只返回时间戳、不返回文件（0 表示本地无证书）
 }
 
 // CertResponse 证书响应
@@ -117,11 +167,16 @@ type CertResponse struct {
 	Domain    string            `json:"domain"`              // 域名
 	Files     map[string][]byte `json:"files,omitempty"`     // 文件名 -> 文件内容
 	Timestamp int64             `json:"timestamp,omitempty"` // 证书更新时间戳
+	Checksums map[string]string `json:"checksums,omitempty"` // 文件名 -> SHA-256（十六进制），未返回文件时同样提供
 	Error     string            `json:"error,omitempty"`     // 错误信息
+	// FileNames 服务端配置了非标准文件名时携带，客户端据此将 Files 与 Checksums 映射为标准文件名
+	FileNames *cert.FileNames `json:"file_names,omitempty"`
 }
 
-// StatusRequest 状态请求（空请求体）
-type StatusRequest struct{}
+// StatusRequest 状态请求
+type StatusRequest struct {
+	CheckCRL bool `json:"check_crl,omitempty"` // 通过 CRL 分发点检查各域名证书是否已被吊销（耗时较长）
+}
 
 // ClientStatusInfo 客户端状态信息
 type ClientStatusInfo struct {
@@ -139,8 +194,28 @@ type StatusResponse struct {
 	Error       string             `json:"error,omitempty"`   // 错误信息
 }
 
+// DomainEntry 域名列表项
+type DomainEntry struct {
+	Domain    string   `json:"domain"`    // 域名
+	Timestamp int64    `json:"timestamp"` // time.log 中的证书更新时间戳（0 表示没有 time.log）
+	Files     []string `json:"files"`     // 域名目录中的文件名
+}
+
+// ListResponse 域名列表响应
+// 只包含域名、时间戳与文件列表，比 StatusResponse 轻量，不解析证书
+type ListResponse struct {
+	Domains []DomainEntry `json:"domains"`
+	Error   string        `json:"error,omitempty"` // 错误信息
+}
+
 // SyncRequest 证书同步请求数据
 // 客户端发送本地各域名的时间戳，服务端比对后推送差异证书
 type SyncRequest struct {
 	Timestamps map[string]int64 `json:"timestamps"` // 域名 -> 本地时间戳（0 表示本地无此证书）
 }
+
+// SyncResult 证书同步结果
+// 与证书推送经同一发送队列发出，客户端收到时本次同步的推送已全部送达
+type SyncResult struct {
+	Pushed int `json:"pushed"` // 本次同步推送的证书数量
+}
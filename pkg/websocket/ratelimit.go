@@ -0,0 +1,42 @@
+package websocket
+
+import (
+	"sync"
+	"time"
+)
+
+// pushLimiter 按字节计量的令牌桶，所有证书推送共享
+// 大量证书同时续期时，BroadcastCert 按配置的速率逐个入队推送消息，平滑出站流量
+type pushLimiter struct {
+	mu     sync.Mutex
+	rate   float64   // 每秒补充的字节数，同时也是桶容量（允许 1 秒的突发）
+	tokens float64   // 当前可用字节数，为负表示已预支，需等待补充
+	last   time.Time // 上次补充令牌的时间
+}
+
+// newPushLimiter 创建速率为 bytesPerSec 的令牌桶，初始为满桶
+func newPushLimiter(bytesPerSec int, now time.Time) *pushLimiter {
+	return &pushLimiter{
+		rate:   float64(bytesPerSec),
+		tokens: float64(bytesPerSec),
+		last:   now,
+	}
+}
+
+// reserve 预支 n 字节，返回发送前需等待的时间
+// 并发调用按调用顺序排队，后到者的等待时间包含前者预支的部分
+func (l *pushLimiter) reserve(n int, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens = min(l.tokens+elapsed.Seconds()*l.rate, l.rate)
+		l.last = now
+	}
+
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
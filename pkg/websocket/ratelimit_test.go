@@ -0,0 +1,93 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPushLimiterReserve(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	l := newPushLimiter(1000, start)
+
+	steps := []struct {
+		name  string
+		n     int
+		at    time.Duration // 相对 start 的调用时间
+		want  time.Duration
+		delta time.Duration
+	}{
+		{"满桶内无需等待", 600, 0, 0, 0},
+		{"超出部分按速率等待", 600, 0, 200 * time.Millisecond, time.Millisecond},
+		{"预支后继续排队", 500, 0, 700 * time.Millisecond, time.Millisecond},
+		{"时间流逝补充令牌", 100, time.Second, 0, time.Millisecond},
+		{"补充不超过桶容量", 1000, 10 * time.Second, 0, 0},
+	}
+
+	for _, step := range steps {
+		got := l.reserve(step.n, start.Add(step.at))
+		if diff := got - step.want; diff < -step.delta || diff > step.delta {
+			t.Errorf("%s: reserve(%d) = %v, want %v", step.name, step.n, got, step.want)
+		}
+	}
+
+	// 桶已空，再次预支需等待
+	if got := l.reserve(1, start.Add(10*time.Second)); got <= 0 {
+		t.Errorf("桶为空时 reserve() = %v, want > 0", got)
+	}
+}
+
+func TestHubBroadcastCertRespectsPushRate(t *testing.T) {
+	const (
+		clients = 5
+		rate    = 200 * 1024 // 字节/秒
+	)
+
+	h := NewHub()
+	for i := 0; i < clients; i++ {
+		if err := h.registerClient(newTestClient(fmt.Sprintf("node-%d", i), "127.0.0.1", "example.com")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data := &CertPushData{
+		Domain: "example.com",
+		Files:  map[string][]byte{"fullchain.pem": bytes.Repeat([]byte("A"), 60*1024)},
+	}
+	msg, _ := NewMessage(MsgTypeCertPush, data)
+	raw, _ := json.Marshal(msg)
+	size := len(raw)
+
+	// 未限速时立即完成
+	start := time.Now()
+	if sent := h.BroadcastCert("example.com", data); sent != clients {
+		t.Fatalf("sent = %d, want %d", sent, clients)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("未限速时推送耗时 %v", elapsed)
+	}
+	for c := range h.clients {
+		<-c.send
+	}
+
+	// 限速后：满桶允许 rate 字节的突发，剩余部分按速率发送
+	h.SetPushRate(rate)
+	start = time.Now()
+	if sent := h.BroadcastCert("example.com", data); sent != clients {
+		t.Fatalf("sent = %d, want %d", sent, clients)
+	}
+	elapsed := time.Since(start)
+
+	want := time.Duration(float64(clients*size-rate) / rate * float64(time.Second))
+	if elapsed < want*8/10 || elapsed > want*2 {
+		t.Errorf("推送 %d 条 %d 字节消息耗时 %v，按 %d 字节/秒应约为 %v", clients, size, elapsed, rate, want)
+	}
+
+	// 关闭限速
+	h.SetPushRate(0)
+	if h.getPushLimiter() != nil {
+		t.Error("SetPushRate(0) 应关闭限速")
+	}
+}
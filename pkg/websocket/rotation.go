@@ -0,0 +1,169 @@
+package websocket
+
+import (
+	"errors"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/security"
+)
+
+// ErrKeyRotationInProgress 已有密钥轮换在进行中
+var ErrKeyRotationInProgress = errors.New("已有密钥轮换正在进行")
+
+// keyRotation 进行中的密钥轮换状态
+type keyRotation struct {
+	verifier *security.SignatureVerifier // 新密钥的签名校验器
+	pending  map[*Client]bool            // 轮换开始时在线、尚未用新密钥重新认证的连接
+	dropped  map[string]bool             // 轮换期间断开、尚未用新密钥重新连上的客户端 ID
+	reauthed []string                    // 已用新密钥重新认证的客户端 ID
+	done     chan struct{}               // 全部客户端完成重新认证时关闭
+}
+
+// checkDone 全部客户端完成重新认证时通知等待方
+func (r *keyRotation) checkDone() {
+	if len(r.pending) > 0 || len(r.dropped) > 0 {
+		return
+	}
+	select {
+	case <-r.done:
+	default:
+		close(r.done)
+	}
+}
+
+// KeyRotationResult 密钥轮换等待结果
+type KeyRotationResult struct {
+	Reauthenticated []string // 已用新密钥重新认证的客户端 ID
+	Missing         []string // 窗口结束时仍未重新认证的客户端 ID
+}
+
+// BeginKeyRotation 开始密钥轮换
+// 向所有已认证连接广播用旧密钥加密的新密钥，返回成功入队的连接数
+// 轮换期间新密钥即可用于认证，旧密钥仍然有效，直到调用方提交新密钥并调用 EndKeyRotation
+func (h *Hub) BeginKeyRotation(oldKey, newKey string) (int, error) {
+	timestamp := time.Now().Unix()
+	sealed, err := security.SealRotationKey(oldKey, newKey, timestamp)
+	if err != nil {
+		return 0, err
+	}
+	msg, err := NewMessage(MsgTypeKeyRotation, &KeyRotationData{SealedKey: sealed})
+	if err != nil {
+		return 0, err
+	}
+	msg.Timestamp = timestamp
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.rotation != nil {
+		return 0, ErrKeyRotationInProgress
+	}
+
+	rotation := &keyRotation{
+		verifier: security.NewSignatureVerifier(newKey),
+		pending:  make(map[*Client]bool, len(h.clients)),
+		dropped:  make(map[string]bool),
+		done:     make(chan struct{}),
+	}
+
+	sent := 0
+	for client := range h.clients {
+		rotation.pending[client] = true
+		select {
+		case client.send <- msg:
+			sent++
+		default:
+			slog.Warn("客户端发送缓冲区已满，未能下发密钥轮换", "client_id", client.ID)
+		}
+	}
+	rotation.checkDone()
+	h.rotation = rotation
+
+	slog.Info("🔑 密钥轮换开始", "clients", len(h.clients), "sent", sent)
+	return sent, nil
+}
+
+// WaitKeyRotation 等待所有客户端用新密钥重新认证，最长等待 timeout
+func (h *Hub) WaitKeyRotation(timeout time.Duration) KeyRotationResult {
+	h.mu.RLock()
+	rotation := h.rotation
+	h.mu.RUnlock()
+	if rotation == nil {
+		return KeyRotationResult{}
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-rotation.done:
+	case <-timer.C:
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	result := KeyRotationResult{
+		Reauthenticated: append([]string{}, rotation.reauthed...),
+	}
+	for client := range rotation.pending {
+		result.Missing = append(result.Missing, client.ID)
+	}
+	for id := range rotation.dropped {
+		result.Missing = append(result.Missing, id)
+	}
+	sort.Strings(result.Reauthenticated)
+	sort.Strings(result.Missing)
+	return result
+}
+
+// EndKeyRotation 结束密钥轮换，此后只有提交后的密钥可用于新的认证
+func (h *Hub) EndKeyRotation() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.rotation = nil
+}
+
+// rotationVerifier 返回进行中的轮换所用新密钥的校验器，无轮换时返回 nil
+func (h *Hub) rotationVerifier() *security.SignatureVerifier {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.rotation == nil {
+		return nil
+	}
+	return h.rotation.verifier
+}
+
+// completeReauth 记录客户端已用新密钥完成认证
+// 既可以是原连接上的重新认证，也可以是轮换期间断开后用新密钥重连
+func (h *Hub) completeReauth(client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	rotation := h.rotation
+	if rotation == nil {
+		return
+	}
+	switch {
+	case rotation.pending[client]:
+		delete(rotation.pending, client)
+	case rotation.dropped[client.ID]:
+		delete(rotation.dropped, client.ID)
+	default:
+		return
+	}
+	rotation.reauthed = append(rotation.reauthed, client.ID)
+	slog.Info("客户端已使用新密钥认证", "client_id", client.ID)
+	rotation.checkDone()
+}
+
+// forgetRotationClient 客户端断开时将其从待重新认证列表转入断开列表
+// 调用方必须持有 h.mu 写锁
+func (h *Hub) forgetRotationClient(client *Client) {
+	if h.rotation == nil || !h.rotation.pending[client] {
+		return
+	}
+	delete(h.rotation.pending, client)
+	h.rotation.dropped[client.ID] = true
+}
@@ -0,0 +1,125 @@
+package websocket
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/security"
+)
+
+func TestHub_KeyRotation(t *testing.T) {
+	hub := NewHub()
+	a := newTestClient("node-a", "10.0.0.1", "example.com")
+	b := newTestClient("node-b", "10.0.0.2", "example.com")
+	for _, c := range []*Client{a, b} {
+		if err := hub.registerClient(c); err != nil {
+			t.Fatalf("注册客户端失败: %v", err)
+		}
+	}
+
+	sent, err := hub.BeginKeyRotation("old-key", "new-key")
+	if err != nil {
+		t.Fatalf("开始密钥轮换失败: %v", err)
+	}
+	if sent != 2 {
+		t.Errorf("sent = %d, 期望 2", sent)
+	}
+	if _, err := hub.BeginKeyRotation("old-key", "other-key"); !errors.Is(err, ErrKeyRotationInProgress) {
+		t.Errorf("重复开始轮换应返回 ErrKeyRotationInProgress, got %v", err)
+	}
+
+	// 客户端应能用旧密钥解出新密钥
+	msg := <-a.send
+	if msg.Type != MsgTypeKeyRotation {
+		t.Fatalf("消息类型 = %q, 期望 %q", msg.Type, MsgTypeKeyRotation)
+	}
+	var data KeyRotationData
+	if err := msg.ParseData(&data); err != nil {
+		t.Fatalf("解析轮换数据失败: %v", err)
+	}
+	newKey, err := security.OpenRotationKey("old-key", data.SealedKey, msg.Timestamp)
+	if err != nil || newKey != "new-key" {
+		t.Fatalf("解出新密钥 = %q, %v", newKey, err)
+	}
+
+	// 轮换期间新密钥可用于认证
+	v := hub.rotationVerifier()
+	if v == nil {
+		t.Fatal("轮换期间应返回新密钥校验器")
+	}
+	now := time.Now().Unix()
+	if ok, _ := v.VerifySignature(security.NewSignatureVerifier("new-key").GenerateSignature(now), now); !ok {
+		t.Error("新密钥签名应通过校验")
+	}
+
+	// a 在原连接上重新认证；b 断开后用新密钥重连
+	hub.completeReauth(a)
+	hub.mu.Lock()
+	hub.removeClient(b)
+	hub.mu.Unlock()
+	hub.completeReauth(newTestClient("node-b", "10.0.0.2", "example.com"))
+
+	start := time.Now()
+	result := hub.WaitKeyRotation(5 * time.Second)
+	if time.Since(start) > time.Second {
+		t.Error("全部客户端重新认证后应立即返回")
+	}
+	if want := []string{"node-a", "node-b"}; !reflect.DeepEqual(result.Reauthenticated, want) {
+		t.Errorf("Reauthenticated = %v, 期望 %v", result.Reauthenticated, want)
+	}
+	if len(result.Missing) != 0 {
+		t.Errorf("Missing = %v, 期望为空", result.Missing)
+	}
+
+	hub.EndKeyRotation()
+	if hub.rotationVerifier() != nil {
+		t.Error("结束轮换后不应再接受轮换密钥")
+	}
+}
+
+func TestHub_KeyRotationTimeout(t *testing.T) {
+	hub := NewHub()
+	a := newTestClient("node-a", "10.0.0.1")
+	b := newTestClient("node-b", "10.0.0.2")
+	for _, c := range []*Client{a, b} {
+		if err := hub.registerClient(c); err != nil {
+			t.Fatalf("注册客户端失败: %v", err)
+		}
+	}
+
+	if _, err := hub.BeginKeyRotation("old-key", "new-key"); err != nil {
+		t.Fatalf("开始密钥轮换失败: %v", err)
+	}
+	defer hub.EndKeyRotation()
+
+	hub.completeReauth(a)
+	// 未参与轮换的新客户端不计入结果
+	hub.completeReauth(newTestClient("node-c", "10.0.0.3"))
+
+	result := hub.WaitKeyRotation(50 * time.Millisecond)
+	if want := []string{"node-a"}; !reflect.DeepEqual(result.Reauthenticated, want) {
+		t.Errorf("Reauthenticated = %v, 期望 %v", result.Reauthenticated, want)
+	}
+	if want := []string{"node-b"}; !reflect.DeepEqual(result.Missing, want) {
+		t.Errorf("Missing = %v, 期望 %v", result.Missing, want)
+	}
+}
+
+func TestHub_KeyRotationNoClients(t *testing.T) {
+	hub := NewHub()
+	if _, err := hub.BeginKeyRotation("old-key", "new-key"); err != nil {
+		t.Fatalf("开始密钥轮换失败: %v", err)
+	}
+	defer hub.EndKeyRotation()
+
+	start := time.Now()
+	result := hub.WaitKeyRotation(5 * time.Second)
+	if time.Since(start) > time.Second {
+		t.Error("无在线客户端时应立即返回")
+	}
+	if len(result.Reauthenticated) != 0 || len(result.Missing) != 0 {
+		t.Errorf("result = %+v, 期望为空", result)
+	}
+}
@@ -0,0 +1,38 @@
+package websocket
+
+import (
+	"fmt"
+	"slices"
+)
+
+// 协议版本
+const (
+	CurrentProtocolVersion = 1 // 当前协议版本，客户端在认证消息中携带
+
+	ErrCodeVersionNotSupported = 409 // 客户端协议版本高于服务端支持的版本
+)
+
+// SupportedProtocolVersions 服务端支持的协议版本（升序）
+var SupportedProtocolVersions = []int{1}
+
+// NegotiateVersion 选择双方都支持的协议版本（两者中较小的一个）
+// 未携带版本（0）视为版本 1，即引入版本字段之前的客户端
+func NegotiateVersion(serverVersion, clientVersion int) (int, error) {
+	if serverVersion < 0 || clientVersion < 0 {
+		return 0, fmt.Errorf("无效的协议版本: server=%d, client=%d", serverVersion, clientVersion)
+	}
+	return min(normalizeVersion(serverVersion), normalizeVersion(clientVersion)), nil
+}
+
+// normalizeVersion 将未携带版本的消息视为版本 1
+func normalizeVersion(v int) int {
+	if v == 0 {
+		return 1
+	}
+	return v
+}
+
+// versionSupported 判断服务端是否支持客户端的协议版本
+func versionSupported(clientVersion int) bool {
+	return slices.Contains(SupportedProtocolVersions, normalizeVersion(clientVersion))
+}
@@ -0,0 +1,26 @@
+package websocket
+
+import "testing"
+
+func TestNegotiateVersion(t *testing.T) {
+	tests := []struct {
+		server, client int
+		want           int
+		wantErr        bool
+	}{
+		{1, 1, 1, false},
+		{2, 1, 1, false},
+		{1, 3, 1, false},
+		{2, 0, 1, false}, // 未携带版本的旧客户端
+		{1, -1, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := NegotiateVersion(tt.server, tt.client)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NegotiateVersion(%d, %d) error = %v, wantErr %v", tt.server, tt.client, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("NegotiateVersion(%d, %d) = %d, want %d", tt.server, tt.client, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package workspace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// WriteFileAtomic 先写入同目录下的临时文件再 rename 为 path，读取方不会看到写了一半的文件
+// 临时文件名唯一（<文件名>.<随机串>.tmp），并发写入同一文件时互不干扰，最后完成的 rename 生效
+// sync 为 true 时 rename 前 fsync 临时文件、rename 后 fsync 上级目录，确保断电或崩溃后文件内容与目录项均已落盘
+func WriteFileAtomic(path string, content []byte, perm os.FileMode, sync bool) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("写入临时文件失败: %w", err)
+	}
+	tempPath := f.Name()
+	err = f.Chmod(perm)
+	if err == nil {
+		_, err = f.Write(content)
+	}
+	if err == nil && sync {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("写入临时文件失败: %w", err)
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("重命名文件失败: %w", err)
+	}
+	if sync {
+		if err := SyncDir(filepath.Dir(path)); err != nil {
+			return fmt.Errorf("同步目录失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// SyncDir fsync 目录，使其中新建、重命名的目录项落盘
+// Windows 不支持对目录 fsync，直接返回 nil
+func SyncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
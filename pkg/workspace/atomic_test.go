@@ -0,0 +1,114 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	for _, sync := range []bool{false, true} {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "cert.pem")
+		if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := WriteFileAtomic(path, []byte("new"), 0600, sync); err != nil {
+			t.Fatalf("sync=%v: WriteFileAtomic() error = %v", sync, err)
+		}
+		if data, err := os.ReadFile(path); err != nil || string(data) != "new" {
+			t.Errorf("sync=%v: 文件内容 = %q, %v, want new", sync, data, err)
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+			t.Errorf("sync=%v: 临时文件未清理: %v", sync, entries)
+		}
+	}
+
+	// 目录不存在时返回错误，不留下临时文件
+	missing := filepath.Join(t.TempDir(), "missing", "cert.pem")
+	if err := WriteFileAtomic(missing, []byte("x"), 0644, true); err == nil {
+		t.Error("目录不存在时 WriteFileAtomic() 应返回错误")
+	}
+}
+
+func TestWriteFileAtomicConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cert.pem")
+
+	// 并发写入同一文件使用各自的临时文件，最终内容为其中一次完整写入
+	contents := []string{strings.Repeat("a", 64<<10), strings.Repeat("b", 64<<10)}
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(content string) {
+			defer wg.Done()
+			errs <- WriteFileAtomic(path, []byte(content), 0644, false)
+		}(contents[i%2])
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("WriteFileAtomic() error = %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != contents[0] && string(data) != contents[1] {
+		t.Errorf("文件内容不是任何一次完整写入（长度 %d）", len(data))
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("临时文件未清理: %v", entries)
+	}
+}
+
+func TestWriteFileAtomicPerm(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows 不支持 Unix 权限位")
+	}
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := WriteFileAtomic(path, []byte("key"), 0600, true); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("权限 = %o, want 600", info.Mode().Perm())
+	}
+}
+
+func TestSyncDir(t *testing.T) {
+	if err := SyncDir(t.TempDir()); err != nil {
+		t.Errorf("SyncDir() error = %v", err)
+	}
+	if runtime.GOOS != "windows" {
+		if err := SyncDir(filepath.Join(t.TempDir(), "missing")); err == nil {
+			t.Error("目录不存在时 SyncDir() 应返回错误")
+		}
+	}
+}
+
+func TestWorkspaceSaveFileSyncOnWrite(t *testing.T) {
+	workDir := t.TempDir()
+	ws := NewWorkspace(workDir, "example.com")
+	ws.SetSyncOnWrite(true)
+	if err := ws.Ensure(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.SaveFileWithPerm("cert.pem", []byte("cert"), 0644); err != nil {
+		t.Fatalf("SaveFileWithPerm() error = %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(workDir, "example.com", "cert.pem")); err != nil || string(data) != "cert" {
+		t.Errorf("文件内容 = %q, %v", data, err)
+	}
+}
@@ -0,0 +1,131 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"log/slog"
+
+	"github.com/Catker/acmeDeliver/pkg/domainmatch"
+)
+
+// workspaceFiles 域名目录中由客户端写入的文件，目录中至少存在其一才视为工作目录产物
+var workspaceFiles = []string{"cert.pem", "key.pem", "fullchain.pem", "time.log", ChecksumFile, HistoryFile}
+
+// CleanupWorkdir 删除工作目录中不再受管理的域名目录，返回（dryRun 时为将要）删除的域名
+// keep 为当前管理/订阅的域名模式，匹配规则与订阅一致（见 domainmatch.Match），包含 "*" 时不删除任何目录。
+// 为避免误删，只处理 workDir 下一级、非隐藏、包含证书文件的目录，符号链接与锁被其他实例持有的目录会跳过
+func CleanupWorkdir(workDir string, keep []string, dryRun bool) ([]string, error) {
+	if workDir == "" {
+		return nil, errors.New("工作目录为空")
+	}
+	if len(keep) == 0 {
+		return nil, errors.New("保留列表为空，拒绝清理整个工作目录")
+	}
+
+	entries, err := os.ReadDir(workDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取工作目录失败: %w", err)
+	}
+
+	var removed []string
+	for _, entry := range entries {
+		// 跳过文件、符号链接与隐藏目录
+		name := entry.Name()
+		if !entry.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if managed(keep, name) || !isDomainDir(workDir, name) {
+			continue
+		}
+		if err := validatePathWithin(workDir, name); err != nil || filepath.Base(name) != name {
+			slog.Warn("跳过不安全的目录", "workdir", workDir, "dir", name, "error", err)
+			continue
+		}
+
+		if dryRun {
+			slog.Info("[演练] 将删除不再管理的域名目录", "domain", name)
+			removed = append(removed, name)
+			continue
+		}
+
+		// 持有锁期间删除，避免与正在部署该域名的 CLI 实例冲突；锁文件随目录一起删除，无需释放
+		if _, err := NewWorkspace(workDir, name).Lock(); err != nil {
+			slog.Warn("域名目录正在使用，跳过清理", "domain", name, "error", err)
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(workDir, name)); err != nil {
+			return removed, fmt.Errorf("删除域名目录 %s 失败: %w", name, err)
+		}
+		slog.Info("已删除不再管理的域名目录", "domain", name)
+		removed = append(removed, name)
+	}
+	return removed, nil
+}
+
+// managed 判断域名是否仍在 keep 中（支持通配符）
+func managed(keep []string, domain string) bool {
+	for _, pattern := range keep {
+		if domainmatch.Match(pattern, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// isDomainDir 判断目录是否由客户端创建（包含证书文件或时间戳）
+func isDomainDir(workDir, name string) bool {
+	for _, file := range workspaceFiles {
+		if _, err := os.Lstat(filepath.Join(workDir, name, file)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveDomain 删除工作目录中单个域名的目录（域名下线），返回是否（dryRun 时为将要）删除
+// 与 CleanupWorkdir 相同的安全限制：只处理 workDir 下一级、非隐藏、包含证书文件的目录，符号链接不删除；目录不存在时返回 false
+func RemoveDomain(workDir, domain string, dryRun bool) (bool, error) {
+	if workDir == "" {
+		return false, errors.New("工作目录为空")
+	}
+	if domain == "" || strings.HasPrefix(domain, ".") || filepath.Base(domain) != domain {
+		return false, fmt.Errorf("非法域名: %q", domain)
+	}
+	if err := validatePathWithin(workDir, domain); err != nil {
+		return false, err
+	}
+
+	dir := filepath.Join(workDir, domain)
+	info, err := os.Lstat(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("读取域名目录失败: %w", err)
+	}
+	if !info.IsDir() {
+		return false, fmt.Errorf("%s 不是目录（或为符号链接），拒绝删除", dir)
+	}
+	if !isDomainDir(workDir, domain) {
+		return false, fmt.Errorf("%s 不包含证书文件，不是工作目录产物，拒绝删除", dir)
+	}
+	if dryRun {
+		return true, nil
+	}
+
+	// 持有锁期间删除，避免与正在部署该域名的实例冲突；锁文件随目录一起删除，无需释放
+	if _, err := NewWorkspace(workDir, domain).Lock(); err != nil {
+		return false, fmt.Errorf("域名目录正在使用: %w", err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return false, fmt.Errorf("删除域名目录失败: %w", err)
+	}
+	return true, nil
+}
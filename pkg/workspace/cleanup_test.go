@@ -0,0 +1,168 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// makeDomainDir 在工作目录下创建包含 time.log 的域名目录
+func makeDomainDir(t *testing.T, workDir, domain string) string {
+	t.Helper()
+	dir := filepath.Join(workDir, domain)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "time.log"), []byte("1700000000"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+func TestCleanupWorkdir(t *testing.T) {
+	workDir := t.TempDir()
+	for _, domain := range []string{"example.com", "a.example.org", "old.com", "b.old.net"} {
+		makeDomainDir(t, workDir, domain)
+	}
+	// 非工作目录产物：普通目录、隐藏目录、文件、指向外部的符号链接
+	os.MkdirAll(filepath.Join(workDir, "notes"), 0755)
+	makeDomainDir(t, workDir, ".cache")
+	os.WriteFile(filepath.Join(workDir, "README"), []byte("x"), 0644)
+	outside := makeDomainDir(t, t.TempDir(), "outside.com")
+	os.Symlink(outside, filepath.Join(workDir, "link.com"))
+
+	keep := []string{"example.com", "*.example.org"}
+
+	// 演练模式只返回结果，不删除
+	removed, err := CleanupWorkdir(workDir, keep, true)
+	if err != nil {
+		t.Fatalf("CleanupWorkdir(dryRun) error = %v", err)
+	}
+	sort.Strings(removed)
+	if want := []string{"b.old.net", "old.com"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("CleanupWorkdir(dryRun) = %v, want %v", removed, want)
+	}
+	if !exists(filepath.Join(workDir, "old.com")) {
+		t.Fatal("演练模式不应删除目录")
+	}
+
+	removed, err = CleanupWorkdir(workDir, keep, false)
+	if err != nil {
+		t.Fatalf("CleanupWorkdir() error = %v", err)
+	}
+	sort.Strings(removed)
+	if want := []string{"b.old.net", "old.com"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("CleanupWorkdir() = %v, want %v", removed, want)
+	}
+
+	for _, name := range []string{"old.com", "b.old.net"} {
+		if exists(filepath.Join(workDir, name)) {
+			t.Errorf("%s 应被删除", name)
+		}
+	}
+	for _, name := range []string{"example.com", "a.example.org", "notes", ".cache", "README", "link.com"} {
+		if !exists(filepath.Join(workDir, name)) {
+			t.Errorf("%s 不应被删除", name)
+		}
+	}
+	if !exists(filepath.Join(outside, "time.log")) {
+		t.Error("符号链接指向的外部目录不应受影响")
+	}
+}
+
+func TestCleanupWorkdirKeepsAll(t *testing.T) {
+	workDir := t.TempDir()
+	makeDomainDir(t, workDir, "example.com")
+
+	removed, err := CleanupWorkdir(workDir, []string{"*"}, false)
+	if err != nil || len(removed) != 0 {
+		t.Errorf("全局订阅 CleanupWorkdir() = %v, %v; want nothing removed", removed, err)
+	}
+
+	if _, err := CleanupWorkdir(workDir, nil, false); err == nil {
+		t.Error("保留列表为空时应返回错误")
+	}
+	if !exists(filepath.Join(workDir, "example.com")) {
+		t.Error("example.com 不应被删除")
+	}
+
+	if removed, err := CleanupWorkdir(filepath.Join(workDir, "missing"), []string{"example.com"}, false); err != nil || removed != nil {
+		t.Errorf("工作目录不存在时 CleanupWorkdir() = %v, %v", removed, err)
+	}
+}
+
+func TestCleanupWorkdirSkipsLockedDir(t *testing.T) {
+	workDir := t.TempDir()
+	dir := makeDomainDir(t, workDir, "busy.com")
+	os.WriteFile(filepath.Join(dir, ".lock"), []byte(strconv.Itoa(os.Getppid())+"\n"), 0644)
+
+	removed, err := CleanupWorkdir(workDir, []string{"example.com"}, false)
+	if err != nil {
+		t.Fatalf("CleanupWorkdir() error = %v", err)
+	}
+	if len(removed) != 0 || !exists(dir) {
+		t.Errorf("锁被占用的目录不应删除，removed = %v", removed)
+	}
+}
+
+func TestRemoveDomain(t *testing.T) {
+	workDir := t.TempDir()
+	dir := makeDomainDir(t, workDir, "old.com")
+	makeDomainDir(t, workDir, "keep.com")
+	os.MkdirAll(filepath.Join(workDir, "notes"), 0755)
+	outside := makeDomainDir(t, t.TempDir(), "outside.com")
+	os.Symlink(outside, filepath.Join(workDir, "link.com"))
+
+	// 演练模式不删除
+	if removed, err := RemoveDomain(workDir, "old.com", true); err != nil || !removed || !exists(dir) {
+		t.Fatalf("RemoveDomain(dryRun) = %v, %v, exists = %v", removed, err, exists(dir))
+	}
+	if removed, err := RemoveDomain(workDir, "old.com", false); err != nil || !removed || exists(dir) {
+		t.Fatalf("RemoveDomain() = %v, %v, exists = %v", removed, err, exists(dir))
+	}
+	if !exists(filepath.Join(workDir, "keep.com")) {
+		t.Error("不应删除其他域名目录")
+	}
+
+	// 不存在的目录不报错
+	if removed, err := RemoveDomain(workDir, "missing.com", false); err != nil || removed {
+		t.Errorf("RemoveDomain(missing) = %v, %v", removed, err)
+	}
+
+	// 非工作目录产物、符号链接与非法名称均拒绝删除
+	for _, domain := range []string{"notes", "link.com", "..", "../x", ".hidden", "a/b", ""} {
+		if removed, err := RemoveDomain(workDir, domain, false); err == nil || removed {
+			t.Errorf("RemoveDomain(%q) = %v, %v, want error", domain, removed, err)
+		}
+	}
+	if !exists(filepath.Join(workDir, "notes")) || !exists(filepath.Join(outside, "time.log")) {
+		t.Error("拒绝删除的目录或符号链接目标被删除")
+	}
+}
+
+func TestValidatePathWithin(t *testing.T) {
+	base := t.TempDir()
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{"example.com", false},
+		{"cert.pem", false},
+		{"../etc", true},
+		{"a\\b", true},
+	}
+
+	for _, tt := range tests {
+		if err := validatePathWithin(base, tt.name); (err != nil) != tt.wantErr {
+			t.Errorf("validatePathWithin(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Catker/acmeDeliver/pkg/cert"
+)
+
+// ListDomains 扫描工作目录下的域名子目录，返回 域名 -> 本地时间戳（time.log）
+// 缺少 time.log 的目录同样包含在结果中，时间戳为 0（表示需要同步）
+func ListDomains(workDir string) (map[string]int64, error) {
+	entries, err := os.ReadDir(workDir)
+	if err != nil {
+		return nil, err
+	}
+
+	domains := make(map[string]int64)
+	for _, entry := range entries {
+		// 跳过文件与隐藏目录
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		domains[entry.Name()] = GetDomainTimestamp(workDir, entry.Name())
+	}
+	return domains, nil
+}
+
+// GetDomainTimestamp 读取指定域名的本地时间戳
+// time.log 不存在或格式错误时返回 0
+func GetDomainTimestamp(workDir, domain string) int64 {
+	content, err := os.ReadFile(filepath.Join(workDir, domain, "time.log"))
+	if err != nil {
+		return 0
+	}
+	t, _ := cert.ParseTimeLog(content)
+	return t
+}
@@ -0,0 +1,75 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListDomains(t *testing.T) {
+	workDir := t.TempDir()
+
+	files := map[string]string{
+		"example.com/time.log":    "1700000000\n",
+		"long.example/time.log":   "1700000000123", // 毫秒时间戳截取前 10 位
+		"broken.example/time.log": "not-a-number",
+		"nolog.example/cert.pem":  "cert",
+		".hidden/time.log":        "1700000000",
+	}
+	for name, content := range files {
+		path := filepath.Join(workDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(workDir, "stray.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ListDomains(workDir)
+	if err != nil {
+		t.Fatalf("ListDomains() error = %v", err)
+	}
+
+	want := map[string]int64{
+		"example.com":    1700000000,
+		"long.example":   1700000000,
+		"broken.example": 0,
+		"nolog.example":  0,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ListDomains() = %v, want %v", got, want)
+	}
+	for domain, ts := range want {
+		if v, ok := got[domain]; !ok || v != ts {
+			t.Errorf("ListDomains()[%q] = %d (present=%v), want %d", domain, v, ok, ts)
+		}
+	}
+}
+
+func TestListDomains_MissingWorkDir(t *testing.T) {
+	_, err := ListDomains(filepath.Join(t.TempDir(), "missing"))
+	if !os.IsNotExist(err) {
+		t.Errorf("ListDomains() error = %v, want not-exist error", err)
+	}
+}
+
+func TestGetDomainTimestamp(t *testing.T) {
+	workDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(workDir, "example.com"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(workDir, "example.com", "time.log"), []byte(" 1700000000 "), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := GetDomainTimestamp(workDir, "example.com"); got != 1700000000 {
+		t.Errorf("GetDomainTimestamp() = %d, want 1700000000", got)
+	}
+	if got := GetDomainTimestamp(workDir, "missing.com"); got != 0 {
+		t.Errorf("GetDomainTimestamp(missing) = %d, want 0", got)
+	}
+}
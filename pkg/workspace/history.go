@@ -0,0 +1,140 @@
+package workspace
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"log/slog"
+
+	"github.com/Catker/acmeDeliver/pkg/cert"
+)
+
+// HistoryFile 部署历史文件，每行一条 JSON 格式的 DeployRecord，按部署时间追加
+const HistoryFile = "deploy_history.jsonl"
+
+// DeployerLocal 本地文件部署（ConfigDrivenDeployer）
+const DeployerLocal = "local"
+
+// DeployRecord 单次成功部署的记录
+type DeployRecord struct {
+	DeployedAt   time.Time `json:"deployed_at"`         // 部署时间
+	Fingerprint  string    `json:"fingerprint"`         // 叶子证书 DER 的 SHA-256（十六进制）
+	KeyAlgorithm string    `json:"key_algorithm"`       // 公钥算法，如 RSA-2048、ECDSA-P-256
+	NotAfter     time.Time `json:"not_after"`           // 证书过期时间
+	Deployer     string    `json:"deployer"`            // 部署方式：local/remote/k8s
+	ReloadOK     *bool     `json:"reload_ok,omitempty"` // 重载命令是否成功，未执行重载或结果未知时为空
+}
+
+// NewDeployRecord 根据证书内容生成部署记录
+func NewDeployRecord(certPEM []byte, deployer string, reloadOK *bool, now time.Time) (DeployRecord, error) {
+	c, err := cert.ParseCertificate(certPEM)
+	if err != nil {
+		return DeployRecord{}, fmt.Errorf("解析证书失败: %w", err)
+	}
+	sum := sha256.Sum256(c.Raw)
+	return DeployRecord{
+		DeployedAt:   now,
+		Fingerprint:  hex.EncodeToString(sum[:]),
+		KeyAlgorithm: keyAlgorithm(c.PublicKey),
+		NotAfter:     c.NotAfter,
+		Deployer:     deployer,
+		ReloadOK:     reloadOK,
+	}, nil
+}
+
+// keyAlgorithm 返回公钥算法及长度/曲线的描述
+func keyAlgorithm(pub any) string {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA-%d", k.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA-" + k.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	default:
+		return fmt.Sprintf("%T", pub)
+	}
+}
+
+// AppendDeployHistory 追加一条部署记录，同时删除部署时间早于 retention 的旧记录（retention 为 0 时全部保留）
+// 文件整体重写后原子替换，写入中断不会留下半行记录
+func AppendDeployHistory(workDir, domain string, rec DeployRecord, retention time.Duration) error {
+	records, err := readDeployHistory(filepath.Join(workDir, domain, HistoryFile))
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	pruned := 0
+	for _, r := range records {
+		if retention > 0 && rec.DeployedAt.Sub(r.DeployedAt) > retention {
+			pruned++
+			continue
+		}
+		if err := enc.Encode(r); err != nil {
+			return fmt.Errorf("编码部署记录失败: %w", err)
+		}
+	}
+	if err := enc.Encode(rec); err != nil {
+		return fmt.Errorf("编码部署记录失败: %w", err)
+	}
+	if pruned > 0 {
+		slog.Debug("已清理过期的部署记录", "domain", domain, "count", pruned)
+	}
+
+	return NewWorkspace(workDir, domain).SaveFileWithPerm(HistoryFile, buf.Bytes(), 0644)
+}
+
+// GetDeployHistory 读取域名最近 limit 条部署记录（按部署顺序，最新的在最后），limit <= 0 时返回全部
+// 尚无部署历史时返回空切片
+func GetDeployHistory(workDir, domain string, limit int) ([]DeployRecord, error) {
+	records, err := readDeployHistory(filepath.Join(workDir, domain, HistoryFile))
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(records) > limit {
+		records = records[len(records)-limit:]
+	}
+	return records, nil
+}
+
+// readDeployHistory 读取部署历史文件，无法解析的行记录警告后跳过，文件不存在时返回 nil
+func readDeployHistory(path string) ([]DeployRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取部署历史失败: %w", err)
+	}
+	defer f.Close()
+
+	var records []DeployRecord
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var rec DeployRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			slog.Warn("跳过无法解析的部署记录", "file", path, "line", line, "error", err)
+			continue
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取部署历史失败: %w", err)
+	}
+	return records, nil
+}
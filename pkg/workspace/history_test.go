@@ -0,0 +1,135 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewDeployRecord(t *testing.T) {
+	certPEM, _ := generateKeyPair(t)
+	ok := true
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	rec, err := NewDeployRecord(certPEM, DeployerLocal, &ok, now)
+	if err != nil {
+		t.Fatalf("NewDeployRecord() error = %v", err)
+	}
+	if !rec.DeployedAt.Equal(now) || rec.Deployer != DeployerLocal || rec.ReloadOK == nil || !*rec.ReloadOK {
+		t.Errorf("记录 = %+v", rec)
+	}
+	if rec.KeyAlgorithm != "ECDSA-P-256" {
+		t.Errorf("KeyAlgorithm = %q, want ECDSA-P-256", rec.KeyAlgorithm)
+	}
+	if len(rec.Fingerprint) != 64 {
+		t.Errorf("Fingerprint = %q, want 64 位十六进制", rec.Fingerprint)
+	}
+	if rec.NotAfter.IsZero() {
+		t.Error("NotAfter 未填充")
+	}
+
+	if _, err := NewDeployRecord([]byte("not pem"), DeployerLocal, nil, now); err == nil {
+		t.Error("证书无效时应返回错误")
+	}
+}
+
+func TestDeployHistory(t *testing.T) {
+	workDir := t.TempDir()
+	domain := "example.com"
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := NewWorkspace(workDir, domain).Ensure(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := GetDeployHistory(workDir, domain, 10)
+	if err != nil || len(records) != 0 {
+		t.Fatalf("无历史时 GetDeployHistory() = %v, %v", records, err)
+	}
+
+	for i := 0; i < 3; i++ {
+		rec := DeployRecord{DeployedAt: base.Add(time.Duration(i) * time.Hour), Fingerprint: string(rune('a' + i)), Deployer: DeployerLocal}
+		if err := AppendDeployHistory(workDir, domain, rec, 0); err != nil {
+			t.Fatalf("AppendDeployHistory() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		limit int
+		want  string
+	}{
+		{"最近两条", 2, "bc"},
+		{"超过记录数", 10, "abc"},
+		{"不限制", 0, "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := GetDeployHistory(workDir, domain, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got strings.Builder
+			for _, r := range records {
+				got.WriteString(r.Fingerprint)
+			}
+			if got.String() != tt.want {
+				t.Errorf("记录顺序 = %q, want %q", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestAppendDeployHistoryPrunes(t *testing.T) {
+	workDir := t.TempDir()
+	domain := "example.com"
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := NewWorkspace(workDir, domain).Ensure(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, age := range []int{10, 5, 1} {
+		rec := DeployRecord{DeployedAt: base.AddDate(0, 0, -age), Fingerprint: string(rune('0' + age%10))}
+		if err := AppendDeployHistory(workDir, domain, rec, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 保留 7 天：10 天前的记录被清理
+	if err := AppendDeployHistory(workDir, domain, DeployRecord{DeployedAt: base, Fingerprint: "n"}, 7*24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := GetDeployHistory(workDir, domain, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got strings.Builder
+	for _, r := range records {
+		got.WriteString(r.Fingerprint)
+	}
+	if got.String() != "51n" {
+		t.Errorf("清理后记录 = %q, want %q", got.String(), "51n")
+	}
+}
+
+func TestGetDeployHistorySkipsInvalidLines(t *testing.T) {
+	workDir := t.TempDir()
+	domain := "example.com"
+	if err := os.MkdirAll(filepath.Join(workDir, domain), 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := `{"deployed_at":"2026-01-01T00:00:00Z","fingerprint":"a"}` + "\n\nnot json\n" +
+		`{"deployed_at":"2026-01-02T00:00:00Z","fingerprint":"b"}` + "\n"
+	if err := os.WriteFile(filepath.Join(workDir, domain, HistoryFile), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := GetDeployHistory(workDir, domain, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[1].Fingerprint != "b" {
+		t.Errorf("records = %+v", records)
+	}
+}
@@ -0,0 +1,160 @@
+package workspace
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Catker/acmeDeliver/pkg/cert"
+)
+
+// ChecksumFile 证书文件校验和，格式与 sha256sum 输出一致，可用 sha256sum -c 校验
+const ChecksumFile = "checksum.sha256"
+
+// ErrChecksumMismatch 证书文件内容与记录的校验和不一致（位腐烂或写入不完整）
+var ErrChecksumMismatch = errors.New("证书文件校验和不匹配")
+
+// verifiedFiles 参与校验的证书文件（顺序即校验和文件中的顺序）
+var verifiedFiles = []string{"cert.pem", "key.pem", "fullchain.pem"}
+
+// Verify 校验域名目录中已保存证书的完整性
+// 只校验存在的证书文件（服务端可能不提供 fullchain.pem 等文件），存在的文件必须非空且为 PEM 格式，
+// 证书与私钥均存在时必须匹配；已有 checksum.sha256 时与之比对，记录的文件缺失时返回错误，
+// 内容不一致返回 ErrChecksumMismatch，否则校验通过后写入
+func Verify(workDir, domain string) error {
+	domainDir := filepath.Join(workDir, domain)
+
+	recorded, err := readChecksums(filepath.Join(domainDir, ChecksumFile))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	contents := make(map[string][]byte, len(verifiedFiles))
+	for _, name := range verifiedFiles {
+		data, err := os.ReadFile(filepath.Join(domainDir, name))
+		if os.IsNotExist(err) && recorded[name] == "" {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("读取 %s 失败: %w", name, err)
+		}
+		if len(data) == 0 {
+			return fmt.Errorf("%s 为空", name)
+		}
+		contents[name] = data
+	}
+	if len(contents) == 0 {
+		return fmt.Errorf("没有证书文件")
+	}
+
+	sums := make(map[string]string, len(contents))
+	for name, data := range contents {
+		sums[name] = checksum(data)
+	}
+
+	if recorded != nil {
+		for _, name := range verifiedFiles {
+			if recorded[name] != sums[name] {
+				return fmt.Errorf("%w: %s", ErrChecksumMismatch, name)
+			}
+		}
+	}
+
+	for _, name := range verifiedFiles {
+		if data, ok := contents[name]; ok {
+			if block, _ := pem.Decode(data); block == nil {
+				return fmt.Errorf("%s 不是有效的 PEM 格式", name)
+			}
+		}
+	}
+	if contents["cert.pem"] != nil && contents["key.pem"] != nil {
+		if err := cert.VerifyKeyPair(contents["cert.pem"], contents["key.pem"]); err != nil {
+			return err
+		}
+	}
+
+	if recorded != nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	for _, name := range verifiedFiles {
+		if sum, ok := sums[name]; ok {
+			fmt.Fprintf(&buf, "%s  %s\n", sum, name)
+		}
+	}
+	return NewWorkspace(workDir, domain).SaveFileWithPerm(ChecksumFile, buf.Bytes(), 0644)
+}
+
+// VerifyAll 校验工作目录下所有域名，返回 域名 -> 校验结果（nil 表示通过）
+func VerifyAll(workDir string) (map[string]error, error) {
+	domains, err := ListDomains(workDir)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]error, len(domains))
+	for domain := range domains {
+		results[domain] = Verify(workDir, domain)
+	}
+	return results, nil
+}
+
+// MatchesChecksums 判断本地证书文件是否与服务端校验和（文件名 -> SHA-256）一致
+// 只比对 cert.pem、key.pem、fullchain.pem；sums 为空或缺少其中任一文件时返回 false
+func (ws *Workspace) MatchesChecksums(sums map[string]string) bool {
+	if len(sums) == 0 {
+		return false
+	}
+	for _, name := range verifiedFiles {
+		want, ok := sums[name]
+		if !ok {
+			return false
+		}
+		data, err := os.ReadFile(filepath.Join(ws.workDir, ws.domain, name))
+		if err != nil || !strings.EqualFold(checksum(data), want) {
+			return false
+		}
+	}
+	return true
+}
+
+// checksum 返回 data 的 SHA-256（十六进制）
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ClearChecksum 删除记录的校验和，证书更新前调用，由下一次 Verify 重新记录
+func ClearChecksum(workDir, domain string) error {
+	err := os.Remove(filepath.Join(workDir, domain, ChecksumFile))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// readChecksums 解析 sha256sum 格式的校验和文件，返回 文件名 -> 十六进制摘要
+func readChecksums(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		sums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+	}
+	return sums, nil
+}
@@ -0,0 +1,238 @@
+package workspace
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Catker/acmeDeliver/pkg/testutil"
+)
+
+// generateKeyPair 生成自签名证书及其私钥（PEM）
+func generateKeyPair(t *testing.T) (certPEM, keyPEM []byte) {
+	t.Helper()
+	certPEM, keyPEM, err := testutil.GenerateSelfSignedCert("example.com", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certPEM, keyPEM
+}
+
+// saveTestCerts 通过 SaveCertificateFiles 保存一组有效证书
+func saveTestCerts(t *testing.T, workDir, domain string) *CertificateFiles {
+	t.Helper()
+	certPEM, keyPEM := generateKeyPair(t)
+	certs := &CertificateFiles{Cert: certPEM, Key: keyPEM, Fullchain: certPEM}
+	ws := NewWorkspace(workDir, domain)
+	if err := ws.Ensure(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.SaveCertificateFiles(certs); err != nil {
+		t.Fatalf("SaveCertificateFiles() error = %v", err)
+	}
+	return certs
+}
+
+func TestSaveCertificateFilesRecordsChecksum(t *testing.T) {
+	workDir := t.TempDir()
+	saveTestCerts(t, workDir, "example.com")
+
+	data, err := os.ReadFile(filepath.Join(workDir, "example.com", ChecksumFile))
+	if err != nil {
+		t.Fatalf("应写入 %s: %v", ChecksumFile, err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 || !strings.HasSuffix(lines[0], "  cert.pem") {
+		t.Errorf("校验和文件格式错误:\n%s", data)
+	}
+
+	if err := Verify(workDir, "example.com"); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+
+	// 更新证书后校验和随之刷新
+	saveTestCerts(t, workDir, "example.com")
+	if err := Verify(workDir, "example.com"); err != nil {
+		t.Errorf("更新证书后 Verify() error = %v", err)
+	}
+}
+
+func TestSaveCertificateFilesWithoutFullchain(t *testing.T) {
+	// 服务端只提供证书与私钥时，只校验并记录已保存的文件
+	workDir := t.TempDir()
+	certPEM, keyPEM := generateKeyPair(t)
+	ws := NewWorkspace(workDir, "example.com")
+	if err := ws.Ensure(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.SaveCertificateFiles(&CertificateFiles{Cert: certPEM, Key: keyPEM}); err != nil {
+		t.Fatalf("SaveCertificateFiles() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(workDir, "example.com", ChecksumFile))
+	if err != nil {
+		t.Fatalf("应写入 %s: %v", ChecksumFile, err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 2 || strings.Contains(string(data), "fullchain.pem") {
+		t.Errorf("校验和文件应只包含 cert.pem 与 key.pem:\n%s", data)
+	}
+	if err := Verify(workDir, "example.com"); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+
+	// 记录过的文件被删除时仍校验失败
+	os.Remove(filepath.Join(workDir, "example.com", "key.pem"))
+	if err := Verify(workDir, "example.com"); err == nil {
+		t.Error("记录过的 key.pem 缺失时 Verify() 应返回错误")
+	}
+}
+
+func TestVerifyDetectsCorruption(t *testing.T) {
+	tests := []struct {
+		name     string
+		corrupt  func(t *testing.T, domainDir string)
+		mismatch bool // 期望 ErrChecksumMismatch
+	}{
+		{
+			name: "内容被篡改",
+			corrupt: func(t *testing.T, domainDir string) {
+				path := filepath.Join(domainDir, "key.pem")
+				data, _ := os.ReadFile(path)
+				data[len(data)/2] ^= 0x01
+				os.WriteFile(path, data, 0600)
+			},
+			mismatch: true,
+		},
+		{
+			name: "写入不完整",
+			corrupt: func(t *testing.T, domainDir string) {
+				path := filepath.Join(domainDir, "fullchain.pem")
+				data, _ := os.ReadFile(path)
+				os.WriteFile(path, data[:len(data)/2], 0644)
+			},
+			mismatch: true,
+		},
+		{
+			name: "文件为空",
+			corrupt: func(t *testing.T, domainDir string) {
+				os.WriteFile(filepath.Join(domainDir, "cert.pem"), nil, 0644)
+			},
+		},
+		{
+			name: "文件缺失",
+			corrupt: func(t *testing.T, domainDir string) {
+				os.Remove(filepath.Join(domainDir, "key.pem"))
+			},
+		},
+		{
+			name: "无校验和时私钥不匹配",
+			corrupt: func(t *testing.T, domainDir string) {
+				os.Remove(filepath.Join(domainDir, ChecksumFile))
+				_, otherKey := generateKeyPair(t)
+				os.WriteFile(filepath.Join(domainDir, "key.pem"), otherKey, 0600)
+			},
+		},
+		{
+			name: "无校验和时不是 PEM",
+			corrupt: func(t *testing.T, domainDir string) {
+				os.Remove(filepath.Join(domainDir, ChecksumFile))
+				os.WriteFile(filepath.Join(domainDir, "fullchain.pem"), []byte("garbage"), 0644)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workDir := t.TempDir()
+			saveTestCerts(t, workDir, "example.com")
+			tt.corrupt(t, filepath.Join(workDir, "example.com"))
+
+			err := Verify(workDir, "example.com")
+			if err == nil {
+				t.Fatal("Verify() 应返回错误")
+			}
+			if got := errors.Is(err, ErrChecksumMismatch); got != tt.mismatch {
+				t.Errorf("errors.Is(ErrChecksumMismatch) = %v, want %v (err = %v)", got, tt.mismatch, err)
+			}
+		})
+	}
+}
+
+func TestVerifyAll(t *testing.T) {
+	workDir := t.TempDir()
+	saveTestCerts(t, workDir, "good.example")
+	saveTestCerts(t, workDir, "bad.example")
+	os.WriteFile(filepath.Join(workDir, "bad.example", "cert.pem"), []byte("corrupted"), 0644)
+
+	results, err := VerifyAll(workDir)
+	if err != nil {
+		t.Fatalf("VerifyAll() error = %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("results = %v, want 2 domains", results)
+	}
+	if results["good.example"] != nil {
+		t.Errorf("good.example error = %v", results["good.example"])
+	}
+	if !errors.Is(results["bad.example"], ErrChecksumMismatch) {
+		t.Errorf("bad.example error = %v, want ErrChecksumMismatch", results["bad.example"])
+	}
+}
+
+func TestClearChecksum(t *testing.T) {
+	workDir := t.TempDir()
+	if err := ClearChecksum(workDir, "missing.example"); err != nil {
+		t.Errorf("不存在的校验和不应报错: %v", err)
+	}
+
+	saveTestCerts(t, workDir, "example.com")
+	if err := ClearChecksum(workDir, "example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "example.com", ChecksumFile)); !os.IsNotExist(err) {
+		t.Error("校验和文件应被删除")
+	}
+}
+
+func TestMatchesChecksums(t *testing.T) {
+	workDir := t.TempDir()
+	certs := saveTestCerts(t, workDir, "example.com")
+	ws := NewWorkspace(workDir, "example.com")
+
+	sums := map[string]string{
+		"cert.pem":      checksum(certs.Cert),
+		"key.pem":       checksum(certs.Key),
+		"fullchain.pem": checksum(certs.Fullchain),
+	}
+	upper := make(map[string]string, len(sums))
+	for name, sum := range sums {
+		upper[name] = strings.ToUpper(sum)
+	}
+	partial := map[string]string{"cert.pem": sums["cert.pem"]}
+	changed := map[string]string{"cert.pem": sums["cert.pem"], "key.pem": sums["key.pem"], "fullchain.pem": checksum([]byte("new"))}
+
+	tests := []struct {
+		name string
+		ws   *Workspace
+		sums map[string]string
+		want bool
+	}{
+		{"一致", ws, sums, true},
+		{"大写摘要", ws, upper, true},
+		{"服务端文件已变化", ws, changed, false},
+		{"缺少文件的校验和", ws, partial, false},
+		{"未提供校验和", ws, nil, false},
+		{"本地未保存", NewWorkspace(workDir, "missing.com"), sums, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.ws.MatchesChecksums(tt.sums); got != tt.want {
+				t.Errorf("MatchesChecksums() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,22 +1,25 @@
 package workspace
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"log/slog"
 
-	"github.com/Catker/acmeDeliver/pkg/client"
 	"github.com/nightlyone/lockfile"
 )
 
 // Workspace 管理客户端的工作目录
 type Workspace struct {
-	workDir   string
-	domain    string
-	domainDir string
+	workDir     string
+	domain      string
+	domainDir   string
+	syncOnWrite bool // 写入后 fsync 文件与目录
 }
 
 // NewWorkspace 创建新的工作空间管理器
@@ -45,6 +48,11 @@ func (ws *Workspace) Ensure() error {
 	return nil
 }
 
+// SetSyncOnWrite 设置保存文件时是否 fsync 文件及所在目录（sync_on_write）
+func (ws *Workspace) SetSyncOnWrite(sync bool) {
+	ws.syncOnWrite = sync
+}
+
 // GetWorkDir 获取主工作目录
 func (ws *Workspace) GetWorkDir() string {
 	return ws.workDir
@@ -52,13 +60,18 @@ func (ws *Workspace) GetWorkDir() string {
 
 // validateFilename 验证文件名是否安全
 func (ws *Workspace) validateFilename(filename string) error {
+	return validatePathWithin(ws.domainDir, filename)
+}
+
+// validatePathWithin 验证 name 拼接到 baseDir 后仍位于 baseDir 内
+func validatePathWithin(baseDir, name string) error {
 	// 检查路径遍历攻击
-	if strings.Contains(filename, "..") || strings.Contains(filename, "\\") {
-		return fmt.Errorf("不安全的文件名: %s", filename)
+	if strings.Contains(name, "..") || strings.Contains(name, "\\") {
+		return fmt.Errorf("不安全的文件名: %s", name)
 	}
 
 	// 构建完整路径
-	fullPath := filepath.Join(ws.domainDir, filename)
+	fullPath := filepath.Join(baseDir, name)
 
 	// 获取绝对路径
 	absFullPath, err := filepath.Abs(fullPath)
@@ -67,14 +80,14 @@ func (ws *Workspace) validateFilename(filename string) error {
 	}
 
 	// 获取工作目录的绝对路径
-	absDomainDir, err := filepath.Abs(ws.domainDir)
+	absBaseDir, err := filepath.Abs(baseDir)
 	if err != nil {
 		return fmt.Errorf("无法解析工作目录路径: %w", err)
 	}
 
 	// 确保文件路径在工作目录内
-	if !strings.HasPrefix(absFullPath, absDomainDir) {
-		return fmt.Errorf("文件路径超出工作目录范围: %s", filename)
+	if !strings.HasPrefix(absFullPath, absBaseDir) {
+		return fmt.Errorf("文件路径超出工作目录范围: %s", name)
 	}
 
 	return nil
@@ -90,13 +103,7 @@ func (ws *Workspace) SaveFileWithPerm(filename string, content []byte, perm os.F
 	filePath := filepath.Join(ws.domainDir, filename)
 
 	// 先写入临时文件，然后原子性重命名
-	tempPath := filePath + ".tmp"
-	if err := os.WriteFile(tempPath, content, perm); err != nil {
-		return fmt.Errorf("写入临时文件失败: %w", err)
-	}
-
-	if err := os.Rename(tempPath, filePath); err != nil {
-		os.Remove(tempPath) // 清理临时文件
+	if err := WriteFileAtomic(filePath, content, perm, ws.syncOnWrite); err != nil {
 		return fmt.Errorf("保存文件失败: %w", err)
 	}
 
@@ -104,26 +111,82 @@ func (ws *Workspace) SaveFileWithPerm(filename string, content []byte, perm os.F
 	return nil
 }
 
-// Lock 获取文件锁，防止并发操作
+// lockRetryInterval 等待文件锁时的重试间隔
+const lockRetryInterval = 500 * time.Millisecond
+
+// Lock 获取文件锁，防止并发操作；锁被其他实例持有时立即失败
 func (ws *Workspace) Lock() (*lockfile.Lockfile, error) {
+	return ws.LockWithTimeout(0)
+}
+
+// LockWithTimeout 获取文件锁，锁被其他存活进程持有时按 lockRetryInterval 重试，直到超过 d
+// 持有进程已退出的残留锁会被清理后直接获取；超时的错误信息包含锁文件中记录的持有进程 PID
+func (ws *Workspace) LockWithTimeout(d time.Duration) (*lockfile.Lockfile, error) {
 	lockFilePath := filepath.Join(ws.domainDir, ".lock")
 	fileLock, err := lockfile.New(lockFilePath)
 	if err != nil {
 		return nil, fmt.Errorf("创建文件锁失败: %w", err)
 	}
 
-	// 尝试获取锁
-	if err := fileLock.TryLock(); err != nil {
-		slog.Info("另一个实例正在运行", "domain", ws.domain, "error", err)
-		return nil, fmt.Errorf("另一个实例正在运行: %w", err)
+	deadline := time.Now().Add(d)
+	waiting := false
+	for {
+		ws.logStaleLock(fileLock)
+
+		err := fileLock.TryLock()
+		if err == nil {
+			slog.Debug("获取文件锁成功", "domain", ws.domain)
+			return &fileLock, nil
+		}
+
+		// 仅锁被占用等临时错误值得重试
+		var tempErr lockfile.TemporaryError
+		if !errors.As(err, &tempErr) || !time.Now().Add(lockRetryInterval).Before(deadline) {
+			pid, alive := lockOwner(fileLock)
+			slog.Info("另一个实例正在运行", "domain", ws.domain, "pid", pid, "alive", alive, "error", err)
+			if pid > 0 {
+				return nil, fmt.Errorf("另一个实例正在运行（PID %d）: %w", pid, err)
+			}
+			return nil, fmt.Errorf("另一个实例正在运行: %w", err)
+		}
+
+		if !waiting {
+			pid, alive := lockOwner(fileLock)
+			slog.Info("文件锁被占用，等待释放", "domain", ws.domain, "pid", pid, "alive", alive, "timeout", d)
+			waiting = true
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// logStaleLock 锁文件的持有进程已不存在时记录日志，随后由 TryLock 清理
+func (ws *Workspace) logStaleLock(fileLock lockfile.Lockfile) {
+	if pid, alive := lockOwner(fileLock); pid > 0 && !alive {
+		slog.Warn("发现残留的文件锁，持有进程已退出，将自动清理", "domain", ws.domain, "pid", pid)
 	}
+}
 
-	slog.Debug("获取文件锁成功", "domain", ws.domain)
-	return &fileLock, nil
+// lockOwner 返回锁文件记录的持有进程 PID 及其是否存活，无锁文件或内容无效时 pid 为 0
+func lockOwner(fileLock lockfile.Lockfile) (pid int, alive bool) {
+	content, err := os.ReadFile(string(fileLock))
+	if err != nil {
+		return 0, false
+	}
+	pid, err = strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil || pid <= 0 {
+		return 0, false
+	}
+	proc, err := fileLock.GetOwner()
+	return pid, err == nil && proc != nil
 }
 
-// SaveCertificateFiles 保存所有证书文件
-func (ws *Workspace) SaveCertificateFiles(certs *client.CertificateFiles) error {
+// SaveCertificateFiles 保存所有证书文件，保存后校验完整性并记录校验和
+func (ws *Workspace) SaveCertificateFiles(certs *CertificateFiles) error {
+	// 旧校验和对应更新前的证书，先删除再由 Verify 重新记录
+	if err := ClearChecksum(ws.workDir, ws.domain); err != nil {
+		return fmt.Errorf("删除旧校验和失败: %w", err)
+	}
+
 	files := map[string][]byte{
 		"cert.pem":      certs.Cert,
 		"key.pem":       certs.Key,
@@ -147,6 +210,20 @@ func (ws *Workspace) SaveCertificateFiles(certs *client.CertificateFiles) error
 		}
 	}
 
+	if err := Verify(ws.workDir, ws.domain); err != nil {
+		return fmt.Errorf("证书文件校验失败: %w", err)
+	}
+
 	slog.Info("所有证书文件已保存", "domain", ws.domain)
 	return nil
 }
+
+// Timestamp 读取本地已部署证书的时间戳（time.log），不存在时返回 0
+func (ws *Workspace) Timestamp() int64 {
+	return GetDomainTimestamp(ws.workDir, ws.domain)
+}
+
+// SaveTimestamp 记录已部署证书的服务端时间戳，下次部署时据此跳过未变化的证书
+func (ws *Workspace) SaveTimestamp(timestamp int64) error {
+	return ws.SaveFileWithPerm("time.log", []byte(strconv.FormatInt(timestamp, 10)), 0644)
+}
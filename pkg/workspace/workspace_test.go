@@ -0,0 +1,196 @@
+package workspace
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nightlyone/lockfile"
+)
+
+// holdLock 以指定 PID 写入锁文件，模拟其他进程持有锁
+func holdLock(t *testing.T, ws *Workspace, pid int) string {
+	t.Helper()
+	if err := ws.Ensure(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(ws.domainDir, ".lock")
+	if err := os.WriteFile(path, []byte(strconv.Itoa(pid)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// lockFile 创建指向 path 的 lockfile
+func lockFile(t *testing.T, path string) lockfile.Lockfile {
+	t.Helper()
+	l, err := lockfile.New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+// deadPID 返回一个已退出进程的 PID
+func deadPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Process.Pid
+}
+
+func TestLockWithTimeout(t *testing.T) {
+	livePID := os.Getppid() // go test 的父进程在测试期间保持存活
+
+	t.Run("无锁时立即获取", func(t *testing.T) {
+		ws := NewWorkspace(t.TempDir(), "example.com")
+		ws.Ensure()
+		lock, err := ws.LockWithTimeout(time.Second)
+		if err != nil {
+			t.Fatalf("LockWithTimeout() error = %v", err)
+		}
+		lock.Unlock()
+	})
+
+	t.Run("锁被占用时 Lock 立即失败", func(t *testing.T) {
+		ws := NewWorkspace(t.TempDir(), "example.com")
+		holdLock(t, ws, livePID)
+
+		start := time.Now()
+		_, err := ws.Lock()
+		if err == nil {
+			t.Fatal("Lock() 应返回错误")
+		}
+		if elapsed := time.Since(start); elapsed >= lockRetryInterval {
+			t.Errorf("Lock() 耗时 %v，不应重试", elapsed)
+		}
+	})
+
+	t.Run("锁一直被占用时超时失败", func(t *testing.T) {
+		ws := NewWorkspace(t.TempDir(), "example.com")
+		holdLock(t, ws, livePID)
+
+		timeout := 3 * lockRetryInterval
+		start := time.Now()
+		_, err := ws.LockWithTimeout(timeout)
+		elapsed := time.Since(start)
+		if err == nil {
+			t.Fatal("LockWithTimeout() 应返回错误")
+		}
+		if !strings.Contains(err.Error(), strconv.Itoa(livePID)) {
+			t.Errorf("错误信息应包含持有进程 PID %d: %v", livePID, err)
+		}
+		if elapsed < timeout-lockRetryInterval || elapsed > timeout+time.Second {
+			t.Errorf("LockWithTimeout() 耗时 %v，期望约 %v", elapsed, timeout)
+		}
+	})
+
+	t.Run("等待期间锁被释放后获取成功", func(t *testing.T) {
+		ws := NewWorkspace(t.TempDir(), "example.com")
+		path := holdLock(t, ws, livePID)
+
+		release := 2 * lockRetryInterval
+		go func() {
+			time.Sleep(release)
+			os.Remove(path)
+		}()
+
+		start := time.Now()
+		lock, err := ws.LockWithTimeout(5 * time.Second)
+		if err != nil {
+			t.Fatalf("LockWithTimeout() error = %v", err)
+		}
+		defer lock.Unlock()
+		if elapsed := time.Since(start); elapsed < release {
+			t.Errorf("LockWithTimeout() 耗时 %v，应等待锁释放（%v）", elapsed, release)
+		}
+
+		data, _ := os.ReadFile(path)
+		if strings.TrimSpace(string(data)) != strconv.Itoa(os.Getpid()) {
+			t.Errorf("锁文件内容 = %q，应为当前进程 PID", data)
+		}
+	})
+
+	t.Run("持有进程已退出的残留锁被清理", func(t *testing.T) {
+		ws := NewWorkspace(t.TempDir(), "example.com")
+		path := holdLock(t, ws, deadPID(t))
+
+		if pid, alive := lockOwner(lockFile(t, path)); pid == 0 || alive {
+			t.Fatalf("lockOwner() = %d, %v，应识别为已退出的进程", pid, alive)
+		}
+
+		start := time.Now()
+		lock, err := ws.Lock()
+		if err != nil {
+			t.Fatalf("Lock() error = %v", err)
+		}
+		defer lock.Unlock()
+		if elapsed := time.Since(start); elapsed >= lockRetryInterval {
+			t.Errorf("残留锁应立即清理，耗时 %v", elapsed)
+		}
+	})
+}
+
+func TestLockWithTimeoutContention(t *testing.T) {
+	// 模拟另一个实例持有锁 hold 后释放，两个 goroutine 同时等待：
+	// 超时短于 hold 的失败并报告持有进程 PID，超时足够长的在锁释放后获取成功
+	livePID := os.Getppid()
+	dir := t.TempDir()
+	path := holdLock(t, NewWorkspace(dir, "example.com"), livePID)
+	hold := 3 * lockRetryInterval
+	go func() {
+		time.Sleep(hold)
+		os.Remove(path)
+	}()
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	timeouts := []time.Duration{lockRetryInterval, 5 * time.Second}
+	for i, timeout := range timeouts {
+		wg.Add(1)
+		go func(i int, timeout time.Duration) {
+			defer wg.Done()
+			lock, err := NewWorkspace(dir, "example.com").LockWithTimeout(timeout)
+			if err == nil {
+				lock.Unlock()
+			}
+			errs[i] = err
+		}(i, timeout)
+	}
+	wg.Wait()
+
+	if errs[0] == nil {
+		t.Error("超时短于持有时长时 LockWithTimeout() 应返回错误")
+	} else if !strings.Contains(errs[0].Error(), "PID "+strconv.Itoa(livePID)) {
+		t.Errorf("错误信息应包含持有进程 PID %d: %v", livePID, errs[0])
+	}
+	if errs[1] != nil {
+		t.Errorf("锁释放后 LockWithTimeout() error = %v", errs[1])
+	}
+}
+
+func TestLockOwner(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".lock")
+
+	if pid, alive := lockOwner(lockFile(t, path)); pid != 0 || alive {
+		t.Errorf("无锁文件时 lockOwner() = %d, %v", pid, alive)
+	}
+
+	os.WriteFile(path, []byte("garbage"), 0644)
+	if pid, alive := lockOwner(lockFile(t, path)); pid != 0 || alive {
+		t.Errorf("内容无效时 lockOwner() = %d, %v", pid, alive)
+	}
+
+	os.WriteFile(path, []byte(strconv.Itoa(os.Getppid())+"\n"), 0644)
+	if pid, alive := lockOwner(lockFile(t, path)); pid != os.Getppid() || !alive {
+		t.Errorf("存活进程 lockOwner() = %d, %v", pid, alive)
+	}
+}